@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// client talks to a running bridge over its local socket. Requests on the
+// socket are trusted, so no device token is needed; they name localhost
+// as their host, as localhost-only endpoints require.
+type client struct {
+	http   *http.Client
+	socket string
+	// header is added to every request
+	header http.Header
+}
+
+func newClient(opts *cliOptions) *client {
+	return &client{
+		socket: opts.socket,
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", opts.socket)
+				},
+			},
+		},
+	}
+}
+
+// do sends a request to the bridge and decodes the JSON response into out.
+// The raw body is returned for --json output.
+func (c *client) do(method, path string, body interface{}, out interface{}) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, "http://localhost"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if _, statErr := os.Stat(c.socket); os.IsNotExist(statErr) {
+			return nil, fmt.Errorf("bridge is not running (no socket at %s)", c.socket)
+		}
+		return nil, fmt.Errorf("failed to reach bridge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// health 在组件出错时返回 503，但响应体仍然有效
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusServiceUnavailable {
+		var apiErr struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return data, fmt.Errorf("%s (%s)", apiErr.Error, apiErr.Code)
+		}
+		return data, fmt.Errorf("bridge returned %s", resp.Status)
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return data, fmt.Errorf("invalid response: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// printJSON writes a raw response body to stdout
+func printJSON(data []byte) {
+	os.Stdout.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		fmt.Println()
+	}
+}
+
+// formatTime renders a timestamp for table output
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"echohelix/bridge/internal/auth"
+
+	"github.com/spf13/cobra"
+)
+
+func newDevicesCmd(opts *cliOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "devices",
+		Short: "Manage paired devices",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List paired devices",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var devices []auth.DeviceInfo
+			data, err := newClient(opts).do("GET", "/api/v2/devices", nil, &devices)
+			if err != nil {
+				return err
+			}
+			if opts.json {
+				printJSON(data)
+				return nil
+			}
+
+			if len(devices) == 0 {
+				fmt.Println("No paired devices")
+				return nil
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "DEVICE ID\tNAME\tPAIRED\tLAST USED\tFROM\tEXPIRES")
+			for _, d := range devices {
+				name := d.DeviceName
+				if d.Guest {
+					name += " (guest)"
+				}
+				if d.Admin {
+					name += " (admin)"
+				}
+				from := d.LastSeen.IP
+				if from == "" {
+					from = "-"
+				}
+				if d.LastSeen.Platform != "" {
+					from += " (" + strings.TrimSpace(d.LastSeen.Platform+" "+d.LastSeen.AppVersion) + ")"
+				}
+				if d.BoundNetwork != "" {
+					from += " bound " + d.BoundNetwork
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", d.DeviceID, name,
+					formatTime(d.CreatedAt), formatTime(d.LastUsedAt), from, formatTime(d.ExpiresAt))
+			}
+			return tw.Flush()
+		},
+	}
+
+	revoke := &cobra.Command{
+		Use:   "revoke <device-id>",
+		Short: "Revoke a paired device",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := newClient(opts).do("DELETE", "/api/v2/devices?id="+url.QueryEscape(args[0]), nil, nil)
+			if err != nil {
+				return err
+			}
+			if opts.json {
+				printJSON(data)
+				return nil
+			}
+			fmt.Printf("Revoked %s\n", args[0])
+			return nil
+		},
+	}
+
+	var withdraw bool
+	admin := &cobra.Command{
+		Use:   "admin <device-id>",
+		Short: "Let a device manage the bridge",
+		Long: "Grant a paired device the admin permission: revoking devices, changing the\n" +
+			"config, starting and stopping kernels, and installing updates. Devices\n" +
+			"paired from this machine are admins already.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "/api/v2/devices/admin?id=" + url.QueryEscape(args[0])
+			if withdraw {
+				path += "&admin=false"
+			}
+			var device auth.DeviceInfo
+			data, err := newClient(opts).do("POST", path, nil, &device)
+			if err != nil {
+				return err
+			}
+			if opts.json {
+				printJSON(data)
+				return nil
+			}
+			if device.Admin {
+				fmt.Printf("%s is now an admin\n", args[0])
+			} else {
+				fmt.Printf("%s is no longer an admin\n", args[0])
+			}
+			return nil
+		},
+	}
+	admin.Flags().BoolVar(&withdraw, "revoke", false, "withdraw the admin permission instead")
+
+	cmd.AddCommand(list, revoke, admin)
+	return cmd
+}
@@ -4,31 +4,55 @@ import (
 	"os"
 
 	"echohelix/bridge/internal/api"
-	"echohelix/bridge/internal/process"
 
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
 )
 
 func main() {
-	// Setup Logging
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
-	log.Info().Msg("EchoHelix Bridge v3 Starting...")
-
-	// 1. Initialize Process Manager
-	cwd, _ := os.Getwd()
-	pm := process.NewManager(cwd)
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
 
-	// Note: We are NOT auto-starting the Gemini Core here yet.
-	// We will add a /process/start endpoint later or let the user control it.
-	// For now, we focus on the Stop capability as requested.
+// cliOptions are the flags shared by every subcommand
+type cliOptions struct {
+	socket string
+	json   bool
+}
 
-	// 2. Initialize API Server
-	server := api.NewServer(pm)
+func newRootCmd() *cobra.Command {
+	opts := &cliOptions{}
+	serve := newServeCmd()
+
+	root := &cobra.Command{
+		Use:          "echohelix",
+		Short:        "EchoHelix Bridge",
+		Long:         "EchoHelix Bridge connects the mobile app to AI kernels on this machine.\nRun without a subcommand to start the bridge.",
+		SilenceUsage: true,
+		// 不带子命令时直接启动服务，保持旧的启动方式
+		RunE: serve.RunE,
+	}
+	root.Flags().AddFlagSet(serve.Flags())
 
-	// 3. Start Server
-	// Bridge listens on 8765 (standard EchoHelix Bridge port)
-	if err := server.Start(":8765"); err != nil {
-		log.Fatal().Err(err).Msg("Failed to start server")
+	defaultSocket := os.Getenv("SOCKET_PATH")
+	if defaultSocket == "" {
+		defaultSocket = api.DefaultSocketPath()
 	}
+	root.PersistentFlags().StringVar(&opts.socket, "socket", defaultSocket, "path of the running bridge's local socket")
+	root.PersistentFlags().BoolVar(&opts.json, "json", false, "print raw JSON responses")
+
+	root.AddCommand(
+		serve,
+		newPairCmd(opts),
+		newDevicesCmd(opts),
+		newSessionsCmd(opts),
+		newStatusCmd(opts),
+		newInstallServiceCmd(),
+		newUninstallServiceCmd(),
+		newStartCmd(),
+		newStopCmd(),
+		newRelayCmd(),
+		newMCPCmd(opts),
+	)
+	return root
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newMCPCmd(opts *cliOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve the bridge's MCP tools over stdio",
+		Long: "Relay MCP messages between stdin/stdout and the running bridge, for MCP\n" +
+			"clients that launch tool servers as subprocesses. Configure the client\n" +
+			"to run `echohelix mcp`.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient(opts)
+			// 工具调用（如 exec）可能运行较久，不设整体超时
+			c.http.Timeout = 0
+
+			reader := bufio.NewReader(os.Stdin)
+			for {
+				line, err := reader.ReadBytes('\n')
+				if line = bytes.TrimSpace(line); len(line) > 0 {
+					if err := relayMCP(c, line); err != nil {
+						return err
+					}
+				}
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+		},
+	}
+}
+
+// relayMCP posts one message to the bridge and writes any response as a
+// single line on stdout
+func relayMCP(c *client, message []byte) error {
+	resp, err := c.http.Post("http://localhost/api/v2/mcp", "application/json", bytes.NewReader(message))
+	if err != nil {
+		if _, statErr := os.Stat(c.socket); os.IsNotExist(statErr) {
+			return fmt.Errorf("bridge is not running (no socket at %s)", c.socket)
+		}
+		return fmt.Errorf("failed to reach bridge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusAccepted || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bridge returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	_, err = os.Stdout.Write(append(bytes.TrimSpace(data), '\n'))
+	return err
+}
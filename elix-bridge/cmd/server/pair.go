@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"echohelix/bridge/internal/auth"
+
+	"github.com/spf13/cobra"
+)
+
+func newPairCmd(opts *cliOptions) *cobra.Command {
+	var guest bool
+	cmd := &cobra.Command{
+		Use:   "pair",
+		Short: "Print a fresh pairing code",
+		Long: "Generate a new pairing code on the running bridge and print it.\nEnter the code in the mobile app to pair a device.\n\n" +
+			"With --guest the device gets a read-only token that expires after a day,\n" +
+			"so someone can watch sessions without being able to write or run anything.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var code struct {
+				auth.PairingCode
+				Fingerprint string `json:"bridge_key_fingerprint"`
+			}
+			path := "/api/v2/auth/code"
+			if guest {
+				path += "?guest=true"
+			}
+			data, err := newClient(opts).do("POST", path, nil, &code)
+			if err != nil {
+				return err
+			}
+			if opts.json {
+				printJSON(data)
+				return nil
+			}
+
+			fmt.Println(code.Code)
+			if code.Guest {
+				fmt.Println("Guest code: the device will have read-only access")
+			}
+			fmt.Printf("Expires in %s (at %s)\n", time.Until(code.ExpiresAt).Round(time.Second), code.ExpiresAt.Local().Format("15:04:05"))
+			if code.Fingerprint != "" {
+				fmt.Printf("Bridge key fingerprint: %s\n", code.Fingerprint)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&guest, "guest", false, "issue a read-only guest code")
+
+	// PAIRING_APPROVAL=true 时，设备输入配对码后需在此批准
+	requests := &cobra.Command{
+		Use:   "requests",
+		Short: "List pairings waiting for approval",
+		Long: "With PAIRING_APPROVAL=true a device that enters a valid code waits until the\n" +
+			"pairing is approved here or on the dashboard. Approve only when the\n" +
+			"fingerprint matches the one the device shows.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var pending []auth.PairingRequest
+			data, err := newClient(opts).do("GET", "/api/v2/auth/pair/requests", nil, &pending)
+			if err != nil {
+				return err
+			}
+			if opts.json {
+				printJSON(data)
+				return nil
+			}
+
+			if len(pending) == 0 {
+				fmt.Println("No pairings waiting for approval")
+				return nil
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "REQUEST ID\tDEVICE\tFINGERPRINT\tADDRESS\tEXPIRES")
+			for _, p := range pending {
+				name := p.DeviceName + " (" + p.DeviceID + ")"
+				if p.Guest {
+					name += " (guest)"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", p.ID, name, p.Fingerprint, p.RemoteAddr, formatTime(p.ExpiresAt))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.AddCommand(requests, newPairDecisionCmd(opts, "approve"), newPairDecisionCmd(opts, "deny"))
+	return cmd
+}
+
+// newPairDecisionCmd approves or denies a waiting pairing
+func newPairDecisionCmd(opts *cliOptions, decision string) *cobra.Command {
+	return &cobra.Command{
+		Use:   decision + " <request-id>",
+		Short: strings.ToUpper(decision[:1]) + decision[1:] + " a waiting pairing",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var req auth.PairingRequest
+			c := newClient(opts)
+			c.header = make(http.Header)
+			c.header.Set(auth.PairingDecisionHeader, decision)
+			data, err := c.do("POST", "/api/v2/auth/pair/"+decision+"?id="+url.QueryEscape(args[0]), nil, &req)
+			if err != nil {
+				return err
+			}
+			if opts.json {
+				printJSON(data)
+				return nil
+			}
+			fmt.Printf("%s: %s (%s, fingerprint %s)\n", req.Status, req.DeviceName, req.DeviceID, req.Fingerprint)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"echohelix/bridge/internal/relay"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func newRelayCmd() *cobra.Command {
+	var addr, secret, certFile, keyFile string
+
+	cmd := &cobra.Command{
+		Use:   "relay",
+		Short: "Run a relay server for bridges outside the LAN",
+		Long: "Run the built-in relay on a publicly reachable host. Bridges connect with\n" +
+			"RELAY_URL=wss://<host> and RELAY_SECRET=<secret>; phones then use the\n" +
+			"public URL shown by `echohelix status`. Device tokens are still checked by\n" +
+			"the bridge, the relay only forwards traffic.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+			if secret == "" {
+				secret = os.Getenv("RELAY_SECRET")
+			}
+			server, err := relay.NewServer(relay.ServerConfig{Secret: secret})
+			if err != nil {
+				return err
+			}
+			if (certFile == "") != (keyFile == "") {
+				return errors.New("--tls-cert and --tls-key must be set together")
+			}
+
+			httpServer := &http.Server{
+				Addr:              addr,
+				Handler:           server,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			log.Info().Str("addr", addr).Bool("tls", certFile != "").Msg("Relay listening")
+			if certFile != "" {
+				return httpServer.ListenAndServeTLS(certFile, keyFile)
+			}
+			log.Warn().Msg("Relay running without TLS; put it behind a TLS proxy")
+			return httpServer.ListenAndServe()
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", ":8443", "address to listen on")
+	cmd.Flags().StringVar(&secret, "secret", "", "shared secret bridges must present (default $RELAY_SECRET)")
+	cmd.Flags().StringVar(&certFile, "tls-cert", "", "TLS certificate file")
+	cmd.Flags().StringVar(&keyFile, "tls-key", "", "TLS key file")
+	return cmd
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"echohelix/bridge/internal/daemon"
+	"echohelix/bridge/pkg/bridge"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// serveOptions are the flags of the serve command
+type serveOptions struct {
+	addr         string
+	insecureCORS bool
+	logFile      string
+	workDir      string
+}
+
+func newServeCmd() *cobra.Command {
+	opts := &serveOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Start the bridge",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve(opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.addr, "addr", ":8765", "TCP address to listen on")
+	cmd.Flags().BoolVar(&opts.insecureCORS, "insecure-cors", false, "allow any origin to call the API (development only)")
+	cmd.Flags().StringVar(&opts.logFile, "log-file", "", "append logs to this file instead of the console")
+	cmd.Flags().StringVar(&opts.workDir, "workdir", "", "directory containing .env and cores/ (default: current directory)")
+	return cmd
+}
+
+func serve(opts *serveOptions) error {
+	if opts.workDir != "" {
+		if err := os.Chdir(opts.workDir); err != nil {
+			return err
+		}
+	}
+
+	// Setup Logging
+	var console io.Writer = zerolog.ConsoleWriter{Out: os.Stderr}
+	if opts.logFile != "" {
+		f, err := os.OpenFile(opts.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		console = zerolog.ConsoleWriter{Out: f, NoColor: true, TimeFormat: time.RFC3339}
+	}
+	log.Logger = log.Output(console)
+	log.Info().Msg("EchoHelix Bridge v3 Starting...")
+
+	// 1. Initialize the bridge
+	cwd, _ := os.Getwd()
+	b, err := bridge.New(bridge.Options{
+		Addr:         opts.addr,
+		WorkDir:      cwd,
+		InsecureCORS: opts.insecureCORS,
+		LogOutput:    console,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Note: We are NOT auto-starting the Gemini Core here yet.
+	// We will add a /process/start endpoint later or let the user control it.
+	// For now, we focus on the Stop capability as requested.
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	done := make(chan struct{})
+
+	// systemd / launchd 通过 SIGTERM 停止服务；Windows 服务通过服务控制管理器
+	if daemon.RunningAsService() {
+		go func() {
+			err := daemon.RunService(func() {
+				stop()
+				<-done
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("Service control failed")
+			}
+		}()
+	} else {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			stop()
+		}()
+	}
+
+	// 2. Run until stopped
+	// Bridge listens on 8765 (standard EchoHelix Bridge port)
+	err = b.Run(ctx)
+	close(done)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to start server")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"echohelix/bridge/internal/daemon"
+
+	"github.com/spf13/cobra"
+)
+
+func newInstallServiceCmd() *cobra.Command {
+	var workDir string
+	var addr string
+
+	cmd := &cobra.Command{
+		Use:   "install-service",
+		Short: "Register the bridge to start automatically",
+		Long: "Register the bridge as a systemd user unit (Linux), launchd agent (macOS),\n" +
+			"or Windows service, and start it. The service runs from the current\n" +
+			"directory (or --workdir) and logs to ~/.echohelix/logs/bridge.log.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := daemon.DefaultConfig()
+			if err != nil {
+				return err
+			}
+			if workDir != "" {
+				if config.WorkDir, err = filepath.Abs(workDir); err != nil {
+					return err
+				}
+			}
+			if addr != ":8765" {
+				config.Args = append(config.Args, "--addr", addr)
+			}
+
+			if err := daemon.Install(config); err != nil {
+				return err
+			}
+			fmt.Printf("Installed %s\n", daemon.DisplayName)
+			fmt.Printf("  executable: %s\n", config.Executable)
+			fmt.Printf("  workdir:    %s\n", config.WorkDir)
+			fmt.Printf("  log file:   %s\n", config.LogFile)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&workDir, "workdir", "", "directory containing .env and cores/ (default: current directory)")
+	cmd.Flags().StringVar(&addr, "addr", ":8765", "TCP address for the service to listen on")
+	return cmd
+}
+
+func newUninstallServiceCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "uninstall-service",
+		Short: "Stop and remove the bridge service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := daemon.Uninstall(); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s\n", daemon.DisplayName)
+			return nil
+		},
+	}
+}
+
+func newStartCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "start",
+		Short: "Start the installed bridge service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return daemon.Start()
+		},
+	}
+}
+
+func newStopCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the installed bridge service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return daemon.Stop()
+		},
+	}
+}
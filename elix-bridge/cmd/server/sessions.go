@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"echohelix/bridge/internal/session"
+
+	"github.com/spf13/cobra"
+)
+
+func newSessionsCmd(opts *cliOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Inspect chat sessions",
+	}
+
+	var status string
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "/api/v2/sessions"
+			if status != "" {
+				path += "?status=" + url.QueryEscape(status)
+			}
+
+			var resp struct {
+				Sessions []*session.Session `json:"sessions"`
+			}
+			data, err := newClient(opts).do("GET", path, nil, &resp)
+			if err != nil {
+				return err
+			}
+			if opts.json {
+				printJSON(data)
+				return nil
+			}
+
+			if len(resp.Sessions) == 0 {
+				fmt.Println("No sessions")
+				return nil
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tNAME\tSTATUS\tPROVIDER\tMESSAGES\tUPDATED")
+			for _, s := range resp.Sessions {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", s.ID, s.Name, s.Status,
+					s.Provider, s.MessageCount, formatTime(s.UpdatedAt))
+			}
+			return tw.Flush()
+		},
+	}
+	list.Flags().StringVar(&status, "status", "", "only list sessions with this status")
+
+	cmd.AddCommand(list)
+	return cmd
+}
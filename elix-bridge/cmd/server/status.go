@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"echohelix/bridge/internal/api"
+	"echohelix/bridge/internal/daemon"
+
+	"github.com/spf13/cobra"
+)
+
+func newStatusCmd(opts *cliOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the running bridge's health",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var health struct {
+				Status        string                         `json:"status"`
+				Version       string                         `json:"version"`
+				UptimeSeconds int64                          `json:"uptime_seconds"`
+				Components    map[string]api.ComponentHealth `json:"components"`
+			}
+			if !opts.json {
+				printServiceStatus()
+			}
+
+			data, err := newClient(opts).do("GET", "/api/v2/health/details", nil, &health)
+			if err != nil {
+				return err
+			}
+			if opts.json {
+				printJSON(data)
+			} else {
+				fmt.Printf("Bridge %s (version %s, up %s)\n", health.Status, health.Version,
+					(time.Duration(health.UptimeSeconds) * time.Second).String())
+
+				names := make([]string, 0, len(health.Components))
+				for name := range health.Components {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+
+				tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				for _, name := range names {
+					c := health.Components[name]
+					fmt.Fprintf(tw, "  %s\t%s\t%s\n", name, c.Status, c.Message)
+				}
+				tw.Flush()
+			}
+
+			// 便于脚本判断：桥接异常时返回非零退出码
+			if health.Status == "error" {
+				os.Exit(2)
+			}
+			return nil
+		},
+	}
+}
+
+// printServiceStatus shows the installed service, if any
+func printServiceStatus() {
+	st, err := daemon.Query()
+	if err != nil || !st.Installed {
+		return
+	}
+	state := "stopped"
+	if st.Running {
+		state = "running"
+	}
+	fmt.Printf("Service %s (%s)\n", state, st.Manager)
+}
@@ -0,0 +1,198 @@
+// Package agent provides long-running agent task orchestration for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Status is the state of an agent task
+type Status string
+
+const (
+	StatusQueued  Status = "queued"
+	StatusRunning Status = "running"
+	// StatusSucceeded means the tests passed, or the only step finished
+	// when the task has no test command
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+	// StatusStepLimit means the tests still failed after the last step
+	StatusStepLimit Status = "step_limit"
+	// StatusBudgetExceeded means the task used up its tokens or cost
+	StatusBudgetExceeded Status = "budget_exceeded"
+)
+
+const (
+	DefaultMaxSteps = 5
+	MaxSteps        = 20
+	// testOutputLimit is how much of the test output is fed back
+	testOutputLimit = 4000
+)
+
+// Limits bound a task. Zero MaxTokens or MaxCost means no cap.
+type Limits struct {
+	MaxSteps  int     `json:"max_steps"`
+	MaxTokens int     `json:"max_tokens,omitempty"`
+	MaxCost   float64 `json:"max_cost,omitempty"`
+}
+
+// Task is a goal the bridge works toward by prompting a kernel and
+// running the tests until they pass or a limit is reached
+type Task struct {
+	ID          string     `json:"id"`
+	Goal        string     `json:"goal"`
+	SessionID   string     `json:"session_id"`
+	Kernel      string     `json:"kernel"`
+	DeviceID    string     `json:"device_id,omitempty"`
+	Workspace   string     `json:"workspace"`
+	TestCommand string     `json:"test_command,omitempty"`
+	Limits      Limits     `json:"limits"`
+	Status      Status     `json:"status"`
+	Reason      string     `json:"reason,omitempty"`
+	JobID       string     `json:"job_id,omitempty"`
+	Steps       []Step     `json:"steps"`
+	TokensUsed  int        `json:"tokens_used"`
+	Cost        float64    `json:"cost"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// Step is one iteration: a checkpoint, a prompt and reply, and a test run
+type Step struct {
+	N          int         `json:"n"`
+	Checkpoint string      `json:"checkpoint,omitempty"` // 本步之前的工作区快照，可用于回滚
+	Prompt     string      `json:"prompt"`
+	Reply      string      `json:"reply,omitempty"`
+	MessageID  string      `json:"message_id,omitempty"`
+	Tokens     int         `json:"tokens"`
+	Cost       float64     `json:"cost,omitempty"`
+	Test       *TestResult `json:"test,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
+
+// TestResult is the outcome of the task's test command
+type TestResult struct {
+	Command  string `json:"command"`
+	RunID    string `json:"run_id,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Passed   bool   `json:"passed"`
+	Output   string `json:"output,omitempty"`
+}
+
+// Turn is a kernel's answer to one prompt
+type Turn struct {
+	Reply     string
+	MessageID string
+	Tokens    int
+	Cost      float64
+}
+
+// ErrBudgetExceeded is wrapped by Env.Prompt when the bridge's usage
+// budget refuses the prompt; the task ends as budget_exceeded
+var ErrBudgetExceeded = errors.New("usage budget exceeded")
+
+// Env is what a task needs from the bridge
+type Env interface {
+	// Checkpoint snapshots the workspace and returns the checkpoint ID
+	Checkpoint(label string) (string, error)
+	// Prompt sends text to the kernel and waits for the end of its turn
+	Prompt(ctx context.Context, text string) (Turn, error)
+	// Test runs the test command
+	Test(ctx context.Context, command string) (TestResult, error)
+}
+
+// Run drives task id to completion: each step checkpoints the
+// workspace, prompts the kernel with the goal or the last test failure,
+// and runs the tests. progress is called as steps start.
+func Run(ctx context.Context, store *Store, id string, env Env, progress func(step, max int, message string)) (Task, error) {
+	task, ok := store.Get(id)
+	if !ok {
+		return Task{}, ErrNotFound
+	}
+	store.Update(id, func(t *Task) { t.Status = StatusRunning })
+
+	var last *TestResult
+	for n := 1; ; n++ {
+		if status, reason := checkLimits(task, n); status != "" {
+			return store.Finish(id, status, reason), nil
+		}
+
+		prompt := task.Goal
+		if last != nil {
+			prompt = followUp(task.Goal, *last)
+		}
+		step := Step{N: n, Prompt: prompt, StartedAt: time.Now()}
+		progress(n, task.Limits.MaxSteps, "Step "+fmt.Sprint(n)+": prompting "+task.Kernel)
+
+		if cp, err := env.Checkpoint(fmt.Sprintf("agent %s step %d", id, n)); err == nil {
+			step.Checkpoint = cp
+		} else {
+			log.Warn().Err(err).Str("task", id).Msg("Agent checkpoint skipped")
+		}
+
+		turn, err := env.Prompt(ctx, prompt)
+		step.Reply, step.MessageID, step.Tokens, step.Cost = turn.Reply, turn.MessageID, turn.Tokens, turn.Cost
+		if err == nil && task.TestCommand != "" {
+			progress(n, task.Limits.MaxSteps, "Step "+fmt.Sprint(n)+": running "+task.TestCommand)
+			var result TestResult
+			result, err = env.Test(ctx, task.TestCommand)
+			if err == nil {
+				step.Test = &result
+				last = &result
+			}
+		}
+		now := time.Now()
+		step.FinishedAt = &now
+		if err != nil {
+			step.Error = err.Error()
+		}
+		task = store.addStep(id, step)
+
+		switch {
+		case ctx.Err() != nil:
+			return store.Finish(id, StatusCanceled, ""), ctx.Err()
+		case errors.Is(err, ErrBudgetExceeded):
+			return store.Finish(id, StatusBudgetExceeded, err.Error()), err
+		case err != nil:
+			return store.Finish(id, StatusFailed, err.Error()), err
+		case task.TestCommand == "":
+			return store.Finish(id, StatusSucceeded, ""), nil
+		case last.Passed:
+			return store.Finish(id, StatusSucceeded, fmt.Sprintf("Tests passed after %d steps", n)), nil
+		}
+	}
+}
+
+// checkLimits returns a final status when step n must not start
+func checkLimits(t Task, n int) (Status, string) {
+	switch {
+	case n > t.Limits.MaxSteps:
+		return StatusStepLimit, fmt.Sprintf("Tests still fail after %d steps", t.Limits.MaxSteps)
+	case t.Limits.MaxTokens > 0 && t.TokensUsed >= t.Limits.MaxTokens:
+		return StatusBudgetExceeded, fmt.Sprintf("Used %d of %d tokens", t.TokensUsed, t.Limits.MaxTokens)
+	case t.Limits.MaxCost > 0 && t.Cost >= t.Limits.MaxCost:
+		return StatusBudgetExceeded, fmt.Sprintf("Spent $%.4f of $%.4f", t.Cost, t.Limits.MaxCost)
+	}
+	return "", ""
+}
+
+// followUp feeds a failed test run back to the kernel
+func followUp(goal string, test TestResult) string {
+	output := strings.TrimSpace(test.Output)
+	if len(output) > testOutputLimit {
+		output = "...\n" + output[len(output)-testOutputLimit:]
+	}
+	return fmt.Sprintf("`%s` still fails (exit code %d):\n\n```\n%s\n```\n\nFix the failures and continue with the goal: %s",
+		test.Command, test.ExitCode, output, goal)
+}
@@ -0,0 +1,214 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TaskError is an agent task failure with a stable code
+type TaskError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *TaskError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrNotFound   = &TaskError{Code: "AGENT_TASK_NOT_FOUND", Message: "Agent task not found"}
+	ErrNotRunning = &TaskError{Code: "AGENT_TASK_NOT_RUNNING", Message: "Agent task already finished"}
+)
+
+// Store keeps agent tasks and their traces
+type Store struct {
+	mu          sync.Mutex
+	tasks       map[string]*Task
+	storagePath string
+	maxFinished int
+
+	saveMu sync.Mutex
+}
+
+// NewStore creates a store persisted at storagePath. Tasks that were
+// unfinished when the bridge stopped are marked failed; their traces
+// and checkpoints remain.
+func NewStore(storagePath string) *Store {
+	s := &Store{
+		tasks:       make(map[string]*Task),
+		storagePath: storagePath,
+		maxFinished: 100,
+	}
+	if err := s.load(); err != nil {
+		log.Warn().Err(err).Msg("Failed to load agent tasks")
+	}
+	for _, t := range s.tasks {
+		if t.FinishedAt == nil {
+			now := time.Now()
+			t.Status = StatusFailed
+			t.Reason = "The bridge stopped while the task was running"
+			t.FinishedAt = &now
+		}
+	}
+	return s
+}
+
+// Create stores a new queued task, applying the default step limit
+func (s *Store) Create(t Task) Task {
+	t.ID = generateID()
+	t.Status = StatusQueued
+	t.Steps = []Step{}
+	t.CreatedAt = time.Now()
+	if t.Limits.MaxSteps <= 0 {
+		t.Limits.MaxSteps = DefaultMaxSteps
+	}
+	if t.Limits.MaxSteps > MaxSteps {
+		t.Limits.MaxSteps = MaxSteps
+	}
+
+	s.mu.Lock()
+	s.tasks[t.ID] = &t
+	s.pruneLocked()
+	s.mu.Unlock()
+	s.save()
+	return t
+}
+
+// Get returns a task with its steps
+func (s *Store) Get(id string) (Task, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return Task{}, false
+	}
+	return t.copy(), true
+}
+
+// List returns all tasks, newest first
+func (s *Store) List() []Task {
+	s.mu.Lock()
+	list := make([]Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		list = append(list, t.copy())
+	}
+	s.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// Update changes a task in place
+func (s *Store) Update(id string, fn func(t *Task)) (Task, bool) {
+	s.mu.Lock()
+	t, ok := s.tasks[id]
+	if !ok {
+		s.mu.Unlock()
+		return Task{}, false
+	}
+	fn(t)
+	snapshot := t.copy()
+	s.mu.Unlock()
+	s.save()
+	return snapshot, true
+}
+
+// addStep appends a finished step and adds its usage to the task
+func (s *Store) addStep(id string, step Step) Task {
+	t, _ := s.Update(id, func(t *Task) {
+		t.Steps = append(t.Steps, step)
+		t.TokensUsed += step.Tokens
+		t.Cost += step.Cost
+	})
+	return t
+}
+
+// Finish ends a task with status
+func (s *Store) Finish(id string, status Status, reason string) Task {
+	t, _ := s.Update(id, func(t *Task) {
+		now := time.Now()
+		t.Status = status
+		t.Reason = reason
+		t.FinishedAt = &now
+	})
+	return t
+}
+
+func (t *Task) copy() Task {
+	c := *t
+	c.Steps = append([]Step{}, t.Steps...)
+	return c
+}
+
+// pruneLocked drops the oldest finished tasks beyond maxFinished
+func (s *Store) pruneLocked() {
+	var finished []*Task
+	for _, t := range s.tasks {
+		if t.FinishedAt != nil {
+			finished = append(finished, t)
+		}
+	}
+	if len(finished) <= s.maxFinished {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].FinishedAt.Before(*finished[j].FinishedAt) })
+	for _, t := range finished[:len(finished)-s.maxFinished] {
+		delete(s.tasks, t.ID)
+	}
+}
+
+func (s *Store) save() {
+	if s.storagePath == "" {
+		return
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	list := make([]Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		list = append(list, t.copy())
+	}
+	s.mu.Unlock()
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(s.storagePath), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(s.storagePath, data, 0600)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to save agent tasks")
+	}
+}
+
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.storagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var list []*Task
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, t := range list {
+		s.tasks[t.ID] = t
+	}
+	return nil
+}
+
+func generateID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
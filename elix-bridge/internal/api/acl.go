@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// lanPrefixes is the "lan" preset: private, link-local and loopback
+// ranges
+var lanPrefixes = []string{
+	"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16",
+	"::1/128", "fc00::/7", "fe80::/10",
+}
+
+// networkACL decides which client addresses may reach the API at all.
+// deny wins over allow; an empty allow list allows everything not denied.
+type networkACL struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// setupNetworkACL reads NETWORK_ALLOW and NETWORK_DENY: comma separated
+// CIDRs or single addresses. NETWORK_ALLOW also takes the preset "lan".
+// Invalid entries are logged and skipped.
+func (s *Server) setupNetworkACL() {
+	s.acl = networkACL{
+		allow: parsePrefixes("NETWORK_ALLOW", s.configSvc.Get("NETWORK_ALLOW")),
+		deny:  parsePrefixes("NETWORK_DENY", s.configSvc.Get("NETWORK_DENY")),
+	}
+	if len(s.acl.allow) > 0 || len(s.acl.deny) > 0 {
+		log.Info().Int("allow", len(s.acl.allow)).Int("deny", len(s.acl.deny)).Msg("Network ACL enabled")
+	}
+}
+
+func parsePrefixes(key, value string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			continue
+		case strings.EqualFold(entry, "lan"):
+			for _, p := range lanPrefixes {
+				prefixes = append(prefixes, netip.MustParsePrefix(p))
+			}
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				log.Warn().Str("key", key).Str("entry", entry).Msg("Invalid network address, ignoring")
+				continue
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			log.Warn().Str("key", key).Str("entry", entry).Msg("Invalid CIDR, ignoring")
+			continue
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+// allows reports whether addr may connect
+func (a networkACL) allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range a.deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	if len(a.allow) == 0 {
+		return true
+	}
+	for _, p := range a.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// networkACLMiddleware turns away clients outside the ACL with 403
+// before authentication, so they cannot even try to pair. Loopback, the
+// local socket and the relay (whose clients are not on this network)
+// are always let through, which keeps the owner from locking themselves
+// out.
+func (s *Server) networkACLMiddleware(next http.Handler) http.Handler {
+	if len(s.acl.allow) == 0 && len(s.acl.deny) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isSocketRequest(r) || isRelayRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		addr, err := netip.ParseAddr(clientIP(r))
+		if err == nil && (addr.IsLoopback() || s.acl.allows(addr)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		log.Ctx(r.Context()).Warn().Str("remote", r.RemoteAddr).Str("path", r.URL.Path).Msg("Request blocked by network ACL")
+		WriteError(w, CodeForbidden, http.StatusForbidden, "Address not allowed")
+	})
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/auth"
+
+	"github.com/gorilla/mux"
+)
+
+// Device activity is kept for activityHours in hourly buckets, with the
+// latest activityRecent requests. It lives in memory only and starts over
+// with the bridge.
+const (
+	activityHours  = 24
+	activityRecent = 50
+	activityRoutes = 10
+)
+
+// activityTracker counts each device's requests so a device behaving
+// strangely stands out: a phone that only reads suddenly running
+// commands, or requests from an unknown address
+type activityTracker struct {
+	mu      sync.Mutex
+	devices map[string]*deviceActivity
+}
+
+type deviceActivity struct {
+	hours  [activityHours]activityHour
+	recent []activityEntry // 最近的请求，新的在后
+}
+
+// activityHour counts the requests of one hour
+type activityHour struct {
+	start   time.Time
+	total   int
+	errors  int
+	denied  int
+	classes map[string]int
+	routes  map[string]int
+	ips     map[string]int
+}
+
+// activityEntry is one request of a device
+type activityEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Route  string    `json:"route"`
+	Status int       `json:"status"`
+	IP     string    `json:"ip"`
+}
+
+// activitySummary is a device's activity over the last day
+type activitySummary struct {
+	Window    string         `json:"window"`
+	Total     int            `json:"total"`
+	Errors    int            `json:"errors"`
+	Denied    int            `json:"denied"` // 401 与 403
+	Classes   map[string]int `json:"classes"`
+	Routes    []routeCount   `json:"routes"`
+	IPs       []string       `json:"ips"`
+	Hourly    []hourCount    `json:"hourly"`
+	Last      *activityEntry `json:"last_request,omitempty"`
+	SinceBoot bool           `json:"since_boot"` // 桥接启动不足 24 小时
+}
+
+type routeCount struct {
+	Route string `json:"route"`
+	Count int    `json:"count"`
+}
+
+type hourCount struct {
+	Hour  time.Time `json:"hour"`
+	Count int       `json:"count"`
+}
+
+func newActivityTracker() *activityTracker {
+	return &activityTracker{devices: make(map[string]*deviceActivity)}
+}
+
+// record counts a request of device
+func (t *activityTracker) record(device string, entry activityEntry, class string) {
+	hour := entry.Time.Truncate(time.Hour)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	d, ok := t.devices[device]
+	if !ok {
+		d = &deviceActivity{}
+		t.devices[device] = d
+	}
+	b := &d.hours[hour.Unix()/3600%activityHours]
+	if !b.start.Equal(hour) {
+		*b = activityHour{
+			start:   hour,
+			classes: make(map[string]int),
+			routes:  make(map[string]int),
+			ips:     make(map[string]int),
+		}
+	}
+	b.total++
+	b.classes[class]++
+	b.routes[entry.Method+" "+entry.Route]++
+	b.ips[entry.IP]++
+	switch {
+	case entry.Status == http.StatusUnauthorized || entry.Status == http.StatusForbidden:
+		b.denied++
+	case entry.Status >= 400:
+		b.errors++
+	}
+
+	d.recent = append(d.recent, entry)
+	if len(d.recent) > activityRecent {
+		d.recent = d.recent[len(d.recent)-activityRecent:]
+	}
+}
+
+// summary sums up the last day of a device
+func (t *activityTracker) summary(device string, bootedAt time.Time) activitySummary {
+	now := time.Now()
+	since := now.Add(-activityHours * time.Hour)
+	sum := activitySummary{
+		Window:    "24h",
+		Classes:   map[string]int{rateClassRead: 0, rateClassWrite: 0, rateClassExec: 0},
+		Routes:    []routeCount{},
+		IPs:       []string{},
+		Hourly:    []hourCount{},
+		SinceBoot: bootedAt.After(since),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	d, ok := t.devices[device]
+	if !ok {
+		return sum
+	}
+	routes := make(map[string]int)
+	ips := make(map[string]bool)
+	for _, b := range d.hours {
+		if b.start.IsZero() || !b.start.After(since.Truncate(time.Hour)) {
+			continue
+		}
+		sum.Total += b.total
+		sum.Errors += b.errors
+		sum.Denied += b.denied
+		for c, n := range b.classes {
+			sum.Classes[c] += n
+		}
+		for r, n := range b.routes {
+			routes[r] += n
+		}
+		for ip := range b.ips {
+			ips[ip] = true
+		}
+		sum.Hourly = append(sum.Hourly, hourCount{Hour: b.start, Count: b.total})
+	}
+	sort.Slice(sum.Hourly, func(i, j int) bool { return sum.Hourly[i].Hour.Before(sum.Hourly[j].Hour) })
+	for r, n := range routes {
+		sum.Routes = append(sum.Routes, routeCount{Route: r, Count: n})
+	}
+	sort.Slice(sum.Routes, func(i, j int) bool {
+		if sum.Routes[i].Count != sum.Routes[j].Count {
+			return sum.Routes[i].Count > sum.Routes[j].Count
+		}
+		return sum.Routes[i].Route < sum.Routes[j].Route
+	})
+	if len(sum.Routes) > activityRoutes {
+		sum.Routes = sum.Routes[:activityRoutes]
+	}
+	for ip := range ips {
+		sum.IPs = append(sum.IPs, ip)
+	}
+	sort.Strings(sum.IPs)
+	if n := len(d.recent); n > 0 {
+		last := d.recent[n-1]
+		sum.Last = &last
+	}
+	return sum
+}
+
+// recentEntries returns a device's latest requests, newest first
+func (t *activityTracker) recentEntries(device string) []activityEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := []activityEntry{}
+	if d, ok := t.devices[device]; ok {
+		for i := len(d.recent) - 1; i >= 0; i-- {
+			entries = append(entries, d.recent[i])
+		}
+	}
+	return entries
+}
+
+// forget drops the activity of a revoked device
+func (t *activityTracker) forget(device string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.devices, device)
+}
+
+// recordActivity counts an API request against the device that made it.
+// Routes are recorded by template so IDs don't split the counts.
+func (s *Server) recordActivity(r *http.Request, device string, status int) {
+	if device == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+		return
+	}
+	route := r.URL.Path
+	var match mux.RouteMatch
+	if s.router.Match(r, &match) && match.Route != nil {
+		if tmpl, err := match.Route.GetPathTemplate(); err == nil {
+			route = tmpl
+		}
+	}
+	s.activity.record(device, activityEntry{
+		Time:   time.Now(),
+		Method: r.Method,
+		Route:  route,
+		Status: status,
+		IP:     clientIP(r),
+	}, rateClass(r))
+}
+
+// HandleDeviceActivity returns a device's requests over the last day: counts
+// per route class and route, the addresses it came from, and its latest
+// requests. Devices can see their own activity; others need admin.
+// GET /api/v2/auth/devices/{id}/activity
+func (s *Server) HandleDeviceActivity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		id = r.URL.Query().Get("id")
+	}
+	if token, ok := auth.TokenFromContext(r.Context()); ok && token.DeviceID != id && !token.HasPermission(auth.PermissionAdmin) {
+		WriteError(w, CodeForbidden, http.StatusForbidden, map[string]interface{}{
+			"required_permission": auth.PermissionAdmin,
+			"permissions":         token.Permissions,
+		})
+		return
+	}
+
+	var device *auth.DeviceInfo
+	for _, t := range s.authService.ListActiveDevices() {
+		if t.DeviceID == id {
+			info := t.Info()
+			device = &info
+			break
+		}
+	}
+	if device == nil {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "device not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device":   device,
+		"activity": s.activity.summary(id, s.startedAt),
+		"recent":   s.activity.recentEntries(id),
+	})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/rs/cors"
+	"github.com/rs/zerolog/log"
+)
+
+// SetInsecureCORS allows every origin. Only meant for local development
+// (--insecure-cors); must be called before Start.
+func (s *Server) SetInsecureCORS(insecure bool) {
+	s.insecureCORS = insecure
+}
+
+// corsHandler builds the CORS policy. Origins come from CORS_ALLOWED_ORIGINS
+// (comma separated, "*" wildcards allowed, e.g. "https://*.example.com");
+// when unset only localhost origins are accepted. Native apps send no
+// Origin header and are unaffected.
+func (s *Server) corsHandler() *cors.Cors {
+	options := cors.Options{
+		AllowedMethods:   []string{"GET", "POST", "OPTIONS", "DELETE", "PUT"},
+		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{requestIDHeader, "Retry-After", "ETag", idempotentReplayed},
+		AllowCredentials: true,
+	}
+
+	if s.insecureCORS {
+		log.Warn().Msg("Insecure CORS enabled: any origin may call the API")
+		options.AllowedOrigins = []string{"*"}
+		return cors.New(options)
+	}
+
+	// 每次请求时读取配置，修改后无需重启
+	options.AllowOriginFunc = func(origin string) bool {
+		return s.originAllowed(origin)
+	}
+	return cors.New(options)
+}
+
+// originAllowed reports whether origin may make credentialed requests
+func (s *Server) originAllowed(origin string) bool {
+	return originMatches(s.configSvc.Get("CORS_ALLOWED_ORIGINS"), origin)
+}
+
+// originMatches reports whether origin is in the comma separated list
+// of patterns; an empty list allows localhost origins only
+func originMatches(allowed, origin string) bool {
+	if allowed == "" {
+		return isLocalOrigin(origin)
+	}
+
+	for _, pattern := range strings.Split(allowed, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if pattern == origin {
+			return true
+		}
+		if ok, _ := path.Match(pattern, origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalOrigin reports whether origin points at this machine
+func isLocalOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return u.Scheme == "http" || u.Scheme == "https"
+	}
+	return false
+}
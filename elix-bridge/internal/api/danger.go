@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"echohelix/bridge/internal/changes"
+	"echohelix/bridge/internal/danger"
+
+	"github.com/rs/zerolog/log"
+)
+
+// setupConfirmations configures confirmation of dangerous operations.
+// CONFIRM_TOKEN_TTL_SECONDS (default 120) is how long a minted token is
+// valid; DANGER_DELETE_THRESHOLD (default 10) is how many deletions make
+// a change set dangerous.
+func (s *Server) setupConfirmations() {
+	ttl := 2 * time.Minute
+	if n := s.configInt("CONFIRM_TOKEN_TTL_SECONDS"); n > 0 {
+		ttl = time.Duration(n) * time.Second
+	}
+	s.confirmer = danger.NewConfirmer(ttl)
+	s.deleteThreshold = 10
+	if n := s.configInt("DANGER_DELETE_THRESHOLD"); n > 0 {
+		s.deleteThreshold = n
+	}
+}
+
+// classify returns why op is dangerous, or "" when it needs no
+// confirmation
+func (s *Server) classify(op danger.Operation) string {
+	switch op.Action {
+	case danger.ActionExec:
+		return danger.ClassifyCommand(op.Target)
+	case danger.ActionFSWrite:
+		if s.processManager == nil || !filepath.IsAbs(op.Target) {
+			return ""
+		}
+		if _, inside := workspaceRel(filepath.Clean(s.processManager.WorkDir), op.Target); !inside {
+			return danger.ClassOutsideWorkspace
+		}
+	case danger.ActionChangeApply:
+		if c, ok := s.changeQueue.Get(op.Target); ok && s.bulkDelete(c) {
+			return danger.ClassBulkDelete
+		}
+	}
+	return ""
+}
+
+// bulkDelete reports whether applying c deletes more files than the
+// threshold
+func (s *Server) bulkDelete(c changes.Change) bool {
+	deletes := 0
+	for _, f := range c.Files {
+		if f.Action == changes.ActionDelete {
+			deletes++
+		}
+	}
+	return deletes > s.deleteThreshold
+}
+
+// confirmed checks that a dangerous operation carries a valid
+// confirmation token, from the X-Confirm-Token header or the request's
+// own confirm_token. Without one it answers 409 DANGEROUS_OPERATION with
+// the operation to confirm and returns false. A safe operation (class "")
+// is always confirmed.
+func (s *Server) confirmed(w http.ResponseWriter, r *http.Request, op danger.Operation, token string) bool {
+	if op.Class == "" {
+		return true
+	}
+	if token == "" {
+		token = r.Header.Get("X-Confirm-Token")
+	}
+	details := map[string]interface{}{
+		"operation":   op,
+		"fingerprint": op.Fingerprint(),
+	}
+	if token == "" {
+		WriteError(w, CodeDangerousOperation, http.StatusConflict, details)
+		return false
+	}
+	if err := s.confirmer.Redeem(token, op, deviceID(r)); err != nil {
+		details["token_error"] = err.Error()
+		WriteError(w, CodeDangerousOperation, http.StatusConflict, details)
+		return false
+	}
+	log.Ctx(r.Context()).Warn().
+		Str("action", op.Action).
+		Str("target", op.Target).
+		Str("class", op.Class).
+		Msg("Confirmed dangerous operation")
+	return true
+}
+
+// HandleConfirm mints a single-use token confirming one dangerous
+// operation, as named in a DANGEROUS_OPERATION error. The token is bound
+// to the operation and the calling device and expires after
+// CONFIRM_TOKEN_TTL_SECONDS.
+// POST /api/v2/confirm {"action": "exec", "target": "rm -rf build"}
+func (s *Server) HandleConfirm(w http.ResponseWriter, r *http.Request) {
+	var op danger.Operation
+	if err := json.NewDecoder(r.Body).Decode(&op); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !danger.ValidAction(op.Action) || op.Target == "" {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "action must be exec, fs.write or change.apply, with a target")
+		return
+	}
+	op.Class = s.classify(op)
+	if op.Class == "" {
+		WriteError(w, CodeInvalidRequest, http.StatusBadRequest, "this operation does not need confirmation")
+		return
+	}
+
+	token := s.confirmer.Mint(op, deviceID(r))
+	log.Ctx(r.Context()).Info().
+		Str("action", op.Action).
+		Str("class", op.Class).
+		Str("fingerprint", token.Fingerprint).
+		Msg("Minted confirmation token")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(token)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/dashboard"
+
+	"github.com/rs/zerolog/log"
+)
+
+// dashboardCookie remembers a DASHBOARD_TOKEN given as ?token= so the
+// page's own requests are authorized
+const dashboardCookie = "echohelix_dashboard"
+
+// setupDashboard connects the dashboard's session and kernel panels
+func (s *Server) setupDashboard() {
+	kernel := dashboard.KernelControl{}
+	if s.processManager != nil {
+		kernel = dashboard.KernelControl{
+			Status: s.processManager.Status,
+			Start:  s.startKernel,
+			Stop:   s.stopKernel,
+		}
+	}
+	s.dashboardHandler.SetControls(s.sessionMgr, kernel)
+	s.dashboardHandler.SetEvents(s.eventBus)
+
+	// 开发模式：直接从磁盘读取页面，修改后刷新即可生效
+	if dir := s.configSvc.Get("DASHBOARD_DEV_DIR"); dir != "" {
+		if err := s.dashboardHandler.SetDevDir(dir); err != nil {
+			log.Warn().Err(err).Str("dir", dir).Msg("Failed to load dashboard from disk, using embedded assets")
+		} else {
+			log.Info().Str("dir", dir).Msg("Serving dashboard from disk")
+		}
+	}
+}
+
+// LogWriter receives zerolog JSON output for the dashboard's log view.
+// Add it to the global logger next to the console writer.
+func (s *Server) LogWriter() io.Writer {
+	return s.dashboardLogger
+}
+
+// dashboardAuth limits the dashboard to this machine, or to browsers
+// presenting DASHBOARD_TOKEN (header, ?token=, or cookie). State-changing
+// requests must also come from a dashboard origin, so other websites
+// cannot drive a local dashboard from the user's browser.
+func (s *Server) dashboardAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// IsLocalRequest 同时校验 Host，以防 DNS rebinding
+		if !auth.IsLocalRequest(r) && !isSocketRequest(r) && !s.dashboardTokenValid(w, r) {
+			log.Ctx(r.Context()).Warn().Str("remote", r.RemoteAddr).Msg("Rejected dashboard request")
+			WriteError(w, CodeForbidden, http.StatusForbidden, "the dashboard is only available from this machine or with DASHBOARD_TOKEN")
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if origin := r.Header.Get("Origin"); origin != "" && !isLocalOrigin(origin) && origin != requestOrigin(r) {
+				WriteError(w, CodeForbidden, http.StatusForbidden, "cross-origin dashboard request")
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// dashboardTokenValid checks the request against DASHBOARD_TOKEN, setting
+// the cookie when the token came from the query string
+func (s *Server) dashboardTokenValid(w http.ResponseWriter, r *http.Request) bool {
+	expected := s.configSvc.Get("DASHBOARD_TOKEN")
+	if expected == "" {
+		return false
+	}
+	matches := func(v string) bool {
+		return v != "" && subtle.ConstantTimeCompare([]byte(v), []byte(expected)) == 1
+	}
+
+	if c, err := r.Cookie(dashboardCookie); err == nil && matches(c.Value) {
+		return true
+	}
+	if h := r.Header.Get("Authorization"); len(h) > 7 && matches(h[7:]) {
+		return true
+	}
+	if q := r.URL.Query().Get("token"); matches(q) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     dashboardCookie,
+			Value:    q,
+			Path:     "/dashboard",
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+			Secure:   r.TLS != nil,
+		})
+		return true
+	}
+	return false
+}
+
+// requestOrigin is the origin the dashboard page itself was served from
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"echohelix/bridge/internal/workspace"
+
+	"github.com/rs/zerolog/log"
+)
+
+// builtinDefaults apply when neither the config nor the workspace set a
+// default
+var builtinDefaults = workspace.Defaults{
+	Provider:    "gemini",
+	Model:       "gemini-2.5-flash",
+	Kernel:      "gemini",
+	SessionName: "New Session",
+}
+
+// Where a default came from
+const (
+	sourceBuiltin   = "builtin"
+	sourceConfig    = "config"
+	sourceWorkspace = "workspace"
+)
+
+// sessionDefaults are the resolved defaults and the source of each
+type sessionDefaults struct {
+	workspace.Defaults
+	Sources map[string]string `json:"sources"`
+}
+
+// defaults resolves the defaults for a workspace: the built-in ones,
+// overridden by DEFAULT_PROVIDER, DEFAULT_MODEL, DEFAULT_KERNEL and
+// DEFAULT_SESSION_NAME in the config, overridden by the workspace's
+// .echohelix/defaults.json. A layer that changes the provider without
+// naming a model or kernel switches to that provider's first model and
+// its kernel.
+func (s *Server) defaults(dir string) sessionDefaults {
+	d := sessionDefaults{
+		Defaults: builtinDefaults,
+		Sources: map[string]string{
+			"provider":     sourceBuiltin,
+			"model":        sourceBuiltin,
+			"kernel":       sourceBuiltin,
+			"session_name": sourceBuiltin,
+		},
+	}
+	d.apply(s, sourceConfig, workspace.Defaults{
+		Provider:    s.configSvc.Get("DEFAULT_PROVIDER"),
+		Model:       s.configSvc.Get("DEFAULT_MODEL"),
+		Kernel:      s.configSvc.Get("DEFAULT_KERNEL"),
+		SessionName: s.configSvc.Get("DEFAULT_SESSION_NAME"),
+	})
+	if dir != "" {
+		ws, ok, err := workspace.LoadDefaults(dir)
+		if err != nil {
+			log.Warn().Err(err).Str("workspace", dir).Msg("Ignoring workspace defaults")
+		} else if ok {
+			d.apply(s, sourceWorkspace, ws)
+		}
+	}
+	return d
+}
+
+func (d *sessionDefaults) apply(s *Server, source string, layer workspace.Defaults) {
+	if layer.Provider != "" {
+		if layer.Provider != d.Provider {
+			if layer.Model == "" {
+				d.Model, d.Sources["model"] = s.firstModel(layer.Provider), source
+			}
+			if p, ok := s.providerRegistry.Find(layer.Provider); ok && p.Kernel != "" && layer.Kernel == "" {
+				d.Kernel, d.Sources["kernel"] = p.Kernel, source
+			}
+		}
+		d.Provider, d.Sources["provider"] = layer.Provider, source
+	}
+	if layer.Model != "" {
+		d.Model, d.Sources["model"] = layer.Model, source
+	}
+	if layer.Kernel != "" {
+		d.Kernel, d.Sources["kernel"] = layer.Kernel, source
+	}
+	if layer.SessionName != "" {
+		d.SessionName, d.Sources["session_name"] = layer.SessionName, source
+	}
+}
+
+// firstModel is the first model the registry lists for a provider, or
+// "" for providers it does not know
+func (s *Server) firstModel(provider string) string {
+	p, ok := s.providerRegistry.Find(provider)
+	if !ok || len(p.Models) == 0 {
+		return ""
+	}
+	return p.Models[0].ID
+}
+
+// HandleDefaults returns the provider, model, kernel and session name
+// used when a request leaves them out, and where each comes from
+// (builtin, config or workspace)
+// GET /api/v2/defaults?workspace=
+func (s *Server) HandleDefaults(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dir := s.resolveWorkDir(r.URL.Query().Get("workspace"))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workspace": dir,
+		"defaults":  s.defaults(dir),
+	})
+}
@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+
+package api
+
+import "golang.org/x/sys/unix"
+
+// diskFree returns the bytes available to the current user on the volume
+// containing path
+func diskFree(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	// 字段类型随平台不同（FreeBSD 上 Bavail 为 int64）
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
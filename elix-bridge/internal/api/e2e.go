@@ -0,0 +1,206 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/e2e"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// e2eHeader marks a request whose body is an e2e envelope and asks for an
+// encrypted response. WebSocket clients that cannot set headers use the
+// "e2e" query parameter instead.
+const e2eHeader = "X-EchoHelix-E2E"
+
+// e2eIDHeader carries the request ID, "<unix seconds>.<random>", bound
+// into both envelopes and accepted once per device
+const e2eIDHeader = "X-EchoHelix-E2E-ID"
+
+// e2eEnvelopeHeader carries the request envelope, base64-encoded, for
+// requests without a body; its plaintext is empty but it authenticates
+// the request line and ID
+const e2eEnvelopeHeader = "X-EchoHelix-E2E-Envelope"
+
+type e2eKeyContextKey struct{}
+
+// setupE2E loads the bridge identity key used for end-to-end encryption.
+// Without it pairing still works, just without key exchange.
+func (s *Server) setupE2E() {
+	identity, err := e2e.LoadOrCreateIdentity(filepath.Join(s.echoDir, "identity_key"))
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load identity key, end-to-end encryption disabled")
+		return
+	}
+	s.e2eIdentity = identity
+	s.e2eReplay = e2e.NewReplayGuard()
+	s.authHandler.SetE2EIdentity(identity)
+}
+
+// e2eKeyFromContext returns the device key for a WebSocket negotiated
+// with end-to-end encryption
+func e2eKeyFromContext(ctx context.Context) ([]byte, bool) {
+	key, ok := ctx.Value(e2eKeyContextKey{}).([]byte)
+	return key, ok
+}
+
+// e2eMiddleware decrypts request bodies and encrypts responses for
+// requests that opt in. It runs after authentication since the key
+// belongs to the calling device. Plaintext requests from devices are
+// rejected with E2E_REQUIRED=true, and always through the relay from a
+// device that has a key, since the relay sees the bearer token.
+//
+// Every encrypted request carries a request envelope, in the body or in
+// e2eEnvelopeHeader, sealed over the method, the full request URI and
+// its e2eIDHeader, so the relay can neither alter the query nor replay
+// the request.
+func (s *Server) e2eMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version := r.Header.Get(e2eHeader)
+		if version == "" {
+			version = r.URL.Query().Get("e2e")
+		}
+		if version == "" || r.Method == http.MethodOptions {
+			// 批量子请求随外层请求整体加密
+			if r.Method != http.MethodOptions && !isBatchRequest(r) && s.e2eRequired(r) {
+				WriteError(w, CodeE2ERequired, http.StatusForbidden, nil)
+				return
+			}
+			next(w, r)
+			return
+		}
+		if version != e2e.Version {
+			WriteError(w, CodeInvalidRequest, http.StatusBadRequest, "unsupported "+e2eHeader+" version")
+			return
+		}
+
+		key, err := s.deviceE2EKey(r)
+		if err != nil {
+			WriteError(w, CodeE2EUnavailable, http.StatusBadRequest, nil)
+			return
+		}
+
+		// WebSocket 按消息加密，由处理器完成
+		if websocket.IsWebSocketUpgrade(r) {
+			next(w, r.WithContext(context.WithValue(r.Context(), e2eKeyContextKey{}, key)))
+			return
+		}
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			WriteError(w, CodeInvalidRequest, http.StatusBadRequest, "event streams do not support end-to-end encryption; use the WebSocket endpoints")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+			return
+		}
+		envelope := body
+		if len(envelope) == 0 {
+			if envelope, err = base64.StdEncoding.DecodeString(r.Header.Get(e2eEnvelopeHeader)); err != nil {
+				WriteError(w, CodeDecryptFailed, http.StatusBadRequest, nil)
+				return
+			}
+		}
+		id := r.Header.Get(e2eIDHeader)
+		plaintext, err := e2e.Open(key, envelope, e2eAAD(r, id, "request"))
+		if err != nil {
+			WriteError(w, CodeDecryptFailed, http.StatusBadRequest, nil)
+			return
+		}
+		// 仅在信封验证通过后登记 ID，伪造的请求无法占用他人的 ID
+		token, _ := auth.TokenFromContext(r.Context())
+		if err := s.e2eReplay.Check(token.DeviceID, id); err != nil {
+			WriteError(w, CodeE2EReplay, http.StatusBadRequest, nil)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(plaintext))
+		r.ContentLength = int64(len(plaintext))
+
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next(rec, r)
+
+		sealed, err := e2e.Seal(key, rec.body.Bytes(), e2eAAD(r, id, "response"))
+		if err != nil {
+			WriteError(w, CodeInternal, http.StatusInternalServerError, nil)
+			return
+		}
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		if rec.status == http.StatusNotModified {
+			// 304 不带响应体，无需加密
+			w.WriteHeader(rec.status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(sealed)))
+		w.Header().Set(e2eHeader, e2e.Version)
+		w.WriteHeader(rec.status)
+		w.Write(sealed)
+	}
+}
+
+// e2eRequired reports whether r must be encrypted: with E2E_REQUIRED=true,
+// or when it came through the relay from a device that has a key
+func (s *Server) e2eRequired(r *http.Request) bool {
+	if s.configSvc.Get("E2E_REQUIRED") == "true" {
+		return true
+	}
+	if !isRelayRequest(r) {
+		return false
+	}
+	_, err := s.deviceE2EKey(r)
+	return err == nil
+}
+
+// deviceE2EKey derives the key shared with the authenticated device
+func (s *Server) deviceE2EKey(r *http.Request) ([]byte, error) {
+	token, ok := auth.TokenFromContext(r.Context())
+	if !ok || token.E2EPublicKey == "" || s.e2eIdentity == nil {
+		return nil, e2e.ErrInvalidKey
+	}
+	return s.e2eIdentity.DeviceKey(token.E2EPublicKey, token.DeviceID)
+}
+
+// e2eAAD binds an envelope to the request it belongs to: its method, its
+// full request URI with the query, its ID and the direction. A relay
+// cannot move a payload to another endpoint or query, or swap responses.
+func e2eAAD(r *http.Request, id, direction string) []byte {
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	return []byte(r.Method + " " + uri + " " + id + " " + direction)
+}
+
+// bufferedResponse collects a handler's response for encryption
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wrote {
+		b.status = status
+		b.wrote = true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wrote = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/config"
+	"echohelix/bridge/internal/events"
+	"echohelix/bridge/internal/process"
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/terminal"
+	"echohelix/bridge/internal/workspace"
+)
+
+// 以下访问器供嵌入方（pkg/bridge）直接调用各管理器，不经过 HTTP
+
+// AuthService returns the pairing and token service
+func (s *Server) AuthService() *auth.Service {
+	return s.authService
+}
+
+// Sessions returns the chat session manager
+func (s *Server) Sessions() *session.Manager {
+	return s.sessionMgr
+}
+
+// Processes returns the kernel process manager
+func (s *Server) Processes() *process.Manager {
+	return s.processManager
+}
+
+// Terminals returns the terminal manager
+func (s *Server) Terminals() *terminal.Manager {
+	return s.terminalMgr
+}
+
+// Workspaces returns the workspace service
+func (s *Server) Workspaces() *workspace.Service {
+	return s.workspaceSvc
+}
+
+// Config returns the .env backed configuration
+func (s *Server) Config() *config.Service {
+	return s.configSvc
+}
+
+// Events returns the bus the bridge publishes its events on
+func (s *Server) Events() *events.Bus {
+	return s.eventBus
+}
+
+// DataDir returns the directory the bridge stores its data in
+func (s *Server) DataDir() string {
+	return s.echoDir
+}
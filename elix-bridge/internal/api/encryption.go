@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"echohelix/bridge/internal/config"
+	"echohelix/bridge/internal/vault"
+
+	"github.com/rs/zerolog/log"
+)
+
+// openDataVault unlocks encryption at rest of session transcripts and
+// auth state. DATA_ENCRYPTION selects the key source: "passphrase" or
+// "keychain" (macOS keychain, or Secret Service on Linux); empty leaves
+// data in plaintext. The passphrase is taken from the DATA_PASSPHRASE
+// environment variable or the file named by DATA_PASSPHRASE_FILE, never
+// from .env, which the config API can read back. An encrypted data
+// directory that cannot be unlocked stops startup with an error. created
+// reports a vault set up just now, whose existing plaintext should be
+// rewritten.
+func openDataVault(echoDir string, configSvc *config.Service) (v *vault.Vault, created bool, err error) {
+	source := strings.ToLower(configSvc.Get("DATA_ENCRYPTION"))
+	if source == "" || source == "off" {
+		if vault.IsConfigured(echoDir) {
+			return nil, false, fmt.Errorf("data directory %s is encrypted: %w", echoDir, vault.ErrLocked)
+		}
+		return nil, false, nil
+	}
+
+	passphrase := os.Getenv("DATA_PASSPHRASE")
+	if file := configSvc.Get("DATA_PASSPHRASE_FILE"); passphrase == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, false, fmt.Errorf("read DATA_PASSPHRASE_FILE: %w", err)
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	}
+
+	v, created, err = vault.Unlock(echoDir, source, passphrase)
+	if err != nil {
+		return nil, false, fmt.Errorf("unlock data directory (%s): %w", source, err)
+	}
+	if created {
+		log.Info().Str("source", source).Msg("Encryption at rest enabled")
+	} else {
+		log.Info().Str("source", source).Msg("Data directory unlocked")
+	}
+	return v, created, nil
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"echohelix/bridge/internal/agent"
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/changes"
+	"echohelix/bridge/internal/fs"
+	"echohelix/bridge/internal/fslock"
+	"echohelix/bridge/internal/git"
+	"echohelix/bridge/internal/i18n"
+	"echohelix/bridge/internal/notebook"
+	"echohelix/bridge/internal/plugin"
+	"echohelix/bridge/internal/prompts"
+	"echohelix/bridge/internal/secrets"
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/shell"
+	"echohelix/bridge/internal/usage"
+)
+
+// Error codes returned in the "code" field of error responses.
+// Domain packages define their own codes (AuthError, SessionError,
+// GitError, ShellError, PromptError, ChangeError, LockError, notebook.Error); these cover errors raised by the handlers.
+// The default message of each code is its "error.<CODE>" catalog entry.
+const (
+	CodeInvalidBody          = "INVALID_BODY"
+	CodeInvalidRequest       = "INVALID_REQUEST"
+	CodeMissingParameter     = "MISSING_PARAMETER"
+	CodeInvalidParameter     = "INVALID_PARAMETER"
+	CodeNotFound             = "NOT_FOUND"
+	CodeFileNotFound         = "FILE_NOT_FOUND"
+	CodeIsDirectory          = "IS_DIRECTORY"
+	CodeWriteFailed          = "WRITE_FAILED"
+	CodeJobNotFound          = "JOB_NOT_FOUND"
+	CodeTaskNotFound         = "TASK_NOT_FOUND"
+	CodeTerminalNotFound     = "TERMINAL_NOT_FOUND"
+	CodeConfirmationRequired = "CONFIRMATION_REQUIRED"
+	CodeConflict             = "CONFLICT"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeNotConfigured        = "NOT_CONFIGURED"
+	CodeNotInitialized       = "NOT_INITIALIZED"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeE2ERequired          = "E2E_REQUIRED"
+	CodeE2EUnavailable       = "E2E_UNAVAILABLE"
+	CodeDecryptFailed        = "DECRYPT_FAILED"
+	CodeE2EReplay            = "E2E_REPLAY"
+	CodeUpstreamError        = "UPSTREAM_ERROR"
+	CodeUnavailable          = "UNAVAILABLE"
+	CodePolicyViolation      = "POLICY_VIOLATION"
+	CodeDangerousOperation   = "DANGEROUS_OPERATION"
+	CodeInternal             = "INTERNAL_ERROR"
+)
+
+// ErrorResponse is the body of every error response.
+// "error" stays a human-readable string for older clients; new clients
+// should branch on "code".
+type ErrorResponse struct {
+	Error     string      `json:"error"`
+	Code      string      `json:"code"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// WriteError writes a structured JSON error response.
+//
+// details may be a string or error, used as the message, or any other
+// JSON value, returned under "details" alongside the code's default message.
+// Default messages, and messages equal to the code's catalog text, are
+// translated into the language negotiated for the request; the catalogs
+// are in internal/i18n/locales.
+func WriteError(w http.ResponseWriter, code string, status int, details interface{}) {
+	lang := responseLanguage(w)
+	resp := ErrorResponse{
+		Code:      code,
+		RequestID: requestID(w),
+	}
+	switch d := details.(type) {
+	case nil:
+		resp.Error, _ = i18n.Default.Lookup(lang, "error."+code)
+	case string:
+		resp.Error = i18n.Default.Localize(lang, "error."+code, d)
+	case error:
+		resp.Error = i18n.Default.Localize(lang, "error."+code, d.Error())
+	default:
+		resp.Error, _ = i18n.Default.Lookup(lang, "error."+code)
+		resp.Details = d
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeServiceError writes err using its own code when it is a domain
+// error, falling back to a code derived from status
+func writeServiceError(w http.ResponseWriter, status int, err error) {
+	WriteError(w, errorCode(err, status), status, err)
+}
+
+// errorCode extracts the machine-readable code from a domain error
+func errorCode(err error, status int) string {
+	var authErr *auth.AuthError
+	var sessionErr *session.SessionError
+	var gitErr *git.GitError
+	var shellErr *shell.ShellError
+	var promptErr *prompts.PromptError
+	var changeErr *changes.ChangeError
+	var agentErr *agent.TaskError
+	var budgetErr *usage.BudgetError
+	var pluginErr *plugin.DeniedError
+	var secretErr *secrets.BlockedError
+	var policyErr *fs.PolicyError
+	var lockErr *fslock.LockError
+	var notebookErr *notebook.Error
+
+	switch {
+	case errors.As(err, &authErr):
+		return authErr.Code
+	case errors.As(err, &sessionErr):
+		return sessionErr.Code
+	case errors.As(err, &gitErr):
+		return gitErr.Code
+	case errors.As(err, &shellErr):
+		return shellErr.Code
+	case errors.As(err, &promptErr):
+		return promptErr.Code
+	case errors.As(err, &changeErr):
+		return changeErr.Code
+	case errors.As(err, &agentErr):
+		return agentErr.Code
+	case errors.As(err, &budgetErr):
+		return budgetErr.Code
+	case errors.As(err, &pluginErr):
+		return pluginErr.Code
+	case errors.As(err, &secretErr):
+		return secretErr.Code
+	case errors.As(err, &policyErr):
+		return policyErr.Code
+	case errors.As(err, &lockErr):
+		return lockErr.Code
+	case errors.As(err, &notebookErr):
+		return notebookErr.Code
+	}
+	return statusCode(status)
+}
+
+// statusCode maps an HTTP status to a generic error code
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusBadGateway:
+		return CodeUpstreamError
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	return CodeInternal
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// notModified sets the ETag and Cache-Control headers and, when the
+// request's If-None-Match already names etag, answers 304 Not Modified.
+// It reports whether the response has been written.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	// 客户端可以缓存，但每次使用前都要重新验证
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSONWithETag encodes v with an ETag derived from its content,
+// answering 304 Not Modified when the client already has it
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Internal serialization error")
+		return
+	}
+	sum := sha256.Sum256(data)
+	if notModified(w, r, `"`+hex.EncodeToString(sum[:12])+`"`) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"echohelix/bridge/internal/fs"
+)
+
+// resolveFSPath resolves a path from a file request against the workspace
+// and checks op against the workspace policy (.echohelix/policy.json).
+// Absolute paths are used as given; only paths inside the workspace are
+// subject to its policy. The bridge's own files are refused either way.
+func (s *Server) resolveFSPath(op, path string) (string, error) {
+	if s.processManager == nil {
+		return "", errors.New("no active workspace")
+	}
+	root := filepath.Clean(s.processManager.WorkDir)
+	full := path
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(root, path)
+	}
+	if err := s.checkPrivate(op, full); err != nil {
+		return "", err
+	}
+	if err := checkFSPolicy(root, op, full); err != nil {
+		return "", err
+	}
+	return full, nil
+}
+
+// checkFSPolicy checks op on full against the policy of the workspace at
+// root. A policy file that cannot be read refuses everything rather than
+// silently allowing it.
+func checkFSPolicy(root, op, full string) error {
+	rel, ok := workspaceRel(root, full)
+	if !ok {
+		return nil
+	}
+	policy, err := fs.LoadPolicy(root)
+	if err != nil {
+		return err
+	}
+	return policy.Check(op, rel)
+}
+
+// workspaceRel returns full relative to root, and false when it is
+// outside root
+func workspaceRel(root, full string) (string, bool) {
+	rel, err := filepath.Rel(root, full)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// bridgePrivate reports whether full is one of the bridge's own files:
+// anything in the data directory (auth.json holds the token values,
+// identity_key the E2E key) or the env file with the API keys. The fs
+// API never serves these, whatever the token; GET /config is the way
+// for an admin to see the settings.
+func (s *Server) bridgePrivate(full string) bool {
+	for _, p := range pathForms(full) {
+		for _, private := range s.privatePaths() {
+			if _, inside := workspaceRel(private, p); inside {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// privatePaths returns the data directory and the env file in every
+// form pathForms gives
+func (s *Server) privatePaths() []string {
+	paths := pathForms(s.echoDir)
+	if s.configSvc != nil {
+		paths = append(paths, pathForms(s.configSvc.Path())...)
+	}
+	return paths
+}
+
+// checkPrivate refuses op on the bridge's own files with a policy error
+func (s *Server) checkPrivate(op, full string) error {
+	if !s.bridgePrivate(full) {
+		return nil
+	}
+	return &fs.PolicyError{Code: "POLICY_VIOLATION", Op: op, Path: filepath.ToSlash(full), Rule: "bridge_data"}
+}
+
+// pathForms returns path made absolute, and also with its symlinks
+// resolved when it exists, so a link into the data directory is caught
+func pathForms(path string) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = filepath.Clean(path)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil && real != abs {
+		return []string{abs, real}
+	}
+	return []string{abs}
+}
+
+// listReadable returns whether an entry of a listing of the workspace at
+// root may be shown: policy must allow reading it and it must not be
+// one of the bridge's own files. Those are found relative to root once,
+// so most workspaces, which hold none of them, pay nothing per entry.
+func (s *Server) listReadable(root string, policy *fs.Policy) func(rel string) bool {
+	var hidden []string
+	for _, r := range pathForms(root) {
+		for _, private := range s.privatePaths() {
+			if rel, inside := workspaceRel(r, private); inside {
+				hidden = append(hidden, rel)
+			}
+		}
+	}
+	return func(rel string) bool {
+		if !policy.Readable(rel) {
+			return false
+		}
+		for _, h := range hidden {
+			if h == "." || rel == h || strings.HasPrefix(rel, h+"/") {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// readableEntries drops the entries of a listing that readable refuses
+func readableEntries(readable func(rel string) bool, entries []fs.FileEntry) []fs.FileEntry {
+	out := make([]fs.FileEntry, 0, len(entries))
+	for _, e := range entries {
+		if readable(e.Path) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// writeFSError answers a policy violation with 403 and its op, path and
+// rule under "details", and other resolution failures with 500
+func writeFSError(w http.ResponseWriter, err error) {
+	var policyErr *fs.PolicyError
+	if errors.As(err, &policyErr) {
+		WriteError(w, CodePolicyViolation, http.StatusForbidden, *policyErr)
+		return
+	}
+	writeServiceError(w, http.StatusInternalServerError, err)
+}
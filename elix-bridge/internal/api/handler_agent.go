@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"echohelix/bridge/internal/agent"
+	"echohelix/bridge/internal/git"
+	"echohelix/bridge/internal/jobs"
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/shell"
+	"echohelix/bridge/internal/usage"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// agentStepTimeout bounds the kernel's turn in one agent step
+const agentStepTimeout = 30 * time.Minute
+
+// agentEnv runs agent steps against the bridge's kernel, git
+// checkpoints and shell runner
+type agentEnv struct {
+	s    *Server
+	task agent.Task
+}
+
+func (e *agentEnv) Checkpoint(label string) (string, error) {
+	repo, err := git.Open(e.task.Workspace)
+	if err != nil {
+		return "", err
+	}
+	cp, err := repo.CreateCheckpoint(label)
+	if err != nil {
+		return "", err
+	}
+	return cp.ID, nil
+}
+
+func (e *agentEnv) Prompt(ctx context.Context, text string) (agent.Turn, error) {
+	req := kernel.Request{Method: kernel.MethodChat, Text: text}
+	turn, err := e.s.runKernelTurn(ctx, e.task.Kernel, e.task.SessionID, e.task.DeviceID, req, agentStepTimeout, func(float64, string) {})
+	var budgetErr *usage.BudgetError
+	if errors.As(err, &budgetErr) {
+		return agent.Turn{}, fmt.Errorf("%w: %s", agent.ErrBudgetExceeded, budgetErr.Error())
+	}
+	if err != nil {
+		return agent.Turn{}, err
+	}
+	tokens := turn.Usage.InputTokens + turn.Usage.OutputTokens
+	if tokens == 0 {
+		tokens = turn.Message.TokenCount
+	}
+	return agent.Turn{Reply: turn.Message.Content, MessageID: turn.Message.ID, Tokens: tokens, Cost: turn.Usage.Cost}, nil
+}
+
+func (e *agentEnv) Test(ctx context.Context, command string) (agent.TestResult, error) {
+	run, err := e.s.shellRunner.Start(shell.Request{Command: command, Dir: e.task.Workspace, Source: "agent"})
+	if err != nil {
+		return agent.TestResult{}, err
+	}
+	done := make(chan struct{})
+	go func() {
+		run.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		e.s.shellRunner.Cancel(run.ID)
+		<-done
+		return agent.TestResult{}, ctx.Err()
+	}
+	info := run.Snapshot()
+	return agent.TestResult{
+		Command:  command,
+		RunID:    info.ID,
+		ExitCode: info.ExitCode,
+		Passed:   info.Status == shell.StatusSuccess,
+		Output:   info.Output,
+	}, nil
+}
+
+// HandleAgentTaskCreate starts an agent task: the bridge prompts the
+// kernel with the goal, runs the test command, and feeds failures back
+// until the tests pass or a step or budget limit is reached. Each step
+// checkpoints the workspace first, so any step can be rolled back with
+// POST /api/v2/checkpoints/rollback.
+// POST /api/v2/agent/tasks
+func (s *Server) HandleAgentTaskCreate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Goal        string  `json:"goal"`
+		SessionID   string  `json:"session_id"`
+		Kernel      string  `json:"kernel"`
+		Workspace   string  `json:"workspace"`
+		TestCommand string  `json:"test_command"`
+		MaxSteps    int     `json:"max_steps"`
+		MaxTokens   int     `json:"max_tokens"`
+		MaxCost     float64 `json:"max_cost"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+		return
+	}
+	if strings.TrimSpace(req.Goal) == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "goal is required")
+		return
+	}
+	if req.Kernel == "" {
+		req.Kernel = s.defaultKernel()
+	}
+	if _, ok := s.kernelAdapters[req.Kernel]; !ok {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "No event adapter for kernel "+req.Kernel)
+		return
+	}
+	workspace := s.resolveWorkDir(req.Workspace)
+
+	// 未指定会话时为任务新建一个，保存完整的对话记录
+	if req.SessionID == "" {
+		name := "Agent: " + req.Goal
+		if len(name) > 60 {
+			name = name[:57] + "..."
+		}
+		sess := s.sessionMgr.Create(name, workspace, req.Kernel, "")
+		s.eventBus.Publish("session.created", sess)
+		req.SessionID = sess.ID
+	} else if _, ok := s.sessionMgr.Get(req.SessionID); !ok {
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+		return
+	}
+
+	task := s.agentTasks.Create(agent.Task{
+		Goal:        req.Goal,
+		SessionID:   req.SessionID,
+		Kernel:      req.Kernel,
+		DeviceID:    deviceID(r),
+		Workspace:   workspace,
+		TestCommand: req.TestCommand,
+		Limits:      agent.Limits{MaxSteps: req.MaxSteps, MaxTokens: req.MaxTokens, MaxCost: req.MaxCost},
+	})
+	job := s.jobMgr.Submit("agent.task", func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		env := &agentEnv{s: s, task: task}
+		result, err := agent.Run(ctx, s.agentTasks, task.ID, env, func(step, max int, message string) {
+			job.SetProgress(float64(step-1)/float64(max), message)
+		})
+		s.eventBus.Publish("agent.finished", result)
+		if err == nil && result.Status != agent.StatusSucceeded {
+			err = errors.New(result.Reason)
+		}
+		if err != nil {
+			return nil, err
+		}
+		job.SetProgress(1, result.Reason)
+		return result, nil
+	})
+	task, _ = s.agentTasks.Update(task.ID, func(t *agent.Task) { t.JobID = job.ID })
+	log.Ctx(r.Context()).Info().Str("id", task.ID).Str("kernel", task.Kernel).Str("workspace", workspace).Msg("Agent task started")
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(task)
+}
+
+// HandleAgentTaskList lists agent tasks, newest first, without their steps
+// GET /api/v2/agent/tasks
+func (s *Server) HandleAgentTaskList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tasks := s.agentTasks.List()
+	for i := range tasks {
+		tasks[i].Steps = nil
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tasks": tasks,
+		"count": len(tasks),
+	})
+}
+
+// HandleAgentTaskGet returns a task with the trace of its steps
+// GET /api/v2/agent/tasks/{id}
+func (s *Server) HandleAgentTaskGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	task, ok := s.agentTasks.Get(mux.Vars(r)["id"])
+	if !ok {
+		writeServiceError(w, http.StatusNotFound, agent.ErrNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(task)
+}
+
+// HandleAgentTaskCancel stops a task after its current step's kernel
+// turn or test run is interrupted
+// POST /api/v2/agent/tasks/{id}/cancel
+func (s *Server) HandleAgentTaskCancel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	task, ok := s.agentTasks.Get(mux.Vars(r)["id"])
+	if !ok {
+		writeServiceError(w, http.StatusNotFound, agent.ErrNotFound)
+		return
+	}
+	if task.FinishedAt != nil {
+		writeServiceError(w, http.StatusConflict, agent.ErrNotRunning)
+		return
+	}
+	s.jobMgr.Cancel(task.JobID)
+	if task.Status == agent.StatusQueued {
+		// 排队中的任务不会再运行
+		task = s.agentTasks.Finish(task.ID, agent.StatusCanceled, "")
+	}
+	json.NewEncoder(w).Encode(task)
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"echohelix/bridge/internal/backup"
+	"echohelix/bridge/internal/config"
+	"echohelix/bridge/internal/jobs"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxRestoreSize bounds an uploaded restore archive
+const maxRestoreSize = 1 << 30
+
+// setupBackups starts the automatic backup schedule. BACKUP_INTERVAL is a
+// duration such as 24h (default; 0 disables) and BACKUP_KEEP the number of
+// backups kept in ~/.echohelix/backups (default 7).
+func (s *Server) setupBackups() {
+	interval := 24 * time.Hour
+	if v := s.configSvc.Get("BACKUP_INTERVAL"); v != "" {
+		if v == "0" {
+			interval = 0
+		} else if d, err := time.ParseDuration(v); err == nil {
+			interval = d
+		} else {
+			log.Warn().Str("value", v).Msg("Invalid BACKUP_INTERVAL, using default")
+		}
+	}
+	keep, _ := strconv.Atoi(s.configSvc.Get("BACKUP_KEEP"))
+
+	s.backups = backup.NewRotator(backup.RotationConfig{
+		Options:  s.backupOptions(false),
+		Interval: interval,
+		Keep:     keep,
+	})
+}
+
+func (s *Server) backupOptions(excludeSecrets bool) backup.Options {
+	return backup.Options{
+		DataDir:        s.echoDir,
+		EnvFile:        s.configSvc.Path(),
+		ExcludeSecrets: excludeSecrets,
+	}
+}
+
+// HandleBackup downloads an archive of ~/.echohelix and the config.
+// exclude_secrets=true leaves out the identity key and API keys, also from a
+// saved archive; save=true stores the archive with the automatic backups
+// instead and returns its info; with async=true as well the archive is
+// written by a "backup.save" job.
+// POST /api/v2/backup?exclude_secrets=true&save=true&async=true
+func (s *Server) HandleBackup(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	excludeSecrets := query.Get("exclude_secrets") == "true"
+
+	if query.Get("save") == "true" {
+		opts := s.backupOptions(excludeSecrets)
+		w.Header().Set("Content-Type", "application/json")
+		if query.Get("async") == "true" {
+			job := s.jobMgr.Submit("backup.save", func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+				job.SetProgress(0, "writing archive")
+				info, err := s.backups.SaveWith("manual", opts)
+				if err != nil {
+					return nil, err
+				}
+				return info, nil
+			})
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]interface{}{"job": job})
+			return
+		}
+		info, err := s.backups.SaveWith("manual", opts)
+		if err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(info)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="echohelix-backup-%s.tar.gz"`, time.Now().Format("20060102-150405")))
+	// 流式输出，写入开始后无法再返回错误响应
+	manifest, err := backup.Write(w, s.backupOptions(excludeSecrets))
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Backup failed")
+		return
+	}
+	log.Ctx(r.Context()).Info().Int("files", len(manifest.Files)).Bool("secrets_excluded", excludeSecrets).Msg("Backup downloaded")
+}
+
+// HandleBackupList lists the stored automatic backups, newest first
+// GET /api/v2/backups
+func (s *Server) HandleBackupList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	list, err := s.backups.List()
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"backups": list,
+	})
+}
+
+// HandleRestore stages an archive uploaded as the request body, or a
+// stored backup given by ?name=, to be restored when the bridge next
+// starts: the running services would otherwise write their in-memory
+// state back over the restored files. Requires confirm=true. The current
+// state is saved as a "pre-restore" backup when the archive is applied.
+// POST /api/v2/restore?confirm=true&name=...
+func (s *Server) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.URL.Query().Get("confirm") != "true" {
+		WriteError(w, CodeConfirmationRequired, http.StatusConflict, "Restoring replaces sessions, devices, and config; repeat with confirm=true")
+		return
+	}
+
+	var src io.Reader = http.MaxBytesReader(w, r.Body, maxRestoreSize)
+	if name := r.URL.Query().Get("name"); name != "" {
+		f, err := s.backups.Open(name)
+		if err != nil {
+			WriteError(w, CodeNotFound, http.StatusNotFound, "Backup not found")
+			return
+		}
+		defer f.Close()
+		src = f
+	}
+
+	manifest, err := backup.Stage(src, s.backupOptions(false))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, backup.ErrInvalidArchive) {
+			status = http.StatusBadRequest
+		}
+		writeServiceError(w, status, err)
+		return
+	}
+
+	log.Ctx(r.Context()).Warn().
+		Int("files", len(manifest.Files)).
+		Time("backup_created_at", manifest.CreatedAt).
+		Msg("Backup staged; it is restored when the bridge restarts")
+	s.eventBus.Publish("backup.staged", manifest)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"manifest":         manifest,
+		"staged":           true,
+		"restart_required": true,
+	})
+}
+
+// applyStagedRestore restores a backup staged by HandleRestore before any
+// service loads the data directory, saving the current state as a
+// "pre-restore" backup first, and reloads the restored config
+func applyStagedRestore(echoDir string, configSvc *config.Service) {
+	if !backup.HasPending(echoDir) {
+		return
+	}
+	opts := backup.Options{DataDir: echoDir, EnvFile: configSvc.Path()}
+	keep, _ := strconv.Atoi(configSvc.Get("BACKUP_KEEP"))
+	safety, err := backup.NewRotator(backup.RotationConfig{Options: opts, Keep: keep}).Save("pre-restore")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to back up current state, staged restore not applied")
+		return
+	}
+	manifest, err := backup.ApplyPending(opts)
+	if err != nil {
+		log.Error().Err(err).Str("pre_restore", safety.Name).Msg("Failed to apply staged restore")
+		return
+	}
+	if err := configSvc.Load(); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msg("Failed to reload config after restore")
+	}
+	log.Warn().
+		Int("files", len(manifest.Files)).
+		Time("backup_created_at", manifest.CreatedAt).
+		Str("pre_restore", safety.Name).
+		Msg("State restored from backup")
+}
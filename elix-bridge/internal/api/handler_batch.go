@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxBatchRequests caps the sub-requests of one batch
+const maxBatchRequests = 20
+
+type batchContextKey struct{}
+
+// isBatchRequest reports whether r is a sub-request of a batch
+func isBatchRequest(r *http.Request) bool {
+	ok, _ := r.Context().Value(batchContextKey{}).(bool)
+	return ok
+}
+
+// batchRequest is one sub-request of POST /batch
+type batchRequest struct {
+	ID      string            `json:"id,omitempty"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"` // 含查询参数，如 /api/v2/fs/stat?path=a.go
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batchResponse is the result of one sub-request. JSON bodies are
+// inlined; other bodies are strings, base64 encoded unless valid UTF-8.
+type batchResponse struct {
+	ID       string            `json:"id,omitempty"`
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     json.RawMessage   `json:"body,omitempty"`
+	Encoding string            `json:"encoding,omitempty"`
+}
+
+// HandleBatch runs a list of API requests in order and returns all their
+// responses in one round trip, e.g. stat + read + git status when a file
+// is opened. Each sub-request goes through authentication, permission
+// checks and rate limits as if sent on its own, with the caller's
+// credentials. WebSockets and streams cannot be batched.
+// POST /api/v2/batch
+func (s *Server) HandleBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Requests    []batchRequest `json:"requests"`
+		StopOnError bool           `json:"stop_on_error"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+		return
+	}
+	if len(req.Requests) == 0 {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "requests is required")
+		return
+	}
+	if len(req.Requests) > maxBatchRequests {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, map[string]interface{}{
+			"message": "too many requests in batch",
+			"max":     maxBatchRequests,
+		})
+		return
+	}
+
+	// 子请求同样经过 panic 恢复、负载限制与限流
+	dispatch := s.recoverMiddleware(s.limitMiddleware(s.rateLimitMiddleware(s.router)))
+	ctx := context.WithValue(r.Context(), batchContextKey{}, true)
+
+	responses := make([]batchResponse, 0, len(req.Requests))
+	for i, br := range req.Requests {
+		sub, err := s.newBatchSubrequest(ctx, r, br)
+		if err != nil {
+			responses = append(responses, batchError(br.ID, http.StatusBadRequest, CodeInvalidRequest, err.Error()))
+		} else {
+			rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+			dispatch.ServeHTTP(rec, sub)
+			responses = append(responses, toBatchResponse(br.ID, rec))
+		}
+
+		if last := responses[len(responses)-1]; req.StopOnError && last.Status >= 400 {
+			log.Ctx(r.Context()).Debug().Int("index", i).Int("status", last.Status).Msg("Batch stopped at failed request")
+			break
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"responses": responses,
+		"count":     len(responses),
+	})
+}
+
+// newBatchSubrequest builds the request for one batch entry, carrying
+// over the caller's credentials and connection context
+func (s *Server) newBatchSubrequest(ctx context.Context, parent *http.Request, br batchRequest) (*http.Request, error) {
+	method := strings.ToUpper(br.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	u, err := url.Parse(br.Path)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return nil, errors.New("path must be an API path such as /api/v2/fs/stat?path=...")
+	}
+	if !strings.HasPrefix(u.Path, "/api/v2/") && !strings.HasPrefix(u.Path, "/api/v3/") {
+		return nil, errors.New("only /api/v2 and /api/v3 paths can be batched")
+	}
+	if strings.HasSuffix(u.Path, "/batch") {
+		return nil, errors.New("batches cannot be nested")
+	}
+
+	var body []byte
+	if string(br.Body) != "null" {
+		body = br.Body
+	}
+	sub, err := http.NewRequestWithContext(ctx, method, u.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	sub.RemoteAddr = parent.RemoteAddr
+	sub.Host = parent.Host
+	sub.TLS = parent.TLS
+
+	for _, name := range []string{"Authorization", "User-Agent", "X-Forwarded-For"} {
+		if v := parent.Header.Get(name); v != "" {
+			sub.Header.Set(name, v)
+		}
+	}
+	if token := parent.URL.Query().Get("token"); token != "" && sub.Header.Get("Authorization") == "" {
+		sub.Header.Set("Authorization", "Bearer "+token)
+	}
+	for name, value := range br.Headers {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Connection", "Upgrade", "Host", "X-Forwarded-For", "X-Real-Ip",
+			http.CanonicalHeaderKey(e2eHeader), http.CanonicalHeaderKey(e2eIDHeader), http.CanonicalHeaderKey(e2eEnvelopeHeader):
+			continue // 凭据、连接、客户端地址与加密相关的头沿用外层请求
+		}
+		sub.Header.Set(name, value)
+	}
+	if len(body) > 0 && sub.Header.Get("Content-Type") == "" {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	if isStreaming(sub) {
+		return nil, errors.New("streaming endpoints cannot be batched")
+	}
+	return sub, nil
+}
+
+func toBatchResponse(id string, rec *bufferedResponse) batchResponse {
+	resp := batchResponse{ID: id, Status: rec.status, Headers: map[string]string{}}
+	for name, values := range rec.header {
+		if len(values) > 0 && name != "Content-Length" {
+			resp.Headers[name] = values[0]
+		}
+	}
+
+	body := rec.body.Bytes()
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		resp.Body = json.RawMessage(bytes.TrimSpace(body))
+	case utf8.Valid(body):
+		resp.Body, _ = json.Marshal(string(body))
+	default:
+		resp.Body, _ = json.Marshal(base64.StdEncoding.EncodeToString(body))
+		resp.Encoding = "base64"
+	}
+	return resp
+}
+
+func batchError(id string, status int, code, message string) batchResponse {
+	body, _ := json.Marshal(ErrorResponse{Error: message, Code: code})
+	return batchResponse{ID: id, Status: status, Body: body}
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"echohelix/bridge/internal/changes"
+	"echohelix/bridge/internal/danger"
+	"echohelix/bridge/internal/fs"
+	"echohelix/bridge/internal/mcp"
+	"echohelix/bridge/internal/notify"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// reviewMode reports whether kernel file writes go to the review queue
+// instead of disk (CHANGE_REVIEW=true)
+func (s *Server) reviewMode() bool {
+	return s.configSvc.Get("CHANGE_REVIEW") == "true"
+}
+
+// writeChangeError maps review queue failures to API errors
+func writeChangeError(w http.ResponseWriter, err error) {
+	var policyErr *fs.PolicyError
+	if errors.As(err, &policyErr) {
+		writeFSError(w, err)
+		return
+	}
+	var changeErr *changes.ChangeError
+	status := http.StatusInternalServerError
+	if errors.As(err, &changeErr) {
+		switch changeErr.Code {
+		case changes.ErrNotFound.Code:
+			status = http.StatusNotFound
+		case changes.ErrNotPending.Code, "CHANGE_CONFLICT":
+			status = http.StatusConflict
+		default:
+			status = http.StatusBadRequest
+		}
+	}
+	writeServiceError(w, status, err)
+}
+
+// proposeChange queues edits for review and tells the mobile app
+func (s *Server) proposeChange(description, source string, edits []changes.Edit) (changes.Change, error) {
+	if s.processManager == nil {
+		return changes.Change{}, errors.New("no active workspace")
+	}
+	for _, e := range edits {
+		if err := s.checkChangePath(s.processManager.WorkDir, e.Path); err != nil {
+			return changes.Change{}, err
+		}
+	}
+	c, err := s.changeQueue.Propose(s.processManager.WorkDir, description, source, edits)
+	if err != nil {
+		return c, err
+	}
+
+	s.eventBus.Publish("change.proposed", c)
+	title := fmt.Sprintf("Review %d changed file(s)", len(c.Files))
+	body := c.Description
+	if body == "" {
+		body = fmt.Sprintf("+%d -%d in %s", c.Additions, c.Deletions, c.Files[0].Path)
+	}
+	s.notifySvc.Notify(notify.Notification{
+		Category: notify.CategoryApprovalNeeded,
+		Title:    title,
+		Body:     body,
+		Data:     map[string]string{"change_id": c.ID},
+	})
+	return c, nil
+}
+
+// checkChangePath refuses an edit to path in the workspace at root that
+// the fs API would refuse: proposing reads the file for the diff and
+// applying writes it, so both must be allowed, and the bridge's own
+// files are never edited this way
+func (s *Server) checkChangePath(root, path string) error {
+	rel, err := changes.CleanPath(path)
+	if err != nil {
+		return err
+	}
+	for _, op := range []string{fs.OpRead, fs.OpWrite} {
+		if _, err := s.workspacePathIn(root, op, filepath.FromSlash(rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mcpProposeChange is the propose_change tool: kernels submit edits that
+// wait for the user's approval
+func (s *Server) mcpProposeChange(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Description string         `json:"description"`
+		Edits       []changes.Edit `json:"edits"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	c, err := s.proposeChange(args.Description, "mcp", args.Edits)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.TextResult(fmt.Sprintf("change %s is waiting for review (+%d -%d)\n%s", c.ID, c.Additions, c.Deletions, c.Diff())), nil
+}
+
+// HandleChangeList returns the proposed changes, pending ones unless
+// ?status= is given (all for every status)
+// GET /api/v2/changes?status=pending
+func (s *Server) HandleChangeList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	status := changes.Status(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = changes.StatusPending
+	case "all":
+		status = ""
+	}
+
+	list := s.changeQueue.List(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"changes": list,
+		"count":   len(list),
+	})
+}
+
+// HandleChangeDiff returns the pending changes (or ?id=a,b) as one
+// unified diff for reviewing everything at once
+// GET /api/v2/changes/diff?id=...
+func (s *Server) HandleChangeDiff(w http.ResponseWriter, r *http.Request) {
+	var list []changes.Change
+	if ids := r.URL.Query().Get("id"); ids != "" {
+		for _, id := range strings.Split(ids, ",") {
+			c, ok := s.changeQueue.Get(strings.TrimSpace(id))
+			if !ok {
+				writeChangeError(w, changes.ErrNotFound)
+				return
+			}
+			list = append(list, c)
+		}
+	} else {
+		list = s.changeQueue.List(changes.StatusPending)
+	}
+
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	for _, c := range list {
+		header := "# change " + c.ID
+		if c.Description != "" {
+			header += ": " + c.Description
+		}
+		io.WriteString(w, header+"\n"+c.Diff())
+	}
+}
+
+// HandleChangeGet returns one change with its diff and new content
+// GET /api/v2/changes/{id}
+func (s *Server) HandleChangeGet(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.changeQueue.Get(mux.Vars(r)["id"])
+	if !ok {
+		writeChangeError(w, changes.ErrNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// HandleChangePropose queues edits for review; the same as the
+// propose_change MCP tool for clients that don't speak MCP
+// POST /api/v2/changes {"description": "", "edits": [{"path": "", "content": ""}]}
+func (s *Server) HandleChangePropose(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Description string         `json:"description"`
+		Source      string         `json:"source"`
+		Edits       []changes.Edit `json:"edits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Source == "" {
+		req.Source = "api"
+	}
+
+	c, err := s.proposeChange(req.Description, req.Source, req.Edits)
+	if err != nil {
+		writeChangeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// HandleChangeApply writes a pending change to disk. Files modified since
+// the change was proposed make it fail with CHANGE_CONFLICT unless
+// ?force=true. A change deleting more than DANGER_DELETE_THRESHOLD files
+// also needs a confirm_token from POST /api/v2/confirm.
+// POST /api/v2/changes/{id}/apply?force=false&confirm_token=
+func (s *Server) HandleChangeApply(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if c, ok := s.changeQueue.Get(id); ok && c.Status == changes.StatusPending {
+		op := danger.Operation{Action: danger.ActionChangeApply, Target: id}
+		if s.bulkDelete(c) {
+			op.Class = danger.ClassBulkDelete
+		}
+		if !s.confirmed(w, r, op, r.URL.Query().Get("confirm_token")) {
+			return
+		}
+		for _, f := range c.Files {
+			// 策略可能在提议之后收紧，应用前再检查一次
+			if err := s.checkChangePath(c.Workspace, f.Path); err != nil {
+				writeChangeError(w, err)
+				return
+			}
+			if f.Action == changes.ActionDelete {
+				continue
+			}
+			if err := s.checkFSWrite(r.Context(), c.Source, "", f.Path, f.Content); err != nil {
+				writePluginError(w, err)
+				return
+			}
+		}
+		s.checkpointBeforeWrite(c.Workspace, "change "+id)
+	}
+
+	c, err := s.changeQueue.Apply(id, r.URL.Query().Get("force") == "true")
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("change", id).Msg("Failed to apply change")
+		writeChangeError(w, err)
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("change", id).Int("files", len(c.Files)).Msg("Change applied")
+	s.eventBus.Publish("change.applied", c)
+	for _, f := range c.Files {
+		s.eventBus.Publish("fs.written", map[string]interface{}{
+			"path": f.Path,
+			"size": len(f.Content),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// HandleChangeReject discards a pending change
+// POST /api/v2/changes/{id}/reject {"reason": ""}
+func (s *Server) HandleChangeReject(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// 请求体可省略
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	c, err := s.changeQueue.Reject(mux.Vars(r)["id"], req.Reason)
+	if err != nil {
+		writeChangeError(w, err)
+		return
+	}
+	s.eventBus.Publish("change.rejected", c)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
@@ -1,85 +1,146 @@
 package api
 
 import (
-	"fmt"
+	"encoding/json"
 	"net/http"
 	"sync"
 
+	"echohelix/bridge/internal/e2e"
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/kernel/gemini"
+	"echohelix/bridge/internal/notify"
+	"echohelix/bridge/internal/session"
+
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for dev
-	},
+// kernelURL returns the WebSocket endpoint of a kernel, using the port
+// it was started on when it is the running kernel
+func (s *Server) kernelURL(name string) string {
+	port := 0
+	if s.processManager != nil {
+		if st := s.processManager.Status(); st.Running && st.Kernel == name {
+			port = st.Port
+		}
+	}
+	adapter, ok := s.kernelAdapters[name]
+	if !ok {
+		// 未知内核按 Gemini 处理
+		adapter = s.kernelAdapters[gemini.Name]
+	}
+	return adapter.URL(port)
 }
 
-// HandleChatProxy upgrades the connection to WebSocket and proxies messages to the active kernel
+// HandleChatProxy upgrades the connection to WebSocket and proxies messages
+// to the kernel named by ?kernel= (default: the running kernel, else the
+// configured one). By default frames are passed through unchanged. With
+// ?events=true the kernel's adapter translates: the client sends
+// kernel.Request JSON and receives typed kernel.Event JSON, and with
+// ?session_id= each turn is recorded in the session transcript.
+// GET /api/v2/chat/proxy?kernel=aider&events=true&session_id=
 func (s *Server) HandleChatProxy(w http.ResponseWriter, r *http.Request) {
+	kernelName := r.URL.Query().Get("kernel")
+	if kernelName == "" {
+		kernelName = s.defaultKernel()
+	}
+	var codec kernel.Codec
+	if r.URL.Query().Get("events") == "true" {
+		adapter, ok := s.kernelAdapters[kernelName]
+		if !ok {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "No event adapter for kernel "+kernelName)
+			return
+		}
+		codec = adapter.NewCodec()
+	}
+	sessionID := r.URL.Query().Get("session_id")
+	workspace := s.resolveWorkDir("")
+	if sessionID != "" {
+		sess, ok := s.sessionMgr.Get(sessionID)
+		if !ok {
+			writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+			return
+		}
+		workspace = s.resolveWorkDir(sess.WorkingDirectory)
+	}
+	device := deviceID(r)
+	if err := s.checkBudget(sessionID, device); err != nil {
+		writeServiceError(w, http.StatusPaymentRequired, err)
+		return
+	}
+
 	// 1. Upgrade Client Connection
-	clientConn, err := upgrader.Upgrade(w, r, nil)
+	clientConn, err := s.upgrade(w, r)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to upgrade websocket")
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to upgrade websocket")
 		return
 	}
 	defer clientConn.Close()
 
-	// 2. Determine Target Kernel Port
-	// Default to Gemini (41242) if not specified or tracked
-	// Ideally ProcessManager should tell us the active port/kernel.
-	// For now, we'll try to determine based on query param or default.
-	// P4/P5 assumption: bridge routes based on "kernel" field in JSON-RPC?
-	// ACTUALLY: The Architecture diagram says "App <--> Bridge <--> Kernel".
-	// The Bridge is a "Dumb Pipe".
-	// We need to connect to the RUNNING kernel.
-	// Since ProcessManager only runs ONE kernel at a time (per our previous logic),
-	// we can try to connect to the active one.
-
-	// Issue: We don't know WHICH port is active easily without querying PM.
-	// But PM currently takes a port as arg.
-	// Let's assume standard ports: Gemini 41242, Aider 41243.
-	// We'll try 41242 first, then 41243? Or use a query param `?kernel=aider`.
-
-	kernel := r.URL.Query().Get("kernel")
-	targetPort := 41242
-	if kernel == "aider" {
-		targetPort = 41243
-	}
-
-	targetURL := fmt.Sprintf("ws://127.0.0.1:%d", targetPort)
-	if kernel == "aider" {
-		// Aider server.py (FastAPI) doesn't expose a WS endpoint typically?
-		// Wait, server.py uses HTTP POST /chat.
-		// BRIDGE MUST TRANSLATE WS <-> HTTP for Aider?
-		// OR we update Aider server.py to support WS?
-		// "Core Policy": Modify source directly if wrapping.
-		// Aider is a CLI. Wrapping it in FastAPI with /chat is Request/Response.
-		// App expects WS.
-		// Bridge must handle the conversion or Aider Server must support WS.
-		// Easier to make Aider Server support WS.
-		// BUT for now, let's implement the Proxy for Gemini (which is WS?)
-		// Gemini "a2a-server" IS a WebSocket server?
-		// Checking "a2a-server": it uses `ws` package. Yes.
-
-		// For Aider: The plan was "Stream output".
-		// Converting Aider to WS in server.py is best for consistency.
-		// Let's assume Aider Server will accept WS at /ws.
-		targetURL = fmt.Sprintf("ws://127.0.0.1:%d/ws", targetPort)
-	}
-
-	log.Info().Str("target", targetURL).Msg("Proxying Chat Connection")
-
-	// 3. Connect to Backend Kernel
-	backendConn, _, err := websocket.DefaultDialer.Dial(targetURL, nil)
+	// 2. Connect to Backend Kernel (forward the request ID so kernel logs correlate)
+	targetURL := s.kernelURL(kernelName)
+	log.Ctx(r.Context()).Info().Str("target", targetURL).Bool("events", codec != nil).Msg("Proxying Chat Connection")
+
+	header := http.Header{}
+	header.Set(requestIDHeader, requestIDFromContext(r.Context()))
+	backendConn, _, err := websocket.DefaultDialer.Dial(targetURL, header)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to connect to backend kernel")
-		clientConn.WriteJSON(map[string]string{"error": "Backend not available"})
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to connect to backend kernel")
+		clientConn.WriteJSON(map[string]string{
+			"error":      "Backend not available",
+			"code":       CodeUpstreamError,
+			"request_id": requestIDFromContext(r.Context()),
+		})
 		return
 	}
 	defer backendConn.Close()
 
-	// 4. Pipe Data
+	// 3. Pipe Data
+	// 端到端加密时，客户端侧的每条消息都是 e2e 信封
+	e2eKey, encrypted := e2eKeyFromContext(r.Context())
+
+	var clientMu sync.Mutex
+	writeClient := func(mt int, message []byte) error {
+		if encrypted {
+			var err error
+			if message, err = e2e.Seal(e2eKey, message, []byte("chat server")); err != nil {
+				return err
+			}
+			mt = websocket.TextMessage
+		}
+		clientMu.Lock()
+		defer clientMu.Unlock()
+		return clientConn.WriteMessage(mt, message)
+	}
+	writeEvent := func(ev kernel.Event) error {
+		data, _ := json.Marshal(ev)
+		return writeClient(websocket.TextMessage, data)
+	}
+
+	// 外部 MCP 工具注入（需要 execute 权限）
+	var backendMu sync.Mutex
+	var tools *kernelToolBridge
+	for _, p := range mcpPermissions(r) {
+		if p == permExecute {
+			tools = &kernelToolBridge{pool: s.mcpPool, backend: backendConn, writeMu: &backendMu, ctx: r.Context()}
+		}
+	}
+	if tools != nil {
+		if err := tools.announce(); err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Msg("Failed to announce MCP tools to kernel")
+		}
+	}
+
+	transcript := kernel.NewTranscript()
+	record := func(msg session.Message) {
+		if sessionID == "" {
+			return
+		}
+		if _, err := s.recordMessage(sessionID, msg); err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Str("session", sessionID).Msg("Failed to record chat message")
+		}
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -90,13 +151,69 @@ func (s *Server) HandleChatProxy(w http.ResponseWriter, r *http.Request) {
 			mt, message, err := clientConn.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Error().Err(err).Msg("Client read error")
+					log.Ctx(r.Context()).Error().Err(err).Msg("Client read error")
 				}
 				return
 			}
-			err = backendConn.WriteMessage(mt, message)
+			if encrypted {
+				if message, err = e2e.Open(e2eKey, message, []byte("chat client")); err != nil {
+					log.Ctx(r.Context()).Warn().Err(err).Msg("Dropping undecryptable chat message")
+					continue
+				}
+			}
+
+			frames := [][]byte{message}
+			var req kernel.Request
+			if codec != nil && json.Unmarshal(message, &req) == nil && req.Method != "" {
+				if req.Method == kernel.MethodChat {
+					// 连接期间也可能用完预算，每次提问前都检查
+					if err := s.checkBudget(sessionID, device); err != nil {
+						writeEvent(kernel.Event{Type: kernel.EventError, Kernel: kernelName, Text: err.Error()})
+						continue
+					}
+					if req.Text, err = s.filterOutbound(r.Context(), workspace, "prompt", req.Text); err == nil {
+						err = s.checkOutboundFiles(r.Context(), workspace, req.Files)
+					}
+					if err == nil {
+						err = s.attachImages(workspace, kernelName, sessionID, &req)
+					}
+					if err != nil {
+						writeEvent(kernel.Event{Type: kernel.EventError, Kernel: kernelName, Text: err.Error()})
+						continue
+					}
+				}
+				if frames, err = codec.Encode(req); err != nil {
+					writeEvent(kernel.Event{Type: kernel.EventError, Kernel: kernelName, Text: err.Error()})
+					continue
+				}
+				mt = websocket.TextMessage
+				if req.Method == kernel.MethodChat {
+					record(session.Message{Role: "user", Content: req.Text})
+				}
+			} else if mt == websocket.TextMessage {
+				// 透传帧无法解析，按原始文本扫描
+				text, err := s.filterOutbound(r.Context(), workspace, "message", string(message))
+				if err != nil {
+					data, _ := json.Marshal(map[string]string{
+						"error":      err.Error(),
+						"code":       errorCode(err, http.StatusUnprocessableEntity),
+						"request_id": requestIDFromContext(r.Context()),
+					})
+					writeClient(websocket.TextMessage, data)
+					continue
+				}
+				frames = [][]byte{[]byte(text)}
+			}
+
+			backendMu.Lock()
+			for _, frame := range frames {
+				if err = backendConn.WriteMessage(mt, frame); err != nil {
+					break
+				}
+			}
+			backendMu.Unlock()
 			if err != nil {
-				log.Error().Err(err).Msg("Backend write error")
+				log.Ctx(r.Context()).Error().Err(err).Msg("Backend write error")
 				return
 			}
 		}
@@ -109,18 +226,94 @@ func (s *Server) HandleChatProxy(w http.ResponseWriter, r *http.Request) {
 			mt, message, err := backendConn.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Error().Err(err).Msg("Backend read error")
+					log.Ctx(r.Context()).Error().Err(err).Msg("Backend read error")
 				}
 				return
 			}
-			err = clientConn.WriteMessage(mt, message)
+			if tools != nil && tools.intercept(mt, message) {
+				continue
+			}
+
+			if codec == nil {
+				err = writeClient(mt, message)
+			} else {
+				for _, ev := range codec.Decode(message) {
+					if ev.Usage != nil {
+						s.recordUsage(kernelName, sessionID, device, *ev.Usage)
+					}
+					s.recordKernelEvent(sessionID, ev)
+					if msg, done := transcript.Observe(ev); done {
+						record(msg)
+					}
+					if ev.ToolCall != nil && ev.ToolCall.Status == kernel.ToolAwaitingApproval {
+						s.requestToolApproval(kernelName, sessionID, ev.ToolCall)
+					}
+					if err = writeEvent(ev); err != nil {
+						break
+					}
+				}
+			}
 			if err != nil {
-				log.Error().Err(err).Msg("Client write error")
+				log.Ctx(r.Context()).Error().Err(err).Msg("Client write error")
 				return
 			}
 		}
 	}()
 
 	wg.Wait()
-	log.Info().Msg("Chat Proxy Closed")
+	log.Ctx(r.Context()).Info().Msg("Chat Proxy Closed")
+}
+
+// recordMessage appends msg to a session transcript and announces it
+func (s *Server) recordMessage(sessionID string, msg session.Message) (*session.Message, error) {
+	saved, err := s.sessionMgr.AppendMessage(sessionID, msg)
+	if err != nil {
+		return nil, err
+	}
+	s.eventBus.Publish("session.message", saved)
+	return saved, nil
+}
+
+// recordKernelEvent keeps a kernel's tool calls, file edits and commits
+// in the session's timeline for replay, and tracks and locks the files
+// they touch
+func (s *Server) recordKernelEvent(sessionID string, ev kernel.Event) {
+	s.trackKernelFiles(sessionID, ev)
+	s.lockKernelFiles(sessionID, ev)
+	if sessionID == "" {
+		return
+	}
+	rec := session.Event{Kernel: ev.Kernel}
+	switch {
+	case ev.ToolCall != nil:
+		rec.Type = session.EventToolCall
+		rec.ToolCall = ev.ToolCall
+	case ev.Edit != nil:
+		rec.Type = session.EventFileEdit
+		rec.Path, rec.Diff, rec.Applied = ev.Edit.Path, ev.Edit.Diff, ev.Edit.Applied
+	case ev.Commit != nil:
+		rec.Type = session.EventCommit
+		rec.Commit, rec.Message = ev.Commit.Hash, ev.Commit.Message
+	default:
+		return
+	}
+	if err := s.sessionMgr.RecordEvent(sessionID, rec); err != nil {
+		log.Warn().Err(err).Str("session", sessionID).Msg("Failed to record session event")
+	}
+}
+
+// requestToolApproval tells the user a kernel is waiting for them to
+// approve a tool call; the client answers with the approve method
+func (s *Server) requestToolApproval(kernelName, sessionID string, call *session.ToolCall) {
+	s.eventBus.Publish("chat.approval_needed", map[string]interface{}{
+		"kernel":     kernelName,
+		"session_id": sessionID,
+		"tool_call":  call,
+	})
+	s.notifySvc.Notify(notify.Notification{
+		Category: notify.CategoryApprovalNeeded,
+		Title:    "Approval needed",
+		Body:     kernelName + " wants to run " + call.Name,
+		Data:     map[string]string{"kernel": kernelName, "session_id": sessionID, "call_id": call.ID},
+	})
 }
@@ -0,0 +1,116 @@
+// Package api provides HTTP handlers for AI-edit checkpoints
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"echohelix/bridge/internal/git"
+
+	"github.com/gorilla/mux"
+)
+
+// checkpointBeforeWrite snapshots the workspace before a file write when
+// CHECKPOINT_MODE is enabled in the bridge config
+func (s *Server) checkpointBeforeWrite(dir, path string) {
+	if s.configSvc.Get("CHECKPOINT_MODE") != "true" {
+		return
+	}
+	s.checkpointer.BeforeWrite(dir, "before write: "+path)
+}
+
+// HandleCheckpointList lists checkpoints for the workspace
+// GET /api/v2/checkpoints?dir=...
+func (s *Server) HandleCheckpointList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	checkpoints, err := repo.ListCheckpoints()
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"checkpoints": checkpoints,
+		"count":       len(checkpoints),
+	})
+}
+
+// HandleCheckpointCreate creates a checkpoint explicitly (e.g. by a kernel
+// before a tool-call batch)
+// POST /api/v2/checkpoints {"label": "..."}
+func (s *Server) HandleCheckpointCreate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Label string `json:"label"`
+	}
+	// 请求体可选
+	json.NewDecoder(r.Body).Decode(&req)
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	cp, err := repo.CreateCheckpoint(req.Label)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cp)
+}
+
+// HandleCheckpointRollback restores the work tree to a checkpoint
+// POST /api/v2/checkpoints/{id}/rollback
+func (s *Server) HandleCheckpointRollback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	backup, err := repo.Rollback(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == git.ErrCheckpointNotFound {
+			status = http.StatusNotFound
+		}
+		writeServiceError(w, status, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"backup":  backup,
+	})
+}
+
+// HandleCheckpointDelete deletes a checkpoint
+// DELETE /api/v2/checkpoints/{id}
+func (s *Server) HandleCheckpointDelete(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	if err := repo.DeleteCheckpoint(mux.Vars(r)["id"]); err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
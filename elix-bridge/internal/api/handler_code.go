@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+
+	"echohelix/bridge/internal/fs"
+	"echohelix/bridge/internal/jobs"
+	"echohelix/bridge/internal/lsp"
+	"echohelix/bridge/internal/symbols"
+
+	"github.com/rs/zerolog/log"
+)
+
+// workspaceSymbols returns the symbol index of the active workspace,
+// replacing it when the workspace changed
+func (s *Server) workspaceSymbols() *symbols.Index {
+	root := s.processManager.WorkDir
+	s.symbolMu.Lock()
+	defer s.symbolMu.Unlock()
+	if s.symbolIndex == nil || s.symbolIndex.Root() != root {
+		s.symbolIndex = symbols.NewIndex(root)
+	}
+	return s.symbolIndex
+}
+
+// fileOutline returns the nested symbols of a workspace file. The
+// built-in parsers are used when they support the file type; otherwise,
+// or with useLSP, the file's language server is asked.
+func (s *Server) fileOutline(ctx context.Context, path string, useLSP bool) ([]symbols.Symbol, string, error) {
+	full, err := s.workspacePath(fs.OpRead, path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !useLSP && symbols.Supported(path) {
+		src, err := os.ReadFile(full)
+		if err != nil {
+			return nil, "", err
+		}
+		outline, err := symbols.Outline(path, src)
+		if outline == nil && err != nil {
+			return nil, "", err
+		}
+		return outline, "parser", nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lspTimeout)
+	defer cancel()
+	docSymbols, err := s.lspMgr.DocumentSymbols(ctx, s.processManager.WorkDir, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return convertLSPSymbols(docSymbols), "lsp", nil
+}
+
+func convertLSPSymbols(list []lsp.DocumentSymbol) []symbols.Symbol {
+	out := make([]symbols.Symbol, 0, len(list))
+	for _, d := range list {
+		out = append(out, symbols.Symbol{
+			Name:      d.Name,
+			Kind:      lsp.SymbolKindName(d.Kind),
+			Detail:    d.Detail,
+			Line:      d.Range.Start.Line,
+			EndLine:   d.Range.End.Line,
+			Container: d.ContainerName,
+			Children:  convertLSPSymbols(d.Children),
+		})
+	}
+	return out
+}
+
+// writeOutlineError maps outline failures to API errors
+func writeOutlineError(w http.ResponseWriter, err error) {
+	if os.IsNotExist(err) {
+		WriteError(w, CodeFileNotFound, http.StatusNotFound, err.Error())
+		return
+	}
+	writeLSPError(w, err)
+}
+
+// HandleCodeOutline returns the nested functions, types, and classes of a
+// file. Lines are zero-based.
+// GET /api/v2/code/outline?path=main.go[&source=lsp]
+func (s *Server) HandleCodeOutline(w http.ResponseWriter, r *http.Request) {
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path parameter is required")
+		return
+	}
+
+	outline, source, err := s.fileOutline(r.Context(), path, r.URL.Query().Get("source") == "lsp")
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("path", path).Msg("Failed to outline file")
+		writeOutlineError(w, err)
+		return
+	}
+	if outline == nil {
+		outline = []symbols.Symbol{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":    path,
+		"source":  source,
+		"symbols": outline,
+	})
+}
+
+// HandleCodeSymbols lists the symbols of one file (?path=) or searches
+// the whole workspace by name (?query=, fuzzy, best matches first)
+// GET /api/v2/code/symbols?path=main.go
+// GET /api/v2/code/symbols?query=handle&limit=50
+func (s *Server) HandleCodeSymbols(w http.ResponseWriter, r *http.Request) {
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
+	q := r.URL.Query()
+
+	if path := q.Get("path"); path != "" {
+		outline, _, err := s.fileOutline(r.Context(), path, q.Get("source") == "lsp")
+		if err != nil {
+			writeOutlineError(w, err)
+			return
+		}
+		flat := symbols.Flatten(outline)
+		for i := range flat {
+			flat[i].Path = path
+		}
+		if flat == nil {
+			flat = []symbols.Symbol{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"path":    path,
+			"symbols": flat,
+		})
+		return
+	}
+
+	limit := 50
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+
+	index := s.workspaceSymbols()
+	if err := index.Refresh(); err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("Symbol index refresh incomplete")
+	}
+	files, total := index.Count()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":         q.Get("query"),
+		"symbols":       index.Search(q.Get("query"), limit),
+		"files_indexed": files,
+		"total_symbols": total,
+	})
+}
+
+// HandleCodeSymbolIndex rebuilds the workspace symbol index in a
+// "symbols.index" job, so a large repository can be indexed ahead of
+// the first search without holding the request open
+// POST /api/v2/code/symbols/index
+func (s *Server) HandleCodeSymbolIndex(w http.ResponseWriter, r *http.Request) {
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
+	index := s.workspaceSymbols()
+	job := s.jobMgr.Submit("symbols.index", func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		job.SetProgress(0, "indexing "+index.Root())
+		if err := index.Refresh(); err != nil {
+			return nil, err
+		}
+		files, total := index.Count()
+		return map[string]interface{}{
+			"root":          index.Root(),
+			"files_indexed": files,
+			"total_symbols": total,
+		}, nil
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"job": job})
+}
@@ -19,10 +19,7 @@ func (s *Server) HandleConfigSet(w http.ResponseWriter, r *http.Request) {
 
 	key := r.URL.Query().Get("key")
 	if key == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "key parameter is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "key parameter is required")
 		return
 	}
 
@@ -31,18 +28,12 @@ func (s *Server) HandleConfigSet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "invalid request body",
-		})
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
 	if err := s.configSvc.Set(key, req.Value); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeServiceError(w, http.StatusInternalServerError, err)
 		return
 	}
 
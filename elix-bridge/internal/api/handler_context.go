@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+
+	"echohelix/bridge/internal/contextpack"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HandleContextPack builds a summary of the workspace for bootstrapping a
+// model session: dependency manifests, the directory tree, and excerpts of
+// key files, within a token budget. Packs are cached until a file in the
+// workspace changes. With ?format=text only the text is returned.
+// POST /api/v2/context/pack {"workspace": "", "budget": 8000, "include": [], "refresh": false}
+func (s *Server) HandleContextPack(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		contextpack.Options
+		Workspace string `json:"workspace"`
+	}
+	// 空请求体使用默认值
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Budget < 0 {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "budget must not be negative")
+		return
+	}
+
+	root := s.resolveWorkDir(req.Workspace)
+	if root == "" {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
+
+	pack, err := s.contextPacks.Build(root, req.Options)
+	if err != nil {
+		if os.IsNotExist(err) {
+			WriteError(w, CodeFileNotFound, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Ctx(r.Context()).Warn().Err(err).Str("workspace", root).Msg("Failed to build context pack")
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Ctx(r.Context()).Debug().
+		Str("workspace", root).
+		Int("tokens", pack.Tokens).
+		Bool("cached", pack.Cached).
+		Msg("Context pack")
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		io.WriteString(w, pack.Text)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pack)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"echohelix/bridge/internal/backup"
+	"echohelix/bridge/internal/crash"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// crashLogLines is how many recent log lines a crash bundle carries
+const crashLogLines = 200
+
+// setupCrashReporting writes crash bundles to ~/.echohelix/crashes
+func (s *Server) setupCrashReporting() {
+	s.crashes = crash.NewReporter(crash.Config{
+		Dir:           filepath.Join(s.echoDir, "crashes"),
+		BridgeVersion: Version,
+		Logs: func() interface{} {
+			return s.dashboardLogger.GetLogs(crashLogLines)
+		},
+		Settings: func() map[string]string {
+			settings := s.configSvc.GetAll()
+			for key := range settings {
+				if backup.IsSecretKey(key) {
+					settings[key] = "[redacted]"
+				}
+			}
+			return settings
+		},
+	})
+}
+
+// reportCrash writes a crash bundle, logging rather than failing when it
+// cannot
+func (s *Server) reportCrash(kind, message string, stack []byte, details map[string]interface{}) {
+	if _, err := s.crashes.Report(kind, message, stack, details); err != nil {
+		log.Error().Err(err).Str("kind", kind).Msg("Failed to write crash bundle")
+	}
+}
+
+// HandleCrashList lists stored crash bundles, newest first
+// GET /api/v2/crashes
+func (s *Server) HandleCrashList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	list, err := s.crashes.List()
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"crashes": list,
+		"count":   len(list),
+	})
+}
+
+// HandleCrashGet downloads a crash bundle to attach to an issue
+// GET /api/v2/crashes/{name}
+func (s *Server) HandleCrashGet(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	f, err := s.crashes.Open(name)
+	if err != nil {
+		if errors.Is(err, crash.ErrNotFound) {
+			WriteError(w, CodeNotFound, http.StatusNotFound, err)
+			return
+		}
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	io.Copy(w, f)
+}
+
+// HandleCrashDelete removes a crash bundle
+// DELETE /api/v2/crashes/{name}
+func (s *Server) HandleCrashDelete(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := s.crashes.Delete(mux.Vars(r)["name"]); err != nil {
+		if errors.Is(err, crash.ErrNotFound) {
+			WriteError(w, CodeNotFound, http.StatusNotFound, err)
+			return
+		}
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]bool{"deleted": true})
+}
@@ -0,0 +1,67 @@
+// Package api provides HTTP handlers for paired device management
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"echohelix/bridge/internal/auth"
+)
+
+// HandleDeviceList returns the paired devices
+// GET /api/v2/devices
+func (s *Server) HandleDeviceList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	devices := []auth.DeviceInfo{}
+	for _, t := range s.authService.ListActiveDevices() {
+		devices = append(devices, t.Info())
+	}
+	json.NewEncoder(w).Encode(devices)
+}
+
+// HandleDeviceRevoke revokes every token of a device
+// DELETE /api/v2/devices?id=
+func (s *Server) HandleDeviceRevoke(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+
+	if !s.authService.RevokeDevice(id) {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "device not found")
+		return
+	}
+	s.activity.forget(id)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "revoked", "device_id": id})
+}
+
+// HandleDeviceAdmin grants a device the admin permission, or withdraws it
+// with ?admin=false. Devices paired from this machine are admins already.
+// POST /api/v2/devices/admin?id=...&admin=true
+func (s *Server) HandleDeviceAdmin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+	admin := r.URL.Query().Get("admin") != "false"
+
+	token, err := s.authService.SetAdmin(id, admin)
+	if errors.Is(err, auth.ErrGuestAdmin) {
+		writeServiceError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "device not found")
+		return
+	}
+	json.NewEncoder(w).Encode(token.Info())
+}
@@ -0,0 +1,104 @@
+// Package api provides the unified event stream
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// eventsControl is sent by clients to change their topic subscription
+// {"action": "subscribe", "topics": ["session", "job.finished"]}
+type eventsControl struct {
+	Action string   `json:"action"` // "subscribe", "unsubscribe", "set"
+	Topics []string `json:"topics"`
+}
+
+// HandleEvents streams bus events over WebSocket. Initial topics come from
+// ?topics=session,job (default: all); clients can adjust them at runtime.
+// GET /api/v2/events?topics=...
+func (s *Server) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	var topics []string
+	if v := r.URL.Query().Get("topics"); v != "" {
+		topics = strings.Split(v, ",")
+	}
+
+	conn, err := s.upgrade(w, r)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to upgrade websocket")
+		return
+	}
+	defer conn.Close()
+
+	sub := s.eventBus.Subscribe(topics...)
+	defer sub.Close()
+
+	// 读取客户端订阅变更；确认由下面的循环写出，连接只有一个写者
+	closed := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	acks := make(chan []string)
+	go func() {
+		defer close(closed)
+		for {
+			var msg eventsControl
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			sub.SetPatterns(applyTopicControl(sub.Patterns(), msg))
+			select {
+			case acks <- sub.Patterns():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		case patterns := <-acks:
+			if patterns == nil {
+				patterns = []string{}
+			}
+			err := conn.WriteJSON(map[string]interface{}{
+				"topic": "events.subscribed",
+				"data":  patterns,
+			})
+			if err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func applyTopicControl(current []string, msg eventsControl) []string {
+	switch msg.Action {
+	case "set":
+		return msg.Topics
+	case "subscribe":
+		return append(current, msg.Topics...)
+	case "unsubscribe":
+		remove := make(map[string]bool)
+		for _, t := range msg.Topics {
+			remove[t] = true
+		}
+		result := make([]string, 0, len(current))
+		for _, t := range current {
+			if !remove[t] {
+				result = append(result, t)
+			}
+		}
+		return result
+	}
+	return current
+}
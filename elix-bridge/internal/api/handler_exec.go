@@ -0,0 +1,244 @@
+// Package api provides HTTP handlers for shell command execution
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"echohelix/bridge/internal/danger"
+	"echohelix/bridge/internal/shell"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultExecAllowlist is used when EXEC_ALLOWLIST is not configured
+var defaultExecAllowlist = []string{
+	"go build", "go test", "go vet", "go run",
+	"npm test", "npm run", "npm install", "yarn", "pnpm",
+	"make", "cargo build", "cargo test", "cargo check",
+	"pytest", "python -m pytest", "git status", "git diff", "git log", "ls",
+}
+
+func (s *Server) execAllowlist() []string {
+	if v := s.configSvc.Get("EXEC_ALLOWLIST"); v != "" {
+		return strings.Split(v, ",")
+	}
+	return defaultExecAllowlist
+}
+
+// resolveWorkDir resolves a request cwd against the active workspace
+func (s *Server) resolveWorkDir(cwd string) string {
+	base := ""
+	if s.processManager != nil {
+		base = s.processManager.WorkDir
+	}
+	if cwd == "" {
+		return base
+	}
+	if filepath.IsAbs(cwd) {
+		return cwd
+	}
+	return filepath.Join(base, cwd)
+}
+
+// HandleExec runs a shell command in the workspace.
+// Commands outside the allowlist require confirm=true, and so does any
+// command given env, since variables such as GOFLAGS, LD_PRELOAD or
+// NODE_OPTIONS make an allowlisted command run other code; dangerous ones
+// such as rm -rf or git push --force require a confirm_token from
+// POST /api/v2/confirm instead. With
+// Accept: text/event-stream the output is streamed in the response,
+// otherwise the run is returned and can be followed via /exec/{id}/stream.
+// POST /api/v2/exec {"command": "...", "cwd": "", "env": {}, "timeout_seconds": 600, "confirm": false, "confirm_token": ""}
+func (s *Server) HandleExec(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Command        string            `json:"command"`
+		Cwd            string            `json:"cwd"`
+		Env            map[string]string `json:"env"`
+		TimeoutSeconds int               `json:"timeout_seconds"`
+		Confirm        bool              `json:"confirm"`
+		ConfirmToken   string            `json:"confirm_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	op := danger.Operation{Action: danger.ActionExec, Target: req.Command}
+	if op.Class = danger.ClassifyCommand(req.Command); op.Class != "" {
+		if !s.confirmed(w, r, op, req.ConfirmToken) {
+			return
+		}
+	}
+	// 危险命令已由确认令牌放行；带 env 的命令仍需 confirm=true
+	allowed := op.Class != "" || shell.IsAllowed(req.Command, s.execAllowlist())
+	if !req.Confirm && (len(req.Env) > 0 || !allowed) {
+		details := map[string]interface{}{"command": req.Command}
+		if len(req.Env) > 0 {
+			names := make([]string, 0, len(req.Env))
+			for name := range req.Env {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			details["env"] = names
+		}
+		WriteError(w, CodeConfirmationRequired, http.StatusConflict, details)
+		return
+	}
+
+	run, err := s.shellRunner.Start(shell.Request{
+		Command: req.Command,
+		Dir:     s.resolveWorkDir(req.Cwd),
+		Env:     req.Env,
+		Timeout: time.Duration(req.TimeoutSeconds) * time.Second,
+		Source:  "exec",
+	})
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamRunSSE(w, r, run)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run.Snapshot())
+}
+
+// HandleExecList returns recorded runs
+// GET /api/v2/exec/runs
+func (s *Server) HandleExecList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	runs := s.shellRunner.List()
+	result := make([]shell.RunInfo, 0, len(runs))
+	for _, run := range runs {
+		snap := run.Snapshot()
+		snap.Output = ""
+		result = append(result, snap)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"runs":  result,
+		"count": len(result),
+	})
+}
+
+// HandleExecGet returns a run including its output tail
+// GET /api/v2/exec/{id}
+func (s *Server) HandleExecGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	run, ok := s.shellRunner.Get(mux.Vars(r)["id"])
+	if !ok {
+		writeServiceError(w, http.StatusNotFound, shell.ErrRunNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(run.Snapshot())
+}
+
+// HandleExecCancel cancels a running command
+// POST /api/v2/exec/{id}/cancel
+func (s *Server) HandleExecCancel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.shellRunner.Cancel(mux.Vars(r)["id"]) {
+		writeServiceError(w, http.StatusNotFound, shell.ErrRunNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleExecStream streams a run's output over WebSocket or SSE
+// GET /api/v2/exec/{id}/stream
+func (s *Server) HandleExecStream(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.shellRunner.Get(mux.Vars(r)["id"])
+	if !ok {
+		writeServiceError(w, http.StatusNotFound, shell.ErrRunNotFound)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.streamRunWS(w, r, run)
+		return
+	}
+	streamRunSSE(w, r, run)
+}
+
+// streamRunSSE writes run output as server-sent events until it finishes
+func streamRunSSE(w http.ResponseWriter, r *http.Request, run *shell.Run) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Run-ID", run.ID)
+
+	replay, ch, cancel := run.Subscribe()
+	defer cancel()
+
+	writeEvent := func(c shell.Chunk) {
+		data, _ := json.Marshal(c)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", c.Stream, data)
+		flusher.Flush()
+	}
+
+	for _, c := range replay {
+		writeEvent(c)
+	}
+	for {
+		select {
+		case c, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(c)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// streamRunWS writes run output as WebSocket JSON messages until it finishes
+func (s *Server) streamRunWS(w http.ResponseWriter, r *http.Request, run *shell.Run) {
+	conn, err := s.upgrade(w, r)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to upgrade websocket")
+		return
+	}
+	defer conn.Close()
+
+	replay, ch, cancel := run.Subscribe()
+	defer cancel()
+
+	for _, c := range replay {
+		if err := conn.WriteJSON(c); err != nil {
+			return
+		}
+	}
+	for c := range ch {
+		if err := conn.WriteJSON(c); err != nil {
+			return
+		}
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/forward"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// previewCookie carries the device token for the pages and assets of a
+// preview, which the web view loads without an Authorization header
+const previewCookie = "echohelix_preview"
+
+// setupForwarding creates the port forward manager. Ports opened by the
+// kernel, exec runs, and terminals (or their children) are forwarded
+// automatically unless FORWARD_AUTODETECT=false.
+func (s *Server) setupForwarding() {
+	cfg := forward.Config{
+		OnChange: func(event string, f forward.Forward) {
+			s.eventBus.Publish("forward."+event, f)
+		},
+	}
+	if s.configSvc.Get("FORWARD_AUTODETECT") != "false" {
+		cfg.Roots = s.managedPIDs
+		cfg.Ignore = func(port int) bool {
+			return s.processManager != nil && port == s.processManager.Status().Port
+		}
+	}
+	s.forwards = forward.NewManager(cfg)
+}
+
+// managedPIDs returns the processes started by the bridge
+func (s *Server) managedPIDs() []int {
+	var pids []int
+	if s.processManager != nil {
+		if pid := s.processManager.Status().PID; pid != 0 {
+			pids = append(pids, pid)
+		}
+	}
+	pids = append(pids, s.shellRunner.RunningPIDs()...)
+	pids = append(pids, s.terminalMgr.PIDs()...)
+	return pids
+}
+
+// HandleForwardList returns the active forwards and the listening ports
+// found on managed processes
+// GET /api/v2/forwards
+func (s *Server) HandleForwardList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"forwards":  s.forwards.List(),
+		"listeners": s.forwards.Listeners(),
+	})
+}
+
+// HandleForwardAdd forwards a local port; it is then served under the
+// returned path, /preview/<id>/. Pages are rewritten to work under that
+// path unless raw is true.
+// POST /api/v2/forward
+func (s *Server) HandleForwardAdd(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Port  int    `json:"port"`
+		Label string `json:"label"`
+		Raw   bool   `json:"raw"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	f, err := s.forwards.Add(req.Port, forward.Options{Label: req.Label, Raw: req.Raw})
+	if err != nil {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(f)
+}
+
+// HandleForwardRemove stops forwarding a port
+// DELETE /api/v2/forward?id=...
+func (s *Server) HandleForwardRemove(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+	if !s.forwards.Remove(id) {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "Forward not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePreview proxies a forwarded port. The device token may be sent as
+// a bearer token or ?token= on the first request; it is then kept in a
+// cookie scoped to the preview so the page's own requests are authorized.
+// GET /preview/{id}/...
+func (s *Server) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	f, ok := s.forwards.Get(id)
+	if !ok {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "Forward not found")
+		return
+	}
+
+	if !isSocketRequest(r) {
+		value, fromCookie := previewToken(r)
+		if value == "" {
+			WriteError(w, CodeUnauthorized, http.StatusUnauthorized, nil)
+			return
+		}
+		token, err := s.authHandler.Authenticate(r, value)
+		if err != nil {
+			writeServiceError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if !token.HasPermission(auth.PermissionRead) {
+			WriteError(w, CodeForbidden, http.StatusForbidden, nil)
+			return
+		}
+		if !fromCookie {
+			http.SetCookie(w, &http.Cookie{
+				Name:     previewCookie,
+				Value:    value,
+				Path:     f.Path,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+				Secure:   r.TLS != nil,
+			})
+		}
+	}
+
+	// 不把桥接凭据转发给开发服务器
+	r.Header.Del("Authorization")
+	query := r.URL.Query()
+	if query.Has("token") {
+		query.Del("token")
+		r.URL.RawQuery = query.Encode()
+	}
+	stripCookie(r, previewCookie)
+
+	log.Ctx(r.Context()).Debug().Str("forward", id).Str("path", r.URL.Path).Msg("Preview request")
+	s.forwards.ServeHTTP(w, r)
+}
+
+// HandlePreviewRedirect adds the trailing slash so relative URLs in the
+// page resolve inside the preview
+// GET /preview/{id}
+func (s *Server) HandlePreviewRedirect(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Path + "/"
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+// previewToken returns the device token of a preview request and whether
+// it came from the preview cookie
+func previewToken(r *http.Request) (string, bool) {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(h), "bearer ") {
+		return strings.TrimSpace(h[len("bearer "):]), false
+	}
+	if t := r.URL.Query().Get("token"); t != "" {
+		return t, false
+	}
+	if c, err := r.Cookie(previewCookie); err == nil {
+		return c.Value, true
+	}
+	return "", false
+}
+
+// stripCookie removes one cookie from the request's Cookie header
+func stripCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != name {
+			r.AddCookie(c)
+		}
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"encoding/json"
 	"net/http"
 	"path/filepath"
+	"strings"
 
 	"echohelix/bridge/internal/fs"
 
 	"github.com/rs/zerolog/log"
 )
 
-// HandleFSList returns a list of files in the workspace
-// GET /api/v2/fs/ls?path=.&recursive=true
+// HandleFSList returns a list of files in the workspace. Responses carry
+// an ETag; with If-None-Match an unchanged listing is 304 Not Modified.
+// With limit= or cursor= the listing is paginated in path order and
+// wrapped as {entries, next_cursor}; format=ndjson instead streams one
+// entry per line while the tree is walked.
+// GET /api/v2/fs/ls?path=.&recursive=true[&root=ssh://user@host/path][&limit=&cursor=][&format=ndjson]
 func (s *Server) HandleFSList(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	relPath := query.Get("path")
@@ -19,32 +24,132 @@ func (s *Server) HandleFSList(w http.ResponseWriter, r *http.Request) {
 		relPath = "."
 	}
 	recursive := query.Get("recursive") == "true"
+	ndjson := query.Get("format") == "ndjson"
+	page, err := parsePage(r)
+	if err != nil {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err)
+		return
+	}
 
-	if s.processManager == nil {
-		http.Error(w, "ProcessManager not initialized", http.StatusInternalServerError)
+	// Remote (SSH) workspace
+	if base, rel, ok, err := s.remoteLocation(query.Get("root"), relPath); ok {
+		if err != nil {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err)
+			return
+		}
+		entries, err := s.remotePool.ListFiles(base, rel, recursive)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("path", relPath).Msg("Failed to list remote files")
+			WriteError(w, CodeUpstreamError, http.StatusBadGateway, "Failed to list files: "+err.Error())
+			return
+		}
+		switch {
+		case ndjson:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			enc := json.NewEncoder(w)
+			for _, e := range entries {
+				enc.Encode(e)
+			}
+		case page.active:
+			writeJSONWithETag(w, r, fileListPage(entries, page))
+		default:
+			writeJSONWithETag(w, r, entries)
+		}
 		return
 	}
 
-	// Use ProcessManager's WorkDir as the root
-	walker := fs.NewWalker(s.processManager.WorkDir)
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
 
 	// Validate path is not escaping root (basic check)
 	cleanPath := filepath.Clean(relPath)
-	if cleanPath == ".." || cleanPath[:3] == "../" {
-		http.Error(w, "Invalid path: cannot escape root", http.StatusBadRequest)
+	if cleanPath == ".." || strings.HasPrefix(cleanPath, "../") {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "Invalid path: cannot escape root")
+		return
+	}
+
+	// 工作区策略隐藏的目录不能列出，其下的条目也不返回
+	root := s.processManager.WorkDir
+	policy, err := fs.LoadPolicy(root)
+	if err == nil {
+		err = policy.Check(fs.OpRead, cleanPath)
+	}
+	if err == nil {
+		err = s.checkPrivate(fs.OpRead, filepath.Join(root, cleanPath))
+	}
+	if err != nil {
+		writeFSError(w, err)
+		return
+	}
+	readable := s.listReadable(root, policy)
+
+	if ndjson {
+		streamFileList(w, r, fs.NewWalker(root), cleanPath, recursive, readable)
 		return
 	}
 
-	entries, err := walker.ListFiles(cleanPath, recursive)
+	// Use ProcessManager's WorkDir as the root; unchanged listings come
+	// from the cache without walking the tree again
+	entries, etag, err := s.fsCache.List(root, cleanPath, recursive)
 	if err != nil {
-		log.Error().Err(err).Str("path", relPath).Msg("Failed to list files")
-		http.Error(w, "Failed to list files: "+err.Error(), http.StatusInternalServerError)
+		log.Ctx(r.Context()).Error().Err(err).Str("path", relPath).Msg("Failed to list files")
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Failed to list files: "+err.Error())
+		return
+	}
+	if notModified(w, r, etag) {
 		return
 	}
+	entries = readableEntries(readable, entries)
 
+	var resp interface{} = entries
+	if page.active {
+		resp = fileListPage(entries, page)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(entries); err != nil {
-		log.Error().Err(err).Msg("Failed to encode response")
-		http.Error(w, "Internal serialization error", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to encode response")
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Internal serialization error")
+	}
+}
+
+// fileListPage is one page of a listing, in path order
+func fileListPage(entries []fs.FileEntry, page pageRequest) map[string]interface{} {
+	items, next := paginate(entries, func(e fs.FileEntry) string { return e.Path }, page)
+	return map[string]interface{}{
+		"entries":     items,
+		"count":       len(items),
+		"next_cursor": next,
+	}
+}
+
+// streamFileList writes a listing as NDJSON while walking the tree,
+// flushing every few hundred entries so large trees show up at once.
+// Entries readable refuses are skipped.
+func streamFileList(w http.ResponseWriter, r *http.Request, walker *fs.Walker, relPath string, recursive bool, readable func(rel string) bool) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	n := 0
+	err := walker.Walk(r.Context(), relPath, recursive, func(e fs.FileEntry) error {
+		if !readable(e.Path) {
+			return nil
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		if n++; n%500 == 0 && flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil && n == 0 && r.Context().Err() == nil {
+		// 尚未写出任何内容时仍可返回错误
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Failed to list files: "+err.Error())
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Int("entries", n).Msg("File list stream ended early")
 	}
 }
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+
+	"echohelix/bridge/internal/fs"
 )
 
 // HandleRoots returns available root directories
@@ -70,16 +72,13 @@ func (s *Server) HandleRoots(w http.ResponseWriter, r *http.Request) {
 }
 
 // HandleStat returns file info
-// GET /api/v2/fs/stat?path=...
+// GET /api/v2/fs/stat?path=...[&root=ssh://user@host/path]
 func (s *Server) HandleStat(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	path := r.URL.Query().Get("path")
 	if path == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "path parameter is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path parameter is required")
 		return
 	}
 
@@ -89,17 +88,26 @@ func (s *Server) HandleStat(w http.ResponseWriter, r *http.Request) {
 	// but relative paths for project access.
 	// For simplicity, let's treat it as: if absolute, use it; if relative, join with WorkDir.
 
-	targetPath := path
-	if !filepath.IsAbs(targetPath) && s.processManager != nil {
-		targetPath = filepath.Join(s.processManager.WorkDir, path)
+	var info os.FileInfo
+	var err error
+	if base, rel, ok, rerr := s.remoteLocation(r.URL.Query().Get("root"), path); ok {
+		if rerr != nil {
+			err = rerr
+		} else {
+			info, err = s.remoteStat(base, rel)
+		}
+	} else {
+		targetPath := path
+		if s.processManager != nil {
+			if targetPath, err = s.resolveFSPath(fs.OpRead, path); err != nil {
+				writeFSError(w, err)
+				return
+			}
+		}
+		info, err = os.Stat(targetPath)
 	}
-
-	info, err := os.Stat(targetPath)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": err.Error(),
-		})
+		writeServiceError(w, http.StatusNotFound, err)
 		return
 	}
 
@@ -113,22 +121,37 @@ func (s *Server) HandleStat(w http.ResponseWriter, r *http.Request) {
 }
 
 // HandleExists checks existence
-// GET /api/v2/fs/exists?path=...
+// GET /api/v2/fs/exists?path=...[&root=ssh://user@host/path]
 func (s *Server) HandleExists(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	path := r.URL.Query().Get("path")
 	if path == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path parameter is required")
+		return
+	}
+
+	if base, rel, ok, err := s.remoteLocation(r.URL.Query().Get("root"), path); ok {
+		exists, isDir := false, false
+		if err == nil {
+			if info, err := s.remoteStat(base, rel); err == nil {
+				exists, isDir = true, info.IsDir()
+			}
+		}
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "path parameter is required",
+			"exists":       exists,
+			"is_directory": isDir,
 		})
 		return
 	}
 
 	targetPath := path
-	if !filepath.IsAbs(targetPath) && s.processManager != nil {
-		targetPath = filepath.Join(s.processManager.WorkDir, path)
+	if s.processManager != nil {
+		var err error
+		if targetPath, err = s.resolveFSPath(fs.OpRead, path); err != nil {
+			writeFSError(w, err)
+			return
+		}
 	}
 
 	_, err := os.Stat(targetPath)
@@ -9,11 +9,23 @@ import (
 	"path/filepath"
 	"strconv"
 
+	"echohelix/bridge/internal/danger"
+	"echohelix/bridge/internal/fs"
+	"echohelix/bridge/internal/fslock"
+	"echohelix/bridge/internal/recentfiles"
+
 	"github.com/rs/zerolog/log"
 )
 
-// HandleFile returns file content
-// GET /api/v2/fs/file?path=...&offset=0&limit=0
+// HandleFile returns file content, with the lock of whoever else is
+// editing it; session_id= counts the read toward that session's files.
+// Text is decoded to UTF-8 from the encoding detected for the file,
+// reported with its line endings as encoding and eol; offset and limit
+// count bytes of the file as stored.
+// The ETag is the SHA-256 of the whole file, also returned as sha256, so
+// a client holding a copy can send If-None-Match with the hash of its
+// content and get 304 Not Modified when the file is unchanged.
+// GET /api/v2/fs/file?path=...&offset=0&limit=0[&root=ssh://user@host/path][&session_id=]
 func (s *Server) HandleFile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -22,17 +34,19 @@ func (s *Server) HandleFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if base, rel, ok, err := s.remoteLocation(r.URL.Query().Get("root"), r.URL.Query().Get("path")); ok {
+		s.handleRemoteFile(w, r, base, rel, err)
+		return
+	}
+
 	if s.processManager == nil {
-		http.Error(w, "ProcessManager not initialized", http.StatusInternalServerError)
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
 		return
 	}
 
 	relPath := r.URL.Query().Get("path")
 	if relPath == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "path parameter is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path parameter is required")
 		return
 	}
 
@@ -50,41 +64,42 @@ func (s *Server) HandleFile(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	fullPath := filepath.Join(s.processManager.WorkDir, relPath)
-
-	// Security check to prevent escaping WorkDir
-	// Note: basic check. For production, more robust sandboxing is needed.
-	// But EchoHelix acts as a local agent, so we trust the user context mostly.
+	fullPath, err := s.resolveFSPath(fs.OpRead, relPath)
+	if err != nil {
+		writeFSError(w, err)
+		return
+	}
 
 	f, err := os.Open(fullPath)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": fmt.Sprintf("File not found or unreadable: %s", err),
-		})
+		WriteError(w, CodeFileNotFound, http.StatusNotFound, fmt.Sprintf("File not found or unreadable: %s", err))
 		return
 	}
 	defer f.Close()
 
 	info, err := f.Stat()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": err.Error(),
-		})
+		writeServiceError(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	if info.IsDir() {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "path is a directory",
-		})
+		WriteError(w, CodeIsDirectory, http.StatusBadRequest, "path is a directory")
 		return
 	}
 
 	fileSize := info.Size()
 
+	sum, err := s.fileHashes.Hash(f, info)
+	if err != nil {
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Read failed")
+		return
+	}
+	if notModified(w, r, `"`+sum+`"`) {
+		s.trackFile(r, recentfiles.OpRead, relPath)
+		return
+	}
+
 	if int64(offset) > fileSize {
 		offset = int(fileSize) // Clamp
 	}
@@ -96,7 +111,7 @@ func (s *Server) HandleFile(w http.ResponseWriter, r *http.Request) {
 
 	// Seek
 	if _, err := f.Seek(int64(offset), 0); err != nil {
-		http.Error(w, "Seek failed", http.StatusInternalServerError)
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Seek failed")
 		return
 	}
 
@@ -104,89 +119,205 @@ func (s *Server) HandleFile(w http.ResponseWriter, r *http.Request) {
 	buf := make([]byte, limit)
 	n, err := io.ReadFull(f, buf)
 	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
-		http.Error(w, "Read failed", http.StatusInternalServerError)
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Read failed")
 		return
 	}
 	buf = buf[:n]
+	s.trackFile(r, recentfiles.OpRead, relPath)
+	enc, eol := detectText(f)
 
 	// Response structure from V1
 	resp := map[string]interface{}{
 		"path":      relPath,
-		"content":   string(buf),
+		"content":   fs.Decode(buf, enc),
 		"size":      fileSize,
 		"offset":    offset,
 		"limit":     limit,
 		"truncated": int64(offset+limit) < fileSize, // Crude truncated check
-		"is_binary": false,                          // TODO: Implement binary check if needed
+		"is_binary": enc == fs.EncodingBinary,
+		"encoding":  enc,
+		"eol":       eol,
+		"sha256":    sum,
+	}
+	if lock := s.lockConflict(s.trackedPath(relPath), lockOwner(r), fslock.KindDevice); lock != nil && lock.Mode == fslock.ModeWrite {
+		resp["lock"] = lock
 	}
 
 	json.NewEncoder(w).Encode(resp)
 }
 
-// HandleWriteFile writes content to a file
-// POST /api/v2/fs/write
+// HandleWriteFile writes content to a file; session_id= counts the write
+// toward that session's files. A file locked by another device or the AI
+// is not written unless force is set. encoding and preserve_eol keep a
+// local file's encoding and line endings instead of writing UTF-8 as sent.
+// POST /api/v2/fs/write[?session_id=]
+// PUT /api/v3/fs/file
 func (s *Server) HandleWriteFile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if r.Method != http.MethodPost {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
 	if s.processManager == nil {
-		http.Error(w, "ProcessManager not initialized", http.StatusInternalServerError)
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
 		return
 	}
 
 	var req struct {
 		Path    string `json:"path"`
+		Root    string `json:"root,omitempty"`
 		Content string `json:"content"`
+		// ConfirmToken confirms a write outside the workspace
+		ConfirmToken string `json:"confirm_token,omitempty"`
+		// Force writes a file another device or the AI holds a lock on
+		Force bool `json:"force,omitempty"`
+		// Encoding stores the content in this encoding, or in the existing
+		// file's with "auto"; UTF-8 by default
+		Encoding string `json:"encoding,omitempty"`
+		// PreserveEOL converts the content's line breaks to those of the
+		// existing file, so an app editing with LF keeps a CRLF file CRLF
+		PreserveEOL bool `json:"preserve_eol,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "invalid request body",
-		})
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
 	if req.Path == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path parameter is required")
+		return
+	}
+
+	if err := s.checkFSWrite(r.Context(), "api", req.Root, req.Path, req.Content); err != nil {
+		writePluginError(w, err)
+		return
+	}
+
+	if base, rel, ok, err := s.remoteLocation(req.Root, req.Path); ok {
+		if err != nil {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.remotePool.WriteFile(base.Join(rel), []byte(req.Content)); err != nil {
+			WriteError(w, CodeWriteFailed, http.StatusBadGateway, "failed to write file: "+err.Error())
+			log.Ctx(r.Context()).Error().Err(err).Str("path", req.Path).Msg("Failed to write remote file")
+			return
+		}
+		log.Ctx(r.Context()).Info().Str("path", req.Path).Msg("Remote file written successfully")
+		s.eventBus.Publish("fs.written", map[string]interface{}{
+			"path": req.Path,
+			"root": req.Root,
+			"size": len(req.Content),
+		})
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "path parameter is required",
+			"success": true,
+			"path":    req.Path,
 		})
 		return
 	}
 
-	fullPath := filepath.Join(s.processManager.WorkDir, req.Path)
+	fullPath, err := s.resolveFSPath(fs.OpWrite, req.Path)
+	if err != nil {
+		writeFSError(w, err)
+		return
+	}
+	if lock := s.lockConflict(s.trackedPath(req.Path), lockOwner(r), fslock.KindDevice); lock != nil && !req.Force {
+		writeLocked(w, lock)
+		return
+	}
+	data, enc, eol, err := encodeForWrite(fullPath, req.Content, req.Encoding, req.PreserveEOL)
+	if err != nil {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err)
+		return
+	}
+	op := danger.Operation{Action: danger.ActionFSWrite, Target: fullPath}
+	op.Class = s.classify(op)
+	if !s.confirmed(w, r, op, req.ConfirmToken) {
+		return
+	}
+
+	s.checkpointBeforeWrite(s.processManager.WorkDir, req.Path)
 
 	// Ensure dir exists
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "failed to create directory: " + err.Error(),
-		})
+		WriteError(w, CodeWriteFailed, http.StatusInternalServerError, "failed to create directory: "+err.Error())
 		return
 	}
 
 	// Write file
 	// Use os.WriteFile for atomic-ish write (replace content)
-	if err := os.WriteFile(fullPath, []byte(req.Content), 0644); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "failed to write file: " + err.Error(),
-		})
-		log.Error().Err(err).Str("path", fullPath).Msg("Failed to write file")
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		WriteError(w, CodeWriteFailed, http.StatusInternalServerError, "failed to write file: "+err.Error())
+		log.Ctx(r.Context()).Error().Err(err).Str("path", fullPath).Msg("Failed to write file")
 		return
 	}
 
-	log.Info().Str("path", req.Path).Msg("File written successfully")
+	log.Ctx(r.Context()).Info().Str("path", req.Path).Msg("File written successfully")
+	s.trackFile(r, recentfiles.OpWrite, req.Path)
+	s.eventBus.Publish("fs.written", map[string]interface{}{
+		"path": req.Path,
+		"size": len(data),
+	})
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"path":    req.Path,
+		"success":  true,
+		"path":     req.Path,
+		"encoding": enc,
+		"eol":      eol,
 	})
 }
+
+// detectText detects the encoding and line endings of a file from its
+// start. Binary files have no line endings.
+func detectText(f io.ReaderAt) (fs.Encoding, fs.EOL) {
+	sample := make([]byte, fs.DetectSample)
+	n, _ := f.ReadAt(sample, 0)
+	enc := fs.DetectEncoding(sample[:n], n == len(sample))
+	if enc == fs.EncodingBinary {
+		return enc, ""
+	}
+	return enc, fs.DetectEOL(fs.Decode(sample[:n], enc))
+}
+
+// encodeForWrite applies the encoding and preserve_eol options of a write
+// to content, detecting the existing file's when they refer to it. It
+// returns the bytes to write with their encoding and line endings.
+func encodeForWrite(fullPath, content, encoding string, preserveEOL bool) ([]byte, fs.Encoding, fs.EOL, error) {
+	var current fs.Encoding
+	var currentEOL fs.EOL
+	if encoding == "auto" || preserveEOL {
+		if f, err := os.Open(fullPath); err == nil {
+			current, currentEOL = detectText(f)
+			f.Close()
+		}
+	}
+
+	enc := fs.EncodingUTF8
+	switch encoding {
+	case "":
+	case "auto":
+		// 新文件或二进制文件按 UTF-8 写入
+		if current != "" && current != fs.EncodingBinary {
+			enc = current
+		}
+	default:
+		var err error
+		if enc, err = fs.ParseEncoding(encoding); err != nil {
+			return nil, "", "", err
+		}
+	}
+	if preserveEOL {
+		content = fs.ConvertEOL(content, currentEOL)
+	}
+
+	data, err := fs.Encode(content, enc)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return data, enc, fs.DetectEOL(content), nil
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"echohelix/bridge/internal/fslock"
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/recentfiles"
+
+	"github.com/rs/zerolog/log"
+)
+
+// kernelLockTTL bounds an AI lock whose tool call never reports its end
+const kernelLockTTL = 2 * time.Minute
+
+// setupLocks creates the advisory lock service and announces lock changes
+// so editors can warn while the AI or another device edits a file
+func (s *Server) setupLocks() {
+	s.locks = fslock.NewService()
+	s.locks.OnChange(func(l fslock.Lock, held bool) {
+		topic := "fs.unlocked"
+		if held {
+			topic = "fs.locked"
+		}
+		s.eventBus.Publish(topic, l)
+	})
+}
+
+// lockOwner identifies the holder of the request's locks: the paired
+// device, or "local" for requests from this machine
+func lockOwner(r *http.Request) string {
+	if id := deviceID(r); id != "" {
+		return id
+	}
+	return "local"
+}
+
+// lockConflict returns the lock that should stop a write to path: any
+// lock held by another device, and for devices also the AI's. Locks of
+// the AI never stop the AI, whichever kernel or tool holds them.
+func (s *Server) lockConflict(path, owner string, kind fslock.Kind) *fslock.Lock {
+	var in *fslock.Lock
+	for _, l := range s.locks.List(path) {
+		if l.Owner == owner || (kind == fslock.KindAI && l.Kind == fslock.KindAI) {
+			continue
+		}
+		if in == nil || l.Mode == fslock.ModeWrite {
+			l := l
+			in = &l
+		}
+	}
+	return in
+}
+
+// writeLocked answers a write stopped by lock with 409 FILE_LOCKED and
+// the lock in the details
+func writeLocked(w http.ResponseWriter, lock *fslock.Lock) {
+	WriteError(w, fslock.CodeLocked, http.StatusConflict, map[string]interface{}{"lock": lock})
+}
+
+// lockKernelFiles holds a write lock for the AI on the file of a write
+// tool call while it runs, releasing it when the call ends
+func (s *Server) lockKernelFiles(sessionID string, ev kernel.Event) {
+	if ev.ToolCall == nil {
+		return
+	}
+	if op, ok := toolFileOp(ev.ToolCall.Name); !ok || op != recentfiles.OpWrite {
+		return
+	}
+	path := toolFilePath(ev.ToolCall)
+	if path == "" {
+		return
+	}
+	path = s.trackedPath(path)
+	owner := "kernel:" + ev.Kernel
+
+	switch ev.ToolCall.Status {
+	case "completed", "failed", kernel.ToolCancelled:
+		s.locks.ReleasePath(path, owner)
+		return
+	}
+	_, err := s.locks.Acquire(fslock.Request{
+		Path:      path,
+		Mode:      fslock.ModeWrite,
+		Owner:     owner,
+		Kind:      fslock.KindAI,
+		SessionID: sessionID,
+		TTL:       kernelLockTTL,
+	})
+	var lockErr *fslock.LockError
+	if errors.As(err, &lockErr) && lockErr.Lock != nil {
+		// 内核无法被阻止，只提醒正在编辑的设备
+		log.Warn().Str("path", path).Str("holder", lockErr.Lock.Owner).Msg("AI is writing a file locked by a device")
+		s.eventBus.Publish("fs.lock_conflict", map[string]interface{}{
+			"path":       path,
+			"lock":       lockErr.Lock,
+			"kernel":     ev.Kernel,
+			"session_id": sessionID,
+		})
+	}
+}
+
+// HandleFSLock takes or renews an advisory lock on a file. Write locks
+// are exclusive; read locks are shared with other readers. A file locked
+// by someone else answers 409 FILE_LOCKED with the lock in the details.
+// POST /api/v2/fs/lock {"path": "", "mode": "write", "ttl_seconds": 60, "session_id": ""}
+func (s *Server) HandleFSLock(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path       string      `json:"path"`
+		Mode       fslock.Mode `json:"mode"`
+		TTLSeconds int         `json:"ttl_seconds"`
+		SessionID  string      `json:"session_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Path == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path is required")
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = fslock.ModeWrite
+	}
+	if req.TTLSeconds < 0 {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "ttl_seconds must not be negative")
+		return
+	}
+
+	lock, err := s.locks.Acquire(fslock.Request{
+		Path:      s.trackedPath(req.Path),
+		Mode:      req.Mode,
+		Owner:     lockOwner(r),
+		Kind:      fslock.KindDevice,
+		SessionID: req.SessionID,
+		TTL:       time.Duration(req.TTLSeconds) * time.Second,
+	})
+	var lockErr *fslock.LockError
+	switch {
+	case errors.As(err, &lockErr) && lockErr.Lock != nil:
+		writeLocked(w, lockErr.Lock)
+		return
+	case err != nil:
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lock)
+}
+
+// HandleFSLockHeartbeat keeps a lock alive for another TTL
+// POST /api/v2/fs/lock/heartbeat?id=
+func (s *Server) HandleFSLockHeartbeat(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+
+	lock, err := s.locks.Heartbeat(id, lockOwner(r))
+	if err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lock)
+}
+
+// HandleFSUnlock releases a lock the caller holds
+// DELETE /api/v2/fs/lock?id=
+func (s *Server) HandleFSUnlock(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+	if err := s.locks.Release(id, lockOwner(r)); err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleFSLocks lists the live locks, on one file with ?path=
+// GET /api/v2/fs/locks?path=
+func (s *Server) HandleFSLocks(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path != "" {
+		path = s.trackedPath(path)
+	}
+	locks := s.locks.List(path)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"locks": locks,
+		"count": len(locks),
+	})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"echohelix/bridge/internal/fs"
+)
+
+const (
+	minSyncBlockSize = 256
+	maxSyncBlockSize = 1 << 20
+	maxSyncFileSize  = 64 << 20
+)
+
+// HandleFSSync sends only what changed in a file since the client's
+// copy, rsync style. The client cuts its copy into block_size blocks and
+// sends each block's checksums (see fs.BlockSignature) and its size; the
+// response lists the blocks to reuse and the literal bytes in between,
+// plus the new file's SHA-256 to verify the rebuilt copy. Without
+// signatures the whole file comes back as one literal.
+// POST /api/v2/fs/sync
+func (s *Server) HandleFSSync(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
+
+	var req struct {
+		Path       string              `json:"path"`
+		BlockSize  int                 `json:"block_size"`
+		Size       int64               `json:"size"` // 客户端副本大小，用于确定末尾短块
+		Signatures []fs.BlockSignature `json:"signatures"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+		return
+	}
+	if req.Path == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path is required")
+		return
+	}
+	if len(req.Signatures) > 0 {
+		if req.BlockSize < minSyncBlockSize || req.BlockSize > maxSyncBlockSize {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, fmt.Sprintf("block_size must be between %d and %d", minSyncBlockSize, maxSyncBlockSize))
+			return
+		}
+		if want := (req.Size + int64(req.BlockSize) - 1) / int64(req.BlockSize); want != int64(len(req.Signatures)) {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "signatures do not match size and block_size")
+			return
+		}
+	}
+
+	fullPath, err := s.resolveFSPath(fs.OpRead, req.Path)
+	if err != nil {
+		writeFSError(w, err)
+		return
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		WriteError(w, CodeFileNotFound, http.StatusNotFound, fmt.Sprintf("File not found or unreadable: %s", err))
+		return
+	}
+	if info.IsDir() {
+		WriteError(w, CodeIsDirectory, http.StatusBadRequest, "path is a directory")
+		return
+	}
+	if info.Size() > maxSyncFileSize {
+		WriteError(w, CodeInvalidRequest, http.StatusRequestEntityTooLarge, fmt.Sprintf("files over %d bytes cannot be synced", maxSyncFileSize))
+		return
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	lastSize := 0
+	if req.BlockSize > 0 {
+		lastSize = int(req.Size % int64(req.BlockSize))
+	}
+	ops := fs.Delta(data, req.BlockSize, req.Signatures, lastSize)
+
+	literal, copied := 0, 0
+	for _, op := range ops {
+		if op.Copy != nil {
+			copied += op.Copy.Count
+		} else {
+			literal += len(op.Data)
+		}
+	}
+	sum := sha256.Sum256(data)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":          req.Path,
+		"size":          len(data),
+		"modified_time": info.ModTime(),
+		"sha256":        hex.EncodeToString(sum[:]),
+		"block_size":    req.BlockSize,
+		"ops":           ops,
+		"copied_blocks": copied,
+		"literal_bytes": literal,
+	})
+}
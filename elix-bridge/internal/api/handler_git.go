@@ -0,0 +1,269 @@
+// Package api provides HTTP handlers for git operations
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"echohelix/bridge/internal/git"
+	"echohelix/bridge/internal/jobs"
+)
+
+// openRepo opens the repository for the request's workspace
+// (?dir= overrides the active WorkDir). It writes an error response on failure.
+func (s *Server) openRepo(w http.ResponseWriter, r *http.Request) (*git.Repo, bool) {
+	dir := r.URL.Query().Get("dir")
+	if dir == "" {
+		if s.processManager == nil {
+			WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+			return nil, false
+		}
+		dir = s.processManager.WorkDir
+	} else if !filepath.IsAbs(dir) && s.processManager != nil {
+		dir = filepath.Join(s.processManager.WorkDir, dir)
+	}
+
+	repo, err := git.Open(dir)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	return repo, true
+}
+
+// HandleGitStatus returns the work tree status
+// GET /api/v2/git/status?dir=...
+func (s *Server) HandleGitStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
+
+// HandleGitDiff returns a unified diff
+// GET /api/v2/git/diff?path=...&staged=true
+func (s *Server) HandleGitDiff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	staged := r.URL.Query().Get("staged") == "true"
+
+	diff, err := repo.Diff(path, staged)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":   path,
+		"staged": staged,
+		"diff":   diff,
+	})
+}
+
+// HandleGitLog returns recent commits
+// GET /api/v2/git/log?limit=20
+func (s *Server) HandleGitLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 20
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+
+	commits, err := repo.Log(limit)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"commits": commits,
+		"count":   len(commits),
+	})
+}
+
+// HandleGitStage stages paths (all changes when none given)
+// POST /api/v2/git/stage {"paths": [...]}
+func (s *Server) HandleGitStage(w http.ResponseWriter, r *http.Request) {
+	s.handleGitPaths(w, r, (*git.Repo).Stage)
+}
+
+// HandleGitUnstage unstages paths (everything when none given)
+// POST /api/v2/git/unstage {"paths": [...]}
+func (s *Server) HandleGitUnstage(w http.ResponseWriter, r *http.Request) {
+	s.handleGitPaths(w, r, (*git.Repo).Unstage)
+}
+
+func (s *Server) handleGitPaths(w http.ResponseWriter, r *http.Request, op func(*git.Repo, ...string) error) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	if err := op(repo, req.Paths...); err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// HandleGitCommit commits the index. Author defaults to GIT_AUTHOR_NAME /
+// GIT_AUTHOR_EMAIL from the bridge config.
+// POST /api/v2/git/commit {"message": "...", "author_name": "", "author_email": ""}
+func (s *Server) HandleGitCommit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Message     string `json:"message"`
+		AuthorName  string `json:"author_name"`
+		AuthorEmail string `json:"author_email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.AuthorName == "" {
+		req.AuthorName = s.configSvc.Get("GIT_AUTHOR_NAME")
+	}
+	if req.AuthorEmail == "" {
+		req.AuthorEmail = s.configSvc.Get("GIT_AUTHOR_EMAIL")
+	}
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	commit, err := repo.Commit(req.Message, req.AuthorName, req.AuthorEmail)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == git.ErrEmptyMessage {
+			status = http.StatusBadRequest
+		}
+		writeServiceError(w, status, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(commit)
+}
+
+// HandleGitDiscard discards work tree changes to a path.
+// Without confirm=true it only returns the changes that would be lost.
+// POST /api/v2/git/discard?path=...&confirm=true
+func (s *Server) HandleGitDiscard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path parameter is required")
+		return
+	}
+
+	repo, ok := s.openRepo(w, r)
+	if !ok {
+		return
+	}
+
+	if r.URL.Query().Get("confirm") != "true" {
+		diff, _ := repo.Diff(path, false)
+		WriteError(w, CodeConfirmationRequired, http.StatusConflict, map[string]interface{}{
+			"path": path,
+			"diff": diff,
+		})
+		return
+	}
+
+	if err := repo.Discard(path); err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"path":    path,
+	})
+}
+
+// HandleGitClone clones a repository in the background and optionally
+// registers it as a workspace. Returns a job to poll via /jobs/{id}.
+// POST /api/v2/git/clone {"url": "...", "path": "/abs/dest", "name": "", "add_workspace": true}
+func (s *Server) HandleGitClone(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		URL          string `json:"url"`
+		Path         string `json:"path"`
+		Name         string `json:"name"`
+		AddWorkspace bool   `json:"add_workspace"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" || req.Path == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "url and path are required")
+		return
+	}
+
+	dest := s.resolveWorkDir(req.Path)
+	job := s.jobMgr.Submit("git.clone", func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		job.SetProgress(0, "cloning "+req.URL)
+		if err := git.Clone(ctx, req.URL, dest); err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{"path": dest}
+		if req.AddWorkspace {
+			name := req.Name
+			if name == "" {
+				name = filepath.Base(dest)
+			}
+			ws, err := s.workspaceSvc.Add(name, dest)
+			if err != nil {
+				return nil, err
+			}
+			result["workspace"] = ws
+		}
+		return result, nil
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,168 @@
+// Package api provides HTTP handlers for bridge health reporting
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"echohelix/bridge/internal/migrate"
+)
+
+// Version is the bridge version, overridden at build time with
+// -ldflags "-X echohelix/bridge/internal/api.Version=..."
+var Version = "dev"
+
+// Component health states; the overall status is the worst of them
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthError    = "error"
+)
+
+const (
+	diskWarnBytes  = 500 << 20
+	diskErrorBytes = 50 << 20
+)
+
+// ComponentHealth is the status of one bridge subsystem
+type ComponentHealth struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// HandleHealth reports the overall status only. It needs no token, so it
+// leaves out paths, the kernel and the relay, and does not write to the
+// data directory.
+// GET /api/v2/health
+func (s *Server) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	overall, _ := s.healthReport(false)
+	if overall == healthError {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": overall,
+	})
+}
+
+// HandleHealthDetails reports per-component status, probing that the
+// storage directories accept new files
+// GET /api/v2/health/details
+func (s *Server) HandleHealthDetails(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	overall, components := s.healthReport(true)
+	if overall == healthError {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         overall,
+		"version":        Version,
+		"data_schema":    migrate.CurrentVersion(),
+		"encrypted":      s.dataVault.Enabled(),
+		"uptime_seconds": int64(time.Since(s.startedAt).Seconds()),
+		"started_at":     s.startedAt,
+		"components":     components,
+	})
+}
+
+// healthReport checks each component and returns the worst status.
+// Without probe storage is only checked to exist.
+func (s *Server) healthReport(probe bool) (string, map[string]ComponentHealth) {
+	storage := checkExists
+	if probe {
+		storage = checkWritable
+	}
+	components := map[string]ComponentHealth{
+		"auth_storage":    storage(s.echoDir),
+		"session_storage": storage(filepath.Join(s.echoDir, "sessions")),
+		"kernel":          s.checkKernel(),
+		"disk":            checkDisk(s.echoDir),
+	}
+	if s.relayClient != nil {
+		components["relay"] = s.checkRelay()
+	}
+
+	overall := healthOK
+	for _, c := range components {
+		if c.Status == healthError {
+			overall = healthError
+		} else if c.Status == healthDegraded && overall == healthOK {
+			overall = healthDegraded
+		}
+	}
+	return overall, components
+}
+
+// checkExists verifies dir exists without touching it
+func checkExists(dir string) ComponentHealth {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return ComponentHealth{Status: healthError, Message: err.Error()}
+	}
+	if !info.IsDir() {
+		return ComponentHealth{Status: healthError, Message: "not a directory"}
+	}
+	return ComponentHealth{Status: healthOK, Details: map[string]string{"path": dir}}
+}
+
+// checkWritable verifies dir exists and accepts new files
+func checkWritable(dir string) ComponentHealth {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ComponentHealth{Status: healthError, Message: err.Error()}
+	}
+	f, err := os.CreateTemp(dir, ".health-*")
+	if err != nil {
+		return ComponentHealth{Status: healthError, Message: "not writable: " + err.Error()}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return ComponentHealth{Status: healthOK, Details: map[string]string{"path": dir}}
+}
+
+// checkDisk reports free space on the data directory's volume
+func checkDisk(dir string) ComponentHealth {
+	free, err := diskFree(dir)
+	if err != nil {
+		return ComponentHealth{Status: healthDegraded, Message: "unable to read disk usage: " + err.Error()}
+	}
+
+	details := map[string]uint64{"free_bytes": free}
+	switch {
+	case free < diskErrorBytes:
+		return ComponentHealth{Status: healthError, Message: "data directory is almost out of space", Details: details}
+	case free < diskWarnBytes:
+		return ComponentHealth{Status: healthDegraded, Message: "low disk space", Details: details}
+	}
+	return ComponentHealth{Status: healthOK, Details: details}
+}
+
+// checkKernel reports the kernel process and whether its port accepts
+// connections. No running kernel is not an error.
+func (s *Server) checkKernel() ComponentHealth {
+	if s.processManager == nil {
+		return ComponentHealth{Status: healthError, Message: "ProcessManager not initialized"}
+	}
+
+	st := s.processManager.Status()
+	if !st.Running {
+		return ComponentHealth{Status: healthOK, Message: "no kernel running", Details: st}
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(st.Port))
+	conn, err := net.DialTimeout("tcp", addr, 500*time.Millisecond)
+	if err != nil {
+		// 刚启动的内核可能尚未监听端口
+		return ComponentHealth{Status: healthDegraded, Message: fmt.Sprintf("%s kernel not ready", st.Kernel), Details: st}
+	}
+	conn.Close()
+	return ComponentHealth{Status: healthOK, Message: fmt.Sprintf("%s kernel ready", st.Kernel), Details: st}
+}
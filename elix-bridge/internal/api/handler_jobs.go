@@ -0,0 +1,90 @@
+// Package api provides HTTP handlers for background jobs
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// HandleJobList returns all jobs
+// GET /api/v2/jobs
+func (s *Server) HandleJobList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	list := s.jobMgr.List()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":  list,
+		"count": len(list),
+	})
+}
+
+// HandleJobGet returns a job's status, progress, and result
+// GET /api/v2/jobs/{id}
+func (s *Server) HandleJobGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	job, ok := s.jobMgr.Get(mux.Vars(r)["id"])
+	if !ok {
+		WriteError(w, CodeJobNotFound, http.StatusNotFound, "job not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+// HandleJobCancel cancels a queued or running job
+// POST /api/v2/jobs/{id}/cancel
+func (s *Server) HandleJobCancel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.jobMgr.Cancel(mux.Vars(r)["id"]) {
+		WriteError(w, CodeJobNotFound, http.StatusNotFound, "job not found or already finished")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleJobEvents streams job events over WebSocket
+// GET /api/v2/jobs/events
+func (s *Server) HandleJobEvents(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrade(w, r)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to upgrade websocket")
+		return
+	}
+	defer conn.Close()
+
+	events, cancel := s.jobMgr.Subscribe()
+	defer cancel()
+
+	// 读取循环用于感知客户端断开
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"echohelix/bridge/internal/installer"
+	"echohelix/bridge/internal/jobs"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// installLogLines is the output kept per kernel install for polling clients
+const installLogLines = 200
+
+// kernelInstall tracks the latest install of one kernel
+type kernelInstall struct {
+	jobID string
+	lines []string
+}
+
+// installOptions builds the installer options for kernel from the config.
+// KERNEL_CORES_REPO provides all kernels at once; KERNEL_<NAME>_REPO and
+// KERNEL_<NAME>_REF override the source of one kernel.
+func (s *Server) installOptions(kernel, ref string, update bool) installer.Options {
+	name := strings.ToUpper(kernel)
+	if ref == "" {
+		ref = s.configSvc.Get("KERNEL_" + name + "_REF")
+	}
+	return installer.Options{
+		CoresDir:  filepath.Join(s.processManager.WorkDir, "cores"),
+		Repo:      s.configSvc.Get("KERNEL_" + name + "_REPO"),
+		CoresRepo: s.configSvc.Get("KERNEL_CORES_REPO"),
+		Ref:       ref,
+		Update:    update,
+	}
+}
+
+// appendInstallLine records one line of install output
+func (s *Server) appendInstallLine(kernel, line string) {
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+	inst := s.installs[kernel]
+	if inst == nil {
+		return
+	}
+	inst.lines = append(inst.lines, line)
+	if len(inst.lines) > installLogLines {
+		inst.lines = inst.lines[len(inst.lines)-installLogLines:]
+	}
+}
+
+// kernelOpFunc is the body of a kernel install, upgrade, or rollback job
+type kernelOpFunc func(ctx context.Context, progress func(i, total int, step installer.Step), output func(line string)) (interface{}, error)
+
+// startKernelOp runs op as a job of kind, one at a time per kernel: while
+// one is running its job is returned instead. Output lines are kept for
+// polling and published on the event bus as kernel.install.output.
+func (s *Server) startKernelOp(w http.ResponseWriter, kernel, kind string, op kernelOpFunc) {
+	// 运行中的内核不能重装，依赖目录正被使用
+	if st := s.processManager.Status(); st.Running && st.Kernel == kernel {
+		WriteError(w, CodeConflict, http.StatusConflict, "Stop the "+kernel+" kernel before changing its installation")
+		return
+	}
+
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+	if inst := s.installs[kernel]; inst != nil {
+		if job, ok := s.jobMgr.Get(inst.jobID); ok && (job.Status == jobs.StatusQueued || job.Status == jobs.StatusRunning) {
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(job)
+			return
+		}
+	}
+
+	dir := filepath.Join(s.processManager.WorkDir, "cores", kernel)
+	if s.kernelVersions.Get(kernel).Current == nil {
+		// 首次记录当前版本，之后的升级才能回滚到它
+		if v, err := installer.Detect(dir); err == nil {
+			s.kernelVersions.Installed(kernel, v)
+		}
+	}
+
+	s.installs[kernel] = &kernelInstall{}
+	job := s.jobMgr.Submit(kind, func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		output := func(line string) {
+			s.appendInstallLine(kernel, line)
+			s.eventBus.Publish("kernel.install.output", map[string]interface{}{
+				"job_id": job.ID,
+				"kernel": kernel,
+				"line":   line,
+			})
+		}
+		result, err := op(ctx, func(i, total int, step installer.Step) {
+			job.SetProgress(float64(i)/float64(total), fmt.Sprintf("%s (%d/%d)", step.Name, i+1, total))
+			output("$ " + strings.Join(step.Command, " "))
+		}, output)
+		if err != nil {
+			log.Warn().Err(err).Str("kernel", kernel).Str("kind", kind).Msg("Kernel operation failed")
+		}
+		// 无论成败都记录磁盘上的实际版本，回滚后也能反映真实状态
+		if v, detectErr := installer.Detect(dir); detectErr == nil {
+			s.kernelVersions.Installed(kernel, v)
+		}
+		return result, err
+	})
+	s.installs[kernel].jobID = job.ID
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// kernelFromRequest returns the {name} of a kernel route, writing an
+// error when it is not a kernel the bridge can manage
+func (s *Server) kernelFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return "", false
+	}
+	kernel := mux.Vars(r)["name"]
+	if !isInstallableKernel(kernel) {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, installer.ErrUnknownKernel.Error())
+		return "", false
+	}
+	return kernel, true
+}
+
+type kernelRefRequest struct {
+	Ref string `json:"ref"`
+}
+
+// decodeRefRequest reads an optional {"ref": ...} body
+func decodeRefRequest(w http.ResponseWriter, r *http.Request) (kernelRefRequest, bool) {
+	var req kernelRefRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+			return req, false
+		}
+	}
+	return req, true
+}
+
+// HandleKernelInstall fetches a kernel's sources and installs its
+// dependencies as a job. Output lines are published on the event bus as
+// kernel.install.output; an install already running is returned as is.
+// POST /api/v2/kernels/{name}/install?update=true
+func (s *Server) HandleKernelInstall(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	kernel, ok := s.kernelFromRequest(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeRefRequest(w, r)
+	if !ok {
+		return
+	}
+
+	opts := s.installOptions(kernel, req.Ref, r.URL.Query().Get("update") == "true")
+	if _, err := installer.Plan(kernel, opts); err != nil {
+		WriteError(w, CodeNotConfigured, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.startKernelOp(w, kernel, "kernel.install", func(ctx context.Context, progress func(int, int, installer.Step), output func(string)) (interface{}, error) {
+		var steps []string
+		err := installer.Install(ctx, kernel, opts, func(i, total int, step installer.Step) {
+			steps = append(steps, step.Name)
+			progress(i, total, step)
+		}, output)
+		result := map[string]interface{}{"kernel": kernel, "steps": steps}
+		if err == nil {
+			log.Info().Str("kernel", kernel).Int("steps", len(steps)).Msg("Kernel installed")
+			s.eventBus.Publish("kernel.installed", result)
+		}
+		return result, err
+	})
+}
+
+// HandleKernelVersion returns the installed version of a kernel and the
+// version it replaced. With check=true the remote is fetched and the
+// commits an upgrade would bring in are listed.
+// GET /api/v2/kernels/{name}/version?check=true&ref=
+func (s *Server) HandleKernelVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	kernel, ok := s.kernelFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	opts := s.installOptions(kernel, "", false)
+	current, err := installer.Detect(filepath.Join(opts.CoresDir, kernel))
+	if errors.Is(err, installer.ErrNotInstalled) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"kernel": kernel, "installed": false})
+		return
+	}
+	record := s.kernelVersions.Get(kernel)
+	resp := map[string]interface{}{
+		"kernel":    kernel,
+		"installed": true,
+		"current":   current,
+		"pinned":    current.Pinned(),
+		"previous":  record.Previous,
+	}
+	if record.Current != nil && record.Current.Commit == current.Commit {
+		current.InstalledAt = record.Current.InstalledAt
+		resp["current"] = current
+	}
+
+	if r.URL.Query().Get("check") == "true" {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+		defer cancel()
+		target, commits, err := installer.Pending(ctx, kernel, opts, r.URL.Query().Get("ref"))
+		if err != nil {
+			resp["update_error"] = err.Error()
+		} else {
+			resp["update"] = map[string]interface{}{
+				"target":    target,
+				"available": target != current.Commit,
+				"changelog": commits,
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleKernelUpgrade moves a kernel to a newer version as a job: the
+// current branch's upstream, or ref. The result includes the changelog;
+// a failed upgrade restores the version it started from.
+// POST /api/v2/kernels/{name}/upgrade
+func (s *Server) HandleKernelUpgrade(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	kernel, ok := s.kernelFromRequest(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeRefRequest(w, r)
+	if !ok {
+		return
+	}
+
+	opts := s.installOptions(kernel, "", false)
+	if _, err := installer.Detect(filepath.Join(opts.CoresDir, kernel)); err != nil {
+		WriteError(w, CodeNotFound, http.StatusNotFound, err.Error())
+		return
+	}
+	s.startKernelOp(w, kernel, "kernel.upgrade", func(ctx context.Context, progress func(int, int, installer.Step), output func(string)) (interface{}, error) {
+		result, err := installer.Upgrade(ctx, kernel, opts, req.Ref, progress, output)
+		if err == nil && !result.UpToDate {
+			log.Info().Str("kernel", kernel).Str("from", result.From.String()).Str("to", result.To.String()).Msg("Kernel upgraded")
+			s.eventBus.Publish("kernel.upgraded", result)
+		}
+		return result, err
+	})
+}
+
+// HandleKernelRollback returns a kernel to the version it had before the
+// last install or upgrade
+// POST /api/v2/kernels/{name}/rollback
+func (s *Server) HandleKernelRollback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	kernel, ok := s.kernelFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	previous := s.kernelVersions.Get(kernel).Previous
+	if previous == nil {
+		WriteError(w, CodeNotFound, http.StatusNotFound, installer.ErrNoPrevious.Error())
+		return
+	}
+	opts := s.installOptions(kernel, "", false)
+	s.startKernelOp(w, kernel, "kernel.rollback", func(ctx context.Context, progress func(int, int, installer.Step), output func(string)) (interface{}, error) {
+		result, err := installer.Rollback(ctx, kernel, opts, *previous, progress, output)
+		if err == nil {
+			log.Info().Str("kernel", kernel).Str("to", result.To.String()).Msg("Kernel rolled back")
+			s.eventBus.Publish("kernel.rolled_back", result)
+		}
+		return result, err
+	})
+}
+
+// HandleKernelInstallStatus returns the latest install, upgrade, or
+// rollback job of a kernel with the most recent lines of its output
+// GET /api/v2/kernels/{name}/install
+func (s *Server) HandleKernelInstallStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	kernel := mux.Vars(r)["name"]
+
+	s.installMu.Lock()
+	inst := s.installs[kernel]
+	var jobID string
+	var lines []string
+	if inst != nil {
+		jobID = inst.jobID
+		lines = append([]string{}, inst.lines...)
+	}
+	s.installMu.Unlock()
+
+	job, ok := s.jobMgr.Get(jobID)
+	if !ok {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "No install or upgrade of "+kernel+" since the bridge started")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job":    job,
+		"output": lines,
+	})
+}
+
+func isInstallableKernel(name string) bool {
+	for _, k := range installer.Kernels {
+		if k == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleKernelCapabilities describes what a kernel supports, as declared
+// by its adapter, so clients can show only the features that work
+// GET /api/v2/kernels/{name}/capabilities
+func (s *Server) HandleKernelCapabilities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	name := mux.Vars(r)["name"]
+	adapter, ok := s.kernelAdapters[name]
+	if !ok {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "No adapter for kernel "+name)
+		return
+	}
+	running := false
+	if s.processManager != nil {
+		st := s.processManager.Status()
+		running = st.Running && st.Kernel == name
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"kernel":       name,
+		"running":      running,
+		"capabilities": adapter.Capabilities(),
+	})
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"echohelix/bridge/internal/fs"
+	"echohelix/bridge/internal/lsp"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// lspTimeout bounds a single LSP request, including starting the server
+const lspTimeout = 30 * time.Second
+
+// setupLSP configures the language servers. LSP_<LANGUAGE>_COMMAND (e.g.
+// LSP_GO_COMMAND="gopls -remote=auto") overrides a server's command line.
+func (s *Server) setupLSP() {
+	languages := make([]lsp.Language, 0, len(lsp.DefaultLanguages))
+	for _, lang := range lsp.DefaultLanguages {
+		if v := s.configSvc.Get("LSP_" + strings.ToUpper(lang.Name) + "_COMMAND"); v != "" {
+			lang.Command = strings.Fields(v)
+		}
+		languages = append(languages, lang)
+	}
+	s.lspMgr = lsp.NewManager(languages)
+}
+
+// lspRequest is a position in a workspace file
+type lspRequest struct {
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	Character int    `json:"character"`
+}
+
+func parseLSPRequest(r *http.Request) (lspRequest, error) {
+	q := r.URL.Query()
+	req := lspRequest{Path: q.Get("path")}
+	if req.Path == "" {
+		return req, errors.New("path parameter is required")
+	}
+	var err error
+	if v := q.Get("line"); v != "" {
+		if req.Line, err = strconv.Atoi(v); err != nil {
+			return req, errors.New("line must be an integer")
+		}
+	}
+	if v := q.Get("character"); v != "" {
+		if req.Character, err = strconv.Atoi(v); err != nil {
+			return req, errors.New("character must be an integer")
+		}
+	}
+	return req, nil
+}
+
+// lspRoot returns the workspace root after checking path stays inside it
+func (s *Server) lspRoot(path string) (string, error) {
+	if _, err := s.workspacePath(fs.OpRead, path); err != nil {
+		return "", err
+	}
+	return s.processManager.WorkDir, nil
+}
+
+// writeLSPError maps LSP failures to API errors
+func writeLSPError(w http.ResponseWriter, err error) {
+	var policyErr *fs.PolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		writeFSError(w, err)
+	case errors.Is(err, lsp.ErrUnsupported):
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err.Error())
+	case errors.Is(err, exec.ErrNotFound):
+		WriteError(w, CodeUnavailable, http.StatusServiceUnavailable, "language server is not installed: "+err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		WriteError(w, CodeUpstreamError, http.StatusGatewayTimeout, "language server timed out")
+	default:
+		WriteError(w, CodeUpstreamError, http.StatusBadGateway, err.Error())
+	}
+}
+
+// HandleLSPHover returns hover documentation at a position (zero-based)
+// GET /api/v2/lsp/hover?path=main.go&line=10&character=4
+func (s *Server) HandleLSPHover(w http.ResponseWriter, r *http.Request) {
+	s.handleLSP(w, r, func(ctx context.Context, root string, req lspRequest) (interface{}, error) {
+		hover, err := s.lspMgr.Hover(ctx, root, req.Path, lsp.Position{Line: req.Line, Character: req.Character})
+		return map[string]interface{}{"hover": hover}, err
+	})
+}
+
+// HandleLSPDefinition returns where the symbol at a position is defined
+// GET /api/v2/lsp/definition?path=main.go&line=10&character=4
+func (s *Server) HandleLSPDefinition(w http.ResponseWriter, r *http.Request) {
+	s.handleLSP(w, r, func(ctx context.Context, root string, req lspRequest) (interface{}, error) {
+		locations, err := s.lspMgr.Definition(ctx, root, req.Path, lsp.Position{Line: req.Line, Character: req.Character})
+		return map[string]interface{}{"locations": locations}, err
+	})
+}
+
+// HandleLSPDiagnostics returns the errors and warnings for a file
+// GET /api/v2/lsp/diagnostics?path=main.go&wait_ms=3000
+func (s *Server) HandleLSPDiagnostics(w http.ResponseWriter, r *http.Request) {
+	wait := 3 * time.Second
+	if v, err := strconv.Atoi(r.URL.Query().Get("wait_ms")); err == nil && v >= 0 {
+		wait = time.Duration(v) * time.Millisecond
+	}
+	s.handleLSP(w, r, func(ctx context.Context, root string, req lspRequest) (interface{}, error) {
+		diags, err := s.lspMgr.Diagnostics(ctx, root, req.Path, wait)
+		return map[string]interface{}{"path": req.Path, "diagnostics": diags}, err
+	})
+}
+
+func (s *Server) handleLSP(w http.ResponseWriter, r *http.Request, fn func(context.Context, string, lspRequest) (interface{}, error)) {
+	req, err := parseLSPRequest(r)
+	if err != nil {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, err.Error())
+		return
+	}
+	root, err := s.lspRoot(req.Path)
+	if err != nil {
+		var policyErr *fs.PolicyError
+		if errors.As(err, &policyErr) {
+			writeFSError(w, err)
+			return
+		}
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), lspTimeout)
+	defer cancel()
+	result, err := fn(ctx, root, req)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("path", req.Path).Msg("LSP request failed")
+		writeLSPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// HandleLSPServers lists the running language servers
+// GET /api/v2/lsp/servers
+func (s *Server) HandleLSPServers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"servers": s.lspMgr.Servers(),
+	})
+}
+
+// lspMessage is exchanged over the LSP WebSocket.
+// Client -> Bridge: {"id":1,"method":"hover|definition|diagnostics","params":{"path":"main.go","line":3,"character":5}}
+// Bridge -> Client: {"id":1,"result":{...}} / {"id":1,"error":"..."} / {"event":"diagnostics","result":{"path":...,"diagnostics":[...]}}
+type lspMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params lspRequest      `json:"params"`
+	Event  string          `json:"event,omitempty"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// HandleLSPSocket serves LSP requests over WebSocket and pushes
+// diagnostics for workspace files as language servers report them
+// GET /api/v2/lsp/ws
+func (s *Server) HandleLSPSocket(w http.ResponseWriter, r *http.Request) {
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
+	root := s.processManager.WorkDir
+
+	conn, err := s.upgrade(w, r)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to upgrade websocket")
+		return
+	}
+	defer conn.Close()
+
+	out := make(chan lspMessage, 16)
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	diags, unsubscribe := s.lspMgr.Subscribe(root)
+	defer unsubscribe()
+
+	// 单一写协程，避免并发写 WebSocket
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-out:
+				if err := conn.WriteJSON(msg); err != nil {
+					cancel()
+					return
+				}
+			case event := <-diags:
+				if err := conn.WriteJSON(lspMessage{Event: "diagnostics", Result: event}); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var msg lspMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Ctx(r.Context()).Warn().Err(err).Msg("LSP socket read error")
+			}
+			return
+		}
+		go func(msg lspMessage) {
+			reply := lspMessage{ID: msg.ID}
+			result, err := s.lspSocketCall(ctx, root, msg)
+			if err != nil {
+				reply.Error = err.Error()
+			} else {
+				reply.Result = result
+			}
+			select {
+			case out <- reply:
+			case <-ctx.Done():
+			}
+		}(msg)
+	}
+}
+
+func (s *Server) lspSocketCall(ctx context.Context, root string, msg lspMessage) (interface{}, error) {
+	if _, err := s.workspacePath(fs.OpRead, msg.Params.Path); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, lspTimeout)
+	defer cancel()
+
+	pos := lsp.Position{Line: msg.Params.Line, Character: msg.Params.Character}
+	switch msg.Method {
+	case "hover":
+		return s.lspMgr.Hover(ctx, root, msg.Params.Path, pos)
+	case "definition":
+		return s.lspMgr.Definition(ctx, root, msg.Params.Path, pos)
+	case "diagnostics":
+		return s.lspMgr.Diagnostics(ctx, root, msg.Params.Path, 3*time.Second)
+	}
+	return nil, errors.New("unknown method: " + msg.Method)
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"echohelix/bridge/internal/danger"
+	"echohelix/bridge/internal/fs"
+	"echohelix/bridge/internal/fslock"
+	"echohelix/bridge/internal/mcp"
+	"echohelix/bridge/internal/notebook"
+	"echohelix/bridge/internal/recentfiles"
+
+	"github.com/rs/zerolog/log"
+)
+
+// notebookMaxSize bounds the notebooks the bridge parses; larger ones are
+// read and written whole through the fs endpoints
+const notebookMaxSize = 64 << 20
+
+// readNotebook reads and parses a local notebook, returning it with the
+// SHA-256 of the file
+func readNotebook(fullPath string) (*notebook.Notebook, string, error) {
+	if !strings.EqualFold(filepath.Ext(fullPath), notebook.Ext) {
+		return nil, "", &notebook.Error{Code: notebook.CodeInvalid, Message: "not a " + notebook.Ext + " file"}
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.Size() > notebookMaxSize {
+		return nil, "", &notebook.Error{Code: notebook.CodeInvalid, Message: fmt.Sprintf("notebook is larger than %d MB", notebookMaxSize>>20)}
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, "", err
+	}
+	nb, err := notebook.Parse(data)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return nb, hex.EncodeToString(sum[:]), nil
+}
+
+// writeNotebookError maps notebook failures to API errors
+func writeNotebookError(w http.ResponseWriter, err error) {
+	var nbErr *notebook.Error
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		WriteError(w, CodeFileNotFound, http.StatusNotFound, err)
+	case errors.As(err, &nbErr) && nbErr.Code == notebook.CodeCellNotFound:
+		writeServiceError(w, http.StatusNotFound, err)
+	case errors.As(err, &nbErr):
+		writeServiceError(w, http.StatusBadRequest, err)
+	default:
+		writeFSError(w, err)
+	}
+}
+
+// HandleNotebook returns a notebook as cells, each output reduced to its
+// text and MIME types so images and widget state are not sent. The ETag
+// and sha256 are those of the file, as for fs/file; send sha256 back as
+// base_sha256 when editing.
+// GET /api/v2/fs/notebook?path=...[&session_id=]
+func (s *Server) HandleNotebook(w http.ResponseWriter, r *http.Request) {
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
+	relPath := r.URL.Query().Get("path")
+	if relPath == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path parameter is required")
+		return
+	}
+	fullPath, err := s.resolveFSPath(fs.OpRead, relPath)
+	if err != nil {
+		writeFSError(w, err)
+		return
+	}
+
+	nb, sum, err := readNotebook(fullPath)
+	if err != nil {
+		writeNotebookError(w, err)
+		return
+	}
+	s.trackFile(r, recentfiles.OpRead, relPath)
+	if notModified(w, r, `"`+sum+`"`) {
+		return
+	}
+
+	resp := map[string]interface{}{
+		"path":     relPath,
+		"nbformat": nb.Version(),
+		"language": nb.Language(),
+		"cells":    nb.Cells(),
+		"sha256":   sum,
+	}
+	if lock := s.lockConflict(s.trackedPath(relPath), lockOwner(r), fslock.KindDevice); lock != nil && lock.Mode == fslock.ModeWrite {
+		resp["lock"] = lock
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleNotebookEdit applies cell edits to a notebook and writes it,
+// keeping every field the edits don't touch. With base_sha256 the edits
+// apply only to that version of the file; a notebook changed since
+// answers 409 with the current sha256. Locks are honoured as by fs/write.
+// POST /api/v2/fs/notebook[?session_id=]
+func (s *Server) HandleNotebookEdit(w http.ResponseWriter, r *http.Request) {
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
+
+	var req struct {
+		Path  string          `json:"path"`
+		Edits []notebook.Edit `json:"edits"`
+		// BaseSHA256 is the sha256 of the notebook the edits were made on
+		BaseSHA256   string `json:"base_sha256,omitempty"`
+		ConfirmToken string `json:"confirm_token,omitempty"`
+		Force        bool   `json:"force,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Path == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path parameter is required")
+		return
+	}
+	if len(req.Edits) == 0 {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "edits are required")
+		return
+	}
+
+	fullPath, err := s.resolveFSPath(fs.OpWrite, req.Path)
+	if err != nil {
+		writeFSError(w, err)
+		return
+	}
+	nb, sum, err := readNotebook(fullPath)
+	if err != nil {
+		writeNotebookError(w, err)
+		return
+	}
+	if req.BaseSHA256 != "" && req.BaseSHA256 != sum {
+		WriteError(w, CodeConflict, http.StatusConflict, map[string]string{"sha256": sum})
+		return
+	}
+	if lock := s.lockConflict(s.trackedPath(req.Path), lockOwner(r), fslock.KindDevice); lock != nil && !req.Force {
+		writeLocked(w, lock)
+		return
+	}
+	if err := nb.Apply(req.Edits); err != nil {
+		writeNotebookError(w, err)
+		return
+	}
+	data, err := nb.Marshal()
+	if err != nil {
+		WriteError(w, CodeInternal, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := s.checkFSWrite(r.Context(), "api", "", req.Path, string(data)); err != nil {
+		writePluginError(w, err)
+		return
+	}
+	op := danger.Operation{Action: danger.ActionFSWrite, Target: fullPath}
+	op.Class = s.classify(op)
+	if !s.confirmed(w, r, op, req.ConfirmToken) {
+		return
+	}
+
+	s.checkpointBeforeWrite(s.processManager.WorkDir, req.Path)
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		WriteError(w, CodeWriteFailed, http.StatusInternalServerError, "failed to write file: "+err.Error())
+		log.Ctx(r.Context()).Error().Err(err).Str("path", fullPath).Msg("Failed to write notebook")
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("path", req.Path).Int("edits", len(req.Edits)).Msg("Notebook edited")
+	s.trackFile(r, recentfiles.OpWrite, req.Path)
+	s.eventBus.Publish("fs.written", map[string]interface{}{
+		"path": req.Path,
+		"size": len(data),
+	})
+
+	newSum := sha256.Sum256(data)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"path":    req.Path,
+		"cells":   nb.Cells(),
+		"sha256":  hex.EncodeToString(newSum[:]),
+	})
+}
+
+func (s *Server) mcpNotebookRead(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	full, err := s.workspacePath(fs.OpRead, args.Path)
+	if err != nil {
+		return nil, err
+	}
+	nb, _, err := readNotebook(full)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"language": nb.Language(),
+		"cells":    nb.Cells(),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.filterOutbound(ctx, s.processManager.WorkDir, args.Path, string(data))
+	if err != nil {
+		return nil, err
+	}
+	s.recentFiles.Record(recentfiles.Touch{Workspace: s.trackedRoot(), Path: s.trackedPath(args.Path), Source: recentfiles.SourceMCP, Op: recentfiles.OpRead})
+	return mcp.TextResult(content), nil
+}
+
+func (s *Server) mcpNotebookEdit(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Path  string          `json:"path"`
+		Edits []notebook.Edit `json:"edits"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	full, err := s.workspacePath(fs.OpWrite, args.Path)
+	if err != nil {
+		return nil, err
+	}
+	nb, _, err := readNotebook(full)
+	if err != nil {
+		return nil, err
+	}
+	if err := nb.Apply(args.Edits); err != nil {
+		return nil, err
+	}
+	data, err := nb.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return s.mcpWrite(ctx, args.Path, full, string(data))
+}
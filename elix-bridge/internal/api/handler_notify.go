@@ -0,0 +1,121 @@
+// Package api provides HTTP handlers for push notification settings
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/notify"
+)
+
+// pushSender resolves the configured push relay, or nil when unset
+func (s *Server) pushSender() notify.Sender {
+	url := s.configSvc.Get("PUSH_RELAY_URL")
+	if url == "" {
+		return nil
+	}
+	return notify.NewRelaySender(url, s.configSvc.Get("PUSH_RELAY_KEY"))
+}
+
+// HandleNotifyRegister registers the calling device's push token
+// PUT /api/v2/notifications/device {"push_token": "...", "push_platform": "fcm|apns"}
+func (s *Server) HandleNotifyRegister(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _ := auth.TokenFromContext(r.Context())
+
+	var req struct {
+		PushToken    string `json:"push_token"`
+		PushPlatform string `json:"push_platform"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.PushPlatform != "fcm" && req.PushPlatform != "apns" && req.PushToken != "" {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "push_platform must be fcm or apns")
+		return
+	}
+
+	if _, err := s.authService.SetPushToken(token.DeviceID, req.PushPlatform, req.PushToken); err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleNotifyPrefsGet returns the calling device's notification preferences
+// GET /api/v2/notifications/prefs
+func (s *Server) HandleNotifyPrefsGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _ := auth.TokenFromContext(r.Context())
+
+	prefs := make(map[string]bool)
+	for _, category := range notify.Categories {
+		enabled, ok := token.NotifyPrefs[category]
+		prefs[category] = !ok || enabled
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"prefs":      prefs,
+		"registered": token.PushToken != "",
+	})
+}
+
+// HandleNotifyPrefsSet updates the calling device's notification preferences
+// PUT /api/v2/notifications/prefs {"task_finished": true, "kernel_crashed": false}
+func (s *Server) HandleNotifyPrefsSet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _ := auth.TokenFromContext(r.Context())
+
+	var prefs map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	updated, err := s.authService.SetNotifyPrefs(token.DeviceID, prefs)
+	if err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"prefs": updated.NotifyPrefs,
+	})
+}
+
+// HandleNotifySend sends a notification to all subscribed devices. Kernels
+// use this to request approval; clients can use it to test delivery.
+// POST /api/v2/notifications/send {"category": "approval_needed", "title": "...", "body": "..."}
+func (s *Server) HandleNotifySend(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var n notify.Notification
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil || n.Title == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "title is required")
+		return
+	}
+	if n.Category == "" {
+		n.Category = notify.CategoryApprovalNeeded
+	}
+
+	if s.pushSender() == nil {
+		WriteError(w, CodeNotConfigured, http.StatusServiceUnavailable, "PUSH_RELAY_URL is not configured")
+		return
+	}
+
+	targets := s.notifySvc.Targets(n.Category)
+	s.notifySvc.Notify(n)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"targets": len(targets),
+	})
+}
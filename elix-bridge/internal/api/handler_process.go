@@ -8,18 +8,18 @@ import (
 )
 
 func (s *Server) HandleProcessStop(w http.ResponseWriter, r *http.Request) {
-	log.Info().Msg("Received request to STOP process")
+	log.Ctx(r.Context()).Info().Msg("Received request to STOP process")
 
 	if s.processManager == nil {
-		log.Error().Msg("ProcessManager is nil")
-		http.Error(w, "ProcessManager not initialized", http.StatusInternalServerError)
+		log.Ctx(r.Context()).Error().Msg("ProcessManager is nil")
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
 		return
 	}
 
-	err := s.processManager.Stop()
+	err := s.stopKernel()
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to stop process")
-		http.Error(w, "Failed to stop process: "+err.Error(), http.StatusInternalServerError)
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to stop process")
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Failed to stop process: "+err.Error())
 		return
 	}
 
@@ -28,6 +28,18 @@ func (s *Server) HandleProcessStop(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"stopped", "message":"Process terminated successfully"}`))
 }
 
+// HandleProcessStats returns the kernel status with memory usage and uptime
+// GET /api/v2/process/stats
+func (s *Server) HandleProcessStats(w http.ResponseWriter, r *http.Request) {
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.processManager.Stats())
+}
+
 type StartRequest struct {
 	Kernel string `json:"kernel"`
 	Port   int    `json:"port"`
@@ -36,22 +48,37 @@ type StartRequest struct {
 func (s *Server) HandleProcessStart(w http.ResponseWriter, r *http.Request) {
 	var req StartRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
 		return
 	}
 
-	if req.Port == 0 {
-		req.Port = 41242 // Default port
+	log.Ctx(r.Context()).Info().Str("kernel", req.Kernel).Int("port", req.Port).Msg("Received request to START process")
+
+	if s.processManager == nil {
+		WriteError(w, CodeNotInitialized, http.StatusInternalServerError, nil)
+		return
 	}
-	if req.Kernel == "" {
-		req.Kernel = "gemini"
+
+	err := s.startKernel(req.Kernel, req.Port)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to start process")
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "Failed to start process: "+err.Error())
+		return
 	}
 
-	log.Info().Str("kernel", req.Kernel).Int("port", req.Port).Msg("Received request to START process")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"started", "message":"Process started successfully"}`))
+}
 
-	if s.processManager == nil {
-		http.Error(w, "ProcessManager not initialized", http.StatusInternalServerError)
-		return
+// startKernel replaces the running kernel and announces it on the event
+// bus. Shared by the API and the dashboard.
+func (s *Server) startKernel(kernel string, port int) error {
+	if port == 0 {
+		port = 41242 // Default port
+	}
+	if kernel == "" {
+		kernel = s.defaults(s.resolveWorkDir("")).Kernel
 	}
 
 	// Stop existing first? Or Manager handles it?
@@ -60,14 +87,19 @@ func (s *Server) HandleProcessStart(w http.ResponseWriter, r *http.Request) {
 	// We'll call Stop first just in case?
 	s.processManager.Stop()
 
-	err := s.processManager.Start(req.Kernel, req.Port)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to start process")
-		http.Error(w, "Failed to start process: "+err.Error(), http.StatusInternalServerError)
-		return
+	if err := s.processManager.Start(kernel, port); err != nil {
+		return err
 	}
+	s.eventBus.Publish("process.started", StartRequest{Kernel: kernel, Port: port})
+	s.telemetry.Kernel(kernel)
+	return nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"started", "message":"Process started successfully"}`))
+// stopKernel stops the running kernel and announces it on the event bus
+func (s *Server) stopKernel() error {
+	if err := s.processManager.Stop(); err != nil {
+		return err
+	}
+	s.eventBus.Publish("process.stopped", nil)
+	return nil
 }
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"echohelix/bridge/internal/prompts"
+
+	"github.com/gorilla/mux"
+)
+
+// writePromptError maps prompt store failures to API errors
+func writePromptError(w http.ResponseWriter, err error) {
+	var promptErr *prompts.PromptError
+	status := http.StatusInternalServerError
+	if errors.As(err, &promptErr) {
+		status = http.StatusBadRequest
+		if promptErr == prompts.ErrNotFound {
+			status = http.StatusNotFound
+		}
+	}
+	writeServiceError(w, status, err)
+}
+
+// HandlePromptList returns the user templates and those of the workspace
+// (the active one unless ?workspace= is given)
+// GET /api/v2/prompts?workspace=...
+func (s *Server) HandlePromptList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	list, err := s.promptStore.List(s.resolveWorkDir(r.URL.Query().Get("workspace")))
+	if err != nil {
+		writePromptError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"prompts": list,
+	})
+}
+
+// HandlePromptCreate saves a new template. Workspace templates are stored
+// in <workspace>/.echohelix/prompts.json.
+// POST /api/v2/prompts
+func (s *Server) HandlePromptCreate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Name        string        `json:"name"`
+		Description string        `json:"description"`
+		Content     string        `json:"content"`
+		Scope       prompts.Scope `json:"scope"`
+		Workspace   string        `json:"workspace"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	t := prompts.Template{
+		Name:        req.Name,
+		Description: req.Description,
+		Content:     req.Content,
+		Scope:       req.Scope,
+	}
+	if req.Scope == prompts.ScopeWorkspace {
+		t.Workspace = s.resolveWorkDir(req.Workspace)
+	}
+
+	t, err := s.promptStore.Create(t)
+	if err != nil {
+		writePromptError(w, err)
+		return
+	}
+	s.eventBus.Publish("prompt.created", t)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+// HandlePromptGet returns a template
+// GET /api/v2/prompts/{id}?workspace=...
+func (s *Server) HandlePromptGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	t, err := s.promptStore.Get(mux.Vars(r)["id"], s.resolveWorkDir(r.URL.Query().Get("workspace")))
+	if err != nil {
+		writePromptError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// HandlePromptUpdate changes the name, description, or content of a
+// template; omitted fields are kept
+// PUT /api/v2/prompts/{id}?workspace=...
+func (s *Server) HandlePromptUpdate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var patch prompts.Patch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	t, err := s.promptStore.Update(mux.Vars(r)["id"], s.resolveWorkDir(r.URL.Query().Get("workspace")), patch)
+	if err != nil {
+		writePromptError(w, err)
+		return
+	}
+	s.eventBus.Publish("prompt.updated", t)
+
+	json.NewEncoder(w).Encode(t)
+}
+
+// HandlePromptDelete deletes a template
+// DELETE /api/v2/prompts/{id}?workspace=...
+func (s *Server) HandlePromptDelete(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := s.promptStore.Delete(id, s.resolveWorkDir(r.URL.Query().Get("workspace"))); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writePromptError(w, err)
+		return
+	}
+	s.eventBus.Publish("prompt.deleted", map[string]string{"id": id})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePromptExpand fills in a template's variables without sending it,
+// for previewing in the app
+// POST /api/v2/prompts/{id}/expand?workspace=...
+func (s *Server) HandlePromptExpand(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Variables map[string]string `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	content, err := s.expandPrompt(mux.Vars(r)["id"], s.resolveWorkDir(r.URL.Query().Get("workspace")), req.Variables)
+	if err != nil {
+		writePromptError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"content": content,
+	})
+}
+
+// expandPrompt loads a template and substitutes vars. The workspace path
+// is always available as {{workspace}}.
+func (s *Server) expandPrompt(id, workspace string, vars map[string]string) (string, error) {
+	t, err := s.promptStore.Get(id, workspace)
+	if err != nil {
+		return "", err
+	}
+
+	merged := map[string]string{"workspace": workspace}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return prompts.Expand(t.Content, merged)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"echohelix/bridge/internal/providers"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HandleProviderList returns the known providers and whether each is
+// configured
+// GET /api/v2/providers
+func (s *Server) HandleProviderList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"providers": s.providerRegistry.Providers(),
+	})
+}
+
+// HandleModelList returns a provider's models from the registry plus
+// those the provider reports live. Without ?provider= every configured
+// provider is listed.
+// GET /api/v2/models?provider=gemini&refresh=true
+func (s *Server) HandleModelList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	refresh := r.URL.Query().Get("refresh") == "true"
+
+	ids := []string{r.URL.Query().Get("provider")}
+	if ids[0] == "" {
+		ids = ids[:0]
+		for _, p := range s.providerRegistry.Providers() {
+			if p.Configured {
+				ids = append(ids, p.ID)
+			}
+		}
+	}
+
+	models := []providers.Model{}
+	liveErrors := map[string]string{}
+	for _, id := range ids {
+		p, err := s.providerRegistry.Models(id, refresh)
+		if errors.Is(err, providers.ErrUnknownProvider) {
+			WriteError(w, CodeNotFound, http.StatusNotFound, err.Error())
+			return
+		}
+		models = append(models, p.Models...)
+		if p.LiveError != "" {
+			liveErrors[id] = p.LiveError
+		}
+	}
+
+	resp := map[string]interface{}{"models": models}
+	if len(liveErrors) > 0 {
+		resp["live_errors"] = liveErrors
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleProviderValidate checks a provider API key with a cheap
+// authenticated call. Without "api_key" the configured key is checked.
+// POST /api/v2/providers/validate {"provider": "openai", "api_key": "sk-..."}
+func (s *Server) HandleProviderValidate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Provider string `json:"provider"`
+		APIKey   string `json:"api_key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+		return
+	}
+	if req.Provider == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "provider is required")
+		return
+	}
+
+	result, err := s.providerRegistry.Validate(req.Provider, req.APIKey)
+	switch {
+	case errors.Is(err, providers.ErrUnknownProvider):
+		WriteError(w, CodeNotFound, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, providers.ErrNoKey):
+		WriteError(w, CodeNotConfigured, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		log.Ctx(r.Context()).Warn().Err(err).Str("provider", req.Provider).Msg("Provider validation failed")
+		WriteError(w, CodeUpstreamError, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	log.Ctx(r.Context()).Info().Str("provider", req.Provider).Bool("valid", result.Valid).Msg("Validated provider key")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
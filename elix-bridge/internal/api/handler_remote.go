@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/remote"
+	"echohelix/bridge/internal/workspace"
+)
+
+// errRemoteNotRegistered refuses SSH locations outside the registered
+// workspaces: the bridge connects with the owner's keys, so a request must
+// not be able to name any host they reach
+var errRemoteNotRegistered = errors.New("ssh location is not in a registered workspace")
+
+// remoteLocation resolves an fs request against an SSH workspace.
+// p may itself be an ssh:// URL inside a registered workspace, or be
+// relative to an ssh:// root that is one. It returns the workspace base
+// and the path relative to it; ok is false when the request targets the
+// local filesystem.
+func (s *Server) remoteLocation(root, p string) (base *remote.Location, rel string, ok bool, err error) {
+	switch {
+	case remote.IsRemote(p):
+		loc, err := remote.ParseURL(p)
+		if err != nil {
+			return nil, "", true, err
+		}
+		base, rel, err = s.remoteWorkspace(loc, false)
+		return base, rel, true, err
+	case remote.IsRemote(root):
+		loc, err := remote.ParseURL(root)
+		if err != nil {
+			return nil, "", true, err
+		}
+		if base, _, err = s.remoteWorkspace(loc, true); err != nil {
+			return nil, "", true, err
+		}
+		// 清理后仍以 .. 开头的路径会逃出工作区
+		clean := path.Clean(filepath.ToSlash(p))
+		if clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, "", true, fmt.Errorf("path %q is outside the workspace", p)
+		}
+		return base, clean, true, nil
+	}
+	return nil, "", false, nil
+}
+
+// remoteWorkspace finds the registered SSH workspace containing loc, or
+// with exact only the one rooted at it, and returns its root and loc's
+// path relative to it
+func (s *Server) remoteWorkspace(loc *remote.Location, exact bool) (*remote.Location, string, error) {
+	for _, ws := range s.workspaceSvc.List() {
+		if ws.Type != workspace.TypeSSH {
+			continue
+		}
+		root, err := remote.ParseURL(ws.Path)
+		if err != nil || root.User != loc.User || root.Host != loc.Host {
+			continue
+		}
+		if loc.Path == root.Path {
+			return root, ".", nil
+		}
+		if rel, ok := strings.CutPrefix(loc.Path, strings.TrimSuffix(root.Path, "/")+"/"); ok && !exact {
+			return root, rel, nil
+		}
+	}
+	return nil, "", errRemoteNotRegistered
+}
+
+// requireRemoteAdmin answers 403 unless the caller may point the bridge at
+// a new SSH host, which only an admin can: connections use the owner's
+// keys and agent
+func requireRemoteAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if token, ok := auth.TokenFromContext(r.Context()); ok && !token.HasPermission(auth.PermissionAdmin) {
+		WriteError(w, CodeForbidden, http.StatusForbidden, map[string]interface{}{
+			"required_permission": auth.PermissionAdmin,
+			"permissions":         token.Permissions,
+		})
+		return false
+	}
+	return true
+}
+
+// handleRemoteFile serves GET /fs/file for an SSH workspace
+func (s *Server) handleRemoteFile(w http.ResponseWriter, r *http.Request, base *remote.Location, rel string, err error) {
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var offset, limit int64
+	if v, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64); err == nil {
+		offset = v
+	}
+	if v, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64); err == nil {
+		limit = v
+	}
+
+	if offset < 0 {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "offset must not be negative")
+		return
+	}
+
+	buf, size, err := s.remotePool.ReadFile(base.Join(rel), offset, limit)
+	if err != nil {
+		WriteError(w, CodeFileNotFound, http.StatusNotFound, "File not found or unreadable: "+err.Error())
+		return
+	}
+
+	if offset > size {
+		offset = size
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":      r.URL.Query().Get("path"),
+		"content":   string(buf),
+		"size":      size,
+		"offset":    offset,
+		"limit":     len(buf),
+		"truncated": offset+int64(len(buf)) < size,
+		"is_binary": false,
+	})
+}
+
+// remoteStat stats a path in an SSH workspace
+func (s *Server) remoteStat(base *remote.Location, rel string) (os.FileInfo, error) {
+	return s.remotePool.Stat(base.Join(rel))
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"echohelix/bridge/internal/jobs"
+	"echohelix/bridge/internal/semantic"
+
+	"github.com/rs/zerolog/log"
+)
+
+// semanticStaleAfter is how old an index may get before a search starts
+// a background refresh
+const semanticStaleAfter = 5 * time.Minute
+
+// semanticIndex returns the embedding index of a workspace, replacing
+// the current one when the workspace or SEMANTIC_SEARCH changed.
+// SEMANTIC_SEARCH names the embedding provider; unset disables the index.
+func (s *Server) semanticIndex(root string) (*semantic.Index, error) {
+	provider := s.configSvc.Get("SEMANTIC_SEARCH")
+	if provider == "" {
+		return nil, errSemanticDisabled
+	}
+	embedder, err := semantic.NewEmbedder(provider, s.configSvc.Get("SEMANTIC_MODEL"), s.configSvc.Get)
+	if err != nil {
+		return nil, err
+	}
+
+	s.semanticMu.Lock()
+	defer s.semanticMu.Unlock()
+	if s.semanticIdx == nil || s.semanticIdx.Root() != root || s.semanticIdx.Embedder() != embedder.Name() {
+		s.semanticIdx = semantic.Open(root, filepath.Join(s.echoDir, "semantic"), embedder)
+		s.semanticJob = ""
+	}
+	return s.semanticIdx, nil
+}
+
+var errSemanticDisabled = errors.New("semantic search is disabled; set SEMANTIC_SEARCH to gemini, openai, ollama, or hash")
+
+// writeSemanticError maps index setup failures to API errors
+func writeSemanticError(w http.ResponseWriter, err error) {
+	WriteError(w, CodeNotConfigured, http.StatusServiceUnavailable, err.Error())
+}
+
+// refreshSemanticIndex submits a job that brings idx up to date, or
+// returns the job already doing so
+func (s *Server) refreshSemanticIndex(idx *semantic.Index) jobs.Job {
+	s.semanticMu.Lock()
+	defer s.semanticMu.Unlock()
+	if job, ok := s.jobMgr.Get(s.semanticJob); ok && (job.Status == jobs.StatusQueued || job.Status == jobs.StatusRunning) {
+		return job
+	}
+
+	job := s.jobMgr.Submit("semantic.index", func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		job.SetProgress(0, "indexing "+idx.Root())
+		stats, err := idx.Refresh(ctx, func(done, total int) {
+			job.SetProgress(float64(done)/float64(total), fmt.Sprintf("embedded %d of %d files", done, total))
+		})
+		if err != nil {
+			log.Warn().Err(err).Str("workspace", idx.Root()).Msg("Semantic index refresh failed")
+			return stats, err
+		}
+		return stats, nil
+	})
+	s.semanticJob = job.ID
+	return job
+}
+
+// HandleSemanticSearch finds code by meaning. The first search of a
+// workspace starts indexing it and returns 202 with the job; later
+// searches answer from the index and refresh it in the background when
+// it is stale.
+// GET /api/v2/search/semantic?q=...&limit=20&workspace=
+func (s *Server) HandleSemanticSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "q parameter is required")
+		return
+	}
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	idx, err := s.semanticIndex(s.resolveWorkDir(r.URL.Query().Get("workspace")))
+	if err != nil {
+		writeSemanticError(w, err)
+		return
+	}
+
+	status := idx.Status()
+	if status.UpdatedAt.IsZero() {
+		job := s.refreshSemanticIndex(idx)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "indexing",
+			"job":    job,
+		})
+		return
+	}
+	if !status.Refreshing && time.Since(status.UpdatedAt) > semanticStaleAfter {
+		s.refreshSemanticIndex(idx)
+	}
+
+	results, err := idx.Search(r.Context(), query, limit)
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("Semantic search failed")
+		WriteError(w, CodeUpstreamError, http.StatusBadGateway, err.Error())
+		return
+	}
+	if results == nil {
+		results = []semantic.Result{}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   query,
+		"results": results,
+		"index":   idx.Status(),
+	})
+}
+
+// HandleSemanticIndex starts (re)indexing a workspace; only changed
+// files are embedded again. Returns the job to poll via /jobs/{id}.
+// POST /api/v2/search/semantic/index?workspace=
+func (s *Server) HandleSemanticIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	idx, err := s.semanticIndex(s.resolveWorkDir(r.URL.Query().Get("workspace")))
+	if err != nil {
+		writeSemanticError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(s.refreshSemanticIndex(idx))
+}
+
+// HandleSemanticStatus returns the size and age of a workspace's index
+// GET /api/v2/search/semantic/status?workspace=
+func (s *Server) HandleSemanticStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	idx, err := s.semanticIndex(s.resolveWorkDir(r.URL.Query().Get("workspace")))
+	if err != nil {
+		writeSemanticError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(idx.Status())
+}
@@ -5,14 +5,22 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"echohelix/bridge/internal/session"
 )
 
-// HandleSessionList returns all sessions
+// HandleSessionList returns all sessions, most recently updated first.
+// With limit= or cursor= it returns one page and the next_cursor.
 func (s *Server) HandleSessionList(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	page, err := parsePage(r)
+	if err != nil {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err)
+		return
+	}
+
 	// 可选的状态过滤
 	statusParam := r.URL.Query().Get("status")
 	var sessions []*session.Session
@@ -22,13 +30,24 @@ func (s *Server) HandleSessionList(w http.ResponseWriter, r *http.Request) {
 		sessions = s.sessionMgr.List()
 	}
 
+	if !page.active {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sessions": sessions,
+			"count":    len(sessions),
+		})
+		return
+	}
+	items, next := paginate(sessions, func(s *session.Session) string { return newestFirstKey(s.UpdatedAt, s.ID) }, page)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"sessions": sessions,
-		"count":    len(sessions),
+		"sessions":    items,
+		"count":       len(items),
+		"total":       len(sessions),
+		"next_cursor": next,
 	})
 }
 
-// HandleSessionCreate creates a new session
+// HandleSessionCreate creates a new session. Name, provider and model
+// default to those of GET /api/v2/defaults for its working directory.
 func (s *Server) HandleSessionCreate(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -40,25 +59,30 @@ func (s *Server) HandleSessionCreate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request body",
-		})
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	// 默认值
+	// 默认值来自配置与工作区的 defaults.json
+	defaults := s.defaults(s.resolveWorkDir(req.WorkingDirectory))
 	if req.Name == "" {
-		req.Name = "New Session"
-	}
-	if req.Provider == "" {
-		req.Provider = "gemini"
+		req.Name = defaults.SessionName
 	}
-	if req.Model == "" {
-		req.Model = "gemini-2.5-flash"
+	switch {
+	case req.Provider == "":
+		req.Provider = defaults.Provider
+		if req.Model == "" {
+			req.Model = defaults.Model
+		}
+	case req.Model == "" && req.Provider == defaults.Provider:
+		req.Model = defaults.Model
+	case req.Model == "":
+		req.Model = s.firstModel(req.Provider)
 	}
 
 	sess := s.sessionMgr.Create(req.Name, req.WorkingDirectory, req.Provider, req.Model)
+	s.workspaceSvc.RecordSession(sess.WorkingDirectory)
+	s.eventBus.Publish("session.created", sess)
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(sess)
@@ -70,19 +94,13 @@ func (s *Server) HandleSessionGet(w http.ResponseWriter, r *http.Request) {
 
 	sessionID := r.URL.Query().Get("id")
 	if sessionID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Session ID is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
 		return
 	}
 
 	sess, ok := s.sessionMgr.Get(sessionID)
 	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Session not found",
-		})
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
 		return
 	}
 
@@ -95,30 +113,22 @@ func (s *Server) HandleSessionUpdate(w http.ResponseWriter, r *http.Request) {
 
 	sessionID := r.URL.Query().Get("id")
 	if sessionID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Session ID is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
 		return
 	}
 
 	var updates map[string]string
 	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request body",
-		})
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	sess, ok := s.sessionMgr.Update(sessionID, updates)
 	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Session not found",
-		})
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
 		return
 	}
+	s.eventBus.Publish("session.updated", sess)
 
 	json.NewEncoder(w).Encode(sess)
 }
@@ -129,20 +139,19 @@ func (s *Server) HandleSessionDelete(w http.ResponseWriter, r *http.Request) {
 
 	sessionID := r.URL.Query().Get("id")
 	if sessionID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Session ID is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
 		return
 	}
 
+	sess, _ := s.sessionMgr.Get(sessionID)
 	if !s.sessionMgr.Delete(sessionID) {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Session not found",
-		})
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
 		return
 	}
+	s.drafts.ClearSession(sessionID)
+	s.recentFiles.Forget(sessionID)
+	s.removeSessionImages(sess)
+	s.eventBus.Publish("session.deleted", map[string]string{"id": sessionID})
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -153,10 +162,7 @@ func (s *Server) HandleSessionMessages(w http.ResponseWriter, r *http.Request) {
 
 	sessionID := r.URL.Query().Get("session_id")
 	if sessionID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Session ID is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
 		return
 	}
 
@@ -177,10 +183,7 @@ func (s *Server) HandleSessionMessages(w http.ResponseWriter, r *http.Request) {
 
 	messages, err := s.sessionMgr.GetMessages(sessionID, limit, offset)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeServiceError(w, http.StatusNotFound, err)
 		return
 	}
 
@@ -192,42 +195,104 @@ func (s *Server) HandleSessionMessages(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// HandleSessionAddMessage adds a message to a session
+// HandleSessionAddMessage adds a message to a session. With prompt_id,
+// the content is the expansion of that template using variables, looked
+// up in the session's working directory.
 func (s *Server) HandleSessionAddMessage(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	sessionID := r.URL.Query().Get("session_id")
 	if sessionID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Session ID is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
 		return
 	}
 
 	var req struct {
-		Role       string `json:"role"`
-		Content    string `json:"content"`
-		TokenCount int    `json:"token_count"`
+		Role       string            `json:"role"`
+		Content    string            `json:"content"`
+		TokenCount int               `json:"token_count"`
+		PromptID   string            `json:"prompt_id"`
+		Variables  map[string]string `json:"variables"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request body",
-		})
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.PromptID != "" {
+		sess, ok := s.sessionMgr.Get(sessionID)
+		if !ok {
+			writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+			return
+		}
+		content, err := s.expandPrompt(req.PromptID, s.resolveWorkDir(sess.WorkingDirectory), req.Variables)
+		if err != nil {
+			writePromptError(w, err)
+			return
+		}
+		req.Content = content
+	}
+
+	if err := s.checkSessionMessage(r, sessionID, req.Role, req.Content); err != nil {
+		writePluginError(w, err)
 		return
 	}
 
 	msg, err := s.sessionMgr.AddMessage(sessionID, req.Role, req.Content, req.TokenCount)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeServiceError(w, http.StatusNotFound, err)
 		return
 	}
 
+	if sess, ok := s.sessionMgr.Get(sessionID); ok {
+		s.workspaceSvc.RecordMessage(sess.WorkingDirectory, req.TokenCount)
+	}
+	if req.Role == "user" {
+		s.drafts.Clear(sessionID, deviceID(r))
+	}
+	s.eventBus.Publish("session.message", msg)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(msg)
 }
+
+// HandleSessionReplay reconstructs a session at a point in time: its
+// messages, tool calls and file edits up to until (RFC 3339 or Unix
+// seconds; default now) and every file edited so far with its diffs
+// GET /api/v2/session/replay?id=...&until=2026-01-02T15:04:05Z
+func (s *Server) HandleSessionReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sessionID := r.URL.Query().Get("id")
+	if sessionID == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
+		return
+	}
+
+	var until time.Time
+	if v := r.URL.Query().Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			secs, convErr := strconv.ParseInt(v, 10, 64)
+			if convErr != nil {
+				WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "until must be an RFC 3339 time or Unix seconds")
+				return
+			}
+			t = time.Unix(secs, 0)
+		}
+		until = t
+	}
+
+	replay, err := s.sessionMgr.Replay(sessionID, until)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == session.ErrSessionNotFound {
+			status = http.StatusNotFound
+		}
+		writeServiceError(w, status, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(replay)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"echohelix/bridge/internal/session"
+)
+
+// HandleSessionDraftSave stores the calling device's unsent prompt for a
+// session; empty content clears it. A draft is also cleared when the
+// device sends a message to the session.
+// PUT /api/v2/session/draft?session_id=... {"content": "...", "files": []}
+func (s *Server) HandleSessionDraftSave(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
+		return
+	}
+	if _, ok := s.sessionMgr.Get(sessionID); !ok {
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+		return
+	}
+
+	var req struct {
+		Content string   `json:"content"`
+		Files   []string `json:"files"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	draft, err := s.drafts.Save(session.Draft{
+		SessionID: sessionID,
+		Device:    deviceID(r),
+		Content:   req.Content,
+		Files:     req.Files,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, session.ErrDraftTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeServiceError(w, status, err)
+		return
+	}
+	// 只通知有草稿更新，不广播内容
+	s.eventBus.Publish("session.draft", map[string]interface{}{
+		"session_id": sessionID,
+		"device":     draft.Device,
+		"updated_at": draft.UpdatedAt,
+		"cleared":    draft.Content == "" && len(draft.Files) == 0,
+	})
+
+	json.NewEncoder(w).Encode(draft)
+}
+
+// HandleSessionDraftGet returns the calling device's draft for a session
+// and the drafts of every device, newest first, so a prompt started on
+// one device can be finished on another
+// GET /api/v2/session/draft?session_id=...
+func (s *Server) HandleSessionDraftGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
+		return
+	}
+	if _, ok := s.sessionMgr.Get(sessionID); !ok {
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"drafts": s.drafts.List(sessionID),
+	}
+	if draft, ok := s.drafts.Get(sessionID, deviceID(r)); ok {
+		resp["draft"] = draft
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+
+	"echohelix/bridge/internal/imaging"
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/session"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HandleSessionImageUpload stores an image to attach to a session's
+// prompts, such as a screenshot taken on the phone. The image is the
+// request body (Content-Type image/...) or the "image" field of a
+// multipart form. Chat and queued prompts reference it by ID in their
+// "images"; it is downscaled for each kernel when sent.
+// POST /api/v2/session/image?session_id=...
+func (s *Server) HandleSessionImageUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
+		return
+	}
+	sess, ok := s.sessionMgr.Get(sessionID)
+	if !ok {
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+		return
+	}
+
+	src, err := imageUpload(w, r)
+	if err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer src.Close()
+
+	img, err := imaging.Save(s.resolveWorkDir(sess.WorkingDirectory), sessionID, src)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			WriteError(w, CodeInvalidBody, http.StatusRequestEntityTooLarge, fmt.Sprintf("image is larger than %d MB", imaging.MaxUpload>>20))
+		case errors.Is(err, imaging.ErrUnsupported):
+			WriteError(w, CodeInvalidBody, http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, imaging.ErrTooManyPixels):
+			WriteError(w, CodeInvalidBody, http.StatusRequestEntityTooLarge, err.Error())
+		default:
+			WriteError(w, CodeWriteFailed, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	log.Ctx(r.Context()).Info().
+		Str("session", sessionID).
+		Str("image", img.ID).
+		Int("width", img.Width).
+		Int("height", img.Height).
+		Int64("size", img.Size).
+		Msg("Image uploaded")
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(img)
+}
+
+// HandleSessionImageGet returns an uploaded image as it was sent
+// GET /api/v2/session/image?session_id=...&id=img_...
+func (s *Server) HandleSessionImageGet(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sessionID := q.Get("session_id")
+	if sessionID == "" {
+		w.Header().Set("Content-Type", "application/json")
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
+		return
+	}
+	sess, ok := s.sessionMgr.Get(sessionID)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+		return
+	}
+	path, format, err := imaging.Open(s.resolveWorkDir(sess.WorkingDirectory), sessionID, q.Get("id"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		WriteError(w, CodeNotFound, http.StatusNotFound, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", imaging.ContentType(format))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeFile(w, r, path)
+}
+
+// imageUpload returns the uploaded image of a request, at most
+// imaging.MaxUpload bytes of it
+func imageUpload(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	body := http.MaxBytesReader(w, r.Body, imaging.MaxUpload+(1<<20))
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "multipart/form-data":
+		r.Body = body
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			return nil, err
+		}
+		f, _, err := r.FormFile("image")
+		if err != nil {
+			r.MultipartForm.RemoveAll()
+			return nil, errors.New("multipart form needs an \"image\" file")
+		}
+		return multipartFile{f, r}, nil
+	case strings.HasPrefix(mediaType, "image/"):
+		return body, nil
+	}
+	return nil, errors.New("send the image as the body with an image/* Content-Type, or as the \"image\" field of a multipart form")
+}
+
+// multipartFile removes the form's temporary files once the upload is read
+type multipartFile struct {
+	io.ReadCloser
+	r *http.Request
+}
+
+func (f multipartFile) Close() error {
+	err := f.ReadCloser.Close()
+	f.r.MultipartForm.RemoveAll()
+	return err
+}
+
+// attachImages fits a request's uploaded images to the kernel's limits
+// and adds them to its files
+func (s *Server) attachImages(workspace, kernelName, sessionID string, req *kernel.Request) error {
+	if len(req.Images) == 0 {
+		return nil
+	}
+	if sessionID == "" {
+		return errors.New("images need a session_id")
+	}
+	adapter, ok := s.kernelAdapters[kernelName]
+	if !ok {
+		return fmt.Errorf("no event adapter for kernel %s", kernelName)
+	}
+	caps := adapter.Capabilities()
+	if !caps.ImageInput {
+		return fmt.Errorf("kernel %s does not accept images", kernelName)
+	}
+	limits := imaging.Limits{MaxDimension: caps.MaxImageDimension, MaxBytes: caps.MaxImageBytes}
+	for _, id := range req.Images {
+		path, err := imaging.Prepare(workspace, sessionID, id, kernelName, limits)
+		if err != nil {
+			return fmt.Errorf("image %s: %w", id, err)
+		}
+		req.Files = append(req.Files, path)
+	}
+	req.Images = nil
+	return nil
+}
+
+// removeSessionImages deletes the images uploaded to a deleted session
+func (s *Server) removeSessionImages(sess *session.Session) {
+	dir := imaging.Dir(s.resolveWorkDir(sess.WorkingDirectory), sess.ID)
+	if err := os.RemoveAll(dir); err != nil {
+		log.Warn().Err(err).Str("session", sess.ID).Msg("Failed to remove session images")
+	}
+}
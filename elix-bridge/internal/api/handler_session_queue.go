@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"echohelix/bridge/internal/jobs"
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/session"
+
+	"github.com/rs/zerolog/log"
+)
+
+// queuedPromptTimeout bounds one queued prompt's turn
+const queuedPromptTimeout = 30 * time.Minute
+
+// HandleSessionQueueAdd queues a prompt for a session. Queued prompts run
+// one at a time as jobs, starting the kernel when it is stopped, and each
+// finished job is pushed to the paired devices.
+// POST /api/v2/session/queue {"session_id": "", "kernel": "", "text": "", "files": [], "images": []}
+func (s *Server) HandleSessionQueueAdd(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		SessionID string   `json:"session_id"`
+		Kernel    string   `json:"kernel"`
+		Text      string   `json:"text"`
+		Files     []string `json:"files"`
+		Images    []string `json:"images"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+		return
+	}
+	if req.SessionID == "" {
+		req.SessionID = r.URL.Query().Get("session_id") // v3 路径参数
+	}
+	if req.SessionID == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "session_id is required")
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "text is required")
+		return
+	}
+	sess, ok := s.sessionMgr.Get(req.SessionID)
+	if !ok {
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+		return
+	}
+	if req.Kernel == "" {
+		req.Kernel = s.defaultKernel()
+	}
+	if _, ok := s.kernelAdapters[req.Kernel]; !ok {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "No event adapter for kernel "+req.Kernel)
+		return
+	}
+	// 图片在入队时按内核限制处理好，之后作为普通文件发送
+	kreq := kernel.Request{Files: req.Files, Images: req.Images}
+	if err := s.attachImages(s.resolveWorkDir(sess.WorkingDirectory), req.Kernel, req.SessionID, &kreq); err != nil {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Files = kreq.Files
+
+	if err := s.checkSessionMessage(r, req.SessionID, "user", req.Text); err != nil {
+		writePluginError(w, err)
+		return
+	}
+
+	prompt := s.promptQueue.Add(req.SessionID, req.Kernel, deviceID(r), req.Text, req.Files)
+	s.drafts.Clear(req.SessionID, deviceID(r))
+	log.Ctx(r.Context()).Info().Str("id", prompt.ID).Str("session", prompt.SessionID).Str("kernel", prompt.Kernel).Msg("Prompt queued")
+	s.eventBus.Publish("session.prompt_queued", prompt)
+	s.runPromptQueue()
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(prompt)
+}
+
+// defaultKernel is the kernel prompts go to when none is named: the
+// running one, else the configured default
+func (s *Server) defaultKernel() string {
+	if s.processManager != nil {
+		if st := s.processManager.Status(); st.Running {
+			return st.Kernel
+		}
+	}
+	return s.defaults(s.resolveWorkDir("")).Kernel
+}
+
+// HandleSessionQueueList returns the queued, running and recently
+// finished prompts, oldest first
+// GET /api/v2/session/queue?session_id=
+func (s *Server) HandleSessionQueueList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	prompts := s.promptQueue.List(r.URL.Query().Get("session_id"))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"prompts": prompts,
+		"count":   len(prompts),
+	})
+}
+
+// HandleSessionQueueCancel removes a prompt that has not started yet;
+// a running prompt is stopped by canceling its job
+// DELETE /api/v2/session/queue?id=
+func (s *Server) HandleSessionQueueCancel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id is required")
+		return
+	}
+	prompt, err := s.promptQueue.Cancel(id)
+	switch {
+	case errors.Is(err, session.ErrPromptNotFound):
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	case err != nil:
+		writeServiceError(w, http.StatusConflict, err)
+		return
+	}
+	json.NewEncoder(w).Encode(prompt)
+}
+
+// runPromptQueue submits a job for the oldest queued prompt unless one
+// is running. Each job starts the next when it finishes, so prompts run
+// in order even though the job manager has several workers.
+func (s *Server) runPromptQueue() {
+	s.promptMu.Lock()
+	defer s.promptMu.Unlock()
+	if s.promptJob != "" {
+		return
+	}
+	prompt, ok := s.promptQueue.Next()
+	if !ok {
+		return
+	}
+
+	job := s.jobMgr.Submit("session.prompt", func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		defer func() {
+			s.promptMu.Lock()
+			s.promptJob = ""
+			s.promptMu.Unlock()
+			s.runPromptQueue()
+		}()
+		return s.runQueuedPrompt(ctx, job, prompt)
+	})
+	s.promptJob = job.ID
+	if err := s.promptQueue.Start(prompt.ID, job.ID); err != nil {
+		log.Warn().Err(err).Str("id", prompt.ID).Msg("Failed to mark queued prompt as running")
+	}
+}
+
+// runQueuedPrompt sends one prompt to its kernel and records the turn in
+// the session. The job message ends up as the reply's opening, which is
+// what the job.finished push shows.
+func (s *Server) runQueuedPrompt(ctx context.Context, job *jobs.Job, prompt session.QueuedPrompt) (interface{}, error) {
+	req := kernel.Request{Method: kernel.MethodChat, Text: prompt.Text, Files: prompt.Files}
+	turn, err := s.runKernelTurn(ctx, prompt.Kernel, prompt.SessionID, prompt.DeviceID, req, queuedPromptTimeout, job.SetProgress)
+	finished, _ := s.promptQueue.Finish(prompt.ID, turn.Message, err)
+	s.eventBus.Publish("session.prompt_finished", finished)
+	if err != nil {
+		log.Warn().Err(err).Str("id", prompt.ID).Str("session", prompt.SessionID).Msg("Queued prompt failed")
+		return nil, err
+	}
+	job.SetProgress(1, finished.Reply)
+	return finished, nil
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"echohelix/bridge/internal/tts"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ttsTimeout bounds the synthesis of one reply
+const ttsTimeout = 10 * time.Minute
+
+// HandleSessionTTS speaks an assistant message and streams the audio as
+// it is synthesized, for listening to a long explanation away from the
+// screen. Code blocks are left out. TTS_ENGINE picks system (espeak-ng,
+// say, or TTS_COMMAND; WAV) or openai (speech API; MP3), with TTS_MODEL
+// and TTS_VOICE as defaults for the engine.
+// GET /api/v2/session/tts?message_id=...&voice=
+func (s *Server) HandleSessionTTS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	messageID := r.URL.Query().Get("message_id")
+	if messageID == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "message_id is required")
+		return
+	}
+	msg, err := s.sessionMgr.GetMessage(messageID)
+	if err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+	if msg.Role != "assistant" {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "only assistant messages can be spoken")
+		return
+	}
+	text := tts.Speakable(msg.Content)
+	if text == "" {
+		WriteError(w, CodeInvalidRequest, http.StatusUnprocessableEntity, "message has nothing to speak")
+		return
+	}
+
+	synth, err := tts.New(s.configSvc.Get("TTS_ENGINE"), s.configSvc.Get("TTS_MODEL"), s.configSvc.Get)
+	if err != nil {
+		WriteError(w, CodeNotConfigured, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	voice := r.URL.Query().Get("voice")
+	if voice == "" {
+		voice = s.configSvc.Get("TTS_VOICE")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), ttsTimeout)
+	defer cancel()
+	started := time.Now()
+	out := &audioStream{w: w, contentType: synth.ContentType()}
+	out.flusher, _ = w.(http.Flusher)
+	if err := synth.Synthesize(ctx, text, voice, out); err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("engine", synth.Name()).Int64("bytes", out.n).Msg("Speech synthesis failed")
+		if out.n == 0 && r.Context().Err() == nil {
+			// 尚未写出音频时仍可返回错误
+			WriteError(w, CodeUpstreamError, http.StatusBadGateway, "speech synthesis failed: "+err.Error())
+		}
+		return
+	}
+	log.Ctx(r.Context()).Info().
+		Str("message", messageID).
+		Str("engine", synth.Name()).
+		Dur("took", time.Since(started)).
+		Int64("bytes", out.n).
+		Msg("Message spoken")
+}
+
+// audioStream writes synthesized audio to the response, sending the
+// audio headers with the first bytes and flushing every write so
+// playback can start at once
+type audioStream struct {
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	contentType string
+	n           int64
+}
+
+func (a *audioStream) Write(p []byte) (int, error) {
+	if a.n == 0 {
+		a.w.Header().Set("Content-Type", a.contentType)
+		a.w.Header().Set("Cache-Control", "no-store")
+		a.w.Header().Set("X-Content-Type-Options", "nosniff")
+	}
+	n, err := a.w.Write(p)
+	a.n += int64(n)
+	if a.flusher != nil {
+		a.flusher.Flush()
+	}
+	return n, err
+}
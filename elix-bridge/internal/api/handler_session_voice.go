@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/transcribe"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxVoiceNote is the largest audio upload, the Whisper API's own limit
+const maxVoiceNote = 25 << 20
+
+// voiceTimeout bounds one transcription
+const voiceTimeout = 5 * time.Minute
+
+// audioExtensions names uploaded audio by content type, since both
+// whisper.cpp and the Whisper API go by the file extension
+var audioExtensions = map[string]string{
+	"audio/wav":   ".wav",
+	"audio/x-wav": ".wav",
+	"audio/wave":  ".wav",
+	"audio/mpeg":  ".mp3",
+	"audio/mp4":   ".m4a",
+	"audio/x-m4a": ".m4a",
+	"audio/aac":   ".aac",
+	"audio/webm":  ".webm",
+	"audio/ogg":   ".ogg",
+	"audio/flac":  ".flac",
+}
+
+// HandleSessionVoice transcribes a voice note into a user message of a
+// session, or with queue=true queues the transcript as a prompt. The
+// audio is the request body (Content-Type audio/...) or the "audio" field
+// of a multipart form. TRANSCRIBE_PROVIDER picks openai (Whisper API) or
+// whisper.cpp, with TRANSCRIBE_MODEL and TRANSCRIBE_COMMAND.
+// POST /api/v2/session/voice?session_id=...&language=en&queue=false&kernel=
+func (s *Server) HandleSessionVoice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+	sessionID := q.Get("session_id")
+	if sessionID == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
+		return
+	}
+	if _, ok := s.sessionMgr.Get(sessionID); !ok {
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+		return
+	}
+	queue := q.Get("queue") == "true"
+	kernelName := q.Get("kernel")
+	if queue {
+		if kernelName == "" {
+			kernelName = s.defaultKernel()
+		}
+		if _, ok := s.kernelAdapters[kernelName]; !ok {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "No event adapter for kernel "+kernelName)
+			return
+		}
+	}
+
+	transcriber, err := transcribe.New(s.configSvc.Get("TRANSCRIBE_PROVIDER"), s.configSvc.Get("TRANSCRIBE_MODEL"), s.configSvc.Get)
+	if err != nil {
+		WriteError(w, CodeNotConfigured, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	path, err := saveVoiceNote(w, r)
+	if err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer os.Remove(path)
+
+	ctx, cancel := context.WithTimeout(r.Context(), voiceTimeout)
+	defer cancel()
+	started := time.Now()
+	text, err := transcriber.Transcribe(ctx, path, q.Get("language"))
+	if err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Str("provider", transcriber.Name()).Msg("Transcription failed")
+		WriteError(w, CodeUpstreamError, http.StatusBadGateway, "transcription failed: "+err.Error())
+		return
+	}
+	if text == "" {
+		WriteError(w, CodeInvalidRequest, http.StatusUnprocessableEntity, "no speech was recognized")
+		return
+	}
+	log.Ctx(r.Context()).Info().
+		Str("session", sessionID).
+		Str("provider", transcriber.Name()).
+		Dur("took", time.Since(started)).
+		Int("chars", len(text)).
+		Msg("Voice note transcribed")
+
+	if err := s.checkSessionMessage(r, sessionID, "user", text); err != nil {
+		writePluginError(w, err)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"transcript":  text,
+		"transcriber": transcriber.Name(),
+	}
+	if queue {
+		// 排队的提示词在执行时才记入会话
+		prompt := s.promptQueue.Add(sessionID, kernelName, deviceID(r), text, nil)
+		s.eventBus.Publish("session.prompt_queued", prompt)
+		s.runPromptQueue()
+		resp["prompt"] = prompt
+	} else {
+		msg, err := s.recordMessage(sessionID, session.Message{Role: "user", Content: text})
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		resp["message"] = msg
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// saveVoiceNote writes the uploaded audio to a temporary file named with
+// the audio's extension
+func saveVoiceNote(w http.ResponseWriter, r *http.Request) (string, error) {
+	body := http.MaxBytesReader(w, r.Body, maxVoiceNote)
+	var src io.Reader = body
+	ext := ""
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "multipart/form-data":
+		r.Body = body
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			return "", err
+		}
+		defer r.MultipartForm.RemoveAll()
+		f, header, err := r.FormFile("audio")
+		if err != nil {
+			return "", errors.New("multipart form needs an \"audio\" file")
+		}
+		defer f.Close()
+		src = f
+		ext = strings.ToLower(filepath.Ext(header.Filename))
+		if ext == "" {
+			mediaType, _, _ = mime.ParseMediaType(header.Header.Get("Content-Type"))
+			ext = audioExtensions[mediaType]
+		}
+	case strings.HasPrefix(mediaType, "audio/"):
+		ext = audioExtensions[mediaType]
+	default:
+		return "", errors.New("send audio as the body with an audio/* Content-Type, or as the \"audio\" field of a multipart form")
+	}
+	if ext == "" {
+		return "", errors.New("unsupported audio format")
+	}
+
+	tmp, err := os.CreateTemp("", "echohelix-voice-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, src); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"echohelix/bridge/internal/backup"
+	"echohelix/bridge/internal/migrate"
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/vault"
+)
+
+// storageSubsystems maps top-level entries of the data directory to the
+// subsystem that owns them; anything else counts as "other"
+var storageSubsystems = map[string]string{
+	"sessions":          "sessions",
+	backup.BackupsDir:   "backups",
+	"logs":              "logs",
+	"crashes":           "crashes",
+	"semantic":          "semantic_index",
+	"auth.json":         "auth",
+	"identity_key":      "auth",
+	"models.json":       "providers",
+	"mcp_servers.json":  "mcp",
+	"workspaces.json":   "workspaces",
+	"jobs.json":         "jobs",
+	"exec_runs.json":    "exec",
+	"changes.json":      "changes",
+	"prompt_queue.json": "sessions",
+	"shares.json":       "sessions",
+	"drafts.json":       "sessions",
+	"agent_tasks.json":  "agent",
+	"kernels.json":      "kernels",
+	"usage.json":        "usage",
+	"telemetry.json":    "telemetry",
+	"locales":           "locales",
+	migrate.MarkerFile:  "meta",
+	vault.MetaFile:      "meta",
+}
+
+// storageUsage is the disk use of one subsystem
+type storageUsage struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+	Files int    `json:"files"`
+}
+
+// HandleStorageUsage reports how much of the data directory each
+// subsystem uses, with the retention limits and the last sweep
+// GET /api/v2/storage/usage
+func (s *Server) HandleStorageUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usage := map[string]*storageUsage{}
+	var total int64
+	filepath.WalkDir(s.echoDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(s.echoDir, path)
+		name := storageSubsystem(rel)
+		u, ok := usage[name]
+		if !ok {
+			u = &storageUsage{Name: name}
+			usage[name] = u
+		}
+		u.Bytes += info.Size()
+		u.Files++
+		total += info.Size()
+		return nil
+	})
+
+	subsystems := make([]*storageUsage, 0, len(usage))
+	for _, u := range usage {
+		subsystems = append(subsystems, u)
+	}
+	sort.Slice(subsystems, func(i, j int) bool {
+		return subsystems[i].Bytes > subsystems[j].Bytes
+	})
+
+	resp := map[string]interface{}{
+		"data_dir":    s.echoDir,
+		"total_bytes": total,
+		"subsystems":  subsystems,
+	}
+	if archived, err := s.sessionMgr.ListArchived(); err == nil {
+		resp["archived_sessions"] = len(archived)
+	}
+	if free, err := diskFree(s.echoDir); err == nil {
+		resp["disk_free_bytes"] = free
+	}
+	if s.retention != nil {
+		s.retention.mu.Lock()
+		resp["retention"] = s.retention.policy
+		if s.retention.last != nil {
+			resp["last_sweep"] = s.retention.last
+		}
+		s.retention.mu.Unlock()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// storageSubsystem names the owner of a path relative to the data
+// directory
+func storageSubsystem(rel string) string {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) > 2 && parts[0] == "sessions" && parts[1] == session.ArchiveDir {
+		return "sessions_archive"
+	}
+	top := parts[0]
+	if len(parts) == 1 {
+		// 同时统计备份副本，如 auth.json.bak
+		top = strings.TrimSuffix(top, ".bak")
+	}
+	if name, ok := storageSubsystems[top]; ok {
+		return name
+	}
+	return "other"
+}
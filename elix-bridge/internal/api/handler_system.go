@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// systemInfoTTL is how long a report is reused; probing runs a dozen
+// processes
+const systemInfoTTL = time.Minute
+
+// ToolInfo is a program found (or not) on PATH
+type ToolInfo struct {
+	Found   bool   `json:"found"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// KernelRequirements says whether a kernel can start
+type KernelRequirements struct {
+	Ready   bool     `json:"ready"`
+	Missing []string `json:"missing,omitempty"`
+}
+
+// SystemInfo describes the machine the bridge runs on
+type SystemInfo struct {
+	OS              string                        `json:"os"`
+	OSVersion       string                        `json:"os_version,omitempty"`
+	Arch            string                        `json:"arch"`
+	Hostname        string                        `json:"hostname,omitempty"`
+	CPUs            int                           `json:"cpus"`
+	BridgeVersion   string                        `json:"bridge_version"`
+	GoRuntime       string                        `json:"go_runtime"`
+	Tools           map[string]ToolInfo           `json:"tools"`
+	PackageManagers []string                      `json:"package_managers"`
+	Disk            map[string]interface{}        `json:"disk"`
+	Kernels         map[string]KernelRequirements `json:"kernels"`
+	Warnings        []string                      `json:"warnings"`
+	CollectedAt     time.Time                     `json:"collected_at"`
+}
+
+// probedTools are reported with their versions; each entry lists the
+// candidate binaries and the arguments that print the version
+var probedTools = map[string]struct {
+	names []string
+	args  []string
+}{
+	"go":     {[]string{"go"}, []string{"version"}},
+	"node":   {[]string{"node"}, []string{"--version"}},
+	"npm":    {[]string{"npm"}, []string{"--version"}},
+	"python": {[]string{"python3", "python"}, []string{"--version"}},
+	"pip":    {[]string{"pip3", "pip"}, []string{"--version"}},
+	"git":    {[]string{"git"}, []string{"--version"}},
+}
+
+// packageManagers are only checked for presence
+var packageManagers = []string{
+	"npm", "pnpm", "yarn", "bun", "pip", "pipx", "uv", "poetry", "cargo",
+	"brew", "apt-get", "dnf", "pacman", "apk", "choco", "winget", "scoop",
+}
+
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// probeTool looks up a tool and runs it to read its version
+func probeTool(ctx context.Context, names, args []string) ToolInfo {
+	for _, name := range names {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		info := ToolInfo{Found: true, Path: path}
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+		cancel()
+		if err == nil {
+			info.Version = versionPattern.FindString(string(out))
+		}
+		return info
+	}
+	return ToolInfo{}
+}
+
+// osVersion returns a readable OS release name where one is available
+func osVersion(ctx context.Context) string {
+	switch runtime.GOOS {
+	case "linux":
+		f, err := os.Open("/etc/os-release")
+		if err != nil {
+			return ""
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if v, ok := strings.CutPrefix(scanner.Text(), "PRETTY_NAME="); ok {
+				return strings.Trim(v, `"`)
+			}
+		}
+	case "darwin":
+		out, err := exec.CommandContext(ctx, "sw_vers", "-productVersion").Output()
+		if err == nil {
+			return "macOS " + strings.TrimSpace(string(out))
+		}
+	case "windows":
+		out, err := exec.CommandContext(ctx, "cmd", "/c", "ver").Output()
+		if err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	return ""
+}
+
+// collectSystemInfo probes the tools concurrently and checks what each
+// kernel needs
+func (s *Server) collectSystemInfo(ctx context.Context) SystemInfo {
+	info := SystemInfo{
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		CPUs:          runtime.NumCPU(),
+		BridgeVersion: Version,
+		GoRuntime:     runtime.Version(),
+		Tools:         make(map[string]ToolInfo),
+		Kernels:       make(map[string]KernelRequirements),
+		Warnings:      []string{},
+		CollectedAt:   time.Now(),
+	}
+	info.Hostname, _ = os.Hostname()
+	info.OSVersion = osVersion(ctx)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, t := range probedTools {
+		wg.Add(1)
+		go func(name string, names, args []string) {
+			defer wg.Done()
+			ti := probeTool(ctx, names, args)
+			mu.Lock()
+			info.Tools[name] = ti
+			mu.Unlock()
+		}(name, t.names, t.args)
+	}
+	wg.Wait()
+
+	info.PackageManagers = []string{}
+	for _, pm := range packageManagers {
+		if _, err := exec.LookPath(pm); err == nil {
+			info.PackageManagers = append(info.PackageManagers, pm)
+		}
+	}
+
+	info.Disk = map[string]interface{}{"path": s.echoDir}
+	if free, err := diskFree(s.echoDir); err == nil {
+		info.Disk["free_bytes"] = free
+		if free < diskWarnBytes {
+			info.Warnings = append(info.Warnings, "Low disk space on the data volume")
+		}
+	}
+
+	if !info.Tools["git"].Found {
+		info.Warnings = append(info.Warnings, "git was not found; checkpoints and git features are unavailable")
+	}
+
+	workDir := ""
+	if s.processManager != nil {
+		workDir = s.processManager.WorkDir
+	}
+	gemini := KernelRequirements{}
+	if !info.Tools["node"].Found {
+		gemini.Missing = append(gemini.Missing, "node")
+	}
+	if !info.Tools["npm"].Found {
+		gemini.Missing = append(gemini.Missing, "npm")
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "cores", "gemini", "packages", "a2a-server")); err != nil {
+		gemini.Missing = append(gemini.Missing, "cores/gemini")
+	}
+	aider := KernelRequirements{}
+	if !info.Tools["python"].Found {
+		aider.Missing = append(aider.Missing, "python")
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "cores", "aider", "server.py")); err != nil {
+		aider.Missing = append(aider.Missing, "cores/aider")
+	}
+	for _, kernel := range []struct {
+		name string
+		req  KernelRequirements
+	}{{"gemini", gemini}, {"aider", aider}, {"echo", KernelRequirements{}}} {
+		name, k := kernel.name, kernel.req
+		k.Ready = len(k.Missing) == 0
+		info.Kernels[name] = k
+		if !k.Ready {
+			info.Warnings = append(info.Warnings, name+" kernel is missing "+strings.Join(k.Missing, ", "))
+		}
+	}
+	return info
+}
+
+// HandleSystemInfo reports the OS, toolchain versions on PATH, package
+// managers, free disk space, and which kernels have their prerequisites,
+// so clients can warn before a session fails. Reports are cached for a
+// minute unless ?refresh=true.
+// GET /api/v2/system/info
+func (s *Server) HandleSystemInfo(w http.ResponseWriter, r *http.Request) {
+	s.systemInfoMu.Lock()
+	if s.systemInfo == nil || time.Since(s.systemInfo.CollectedAt) > systemInfoTTL || r.URL.Query().Get("refresh") == "true" {
+		info := s.collectSystemInfo(r.Context())
+		s.systemInfo = &info
+	}
+	info := *s.systemInfo
+	s.systemInfoMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
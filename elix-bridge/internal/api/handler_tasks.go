@@ -0,0 +1,115 @@
+// Package api provides HTTP handlers for the project task runner
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"echohelix/bridge/internal/jobs"
+	"echohelix/bridge/internal/shell"
+	"echohelix/bridge/internal/tasks"
+)
+
+// HandleTaskList returns the runnable tasks detected in the workspace
+// GET /api/v2/tasks?cwd=...
+func (s *Server) HandleTaskList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dir := s.resolveWorkDir(r.URL.Query().Get("cwd"))
+	detected := tasks.Detect(dir)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tasks": detected,
+		"count": len(detected),
+		"cwd":   dir,
+	})
+}
+
+// HandleTaskRun runs a detected task. Output streams like /exec: inline with
+// Accept: text/event-stream, otherwise via /exec/{id}/stream. Results are
+// recorded in the exec run history with source "task". With "async": true the
+// task is queued as a background job instead.
+// POST /api/v2/tasks/run {"id": "npm:test", "cwd": "", "async": false}
+func (s *Server) HandleTaskRun(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID    string `json:"id"`
+		Cwd   string `json:"cwd"`
+		Async bool   `json:"async"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "task id is required")
+		return
+	}
+
+	dir := s.resolveWorkDir(req.Cwd)
+	task, ok := tasks.Find(dir, req.ID)
+	if !ok {
+		WriteError(w, CodeTaskNotFound, http.StatusNotFound, "task not found: "+req.ID)
+		return
+	}
+
+	if req.Async {
+		job := s.jobMgr.Submit("task.run", func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+			run, err := s.shellRunner.Start(shell.Request{
+				Command: task.Command,
+				Dir:     dir,
+				Source:  "task",
+			})
+			if err != nil {
+				return nil, err
+			}
+			job.SetProgress(0, "running "+task.Command)
+
+			done := make(chan struct{})
+			go func() {
+				run.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				s.shellRunner.Cancel(run.ID)
+				<-done
+			}
+			snap := run.Snapshot()
+			if snap.Status != shell.StatusSuccess {
+				return snap, fmt.Errorf("task %s %s (exit code %d)", task.ID, snap.Status, snap.ExitCode)
+			}
+			return snap, nil
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"task": task,
+			"job":  job,
+		})
+		return
+	}
+
+	run, err := s.shellRunner.Start(shell.Request{
+		Command: task.Command,
+		Dir:     dir,
+		Source:  "task",
+	})
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamRunSSE(w, r, run)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"task": task,
+		"run":  run.Snapshot(),
+	})
+}
@@ -0,0 +1,133 @@
+// Package api provides HTTP handlers for interactive terminals
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"echohelix/bridge/internal/terminal"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// terminalMessage is exchanged over the terminal WebSocket.
+// Client -> Bridge: {"type":"input","data":"ls\r"} / {"type":"resize","cols":120,"rows":40}
+// Bridge -> Client: {"type":"output","data":"..."} / {"type":"ready","id":"..."} / {"type":"exit"}
+type terminalMessage struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	Data string `json:"data,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+}
+
+// HandleTerminal bridges a PTY shell over WebSocket. Pass ?id= to reattach
+// to an existing terminal (its scrollback is replayed first).
+// GET /api/v2/terminal?id=&cwd=&cols=80&rows=24
+func (s *Server) HandleTerminal(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var term *terminal.Session
+	if id := query.Get("id"); id != "" {
+		t, ok := s.terminalMgr.Get(id)
+		if !ok {
+			WriteError(w, CodeTerminalNotFound, http.StatusNotFound, "terminal not found")
+			return
+		}
+		term = t
+	} else {
+		cols, _ := strconv.Atoi(query.Get("cols"))
+		rows, _ := strconv.Atoi(query.Get("rows"))
+		t, err := s.terminalMgr.Create(s.resolveWorkDir(query.Get("cwd")), uint16(cols), uint16(rows))
+		if err != nil {
+			WriteError(w, CodeInternal, http.StatusInternalServerError, "failed to start terminal: "+err.Error())
+			return
+		}
+		term = t
+	}
+
+	conn, err := s.upgrade(w, r)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to upgrade websocket")
+		return
+	}
+	defer conn.Close()
+
+	scrollback, out, detach := term.Attach()
+	defer detach()
+
+	if err := conn.WriteJSON(terminalMessage{Type: "ready", ID: term.ID}); err != nil {
+		return
+	}
+	if len(scrollback) > 0 {
+		if err := conn.WriteJSON(terminalMessage{Type: "output", Data: string(scrollback)}); err != nil {
+			return
+		}
+	}
+
+	// Client -> PTY
+	go func() {
+		for {
+			var msg terminalMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				detach()
+				return
+			}
+			switch msg.Type {
+			case "input":
+				term.Write([]byte(msg.Data))
+			case "resize":
+				if msg.Cols > 0 && msg.Rows > 0 {
+					term.Resize(msg.Cols, msg.Rows)
+				}
+			}
+		}
+	}()
+
+	// PTY -> Client
+	for data := range out {
+		if err := conn.WriteJSON(terminalMessage{Type: "output", Data: string(data)}); err != nil {
+			return
+		}
+	}
+
+	select {
+	case <-term.Done():
+		conn.WriteJSON(terminalMessage{Type: "exit", ID: term.ID})
+	default:
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// HandleTerminalList lists running terminals
+// GET /api/v2/terminals
+func (s *Server) HandleTerminalList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	terminals := s.terminalMgr.List()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"terminals": terminals,
+		"count":     len(terminals),
+	})
+}
+
+// HandleTerminalClose kills a terminal
+// DELETE /api/v2/terminal?id=...
+func (s *Server) HandleTerminalClose(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+
+	if !s.terminalMgr.Close(id) {
+		WriteError(w, CodeTerminalNotFound, http.StatusNotFound, "terminal not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
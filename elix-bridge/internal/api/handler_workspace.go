@@ -2,19 +2,53 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
+
+	"echohelix/bridge/internal/git"
+	"echohelix/bridge/internal/remote"
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/workspace"
+
+	"github.com/rs/zerolog/log"
 )
 
-// HandleWorkspaceList returns the list of workspaces
+// summaryScanTimeout bounds the language scan of a workspace summary; a
+// scan cut short returns what it counted with languages_partial set
+const summaryScanTimeout = 2 * time.Second
+
+// summaryCommits is how many recent commits a workspace summary lists
+const summaryCommits = 5
+
+// HandleWorkspaceList returns the list of workspaces, with an ETag for
+// If-None-Match. With limit= or cursor= it returns a page in the order
+// workspaces were added, as {workspaces, next_cursor}.
 func (s *Server) HandleWorkspaceList(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	page, err := parsePage(r)
+	if err != nil {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err)
+		return
+	}
 	workspaces := s.workspaceSvc.List()
-	json.NewEncoder(w).Encode(workspaces)
+	if !page.active {
+		writeJSONWithETag(w, r, workspaces)
+		return
+	}
+	items, next := paginate(workspaces, func(ws workspace.Workspace) string { return ws.ID }, page)
+	writeJSONWithETag(w, r, map[string]interface{}{
+		"workspaces":  items,
+		"count":       len(items),
+		"next_cursor": next,
+	})
 }
 
-// HandleWorkspaceAdd adds a new workspace
+// HandleWorkspaceAdd adds a new workspace. Registering an ssh:// workspace
+// needs admin permission: the fs API then reaches it with the owner's keys.
 func (s *Server) HandleWorkspaceAdd(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -24,27 +58,28 @@ func (s *Server) HandleWorkspaceAdd(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "invalid request body",
-		})
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
 	if req.Path == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "path is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "path is required")
 		return
 	}
+	// SSH 工作区让设备借用本机的密钥访问该主机，仅限管理员注册
+	if remote.IsRemote(req.Path) {
+		if !requireRemoteAdmin(w, r) {
+			return
+		}
+		if _, err := remote.ParseURL(req.Path); err != nil {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 
 	ws, err := s.workspaceSvc.Add(req.Name, req.Path)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": err.Error(),
-		})
+		writeServiceError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -57,18 +92,12 @@ func (s *Server) HandleWorkspaceRemove(w http.ResponseWriter, r *http.Request) {
 
 	id := r.URL.Query().Get("id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": "id parameter is required",
-		})
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id parameter is required")
 		return
 	}
 
 	if err := s.workspaceSvc.Remove(id); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": err.Error(),
-		})
+		writeServiceError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -90,7 +119,24 @@ func (s *Server) HandleWorkspaceValidate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	info, err := os.Stat(req.Path)
+	var info os.FileInfo
+	var err error
+	if base, rel, ok, rerr := s.remoteLocation("", req.Path); ok {
+		if errors.Is(rerr, errRemoteNotRegistered) {
+			// 注册前的检查：未注册的主机只有管理员可以连接
+			if !requireRemoteAdmin(w, r) {
+				return
+			}
+			rel = "."
+			base, rerr = remote.ParseURL(req.Path)
+		}
+		err = rerr
+		if err == nil {
+			info, err = s.remoteStat(base, rel)
+		}
+	} else {
+		info, err = os.Stat(req.Path)
+	}
 	exists := err == nil
 	isDir := false
 	if exists {
@@ -103,3 +149,150 @@ func (s *Server) HandleWorkspaceValidate(w http.ResponseWriter, r *http.Request)
 		"path":         req.Path,
 	})
 }
+
+// maxWorkspaceLimit caps limit= on the workspace statistics summary
+const maxWorkspaceLimit = 100
+
+// workspaceDays reads days= (default 7), capped at the days of activity
+// a workspace keeps
+func workspaceDays(r *http.Request) int {
+	days := 7
+	if v, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && v > 0 {
+		days = min(v, workspace.DailyRetention)
+	}
+	return days
+}
+
+// HandleWorkspaceStats returns usage statistics for a workspace
+// GET /api/v2/workspace/stats?id=...&days=7
+func (s *Server) HandleWorkspaceStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+
+	days := workspaceDays(r)
+
+	ws, activity, err := s.workspaceSvc.GetStats(id, days)
+	if err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workspace": ws,
+		"activity":  activity,
+	})
+}
+
+// HandleWorkspaceStatsSummary returns the most active workspaces
+// GET /api/v2/workspaces/stats?limit=5&days=7
+func (s *Server) HandleWorkspaceStatsSummary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := 5
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = min(v, maxWorkspaceLimit)
+	}
+	days := workspaceDays(r)
+
+	var totalSessions, totalMessages int
+	var totalTokens int64
+	for _, ws := range s.workspaceSvc.List() {
+		totalSessions += ws.Stats.Sessions
+		totalMessages += ws.Stats.Messages
+		totalTokens += ws.Stats.TokensUsed
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"most_active": s.workspaceSvc.MostActive(limit, days),
+		"days":        days,
+		"totals": map[string]interface{}{
+			"sessions":    totalSessions,
+			"messages":    totalMessages,
+			"tokens_used": totalTokens,
+		},
+	})
+}
+
+// HandleWorkspaceSummary returns what the app's project card shows in one
+// call: the README excerpt, languages, recent commits, open sessions and
+// the last week's activity
+// GET /api/v2/workspace/summary?id=...
+func (s *Server) HandleWorkspaceSummary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+	ws, activity, err := s.workspaceSvc.GetStats(id, 7)
+	if err != nil {
+		WriteError(w, CodeNotFound, http.StatusNotFound, err)
+		return
+	}
+	if ws.Type != workspace.TypeLocal {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "summaries are only available for local workspaces")
+		return
+	}
+	if info, err := os.Stat(ws.Path); err != nil || !info.IsDir() {
+		WriteError(w, CodeFileNotFound, http.StatusNotFound, "workspace directory not found")
+		return
+	}
+
+	resp := map[string]interface{}{
+		"workspace":     ws,
+		"activity":      activity,
+		"open_sessions": s.openSessions(ws.ID),
+		"readme":        nil,
+		"git":           nil,
+	}
+
+	if readme, err := workspace.ReadReadme(ws.Path); err != nil {
+		log.Debug().Err(err).Str("workspace", ws.ID).Msg("Failed to read README")
+	} else if readme != nil {
+		resp["readme"] = readme
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), summaryScanTimeout)
+	defer cancel()
+	langs, partial, err := workspace.Languages(ctx, ws.Path)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	resp["languages"] = langs
+	resp["languages_partial"] = partial
+
+	// 非 git 仓库时 git 为 null
+	if repo, err := git.Open(ws.Path); err == nil {
+		info := map[string]interface{}{"branch": repo.CurrentBranch()}
+		if commits, err := repo.Log(summaryCommits); err == nil {
+			info["commits"] = commits
+		}
+		if status, err := repo.Status(); err == nil {
+			info["changed_files"] = len(status.Files)
+			info["ahead"] = status.Ahead
+			info["behind"] = status.Behind
+		}
+		resp["git"] = info
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// openSessions counts the sessions not closed whose working directory
+// belongs to workspace id
+func (s *Server) openSessions(id string) int {
+	n := 0
+	for _, sess := range s.sessionMgr.List(session.StatusActive, session.StatusIdle) {
+		if ws, ok := s.workspaceSvc.Owner(sess.WorkingDirectory); ok && ws.ID == id {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"echohelix/bridge/internal/i18n"
+
+	"github.com/rs/zerolog/log"
+)
+
+// setupLocales loads the translation catalogs in <data dir>/locales over
+// the bundled English and Chinese ones. LOCALE is the language used when
+// a client sends no Accept-Language the catalogs can answer.
+func (s *Server) setupLocales() {
+	dir := filepath.Join(s.echoDir, "locales")
+	if err := i18n.Default.LoadDir(dir); err != nil {
+		log.Warn().Err(err).Str("dir", dir).Msg("Failed to load translation catalogs")
+	}
+	if lang := s.configSvc.Get("LOCALE"); lang != "" {
+		i18n.Default.SetDefault(lang)
+	}
+}
+
+// localeMiddleware negotiates the response language from ?lang= or
+// Accept-Language and announces it in Content-Language, where
+// WriteError reads it back
+func (s *Server) localeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.URL.Query().Get("lang")
+		if accept == "" {
+			accept = r.Header.Get("Accept-Language")
+		}
+		w.Header().Set("Content-Language", i18n.Default.Negotiate(accept))
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseLanguage is the language negotiated for the response w
+func responseLanguage(w http.ResponseWriter) string {
+	return w.Header().Get("Content-Language")
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/auth"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	idempotencyHeader   = "Idempotency-Key"
+	idempotentReplayed  = "Idempotent-Replayed"
+	maxIdempotencyKey   = 255
+	maxIdempotentBody   = 32 << 20 // 更大的请求体不做去重
+	maxIdempotentResult = 1 << 20  // 更大的响应不缓存
+	maxIdempotentKeys   = 1000
+)
+
+// idempotencyCache remembers the responses to requests sent with an
+// Idempotency-Key, so a client retrying after a dropped connection gets
+// the original result instead of running the request twice
+type idempotencyCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*idempotentEntry
+}
+
+// idempotentEntry is a request seen with a key. done is closed once the
+// response is recorded.
+type idempotentEntry struct {
+	fingerprint string
+	done        chan struct{}
+	expires     time.Time
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+// setupIdempotency reads IDEMPOTENCY_TTL (default 24h, 0 disables)
+func (s *Server) setupIdempotency() {
+	ttl := 24 * time.Hour
+	if v := s.configSvc.Get("IDEMPOTENCY_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Warn().Str("key", "IDEMPOTENCY_TTL").Str("value", v).Msg("Invalid TTL, using default")
+		} else {
+			ttl = d
+		}
+	}
+	if ttl > 0 {
+		s.idempotency = &idempotencyCache{ttl: ttl, entries: make(map[string]*idempotentEntry)}
+	}
+}
+
+// begin returns the entry already held for key, or records a new
+// in-flight one and reports found as false
+func (c *idempotencyCache) begin(key, fingerprint string) (entry *idempotentEntry, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		return e, true
+	}
+	c.pruneLocked(now)
+	entry = &idempotentEntry{fingerprint: fingerprint, done: make(chan struct{}), expires: now.Add(c.ttl)}
+	c.entries[key] = entry
+	return entry, false
+}
+
+// complete stores the response of an in-flight entry
+func (c *idempotencyCache) complete(entry *idempotentEntry, status int, header http.Header, body []byte) {
+	c.mu.Lock()
+	entry.status, entry.header, entry.body = status, header, body
+	entry.expires = time.Now().Add(c.ttl)
+	c.mu.Unlock()
+	close(entry.done)
+}
+
+// release forgets an entry whose response should not be replayed, so
+// the next retry runs the request again
+func (c *idempotencyCache) release(key string, entry *idempotentEntry) {
+	c.mu.Lock()
+	if c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(entry.done)
+}
+
+// pruneLocked drops expired entries and, above the cap, the completed
+// entries closest to expiry
+func (c *idempotencyCache) pruneLocked(now time.Time) {
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+	for len(c.entries) >= maxIdempotentKeys {
+		var oldest string
+		for key, e := range c.entries {
+			if e.status == 0 {
+				continue // 仍在处理中
+			}
+			if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+				oldest = key
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		delete(c.entries, oldest)
+	}
+}
+
+// idempotent replays the recorded response when a mutating request is
+// retried with the same Idempotency-Key. Keys are scoped to the device
+// and route; reusing one for a different body is rejected with 422, and
+// a retry arriving while the first attempt still runs gets 409. Server
+// errors and rate limits are not recorded, so those can be retried.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || s.idempotency == nil || isStreaming(r) {
+			next(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+		if err != nil {
+			WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+			return
+		}
+		if len(body) > maxIdempotentBody {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			next(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(append([]byte(r.URL.RawQuery+"\n"), body...))
+		fingerprint := hex.EncodeToString(sum[:])
+		scope := idempotencyScope(r) + " " + r.Method + " " + r.URL.Path + " " + key
+
+		entry, found := s.idempotency.begin(scope, fingerprint)
+		if found {
+			if entry.fingerprint != fingerprint {
+				WriteError(w, CodeInvalidRequest, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+				return
+			}
+			select {
+			case <-entry.done:
+			default:
+				WriteError(w, CodeConflict, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+				return
+			}
+			log.Ctx(r.Context()).Debug().Str("key", key).Msg("Replaying idempotent response")
+			for name, values := range entry.header {
+				if name != http.CanonicalHeaderKey(requestIDHeader) {
+					w.Header()[name] = values
+				}
+			}
+			w.Header().Set(idempotentReplayed, "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		rec := &idempotentRecorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			if !completed {
+				s.idempotency.release(scope, entry) // 处理器 panic
+			}
+		}()
+		next(rec, r)
+		completed = true
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= 500 || status == http.StatusTooManyRequests || rec.overflow {
+			s.idempotency.release(scope, entry)
+			return
+		}
+		s.idempotency.complete(entry, status, rec.header, rec.body.Bytes())
+	}
+}
+
+// idempotencyScope keeps one device from replaying another's responses
+func idempotencyScope(r *http.Request) string {
+	if token, ok := auth.TokenFromContext(r.Context()); ok {
+		return "device:" + token.DeviceID
+	}
+	if isSocketRequest(r) {
+		return "local"
+	}
+	return "ip:" + clientIP(r)
+}
+
+// idempotentRecorder passes a response through while keeping a copy
+type idempotentRecorder struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *idempotentRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *idempotentRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow {
+		if r.body.Len()+len(p) > maxIdempotentResult {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *idempotentRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *idempotentRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/session"
+
+	"github.com/gorilla/websocket"
+)
+
+// kernelReadyTimeout bounds the wait for a started kernel to accept
+// connections
+const kernelReadyTimeout = time.Minute
+
+// kernelTurn is a prompt's recorded reply and what it cost
+type kernelTurn struct {
+	Message *session.Message
+	Usage   kernel.Usage
+}
+
+// runKernelTurn sends req to a kernel on behalf of a session and waits
+// for the end of the turn, recording both sides in the session. The
+// kernel is started when none is running; a different running kernel is
+// left alone. The turn is refused when the session or device is over
+// budget, and its usage is recorded. progress reports what the turn is
+// waiting for.
+func (s *Server) runKernelTurn(ctx context.Context, kernelName, sessionID, deviceID string, req kernel.Request, timeout time.Duration, progress func(float64, string)) (kernelTurn, error) {
+	sess, ok := s.sessionMgr.Get(sessionID)
+	if !ok {
+		return kernelTurn{}, session.ErrSessionNotFound
+	}
+	adapter, ok := s.kernelAdapters[kernelName]
+	if !ok {
+		return kernelTurn{}, fmt.Errorf("no event adapter for kernel %s", kernelName)
+	}
+	if s.processManager == nil {
+		return kernelTurn{}, errors.New("process manager is not initialized")
+	}
+	if err := s.checkBudget(sessionID, deviceID); err != nil {
+		return kernelTurn{}, err
+	}
+	workspace := s.resolveWorkDir(sess.WorkingDirectory)
+	text, err := s.filterOutbound(ctx, workspace, "prompt", req.Text)
+	if err != nil {
+		return kernelTurn{}, err
+	}
+	req.Text = text
+	if err := s.checkOutboundFiles(ctx, workspace, req.Files); err != nil {
+		return kernelTurn{}, err
+	}
+	if err := s.attachImages(workspace, kernelName, sessionID, &req); err != nil {
+		return kernelTurn{}, err
+	}
+
+	// 内核未运行时启动它；不替换正在运行的其他内核
+	st := s.processManager.Status()
+	switch {
+	case st.Running && st.Kernel != kernelName:
+		return kernelTurn{}, fmt.Errorf("kernel %s is running; stop it to send prompts to %s", st.Kernel, kernelName)
+	case !st.Running:
+		progress(0.1, "Starting "+kernelName)
+		if err := s.startKernel(kernelName, 0); err != nil {
+			return kernelTurn{}, fmt.Errorf("start kernel: %w", err)
+		}
+	}
+
+	progress(0.2, "Connecting to "+kernelName)
+	conn, err := dialKernel(ctx, s.kernelURL(kernelName))
+	if err != nil {
+		return kernelTurn{}, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	go func() {
+		// 取消或超时时关闭连接以结束读取
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	codec := adapter.NewCodec()
+	frames, err := codec.Encode(req)
+	if err != nil {
+		return kernelTurn{}, err
+	}
+	if _, err := s.recordMessage(sessionID, session.Message{Role: "user", Content: req.Text}); err != nil {
+		return kernelTurn{}, err
+	}
+	for _, frame := range frames {
+		if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+			return kernelTurn{}, err
+		}
+	}
+
+	progress(0.3, "Waiting for "+kernelName)
+	transcript := kernel.NewTranscript()
+	var turn kernelTurn
+	var kernelErr string
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return kernelTurn{}, ctx.Err()
+			}
+			return kernelTurn{}, fmt.Errorf("kernel connection closed: %w", err)
+		}
+		for _, ev := range codec.Decode(data) {
+			switch ev.Type {
+			case kernel.EventError:
+				kernelErr = ev.Text
+			case kernel.EventMessageDelta, kernel.EventMessage:
+				progress(0.5, "Receiving reply")
+			}
+			if ev.Usage != nil {
+				turn.Usage = *ev.Usage
+			}
+			if ev.ToolCall != nil && ev.ToolCall.Status == kernel.ToolAwaitingApproval {
+				s.requestToolApproval(kernelName, sessionID, ev.ToolCall)
+			}
+			s.recordKernelEvent(sessionID, ev)
+
+			msg, done := transcript.Observe(ev)
+			if ev.Type != kernel.EventDone && !done {
+				continue
+			}
+			if !done {
+				if kernelErr != "" {
+					return kernelTurn{}, errors.New(kernelErr)
+				}
+				return kernelTurn{}, errors.New("kernel ended the turn without a reply")
+			}
+			s.recordUsage(kernelName, sessionID, deviceID, turn.Usage)
+			turn.Message, err = s.recordMessage(sessionID, msg)
+			return turn, err
+		}
+	}
+}
+
+// dialKernel connects to a kernel's WebSocket, retrying while a freshly
+// started kernel comes up
+func dialKernel(ctx context.Context, url string) (*websocket.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, kernelReadyTimeout)
+	defer cancel()
+	for {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+		if err == nil {
+			return conn, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("kernel not reachable at %s: %w", url, err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
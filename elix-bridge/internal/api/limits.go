@@ -0,0 +1,171 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// httpLimits are the server's timeouts and concurrency caps, read from
+// HTTP_* and MAX_* settings. Zero caps are unlimited.
+type httpLimits struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	MaxConnections    int // 同时打开的 TCP 连接（本地 socket 不计）
+	MaxWebSockets     int // 同时代理的 WebSocket
+	MaxGoroutines     int // 超过后拒绝新请求
+}
+
+// setupHTTPLimits reads the limits, falling back to the defaults for
+// unset or invalid values
+func (s *Server) setupHTTPLimits() {
+	duration := func(key string, def time.Duration) time.Duration {
+		v := s.configSvc.Get(key)
+		if v == "" {
+			return def
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Warn().Str("key", key).Str("value", v).Msg("Invalid timeout, using default")
+			return def
+		}
+		return d
+	}
+	count := func(key string, def int) int {
+		v := s.configSvc.Get(key)
+		if v == "" {
+			return def
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Warn().Str("key", key).Str("value", v).Msg("Invalid limit, using default")
+			return def
+		}
+		return n
+	}
+
+	s.limits = httpLimits{
+		ReadHeaderTimeout: duration("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       duration("HTTP_READ_TIMEOUT", 2*time.Minute),
+		WriteTimeout:      duration("HTTP_WRITE_TIMEOUT", 10*time.Minute),
+		IdleTimeout:       duration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		MaxHeaderBytes:    count("HTTP_MAX_HEADER_BYTES", 64<<10),
+		MaxConnections:    count("MAX_CONNECTIONS", 512),
+		MaxWebSockets:     count("MAX_WEBSOCKETS", 64),
+		MaxGoroutines:     count("MAX_GOROUTINES", 20000),
+	}
+}
+
+// isWebSocket reports whether r asks to upgrade to WebSocket
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// isStreaming reports whether a response may legitimately stay open
+// longer than the write timeout: WebSockets, server-sent events, NDJSON
+// streams and forwarded previews
+func isStreaming(r *http.Request) bool {
+	switch {
+	case isWebSocket(r):
+		return true
+	case strings.Contains(r.Header.Get("Accept"), "text/event-stream"):
+		return true
+	case strings.HasSuffix(r.URL.Path, "/stream"), r.URL.Path == "/api/v2/events", r.URL.Path == "/api/v3/events":
+		return true
+	case r.URL.Query().Get("format") == "ndjson":
+		return true
+	case strings.HasPrefix(r.URL.Path, "/preview/"):
+		return true
+	}
+	return false
+}
+
+// limitMiddleware lifts the read and write deadlines for streaming
+// requests and turns requests away with 503 while the WebSocket or
+// goroutine cap is reached
+func (s *Server) limitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if max := s.limits.MaxGoroutines; max > 0 && runtime.NumGoroutine() > max {
+			w.Header().Set("Retry-After", "5")
+			WriteError(w, CodeUnavailable, http.StatusServiceUnavailable, "Bridge is overloaded, try again shortly")
+			return
+		}
+
+		if isStreaming(r) {
+			// 截止时间设置在底层连接上，WebSocket 接管连接后同样生效
+			rc := http.NewResponseController(w)
+			rc.SetReadDeadline(time.Time{})
+			rc.SetWriteDeadline(time.Time{})
+		}
+
+		if isWebSocket(r) && s.limits.MaxWebSockets > 0 {
+			if n := s.webSockets.Add(1); int(n) > s.limits.MaxWebSockets {
+				s.webSockets.Add(-1)
+				log.Ctx(r.Context()).Warn().Int("max", s.limits.MaxWebSockets).Str("path", r.URL.Path).Msg("WebSocket limit reached")
+				w.Header().Set("Retry-After", "5")
+				WriteError(w, CodeUnavailable, http.StatusServiceUnavailable, "Too many open WebSocket connections")
+				return
+			}
+			defer s.webSockets.Add(-1)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitListener caps the number of open connections. Connections over
+// the cap get a 503 response and are closed instead of waiting in the
+// accept queue.
+type limitListener struct {
+	net.Listener
+	max  int64
+	open atomic.Int64
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.open.Add(1) <= l.max {
+			return &limitConn{Conn: conn, release: func() { l.open.Add(-1) }}, nil
+		}
+		l.open.Add(-1)
+		go reject(conn)
+	}
+}
+
+// reject answers a connection over the cap without reading its request
+func reject(conn net.Conn) {
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	body := `{"error":"Too many connections","code":"` + CodeUnavailable + `"}`
+	conn.Write([]byte("HTTP/1.1 503 Service Unavailable\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Retry-After: 5\r\n" +
+		"Connection: close\r\n" +
+		"Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body))
+}
+
+type limitConn struct {
+	net.Conn
+	release func()
+	closed  atomic.Bool
+}
+
+func (c *limitConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.release()
+	}
+	return c.Conn.Close()
+}
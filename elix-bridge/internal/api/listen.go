@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"echohelix/bridge/internal/auth"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Bind modes selected with BIND_MODE
+const (
+	BindModeLAN       = "lan"       // 所有网卡，手机配对所需（默认）
+	BindModeLocalhost = "localhost" // 仅本机回环地址
+	BindModeSocket    = "socket"    // 仅 Unix socket，不开放网络端口
+)
+
+// DefaultSocketPath returns the local control socket path used by the
+// desktop companion and the CLI
+func DefaultSocketPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".echohelix", "bridge.sock")
+}
+
+// socketPath returns SOCKET_PATH or bridge.sock in the data directory
+func (s *Server) socketPath() string {
+	if p := s.configSvc.Get("SOCKET_PATH"); p != "" {
+		return p
+	}
+	return filepath.Join(s.echoDir, "bridge.sock")
+}
+
+// listen opens the listeners for the configured bind mode. The local
+// socket is always opened (AF_UNIX also works on Windows 10+); the TCP
+// listener depends on BIND_MODE.
+func (s *Server) listen(addr string) ([]net.Listener, error) {
+	var listeners []net.Listener
+
+	mode := strings.ToLower(s.configSvc.Get("BIND_MODE"))
+	switch mode {
+	case "", BindModeLAN:
+		mode = BindModeLAN
+	case BindModeLocalhost:
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = net.JoinHostPort("127.0.0.1", port)
+	case BindModeSocket:
+	default:
+		log.Warn().Str("mode", mode).Msg("Unknown BIND_MODE, using lan")
+		mode = BindModeLAN
+	}
+
+	if mode != BindModeSocket {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Str("addr", addr).Str("mode", mode).Msg("Listening on TCP")
+		if s.limits.MaxConnections > 0 {
+			l = &limitListener{Listener: l, max: int64(s.limits.MaxConnections)}
+		}
+		listeners = append(listeners, l)
+	}
+
+	rl, err := s.listenRelay()
+	if err != nil {
+		closeAll(listeners)
+		return nil, err
+	}
+	if rl != nil {
+		listeners = append(listeners, rl)
+	}
+
+	sl, err := s.listenSocket(s.socketPath())
+	if err != nil {
+		if mode == BindModeSocket {
+			closeAll(listeners)
+			return nil, err
+		}
+		// socket 仅是附加入口，失败不影响网络监听
+		log.Warn().Err(err).Msg("Failed to open local socket")
+	} else {
+		listeners = append(listeners, sl)
+	}
+
+	return listeners, nil
+}
+
+// listenSocket listens on a Unix socket readable only by the current user
+func (s *Server) listenSocket(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	// 清理上次异常退出遗留的 socket 文件
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another bridge is already listening on %s", path)
+		}
+		os.Remove(path)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	os.Chmod(path, 0600)
+
+	s.socketFile = path
+	log.Info().Str("path", path).Msg("Listening on local socket")
+	return socketListener{l}, nil
+}
+
+// socketListener reports socket peers as loopback so localhost-only
+// endpoints (pairing code generation) work over the socket; only the
+// owning user can connect to it
+type socketListener struct {
+	net.Listener
+}
+
+func (l socketListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return socketConn{conn}, nil
+}
+
+type socketConn struct {
+	net.Conn
+}
+
+func (c socketConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+type socketConnKey struct{}
+
+// connContext tags requests that arrived over the local socket
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(socketConn); ok {
+		return context.WithValue(ctx, socketConnKey{}, true)
+	}
+	return ctx
+}
+
+// isSocketRequest reports whether r came over the local socket
+func isSocketRequest(r *http.Request) bool {
+	ok, _ := r.Context().Value(socketConnKey{}).(bool)
+	return ok
+}
+
+// protect requires a device token with the route's permission, except
+// over the local socket, which only the owning user can open (used by the
+// CLI and desktop companion). WebSockets opened without a token
+// authenticate with their first message. Retries carrying an
+// Idempotency-Key are answered from the idempotency cache.
+func (s *Server) protect(next http.HandlerFunc) http.HandlerFunc {
+	next = s.idempotent(s.pluginRequestHook(next))
+	authenticated := s.authHandler.AuthenticateMiddleware(s.permissionMiddleware(s.e2eMiddleware(next)))
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isSocketRequest(r) {
+			next(w, r)
+			return
+		}
+		if isWebSocket(r) && !auth.HasToken(r) {
+			s.authenticateWebSocket(w, r, authenticated)
+			return
+		}
+		authenticated(w, r)
+	}
+}
+
+func closeAll(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
@@ -0,0 +1,590 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/changes"
+	"echohelix/bridge/internal/danger"
+	"echohelix/bridge/internal/fs"
+	"echohelix/bridge/internal/fslock"
+	"echohelix/bridge/internal/git"
+	"echohelix/bridge/internal/mcp"
+	"echohelix/bridge/internal/recentfiles"
+	"echohelix/bridge/internal/shell"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Auth permissions that gate MCP tools and routes
+const (
+	permRead    = auth.PermissionRead
+	permWrite   = auth.PermissionWrite
+	permExecute = auth.PermissionExecute
+	permAdmin   = auth.PermissionAdmin
+)
+
+// mcpMaxBody limits a single MCP message
+const mcpMaxBody = 4 << 20
+
+// setupMCP registers the bridge's fs, search, exec, and git capabilities
+// as MCP tools
+func (s *Server) setupMCP() {
+	s.mcpServer = mcp.NewServer(mcp.Implementation{Name: "echohelix-bridge", Version: Version},
+		"Tools operate on the bridge's active workspace. Paths are relative to the workspace root.")
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "fs_list",
+		Description: "List files in a workspace directory",
+		InputSchema: schema(map[string]interface{}{
+			"path":      prop("string", "Directory relative to the workspace root"),
+			"recursive": prop("boolean", "List subdirectories too"),
+		}),
+	}, permRead, s.mcpFSList)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "fs_read",
+		Description: "Read a text file from the workspace",
+		InputSchema: schema(map[string]interface{}{
+			"path": prop("string", "File relative to the workspace root"),
+		}, "path"),
+	}, permRead, s.mcpFSRead)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "fs_write",
+		Description: "Create or overwrite a file in the workspace",
+		InputSchema: schema(map[string]interface{}{
+			"path":    prop("string", "File relative to the workspace root"),
+			"content": prop("string", "New file content"),
+		}, "path", "content"),
+	}, permWrite, s.mcpFSWrite)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "notebook_read",
+		Description: "Read a Jupyter notebook as cells with their source and a text summary of their outputs",
+		InputSchema: schema(map[string]interface{}{
+			"path": prop("string", "Notebook relative to the workspace root"),
+		}, "path"),
+	}, permRead, s.mcpNotebookRead)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "notebook_edit",
+		Description: "Edit cells of a Jupyter notebook, keeping the rest of the notebook as it is",
+		InputSchema: schema(map[string]interface{}{
+			"path": prop("string", "Notebook relative to the workspace root"),
+			"edits": map[string]interface{}{
+				"type":        "array",
+				"description": "Cell edits applied in order, each to the notebook as the previous ones left it",
+				"items": schema(map[string]interface{}{
+					"op":        prop("string", "replace, insert, delete or clear_outputs"),
+					"index":     prop("integer", "Cell index; insert places the new cell there"),
+					"id":        prop("string", "Cell ID, used instead of index when set"),
+					"cell_type": prop("string", "code, markdown or raw"),
+					"source":    prop("string", "New cell source"),
+				}, "op"),
+			},
+		}, "path", "edits"),
+	}, permWrite, s.mcpNotebookEdit)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "propose_change",
+		Description: "Propose edits to workspace files; they are written only after the user approves them",
+		InputSchema: schema(map[string]interface{}{
+			"description": prop("string", "What the change does, shown to the reviewer"),
+			"edits": map[string]interface{}{
+				"type":        "array",
+				"description": "File edits applied in order. Each sets the full content, deletes the file, or replaces the single occurrence of old with new.",
+				"items": schema(map[string]interface{}{
+					"path":    prop("string", "File relative to the workspace root"),
+					"content": prop("string", "New file content"),
+					"delete":  prop("boolean", "Delete the file"),
+					"old":     prop("string", "Exact text to replace; must occur once"),
+					"new":     prop("string", "Replacement for old"),
+				}, "path"),
+			},
+		}, "edits"),
+	}, permWrite, s.mcpProposeChange)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "search",
+		Description: "Search workspace files for a regular expression",
+		InputSchema: schema(map[string]interface{}{
+			"pattern":     prop("string", "Regular expression (RE2)"),
+			"path":        prop("string", "Directory to search, relative to the workspace root"),
+			"max_results": prop("integer", "Maximum matching lines (default 100)"),
+		}, "pattern"),
+	}, permRead, s.mcpSearch)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "exec",
+		Description: "Run an allowlisted shell command in the workspace and return its output",
+		InputSchema: schema(map[string]interface{}{
+			"command":         prop("string", "Command line"),
+			"cwd":             prop("string", "Working directory relative to the workspace root"),
+			"timeout_seconds": prop("integer", "Timeout (default 120)"),
+		}, "command"),
+	}, permExecute, s.mcpExec)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "git_status",
+		Description: "Show the workspace's git branch and changed files",
+		InputSchema: schema(nil),
+	}, permRead, s.mcpGitStatus)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "git_diff",
+		Description: "Show the workspace's uncommitted changes",
+		InputSchema: schema(map[string]interface{}{
+			"path":   prop("string", "Limit the diff to a path"),
+			"staged": prop("boolean", "Show staged changes instead of unstaged"),
+		}),
+	}, permRead, s.mcpGitDiff)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "git_log",
+		Description: "Show recent commits",
+		InputSchema: schema(map[string]interface{}{
+			"limit": prop("integer", "Number of commits (default 20)"),
+		}),
+	}, permRead, s.mcpGitLog)
+
+	s.mcpServer.AddTool(mcp.Tool{
+		Name:        "git_commit",
+		Description: "Stage all changes and commit them",
+		InputSchema: schema(map[string]interface{}{
+			"message": prop("string", "Commit message"),
+		}, "message"),
+	}, permWrite, s.mcpGitCommit)
+}
+
+// HandleMCP serves MCP over the streamable HTTP transport. Each POST
+// carries one JSON-RPC message or batch; the bridge never starts
+// server-to-client streams, so GET is not supported.
+// POST /api/v2/mcp
+func (s *Server) HandleMCP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, mcpMaxBody))
+	if err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+		return
+	}
+
+	resp := s.mcpServer.HandleMessage(r.Context(), body, mcpPermissions(r))
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// mcpPermissions returns the caller's auth permissions. Requests over the
+// local socket are trusted like the CLI and get all of them.
+func mcpPermissions(r *http.Request) []string {
+	if token, ok := auth.TokenFromContext(r.Context()); ok {
+		return token.Permissions
+	}
+	if isSocketRequest(r) {
+		return auth.AdminPermissions
+	}
+	return nil
+}
+
+// workspacePath resolves rel inside the workspace, refusing paths that
+// escape it and op where the workspace policy forbids it
+func (s *Server) workspacePath(op, rel string) (string, error) {
+	if s.processManager == nil {
+		return "", errors.New("no active workspace")
+	}
+	return s.workspacePathIn(s.processManager.WorkDir, op, rel)
+}
+
+// workspacePathIn is workspacePath for the workspace at root
+func (s *Server) workspacePathIn(root, op, rel string) (string, error) {
+	root = filepath.Clean(root)
+	full := filepath.Join(root, rel)
+	if full != root && !strings.HasPrefix(full, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the workspace", rel)
+	}
+	if err := s.checkPrivate(op, full); err != nil {
+		return "", err
+	}
+	if err := checkFSPolicy(root, op, full); err != nil {
+		return "", err
+	}
+	return full, nil
+}
+
+func (s *Server) mcpFSList(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Path      string `json:"path"`
+		Recursive bool   `json:"recursive"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	if _, err := s.workspacePath(fs.OpRead, args.Path); err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.NewWalker(s.processManager.WorkDir).ListFiles(args.Path, args.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := fs.LoadPolicy(s.processManager.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+	entries = readableEntries(s.listReadable(s.processManager.WorkDir, policy), entries)
+	var b strings.Builder
+	for _, e := range entries {
+		if e.IsDir {
+			fmt.Fprintf(&b, "%s/\n", e.Path)
+		} else {
+			fmt.Fprintf(&b, "%s\t%d\n", e.Path, e.Size)
+		}
+	}
+	return mcp.TextResult(b.String()), nil
+}
+
+func (s *Server) mcpFSRead(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	full, err := s.workspacePath(fs.OpRead, args.Path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.filterOutbound(ctx, s.processManager.WorkDir, args.Path, string(data))
+	if err != nil {
+		return nil, err
+	}
+	s.recentFiles.Record(recentfiles.Touch{Workspace: s.trackedRoot(), Path: s.trackedPath(args.Path), Source: recentfiles.SourceMCP, Op: recentfiles.OpRead})
+	return mcp.TextResult(content), nil
+}
+
+func (s *Server) mcpFSWrite(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	full, err := s.workspacePath(fs.OpWrite, args.Path)
+	if err != nil {
+		return nil, err
+	}
+	return s.mcpWrite(ctx, args.Path, full, args.Content)
+}
+
+// mcpWrite writes content to path, resolved to full, for a tool; in
+// review mode the write is proposed instead
+func (s *Server) mcpWrite(ctx context.Context, path, full, content string) (*mcp.CallResult, error) {
+	// 审阅模式下写入先进入待审队列
+	if s.reviewMode() {
+		c, err := s.proposeChange("Write "+path, "mcp", []changes.Edit{{Path: path, Content: &content}})
+		if err != nil {
+			return nil, err
+		}
+		return mcp.TextResult(fmt.Sprintf("change %s to %s is waiting for the user's review; it is not on disk yet", c.ID, path)), nil
+	}
+
+	if err := s.checkFSWrite(ctx, "mcp", "", path, content); err != nil {
+		return nil, err
+	}
+	if lock := s.lockConflict(s.trackedPath(path), "kernel:mcp", fslock.KindAI); lock != nil {
+		return nil, fmt.Errorf("%s is being edited on a device (lock expires %s); ask the user before writing it", path, lock.ExpiresAt.Format(time.RFC3339))
+	}
+	s.checkpointBeforeWrite(s.processManager.WorkDir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("path", path).Msg("File written via MCP")
+	s.recentFiles.Record(recentfiles.Touch{Workspace: s.trackedRoot(), Path: s.trackedPath(path), Source: recentfiles.SourceMCP, Op: recentfiles.OpWrite})
+	s.eventBus.Publish("fs.written", map[string]interface{}{
+		"path": path,
+		"size": len(content),
+	})
+	return mcp.TextResult(fmt.Sprintf("wrote %d bytes to %s", len(content), path)), nil
+}
+
+func (s *Server) mcpSearch(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Pattern    string `json:"pattern"`
+		Path       string `json:"path"`
+		MaxResults int    `json:"max_results"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(args.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	if args.MaxResults <= 0 {
+		args.MaxResults = 100
+	}
+	root, err := s.workspacePath(fs.OpRead, args.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	policy, err := fs.LoadPolicy(s.processManager.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	errLimit := errors.New("limit reached")
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if rel, ok := workspaceRel(s.processManager.WorkDir, path); ok && !policy.Readable(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && fs.IsIgnoredDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > 1<<20 {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+
+		rel, _ := filepath.Rel(s.processManager.WorkDir, path)
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			text := scanner.Text()
+			if strings.IndexByte(text, 0) >= 0 {
+				return nil // 二进制文件
+			}
+			if re.MatchString(text) {
+				fmt.Fprintf(&b, "%s:%d: %s\n", filepath.ToSlash(rel), line, text)
+				count++
+				if count >= args.MaxResults {
+					return errLimit
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil && err != errLimit {
+		return nil, err
+	}
+	if count == 0 {
+		return mcp.TextResult("no matches"), nil
+	}
+	results, err := s.filterOutbound(ctx, s.processManager.WorkDir, "search results", b.String())
+	if err != nil {
+		return nil, err
+	}
+	return mcp.TextResult(results), nil
+}
+
+func (s *Server) mcpExec(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Command        string `json:"command"`
+		Cwd            string `json:"cwd"`
+		TimeoutSeconds int    `json:"timeout_seconds"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	// MCP 无法向用户确认，白名单外的命令直接拒绝
+	if !shell.IsAllowed(args.Command, s.execAllowlist()) {
+		return nil, fmt.Errorf("command is not in the exec allowlist: %s", args.Command)
+	}
+	// 危险命令需要用户经 /api/v2/confirm 确认，工具调用无法做到
+	if class := danger.ClassifyCommand(args.Command); class != "" {
+		return nil, fmt.Errorf("command is dangerous (%s) and must be confirmed by the user; run it through POST /api/v2/exec", class)
+	}
+	dir, err := s.workspacePath(fs.OpRead, args.Cwd)
+	if err != nil {
+		return nil, err
+	}
+	if args.TimeoutSeconds <= 0 {
+		args.TimeoutSeconds = 120
+	}
+
+	run, err := s.shellRunner.Start(shell.Request{
+		Command: args.Command,
+		Dir:     dir,
+		Timeout: time.Duration(args.TimeoutSeconds) * time.Second,
+		Source:  "mcp",
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		s.shellRunner.Cancel(run.ID)
+		return nil, ctx.Err()
+	case <-waitRun(run):
+	}
+
+	info := run.Snapshot()
+	result := mcp.TextResult(fmt.Sprintf("exit code %d (%s)\n%s", info.ExitCode, info.Status, info.Output))
+	result.IsError = info.Status != shell.StatusSuccess
+	return result, nil
+}
+
+// waitRun returns a channel closed when run finishes
+func waitRun(run *shell.Run) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		run.Wait()
+		close(done)
+	}()
+	return done
+}
+
+func (s *Server) mcpRepo() (*git.Repo, error) {
+	if s.processManager == nil {
+		return nil, errors.New("no active workspace")
+	}
+	return git.Open(s.processManager.WorkDir)
+}
+
+func (s *Server) mcpGitStatus(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	repo, err := s.mcpRepo()
+	if err != nil {
+		return nil, err
+	}
+	st, err := repo.Status()
+	if err != nil {
+		return nil, err
+	}
+	return jsonResult(st)
+}
+
+func (s *Server) mcpGitDiff(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Path   string `json:"path"`
+		Staged bool   `json:"staged"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	repo, err := s.mcpRepo()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := repo.Diff(args.Path, args.Staged)
+	if err != nil {
+		return nil, err
+	}
+	if diff == "" {
+		diff = "no changes"
+	}
+	return mcp.TextResult(diff), nil
+}
+
+func (s *Server) mcpGitLog(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Limit int `json:"limit"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Limit <= 0 {
+		args.Limit = 20
+	}
+	repo, err := s.mcpRepo()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := repo.Log(args.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResult(commits)
+}
+
+func (s *Server) mcpGitCommit(ctx context.Context, raw json.RawMessage) (*mcp.CallResult, error) {
+	var args struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(args.Message) == "" {
+		return nil, errors.New("message is required")
+	}
+	repo, err := s.mcpRepo()
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.Stage("."); err != nil {
+		return nil, err
+	}
+	commit, err := repo.Commit(args.Message, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return jsonResult(commit)
+}
+
+// jsonResult returns v as indented JSON text
+func jsonResult(v interface{}) (*mcp.CallResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.TextResult(string(data)), nil
+}
+
+// schema builds a JSON Schema object for tool input
+func schema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	s := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func prop(typ, description string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "description": description}
+}
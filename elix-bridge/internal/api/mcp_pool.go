@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"echohelix/bridge/internal/mcp"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// setupMCPPool connects to the external MCP servers listed in
+// MCP_SERVERS_FILE (default ~/.echohelix/mcp_servers.json) and offers
+// their tools through the bridge's MCP endpoint and the chat proxy.
+// External tools can do anything, so they need the execute permission.
+func (s *Server) setupMCPPool() {
+	path := s.configSvc.Get("MCP_SERVERS_FILE")
+	if path == "" {
+		path = filepath.Join(s.echoDir, "mcp_servers.json")
+	}
+	s.mcpPool = mcp.NewPool(path)
+	s.mcpServer.Attach(s.mcpPool, permExecute)
+
+	// 外部服务器启动可能较慢，不阻塞桥接启动
+	go func() {
+		if err := s.mcpPool.Load(context.Background()); err != nil {
+			log.Warn().Str("component", "mcp").Err(err).Msg("Failed to load MCP servers")
+		}
+	}()
+}
+
+// HandleMCPServers lists the configured external MCP servers and their tools
+// GET /api/v2/mcp/servers
+func (s *Server) HandleMCPServers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"servers": s.mcpPool.Status(),
+	})
+}
+
+// HandleMCPServersReload re-reads the server config and reconnects
+// POST /api/v2/mcp/servers/reload
+func (s *Server) HandleMCPServersReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.mcpPool.Load(r.Context()); err != nil {
+		WriteError(w, CodeInvalidRequest, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Ctx(r.Context()).Info().Msg("Reloaded MCP servers")
+	s.HandleMCPServers(w, r)
+}
+
+// kernelToolBridge lets a kernel behind the chat proxy use external MCP
+// tools. On connect the kernel is sent a "bridge/tools" notification
+// listing them; "tools/call" requests it sends for those tools are
+// answered by the bridge instead of being forwarded to the app.
+type kernelToolBridge struct {
+	pool    *mcp.Pool
+	backend *websocket.Conn
+	writeMu *sync.Mutex
+	ctx     context.Context
+}
+
+// announce sends the tool list to the kernel
+func (b *kernelToolBridge) announce() error {
+	tools := b.pool.Tools()
+	if len(tools) == 0 {
+		return nil
+	}
+	params, _ := json.Marshal(map[string]interface{}{"tools": tools})
+	return b.write(mcp.Request{JSONRPC: "2.0", Method: "bridge/tools", Params: params})
+}
+
+// intercept handles message if it is a call for an external tool and
+// reports whether it did
+func (b *kernelToolBridge) intercept(mt int, message []byte) bool {
+	if mt != websocket.TextMessage {
+		return false
+	}
+	var req mcp.Request
+	if json.Unmarshal(message, &req) != nil || req.Method != "tools/call" || req.IsNotification() {
+		return false
+	}
+	var params mcp.CallParams
+	if json.Unmarshal(req.Params, &params) != nil || !b.pool.Has(params.Name) {
+		return false
+	}
+
+	// 工具调用可能较慢，异步执行以免阻塞消息转发
+	go func() {
+		resp := mcp.Response{JSONRPC: "2.0", ID: req.ID}
+		result, err := b.pool.Call(b.ctx, params.Name, params.Arguments)
+		if err != nil {
+			result = mcp.ErrorResult(err.Error())
+		}
+		resp.Result = result
+		if err := b.write(resp); err != nil {
+			log.Ctx(b.ctx).Warn().Err(err).Str("tool", params.Name).Msg("Failed to return tool result to kernel")
+		}
+	}()
+	return true
+}
+
+func (b *kernelToolBridge) write(v interface{}) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	return b.backend.WriteJSON(v)
+}
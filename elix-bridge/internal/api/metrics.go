@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"echohelix/bridge/internal/metrics"
+	"echohelix/bridge/internal/session"
+)
+
+// Metric series charted on the dashboard
+const (
+	metricRequests       = "requests"
+	metricTokens         = "tokens"
+	metricActiveSessions = "active_sessions"
+	metricKernelMemory   = "kernel_memory_bytes"
+)
+
+// setupMetrics starts the in-memory time-series collector behind the
+// dashboard's metrics page
+func (s *Server) setupMetrics() {
+	s.metrics = metrics.New(metrics.Config{})
+	s.metrics.Counter(metricRequests)
+	s.metrics.Total(metricTokens, func() float64 {
+		var total int64
+		for _, sess := range s.sessionMgr.List() {
+			total += sess.TokensUsed
+		}
+		return float64(total)
+	})
+	s.metrics.Gauge(metricActiveSessions, func() float64 {
+		return float64(len(s.sessionMgr.List(session.StatusActive)))
+	})
+	if s.processManager != nil {
+		s.metrics.Gauge(metricKernelMemory, func() float64 {
+			return float64(s.processManager.Stats().MemoryRSS)
+		})
+	}
+	s.dashboardHandler.SetMetrics(s.metrics)
+}
+
+// countRequest records an API request for the request rate chart. The
+// dashboard's own polling is left out.
+func (s *Server) countRequest(r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/dashboard") {
+		return
+	}
+	s.metrics.Inc(metricRequests)
+}
@@ -0,0 +1,350 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"echohelix/bridge/internal/crash"
+	"echohelix/bridge/internal/ratelimit"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// statusRecorder captures the response status and size while still
+// supporting WebSocket hijacking and SSE flushing
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += n
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection's deadlines
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	// WebSocket 升级后视为 101
+	r.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// requestIDHeader carries the request ID to and from clients and kernels
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern is what a client-supplied request ID must look like
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// accessLogMiddleware logs every request with method, path, status,
+// duration, device, and request ID
+func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// 客户端提供的 ID 会写入日志并转发给内核，格式不符时重新生成
+		requestID := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		// 处理器通过 log.Ctx(r.Context()) 记录日志时自动带上 request_id
+		logger := log.With().Str("request_id", requestID).Logger()
+		ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+		r = r.WithContext(logger.WithContext(ctx))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		s.countRequest(r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		s.recordTelemetry(r, status)
+		device := s.authHandler.DeviceForRequest(r)
+		s.recordActivity(r, device, status)
+
+		event := log.Info()
+		if status >= 500 {
+			event = log.Error()
+		} else if status >= 400 {
+			event = log.Warn()
+		}
+		query := redactQuery(r.URL.RawQuery)
+		fields := func(e *zerolog.Event) *zerolog.Event {
+			e = e.Str("method", r.Method).Str("path", r.URL.Path)
+			if query != "" {
+				e = e.Str("query", query)
+			}
+			return e.
+				Int("status", status).
+				Int("bytes", rec.bytes).
+				Dur("duration", time.Since(start)).
+				Str("device", device).
+				Str("request_id", requestID).
+				Str("remote", r.RemoteAddr)
+		}
+
+		fields(event).Str("component", "http").Msg("HTTP request")
+		if s.accessLog != nil {
+			fields(s.accessLog.Log()).Msg("")
+		}
+	})
+}
+
+// secretParams are query parameters whose values never reach the logs
+var secretParams = map[string]bool{
+	"token":        true,
+	"access_token": true,
+	"code":         true,
+	"key":          true,
+	"secret":       true,
+	"password":     true,
+}
+
+// redactQuery returns the raw query with secret values replaced, so
+// tokens passed as ?token= for WebSockets and previews are not logged
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "[unparseable]"
+	}
+	for name := range query {
+		if secretParams[strings.ToLower(name)] {
+			query[name] = []string{"REDACTED"}
+		}
+	}
+	return query.Encode()
+}
+
+// recoverMiddleware turns a handler panic into a logged 500 response
+// instead of a dropped connection
+func (s *Server) recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// net/http 用于中止响应的哨兵值，需继续向上传递
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			stack := debug.Stack()
+			log.Ctx(r.Context()).Error().
+				Interface("panic", rec).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Bytes("stack", stack).
+				Msg("Handler panicked")
+			s.telemetry.Error("panic")
+			s.reportCrash(crash.KindPanic, fmt.Sprint(rec), stack, map[string]interface{}{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			})
+
+			WriteError(w, CodeInternal, http.StatusInternalServerError, nil)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Rate limit route classes; fs writes and command execution are stricter
+// than reads
+const (
+	rateClassRead  = "read"
+	rateClassWrite = "write"
+	rateClassExec  = "exec"
+)
+
+// execRoutes spawn processes and get the strictest limit
+var execRoutes = map[string]bool{
+	"/api/v2/exec":          true,
+	"/api/v2/tasks/run":     true,
+	"/api/v2/process/start": true,
+	"/api/v2/process/stop":  true,
+	"/api/v2/terminal":      true,
+	"/api/v2/git/clone":     true,
+
+	"/api/v3/exec":              true,
+	"/api/v3/tasks/run":         true,
+	"/api/v3/process/start":     true,
+	"/api/v3/process/stop":      true,
+	"/api/v3/terminals/connect": true,
+	"/api/v3/git/clone":         true,
+}
+
+// rateLimitMiddleware applies per-device and per-IP token buckets and
+// returns 429 with Retry-After when either is exhausted
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		class := rateClass(r)
+		limiter := s.rateLimits[class]
+
+		keys := []string{"ip:" + clientIP(r)}
+		if device := s.authHandler.DeviceForRequest(r); device != "" {
+			keys = append(keys, "device:"+device)
+		}
+
+		for _, key := range keys {
+			if ok, wait := limiter.Allow(key); !ok {
+				retry := int(wait.Seconds()) + 1
+				w.Header().Set("Retry-After", strconv.Itoa(retry))
+				log.Ctx(r.Context()).Warn().Str("key", key).Str("class", class).Msg("Rate limit exceeded")
+				WriteError(w, CodeRateLimited, http.StatusTooManyRequests, map[string]interface{}{
+					"class":       class,
+					"retry_after": retry,
+				})
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setupRateLimits reads RATE_LIMIT_READ, RATE_LIMIT_WRITE, and
+// RATE_LIMIT_EXEC (requests per minute, 0 disables) from the config
+func (s *Server) setupRateLimits() {
+	defaults := map[string]int{
+		rateClassRead:  600,
+		rateClassWrite: 120,
+		rateClassExec:  30,
+	}
+
+	s.rateLimits = make(map[string]*ratelimit.Limiter)
+	for class, perMinute := range defaults {
+		key := "RATE_LIMIT_" + strings.ToUpper(class)
+		if v := s.configSvc.Get(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				log.Warn().Str("key", key).Str("value", v).Msg("Invalid rate limit, using default")
+			} else {
+				perMinute = n
+			}
+		}
+		s.rateLimits[class] = ratelimit.New(ratelimit.Config{PerMinute: perMinute})
+	}
+}
+
+// rateClass classifies a request for rate limiting
+func rateClass(r *http.Request) string {
+	if execRoutes[r.URL.Path] {
+		return rateClassExec
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return rateClassRead
+	}
+	return rateClassWrite
+}
+
+// clientIP returns the remote host without the port
+func clientIP(r *http.Request) string {
+	// 经中继转发的请求，客户端地址由中继写入 X-Forwarded-For
+	if isRelayRequest(r) {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			parts := strings.Split(xff, ",")
+			return strings.TrimSpace(parts[len(parts)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// setupLogging applies LOG_LEVEL and opens ACCESS_LOG_FILE from the config
+func (s *Server) setupLogging() {
+	// 未经过中间件的上下文（如后台任务）回退到全局日志器
+	zerolog.DefaultContextLogger = &log.Logger
+
+	if level := s.configSvc.Get("LOG_LEVEL"); level != "" {
+		if lvl, err := zerolog.ParseLevel(level); err == nil {
+			zerolog.SetGlobalLevel(lvl)
+		} else {
+			log.Warn().Str("level", level).Msg("Invalid LOG_LEVEL, ignoring")
+		}
+	}
+
+	if path := s.configSvc.Get("ACCESS_LOG_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to open access log")
+			return
+		}
+		logger := zerolog.New(f).With().Timestamp().Logger()
+		s.accessLog = &logger
+	}
+}
+
+// requestID returns the ID assigned to the current request by
+// accessLogMiddleware, for inclusion in error responses
+func requestID(w http.ResponseWriter) string {
+	return w.Header().Get(requestIDHeader)
+}
+
+// requestIDFromContext returns the request ID stored in ctx, if any
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
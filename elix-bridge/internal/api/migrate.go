@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+
+	"echohelix/bridge/internal/backup"
+	"echohelix/bridge/internal/config"
+	"echohelix/bridge/internal/migrate"
+
+	"github.com/rs/zerolog/log"
+)
+
+// migrateDataDir upgrades ~/.echohelix to the current schema before any
+// service reads it, writing a labelled backup first. A directory upgraded
+// by a newer bridge stops startup with an error rather than risk this
+// version overwriting data it does not understand.
+func migrateDataDir(echoDir string, configSvc *config.Service) error {
+	result, err := migrate.Run(migrate.Options{
+		DataDir:       echoDir,
+		BridgeVersion: Version,
+		Backup: func(label string) error {
+			keep, _ := strconv.Atoi(configSvc.Get("BACKUP_KEEP"))
+			rotator := backup.NewRotator(backup.RotationConfig{
+				Options: backup.Options{DataDir: echoDir, EnvFile: configSvc.Path()},
+				Keep:    keep,
+			})
+			info, err := rotator.Save(label)
+			if err == nil {
+				log.Info().Str("file", info.Name).Msg("Data directory backed up before migration")
+			}
+			return err
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("migrate data directory %s: %w", echoDir, err)
+	}
+	if len(result.Applied) > 0 {
+		log.Info().Int("from", result.From).Int("to", result.To).Strs("applied", result.Applied).Msg("Data directory migrated")
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// API versions reported in the OpenAPI documents
+const (
+	apiVersion   = "2.0.0"
+	apiVersionV3 = "3.0.0"
+)
+
+// routeDoc documents a single v2 operation. The OpenAPI document is built by
+// walking the router, so every registered route appears in it; routeDocs only
+// adds descriptions, parameters, and request bodies.
+type routeDoc struct {
+	Summary string
+	Tag     string
+	Public  bool
+	Query   []paramDoc
+	Body    []paramDoc
+	Stream  string // "websocket" 或 "sse"
+}
+
+// paramDoc describes a query parameter or request body field
+type paramDoc struct {
+	Name     string
+	Type     string // string, integer, boolean, array, object
+	Required bool
+}
+
+func q(name, typ string) paramDoc  { return paramDoc{Name: name, Type: typ} }
+func qr(name, typ string) paramDoc { return paramDoc{Name: name, Type: typ, Required: true} }
+
+// routeDocs is keyed by "METHOD /path" relative to /api/v2
+var routeDocs = map[string]routeDoc{
+	"GET /health":                      {Summary: "Overall health status", Tag: "system", Public: true},
+	"GET /health/details":              {Summary: "Per-component health status", Tag: "system"},
+	"GET /openapi.json":                {Summary: "This OpenAPI document", Tag: "system", Public: true},
+	"GET /docs":                        {Summary: "Swagger UI", Tag: "system", Public: true},
+	"POST /auth/pair":                  {Summary: "Pair a device with a pairing code", Tag: "auth", Public: true, Body: []paramDoc{qr("code", "string"), qr("device_id", "string"), q("device_name", "string"), q("push_token", "string"), q("push_platform", "string"), q("public_key", "string"), q("platform", "string"), q("app_version", "string")}},
+	"GET /auth/pair/status":            {Summary: "Poll a pairing waiting for desktop approval (PAIRING_APPROVAL); returns the token once approved", Tag: "auth", Public: true, Query: []paramDoc{qr("request_id", "string")}},
+	"GET /auth/pair/requests":          {Summary: "List pairings waiting for approval (localhost only)", Tag: "auth", Public: true},
+	"POST /auth/pair/approve":          {Summary: "Approve a waiting pairing (localhost only, with the X-EchoHelix-Pairing-Decision header)", Tag: "auth", Public: true, Query: []paramDoc{qr("id", "string")}},
+	"POST /auth/pair/deny":             {Summary: "Deny a waiting pairing (localhost only, with the X-EchoHelix-Pairing-Decision header)", Tag: "auth", Public: true, Query: []paramDoc{qr("id", "string")}},
+	"POST /auth/code":                  {Summary: "Generate a pairing code (localhost only); guest codes pair read-only devices", Tag: "auth", Public: true, Query: []paramDoc{q("guest", "boolean")}},
+	"GET /auth/status":                 {Summary: "Check the calling token: permissions, time to expiry, and the last day's activity", Tag: "auth", Public: true},
+	"GET /auth/devices/{id}/activity":  {Summary: "A device's requests over the last day by route class, route and address; other devices need admin", Tag: "auth"},
+	"POST /process/stop":               {Summary: "Stop the running kernel", Tag: "process"},
+	"POST /process/start":              {Summary: "Start a kernel", Tag: "process", Body: []paramDoc{qr("kernel", "string"), q("port", "integer")}},
+	"GET /system/info":                 {Summary: "OS, toolchain versions, package managers, disk space, and kernel prerequisites", Tag: "system", Query: []paramDoc{q("refresh", "boolean")}},
+	"POST /kernels/{name}/install":     {Summary: "Fetch a kernel's sources and install its dependencies as a job", Tag: "process", Query: []paramDoc{q("update", "boolean")}, Body: []paramDoc{q("ref", "string")}},
+	"GET /kernels/{name}/install":      {Summary: "Latest install job of a kernel with recent output", Tag: "process"},
+	"GET /kernels/{name}/version":      {Summary: "Installed and previous kernel version; check=true lists the commits an upgrade would bring in", Tag: "process", Query: []paramDoc{q("check", "boolean"), q("ref", "string")}},
+	"POST /kernels/{name}/upgrade":     {Summary: "Upgrade a kernel to its branch upstream or a ref as a job, restoring the old version on failure", Tag: "process", Body: []paramDoc{q("ref", "string")}},
+	"POST /kernels/{name}/rollback":    {Summary: "Return a kernel to the version before its last install or upgrade", Tag: "process"},
+	"GET /kernels/{name}/capabilities": {Summary: "Features a kernel supports: streaming, tool calls, approvals, image input, interrupts, multi-file edits", Tag: "process"},
+	"GET /process/stats":               {Summary: "Kernel status with memory usage and uptime", Tag: "process"},
+	"GET /providers":                   {Summary: "List model providers", Tag: "providers"},
+	"POST /providers/validate":         {Summary: "Check that a provider API key works", Tag: "providers", Body: []paramDoc{qr("provider", "string"), q("api_key", "string")}},
+	"GET /models":                      {Summary: "List models from the registry and configured providers", Tag: "providers", Query: []paramDoc{q("provider", "string"), q("refresh", "boolean")}},
+	"POST /mcp":                        {Summary: "MCP JSON-RPC endpoint exposing fs, search, exec, and git tools", Tag: "mcp"},
+	"GET /mcp/servers":                 {Summary: "List external MCP servers and their tools", Tag: "mcp"},
+	"GET /plugins":                     {Summary: "List hook plugins with their hooks and call counts", Tag: "system"},
+	"POST /plugins/reload":             {Summary: "Reload the plugin config and restart the plugins", Tag: "system"},
+	"POST /confirm":                    {Summary: "Mint a single-use token confirming a dangerous operation", Tag: "system", Body: []paramDoc{qr("action", "string"), qr("target", "string")}},
+	"POST /mcp/servers/reload":         {Summary: "Reload the external MCP server config and reconnect", Tag: "mcp"},
+	"GET /lsp/hover":                   {Summary: "Hover documentation at a zero-based position", Tag: "lsp", Query: []paramDoc{qr("path", "string"), qr("line", "integer"), qr("character", "integer")}},
+	"GET /lsp/definition":              {Summary: "Definition locations of the symbol at a position", Tag: "lsp", Query: []paramDoc{qr("path", "string"), qr("line", "integer"), qr("character", "integer")}},
+	"GET /lsp/diagnostics":             {Summary: "Errors and warnings for a file", Tag: "lsp", Query: []paramDoc{qr("path", "string"), q("wait_ms", "integer")}},
+	"GET /lsp/servers":                 {Summary: "List running language servers", Tag: "lsp"},
+	"GET /lsp/ws":                      {Summary: "LSP requests and live diagnostics over WebSocket", Tag: "lsp", Stream: "websocket"},
+	"GET /code/outline":                {Summary: "Nested symbols of a file", Tag: "code", Query: []paramDoc{qr("path", "string"), q("source", "string")}},
+	"GET /code/symbols":                {Summary: "Symbols of a file, or workspace-wide symbol search", Tag: "code", Query: []paramDoc{q("path", "string"), q("query", "string"), q("limit", "integer"), q("source", "string")}},
+	"POST /context/pack":               {Summary: "Workspace summary within a token budget for bootstrapping sessions", Tag: "code", Query: []paramDoc{q("format", "string")}, Body: []paramDoc{q("workspace", "string"), q("budget", "integer"), q("include", "array"), q("refresh", "boolean")}},
+	"GET /search/semantic":             {Summary: "Find code by meaning using the workspace embedding index", Tag: "code", Query: []paramDoc{qr("q", "string"), q("limit", "integer"), q("workspace", "string")}},
+	"POST /code/symbols/index":         {Summary: "Rebuild the workspace symbol index (background job)", Tag: "code"},
+	"POST /search/semantic/index":      {Summary: "Index or refresh the workspace embeddings (background job)", Tag: "code", Query: []paramDoc{q("workspace", "string")}},
+	"GET /search/semantic/status":      {Summary: "Size and age of the workspace embedding index", Tag: "code", Query: []paramDoc{q("workspace", "string")}},
+	"GET /forwards":                    {Summary: "List port forwards and listening ports of managed processes", Tag: "forward"},
+	"POST /forward":                    {Summary: "Forward a local port under /preview/{id}/", Tag: "forward", Body: []paramDoc{qr("port", "integer"), q("label", "string"), q("raw", "boolean")}},
+	"DELETE /forward":                  {Summary: "Stop forwarding a port", Tag: "forward", Query: []paramDoc{qr("id", "string")}},
+	"POST /backup":                     {Summary: "Download an archive of all bridge state, or store it (async=true: background job)", Tag: "backup", Query: []paramDoc{q("exclude_secrets", "boolean"), q("save", "boolean"), q("async", "boolean")}},
+	"GET /backups":                     {Summary: "List stored automatic backups", Tag: "backup"},
+	"GET /crashes":                     {Summary: "List crash bundles written on panics and kernel failures", Tag: "system"},
+	"GET /crashes/{name}":              {Summary: "Download a crash bundle to attach to an issue", Tag: "system"},
+	"DELETE /crashes/{name}":           {Summary: "Delete a crash bundle", Tag: "system"},
+	"GET /telemetry/preview":           {Summary: "Telemetry settings and exactly the report that would be sent next", Tag: "system"},
+	"PUT /telemetry":                   {Summary: "Set the telemetry mode: off, local (preview only) or on", Tag: "system", Body: []paramDoc{qr("mode", "string")}},
+	"DELETE /telemetry":                {Summary: "Discard buffered telemetry counts", Tag: "system"},
+	"GET /storage/usage":               {Summary: "Data directory disk use per subsystem, retention limits and the last sweep", Tag: "backup"},
+	"POST /restore":                    {Summary: "Stage an uploaded or stored archive to be restored when the bridge restarts", Tag: "backup", Query: []paramDoc{qr("confirm", "boolean"), q("name", "string")}},
+	"GET /changes":                     {Summary: "List proposed changes awaiting review", Tag: "changes", Query: []paramDoc{q("status", "string")}},
+	"POST /changes":                    {Summary: "Propose file edits for review", Tag: "changes", Body: []paramDoc{q("description", "string"), q("source", "string"), qr("edits", "array")}},
+	"GET /changes/diff":                {Summary: "Pending changes as one unified diff", Tag: "changes", Query: []paramDoc{q("id", "string")}},
+	"GET /changes/{id}":                {Summary: "Get a proposed change", Tag: "changes"},
+	"POST /changes/{id}/apply":         {Summary: "Write a proposed change to disk", Tag: "changes", Query: []paramDoc{q("force", "boolean"), q("confirm_token", "string")}},
+	"POST /changes/{id}/reject":        {Summary: "Discard a proposed change", Tag: "changes", Body: []paramDoc{q("reason", "string")}},
+	"GET /chat/proxy":                  {Summary: "Proxy a chat connection to the kernel; events=true translates to typed bridge events", Tag: "chat", Stream: "websocket", Query: []paramDoc{q("kernel", "string"), q("events", "boolean"), q("session_id", "string")}},
+	"GET /fs/ls":                       {Summary: "List files; paginated with limit or cursor, streamed with format=ndjson", Tag: "fs", Query: []paramDoc{q("path", "string"), q("recursive", "boolean"), q("root", "string"), q("limit", "integer"), q("cursor", "string"), q("format", "string")}},
+	"GET /fs/file":                     {Summary: "Read a file, decoded to UTF-8 and reported with its detected encoding and line endings; its ETag is the content's SHA-256, so If-None-Match with the hash of a cached copy answers 304", Tag: "fs", Query: []paramDoc{qr("path", "string"), q("offset", "integer"), q("limit", "integer"), q("root", "string"), q("session_id", "string")}},
+	"POST /fs/write":                   {Summary: "Write a file; a file locked by another device or the AI answers 409 FILE_LOCKED unless force is set", Tag: "fs", Query: []paramDoc{q("session_id", "string")}, Body: []paramDoc{qr("path", "string"), qr("content", "string"), q("root", "string"), q("confirm_token", "string"), q("force", "boolean"), q("encoding", "string"), q("preserve_eol", "boolean")}},
+	"GET /fs/roots":                    {Summary: "List browsable roots", Tag: "fs"},
+	"GET /fs/stat":                     {Summary: "Stat a path", Tag: "fs", Query: []paramDoc{qr("path", "string"), q("root", "string")}},
+	"GET /fs/exists":                   {Summary: "Check whether a path exists", Tag: "fs", Query: []paramDoc{qr("path", "string"), q("root", "string")}},
+	"POST /fs/sync":                    {Summary: "Changed blocks of a file since the client's copy (rsync-style block diff)", Tag: "fs", Body: []paramDoc{qr("path", "string"), q("block_size", "integer"), q("size", "integer"), q("signatures", "array")}},
+	"POST /fs/lock":                    {Summary: "Take or renew an advisory lock on a file so other devices and the AI are warned before writing it; 409 FILE_LOCKED names the holder", Tag: "fs", Body: []paramDoc{qr("path", "string"), q("mode", "string"), q("ttl_seconds", "integer"), q("session_id", "string")}},
+	"POST /fs/lock/heartbeat":          {Summary: "Keep a lock alive for another TTL", Tag: "fs", Query: []paramDoc{qr("id", "string")}},
+	"DELETE /fs/lock":                  {Summary: "Release a lock", Tag: "fs", Query: []paramDoc{qr("id", "string")}},
+	"GET /fs/locks":                    {Summary: "Live file locks, including the AI's on files it is writing", Tag: "fs", Query: []paramDoc{q("path", "string")}},
+	"GET /fs/notebook":                 {Summary: "Read a Jupyter notebook as cells, with outputs reduced to their text and MIME types; ETag and sha256 are the file's", Tag: "fs", Query: []paramDoc{qr("path", "string"), q("session_id", "string")}},
+	"POST /fs/notebook":                {Summary: "Replace, insert, delete or clear the outputs of notebook cells, keeping the rest of the notebook; a base_sha256 other than the file's answers 409 CONFLICT", Tag: "fs", Query: []paramDoc{q("session_id", "string")}, Body: []paramDoc{qr("path", "string"), qr("edits", "array"), q("base_sha256", "string"), q("confirm_token", "string"), q("force", "boolean")}},
+	"GET /sessions":                    {Summary: "List sessions", Tag: "sessions", Query: []paramDoc{q("status", "string"), q("limit", "integer"), q("cursor", "string")}},
+	"POST /session":                    {Summary: "Create a session", Tag: "sessions", Body: []paramDoc{q("name", "string"), q("working_directory", "string"), q("provider", "string"), q("model", "string")}},
+	"GET /session":                     {Summary: "Get a session", Tag: "sessions", Query: []paramDoc{qr("id", "string")}},
+	"PUT /session":                     {Summary: "Update a session", Tag: "sessions", Query: []paramDoc{qr("id", "string")}, Body: []paramDoc{q("name", "string"), q("working_directory", "string"), q("provider", "string"), q("model", "string"), q("status", "string")}},
+	"DELETE /session":                  {Summary: "Delete a session", Tag: "sessions", Query: []paramDoc{qr("id", "string")}},
+	"POST /session/share":              {Summary: "Mint a time-limited read-only link to a session transcript", Tag: "sessions", Query: []paramDoc{qr("id", "string"), q("ttl_hours", "integer")}},
+	"GET /session/shares":              {Summary: "List live session share links", Tag: "sessions", Query: []paramDoc{q("id", "string")}},
+	"DELETE /session/share":            {Summary: "Revoke a session share link", Tag: "sessions", Query: []paramDoc{qr("share_id", "string")}},
+	"GET /session/draft":               {Summary: "Get this device's unsent prompt and every device's drafts for a session", Tag: "sessions", Query: []paramDoc{qr("session_id", "string")}},
+	"PUT /session/draft":               {Summary: "Save this device's unsent prompt for a session", Tag: "sessions", Query: []paramDoc{qr("session_id", "string")}, Body: []paramDoc{q("content", "string"), q("files", "array")}},
+	"GET /defaults":                    {Summary: "Provider, model, kernel and session name used when a request leaves them out, with the source of each (builtin, config or workspace)", Tag: "sessions", Query: []paramDoc{q("workspace", "string")}},
+	"POST /session/voice":              {Summary: "Transcribe a voice note into a user message or queued prompt", Tag: "sessions", Query: []paramDoc{qr("session_id", "string"), q("language", "string"), q("queue", "boolean"), q("kernel", "string")}},
+	"POST /session/image":              {Summary: "Upload an image (image/* body or multipart \"image\") to attach to a session's prompts by ID", Tag: "sessions", Query: []paramDoc{qr("session_id", "string")}},
+	"GET /session/image":               {Summary: "Download an uploaded image as it was sent", Tag: "sessions", Query: []paramDoc{qr("session_id", "string"), qr("id", "string")}},
+	"GET /session/tts":                 {Summary: "Speak an assistant message, streaming WAV or MP3 audio as it is synthesized", Tag: "sessions", Query: []paramDoc{qr("message_id", "string"), q("voice", "string")}},
+	"GET /session/files":               {Summary: "Files a session read or changed through the bridge, most recent first", Tag: "sessions", Query: []paramDoc{qr("id", "string")}},
+	"GET /session/replay":              {Summary: "Reconstruct a session's messages, tool calls and file edits at a point in time", Tag: "sessions", Query: []paramDoc{qr("id", "string"), q("until", "string")}},
+	"GET /session/messages":            {Summary: "List session messages", Tag: "sessions", Query: []paramDoc{qr("session_id", "string"), q("limit", "integer"), q("offset", "integer")}},
+	"POST /session/message":            {Summary: "Append a message to a session", Tag: "sessions", Query: []paramDoc{qr("session_id", "string")}, Body: []paramDoc{qr("role", "string"), q("content", "string"), q("token_count", "integer"), q("prompt_id", "string"), q("variables", "object")}},
+	"POST /session/queue":              {Summary: "Queue a prompt for a session; prompts run in order as jobs, starting the kernel if needed", Tag: "sessions", Body: []paramDoc{qr("session_id", "string"), qr("text", "string"), q("kernel", "string"), q("files", "array"), q("images", "array")}},
+	"GET /session/queue":               {Summary: "List queued, running and finished prompts", Tag: "sessions", Query: []paramDoc{q("session_id", "string")}},
+	"DELETE /session/queue":            {Summary: "Cancel a prompt that has not started", Tag: "sessions", Query: []paramDoc{qr("id", "string")}},
+	"POST /agent/tasks":                {Summary: "Start an agent task that prompts the kernel and runs the tests until they pass or a step or budget limit is hit", Tag: "agent", Body: []paramDoc{qr("goal", "string"), q("session_id", "string"), q("kernel", "string"), q("workspace", "string"), q("test_command", "string"), q("max_steps", "integer"), q("max_tokens", "integer"), q("max_cost", "number")}},
+	"GET /agent/tasks":                 {Summary: "List agent tasks", Tag: "agent"},
+	"GET /agent/tasks/{id}":            {Summary: "Agent task with the trace of its steps: checkpoint, prompt, reply, test result", Tag: "agent"},
+	"POST /agent/tasks/{id}/cancel":    {Summary: "Stop an agent task", Tag: "agent"},
+	"POST /batch":                      {Summary: "Run several API requests in one round trip; each entry is {id, method, path, headers, body} and is authorized on its own", Tag: "system", Body: []paramDoc{qr("requests", "array"), q("stop_on_error", "boolean")}},
+	"GET /stats/overview":              {Summary: "Sessions, messages and tokens by day, provider and model, average session length and the busiest workspaces", Tag: "usage", Query: []paramDoc{q("days", "integer")}},
+	"GET /usage":                       {Summary: "Token and cost usage today, per device and kernel, and for recent days, with the configured budgets", Tag: "usage", Query: []paramDoc{q("days", "integer"), q("session_id", "string")}},
+	"GET /prompts":                     {Summary: "List user and workspace prompt templates", Tag: "prompts", Query: []paramDoc{q("workspace", "string")}},
+	"POST /prompts":                    {Summary: "Create a prompt template", Tag: "prompts", Body: []paramDoc{qr("name", "string"), qr("content", "string"), q("description", "string"), q("scope", "string"), q("workspace", "string")}},
+	"GET /prompts/{id}":                {Summary: "Get a prompt template", Tag: "prompts", Query: []paramDoc{q("workspace", "string")}},
+	"PUT /prompts/{id}":                {Summary: "Update a prompt template", Tag: "prompts", Query: []paramDoc{q("workspace", "string")}, Body: []paramDoc{q("name", "string"), q("description", "string"), q("content", "string")}},
+	"DELETE /prompts/{id}":             {Summary: "Delete a prompt template", Tag: "prompts", Query: []paramDoc{q("workspace", "string")}},
+	"POST /prompts/{id}/expand":        {Summary: "Fill in a template's variables", Tag: "prompts", Query: []paramDoc{q("workspace", "string")}, Body: []paramDoc{q("variables", "object")}},
+	"GET /workspaces":                  {Summary: "List workspaces", Tag: "workspaces", Query: []paramDoc{q("limit", "integer"), q("cursor", "string")}},
+	"POST /workspace":                  {Summary: "Add a workspace", Tag: "workspaces", Body: []paramDoc{q("name", "string"), qr("path", "string")}},
+	"DELETE /workspace":                {Summary: "Remove a workspace", Tag: "workspaces", Query: []paramDoc{qr("id", "string")}},
+	"POST /workspace/validate":         {Summary: "Validate a workspace path", Tag: "workspaces", Body: []paramDoc{qr("path", "string")}},
+	"GET /workspace/recent-files":      {Summary: "Files recently read or written through the bridge in a workspace (the active one by default); ai=true keeps those the AI changed", Tag: "workspaces", Query: []paramDoc{q("id", "string"), q("limit", "integer"), q("ai", "boolean")}},
+	"GET /workspace/summary":           {Summary: "Project card for a workspace: README excerpt, languages, recent commits, open sessions and the last week's activity", Tag: "workspaces", Query: []paramDoc{qr("id", "string")}},
+	"GET /workspace/stats":             {Summary: "Usage stats for a workspace", Tag: "workspaces", Query: []paramDoc{qr("id", "string"), q("days", "integer")}},
+	"GET /workspaces/stats":            {Summary: "Most active workspaces", Tag: "workspaces", Query: []paramDoc{q("limit", "integer"), q("days", "integer")}},
+	"GET /git/status":                  {Summary: "Working tree status", Tag: "git", Query: []paramDoc{q("dir", "string")}},
+	"GET /git/diff":                    {Summary: "Diff of working tree or index", Tag: "git", Query: []paramDoc{q("dir", "string"), q("path", "string"), q("staged", "boolean")}},
+	"GET /git/log":                     {Summary: "Commit history", Tag: "git", Query: []paramDoc{q("dir", "string"), q("limit", "integer")}},
+	"POST /git/stage":                  {Summary: "Stage paths", Tag: "git", Query: []paramDoc{q("dir", "string")}, Body: []paramDoc{qr("paths", "array")}},
+	"POST /git/unstage":                {Summary: "Unstage paths", Tag: "git", Query: []paramDoc{q("dir", "string")}, Body: []paramDoc{qr("paths", "array")}},
+	"POST /git/commit":                 {Summary: "Commit staged changes", Tag: "git", Query: []paramDoc{q("dir", "string")}, Body: []paramDoc{qr("message", "string"), q("author_name", "string"), q("author_email", "string")}},
+	"POST /git/discard":                {Summary: "Discard changes to a path", Tag: "git", Query: []paramDoc{q("dir", "string"), qr("path", "string"), q("confirm", "boolean")}},
+	"POST /git/clone":                  {Summary: "Clone a repository as a background job", Tag: "git", Body: []paramDoc{qr("url", "string"), qr("path", "string"), q("name", "string"), q("add_workspace", "boolean")}},
+	"GET /checkpoints":                 {Summary: "List checkpoints", Tag: "checkpoints", Query: []paramDoc{q("dir", "string")}},
+	"POST /checkpoints":                {Summary: "Create a checkpoint", Tag: "checkpoints", Query: []paramDoc{q("dir", "string")}, Body: []paramDoc{q("label", "string")}},
+	"POST /checkpoints/{id}/rollback":  {Summary: "Roll back to a checkpoint", Tag: "checkpoints", Query: []paramDoc{q("dir", "string")}},
+	"DELETE /checkpoints/{id}":         {Summary: "Delete a checkpoint", Tag: "checkpoints", Query: []paramDoc{q("dir", "string")}},
+	"POST /exec":                       {Summary: "Run a shell command", Tag: "exec", Body: []paramDoc{qr("command", "string"), q("cwd", "string"), q("env", "object"), q("timeout_seconds", "integer"), q("confirm", "boolean"), q("confirm_token", "string")}},
+	"GET /exec/runs":                   {Summary: "List command runs", Tag: "exec"},
+	"GET /exec/{id}":                   {Summary: "Get a command run", Tag: "exec"},
+	"GET /exec/{id}/stream":            {Summary: "Stream command output", Tag: "exec", Stream: "sse"},
+	"POST /exec/{id}/cancel":           {Summary: "Cancel a command run", Tag: "exec"},
+	"GET /tasks":                       {Summary: "Detect project tasks", Tag: "tasks", Query: []paramDoc{q("cwd", "string")}},
+	"POST /tasks/run":                  {Summary: "Run a detected task", Tag: "tasks", Body: []paramDoc{qr("id", "string"), q("cwd", "string"), q("async", "boolean")}},
+	"GET /jobs":                        {Summary: "List background jobs", Tag: "jobs"},
+	"GET /jobs/events":                 {Summary: "Stream job events", Tag: "jobs", Stream: "websocket"},
+	"GET /jobs/{id}":                   {Summary: "Get a job", Tag: "jobs"},
+	"POST /jobs/{id}/cancel":           {Summary: "Cancel a job", Tag: "jobs"},
+	"GET /terminal":                    {Summary: "Open or attach to a terminal", Tag: "terminal", Stream: "websocket", Query: []paramDoc{q("id", "string"), q("cwd", "string"), q("cols", "integer"), q("rows", "integer")}},
+	"DELETE /terminal":                 {Summary: "Close a terminal", Tag: "terminal", Query: []paramDoc{qr("id", "string")}},
+	"GET /terminals":                   {Summary: "List terminals", Tag: "terminal"},
+	"PUT /notifications/device":        {Summary: "Register the device push token", Tag: "notifications", Body: []paramDoc{qr("push_token", "string"), qr("push_platform", "string")}},
+	"GET /notifications/prefs":         {Summary: "Get notification preferences", Tag: "notifications"},
+	"PUT /notifications/prefs":         {Summary: "Set notification preferences", Tag: "notifications", Body: []paramDoc{q("task_finished", "boolean"), q("approval_needed", "boolean"), q("kernel_crashed", "boolean")}},
+	"POST /notifications/send":         {Summary: "Send a notification to subscribed devices", Tag: "notifications", Body: []paramDoc{q("category", "string"), qr("title", "string"), q("body", "string")}},
+	"GET /events":                      {Summary: "Unified event stream", Tag: "events", Stream: "websocket", Query: []paramDoc{q("topics", "string")}},
+	"GET /devices":                     {Summary: "List paired devices", Tag: "devices"},
+	"DELETE /devices":                  {Summary: "Revoke a paired device", Tag: "devices", Query: []paramDoc{qr("id", "string")}},
+	"POST /devices/admin":              {Summary: "Grant or withdraw a device's admin permission", Tag: "devices", Query: []paramDoc{qr("id", "string"), q("admin", "boolean")}},
+	"GET /config":                      {Summary: "Get configuration", Tag: "config"},
+	"PUT /config":                      {Summary: "Set a configuration value", Tag: "config", Query: []paramDoc{qr("key", "string")}, Body: []paramDoc{qr("value", "string")}},
+}
+
+var pathParamRe = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+// HandleOpenAPI serves the OpenAPI document for the v2 API
+// GET /api/v2/openapi.json
+func (s *Server) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	s.openapiOnce.Do(func() {
+		s.openapiSpec = marshalOpenAPI(s.buildOpenAPI("/api/v2", routeDocs, apiVersion))
+	})
+	writeOpenAPI(w, s.openapiSpec)
+}
+
+// HandleOpenAPIV3 serves the OpenAPI document for the v3 API
+// GET /api/v3/openapi.json
+func (s *Server) HandleOpenAPIV3(w http.ResponseWriter, r *http.Request) {
+	s.openapiV3Once.Do(func() {
+		s.openapiV3Spec = marshalOpenAPI(s.buildOpenAPI("/api/v3", v3Docs(), apiVersionV3))
+	})
+	writeOpenAPI(w, s.openapiV3Spec)
+}
+
+func marshalOpenAPI(doc map[string]interface{}) []byte {
+	spec, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build OpenAPI document")
+		return nil
+	}
+	return spec
+}
+
+func writeOpenAPI(w http.ResponseWriter, spec []byte) {
+	if spec == nil {
+		WriteError(w, CodeInternal, http.StatusInternalServerError, "failed to build OpenAPI document")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// HandleSwaggerUI serves a Swagger UI page for the OpenAPI document
+// GET /api/v2/docs
+func (s *Server) HandleSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIHTML))
+}
+
+// HandleSwaggerUIV3 serves the Swagger UI page for the v3 document
+// GET /api/v3/docs
+func (s *Server) HandleSwaggerUIV3(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(strings.Replace(swaggerUIHTML, "/api/v2/openapi.json", "/api/v3/openapi.json", 1)))
+}
+
+// buildOpenAPI walks the router and produces an OpenAPI 3 document for
+// the routes under prefix. Routes without a docs entry, and entries
+// without a route, are logged so the table stays in sync with the router.
+func (s *Server) buildOpenAPI(prefix string, docs map[string]routeDoc, version string) map[string]interface{} {
+	paths := map[string]map[string]interface{}{}
+	seen := map[string]bool{}
+
+	s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tpl, prefix+"/") {
+			return nil
+		}
+		path := strings.TrimPrefix(tpl, prefix)
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{http.MethodGet} // 未限定方法的路由（如 WebSocket）
+		}
+
+		for _, method := range methods {
+			key := method + " " + path
+			seen[key] = true
+			doc, ok := docs[key]
+			if !ok {
+				log.Warn().Str("route", key).Msg("Route missing from OpenAPI docs")
+				doc = routeDoc{Summary: "Undocumented", Tag: "other"}
+			}
+			if paths[path] == nil {
+				paths[path] = map[string]interface{}{}
+			}
+			paths[path][strings.ToLower(method)] = operation(path, doc)
+		}
+		return nil
+	})
+
+	for key := range docs {
+		if !seen[key] {
+			log.Warn().Str("route", key).Msg("OpenAPI docs entry has no matching route")
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "EchoHelix Bridge API",
+			"version":     version,
+			"description": "HTTP and WebSocket API exposed by the EchoHelix Bridge to paired devices.",
+		},
+		"servers": []map[string]string{{"url": prefix}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer"},
+			},
+			"schemas": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"error", "code"},
+					"properties": map[string]interface{}{
+						"error":      map[string]string{"type": "string"},
+						"code":       map[string]string{"type": "string"},
+						"details":    map[string]string{"type": "object"},
+						"request_id": map[string]string{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// operation builds the OpenAPI operation object for a route
+func operation(path string, doc routeDoc) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary": doc.Summary,
+		"tags":    []string{doc.Tag},
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{"description": "Success"},
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]string{"$ref": "#/components/schemas/Error"},
+					},
+				},
+			},
+		},
+	}
+	wsAuthDoc := ""
+	if !doc.Public {
+		wsAuthDoc = " Without an Authorization header, offer the subprotocols \"echohelix, bearer.<token>\" (padding removed) or send {\"type\":\"auth\",\"token\":...} as the first message; the bridge answers {\"type\":\"auth_ok\"} or closes with 4401/4403."
+		op["security"] = []map[string][]string{{"bearerAuth": {}}}
+	}
+	switch doc.Stream {
+	case "websocket":
+		op["description"] = "WebSocket endpoint; connect with an Upgrade request." + wsAuthDoc
+	case "sse":
+		op["description"] = "Server-Sent Events by default; WebSocket when requested with an Upgrade header." + wsAuthDoc
+	}
+
+	var params []map[string]interface{}
+	for _, m := range pathParamRe.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true,
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+	for _, p := range doc.Query {
+		params = append(params, map[string]interface{}{
+			"name": p.Name, "in": "query", "required": p.Required,
+			"schema": map[string]string{"type": p.Type},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if len(doc.Body) > 0 {
+		props := map[string]interface{}{}
+		var required []string
+		for _, f := range doc.Body {
+			schema := map[string]interface{}{"type": f.Type}
+			if f.Type == "array" {
+				schema["items"] = map[string]string{"type": "string"}
+			}
+			props[f.Name] = schema
+			if f.Required {
+				required = append(required, f.Name)
+			}
+		}
+		sort.Strings(required)
+		schema := map[string]interface{}{"type": "object", "properties": props}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schema},
+			},
+		}
+	}
+	return op
+}
+
+const swaggerUIHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>EchoHelix Bridge API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/v2/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
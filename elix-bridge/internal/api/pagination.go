@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// pageRequest is a client's ?limit=&cursor=. Lists are paginated only
+// when either is given, so older clients keep receiving the full list.
+type pageRequest struct {
+	limit  int
+	after  string
+	active bool
+}
+
+// parsePage reads ?limit= and ?cursor=; cursors are the next_cursor of
+// the previous page and are opaque to clients
+func parsePage(r *http.Request) (pageRequest, error) {
+	q := r.URL.Query()
+	p := pageRequest{limit: defaultPageSize}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return p, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		p.limit = n
+		p.active = true
+	}
+	if v := q.Get("cursor"); v != "" {
+		key, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil || len(key) == 0 {
+			return p, errors.New("invalid cursor")
+		}
+		p.after = string(key)
+		p.active = true
+	}
+	return p, nil
+}
+
+// paginate returns the page of items following the cursor, ordered by
+// key, and the cursor of the next page ("" on the last page). Keys must
+// be unique so the order is stable while items are added or removed
+// between requests.
+func paginate[T any](items []T, key func(T) string, p pageRequest) ([]T, string) {
+	keys := make([]string, len(items))
+	order := make([]int, len(items))
+	for i, item := range items {
+		keys[i] = key(item)
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return keys[order[a]] < keys[order[b]] })
+
+	start := 0
+	if p.after != "" {
+		start = sort.Search(len(order), func(i int) bool { return keys[order[i]] > p.after })
+	}
+	end := start + p.limit
+	if end > len(order) {
+		end = len(order)
+	}
+
+	page := make([]T, 0, end-start)
+	for _, i := range order[start:end] {
+		page = append(page, items[i])
+	}
+	var next string
+	if end < len(order) {
+		next = base64.RawURLEncoding.EncodeToString([]byte(keys[order[end-1]]))
+	}
+	return page, next
+}
+
+// newestFirstKey orders by t descending, then by id
+func newestFirstKey(t time.Time, id string) string {
+	n := t.UnixNano()
+	if n < 0 {
+		n = 0
+	}
+	return fmt.Sprintf("%019d/%s", math.MaxInt64-n, id)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"echohelix/bridge/internal/auth"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// routePermissions overrides the permission derived from the HTTP method
+// for routes whose method doesn't reflect what they do. Keys are
+// "METHOD /path template" as registered on the router; v3 routes use
+// the entry of their v2 equivalent.
+var routePermissions = map[string]string{
+	// 管理桥接本身：配置、设备、内核进程与更新，仅限管理员
+	"GET /api/v2/config":                   permAdmin, // 会暴露 API Key
+	"PUT /api/v2/config":                   permAdmin,
+	"GET /api/v2/health/details":           permAdmin, // 数据目录路径、内核与中继地址
+	"DELETE /api/v2/devices":               permAdmin,
+	"POST /api/v2/devices/admin":           permAdmin,
+	"POST /api/v2/process/start":           permAdmin,
+	"POST /api/v2/process/stop":            permAdmin,
+	"POST /api/v2/kernels/{name}/install":  permAdmin, // 会运行 git、npm、pip
+	"POST /api/v2/kernels/{name}/upgrade":  permAdmin,
+	"POST /api/v2/kernels/{name}/rollback": permAdmin,
+	"POST /api/v2/plugins/reload":          permAdmin,
+	"POST /api/v2/mcp/servers/reload":      permAdmin,
+	"PUT /api/v2/telemetry":                permAdmin,
+	"DELETE /api/v2/telemetry":             permAdmin,
+	"POST /api/v2/backup":                  permAdmin,
+	"POST /api/v2/restore":                 permAdmin,
+	// 聊天连接会向内核发送指令
+	"GET /api/v2/chat/proxy": permWrite,
+	// MCP 按工具逐一检查权限
+	"POST /api/v2/mcp":                 permRead,
+	"POST /api/v2/prompts/{id}/expand": permRead,
+	"POST /api/v2/context/pack":        permRead,
+	"POST /api/v2/batch":               permRead,
+	"POST /api/v2/fs/sync":             permRead,
+	// 代理任务会运行测试命令
+	"POST /api/v2/agent/tasks": permExecute,
+	// 手动转发可把任意本机端口暴露给设备
+	"POST /api/v2/forward": permExecute,
+}
+
+// requiredPermission returns the token permission a request needs:
+// admin for managing the bridge, execute for routes that spawn
+// processes, read for other GETs, and write for everything else
+func requiredPermission(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			key := r.Method + " " + tmpl
+			if v2, ok := v3Compat[key]; ok {
+				key = v2
+			}
+			if perm, ok := routePermissions[key]; ok {
+				return perm
+			}
+			if _, path, _ := strings.Cut(key, " "); execRoutes[path] {
+				return permExecute // 如 /api/v3/terminals/{id}/connect
+			}
+		}
+	}
+	if execRoutes[r.URL.Path] {
+		return permExecute
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return permRead
+	}
+	return permWrite
+}
+
+// permissionMiddleware rejects requests the calling token has no
+// permission for, so read-only guest devices can watch but not act.
+// It runs after authentication.
+func (s *Server) permissionMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := auth.TokenFromContext(r.Context())
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		perm := requiredPermission(r)
+		if !token.HasPermission(perm) {
+			log.Ctx(r.Context()).Warn().
+				Str("device", token.DeviceID).
+				Str("permission", perm).
+				Msg("Permission denied")
+			WriteError(w, CodeForbidden, http.StatusForbidden, map[string]interface{}{
+				"required_permission": perm,
+				"permissions":         token.Permissions,
+			})
+			return
+		}
+		next(w, r)
+	}
+}
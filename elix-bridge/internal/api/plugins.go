@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"echohelix/bridge/internal/plugin"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxHookContent is the most file content an on_fs_write event carries;
+// larger writes are sent without content and flagged content_truncated
+const maxHookContent = 1 << 20
+
+// setupPlugins starts the hook plugins listed in PLUGINS_FILE (default
+// ~/.echohelix/plugins.json). Plugins can veto requests, session
+// messages, file writes and pairings.
+func (s *Server) setupPlugins() {
+	path := s.configSvc.Get("PLUGINS_FILE")
+	if path == "" {
+		path = filepath.Join(s.echoDir, "plugins.json")
+	}
+	s.plugins = plugin.NewManager(path)
+	if err := s.plugins.Load(); err != nil {
+		log.Warn().Str("component", "plugin").Err(err).Msg("Failed to load plugins")
+	}
+
+	s.authHandler.SetPairingCheck(func(r *http.Request, deviceID, deviceName string) error {
+		return s.plugins.Run(r.Context(), plugin.HookPairing, map[string]string{
+			"device_id":   deviceID,
+			"device_name": deviceName,
+			"remote_addr": r.RemoteAddr,
+		})
+	})
+}
+
+// pluginRequestHook runs the on_request hook before a protected handler.
+// Query values that carry secrets are redacted; bodies are not sent.
+func (s *Server) pluginRequestHook(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.plugins.Has(plugin.HookRequest) {
+			err := s.plugins.Run(r.Context(), plugin.HookRequest, map[string]string{
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"query":       redactQuery(r.URL.RawQuery),
+				"device":      deviceID(r),
+				"remote_addr": r.RemoteAddr,
+			})
+			if err != nil {
+				writePluginError(w, err)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// checkFSWrite runs the on_fs_write hook for a write of content to path
+// (relative to root, or the workspace when root is empty). source says
+// where the write came from: "api", "mcp" or a change's source.
+func (s *Server) checkFSWrite(ctx context.Context, source, root, path, content string) error {
+	if !s.plugins.Has(plugin.HookFSWrite) {
+		return nil
+	}
+	data := map[string]interface{}{
+		"source": source,
+		"path":   path,
+		"size":   len(content),
+	}
+	if root != "" {
+		data["root"] = root
+	}
+	if len(content) <= maxHookContent {
+		data["content"] = content
+	} else {
+		data["content_truncated"] = true
+	}
+	return s.plugins.Run(ctx, plugin.HookFSWrite, data)
+}
+
+// checkSessionMessage runs the on_session_message hook for a message
+// about to be added to a session or queued as a prompt
+func (s *Server) checkSessionMessage(r *http.Request, sessionID, role, content string) error {
+	if !s.plugins.Has(plugin.HookSessionMessage) {
+		return nil
+	}
+	return s.plugins.Run(r.Context(), plugin.HookSessionMessage, map[string]string{
+		"session_id": sessionID,
+		"role":       role,
+		"content":    content,
+		"device":     deviceID(r),
+	})
+}
+
+// writePluginError answers a plugin denial with 403 and any other hook
+// failure with 500
+func writePluginError(w http.ResponseWriter, err error) {
+	var denied *plugin.DeniedError
+	if errors.As(err, &denied) {
+		writeServiceError(w, http.StatusForbidden, err)
+		return
+	}
+	writeServiceError(w, http.StatusInternalServerError, err)
+}
+
+// HandlePluginList lists the configured plugins with their hooks and
+// call counts
+// GET /api/v2/plugins
+func (s *Server) HandlePluginList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"plugins": s.plugins.Status(),
+		"hooks":   plugin.Hooks,
+	})
+}
+
+// HandlePluginReload re-reads the plugin config and restarts the plugins
+// POST /api/v2/plugins/reload
+func (s *Server) HandlePluginReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.plugins.Load(); err != nil {
+		WriteError(w, CodeInvalidRequest, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Ctx(r.Context()).Info().Msg("Reloaded plugins")
+	s.HandlePluginList(w, r)
+}
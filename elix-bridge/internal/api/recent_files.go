@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/recentfiles"
+	"echohelix/bridge/internal/session"
+)
+
+// setupRecentFiles tracks the files read and written through the bridge
+func (s *Server) setupRecentFiles() {
+	s.recentFiles = recentfiles.NewTracker(filepath.Join(s.echoDir, "recent_files.json"))
+}
+
+// trackedRoot is the active workspace, which the fs API's paths are
+// relative to
+func (s *Server) trackedRoot() string {
+	if s.processManager == nil {
+		return ""
+	}
+	return filepath.Clean(s.processManager.WorkDir)
+}
+
+// trackFile records a file the request read or wrote. A session_id query
+// parameter naming an existing session attributes it to that session.
+func (s *Server) trackFile(r *http.Request, op recentfiles.Op, path string) {
+	touch := recentfiles.Touch{Workspace: s.trackedRoot(), Path: s.trackedPath(path), Source: recentfiles.SourceDevice, Op: op}
+	if id := r.URL.Query().Get("session_id"); id != "" {
+		if _, ok := s.sessionMgr.Get(id); ok {
+			touch.SessionID = id
+		}
+	}
+	s.recentFiles.Record(touch)
+}
+
+// trackKernelFiles records the files a kernel event shows the AI reading
+// or changing: applied edits, and completed tool calls with a path
+func (s *Server) trackKernelFiles(sessionID string, ev kernel.Event) {
+	touch := recentfiles.Touch{Workspace: s.trackedRoot(), SessionID: sessionID, Source: recentfiles.SourceKernel}
+	switch {
+	case ev.Edit != nil && ev.Edit.Applied:
+		touch.Op, touch.Path = recentfiles.OpWrite, ev.Edit.Path
+	case ev.ToolCall != nil && ev.ToolCall.Status == "completed":
+		op, ok := toolFileOp(ev.ToolCall.Name)
+		if !ok {
+			return
+		}
+		touch.Op, touch.Path = op, toolFilePath(ev.ToolCall)
+	default:
+		return
+	}
+	if touch.Path == "" {
+		return
+	}
+	touch.Path = s.trackedPath(touch.Path)
+	s.recentFiles.Record(touch)
+}
+
+// toolFileOp guesses from a tool's name whether it reads or writes a file
+func toolFileOp(name string) (recentfiles.Op, bool) {
+	name = strings.ToLower(name)
+	for _, w := range []string{"write", "edit", "replace", "patch", "create"} {
+		if strings.Contains(name, w) {
+			return recentfiles.OpWrite, true
+		}
+	}
+	for _, w := range []string{"read", "view", "open"} {
+		if strings.Contains(name, w) {
+			return recentfiles.OpRead, true
+		}
+	}
+	return "", false
+}
+
+// toolFilePath returns the file a tool call names, under the argument
+// names kernels use for it
+func toolFilePath(call *session.ToolCall) string {
+	for _, key := range []string{"path", "file_path", "filePath", "absolute_path"} {
+		if p, ok := call.Arguments[key].(string); ok && p != "" {
+			return p
+		}
+	}
+	return ""
+}
+
+// trackedPath makes path relative to the workspace, as the fs API takes
+// it; paths outside the workspace stay absolute
+func (s *Server) trackedPath(path string) string {
+	if filepath.IsAbs(path) {
+		if rel, ok := workspaceRel(s.trackedRoot(), path); ok {
+			return rel
+		}
+		return filepath.Clean(path)
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// HandleRecentFiles returns the files most recently read or written
+// through the bridge in a workspace (?id=, the active one by default);
+// ai=true keeps the files the AI changed
+// GET /api/v2/workspace/recent-files?id=&limit=50&ai=false
+func (s *Server) HandleRecentFiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	root := s.trackedRoot()
+	if id := r.URL.Query().Get("id"); id != "" {
+		ws, _, err := s.workspaceSvc.GetStats(id, 1)
+		if err != nil {
+			WriteError(w, CodeNotFound, http.StatusNotFound, err)
+			return
+		}
+		root = filepath.Clean(ws.Path)
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	aiOnly := r.URL.Query().Get("ai") == "true"
+
+	files := s.recentFiles.Recent(root, limit, aiOnly)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workspace": root,
+		"files":     files,
+		"count":     len(files),
+	})
+}
+
+// HandleSessionFiles returns the files a session read or changed, most
+// recent first, so the app can jump to what the AI touched
+// GET /api/v2/session/files?id=
+func (s *Server) HandleSessionFiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
+		return
+	}
+	if _, ok := s.sessionMgr.Get(id); !ok {
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+		return
+	}
+
+	root, files := s.recentFiles.Session(id)
+	var changed, read int
+	for _, f := range files {
+		if f.Writes > 0 {
+			changed++
+		} else {
+			read++
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id": id,
+		"workspace":  root,
+		"files":      files,
+		"changed":    changed,
+		"read":       read,
+	})
+}
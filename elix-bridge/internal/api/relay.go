@@ -0,0 +1,74 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"echohelix/bridge/internal/relay"
+
+	"github.com/rs/zerolog/log"
+)
+
+// listenRelay connects to RELAY_URL when configured. Relayed connections
+// are served like LAN ones, so device tokens are still required.
+func (s *Server) listenRelay() (net.Listener, error) {
+	relayURL := s.configSvc.Get("RELAY_URL")
+	if relayURL == "" {
+		return nil, nil
+	}
+
+	client, err := relay.NewClient(relay.ClientConfig{
+		URL:      relayURL,
+		BridgeID: s.relayID(),
+		Secret:   s.configSvc.Get("RELAY_SECRET"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.relayClient = client
+	log.Info().Str("relay", relayURL).Str("public_url", client.PublicURL()).Msg("Relay tunnel enabled")
+	return client, nil
+}
+
+// relayID returns RELAY_ID, or a random ID generated once and kept in
+// the data directory so the phone's relay URL stays stable and is not
+// guessable
+func (s *Server) relayID() string {
+	if id := s.configSvc.Get("RELAY_ID"); id != "" {
+		return id
+	}
+
+	path := filepath.Join(s.echoDir, "relay_id")
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id
+		}
+	}
+
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	id := hex.EncodeToString(bytes)
+	if err := os.WriteFile(path, []byte(id+"\n"), 0600); err != nil {
+		log.Warn().Err(err).Msg("Failed to save relay ID")
+	}
+	return id
+}
+
+// checkRelay reports the tunnel state
+func (s *Server) checkRelay() ComponentHealth {
+	st := s.relayClient.Status()
+	if !st.Connected {
+		return ComponentHealth{Status: healthDegraded, Message: "relay disconnected", Details: st}
+	}
+	return ComponentHealth{Status: healthOK, Message: "connected via " + st.PublicURL, Details: st}
+}
+
+// isRelayRequest reports whether r arrived through the relay tunnel
+func isRelayRequest(r *http.Request) bool {
+	return r.RemoteAddr == relay.RelayedAddr
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/session"
+
+	"github.com/rs/zerolog/log"
+)
+
+// retentionInterval is how often the retention sweep runs
+const retentionInterval = time.Hour
+
+// retentionPolicy bounds how much the data directory keeps. Every limit
+// is off (0) by default:
+//
+//	SESSION_RETENTION_DAYS    sessions idle this long are archived or deleted
+//	SESSION_RETENTION_ACTION  "archive" (default) or "delete"
+//	SESSION_STORAGE_MAX_MB    cap on sessions plus archive; oldest go first
+//	BACKUP_MAX_MB             cap on stored backups, on top of BACKUP_KEEP
+//	LOG_RETENTION_DAYS        rotated log files older than this are removed
+//	LOG_MAX_MB                log files above this are rotated
+type retentionPolicy struct {
+	SessionDays     int    `json:"session_days"`
+	SessionAction   string `json:"session_action"`
+	SessionMaxBytes int64  `json:"session_max_bytes"`
+	BackupMaxBytes  int64  `json:"backup_max_bytes"`
+	LogDays         int    `json:"log_days"`
+	LogMaxBytes     int64  `json:"log_max_bytes"`
+}
+
+func (p retentionPolicy) enabled() bool {
+	return p.SessionDays > 0 || p.SessionMaxBytes > 0 || p.BackupMaxBytes > 0 || p.LogDays > 0 || p.LogMaxBytes > 0
+}
+
+// retentionSweep records what one sweep removed
+type retentionSweep struct {
+	At               time.Time `json:"at"`
+	ArchivedSessions []string  `json:"archived_sessions,omitempty"`
+	DeletedSessions  []string  `json:"deleted_sessions,omitempty"`
+	DeletedArchives  []string  `json:"deleted_archives,omitempty"`
+	DeletedBackups   []string  `json:"deleted_backups,omitempty"`
+	PrunedLogs       []string  `json:"pruned_logs,omitempty"`
+}
+
+// retention runs the sweep in the background
+type retention struct {
+	policy retentionPolicy
+	mu     sync.Mutex
+	last   *retentionSweep
+}
+
+// setupRetention reads the retention policy; Run starts the hourly sweep
+// when any limit is set
+func (s *Server) setupRetention() {
+	p := retentionPolicy{
+		SessionDays:     s.configInt("SESSION_RETENTION_DAYS"),
+		SessionAction:   "archive",
+		SessionMaxBytes: int64(s.configInt("SESSION_STORAGE_MAX_MB")) << 20,
+		BackupMaxBytes:  int64(s.configInt("BACKUP_MAX_MB")) << 20,
+		LogDays:         s.configInt("LOG_RETENTION_DAYS"),
+		LogMaxBytes:     int64(s.configInt("LOG_MAX_MB")) << 20,
+	}
+	switch action := s.configSvc.Get("SESSION_RETENTION_ACTION"); action {
+	case "", "archive":
+	case "delete":
+		p.SessionAction = action
+	default:
+		log.Warn().Str("value", action).Msg("Invalid SESSION_RETENTION_ACTION, archiving")
+	}
+
+	s.retention = &retention{policy: p}
+}
+
+// configInt reads a non-negative integer setting, 0 when unset or invalid
+func (s *Server) configInt(key string) int {
+	v := s.configSvc.Get(key)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Warn().Str("key", key).Str("value", v).Msg("Invalid number, ignoring")
+		return 0
+	}
+	return n
+}
+
+// retentionLoop sweeps every retentionInterval until ctx is done
+func (s *Server) retentionLoop(ctx context.Context) error {
+	// 启动后稍等再清理，避免拖慢启动
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			s.sweepRetention()
+			timer.Reset(retentionInterval)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// sweepRetention applies the retention policy once
+func (s *Server) sweepRetention() *retentionSweep {
+	r := s.retention
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sweep := &retentionSweep{At: time.Now()}
+	s.sweepSessions(r.policy, sweep)
+	if r.policy.BackupMaxBytes > 0 {
+		sweep.DeletedBackups = s.backups.Trim(r.policy.BackupMaxBytes)
+	}
+	s.sweepLogs(r.policy, sweep)
+	r.last = sweep
+
+	removed := len(sweep.ArchivedSessions) + len(sweep.DeletedSessions) + len(sweep.DeletedArchives) + len(sweep.DeletedBackups) + len(sweep.PrunedLogs)
+	if removed > 0 {
+		log.Info().
+			Int("archived_sessions", len(sweep.ArchivedSessions)).
+			Int("deleted_sessions", len(sweep.DeletedSessions)).
+			Int("deleted_archives", len(sweep.DeletedArchives)).
+			Int("deleted_backups", len(sweep.DeletedBackups)).
+			Int("pruned_logs", len(sweep.PrunedLogs)).
+			Msg("Retention sweep")
+	}
+	return sweep
+}
+
+// sweepSessions expires idle sessions, then trims the oldest archives and
+// sessions until storage fits the cap. Active sessions are never touched.
+func (s *Server) sweepSessions(p retentionPolicy, sweep *retentionSweep) {
+	sessions := s.sessionMgr.List()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.Before(sessions[j].UpdatedAt)
+	})
+
+	expire := func(sess *session.Session) bool {
+		if p.SessionAction == "delete" {
+			if s.sessionMgr.Delete(sess.ID) {
+				sweep.DeletedSessions = append(sweep.DeletedSessions, sess.ID)
+				return true
+			}
+			return false
+		}
+		if err := s.sessionMgr.Archive(sess.ID); err != nil {
+			log.Warn().Err(err).Str("session", sess.ID).Msg("Failed to archive session")
+			return false
+		}
+		sweep.ArchivedSessions = append(sweep.ArchivedSessions, sess.ID)
+		return true
+	}
+
+	live := sessions[:0]
+	cutoff := time.Now().AddDate(0, 0, -p.SessionDays)
+	for _, sess := range sessions {
+		if p.SessionDays > 0 && sess.Status != session.StatusActive && sess.UpdatedAt.Before(cutoff) && expire(sess) {
+			continue
+		}
+		live = append(live, sess)
+	}
+
+	if p.SessionMaxBytes <= 0 {
+		return
+	}
+	archived, err := s.sessionMgr.ListArchived()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list archived sessions")
+		return
+	}
+	var total int64
+	for _, a := range archived {
+		total += a.Size
+	}
+	for _, sess := range live {
+		total += s.sessionMgr.DiskUsage(sess.ID)
+	}
+
+	for _, a := range archived {
+		if total <= p.SessionMaxBytes {
+			return
+		}
+		if err := s.sessionMgr.DeleteArchived(a.ID); err == nil {
+			total -= a.Size
+			sweep.DeletedArchives = append(sweep.DeletedArchives, a.ID)
+		}
+	}
+	// 归档仍超限时删除最旧的会话
+	for _, sess := range live {
+		if total <= p.SessionMaxBytes {
+			return
+		}
+		if sess.Status == session.StatusActive {
+			continue
+		}
+		size := s.sessionMgr.DiskUsage(sess.ID)
+		if s.sessionMgr.Delete(sess.ID) {
+			total -= size
+			sweep.DeletedSessions = append(sweep.DeletedSessions, sess.ID)
+		}
+	}
+}
+
+// sweepLogs rotates oversized logs and removes old rotated ones in
+// ~/.echohelix/logs and the access log
+func (s *Server) sweepLogs(p retentionPolicy, sweep *retentionSweep) {
+	logDir := filepath.Join(s.echoDir, "logs")
+	files, _ := filepath.Glob(filepath.Join(logDir, "*.log"))
+	if path := s.configSvc.Get("ACCESS_LOG_FILE"); path != "" {
+		files = append(files, path)
+	}
+
+	if p.LogMaxBytes > 0 {
+		for _, path := range files {
+			if info, err := os.Stat(path); err == nil && info.Size() > p.LogMaxBytes {
+				if err := rotateLog(path, p.LogMaxBytes/2); err != nil {
+					log.Warn().Err(err).Str("file", path).Msg("Failed to rotate log")
+					continue
+				}
+				sweep.PrunedLogs = append(sweep.PrunedLogs, path)
+			}
+		}
+	}
+
+	if p.LogDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -p.LogDays)
+		rotated, _ := filepath.Glob(filepath.Join(logDir, "*.log.*"))
+		for _, path := range rotated {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				if os.Remove(path) == nil {
+					sweep.PrunedLogs = append(sweep.PrunedLogs, path)
+				}
+			}
+		}
+	}
+}
+
+// rotateLog keeps the last keep bytes of a log in <path>.1 and empties
+// it in place, since the bridge (or its service manager) holds it open
+// for appending
+func rotateLog(path string, keep int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if offset := info.Size() - keep; offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	out, err := os.OpenFile(path+".1", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, f); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Truncate(path, 0)
+}
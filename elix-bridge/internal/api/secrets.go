@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"echohelix/bridge/internal/secrets"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxScannedFile is the largest attached file scanned for secrets
+const maxScannedFile = 4 << 20
+
+// secretScanner returns the scanner for a workspace: the policy in its
+// .echohelix/secrets.json, else SECRET_SCAN (off, warn, mask or block;
+// default warn)
+func (s *Server) secretScanner(workspace string) *secrets.Scanner {
+	var cfg secrets.Config
+	if workspace != "" {
+		wsCfg, ok, err := secrets.LoadWorkspace(workspace)
+		if err != nil {
+			log.Warn().Err(err).Str("workspace", workspace).Msg("Ignoring workspace secret policy")
+		} else if ok {
+			cfg = wsCfg
+		}
+	}
+	if cfg.Policy == "" {
+		cfg.Policy = s.configSvc.Get("SECRET_SCAN")
+	}
+	scanner, err := secrets.New(cfg)
+	if err != nil {
+		log.Warn().Err(err).Str("workspace", workspace).Msg("Invalid secret policy, warning only")
+		scanner, _ = secrets.New(secrets.Config{})
+	}
+	return scanner
+}
+
+// filterOutbound applies the workspace's secret policy to text about to
+// be sent to a kernel, returning the text to send. source names the
+// content in logs, events and errors ("prompt", "file read", ...).
+func (s *Server) filterOutbound(ctx context.Context, workspace, source, text string) (string, error) {
+	scanner := s.secretScanner(workspace)
+	out, found, err := scanner.Filter(source, text)
+	if len(found) > 0 {
+		s.reportSecrets(ctx, scanner.Policy, workspace, source, found)
+	}
+	return out, err
+}
+
+// checkOutboundFiles scans files a kernel is asked to read itself. They
+// cannot be masked in transit, so under the mask policy they are blocked
+// like under block.
+func (s *Server) checkOutboundFiles(ctx context.Context, workspace string, files []string) error {
+	scanner := s.secretScanner(workspace)
+	if scanner.Policy == secrets.PolicyOff {
+		return nil
+	}
+	for _, file := range files {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workspace, file)
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() || info.Size() > maxScannedFile {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		found := scanner.Scan(string(data))
+		if len(found) == 0 {
+			continue
+		}
+		s.reportSecrets(ctx, scanner.Policy, workspace, file, found)
+		if scanner.Policy != secrets.PolicyWarn {
+			return &secrets.BlockedError{Code: "SECRET_DETECTED", Source: file, Findings: found}
+		}
+	}
+	return nil
+}
+
+// reportSecrets logs and announces secrets found in outbound content
+func (s *Server) reportSecrets(ctx context.Context, policy, workspace, source string, found []secrets.Finding) {
+	log.Ctx(ctx).Warn().
+		Str("policy", policy).
+		Str("source", source).
+		Int("findings", len(found)).
+		Msg("Secrets detected in content sent to kernel")
+	s.eventBus.Publish("secrets.detected", map[string]interface{}{
+		"policy":    policy,
+		"workspace": workspace,
+		"source":    source,
+		"findings":  found,
+	})
+}
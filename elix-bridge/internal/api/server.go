@@ -2,19 +2,63 @@ package api
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"echohelix/bridge/internal/agent"
 	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/backup"
+	"echohelix/bridge/internal/changes"
 	"echohelix/bridge/internal/config"
+	"echohelix/bridge/internal/contextpack"
+	"echohelix/bridge/internal/crash"
+	"echohelix/bridge/internal/danger"
 	"echohelix/bridge/internal/dashboard"
+	"echohelix/bridge/internal/e2e"
+	"echohelix/bridge/internal/events"
+	"echohelix/bridge/internal/forward"
+	"echohelix/bridge/internal/fs"
+	"echohelix/bridge/internal/fslock"
+	"echohelix/bridge/internal/git"
+	"echohelix/bridge/internal/installer"
+	"echohelix/bridge/internal/jobs"
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/kernel/aider"
+	"echohelix/bridge/internal/kernel/echo"
+	"echohelix/bridge/internal/kernel/gemini"
+	"echohelix/bridge/internal/lifecycle"
+	"echohelix/bridge/internal/lsp"
+	"echohelix/bridge/internal/mcp"
+	"echohelix/bridge/internal/metrics"
+	"echohelix/bridge/internal/notify"
+	"echohelix/bridge/internal/plugin"
 	"echohelix/bridge/internal/process"
+	"echohelix/bridge/internal/prompts"
+	"echohelix/bridge/internal/providers"
+	"echohelix/bridge/internal/ratelimit"
+	"echohelix/bridge/internal/recentfiles"
+	"echohelix/bridge/internal/relay"
+	"echohelix/bridge/internal/remote"
+	"echohelix/bridge/internal/semantic"
 	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/shell"
+	"echohelix/bridge/internal/statefile"
+	"echohelix/bridge/internal/stats"
+	"echohelix/bridge/internal/symbols"
+	"echohelix/bridge/internal/telemetry"
+	"echohelix/bridge/internal/terminal"
+	"echohelix/bridge/internal/usage"
+	"echohelix/bridge/internal/vault"
 	"echohelix/bridge/internal/workspace"
 
 	"github.com/gorilla/mux"
-	"github.com/rs/cors"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -22,37 +66,155 @@ type Server struct {
 	router           *mux.Router
 	httpServer       *http.Server
 	processManager   *process.Manager
+	authService      *auth.Service
 	authHandler      *auth.Handler
 	sessionMgr       *session.Manager
 	workspaceSvc     *workspace.Service
 	configSvc        *config.Service
 	dashboardHandler *dashboard.Handler
+	dashboardLogger  *dashboard.Logger
+	activity         *activityTracker
+	remotePool       *remote.Pool
+	checkpointer     *git.Checkpointer
+	shellRunner      *shell.Runner
+	terminalMgr      *terminal.Manager
+	jobMgr           *jobs.Manager
+	notifySvc        *notify.Service
+	eventBus         *events.Bus
+	accessLog        *zerolog.Logger
+	rateLimits       map[string]*ratelimit.Limiter
+	insecureCORS     bool
+	socketFile       string
+	relayClient      *relay.Client
+	e2eIdentity      *e2e.Identity
+	e2eReplay        *e2e.ReplayGuard
+	metrics          *metrics.Collector
+	providerRegistry *providers.Registry
+	promptStore      *prompts.Store
+	changeQueue      *changes.Queue
+	backups          *backup.Rotator
+	retention        *retention
+	telemetry        *telemetry.Recorder
+	crashes          *crash.Reporter
+	forwards         *forward.Manager
+	mcpServer        *mcp.Server
+	mcpPool          *mcp.Pool
+	plugins          *plugin.Manager
+	confirmer        *danger.Confirmer
+	shares           *session.ShareStore
+	drafts           *session.DraftStore
+	deleteThreshold  int
+	lspMgr           *lsp.Manager
+	symbolMu         sync.Mutex
+	symbolIndex      *symbols.Index
+	contextPacks     *contextpack.Builder
+	semanticMu       sync.Mutex
+	semanticIdx      *semantic.Index
+	semanticJob      string
+	systemInfoMu     sync.Mutex
+	systemInfo       *SystemInfo
+	installMu        sync.Mutex
+	installs         map[string]*kernelInstall
+	kernelVersions   *installer.VersionStore
+	kernelAdapters   map[string]kernel.Adapter
+	promptQueue      *session.PromptQueue
+	promptMu         sync.Mutex
+	promptJob        string
+	agentTasks       *agent.Store
+	usageLedger      *usage.Ledger
+	stats            *stats.Aggregator
+	recentFiles      *recentfiles.Tracker
+	locks            *fslock.Service
+	fsCache          *fs.ListCache
+	fileHashes       *fs.HashCache
+	limits           httpLimits
+	acl              networkACL
+	idempotency      *idempotencyCache
+	webSockets       atomic.Int64
+	upgrader         *websocket.Upgrader
+	echoDir          string
+	dataVault        *vault.Vault
+	startedAt        time.Time
+
+	openapiOnce   sync.Once
+	openapiSpec   []byte
+	openapiV3Once sync.Once
+	openapiV3Spec []byte
+}
+
+// ServerConfig locates the bridge's files. Empty fields use the defaults,
+// so embedders and tests can keep their data apart from an installed
+// bridge.
+type ServerConfig struct {
+	DataDir string // 默认 ~/.echohelix
+	EnvFile string // 默认当前目录下的 .env
+}
+
+func NewServer(pm *process.Manager) (*Server, error) {
+	return NewServerWithConfig(pm, ServerConfig{})
 }
 
-func NewServer(pm *process.Manager) *Server {
+// NewServerWithConfig creates a server storing its data under cfg.DataDir.
+// It fails when the data directory cannot be opened, such as an encrypted
+// one without its key.
+func NewServerWithConfig(pm *process.Manager, cfg ServerConfig) (*Server, error) {
 	// Get home directory for storage
-	homeDir, _ := os.UserHomeDir()
-	echoDir := filepath.Join(homeDir, ".echohelix")
+	echoDir := cfg.DataDir
+	if echoDir == "" {
+		homeDir, _ := os.UserHomeDir()
+		echoDir = filepath.Join(homeDir, ".echohelix")
+	}
+
+	// Initialize Config Service
+	envFile := cfg.EnvFile
+	if envFile == "" {
+		envFile = ".env"
+	}
+	configSvc := config.NewService(envFile)
+
+	// 先套用上次暂存的恢复并升级数据目录格式，再由各服务加载
+	applyStagedRestore(echoDir, configSvc)
+	if err := migrateDataDir(echoDir, configSvc); err != nil {
+		return nil, err
+	}
+	dataVault, vaultCreated, err := openDataVault(echoDir, configSvc)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize Auth Service
 	authConfig := auth.DefaultConfig()
 	authConfig.StoragePath = filepath.Join(echoDir, "auth.json")
+	authConfig.Vault = dataVault
 	authService := auth.NewService(authConfig)
 	authHandler := auth.NewHandler(authService)
+	authHandler.SetApprovalRequired(func() bool { return configSvc.Get("PAIRING_APPROVAL") == "true" })
+	authHandler.SetTokenBinding(func() string { return configSvc.Get("TOKEN_BINDING") })
+	authHandler.SetClientIP(clientIP)
 
 	// Initialize Session Manager
 	sessionConfig := session.ManagerConfig{
 		StorageDir: filepath.Join(echoDir, "sessions"),
 		AutoSave:   true,
+		Vault:      dataVault,
 	}
 	sessionMgr := session.NewManagerWithConfig(sessionConfig)
 
+	// 刚启用加密时，立即以密文重写已有的明文数据
+	if vaultCreated {
+		if err := authService.SaveState(); err != nil {
+			log.Warn().Err(err).Msg("Failed to encrypt auth state")
+		} else {
+			os.Remove(authConfig.StoragePath + statefile.BackupSuffix) // 明文的旧副本
+		}
+		if err := sessionMgr.SaveAll(); err != nil {
+			log.Warn().Err(err).Msg("Failed to encrypt sessions")
+		}
+	}
+
 	// Initialize Workspace Service
 	workspaceSvc := workspace.NewService(echoDir)
 
-	// Initialize Config Service
-	configSvc := config.NewService(".env")
-
 	// Initialize Dashboard
 	dashboardLogger := dashboard.NewLogger(500)
 	dashboardHandler := dashboard.NewHandler(dashboardLogger, authService)
@@ -60,14 +222,154 @@ func NewServer(pm *process.Manager) *Server {
 	s := &Server{
 		router:           mux.NewRouter(),
 		processManager:   pm,
+		authService:      authService,
 		authHandler:      authHandler,
 		sessionMgr:       sessionMgr,
 		workspaceSvc:     workspaceSvc,
 		configSvc:        configSvc,
 		dashboardHandler: dashboardHandler,
+		dashboardLogger:  dashboardLogger,
+		remotePool:       remote.NewPool(),
+		checkpointer:     git.NewCheckpointer(0),
+		shellRunner:      shell.NewRunner(filepath.Join(echoDir, "exec_runs.json"), 100),
+		terminalMgr:      terminal.NewManager(terminal.ManagerConfig{}),
+		jobMgr: jobs.NewManager(jobs.ManagerConfig{
+			StoragePath: filepath.Join(echoDir, "jobs.json"),
+		}),
+		eventBus:       events.NewBus(),
+		activity:       newActivityTracker(),
+		contextPacks:   contextpack.NewBuilder(),
+		installs:       make(map[string]*kernelInstall),
+		kernelVersions: installer.NewVersionStore(filepath.Join(echoDir, "kernels.json")),
+		kernelAdapters: map[string]kernel.Adapter{
+			aider.Name:  aider.New(),
+			echo.Name:   echo.New(),
+			gemini.Name: gemini.New(),
+		},
+		promptQueue: session.NewPromptQueue(filepath.Join(echoDir, "prompt_queue.json")),
+		agentTasks:  agent.NewStore(filepath.Join(echoDir, "agent_tasks.json")),
+		fsCache:     fs.NewListCache(30*time.Second, 64),
+		fileHashes:  fs.NewHashCache(1024),
+		echoDir:     echoDir,
+		dataVault:   dataVault,
+		startedAt:   time.Now(),
+	}
+	s.notifySvc = notify.NewService(authService, s.pushSender)
+	s.providerRegistry = providers.NewRegistry(filepath.Join(echoDir, "models.json"), configSvc.Get)
+	s.promptStore = prompts.NewStore(echoDir)
+	s.changeQueue = changes.NewQueue(filepath.Join(echoDir, "changes.json"))
+	s.shares = session.NewShareStore(filepath.Join(echoDir, "shares.json"), dataVault)
+	s.drafts = session.NewDraftStore(filepath.Join(echoDir, "drafts.json"), dataVault)
+	s.setupLogging()
+	s.setupLocales()
+	s.setupCrashReporting()
+	s.setupRateLimits()
+	s.setupHTTPLimits()
+	s.setupNetworkACL()
+	s.setupIdempotency()
+	s.upgrader = s.newUpgrader()
+	if pm != nil {
+		// 内置 echo 内核，无需 API key 或外部核心即可离线开发
+		pm.RegisterBuiltin(echo.Name, func() http.Handler { return echo.NewServer() })
 	}
+	s.setupE2E()
+	s.setupEvents()
+	s.setupDashboard()
+	s.setupUsage()
+	s.setupStats()
+	s.setupRecentFiles()
+	s.setupLocks()
+	s.setupMetrics()
+	s.setupTelemetry()
+	s.setupMCP()
+	s.setupMCPPool()
+	s.setupPlugins()
+	s.setupConfirmations()
+	s.setupLSP()
+	s.setupBackups()
+	s.setupRetention()
+	s.setupForwarding()
+	s.setupNotifications()
 	s.setupRoutes()
-	return s
+	s.setupV3Routes()
+	s.runPromptQueue()
+	return s, nil
+}
+
+// setupEvents bridges subsystem callbacks onto the event bus
+func (s *Server) setupEvents() {
+	s.authService.OnPairingComplete(func(deviceID, deviceName string) {
+		s.eventBus.Publish("auth.paired", map[string]string{
+			"device_id":   deviceID,
+			"device_name": deviceName,
+		})
+	})
+	s.authHandler.SetActivity(func(deviceID string) interface{} {
+		return s.activity.summary(deviceID, s.startedAt)
+	})
+	s.authService.OnPairingRequested(func(req auth.PairingRequest) {
+		s.eventBus.Publish("auth.pairing_requested", req)
+	})
+	s.authService.OnNetworkChanged(func(deviceID, bound, ip string) {
+		s.eventBus.Publish("auth.network_changed", map[string]string{
+			"device_id": deviceID,
+			"bound":     bound,
+			"ip":        ip,
+		})
+	})
+
+	if s.processManager != nil {
+		s.processManager.OnExit(func(kernel string, err error) {
+			data := map[string]string{"kernel": kernel}
+			if err != nil {
+				data["error"] = err.Error()
+			}
+			s.eventBus.Publish("process.crashed", data)
+			s.telemetry.Error("kernel_crash")
+			if err != nil {
+				s.reportCrash(crash.KindKernel, err.Error(), nil, map[string]interface{}{
+					"kernel": kernel,
+					"status": s.processManager.Status(),
+				})
+			}
+		})
+	}
+
+	jobEvents, _ := s.jobMgr.Subscribe()
+	go func() {
+		for ev := range jobEvents {
+			s.eventBus.Publish(ev.Type, ev.Job)
+		}
+	}()
+}
+
+// setupNotifications forwards job completions and kernel crashes to push
+func (s *Server) setupNotifications() {
+	sub := s.eventBus.Subscribe("job.finished", "process.crashed")
+	go func() {
+		for ev := range sub.C {
+			switch data := ev.Data.(type) {
+			case jobs.Job:
+				s.notifySvc.Notify(notify.Notification{
+					Category: notify.CategoryTaskFinished,
+					Title:    data.Kind + " " + string(data.Status),
+					Body:     data.Message,
+					Data:     map[string]string{"job_id": data.ID},
+				})
+			case map[string]string:
+				body := data["error"]
+				if body == "" {
+					body = "The kernel process stopped unexpectedly"
+				}
+				s.notifySvc.Notify(notify.Notification{
+					Category: notify.CategoryKernelCrashed,
+					Title:    data["kernel"] + " kernel crashed",
+					Body:     body,
+					Data:     map[string]string{"kernel": data["kernel"]},
+				})
+			}
+		}
+	}()
 }
 
 func (s *Server) setupRoutes() {
@@ -75,33 +377,95 @@ func (s *Server) setupRoutes() {
 	v2 := s.router.PathPrefix("/api/v2").Subrouter()
 
 	// Health Check
-	v2.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	}).Methods("GET")
+	v2.HandleFunc("/health", s.HandleHealth).Methods("GET")
+	v2.HandleFunc("/health/details", s.protect(s.HandleHealthDetails)).Methods("GET")
+
+	// API Docs (Public)
+	v2.HandleFunc("/openapi.json", s.HandleOpenAPI).Methods("GET")
+	v2.HandleFunc("/docs", s.HandleSwaggerUI).Methods("GET")
 
 	// Auth API (Public)
 	v2.HandleFunc("/auth/pair", s.authHandler.HandlePair).Methods("POST")
+	v2.HandleFunc("/auth/pair/status", s.authHandler.HandlePairStatus).Methods("GET")
+	v2.HandleFunc("/auth/pair/requests", s.authHandler.HandlePairRequests).Methods("GET")
+	v2.HandleFunc("/auth/pair/approve", s.authHandler.HandlePairApprove).Methods("POST")
+	v2.HandleFunc("/auth/pair/deny", s.authHandler.HandlePairDeny).Methods("POST")
 	v2.HandleFunc("/auth/code", s.authHandler.HandleGenerateCode).Methods("POST")
 	v2.HandleFunc("/auth/status", s.authHandler.HandleStatus).Methods("GET")
 
-	// Dashboard (Public)
-	s.router.HandleFunc("/dashboard", s.dashboardHandler.HandleDashboard).Methods("GET")
-	s.router.HandleFunc("/dashboard/logs", s.dashboardHandler.HandleGetLogs).Methods("GET")
-	s.router.HandleFunc("/dashboard/pairing/refresh", s.dashboardHandler.HandleRefreshPairingCode).Methods("POST")
+	// Shared session links (the token is the credential)
+	s.router.HandleFunc("/share", s.HandleSharedSession).Methods("GET")
+
+	// Dashboard (localhost or DASHBOARD_TOKEN)
+	dash := s.dashboardAuth
+	s.router.HandleFunc("/dashboard", dash(s.dashboardHandler.HandleDashboard)).Methods("GET")
+	s.router.PathPrefix("/dashboard/static/").HandlerFunc(dash(s.dashboardHandler.HandleStatic)).Methods("GET")
+	s.router.HandleFunc("/dashboard/logs", dash(s.dashboardHandler.HandleGetLogs)).Methods("GET")
+	s.router.HandleFunc("/dashboard/logs/stream", dash(s.dashboardHandler.HandleLogStream)).Methods("GET")
+	s.router.HandleFunc("/dashboard/events", dash(s.dashboardHandler.HandleEventStream)).Methods("GET")
+	s.router.HandleFunc("/dashboard/pairing/requests", dash(s.dashboardHandler.HandlePairingRequests)).Methods("GET")
+	s.router.HandleFunc("/dashboard/pairing/approve", dash(s.dashboardHandler.HandleApprovePairing)).Methods("POST")
+	s.router.HandleFunc("/dashboard/pairing/deny", dash(s.dashboardHandler.HandleDenyPairing)).Methods("POST")
+	s.router.HandleFunc("/dashboard/pairing/refresh", dash(s.dashboardHandler.HandleRefreshPairingCode)).Methods("POST")
+	s.router.HandleFunc("/dashboard/devices", dash(s.dashboardHandler.HandleListDevices)).Methods("GET")
+	s.router.HandleFunc("/dashboard/devices", dash(s.dashboardHandler.HandleRevokeDevice)).Methods("DELETE")
+	s.router.HandleFunc("/dashboard/sessions", dash(s.dashboardHandler.HandleListSessions)).Methods("GET")
+	s.router.HandleFunc("/dashboard/sessions/transcript", dash(s.dashboardHandler.HandleSessionTranscript)).Methods("GET")
+	s.router.HandleFunc("/dashboard/kernel", dash(s.dashboardHandler.HandleKernelStatus)).Methods("GET")
+	s.router.HandleFunc("/dashboard/kernel/start", dash(s.dashboardHandler.HandleKernelStart)).Methods("POST")
+	s.router.HandleFunc("/dashboard/kernel/stop", dash(s.dashboardHandler.HandleKernelStop)).Methods("POST")
+	s.router.HandleFunc("/dashboard/metrics", dash(s.dashboardHandler.HandleMetricsPage)).Methods("GET")
+	s.router.HandleFunc("/dashboard/metrics/data", dash(s.dashboardHandler.HandleMetrics)).Methods("GET")
+	s.router.HandleFunc("/dashboard/usage", dash(s.dashboardHandler.HandleUsage)).Methods("GET")
+	s.router.HandleFunc("/dashboard/stats", dash(s.dashboardHandler.HandleStats)).Methods("GET")
 
 	// Protected Routes Wrapper
-	protect := s.authHandler.AuthenticateMiddleware
+	protect := s.protect
 
 	// Process Management (Protected)
 	v2.HandleFunc("/process/stop", protect(s.HandleProcessStop)).Methods("POST")
 	v2.HandleFunc("/process/start", protect(s.HandleProcessStart)).Methods("POST")
+	v2.HandleFunc("/process/stats", protect(s.HandleProcessStats)).Methods("GET")
+	v2.HandleFunc("/system/info", protect(s.HandleSystemInfo)).Methods("GET")
+	v2.HandleFunc("/kernels/{name}/install", protect(s.HandleKernelInstall)).Methods("POST")
+	v2.HandleFunc("/kernels/{name}/install", protect(s.HandleKernelInstallStatus)).Methods("GET")
+	v2.HandleFunc("/kernels/{name}/version", protect(s.HandleKernelVersion)).Methods("GET")
+	v2.HandleFunc("/kernels/{name}/upgrade", protect(s.HandleKernelUpgrade)).Methods("POST")
+	v2.HandleFunc("/kernels/{name}/rollback", protect(s.HandleKernelRollback)).Methods("POST")
+	v2.HandleFunc("/kernels/{name}/capabilities", protect(s.HandleKernelCapabilities)).Methods("GET")
+	v2.HandleFunc("/providers", protect(s.HandleProviderList)).Methods("GET")
+	v2.HandleFunc("/providers/validate", protect(s.HandleProviderValidate)).Methods("POST")
+	v2.HandleFunc("/models", protect(s.HandleModelList)).Methods("GET")
 
 	// Chat Proxy (Protected)
 	// Note: Websocket auth usually via query param, handled directly in handler or via middleware
 	// We'll trust the middleware to check query param token too
 	v2.HandleFunc("/chat/proxy", protect(s.HandleChatProxy))
 
+	// Code intelligence (language servers)
+	v2.HandleFunc("/lsp/hover", protect(s.HandleLSPHover)).Methods("GET")
+	v2.HandleFunc("/lsp/definition", protect(s.HandleLSPDefinition)).Methods("GET")
+	v2.HandleFunc("/lsp/diagnostics", protect(s.HandleLSPDiagnostics)).Methods("GET")
+	v2.HandleFunc("/lsp/servers", protect(s.HandleLSPServers)).Methods("GET")
+	v2.HandleFunc("/lsp/ws", protect(s.HandleLSPSocket)).Methods("GET")
+
+	// Code navigation
+	v2.HandleFunc("/code/outline", protect(s.HandleCodeOutline)).Methods("GET")
+	v2.HandleFunc("/code/symbols", protect(s.HandleCodeSymbols)).Methods("GET")
+	v2.HandleFunc("/code/symbols/index", protect(s.HandleCodeSymbolIndex)).Methods("POST")
+	v2.HandleFunc("/context/pack", protect(s.HandleContextPack)).Methods("POST")
+	v2.HandleFunc("/search/semantic", protect(s.HandleSemanticSearch)).Methods("GET")
+	v2.HandleFunc("/search/semantic/index", protect(s.HandleSemanticIndex)).Methods("POST")
+	v2.HandleFunc("/search/semantic/status", protect(s.HandleSemanticStatus)).Methods("GET")
+
+	// MCP (Model Context Protocol) tool server
+	v2.HandleFunc("/mcp", protect(s.HandleMCP)).Methods("POST")
+	v2.HandleFunc("/mcp/servers", protect(s.HandleMCPServers)).Methods("GET")
+	v2.HandleFunc("/mcp/servers/reload", protect(s.HandleMCPServersReload)).Methods("POST")
+	v2.HandleFunc("/plugins", protect(s.HandlePluginList)).Methods("GET")
+	v2.HandleFunc("/plugins/reload", protect(s.HandlePluginReload)).Methods("POST")
+	v2.HandleFunc("/confirm", protect(s.HandleConfirm)).Methods("POST")
+
 	// File System (Protected)
 	v2.HandleFunc("/fs/ls", protect(s.HandleFSList)).Methods("GET")
 	v2.HandleFunc("/fs/file", protect(s.HandleFile)).Methods("GET")
@@ -109,6 +473,13 @@ func (s *Server) setupRoutes() {
 	v2.HandleFunc("/fs/roots", protect(s.HandleRoots)).Methods("GET")
 	v2.HandleFunc("/fs/stat", protect(s.HandleStat)).Methods("GET")
 	v2.HandleFunc("/fs/exists", protect(s.HandleExists)).Methods("GET")
+	v2.HandleFunc("/fs/sync", protect(s.HandleFSSync)).Methods("POST")
+	v2.HandleFunc("/fs/lock", protect(s.HandleFSLock)).Methods("POST")
+	v2.HandleFunc("/fs/lock/heartbeat", protect(s.HandleFSLockHeartbeat)).Methods("POST")
+	v2.HandleFunc("/fs/lock", protect(s.HandleFSUnlock)).Methods("DELETE")
+	v2.HandleFunc("/fs/locks", protect(s.HandleFSLocks)).Methods("GET")
+	v2.HandleFunc("/fs/notebook", protect(s.HandleNotebook)).Methods("GET")
+	v2.HandleFunc("/fs/notebook", protect(s.HandleNotebookEdit)).Methods("POST")
 
 	// Session Management (Protected)
 	v2.HandleFunc("/sessions", protect(s.HandleSessionList)).Methods("GET")
@@ -118,38 +489,225 @@ func (s *Server) setupRoutes() {
 	v2.HandleFunc("/session", protect(s.HandleSessionDelete)).Methods("DELETE")
 	v2.HandleFunc("/session/messages", protect(s.HandleSessionMessages)).Methods("GET")
 	v2.HandleFunc("/session/message", protect(s.HandleSessionAddMessage)).Methods("POST")
+	v2.HandleFunc("/session/replay", protect(s.HandleSessionReplay)).Methods("GET")
+	v2.HandleFunc("/session/files", protect(s.HandleSessionFiles)).Methods("GET")
+	v2.HandleFunc("/defaults", protect(s.HandleDefaults)).Methods("GET")
+	v2.HandleFunc("/session/voice", protect(s.HandleSessionVoice)).Methods("POST")
+	v2.HandleFunc("/session/image", protect(s.HandleSessionImageUpload)).Methods("POST")
+	v2.HandleFunc("/session/image", protect(s.HandleSessionImageGet)).Methods("GET")
+	v2.HandleFunc("/session/tts", protect(s.HandleSessionTTS)).Methods("GET")
+	v2.HandleFunc("/session/draft", protect(s.HandleSessionDraftGet)).Methods("GET")
+	v2.HandleFunc("/session/draft", protect(s.HandleSessionDraftSave)).Methods("PUT")
+	v2.HandleFunc("/session/share", protect(s.HandleSessionShare)).Methods("POST")
+	v2.HandleFunc("/session/share", protect(s.HandleSessionShareRevoke)).Methods("DELETE")
+	v2.HandleFunc("/session/shares", protect(s.HandleSessionShareList)).Methods("GET")
+	v2.HandleFunc("/session/queue", protect(s.HandleSessionQueueAdd)).Methods("POST")
+	v2.HandleFunc("/session/queue", protect(s.HandleSessionQueueList)).Methods("GET")
+	v2.HandleFunc("/session/queue", protect(s.HandleSessionQueueCancel)).Methods("DELETE")
+
+	// Agent Tasks (Protected)
+	v2.HandleFunc("/agent/tasks", protect(s.HandleAgentTaskCreate)).Methods("POST")
+	v2.HandleFunc("/agent/tasks", protect(s.HandleAgentTaskList)).Methods("GET")
+	v2.HandleFunc("/agent/tasks/{id}", protect(s.HandleAgentTaskGet)).Methods("GET")
+	v2.HandleFunc("/agent/tasks/{id}/cancel", protect(s.HandleAgentTaskCancel)).Methods("POST")
+
+	// Usage and budgets (Protected)
+	v2.HandleFunc("/usage", protect(s.HandleUsage)).Methods("GET")
+	v2.HandleFunc("/stats/overview", protect(s.HandleStatsOverview)).Methods("GET")
+
+	// Port forwarding (Protected); previews authenticate in the handler
+	v2.HandleFunc("/forwards", protect(s.HandleForwardList)).Methods("GET")
+	v2.HandleFunc("/forward", protect(s.HandleForwardAdd)).Methods("POST")
+	v2.HandleFunc("/forward", protect(s.HandleForwardRemove)).Methods("DELETE")
+	s.router.HandleFunc("/preview/{id}", s.HandlePreviewRedirect).Methods("GET")
+	s.router.PathPrefix("/preview/{id}/").HandlerFunc(s.HandlePreview)
+
+	// Backup and restore (Protected)
+	v2.HandleFunc("/backup", protect(s.HandleBackup)).Methods("POST")
+	v2.HandleFunc("/backups", protect(s.HandleBackupList)).Methods("GET")
+	v2.HandleFunc("/restore", protect(s.HandleRestore)).Methods("POST")
+	v2.HandleFunc("/storage/usage", protect(s.HandleStorageUsage)).Methods("GET")
+
+	// Crash bundles (Protected)
+	v2.HandleFunc("/crashes", protect(s.HandleCrashList)).Methods("GET")
+	v2.HandleFunc("/crashes/{name}", protect(s.HandleCrashGet)).Methods("GET")
+	v2.HandleFunc("/crashes/{name}", protect(s.HandleCrashDelete)).Methods("DELETE")
+
+	// Telemetry (Protected)
+	v2.HandleFunc("/telemetry/preview", protect(s.HandleTelemetryPreview)).Methods("GET")
+	v2.HandleFunc("/telemetry", protect(s.HandleTelemetrySet)).Methods("PUT")
+	v2.HandleFunc("/telemetry", protect(s.HandleTelemetryDiscard)).Methods("DELETE")
+
+	// Prompt templates (Protected)
+	v2.HandleFunc("/prompts", protect(s.HandlePromptList)).Methods("GET")
+	v2.HandleFunc("/prompts", protect(s.HandlePromptCreate)).Methods("POST")
+	v2.HandleFunc("/prompts/{id}", protect(s.HandlePromptGet)).Methods("GET")
+	v2.HandleFunc("/prompts/{id}", protect(s.HandlePromptUpdate)).Methods("PUT")
+	v2.HandleFunc("/prompts/{id}", protect(s.HandlePromptDelete)).Methods("DELETE")
+	v2.HandleFunc("/prompts/{id}/expand", protect(s.HandlePromptExpand)).Methods("POST")
+	v2.HandleFunc("/changes", protect(s.HandleChangeList)).Methods("GET")
+	v2.HandleFunc("/changes", protect(s.HandleChangePropose)).Methods("POST")
+	v2.HandleFunc("/changes/diff", protect(s.HandleChangeDiff)).Methods("GET")
+	v2.HandleFunc("/changes/{id}", protect(s.HandleChangeGet)).Methods("GET")
+	v2.HandleFunc("/changes/{id}/apply", protect(s.HandleChangeApply)).Methods("POST")
+	v2.HandleFunc("/changes/{id}/reject", protect(s.HandleChangeReject)).Methods("POST")
 
 	// Workspace Management (Protected)
 	v2.HandleFunc("/workspaces", protect(s.HandleWorkspaceList)).Methods("GET")
 	v2.HandleFunc("/workspace", protect(s.HandleWorkspaceAdd)).Methods("POST")
 	v2.HandleFunc("/workspace", protect(s.HandleWorkspaceRemove)).Methods("DELETE")
 	v2.HandleFunc("/workspace/validate", protect(s.HandleWorkspaceValidate)).Methods("POST")
+	v2.HandleFunc("/workspace/stats", protect(s.HandleWorkspaceStats)).Methods("GET")
+	v2.HandleFunc("/workspace/summary", protect(s.HandleWorkspaceSummary)).Methods("GET")
+	v2.HandleFunc("/workspace/recent-files", protect(s.HandleRecentFiles)).Methods("GET")
+	v2.HandleFunc("/workspaces/stats", protect(s.HandleWorkspaceStatsSummary)).Methods("GET")
+
+	// Git (Protected)
+	v2.HandleFunc("/git/status", protect(s.HandleGitStatus)).Methods("GET")
+	v2.HandleFunc("/git/diff", protect(s.HandleGitDiff)).Methods("GET")
+	v2.HandleFunc("/git/log", protect(s.HandleGitLog)).Methods("GET")
+	v2.HandleFunc("/git/stage", protect(s.HandleGitStage)).Methods("POST")
+	v2.HandleFunc("/git/unstage", protect(s.HandleGitUnstage)).Methods("POST")
+	v2.HandleFunc("/git/commit", protect(s.HandleGitCommit)).Methods("POST")
+	v2.HandleFunc("/git/discard", protect(s.HandleGitDiscard)).Methods("POST")
+	v2.HandleFunc("/git/clone", protect(s.HandleGitClone)).Methods("POST")
+
+	// Checkpoints (Protected)
+	v2.HandleFunc("/checkpoints", protect(s.HandleCheckpointList)).Methods("GET")
+	v2.HandleFunc("/checkpoints", protect(s.HandleCheckpointCreate)).Methods("POST")
+	v2.HandleFunc("/checkpoints/{id}/rollback", protect(s.HandleCheckpointRollback)).Methods("POST")
+	v2.HandleFunc("/checkpoints/{id}", protect(s.HandleCheckpointDelete)).Methods("DELETE")
+
+	// Shell Execution (Protected)
+	v2.HandleFunc("/exec", protect(s.HandleExec)).Methods("POST")
+	v2.HandleFunc("/exec/runs", protect(s.HandleExecList)).Methods("GET")
+	v2.HandleFunc("/exec/{id}", protect(s.HandleExecGet)).Methods("GET")
+	v2.HandleFunc("/exec/{id}/stream", protect(s.HandleExecStream)).Methods("GET")
+	v2.HandleFunc("/exec/{id}/cancel", protect(s.HandleExecCancel)).Methods("POST")
+
+	// Task Runner (Protected)
+	v2.HandleFunc("/tasks", protect(s.HandleTaskList)).Methods("GET")
+	v2.HandleFunc("/tasks/run", protect(s.HandleTaskRun)).Methods("POST")
+
+	// Background Jobs (Protected)
+	v2.HandleFunc("/jobs", protect(s.HandleJobList)).Methods("GET")
+	v2.HandleFunc("/jobs/events", protect(s.HandleJobEvents)).Methods("GET")
+	v2.HandleFunc("/jobs/{id}", protect(s.HandleJobGet)).Methods("GET")
+	v2.HandleFunc("/jobs/{id}/cancel", protect(s.HandleJobCancel)).Methods("POST")
+
+	// Terminal (Protected)
+	v2.HandleFunc("/terminal", protect(s.HandleTerminal)).Methods("GET")
+	v2.HandleFunc("/terminal", protect(s.HandleTerminalClose)).Methods("DELETE")
+	v2.HandleFunc("/terminals", protect(s.HandleTerminalList)).Methods("GET")
+
+	// Notifications (Protected)
+	v2.HandleFunc("/notifications/device", protect(s.HandleNotifyRegister)).Methods("PUT")
+	v2.HandleFunc("/notifications/prefs", protect(s.HandleNotifyPrefsGet)).Methods("GET")
+	v2.HandleFunc("/notifications/prefs", protect(s.HandleNotifyPrefsSet)).Methods("PUT")
+	v2.HandleFunc("/notifications/send", protect(s.HandleNotifySend)).Methods("POST")
+
+	// Devices (Protected)
+	v2.HandleFunc("/devices", protect(s.HandleDeviceList)).Methods("GET")
+	v2.HandleFunc("/devices", protect(s.HandleDeviceRevoke)).Methods("DELETE")
+	v2.HandleFunc("/devices/admin", protect(s.HandleDeviceAdmin)).Methods("POST")
+	v2.HandleFunc("/auth/devices/{id}/activity", protect(s.HandleDeviceActivity)).Methods("GET")
+
+	// Batch requests (Protected); each sub-request is checked on its own
+	v2.HandleFunc("/batch", protect(s.HandleBatch)).Methods("POST")
+
+	// Event Stream (Protected)
+	v2.HandleFunc("/events", protect(s.HandleEvents)).Methods("GET")
 
 	// Config Management (Protected)
 	v2.HandleFunc("/config", protect(s.HandleConfigGet)).Methods("GET")
 	v2.HandleFunc("/config", protect(s.HandleConfigSet)).Methods("PUT")
 }
 
-func (s *Server) Start(addr string) error {
-	// CORS Handler
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"}, // Allow all for local dev
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS", "DELETE", "PUT"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
+// Run starts the bridge's background upkeep under life: expiry cleanup,
+// rate limiter pruning, the idle terminal reaper, saving statistics and the
+// retention sweep.
+// They stop with life, which the caller stops before Shutdown.
+func (s *Server) Run(life *lifecycle.Manager) {
+	life.Every("auth.cleanup", auth.CleanupInterval, func(context.Context) {
+		s.authService.CleanupExpired()
+	})
+	life.Every("ratelimit.cleanup", time.Minute, func(context.Context) {
+		for _, limiter := range s.rateLimits {
+			limiter.Cleanup()
+		}
+	})
+	life.Every("terminal.reaper", time.Minute, func(context.Context) {
+		s.terminalMgr.ReapIdle()
+	})
+	life.Every("stats.save", time.Minute, func(context.Context) {
+		s.stats.Save()
 	})
+	life.Every("recentfiles.save", time.Minute, func(context.Context) {
+		s.recentFiles.Save()
+	})
+	life.Every("fslock.expire", 15*time.Second, func(context.Context) {
+		s.locks.Cleanup()
+	})
+	if s.retention.policy.enabled() {
+		life.Go("retention", s.retentionLoop)
+	}
+}
 
-	handler := c.Handler(s.router)
+// Handler returns the API with its middleware, as Start serves it
+func (s *Server) Handler() http.Handler {
+	c := s.corsHandler()
+	return c.Handler(s.accessLogMiddleware(s.localeMiddleware(s.recoverMiddleware(s.networkACLMiddleware(s.limitMiddleware(s.rateLimitMiddleware(s.router)))))))
+}
 
+func (s *Server) Start(addr string) error {
 	s.httpServer = &http.Server{
-		Addr:    addr,
-		Handler: handler,
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ConnContext:       connContext,
+		ReadHeaderTimeout: s.limits.ReadHeaderTimeout,
+		ReadTimeout:       s.limits.ReadTimeout,
+		WriteTimeout:      s.limits.WriteTimeout,
+		IdleTimeout:       s.limits.IdleTimeout,
+		MaxHeaderBytes:    s.limits.MaxHeaderBytes,
+	}
+
+	listeners, err := s.listen(addr)
+	if err != nil {
+		return err
 	}
 
 	log.Info().Str("addr", addr).Msg("Starting Bridge HTTP Server")
-	return s.httpServer.ListenAndServe()
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			errs <- s.httpServer.Serve(l)
+		}(l)
+	}
+	return <-errs
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.remotePool.Close()
+	s.terminalMgr.CloseAll()
+	s.metrics.Close()
+	s.mcpPool.Close()
+	s.plugins.Close()
+	s.lspMgr.Close()
+	s.telemetry.Close()
+	s.crashes.Close()
+	s.backups.Close()
+	s.forwards.Close()
+	s.sessionMgr.Close()
+	s.stats.Save()
+	s.recentFiles.Save()
+	if err := s.authService.Flush(); err != nil {
+		log.Warn().Err(err).Msg("Failed to save auth state")
+	}
+	if s.socketFile != "" {
+		defer os.Remove(s.socketFile)
+	}
+	if s.relayClient != nil {
+		s.relayClient.Close()
+	}
 	return s.httpServer.Shutdown(ctx)
 }
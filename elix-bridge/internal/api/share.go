@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"echohelix/bridge/internal/session"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxShareHours caps how long a share link can live
+const maxShareHours = 7 * 24
+
+// HandleSessionShare mints a read-only link to a session's transcript,
+// valid for ttl_hours (default SHARE_TTL_HOURS, else 24; at most a
+// week). The token is only returned here.
+// POST /api/v2/session/share?id=...&ttl_hours=24
+func (s *Server) HandleSessionShare(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sessionID := r.URL.Query().Get("id")
+	if sessionID == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "Session ID is required")
+		return
+	}
+	if _, ok := s.sessionMgr.Get(sessionID); !ok {
+		writeServiceError(w, http.StatusNotFound, session.ErrSessionNotFound)
+		return
+	}
+
+	hours := s.configInt("SHARE_TTL_HOURS")
+	if hours == 0 {
+		hours = 24
+	}
+	if v := r.URL.Query().Get("ttl_hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "ttl_hours must be a positive integer")
+			return
+		}
+		hours = n
+	}
+	hours = min(hours, maxShareHours)
+
+	share, token, err := s.shares.Create(sessionID, deviceID(r), time.Duration(hours)*time.Hour)
+	if err != nil {
+		WriteError(w, CodeInternal, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Ctx(r.Context()).Info().Str("session", sessionID).Str("share", share.ID).Int("ttl_hours", hours).Msg("Session shared")
+
+	share.TokenHash = ""
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"share": share,
+		"token": token,
+		"url":   requestOrigin(r) + "/share?token=" + url.QueryEscape(token),
+	})
+}
+
+// HandleSessionShareList lists the live share links, of one session with id
+// GET /api/v2/session/shares?id=
+func (s *Server) HandleSessionShareList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	shares := s.shares.List(r.URL.Query().Get("id"))
+	for i := range shares {
+		shares[i].TokenHash = ""
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shares": shares,
+		"count":  len(shares),
+	})
+}
+
+// HandleSessionShareRevoke revokes a share link
+// DELETE /api/v2/session/share?share_id=...
+func (s *Server) HandleSessionShareRevoke(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("share_id")
+	if id == "" {
+		WriteError(w, CodeMissingParameter, http.StatusBadRequest, "share_id is required")
+		return
+	}
+	if !s.shares.Revoke(id) {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "share not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sharedMessage is a message as shown to the holder of a share link
+type sharedMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+	Tools     []string  `json:"tools,omitempty"`
+}
+
+// HandleSharedSession serves the transcript behind a share link, as a
+// page or, with format=json, as JSON. It needs no pairing: the token is
+// the credential, and it only grants reading this one transcript.
+// Secrets are masked regardless of the workspace's secret policy.
+// GET /share?token=...&format=html
+func (s *Server) HandleSharedSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Cache-Control", "no-store")
+
+	share, ok := s.shares.Resolve(r.URL.Query().Get("token"))
+	if !ok {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "this link is invalid or has expired")
+		return
+	}
+	sess, ok := s.sessionMgr.Get(share.SessionID)
+	if !ok {
+		WriteError(w, CodeNotFound, http.StatusNotFound, "the shared session no longer exists")
+		return
+	}
+	messages, err := s.sessionMgr.GetMessages(share.SessionID, 0, 0)
+	if err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+
+	scanner := s.secretScanner(s.resolveWorkDir(sess.WorkingDirectory))
+	transcript := make([]sharedMessage, 0, len(messages))
+	for _, msg := range messages {
+		// 公开链接始终脱敏，即使工作区策略为 off
+		content, _ := scanner.Mask(msg.Content)
+		m := sharedMessage{Role: msg.Role, Content: content, Timestamp: msg.Timestamp}
+		for _, call := range msg.ToolCalls {
+			m.Tools = append(m.Tools, call.Name)
+		}
+		transcript = append(transcript, m)
+	}
+	view := map[string]interface{}{
+		"name":       sess.Name,
+		"model":      sess.Model,
+		"created_at": sess.CreatedAt,
+		"expires_at": share.ExpiresAt,
+		"messages":   transcript,
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(view)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	if err := sharedSessionPage.Execute(w, view); err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("Failed to render shared session")
+	}
+}
+
+var sharedSessionPage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.name}} · EchoHelix</title>
+  <style>
+    body { font: 15px/1.5 system-ui, sans-serif; max-width: 860px; margin: 0 auto; padding: 24px; color: #1f2328; background: #f6f8fa; }
+    header { margin-bottom: 24px; }
+    header p { color: #656d76; margin: 4px 0 0; }
+    .msg { background: #fff; border: 1px solid #d0d7de; border-radius: 8px; padding: 12px 16px; margin-bottom: 12px; }
+    .msg.user { border-left: 4px solid #0969da; }
+    .msg.assistant { border-left: 4px solid #1a7f37; }
+    .meta { font-size: 12px; color: #656d76; margin-bottom: 6px; }
+    pre { white-space: pre-wrap; word-wrap: break-word; margin: 0; font: 13px/1.5 ui-monospace, monospace; }
+    .tools { font-size: 12px; color: #656d76; margin-top: 6px; }
+  </style>
+</head>
+<body>
+  <header>
+    <h1>{{.name}}</h1>
+    <p>{{.model}} · started {{.created_at.Format "2006-01-02 15:04"}} · link expires {{.expires_at.Format "2006-01-02 15:04"}}</p>
+  </header>
+  {{range .messages}}
+  <div class="msg {{.Role}}">
+    <div class="meta">{{.Role}} · {{.Timestamp.Format "15:04:05"}}</div>
+    <pre>{{.Content}}</pre>
+    {{if .Tools}}<div class="tools">tools: {{range $i, $t := .Tools}}{{if $i}}, {{end}}{{$t}}{{end}}</div>{{end}}
+  </div>
+  {{else}}
+  <p>No messages yet.</p>
+  {{end}}
+</body>
+</html>
+`))
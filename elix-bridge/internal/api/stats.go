@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/stats"
+)
+
+// setupStats keeps session statistics as sessions and messages arrive.
+// A bridge upgraded from a version without them counts the existing
+// sessions once.
+func (s *Server) setupStats() {
+	s.stats = stats.NewAggregator(filepath.Join(s.echoDir, "stats.json"))
+	if !s.stats.Loaded() {
+		s.stats.Backfill(s.sessionMgr.List(), func(id string) []*session.Message {
+			messages, _ := s.sessionMgr.GetMessages(id, 0, 0)
+			return messages
+		})
+		s.stats.Save()
+	}
+	s.sessionMgr.OnCreate(s.stats.SessionCreated)
+	s.sessionMgr.OnMessage(s.stats.MessageAdded)
+	s.dashboardHandler.SetStats(s.stats)
+}
+
+// HandleStatsOverview returns session, message and token counts by day,
+// provider and model for recent days, with all-time averages and the
+// busiest workspaces
+// GET /api/v2/stats/overview?days=30
+func (s *Server) HandleStatsOverview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 90 {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "days must be between 1 and 90")
+			return
+		}
+		days = n
+	}
+	json.NewEncoder(w).Encode(s.stats.Overview(days))
+}
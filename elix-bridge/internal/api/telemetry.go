@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"echohelix/bridge/internal/telemetry"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// setupTelemetry reads TELEMETRY ("off" by default, "local" to aggregate
+// for preview only, "on" to also send) and TELEMETRY_ENDPOINT, where
+// daily reports are POSTed
+func (s *Server) setupTelemetry() {
+	mode := strings.ToLower(s.configSvc.Get("TELEMETRY"))
+	if !validTelemetryMode(mode) {
+		if mode != "" {
+			log.Warn().Str("value", mode).Msg("Invalid TELEMETRY, turning it off")
+		}
+		mode = telemetry.ModeOff
+	}
+	s.telemetry = telemetry.New(telemetry.Config{
+		Mode:          mode,
+		Endpoint:      s.configSvc.Get("TELEMETRY_ENDPOINT"),
+		StoragePath:   filepath.Join(s.echoDir, "telemetry.json"),
+		BridgeVersion: Version,
+	})
+}
+
+func validTelemetryMode(mode string) bool {
+	switch mode {
+	case telemetry.ModeOff, telemetry.ModeLocal, telemetry.ModeOn:
+		return true
+	}
+	return false
+}
+
+// recordTelemetry counts the route template used and, for failures, the
+// status class. Paths, parameters and bodies are never recorded.
+func (s *Server) recordTelemetry(r *http.Request, status int) {
+	if s.telemetry.Mode() == telemetry.ModeOff || !strings.HasPrefix(r.URL.Path, "/api/") {
+		return
+	}
+	var match mux.RouteMatch
+	if !s.router.Match(r, &match) || match.Route == nil {
+		return
+	}
+	tmpl, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return
+	}
+	s.telemetry.Feature(r.Method + " " + tmpl)
+	if status >= 400 {
+		s.telemetry.Error("http_" + strconv.Itoa(status))
+	}
+}
+
+// HandleTelemetryPreview shows the telemetry settings and exactly the
+// report that would be sent next
+// GET /api/v2/telemetry/preview
+func (s *Server) HandleTelemetryPreview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"settings": s.telemetry.Info(),
+		"report":   s.telemetry.Preview(),
+	})
+}
+
+// HandleTelemetrySet changes the telemetry mode and saves it to the
+// config; turning it off discards everything buffered
+// PUT /api/v2/telemetry
+func (s *Server) HandleTelemetrySet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, CodeInvalidBody, http.StatusBadRequest, nil)
+		return
+	}
+	mode := strings.ToLower(req.Mode)
+	if !validTelemetryMode(mode) {
+		WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "mode must be off, local or on")
+		return
+	}
+	if err := s.configSvc.Set("TELEMETRY", mode); err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.telemetry.SetMode(mode)
+	json.NewEncoder(w).Encode(s.telemetry.Info())
+}
+
+// HandleTelemetryDiscard drops the buffered counts without sending them
+// DELETE /api/v2/telemetry
+func (s *Server) HandleTelemetryDiscard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s.telemetry.Discard()
+	json.NewEncoder(w).Encode(map[string]bool{"discarded": true})
+}
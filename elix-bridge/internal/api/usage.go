@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/usage"
+)
+
+// setupUsage opens the usage ledger that budgets are enforced against
+// and shows it on the dashboard
+func (s *Server) setupUsage() {
+	s.usageLedger = usage.NewLedger(filepath.Join(s.echoDir, "usage.json"))
+	s.dashboardHandler.SetUsage(s.usageLedger, s.budgets)
+}
+
+// budgets reads the limits from BUDGET_{SESSION,DAILY,DEVICE}_{TOKENS,COST}.
+// Device budgets are per device per day; unset or invalid values are
+// unlimited.
+func (s *Server) budgets() usage.Budgets {
+	limit := func(scope string) usage.Limit {
+		var l usage.Limit
+		l.Tokens, _ = strconv.Atoi(s.configSvc.Get("BUDGET_" + scope + "_TOKENS"))
+		l.Cost, _ = strconv.ParseFloat(s.configSvc.Get("BUDGET_"+scope+"_COST"), 64)
+		return l
+	}
+	return usage.Budgets{
+		Session: limit("SESSION"),
+		Daily:   limit("DAILY"),
+		Device:  limit("DEVICE"),
+	}
+}
+
+// checkBudget returns a *usage.BudgetError when a prompt for sessionID
+// from deviceID must be blocked
+func (s *Server) checkBudget(sessionID, deviceID string) error {
+	return s.usageLedger.Check(s.budgets(), sessionID, deviceID)
+}
+
+// recordUsage adds a kernel turn's usage to the ledger
+func (s *Server) recordUsage(kernelName, sessionID, deviceID string, u kernel.Usage) {
+	if u.InputTokens == 0 && u.OutputTokens == 0 && u.Cost == 0 {
+		return
+	}
+	s.usageLedger.Record(usage.Entry{
+		SessionID:    sessionID,
+		DeviceID:     deviceID,
+		Kernel:       kernelName,
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+		Cost:         u.Cost,
+	})
+}
+
+// deviceID returns the paired device making the request; local requests
+// have none
+func deviceID(r *http.Request) string {
+	if token, ok := auth.TokenFromContext(r.Context()); ok {
+		return token.DeviceID
+	}
+	return ""
+}
+
+// HandleUsage returns the configured budgets with today's and recent
+// usage; with session_id it adds that session's usage
+// GET /api/v2/usage?days=7&session_id=
+func (s *Server) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 90 {
+			WriteError(w, CodeInvalidParameter, http.StatusBadRequest, "days must be between 1 and 90")
+			return
+		}
+		days = n
+	}
+
+	resp := map[string]interface{}{
+		"budgets": s.budgets(),
+		"today":   s.usageLedger.Today(),
+		"days":    s.usageLedger.Days(days),
+	}
+	if id := r.URL.Query().Get("session_id"); id != "" {
+		resp["session"] = s.usageLedger.Session(id)
+	}
+	if id := deviceID(r); id != "" {
+		var used usage.Totals
+		if t := s.usageLedger.Today().Devices[id]; t != nil {
+			used = *t
+		}
+		resp["device"] = used
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// v3Route is a resource-oriented route of the v3 API. Each one is served
+// by the handler behind an equivalent v2 route; path variables are
+// passed to it as the query parameters it reads, so v2 stays a thin
+// compatibility layer over the same handlers and documentation.
+type v3Route struct {
+	Method  string
+	Path    string
+	V2      string                                            // equivalent v2 route, "METHOD /path"
+	Params  map[string]string                                 // path variable → query parameter, when named differently
+	Handler func(*Server, http.ResponseWriter, *http.Request) // the v2 handler
+}
+
+// v3Routes is the v3 route table. mux matches in order, so fixed
+// segments must come before variables at the same position.
+var v3Routes = []v3Route{
+	// Health and auth (Public)
+	{"GET", "/health", "GET /health", nil, (*Server).HandleHealth},
+	{"GET", "/health/details", "GET /health/details", nil, (*Server).HandleHealthDetails},
+	{"POST", "/auth/pair", "POST /auth/pair", nil, func(s *Server, w http.ResponseWriter, r *http.Request) { s.authHandler.HandlePair(w, r) }},
+	{"GET", "/auth/pairing-requests/{request_id}", "GET /auth/pair/status", nil, func(s *Server, w http.ResponseWriter, r *http.Request) { s.authHandler.HandlePairStatus(w, r) }},
+	{"GET", "/auth/pairing-requests", "GET /auth/pair/requests", nil, func(s *Server, w http.ResponseWriter, r *http.Request) { s.authHandler.HandlePairRequests(w, r) }},
+	{"POST", "/auth/pairing-requests/{id}/approve", "POST /auth/pair/approve", nil, func(s *Server, w http.ResponseWriter, r *http.Request) { s.authHandler.HandlePairApprove(w, r) }},
+	{"POST", "/auth/pairing-requests/{id}/deny", "POST /auth/pair/deny", nil, func(s *Server, w http.ResponseWriter, r *http.Request) { s.authHandler.HandlePairDeny(w, r) }},
+	{"POST", "/auth/code", "POST /auth/code", nil, func(s *Server, w http.ResponseWriter, r *http.Request) { s.authHandler.HandleGenerateCode(w, r) }},
+	{"GET", "/auth/status", "GET /auth/status", nil, func(s *Server, w http.ResponseWriter, r *http.Request) { s.authHandler.HandleStatus(w, r) }},
+
+	// Process and kernels
+	{"POST", "/process/start", "POST /process/start", nil, (*Server).HandleProcessStart},
+	{"POST", "/process/stop", "POST /process/stop", nil, (*Server).HandleProcessStop},
+	{"GET", "/process/stats", "GET /process/stats", nil, (*Server).HandleProcessStats},
+	{"GET", "/system/info", "GET /system/info", nil, (*Server).HandleSystemInfo},
+	{"POST", "/kernels/{name}/install", "POST /kernels/{name}/install", nil, (*Server).HandleKernelInstall},
+	{"GET", "/kernels/{name}/install", "GET /kernels/{name}/install", nil, (*Server).HandleKernelInstallStatus},
+	{"GET", "/kernels/{name}/version", "GET /kernels/{name}/version", nil, (*Server).HandleKernelVersion},
+	{"POST", "/kernels/{name}/upgrade", "POST /kernels/{name}/upgrade", nil, (*Server).HandleKernelUpgrade},
+	{"POST", "/kernels/{name}/rollback", "POST /kernels/{name}/rollback", nil, (*Server).HandleKernelRollback},
+	{"GET", "/kernels/{name}/capabilities", "GET /kernels/{name}/capabilities", nil, (*Server).HandleKernelCapabilities},
+	{"GET", "/kernels/{name}/chat", "GET /chat/proxy", map[string]string{"name": "kernel"}, (*Server).HandleChatProxy},
+	{"GET", "/providers", "GET /providers", nil, (*Server).HandleProviderList},
+	{"POST", "/providers/validate", "POST /providers/validate", nil, (*Server).HandleProviderValidate},
+	{"GET", "/models", "GET /models", nil, (*Server).HandleModelList},
+
+	// Code intelligence
+	{"GET", "/lsp/hover", "GET /lsp/hover", nil, (*Server).HandleLSPHover},
+	{"GET", "/lsp/definition", "GET /lsp/definition", nil, (*Server).HandleLSPDefinition},
+	{"GET", "/lsp/diagnostics", "GET /lsp/diagnostics", nil, (*Server).HandleLSPDiagnostics},
+	{"GET", "/lsp/servers", "GET /lsp/servers", nil, (*Server).HandleLSPServers},
+	{"GET", "/lsp/ws", "GET /lsp/ws", nil, (*Server).HandleLSPSocket},
+	{"GET", "/code/outline", "GET /code/outline", nil, (*Server).HandleCodeOutline},
+	{"GET", "/code/symbols", "GET /code/symbols", nil, (*Server).HandleCodeSymbols},
+	{"POST", "/code/symbols/index", "POST /code/symbols/index", nil, (*Server).HandleCodeSymbolIndex},
+	{"POST", "/context/pack", "POST /context/pack", nil, (*Server).HandleContextPack},
+	{"GET", "/search/semantic", "GET /search/semantic", nil, (*Server).HandleSemanticSearch},
+	{"POST", "/search/semantic/index", "POST /search/semantic/index", nil, (*Server).HandleSemanticIndex},
+	{"GET", "/search/semantic/status", "GET /search/semantic/status", nil, (*Server).HandleSemanticStatus},
+	{"POST", "/mcp", "POST /mcp", nil, (*Server).HandleMCP},
+	{"GET", "/mcp/servers", "GET /mcp/servers", nil, (*Server).HandleMCPServers},
+	{"POST", "/mcp/servers/reload", "POST /mcp/servers/reload", nil, (*Server).HandleMCPServersReload},
+	{"GET", "/plugins", "GET /plugins", nil, (*Server).HandlePluginList},
+	{"POST", "/plugins/reload", "POST /plugins/reload", nil, (*Server).HandlePluginReload},
+	{"POST", "/confirm", "POST /confirm", nil, (*Server).HandleConfirm},
+
+	// File system; paths stay in the query since they contain slashes
+	{"GET", "/fs/entries", "GET /fs/ls", nil, (*Server).HandleFSList},
+	{"GET", "/fs/file", "GET /fs/file", nil, (*Server).HandleFile},
+	{"PUT", "/fs/file", "POST /fs/write", nil, (*Server).HandleWriteFile},
+	{"GET", "/fs/roots", "GET /fs/roots", nil, (*Server).HandleRoots},
+	{"GET", "/fs/stat", "GET /fs/stat", nil, (*Server).HandleStat},
+	{"GET", "/fs/exists", "GET /fs/exists", nil, (*Server).HandleExists},
+	{"POST", "/fs/sync", "POST /fs/sync", nil, (*Server).HandleFSSync},
+	{"POST", "/fs/locks", "POST /fs/lock", nil, (*Server).HandleFSLock},
+	{"POST", "/fs/locks/{id}/heartbeat", "POST /fs/lock/heartbeat", nil, (*Server).HandleFSLockHeartbeat},
+	{"DELETE", "/fs/locks/{id}", "DELETE /fs/lock", nil, (*Server).HandleFSUnlock},
+	{"GET", "/fs/locks", "GET /fs/locks", nil, (*Server).HandleFSLocks},
+	{"GET", "/fs/notebook", "GET /fs/notebook", nil, (*Server).HandleNotebook},
+	{"POST", "/fs/notebook", "POST /fs/notebook", nil, (*Server).HandleNotebookEdit},
+
+	// Sessions
+	{"GET", "/sessions", "GET /sessions", nil, (*Server).HandleSessionList},
+	{"POST", "/sessions", "POST /session", nil, (*Server).HandleSessionCreate},
+	{"GET", "/sessions/{id}", "GET /session", nil, (*Server).HandleSessionGet},
+	{"PUT", "/sessions/{id}", "PUT /session", nil, (*Server).HandleSessionUpdate},
+	{"DELETE", "/sessions/{id}", "DELETE /session", nil, (*Server).HandleSessionDelete},
+	{"GET", "/sessions/{id}/messages", "GET /session/messages", map[string]string{"id": "session_id"}, (*Server).HandleSessionMessages},
+	{"POST", "/sessions/{id}/messages", "POST /session/message", map[string]string{"id": "session_id"}, (*Server).HandleSessionAddMessage},
+	{"GET", "/sessions/{id}/replay", "GET /session/replay", nil, (*Server).HandleSessionReplay},
+	{"GET", "/sessions/{id}/files", "GET /session/files", nil, (*Server).HandleSessionFiles},
+	{"GET", "/defaults", "GET /defaults", nil, (*Server).HandleDefaults},
+	{"POST", "/sessions/{id}/voice", "POST /session/voice", map[string]string{"id": "session_id"}, (*Server).HandleSessionVoice},
+	{"POST", "/sessions/{id}/images", "POST /session/image", map[string]string{"id": "session_id"}, (*Server).HandleSessionImageUpload},
+	{"GET", "/messages/{message_id}/speech", "GET /session/tts", nil, (*Server).HandleSessionTTS},
+	{"GET", "/sessions/{id}/images/{image_id}", "GET /session/image", map[string]string{"id": "session_id", "image_id": "id"}, (*Server).HandleSessionImageGet},
+	{"GET", "/sessions/{id}/draft", "GET /session/draft", map[string]string{"id": "session_id"}, (*Server).HandleSessionDraftGet},
+	{"PUT", "/sessions/{id}/draft", "PUT /session/draft", map[string]string{"id": "session_id"}, (*Server).HandleSessionDraftSave},
+	{"POST", "/sessions/{id}/shares", "POST /session/share", nil, (*Server).HandleSessionShare},
+	{"GET", "/sessions/{id}/shares", "GET /session/shares", nil, (*Server).HandleSessionShareList},
+	{"DELETE", "/sessions/{id}/shares/{share_id}", "DELETE /session/share", nil, (*Server).HandleSessionShareRevoke},
+	{"GET", "/sessions/{id}/queue", "GET /session/queue", map[string]string{"id": "session_id"}, (*Server).HandleSessionQueueList},
+	{"POST", "/sessions/{id}/queue", "POST /session/queue", map[string]string{"id": "session_id"}, (*Server).HandleSessionQueueAdd},
+	{"DELETE", "/sessions/{id}/queue/{prompt_id}", "DELETE /session/queue", map[string]string{"id": "session_id", "prompt_id": "id"}, (*Server).HandleSessionQueueCancel},
+
+	// Agent tasks and usage
+	{"POST", "/agent/tasks", "POST /agent/tasks", nil, (*Server).HandleAgentTaskCreate},
+	{"GET", "/agent/tasks", "GET /agent/tasks", nil, (*Server).HandleAgentTaskList},
+	{"GET", "/agent/tasks/{id}", "GET /agent/tasks/{id}", nil, (*Server).HandleAgentTaskGet},
+	{"POST", "/agent/tasks/{id}/cancel", "POST /agent/tasks/{id}/cancel", nil, (*Server).HandleAgentTaskCancel},
+	{"GET", "/usage", "GET /usage", nil, (*Server).HandleUsage},
+	{"GET", "/stats/overview", "GET /stats/overview", nil, (*Server).HandleStatsOverview},
+
+	// Port forwarding
+	{"GET", "/forwards", "GET /forwards", nil, (*Server).HandleForwardList},
+	{"POST", "/forwards", "POST /forward", nil, (*Server).HandleForwardAdd},
+	{"DELETE", "/forwards/{id}", "DELETE /forward", nil, (*Server).HandleForwardRemove},
+
+	// Backups
+	{"GET", "/backups", "GET /backups", nil, (*Server).HandleBackupList},
+	{"POST", "/backups", "POST /backup", nil, (*Server).HandleBackup},
+	{"POST", "/backups/restore", "POST /restore", nil, (*Server).HandleRestore},
+	{"POST", "/backups/{name}/restore", "POST /restore", nil, (*Server).HandleRestore},
+	{"GET", "/storage/usage", "GET /storage/usage", nil, (*Server).HandleStorageUsage},
+	{"GET", "/crashes", "GET /crashes", nil, (*Server).HandleCrashList},
+	{"GET", "/crashes/{name}", "GET /crashes/{name}", nil, (*Server).HandleCrashGet},
+	{"DELETE", "/crashes/{name}", "DELETE /crashes/{name}", nil, (*Server).HandleCrashDelete},
+	{"GET", "/telemetry/preview", "GET /telemetry/preview", nil, (*Server).HandleTelemetryPreview},
+	{"PUT", "/telemetry", "PUT /telemetry", nil, (*Server).HandleTelemetrySet},
+	{"DELETE", "/telemetry", "DELETE /telemetry", nil, (*Server).HandleTelemetryDiscard},
+
+	// Prompt templates and proposed changes
+	{"GET", "/prompts", "GET /prompts", nil, (*Server).HandlePromptList},
+	{"POST", "/prompts", "POST /prompts", nil, (*Server).HandlePromptCreate},
+	{"GET", "/prompts/{id}", "GET /prompts/{id}", nil, (*Server).HandlePromptGet},
+	{"PUT", "/prompts/{id}", "PUT /prompts/{id}", nil, (*Server).HandlePromptUpdate},
+	{"DELETE", "/prompts/{id}", "DELETE /prompts/{id}", nil, (*Server).HandlePromptDelete},
+	{"POST", "/prompts/{id}/expand", "POST /prompts/{id}/expand", nil, (*Server).HandlePromptExpand},
+	{"GET", "/changes", "GET /changes", nil, (*Server).HandleChangeList},
+	{"POST", "/changes", "POST /changes", nil, (*Server).HandleChangePropose},
+	{"GET", "/changes/diff", "GET /changes/diff", nil, (*Server).HandleChangeDiff},
+	{"GET", "/changes/{id}", "GET /changes/{id}", nil, (*Server).HandleChangeGet},
+	{"POST", "/changes/{id}/apply", "POST /changes/{id}/apply", nil, (*Server).HandleChangeApply},
+	{"POST", "/changes/{id}/reject", "POST /changes/{id}/reject", nil, (*Server).HandleChangeReject},
+
+	// Workspaces
+	{"GET", "/workspaces", "GET /workspaces", nil, (*Server).HandleWorkspaceList},
+	{"POST", "/workspaces", "POST /workspace", nil, (*Server).HandleWorkspaceAdd},
+	{"POST", "/workspaces/validate", "POST /workspace/validate", nil, (*Server).HandleWorkspaceValidate},
+	{"GET", "/workspaces/stats", "GET /workspaces/stats", nil, (*Server).HandleWorkspaceStatsSummary},
+	{"DELETE", "/workspaces/{id}", "DELETE /workspace", nil, (*Server).HandleWorkspaceRemove},
+	{"GET", "/workspaces/{id}/stats", "GET /workspace/stats", nil, (*Server).HandleWorkspaceStats},
+	{"GET", "/workspaces/{id}/summary", "GET /workspace/summary", nil, (*Server).HandleWorkspaceSummary},
+	{"GET", "/workspaces/{id}/recent-files", "GET /workspace/recent-files", nil, (*Server).HandleRecentFiles},
+
+	// Git and checkpoints
+	{"GET", "/git/status", "GET /git/status", nil, (*Server).HandleGitStatus},
+	{"GET", "/git/diff", "GET /git/diff", nil, (*Server).HandleGitDiff},
+	{"GET", "/git/log", "GET /git/log", nil, (*Server).HandleGitLog},
+	{"POST", "/git/stage", "POST /git/stage", nil, (*Server).HandleGitStage},
+	{"POST", "/git/unstage", "POST /git/unstage", nil, (*Server).HandleGitUnstage},
+	{"POST", "/git/commit", "POST /git/commit", nil, (*Server).HandleGitCommit},
+	{"POST", "/git/discard", "POST /git/discard", nil, (*Server).HandleGitDiscard},
+	{"POST", "/git/clone", "POST /git/clone", nil, (*Server).HandleGitClone},
+	{"GET", "/checkpoints", "GET /checkpoints", nil, (*Server).HandleCheckpointList},
+	{"POST", "/checkpoints", "POST /checkpoints", nil, (*Server).HandleCheckpointCreate},
+	{"POST", "/checkpoints/{id}/rollback", "POST /checkpoints/{id}/rollback", nil, (*Server).HandleCheckpointRollback},
+	{"DELETE", "/checkpoints/{id}", "DELETE /checkpoints/{id}", nil, (*Server).HandleCheckpointDelete},
+
+	// Shell execution, tasks and jobs
+	{"POST", "/exec", "POST /exec", nil, (*Server).HandleExec},
+	{"GET", "/exec/runs", "GET /exec/runs", nil, (*Server).HandleExecList},
+	{"GET", "/exec/{id}", "GET /exec/{id}", nil, (*Server).HandleExecGet},
+	{"GET", "/exec/{id}/stream", "GET /exec/{id}/stream", nil, (*Server).HandleExecStream},
+	{"POST", "/exec/{id}/cancel", "POST /exec/{id}/cancel", nil, (*Server).HandleExecCancel},
+	{"GET", "/tasks", "GET /tasks", nil, (*Server).HandleTaskList},
+	{"POST", "/tasks/run", "POST /tasks/run", nil, (*Server).HandleTaskRun},
+	{"GET", "/jobs", "GET /jobs", nil, (*Server).HandleJobList},
+	{"GET", "/jobs/events", "GET /jobs/events", nil, (*Server).HandleJobEvents},
+	{"GET", "/jobs/{id}", "GET /jobs/{id}", nil, (*Server).HandleJobGet},
+	{"POST", "/jobs/{id}/cancel", "POST /jobs/{id}/cancel", nil, (*Server).HandleJobCancel},
+
+	// Terminals
+	{"GET", "/terminals", "GET /terminals", nil, (*Server).HandleTerminalList},
+	{"GET", "/terminals/connect", "GET /terminal", nil, (*Server).HandleTerminal},
+	{"GET", "/terminals/{id}/connect", "GET /terminal", nil, (*Server).HandleTerminal},
+	{"DELETE", "/terminals/{id}", "DELETE /terminal", nil, (*Server).HandleTerminalClose},
+
+	// Notifications, devices, events and config
+	{"PUT", "/notifications/device", "PUT /notifications/device", nil, (*Server).HandleNotifyRegister},
+	{"GET", "/notifications/prefs", "GET /notifications/prefs", nil, (*Server).HandleNotifyPrefsGet},
+	{"PUT", "/notifications/prefs", "PUT /notifications/prefs", nil, (*Server).HandleNotifyPrefsSet},
+	{"POST", "/notifications/send", "POST /notifications/send", nil, (*Server).HandleNotifySend},
+	{"GET", "/devices", "GET /devices", nil, (*Server).HandleDeviceList},
+	{"DELETE", "/devices/{id}", "DELETE /devices", nil, (*Server).HandleDeviceRevoke},
+	{"POST", "/devices/{id}/admin", "POST /devices/admin", nil, (*Server).HandleDeviceAdmin},
+	{"GET", "/devices/{id}/activity", "GET /auth/devices/{id}/activity", nil, (*Server).HandleDeviceActivity},
+	{"POST", "/batch", "POST /batch", nil, (*Server).HandleBatch},
+	{"GET", "/events", "GET /events", nil, (*Server).HandleEvents},
+	{"GET", "/config", "GET /config", nil, (*Server).HandleConfigGet},
+	{"PUT", "/config", "PUT /config", nil, (*Server).HandleConfigSet},
+}
+
+// v3Compat maps "METHOD /api/v3/..." templates to the v2 route they
+// stand in for, so permission overrides apply to both
+var v3Compat = func() map[string]string {
+	m := make(map[string]string, len(v3Routes))
+	for _, rt := range v3Routes {
+		method, path, _ := strings.Cut(rt.V2, " ")
+		m[rt.Method+" /api/v3"+rt.Path] = method + " /api/v2" + path
+	}
+	return m
+}()
+
+// setupV3Routes registers /api/v3. Public routes are those documented
+// as public in v2; everything else goes through protect.
+func (s *Server) setupV3Routes() {
+	v3 := s.router.PathPrefix("/api/v3").Subrouter()
+
+	// API Docs (Public)
+	v3.HandleFunc("/openapi.json", s.HandleOpenAPIV3).Methods("GET")
+	v3.HandleFunc("/docs", s.HandleSwaggerUIV3).Methods("GET")
+
+	for _, rt := range v3Routes {
+		handler := rt.Handler
+		params := rt.Params
+		h := func(w http.ResponseWriter, r *http.Request) {
+			handler(s, w, withPathParams(r, params))
+		}
+		if !routeDocs[rt.V2].Public {
+			h = s.protect(h)
+		}
+		v3.HandleFunc(rt.Path, h).Methods(rt.Method)
+	}
+}
+
+// withPathParams copies the route's path variables into the query,
+// renamed per params, where the v2 handlers look for them
+func withPathParams(r *http.Request, params map[string]string) *http.Request {
+	vars := mux.Vars(r)
+	if len(vars) == 0 {
+		return r
+	}
+	query := r.URL.Query()
+	for name, value := range vars {
+		if param, ok := params[name]; ok {
+			name = param
+		}
+		query.Set(name, value)
+	}
+	r.URL.RawQuery = query.Encode()
+	return r
+}
+
+// v3Docs derives the v3 documentation from the equivalent v2 routes,
+// dropping parameters that moved into the path
+func v3Docs() map[string]routeDoc {
+	docs := map[string]routeDoc{
+		"GET /openapi.json": {Summary: "OpenAPI document for the v3 API", Tag: "system", Public: true},
+		"GET /docs":         {Summary: "Swagger UI for the v3 API", Tag: "system", Public: true},
+	}
+	for _, rt := range v3Routes {
+		doc := routeDocs[rt.V2]
+		inPath := map[string]bool{}
+		for _, m := range pathParamRe.FindAllStringSubmatch(rt.Path, -1) {
+			name := m[1]
+			if param, ok := rt.Params[name]; ok {
+				name = param
+			}
+			inPath[name] = true
+		}
+		doc.Query = withoutParams(doc.Query, inPath)
+		doc.Body = withoutParams(doc.Body, inPath)
+		docs[rt.Method+" "+rt.Path] = doc
+	}
+	return docs
+}
+
+func withoutParams(params []paramDoc, drop map[string]bool) []paramDoc {
+	var kept []paramDoc
+	for _, p := range params {
+		if !drop[p.Name] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
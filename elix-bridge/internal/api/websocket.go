@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"echohelix/bridge/internal/auth"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// wsSubprotocol is selected when a client offers it, which browsers
+// need when they also send a bearer.<token> subprotocol
+const wsSubprotocol = "echohelix"
+
+// wsAuthTimeout bounds the wait for the first-message token
+const wsAuthTimeout = 10 * time.Second
+
+// WebSocket close codes for failed first-message authentication
+const (
+	wsCloseUnauthorized = 4401
+	wsCloseForbidden    = 4403
+	wsCloseRejected     = 4400
+)
+
+// newUpgrader checks origins against the allow-list and picks the
+// echohelix subprotocol when offered
+func (s *Server) newUpgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin:  s.checkWebSocketOrigin,
+		Subprotocols: []string{wsSubprotocol},
+	}
+}
+
+// checkWebSocketOrigin accepts requests without an Origin (native apps),
+// same-origin pages such as the dashboard and previews, and origins in
+// WS_ALLOWED_ORIGINS, falling back to CORS_ALLOWED_ORIGINS and then to
+// localhost only
+func (s *Server) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || s.insecureCORS {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+
+	allowed := s.configSvc.Get("WS_ALLOWED_ORIGINS")
+	if allowed == "" {
+		allowed = s.configSvc.Get("CORS_ALLOWED_ORIGINS")
+	}
+	if originMatches(allowed, origin) {
+		return true
+	}
+	log.Ctx(r.Context()).Warn().Str("origin", origin).Str("path", r.URL.Path).Msg("WebSocket origin rejected")
+	return false
+}
+
+type pendingWebSocketKey struct{}
+
+// pendingWebSocket is a connection upgraded before authentication,
+// waiting for the handler to take it over
+type pendingWebSocket struct {
+	conn  *websocket.Conn
+	taken bool
+}
+
+// upgrade upgrades the request to WebSocket. Connections that were
+// authenticated with a first message are already upgraded; the handler
+// takes them over and the client is told it is in.
+func (s *Server) upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+	if p, ok := r.Context().Value(pendingWebSocketKey{}).(*pendingWebSocket); ok {
+		p.taken = true
+		ack := map[string]string{"type": "auth_ok"}
+		if token, ok := auth.TokenFromContext(r.Context()); ok {
+			ack["device_id"] = token.DeviceID
+		}
+		if err := p.conn.WriteJSON(ack); err != nil {
+			return nil, err
+		}
+		return p.conn, nil
+	}
+	return s.upgrader.Upgrade(w, r, nil)
+}
+
+// authenticateWebSocket lets clients that cannot set headers and should
+// not put their token in the URL authenticate with the first message,
+// {"type":"auth","token":"..."}. The connection is upgraded first, then
+// the request runs through the usual authentication, permission and e2e
+// checks with the token, so the handler only starts once they pass.
+// Rejections close the socket with a 44xx code and the error as reason.
+func (s *Server) authenticateWebSocket(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // upgrader 已写出 HTTP 错误
+	}
+
+	conn.SetReadDeadline(time.Now().Add(wsAuthTimeout))
+	var msg struct {
+		Type  string `json:"type"`
+		Token string `json:"token"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != "auth" || msg.Token == "" {
+		closeWebSocket(conn, wsCloseUnauthorized, "Authentication required: send {\"type\":\"auth\",\"token\":...} first")
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	pending := &pendingWebSocket{conn: conn}
+	authed := r.Clone(r.Context())
+	authed = authed.WithContext(context.WithValue(authed.Context(), pendingWebSocketKey{}, pending))
+	authed.Header.Set("Authorization", "Bearer "+msg.Token)
+
+	rec := &upgradedResponse{header: http.Header{}}
+	next(rec, authed)
+	if pending.taken {
+		return
+	}
+
+	// 处理器未接管连接：把 HTTP 错误转成关闭帧
+	reason := http.StatusText(rec.status)
+	var body ErrorResponse
+	if json.Unmarshal(rec.body, &body) == nil && body.Error != "" {
+		reason = body.Error
+	}
+	code := wsCloseRejected
+	switch rec.status {
+	case http.StatusUnauthorized:
+		code = wsCloseUnauthorized
+	case http.StatusForbidden:
+		code = wsCloseForbidden
+	}
+	closeWebSocket(conn, code, reason)
+}
+
+// closeWebSocket sends a close frame and closes the connection. Reasons
+// are cut to the 123 bytes a close frame can carry.
+func closeWebSocket(conn *websocket.Conn, code int, reason string) {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// upgradedResponse stands in for the ResponseWriter of a request whose
+// connection is already a WebSocket, keeping any HTTP error a
+// middleware or handler writes so it can be sent as a close frame
+type upgradedResponse struct {
+	header http.Header
+	status int
+	body   []byte
+}
+
+func (u *upgradedResponse) Header() http.Header { return u.header }
+
+func (u *upgradedResponse) WriteHeader(status int) {
+	if u.status == 0 {
+		u.status = status
+	}
+}
+
+func (u *upgradedResponse) Write(p []byte) (int, error) {
+	if u.status == 0 {
+		u.status = http.StatusOK
+	}
+	u.body = append(u.body, p...)
+	return len(p), nil
+}
+
+func (u *upgradedResponse) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("connection already upgraded to WebSocket")
+}
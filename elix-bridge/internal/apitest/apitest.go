@@ -0,0 +1,318 @@
+// Package apitest provides an end-to-end test harness for EchoHelix Bridge.
+//
+// New runs the whole bridge, as the binary does, on a random local port
+// with its data and workspace in temporary directories, and pairs a
+// device with admin rights so tests can call any endpoint:
+//
+//	srv := apitest.New(t)
+//	var sess session.Session
+//	srv.JSON("POST", "/api/v2/session", map[string]string{"name": "t"}, &sess)
+//
+//	ws := srv.Dial("/api/v2/chat/proxy?kernel=echo&events=true")
+//	ws.Send(kernel.Request{Method: kernel.MethodChat, Text: "hi"})
+//	events := ws.ReadTurn()
+//
+// The built-in echo kernel (StartEcho) stands in for a real one.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/kernel/echo"
+	"echohelix/bridge/pkg/bridge"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// DeviceID is the device the harness pairs
+const DeviceID = "apitest-device"
+
+// Timeout bounds startup, requests and WebSocket reads
+var Timeout = 10 * time.Second
+
+// quiet silences the bridge's logs once, before the first bridge starts;
+// the logger is process-wide and bridges of earlier tests may still log
+var quiet sync.Once
+
+// Server is a bridge running for one test
+type Server struct {
+	// URL is the HTTP base URL, such as http://127.0.0.1:41000
+	URL string
+	// Token is the admin token of the paired device
+	Token string
+	// WorkDir is the workspace the fs endpoints read and write
+	WorkDir string
+	// DataDir holds the bridge's state
+	DataDir string
+	// Bridge reaches the managers directly, bypassing HTTP
+	Bridge *bridge.Bridge
+
+	t      testing.TB
+	client *http.Client
+}
+
+// New starts a bridge and stops it when the test ends. env lines such as
+// "PAIRING_APPROVAL=true" are written to the bridge's .env.
+func New(t testing.TB, env ...string) *Server {
+	t.Helper()
+	quiet.Do(func() { log.Logger = zerolog.New(io.Discard) })
+
+	workDir := t.TempDir()
+	// socket 路径有长度限制，数据目录放在较短的临时路径下
+	dataDir, err := os.MkdirTemp("", "echohelix-apitest-")
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dataDir) })
+
+	env = append([]string{"BIND_MODE=localhost"}, env...)
+	if err := os.WriteFile(filepath.Join(workDir, ".env"), []byte(strings.Join(env, "\n")+"\n"), 0600); err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+
+	addr := fmt.Sprintf("127.0.0.1:%d", FreePort(t))
+	b, err := bridge.New(bridge.Options{
+		Addr:            addr,
+		WorkDir:         workDir,
+		DataDir:         dataDir,
+		ShutdownTimeout: Timeout,
+	})
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	t.Cleanup(func() {
+		b.Processes().Stop()
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("apitest: bridge stopped with %v", err)
+		}
+	})
+
+	s := &Server{
+		URL:     "http://" + addr,
+		WorkDir: workDir,
+		DataDir: dataDir,
+		Bridge:  b,
+		t:       t,
+		client:  &http.Client{Timeout: Timeout},
+	}
+	s.waitReady(done)
+	s.Token = s.Pair(DeviceID, true)
+	return s
+}
+
+// waitReady polls the health endpoint until the bridge answers
+func (s *Server) waitReady(done <-chan error) {
+	s.t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-done:
+			s.t.Fatalf("apitest: bridge exited during startup: %v", err)
+		default:
+		}
+		if resp, err := s.client.Get(s.URL + "/api/v2/health"); err == nil {
+			resp.Body.Close()
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	s.t.Fatalf("apitest: bridge did not start within %s", Timeout)
+}
+
+// Pair pairs another device and returns its token
+func (s *Server) Pair(deviceID string, admin bool) string {
+	s.t.Helper()
+	auth := s.Bridge.Auth()
+	pc, err := auth.GeneratePairingCode()
+	if err != nil {
+		s.t.Fatalf("apitest: %v", err)
+	}
+	token, err := auth.ValidatePairingCode(pc.Code, deviceID, deviceID)
+	if err != nil {
+		s.t.Fatalf("apitest: pair %s: %v", deviceID, err)
+	}
+	if admin {
+		if _, err := auth.SetAdmin(deviceID, true); err != nil {
+			s.t.Fatalf("apitest: grant admin to %s: %v", deviceID, err)
+		}
+	}
+	return token.Value
+}
+
+// PairGuest pairs a device with a guest code and returns its read-only
+// token
+func (s *Server) PairGuest(deviceID string) string {
+	s.t.Helper()
+	auth := s.Bridge.Auth()
+	pc, err := auth.GenerateGuestPairingCode()
+	if err != nil {
+		s.t.Fatalf("apitest: %v", err)
+	}
+	token, err := auth.ValidatePairingCode(pc.Code, deviceID, deviceID)
+	if err != nil {
+		s.t.Fatalf("apitest: pair guest %s: %v", deviceID, err)
+	}
+	return token.Value
+}
+
+// Do sends a request with the harness token. body is sent as JSON unless
+// it is nil or an io.Reader.
+func (s *Server) Do(method, path string, body interface{}) *http.Response {
+	s.t.Helper()
+	return s.DoAs(s.Token, method, path, body)
+}
+
+// DoAs sends a request with token, or none when it is empty
+func (s *Server) DoAs(token, method, path string, body interface{}) *http.Response {
+	s.t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("apitest: encode body: %v", err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.URL+path, r)
+	if err != nil {
+		s.t.Fatalf("apitest: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.t.Fatalf("apitest: %s %s: %v", method, path, err)
+	}
+	return resp
+}
+
+// JSON sends a request and decodes the response into out, when out is
+// not nil. It returns the status code.
+func (s *Server) JSON(method, path string, body, out interface{}) int {
+	s.t.Helper()
+	resp := s.Do(method, path, body)
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			s.t.Fatalf("apitest: %s %s: decode %d response: %v", method, path, resp.StatusCode, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// StartEcho starts the built-in echo kernel on a free port
+func (s *Server) StartEcho() {
+	s.t.Helper()
+	body := map[string]interface{}{"kernel": echo.Name, "port": FreePort(s.t)}
+	if status := s.JSON("POST", "/api/v2/process/start", body, nil); status != http.StatusOK {
+		s.t.Fatalf("apitest: start echo kernel: status %d", status)
+	}
+}
+
+// WSConn is a WebSocket connection to the bridge
+type WSConn struct {
+	*websocket.Conn
+	t testing.TB
+}
+
+// Dial opens a WebSocket to path with the harness token
+func (s *Server) Dial(path string) *WSConn {
+	s.t.Helper()
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+s.Token)
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + path
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		s.t.Fatalf("apitest: dial %s: %v (status %d)", path, err, status)
+	}
+	s.t.Cleanup(func() { conn.Close() })
+	return &WSConn{Conn: conn, t: s.t}
+}
+
+// Send writes v as a JSON text frame
+func (c *WSConn) Send(v interface{}) {
+	c.t.Helper()
+	if err := c.WriteJSON(v); err != nil {
+		c.t.Fatalf("apitest: send: %v", err)
+	}
+}
+
+// Recv reads the next frame as JSON into v
+func (c *WSConn) Recv(v interface{}) {
+	c.t.Helper()
+	c.SetReadDeadline(time.Now().Add(Timeout))
+	if err := c.ReadJSON(v); err != nil {
+		c.t.Fatalf("apitest: receive: %v", err)
+	}
+}
+
+// ReadTurn reads kernel events up to and including the next done event
+func (c *WSConn) ReadTurn() []kernel.Event {
+	c.t.Helper()
+	var events []kernel.Event
+	for {
+		var ev kernel.Event
+		c.Recv(&ev)
+		events = append(events, ev)
+		if ev.Type == kernel.EventDone {
+			return events
+		}
+	}
+}
+
+// Reply joins the text of a turn's message events
+func Reply(events []kernel.Event) string {
+	var sb strings.Builder
+	for _, ev := range events {
+		if ev.Type == kernel.EventMessageDelta || ev.Type == kernel.EventMessage {
+			sb.WriteString(ev.Text)
+		}
+	}
+	return sb.String()
+}
+
+// FreePort returns a TCP port free on 127.0.0.1 at the time of the call
+func FreePort(t testing.TB) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,481 @@
+package apitest
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"echohelix/bridge/internal/e2e"
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/relay"
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/stats"
+)
+
+func TestRequiresToken(t *testing.T) {
+	srv := New(t)
+
+	resp := srv.DoAs("", "GET", "/api/v2/sessions", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestSessionFlow(t *testing.T) {
+	srv := New(t)
+
+	var sess session.Session
+	if status := srv.JSON("POST", "/api/v2/session", map[string]string{"name": "e2e"}, &sess); status != http.StatusCreated {
+		t.Fatalf("create: status %d", status)
+	}
+	if sess.ID == "" || sess.Name != "e2e" {
+		t.Fatalf("create: got %+v", sess)
+	}
+
+	msg := map[string]string{"role": "user", "content": "hello"}
+	if status := srv.JSON("POST", "/api/v2/session/message?session_id="+sess.ID, msg, nil); status >= 300 {
+		t.Fatalf("add message: status %d", status)
+	}
+
+	var messages struct {
+		Messages []session.Message `json:"messages"`
+	}
+	srv.JSON("GET", "/api/v2/session/messages?session_id="+sess.ID, nil, &messages)
+	if len(messages.Messages) != 1 || messages.Messages[0].Content != "hello" {
+		t.Fatalf("messages = %+v", messages.Messages)
+	}
+
+	var overview stats.Overview
+	srv.JSON("GET", "/api/v2/stats/overview?days=7", nil, &overview)
+	if overview.Sessions != 1 || overview.Messages != 1 || len(overview.Days) != 7 || overview.Days[6].Messages != 1 {
+		t.Fatalf("stats = %+v", overview)
+	}
+
+	var list struct {
+		Sessions []session.Session `json:"sessions"`
+	}
+	srv.JSON("GET", "/api/v2/sessions", nil, &list)
+	if len(list.Sessions) != 1 || list.Sessions[0].ID != sess.ID {
+		t.Fatalf("sessions = %+v", list.Sessions)
+	}
+
+	if status := srv.JSON("DELETE", "/api/v2/session?id="+sess.ID, nil, nil); status >= 300 {
+		t.Fatalf("delete: status %d", status)
+	}
+	if status := srv.JSON("GET", "/api/v2/session?id="+sess.ID, nil, nil); status != http.StatusNotFound {
+		t.Fatalf("get deleted: status %d, want 404", status)
+	}
+}
+
+func TestFSFlow(t *testing.T) {
+	srv := New(t)
+
+	body := map[string]string{"path": "dir/note.txt", "content": "written over http"}
+	if status := srv.JSON("POST", "/api/v2/fs/write", body, nil); status != http.StatusOK {
+		t.Fatalf("write: status %d", status)
+	}
+	data, err := os.ReadFile(filepath.Join(srv.WorkDir, "dir", "note.txt"))
+	if err != nil || string(data) != "written over http" {
+		t.Fatalf("file on disk = %q, %v", data, err)
+	}
+
+	var file struct {
+		Content string `json:"content"`
+	}
+	srv.JSON("GET", "/api/v2/fs/file?path=dir/note.txt", nil, &file)
+	if file.Content != "written over http" {
+		t.Fatalf("read = %q", file.Content)
+	}
+
+	// 工作区外的文件可以读取，但写入需要确认
+	outside := filepath.Join(filepath.Dir(srv.WorkDir), "outside.txt")
+	if err := os.WriteFile(outside, []byte("outside"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	body = map[string]string{"path": "../outside.txt", "content": "overwritten"}
+	if status := srv.JSON("POST", "/api/v2/fs/write", body, nil); status != http.StatusConflict {
+		t.Fatalf("unconfirmed write outside the workspace: status %d, want 409", status)
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "outside" {
+		t.Fatalf("file outside the workspace = %q", data)
+	}
+}
+
+func TestBridgeFilesHidden(t *testing.T) {
+	srv := New(t)
+	guest := srv.PairGuest("apitest-guest")
+
+	for _, path := range []string{
+		filepath.Join(srv.DataDir, "auth.json"),
+		filepath.Join(srv.DataDir, "identity_key"),
+		".env",
+		filepath.Join(srv.WorkDir, ".env"),
+	} {
+		for _, token := range []string{guest, srv.Token} {
+			resp := srv.DoAs(token, "GET", "/api/v2/fs/file?path="+url.QueryEscape(path), nil)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("read %s: status %d, want 403", path, resp.StatusCode)
+			}
+		}
+	}
+
+	resp := srv.DoAs(guest, "GET", "/api/v2/fs/ls?path=.", nil)
+	defer resp.Body.Close()
+	var entries []struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		t.Fatalf("list: status %d: %v", resp.StatusCode, err)
+	}
+	for _, e := range entries {
+		if e.Path == ".env" {
+			t.Fatalf("listing shows the env file: %+v", entries)
+		}
+	}
+}
+
+func TestChangesRespectReadPolicy(t *testing.T) {
+	srv := New(t)
+	for name, content := range map[string]string{
+		"secrets/key.txt": "TOPSECRET\n",
+		"notes.txt":       "notes\n",
+	} {
+		path := filepath.Join(srv.WorkDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	policy := filepath.Join(srv.WorkDir, ".echohelix", "policy.json")
+	os.MkdirAll(filepath.Dir(policy), 0755)
+	if err := os.WriteFile(policy, []byte(`{"deny_read":["secrets/**"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]interface{}{
+		"edits": []map[string]string{{"path": "secrets/key.txt", "content": "changed\n"}},
+	}
+	if status := srv.JSON("POST", "/api/v2/changes", body, nil); status != http.StatusForbidden {
+		t.Fatalf("propose a deny_read file: status %d, want 403", status)
+	}
+
+	// 提议之后收紧的策略在应用时同样生效
+	var change struct {
+		ID string `json:"id"`
+	}
+	body["edits"] = []map[string]string{{"path": "notes.txt", "content": "changed\n"}}
+	if status := srv.JSON("POST", "/api/v2/changes", body, &change); status != http.StatusCreated {
+		t.Fatalf("propose: status %d", status)
+	}
+	if err := os.WriteFile(policy, []byte(`{"deny_read":["secrets/**","notes.txt"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if status := srv.JSON("POST", "/api/v2/changes/"+change.ID+"/apply", nil, nil); status != http.StatusForbidden {
+		t.Fatalf("apply after deny_read: status %d, want 403", status)
+	}
+	if data, _ := os.ReadFile(filepath.Join(srv.WorkDir, "notes.txt")); string(data) != "notes\n" {
+		t.Fatalf("notes.txt = %q", data)
+	}
+}
+
+func TestChangesCannotEditEnvFile(t *testing.T) {
+	srv := New(t)
+	before, err := os.ReadFile(filepath.Join(srv.WorkDir, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]interface{}{
+		"edits": []map[string]string{{"path": ".env", "content": "BIND_MODE=lan\n"}},
+	}
+	if status := srv.JSON("POST", "/api/v2/changes", body, nil); status != http.StatusForbidden {
+		t.Fatalf("propose an edit to .env: status %d, want 403", status)
+	}
+	if data, _ := os.ReadFile(filepath.Join(srv.WorkDir, ".env")); string(data) != string(before) {
+		t.Fatalf(".env changed: %q", data)
+	}
+}
+
+func TestRemoteRootsMustBeRegistered(t *testing.T) {
+	srv := New(t)
+	guest := srv.PairGuest("apitest-guest")
+	member := srv.Pair("apitest-member", false)
+	// 端口 1 没有服务，已注册的根目录在连接时失败，而不是被拒绝
+	const root = "ssh://me@127.0.0.1:1/srv/ws"
+
+	status := func(token, method, path string, body interface{}) int {
+		resp := srv.DoAs(token, method, path, body)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, path := range []string{
+		"/api/v2/fs/file?path=" + url.QueryEscape("ssh://me@127.0.0.1:1/etc/passwd"),
+		"/api/v2/fs/file?root=" + url.QueryEscape("ssh://me@127.0.0.1:1/etc") + "&path=passwd",
+	} {
+		for _, token := range []string{guest, srv.Token} {
+			if got := status(token, "GET", path, nil); got != http.StatusBadRequest {
+				t.Errorf("GET %s: status %d, want 400", path, got)
+			}
+		}
+	}
+
+	body := map[string]string{"name": "ws", "path": root}
+	if got := status(member, "POST", "/api/v2/workspace", body); got != http.StatusForbidden {
+		t.Fatalf("register as non-admin: status %d, want 403", got)
+	}
+	if got := status(srv.Token, "POST", "/api/v2/workspace", body); got != http.StatusOK {
+		t.Fatalf("register as admin: status %d", got)
+	}
+
+	escape := "/api/v2/fs/file?root=" + url.QueryEscape(root) + "&path=" + url.QueryEscape("../../etc/passwd")
+	if got := status(guest, "GET", escape, nil); got != http.StatusBadRequest {
+		t.Errorf("path escaping the root: status %d, want 400", got)
+	}
+	inside := "/api/v2/fs/file?root=" + url.QueryEscape(root) + "&path=README.md"
+	if got := status(guest, "GET", inside, nil); got != http.StatusNotFound {
+		t.Errorf("path in a registered root: status %d, want 404 from the failed connection", got)
+	}
+}
+
+func TestSavedBackupExcludesSecrets(t *testing.T) {
+	srv := New(t)
+
+	var info struct {
+		Name string `json:"name"`
+	}
+	if status := srv.JSON("POST", "/api/v2/backup?save=true&exclude_secrets=true", nil, &info); status != http.StatusCreated {
+		t.Fatalf("save backup: status %d, want 201", status)
+	}
+	f, err := os.Open(filepath.Join(srv.DataDir, "backups", info.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(hdr.Name) == "identity_key" {
+			t.Fatalf("saved archive contains %s despite exclude_secrets", hdr.Name)
+		}
+	}
+}
+
+func TestBatchCannotForgeClientIP(t *testing.T) {
+	rs, err := relay.NewServer(relay.ServerConfig{Secret: "apitest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := httptest.NewServer(rs)
+	defer hs.Close()
+	srv := New(t, "RELAY_URL=ws"+strings.TrimPrefix(hs.URL, "http"), "RELAY_SECRET=apitest", "RELAY_ID=apitest")
+	phone := srv.Pair("apitest-phone", false)
+	base := hs.URL + "/b/apitest"
+
+	post := func(body string) int {
+		req, _ := http.NewRequest("POST", base+"/api/v2/batch", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+phone)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	batch := `{"requests":[{"id":"a","path":"/api/v2/sessions","headers":{"X-Forwarded-For":"203.0.113.9","X-Real-IP":"203.0.113.9"}}]}`
+	deadline := time.Now().Add(Timeout)
+	for post(batch) != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("relay tunnel not connected")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	var devices []struct {
+		DeviceID string `json:"device_id"`
+		LastSeen struct {
+			IP string `json:"ip"`
+		} `json:"last_seen"`
+	}
+	srv.JSON("GET", "/api/v2/devices", nil, &devices)
+	for _, d := range devices {
+		if d.DeviceID == "apitest-phone" && d.LastSeen.IP != "127.0.0.1" {
+			t.Fatalf("last seen from %q, want the relay's forwarded 127.0.0.1", d.LastSeen.IP)
+		}
+	}
+}
+
+func TestPairApprovalNeedsHeader(t *testing.T) {
+	srv := New(t, "PAIRING_APPROVAL=true")
+
+	var code struct {
+		Code string `json:"code"`
+	}
+	if status := srv.JSON("POST", "/api/v2/auth/code", nil, &code); status != http.StatusOK {
+		t.Fatalf("code: status %d", status)
+	}
+	var pending struct {
+		RequestID string `json:"request_id"`
+	}
+	body := map[string]string{"code": code.Code, "device_id": "phone", "device_name": "phone"}
+	if status := srv.JSON("POST", "/api/v2/auth/pair", body, &pending); status != http.StatusAccepted {
+		t.Fatalf("pair: status %d", status)
+	}
+
+	approve := func(header http.Header) int {
+		req, _ := http.NewRequest("POST", srv.URL+"/api/v2/auth/pair/approve?id="+pending.RequestID, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := approve(nil); status != http.StatusForbidden {
+		t.Fatalf("approve without header: status %d, want 403", status)
+	}
+	crossSite := http.Header{"X-Echohelix-Pairing-Decision": {"approve"}, "Origin": {"https://example.com"}}
+	if status := approve(crossSite); status != http.StatusForbidden {
+		t.Fatalf("approve from another origin: status %d, want 403", status)
+	}
+	if status := approve(http.Header{"X-Echohelix-Pairing-Decision": {"approve"}}); status != http.StatusOK {
+		t.Fatalf("approve: status %d", status)
+	}
+}
+
+func TestE2ERequestsBoundAndOnce(t *testing.T) {
+	srv := New(t)
+	device, err := e2e.LoadOrCreateIdentity(filepath.Join(t.TempDir(), "device_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := srv.Bridge.Auth().GeneratePairingCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paired struct {
+		Token           string `json:"token"`
+		BridgePublicKey string `json:"bridge_public_key"`
+	}
+	body := map[string]string{"code": pc.Code, "device_id": "e2e-phone", "public_key": device.PublicKey()}
+	if status := srv.JSON("POST", "/api/v2/auth/pair", body, &paired); status != http.StatusOK {
+		t.Fatalf("pair: status %d", status)
+	}
+	key, err := device.DeviceKey(paired.BridgePublicKey, "e2e-phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(sealedFor, path, id string) int {
+		envelope, err := e2e.Seal(key, nil, []byte("GET "+sealedFor+" "+id+" request"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+paired.Token)
+		req.Header.Set("X-EchoHelix-E2E", e2e.Version)
+		req.Header.Set("X-EchoHelix-E2E-ID", id)
+		req.Header.Set("X-EchoHelix-E2E-Envelope", base64.StdEncoding.EncodeToString(envelope))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	id := strconv.FormatInt(time.Now().Unix(), 10) + ".first"
+	if status := send("/api/v2/sessions?limit=1", "/api/v2/sessions?limit=1", id); status != http.StatusOK {
+		t.Fatalf("encrypted request: status %d", status)
+	}
+	if status := send("/api/v2/sessions?limit=1", "/api/v2/sessions?limit=1", id); status != http.StatusBadRequest {
+		t.Fatalf("replayed request: status %d, want 400", status)
+	}
+	other := strconv.FormatInt(time.Now().Unix(), 10) + ".second"
+	if status := send("/api/v2/sessions?limit=1", "/api/v2/sessions?limit=2", other); status != http.StatusBadRequest {
+		t.Fatalf("request with an altered query: status %d, want 400", status)
+	}
+}
+
+func TestChatProxyEcho(t *testing.T) {
+	srv := New(t)
+	srv.StartEcho()
+
+	var sess session.Session
+	srv.JSON("POST", "/api/v2/session", map[string]string{"name": "chat"}, &sess)
+
+	ws := srv.Dial("/api/v2/chat/proxy?kernel=echo&events=true&session_id=" + sess.ID)
+	ws.Send(kernel.Request{Method: kernel.MethodChat, Text: "ping pong", Files: []string{"main.go"}})
+	events := ws.ReadTurn()
+
+	if got := Reply(events); got != "Echo: ping pong" {
+		t.Fatalf("reply = %q", got)
+	}
+	var tools int
+	for _, ev := range events {
+		if ev.Type == kernel.EventToolCall && ev.ToolCall.Status == "completed" {
+			tools++
+		}
+	}
+	if tools != 1 {
+		t.Fatalf("completed tool calls = %d, want 1", tools)
+	}
+
+	// 工具调用需先批准
+	ws.Send(kernel.Request{Method: kernel.MethodCommand, Command: "tool", Args: []string{"shell"}})
+	var ev kernel.Event
+	ws.Recv(&ev)
+	if ev.Type != kernel.EventToolCall || ev.ToolCall.Status != kernel.ToolAwaitingApproval {
+		t.Fatalf("first event = %+v", ev)
+	}
+	ws.Send(kernel.Request{Method: kernel.MethodApprove, CallID: ev.ToolCall.ID, Outcome: kernel.OutcomeCancel})
+	events = ws.ReadTurn()
+	if events[0].ToolCall == nil || events[0].ToolCall.Status != kernel.ToolCancelled {
+		t.Fatalf("after cancel = %+v", events[0])
+	}
+
+	// 回合写入会话记录
+	deadline := time.Now().Add(Timeout)
+	for {
+		var messages struct {
+			Messages []session.Message `json:"messages"`
+		}
+		srv.JSON("GET", "/api/v2/session/messages?session_id="+sess.ID, nil, &messages)
+		for _, m := range messages.Messages {
+			if m.Role == "assistant" && strings.Contains(m.Content, "Echo: ping pong") {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("transcript = %+v", messages.Messages)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"echohelix/bridge/internal/e2e"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Pairing request states
+const (
+	RequestPending  = "pending"
+	RequestApproved = "approved"
+	RequestDenied   = "denied"
+)
+
+// PairingRequest is a pairing held until the desktop approves it, so a
+// code read over someone's shoulder is not enough to pair. The device
+// and the desktop both show the fingerprint; the user approves only when
+// they match.
+type PairingRequest struct {
+	ID          string     `json:"id"`
+	DeviceID    string     `json:"device_id"`
+	DeviceName  string     `json:"device_name"`
+	Fingerprint string     `json:"fingerprint"`
+	RemoteAddr  string     `json:"remote_addr,omitempty"`
+	Guest       bool       `json:"guest,omitempty"`
+	Status      string     `json:"status"`
+	Client      ClientMeta `json:"client"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+
+	// 设备随请求提交的推送与加密信息，批准后再写入 Token
+	PushToken    string `json:"-"`
+	PushPlatform string `json:"-"`
+	PublicKey    string `json:"-"`
+
+	// local 表示请求来自本机，批准后授予管理权限
+	local bool
+	token *Token
+}
+
+// RequestPairing consumes a pairing code and holds the pairing for
+// approval instead of issuing a token. req describes the device; the
+// stored request is returned.
+func (s *Service) RequestPairing(code string, req PairingRequest) (PairingRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanupExpiredRequestsLocked()
+	pc, err := s.takeCodeLocked(code, req.DeviceID)
+	if err != nil {
+		return PairingRequest{}, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return PairingRequest{}, err
+	}
+	now := time.Now()
+	req.ID = "pr_" + hex.EncodeToString(id)
+	req.Fingerprint = pairingFingerprint(req.PublicKey)
+	req.Guest = pc.Guest
+	req.Status = RequestPending
+	req.CreatedAt = now
+	req.ExpiresAt = now.Add(s.codeExpiry)
+	req.token = nil
+	s.requests[req.ID] = &req
+
+	log.Info().
+		Str("request", req.ID).
+		Str("deviceID", req.DeviceID).
+		Str("deviceName", req.DeviceName).
+		Str("fingerprint", req.Fingerprint).
+		Msg("Pairing awaiting approval")
+
+	if s.onPairingRequested != nil {
+		go s.onPairingRequested(req)
+	}
+	return req, nil
+}
+
+// PairingRequests returns the requests waiting for approval, oldest first
+func (s *Service) PairingRequests() []PairingRequest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	list := make([]PairingRequest, 0, len(s.requests))
+	for _, req := range s.requests {
+		if req.Status == RequestPending && now.Before(req.ExpiresAt) {
+			list = append(list, *req)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// ApprovePairing issues the token of a pending request. The device
+// collects it with CollectPairing.
+func (s *Service) ApprovePairing(id string) (PairingRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.requests[id]
+	if !ok || req.Status != RequestPending || time.Now().After(req.ExpiresAt) {
+		return PairingRequest{}, ErrRequestNotFound
+	}
+	token, err := s.pairLocked(req.DeviceID, req.DeviceName, req.Guest)
+	if err != nil {
+		return PairingRequest{}, err
+	}
+	req.Status = RequestApproved
+	req.token = token
+	// 留出时间让设备取回 Token
+	req.ExpiresAt = time.Now().Add(s.codeExpiry)
+	return *req, nil
+}
+
+// DenyPairing refuses a pending request
+func (s *Service) DenyPairing(id string) (PairingRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.requests[id]
+	if !ok || req.Status != RequestPending || time.Now().After(req.ExpiresAt) {
+		return PairingRequest{}, ErrRequestNotFound
+	}
+	req.Status = RequestDenied
+
+	log.Info().
+		Str("request", id).
+		Str("deviceID", req.DeviceID).
+		Msg("Pairing denied")
+	return *req, nil
+}
+
+// CollectPairing reports the state of a request to the device that made
+// it. Once approved the token is returned and the request forgotten.
+func (s *Service) CollectPairing(id string) (PairingRequest, *Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.requests[id]
+	if !ok || time.Now().After(req.ExpiresAt) {
+		return PairingRequest{}, nil, ErrRequestNotFound
+	}
+	switch req.Status {
+	case RequestApproved:
+		delete(s.requests, id)
+		return *req, req.token.clone(), nil
+	case RequestDenied:
+		delete(s.requests, id)
+		return *req, nil, ErrPairingDenied
+	}
+	return *req, nil, nil
+}
+
+// OnPairingRequested sets callback for pairings awaiting approval
+func (s *Service) OnPairingRequested(callback func(req PairingRequest)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onPairingRequested = callback
+}
+
+func (s *Service) cleanupExpiredRequestsLocked() {
+	now := time.Now()
+	for id, req := range s.requests {
+		if now.After(req.ExpiresAt) {
+			delete(s.requests, id)
+		}
+	}
+}
+
+// pairingFingerprint identifies a pairing request to the user. With a
+// public key it is the key's fingerprint, which the device can show on
+// its own; otherwise it is random and the device shows the one returned.
+func pairingFingerprint(publicKey string) string {
+	var sum []byte
+	if pub, err := e2e.ParsePublicKey(publicKey); err == nil {
+		h := sha256.Sum256(pub.Bytes())
+		sum = h[:6]
+	} else {
+		sum = make([]byte, 6)
+		rand.Read(sum)
+	}
+	hexed := strings.ToUpper(hex.EncodeToString(sum))
+	return hexed[:4] + "-" + hexed[4:8] + "-" + hexed[8:]
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Token binding modes for SetTokenBinding
+const (
+	// BindIP binds a token to the exact address it was first used from
+	BindIP = "ip"
+	// BindSubnet binds a token to the /24 (IPv4) or /64 (IPv6) it was
+	// first used from, tolerating DHCP changes on the same network
+	BindSubnet = "subnet"
+)
+
+// Headers the app identifies itself with on every request
+const (
+	HeaderPlatform   = "X-Client-Platform"
+	HeaderAppVersion = "X-Client-Version"
+)
+
+// ClientMeta describes where a device connected from
+type ClientMeta struct {
+	Platform   string    `json:"platform,omitempty"`
+	AppVersion string    `json:"app_version,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	SeenAt     time.Time `json:"seen_at"`
+}
+
+// ErrNetworkChanged is returned for a bound token used from another network
+var ErrNetworkChanged = &AuthError{Code: "REAUTH_REQUIRED", Message: "Token is bound to another network; pair the device again"}
+
+// SetClientIP sets how the client address of a request is found, for
+// requests that arrive through a relay or proxy
+func (h *Handler) SetClientIP(clientIP func(r *http.Request) string) {
+	h.clientIP = clientIP
+}
+
+// SetTokenBinding installs the switch for binding tokens to the network
+// they were first used from; it returns BindIP, BindSubnet or "" for none
+func (h *Handler) SetTokenBinding(mode func() string) {
+	h.binding = mode
+}
+
+// Authenticate validates a token sent with r and records the client it
+// came from, refusing it when it is bound to another network
+func (h *Handler) Authenticate(r *http.Request, value string) (*Token, error) {
+	token, err := h.service.ValidateToken(value)
+	if err != nil {
+		return nil, err
+	}
+	binding := ""
+	if h.binding != nil {
+		binding = h.binding()
+	}
+	if err := h.service.RecordClient(value, h.clientMeta(r, "", ""), binding); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// clientMeta describes the client of r. platform and appVersion fall
+// back to the app's headers.
+func (h *Handler) clientMeta(r *http.Request, platform, appVersion string) ClientMeta {
+	if platform == "" {
+		platform = r.Header.Get(HeaderPlatform)
+	}
+	if appVersion == "" {
+		appVersion = r.Header.Get(HeaderAppVersion)
+	}
+	ip := ""
+	if h.clientIP != nil {
+		ip = h.clientIP(r)
+	} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	return ClientMeta{
+		Platform:   truncate(platform, 32),
+		AppVersion: truncate(appVersion, 32),
+		IP:         ip,
+		UserAgent:  truncate(r.UserAgent(), 256),
+		SeenAt:     time.Now(),
+	}
+}
+
+// RecordClient notes the client a token was used from. With a binding
+// mode the token is bound to the network of its first recorded use and
+// refused elsewhere.
+func (s *Service) RecordClient(tokenValue string, meta ClientMeta, binding string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[tokenValue]
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	if binding != "" && meta.IP != "" {
+		if token.BoundNetwork == "" {
+			if prefix, ok := bindPrefix(meta.IP, binding); ok {
+				token.BoundNetwork = prefix.String()
+				s.scheduleSaveLocked()
+			}
+		} else if !inNetwork(token.BoundNetwork, meta.IP) {
+			log.Warn().
+				Str("deviceID", token.DeviceID).
+				Str("ip", meta.IP).
+				Str("bound", token.BoundNetwork).
+				Msg("Token used from outside its bound network")
+			if s.onNetworkChanged != nil {
+				go s.onNetworkChanged(token.DeviceID, token.BoundNetwork, meta.IP)
+			}
+			return ErrNetworkChanged
+		}
+	}
+
+	// 客户端信息变化时才保存，避免每个请求都写盘
+	changed := token.LastSeen.IP != meta.IP || token.LastSeen.UserAgent != meta.UserAgent ||
+		token.LastSeen.Platform != meta.Platform || token.LastSeen.AppVersion != meta.AppVersion
+	if token.PairedFrom.SeenAt.IsZero() {
+		token.PairedFrom = meta
+		changed = true
+	}
+	token.LastSeen = meta
+	if changed {
+		s.scheduleSaveLocked()
+	}
+	return nil
+}
+
+// OnNetworkChanged sets callback for bound tokens used from elsewhere,
+// which may have been stolen
+func (s *Service) OnNetworkChanged(callback func(deviceID, bound, ip string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onNetworkChanged = callback
+}
+
+// bindPrefix is the network an address binds a token to
+func bindPrefix(ip, mode string) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	bits := addr.BitLen()
+	if mode == BindSubnet {
+		bits = 24
+		if addr.Is6() {
+			bits = 64
+		}
+	}
+	prefix, err := addr.Prefix(bits)
+	return prefix, err == nil
+}
+
+func inNetwork(network, ip string) bool {
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && prefix.Contains(addr.Unmap())
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
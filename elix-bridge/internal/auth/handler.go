@@ -2,14 +2,48 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
+
+	"echohelix/bridge/internal/e2e"
+	"echohelix/bridge/internal/i18n"
+
+	"github.com/rs/zerolog/log"
 )
 
+type contextKey int
+
+const tokenContextKey contextKey = iota
+
+// PairingDecisionHeader must be set on pairing approvals and denials. A
+// web page can make the user's browser send a simple cross-site POST to
+// the bridge, but not one with a custom header.
+const PairingDecisionHeader = "X-EchoHelix-Pairing-Decision"
+
+// TokenFromContext returns the token authenticated by AuthenticateMiddleware
+func TokenFromContext(ctx context.Context) (*Token, bool) {
+	token, ok := ctx.Value(tokenContextKey).(*Token)
+	return token, ok
+}
+
 // Handler handles authentication requests
 type Handler struct {
-	service *Service
+	service  *Service
+	identity *e2e.Identity
+	// pairingCheck may refuse a pairing before its code is consumed
+	pairingCheck func(r *http.Request, deviceID, deviceName string) error
+	// approvalRequired reports whether pairings wait for the desktop
+	approvalRequired func() bool
+	clientIP         func(r *http.Request) string
+	binding          func() string
+	// activity summarizes a device's recent requests for HandleStatus
+	activity func(deviceID string) interface{}
 }
 
 // NewHandler creates a new auth handler
@@ -19,7 +53,26 @@ func NewHandler(service *Service) *Handler {
 	}
 }
 
-// HandlePair handles pairing requests (Mobile App -> Bridge)
+// SetE2EIdentity enables end-to-end encryption key exchange during pairing
+func (h *Handler) SetE2EIdentity(identity *e2e.Identity) {
+	h.identity = identity
+}
+
+// SetPairingCheck installs a check that can refuse a pairing; its error
+// message is returned to the device
+func (h *Handler) SetPairingCheck(check func(r *http.Request, deviceID, deviceName string) error) {
+	h.pairingCheck = check
+}
+
+// SetApprovalRequired installs the switch for holding pairings until the
+// desktop approves them
+func (h *Handler) SetApprovalRequired(required func() bool) {
+	h.approvalRequired = required
+}
+
+// HandlePair handles pairing requests (Mobile App -> Bridge). When
+// approval is required the response is 202 with a request_id to poll
+// HandlePairStatus with, and the fingerprint to show while waiting.
 func (h *Handler) HandlePair(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -29,40 +82,203 @@ func (h *Handler) HandlePair(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Code       string `json:"code"`
-		DeviceID   string `json:"device_id"`
-		DeviceName string `json:"device_name"`
+		Code         string `json:"code"`
+		DeviceID     string `json:"device_id"`
+		DeviceName   string `json:"device_name"`
+		PushToken    string `json:"push_token"`
+		PushPlatform string `json:"push_platform"`
+		PublicKey    string `json:"public_key"` // 可选：X25519 公钥，启用端到端加密
+		Platform     string `json:"platform"`
+		AppVersion   string `json:"app_version"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request body",
-		})
+		writeError(w, "INVALID_BODY", http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if req.Code == "" || req.DeviceID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Code and Device ID are required",
+		writeError(w, "MISSING_PARAMETER", http.StatusBadRequest, "Code and Device ID are required")
+		return
+	}
+
+	// 在消耗配对码之前校验公钥
+	if req.PublicKey != "" {
+		if h.identity == nil {
+			writeError(w, "E2E_UNAVAILABLE", http.StatusBadRequest, "End-to-end encryption is not available")
+			return
+		}
+		if _, err := e2e.ParsePublicKey(req.PublicKey); err != nil {
+			writeError(w, "INVALID_PUBLIC_KEY", http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if h.pairingCheck != nil {
+		if err := h.pairingCheck(r, req.DeviceID, req.DeviceName); err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Str("device", req.DeviceID).Msg("Pairing refused")
+			writeError(w, "PAIRING_REFUSED", http.StatusForbidden, err.Error())
+			return
+		}
+	}
+
+	client := h.clientMeta(r, req.Platform, req.AppVersion)
+	if h.approvalRequired != nil && h.approvalRequired() {
+		pending, err := h.service.RequestPairing(req.Code, PairingRequest{
+			DeviceID:     req.DeviceID,
+			DeviceName:   req.DeviceName,
+			RemoteAddr:   client.IP,
+			Client:       client,
+			local:        IsLocalRequest(r),
+			PushToken:    req.PushToken,
+			PushPlatform: req.PushPlatform,
+			PublicKey:    req.PublicKey,
 		})
+		if err != nil {
+			writeError(w, errorCode(err, "INVALID_CODE"), http.StatusUnauthorized, err.Error())
+			return
+		}
+		writePending(w, pending)
 		return
 	}
 
 	token, err := h.service.ValidatePairingCode(req.Code, req.DeviceID, req.DeviceName)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeError(w, errorCode(err, "INVALID_CODE"), http.StatusUnauthorized, err.Error())
+		return
+	}
+	h.writePaired(w, r, token, client, IsLocalRequest(r), req.PushPlatform, req.PushToken, req.PublicKey)
+}
+
+// HandlePairStatus reports a pairing waiting for approval to the device
+// that made it: 202 while pending, the token once approved, 403 when
+// denied. The request ID is the credential.
+// GET /api/v2/auth/pair/status?request_id=...
+func (h *Handler) HandlePairStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("request_id")
+	if id == "" {
+		writeError(w, "MISSING_PARAMETER", http.StatusBadRequest, "request_id is required")
+		return
+	}
+	pending, token, err := h.service.CollectPairing(id)
+	switch {
+	case errors.Is(err, ErrPairingDenied):
+		writeError(w, "PAIRING_DENIED", http.StatusForbidden, err.Error())
+	case err != nil:
+		writeError(w, errorCode(err, "PAIRING_REQUEST_NOT_FOUND"), http.StatusNotFound, err.Error())
+	case token == nil:
+		writePending(w, pending)
+	default:
+		h.writePaired(w, r, token, pending.Client, pending.local, pending.PushPlatform, pending.PushToken, pending.PublicKey)
+	}
+}
+
+// HandlePairRequests lists the pairings waiting for approval (localhost only)
+// GET /api/v2/auth/pair/requests
+func (h *Handler) HandlePairRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !IsLocalRequest(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	json.NewEncoder(w).Encode(h.service.PairingRequests())
+}
+
+// HandlePairApprove lets a waiting device pair (localhost only, with
+// PairingDecisionHeader)
+// POST /api/v2/auth/pair/approve?id=...
+func (h *Handler) HandlePairApprove(w http.ResponseWriter, r *http.Request) {
+	h.decidePairing(w, r, h.service.ApprovePairing)
+}
+
+// HandlePairDeny turns a waiting device away (localhost only, with
+// PairingDecisionHeader)
+// POST /api/v2/auth/pair/deny?id=...
+func (h *Handler) HandlePairDeny(w http.ResponseWriter, r *http.Request) {
+	h.decidePairing(w, r, h.service.DenyPairing)
+}
+
+func (h *Handler) decidePairing(w http.ResponseWriter, r *http.Request, decide func(id string) (PairingRequest, error)) {
+	w.Header().Set("Content-Type", "application/json")
+	// 自定义头与 Origin 挡住其他网站经浏览器发来的请求
+	if !IsLocalRequest(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Header.Get(PairingDecisionHeader) == "" {
+		writeError(w, "FORBIDDEN", http.StatusForbidden, PairingDecisionHeader+" header is required")
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && !isLoopbackOrigin(origin) {
+		writeError(w, "FORBIDDEN", http.StatusForbidden, "cross-origin pairing decision")
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, "MISSING_PARAMETER", http.StatusBadRequest, "id is required")
+		return
+	}
+	req, err := decide(id)
+	if err != nil {
+		writeError(w, errorCode(err, "PAIRING_REQUEST_NOT_FOUND"), http.StatusNotFound, err.Error())
 		return
 	}
+	json.NewEncoder(w).Encode(req)
+}
+
+// writePending answers a device whose pairing waits for approval
+func writePending(w http.ResponseWriter, req PairingRequest) {
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      req.Status,
+		"request_id":  req.ID,
+		"fingerprint": req.Fingerprint,
+		"expires_at":  req.ExpiresAt,
+	})
+}
+
+// writePaired registers what the device sent along with pairing and
+// returns its token. client is where the pairing came from; a bound token
+// is bound to its network. Devices paired from this machine are admins.
+func (h *Handler) writePaired(w http.ResponseWriter, r *http.Request, token *Token, client ClientMeta, local bool, pushPlatform, pushToken, publicKey string) {
+	if local && !token.IsGuest() {
+		if t, err := h.service.SetAdmin(token.DeviceID, true); err == nil {
+			token = t
+		}
+	}
+	binding := ""
+	if h.binding != nil {
+		binding = h.binding()
+	}
+	if err := h.service.RecordClient(token.Value, client, binding); err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("Failed to record pairing client")
+	}
+	// 配对时可同时注册推送 Token
+	if pushToken != "" {
+		if t, err := h.service.SetPushToken(token.DeviceID, pushPlatform, pushToken); err == nil {
+			token = t
+		}
+	}
+	if publicKey != "" {
+		if t, err := h.service.SetE2EPublicKey(token.DeviceID, publicKey); err == nil {
+			token = t
+		}
+	}
 
-	json.NewEncoder(w).Encode(token)
+	resp := struct {
+		*Token
+		BridgePublicKey string `json:"bridge_public_key,omitempty"`
+	}{Token: token}
+	if publicKey != "" && h.identity != nil {
+		resp.BridgePublicKey = h.identity.PublicKey()
+	}
+	json.NewEncoder(w).Encode(resp)
 }
 
-// HandleGenerateCode generates a new pairing code (Desktop UI/CLI -> Bridge)
+// HandleGenerateCode generates a new pairing code (Desktop UI/CLI -> Bridge).
+// With ?guest=true the paired device gets a read-only token.
 // This should optimally be protected or only accessible from localhost
 func (h *Handler) HandleGenerateCode(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -74,24 +290,39 @@ func (h *Handler) HandleGenerateCode(w http.ResponseWriter, r *http.Request) {
 
 	// Helper to check if request is from localhost
 	// In production, this should have stricter checks
-	if !isLocalRequest(r) {
+	if !IsLocalRequest(r) {
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
 
-	code, err := h.service.GeneratePairingCode()
+	generate := h.service.GeneratePairingCode
+	if r.URL.Query().Get("guest") == "true" {
+		generate = h.service.GenerateGuestPairingCode
+	}
+	code, err := generate()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeError(w, "INTERNAL_ERROR", http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	json.NewEncoder(w).Encode(code)
+	// 指纹与配对码一同展示，供用户在手机上核对桥接公钥
+	resp := struct {
+		*PairingCode
+		BridgeKeyFingerprint string `json:"bridge_key_fingerprint,omitempty"`
+	}{PairingCode: code}
+	if h.identity != nil {
+		resp.BridgeKeyFingerprint = h.identity.Fingerprint()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SetActivity installs the activity summary reported by HandleStatus
+func (h *Handler) SetActivity(activity func(deviceID string) interface{}) {
+	h.activity = activity
 }
 
-// HandleStatus checks token status
+// HandleStatus checks token status and reports what the token may do,
+// how long it has left and, when tracked, the device's recent activity
 func (h *Handler) HandleStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -101,19 +332,22 @@ func (h *Handler) HandleStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenInfo, err := h.service.ValidateToken(token)
+	tokenInfo, err := h.Authenticate(r, token)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeError(w, errorCode(err, "INVALID_TOKEN"), http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "valid",
-		"token":  tokenInfo,
-	})
+	resp := map[string]interface{}{
+		"status":      "valid",
+		"token":       tokenInfo,
+		"permissions": tokenInfo.Permissions,
+		"expires_in":  int64(time.Until(tokenInfo.ExpiresAt).Seconds()),
+	}
+	if h.activity != nil {
+		resp["activity"] = h.activity(tokenInfo.DeviceID)
+	}
+	json.NewEncoder(w).Encode(resp)
 }
 
 // AuthenticateMiddleware authenticates requests
@@ -127,23 +361,42 @@ func (h *Handler) AuthenticateMiddleware(next http.HandlerFunc) http.HandlerFunc
 
 		token := extractToken(r)
 		if token == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Authentication required",
-			})
+			writeError(w, "UNAUTHORIZED", http.StatusUnauthorized, "Authentication required")
 			return
 		}
 
-		if _, err := h.service.ValidateToken(token); err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Invalid or expired token",
-			})
+		tokenInfo, err := h.Authenticate(r, token)
+		if errors.Is(err, ErrNetworkChanged) {
+			writeError(w, "REAUTH_REQUIRED", http.StatusUnauthorized, err.Error())
 			return
 		}
+		if err != nil {
+			writeError(w, errorCode(err, "INVALID_TOKEN"), http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey, tokenInfo)))
+	}
+}
 
-		next(w, r)
+// DeviceForRequest returns the device ID of the request's token without
+// validating or touching it, for logging purposes
+func (h *Handler) DeviceForRequest(r *http.Request) string {
+	if token, ok := TokenFromContext(r.Context()); ok {
+		return token.DeviceID
+	}
+	if value := extractToken(r); value != "" {
+		if token, ok := h.service.PeekToken(value); ok {
+			return token.DeviceID
+		}
 	}
+	return ""
+}
+
+// HasToken reports whether r carries a token in any of the places the
+// middleware looks, without validating it
+func HasToken(r *http.Request) bool {
+	return extractToken(r) != ""
 }
 
 // Helper functions
@@ -158,15 +411,44 @@ func extractToken(r *http.Request) string {
 		}
 	}
 
+	// Browsers cannot set headers on WebSockets, so they may offer the
+	// token as a "bearer.<token>" subprotocol. "=" is not allowed there;
+	// the padding is dropped by the client and restored here.
+	for _, protocol := range websocketProtocols(r) {
+		if token, ok := strings.CutPrefix(protocol, "bearer."); ok && token != "" {
+			if n := len(token) % 4; n != 0 {
+				token += strings.Repeat("=", 4-n)
+			}
+			return token
+		}
+	}
+
 	// Check query param
 	return r.URL.Query().Get("token")
 }
 
-func isLocalRequest(r *http.Request) bool {
+func websocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	return protocols
+}
+
+// IsLocalRequest reports whether r comes directly from this machine and
+// names it as the host. A page on a domain rebound to 127.0.0.1 connects
+// from loopback too, but sends its own domain as the Host.
+func IsLocalRequest(r *http.Request) bool {
 	// 检查 X-Forwarded-For 头（如果存在则拒绝，因为有代理）
 	if r.Header.Get("X-Forwarded-For") != "" {
 		return false
 	}
+	// 校验 Host 以防 DNS rebinding
+	if !isLoopbackHost(r.Host) {
+		return false
+	}
 
 	// 获取远程地址，去掉端口号
 	remoteAddr := r.RemoteAddr
@@ -199,3 +481,47 @@ func isLocalRequest(r *http.Request) bool {
 
 	return false
 }
+
+// isLoopbackHost reports whether host, with or without a port, names
+// this machine
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	switch strings.Trim(host, "[]") {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// isLoopbackOrigin reports whether a browser Origin is a page served
+// from this machine
+func isLoopbackOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return isLoopbackHost(u.Host)
+}
+
+// writeError writes an error body in the same shape as api.WriteError,
+// translated like it into the response's Content-Language
+func writeError(w http.ResponseWriter, code string, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":      i18n.Default.Localize(w.Header().Get("Content-Language"), "error."+code, message),
+		"code":       code,
+		"request_id": w.Header().Get("X-Request-ID"),
+	})
+}
+
+// errorCode returns the AuthError code of err, or fallback
+func errorCode(err error, fallback string) string {
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return authErr.Code
+	}
+	return fallback
+}
@@ -9,22 +9,46 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
-	"path/filepath"
 	"sync"
 	"time"
 
+	"echohelix/bridge/internal/statefile"
+	"echohelix/bridge/internal/vault"
+
 	"github.com/rs/zerolog/log"
 )
 
+// Token permissions
+const (
+	PermissionRead    = "read"
+	PermissionWrite   = "write"
+	PermissionExecute = "execute"
+	// PermissionAdmin manages the bridge itself: devices, config, the
+	// kernel process and updates
+	PermissionAdmin = "admin"
+)
+
+// FullPermissions are granted to devices paired with a normal code
+var FullPermissions = []string{PermissionRead, PermissionWrite, PermissionExecute}
+
+// AdminPermissions are granted to devices paired from this machine
+var AdminPermissions = []string{PermissionRead, PermissionWrite, PermissionExecute, PermissionAdmin}
+
+// GuestPermissions are granted to devices paired with a guest code: they
+// can watch sessions and browse files but not change anything
+var GuestPermissions = []string{PermissionRead}
+
 // PairingCode represents an active pairing code
 type PairingCode struct {
 	Code      string    `json:"code"`
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
 	DeviceID  string    `json:"device_id,omitempty"`
+	Guest     bool      `json:"guest,omitempty"`
 	Used      bool      `json:"-"`
 }
 
@@ -37,12 +61,87 @@ type Token struct {
 	ExpiresAt   time.Time `json:"expires_at"`
 	LastUsedAt  time.Time `json:"last_used_at"`
 	Permissions []string  `json:"permissions"`
+
+	// 推送通知
+	PushToken    string          `json:"push_token,omitempty"`
+	PushPlatform string          `json:"push_platform,omitempty"` // "fcm" or "apns"
+	NotifyPrefs  map[string]bool `json:"notify_prefs,omitempty"`  // category -> enabled
+
+	// 端到端加密：设备在配对时提交的 X25519 公钥
+	E2EPublicKey string `json:"e2e_public_key,omitempty"`
+
+	// 设备指纹：配对时与最近一次使用时的客户端信息
+	PairedFrom   ClientMeta `json:"paired_from"`
+	LastSeen     ClientMeta `json:"last_seen"`
+	BoundNetwork string     `json:"bound_network,omitempty"` // 绑定的 IP 或网段
+}
+
+// DeviceInfo is the public view of a paired device, without its token
+// or push credentials
+type DeviceInfo struct {
+	DeviceID     string     `json:"device_id"`
+	DeviceName   string     `json:"device_name"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	Permissions  []string   `json:"permissions"`
+	PushPlatform string     `json:"push_platform,omitempty"`
+	E2E          bool       `json:"e2e"`
+	Guest        bool       `json:"guest"`
+	Admin        bool       `json:"admin"`
+	PairedFrom   ClientMeta `json:"paired_from"`
+	LastSeen     ClientMeta `json:"last_seen"`
+	BoundNetwork string     `json:"bound_network,omitempty"`
+}
+
+// HasPermission reports whether the token grants perm
+func (t *Token) HasPermission(perm string) bool {
+	for _, p := range t.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGuest reports whether the token is read-only
+func (t *Token) IsGuest() bool {
+	return !t.HasPermission(PermissionWrite) && !t.HasPermission(PermissionExecute)
+}
+
+// clone copies a token so callers can read it without holding the
+// service lock while the service keeps changing the original
+func (t *Token) clone() *Token {
+	c := *t
+	c.Permissions = append([]string(nil), t.Permissions...)
+	c.NotifyPrefs = maps.Clone(t.NotifyPrefs)
+	return &c
+}
+
+// Info returns the device view of a token
+func (t *Token) Info() DeviceInfo {
+	return DeviceInfo{
+		DeviceID:     t.DeviceID,
+		DeviceName:   t.DeviceName,
+		CreatedAt:    t.CreatedAt,
+		ExpiresAt:    t.ExpiresAt,
+		LastUsedAt:   t.LastUsedAt,
+		Permissions:  t.Permissions,
+		PushPlatform: t.PushPlatform,
+		E2E:          t.E2EPublicKey != "",
+		Guest:        t.IsGuest(),
+		Admin:        t.HasPermission(PermissionAdmin),
+		PairedFrom:   t.PairedFrom,
+		LastSeen:     t.LastSeen,
+		BoundNetwork: t.BoundNetwork,
+	}
 }
 
 // Service provides authentication services
 type Service struct {
 	mu           sync.RWMutex
 	pairingCodes map[string]*PairingCode
+	requests     map[string]*PairingRequest // 等待桌面端批准的配对
 	tokens       map[string]*Token
 	deviceTokens map[string]string // deviceID -> tokenValue
 
@@ -50,11 +149,16 @@ type Service struct {
 	codeLength       int
 	codeExpiry       time.Duration
 	tokenExpiry      time.Duration
+	guestExpiry      time.Duration
 	maxActiveDevices int
 	storagePath      string
+	vault            *vault.Vault
+	saveTimer        *time.Timer // 防抖保存，受 mu 保护
 
 	// 回调
-	onPairingComplete func(deviceID, deviceName string)
+	onPairingComplete  func(deviceID, deviceName string)
+	onPairingRequested func(req PairingRequest)
+	onNetworkChanged   func(deviceID, bound, ip string)
 }
 
 // ServiceConfig configures the auth service
@@ -62,8 +166,10 @@ type ServiceConfig struct {
 	CodeLength       int           // 配对码长度，默认 6
 	CodeExpiry       time.Duration // 配对码过期时间，默认 5 分钟
 	TokenExpiry      time.Duration // Token 过期时间，默认 30 天
+	GuestExpiry      time.Duration // 访客 Token 过期时间，默认 24 小时
 	MaxActiveDevices int           // 最大活跃设备数，默认 5
 	StoragePath      string        // 持久化存储路径，空则不持久化
+	Vault            *vault.Vault  // 非空时加密保存状态
 }
 
 // DefaultConfig returns default configuration
@@ -72,6 +178,7 @@ func DefaultConfig() ServiceConfig {
 		CodeLength:       6,
 		CodeExpiry:       5 * time.Minute,
 		TokenExpiry:      30 * 24 * time.Hour,
+		GuestExpiry:      24 * time.Hour,
 		MaxActiveDevices: 5,
 	}
 }
@@ -87,19 +194,25 @@ func NewService(config ServiceConfig) *Service {
 	if config.TokenExpiry == 0 {
 		config.TokenExpiry = 30 * 24 * time.Hour
 	}
+	if config.GuestExpiry == 0 {
+		config.GuestExpiry = 24 * time.Hour
+	}
 	if config.MaxActiveDevices == 0 {
 		config.MaxActiveDevices = 5
 	}
 
 	s := &Service{
 		pairingCodes:     make(map[string]*PairingCode),
+		requests:         make(map[string]*PairingRequest),
 		tokens:           make(map[string]*Token),
 		deviceTokens:     make(map[string]string),
 		codeLength:       config.CodeLength,
 		codeExpiry:       config.CodeExpiry,
 		tokenExpiry:      config.TokenExpiry,
+		guestExpiry:      config.GuestExpiry,
 		maxActiveDevices: config.MaxActiveDevices,
 		storagePath:      config.StoragePath,
+		vault:            config.Vault,
 	}
 
 	// 尝试从磁盘加载已保存的 Token
@@ -109,14 +222,21 @@ func NewService(config ServiceConfig) *Service {
 		}
 	}
 
-	// 启动过期清理 goroutine
-	go s.cleanupExpired()
-
 	return s
 }
 
 // GeneratePairingCode generates a new pairing code
 func (s *Service) GeneratePairingCode() (*PairingCode, error) {
+	return s.generatePairingCode(false)
+}
+
+// GenerateGuestPairingCode generates a pairing code whose device gets a
+// short-lived read-only token
+func (s *Service) GenerateGuestPairingCode() (*PairingCode, error) {
+	return s.generatePairingCode(true)
+}
+
+func (s *Service) generatePairingCode(guest bool) (*PairingCode, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -142,23 +262,39 @@ func (s *Service) GeneratePairingCode() (*PairingCode, error) {
 		Code:      code,
 		CreatedAt: now,
 		ExpiresAt: now.Add(s.codeExpiry),
+		Guest:     guest,
 	}
 
 	s.pairingCodes[code] = pc
 
 	log.Info().
 		Str("code", code).
+		Bool("guest", guest).
 		Time("expires", pc.ExpiresAt).
 		Msg("Pairing code generated")
 
 	return pc, nil
 }
 
-// ValidatePairingCode validates a pairing code and issues a token
+// ValidatePairingCode validates a pairing code and issues a token. The
+// other outstanding codes expire with it.
 func (s *Service) ValidatePairingCode(code, deviceID, deviceName string) (*Token, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	pc, err := s.takeCodeLocked(code, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.pairLocked(deviceID, deviceName, pc.Guest)
+	if err != nil {
+		return nil, err
+	}
+	return token.clone(), nil
+}
+
+// takeCodeLocked checks a pairing code and marks it used. Must hold s.mu.
+func (s *Service) takeCodeLocked(code, deviceID string) (*PairingCode, error) {
 	pc, exists := s.pairingCodes[code]
 	if !exists {
 		return nil, ErrInvalidCode
@@ -173,6 +309,16 @@ func (s *Service) ValidatePairingCode(code, deviceID, deviceName string) (*Token
 		return nil, ErrCodeAlreadyUsed
 	}
 
+	// 标记配对码已使用
+	pc.Used = true
+	pc.DeviceID = deviceID
+	delete(s.pairingCodes, code)
+	return pc, nil
+}
+
+// pairLocked issues a paired device its token and expires the remaining
+// codes. Must hold s.mu.
+func (s *Service) pairLocked(deviceID, deviceName string, guest bool) (*Token, error) {
 	// 检查设备数量限制
 	activeCount := 0
 	for _, token := range s.tokens {
@@ -185,20 +331,23 @@ func (s *Service) ValidatePairingCode(code, deviceID, deviceName string) (*Token
 		s.removeOldestTokenLocked()
 	}
 
-	// 标记配对码已使用
-	pc.Used = true
-	pc.DeviceID = deviceID
-	delete(s.pairingCodes, code)
+	// 其余未用的配对码一并作废，避免屏幕上仍显示的码被他人使用
+	expired := len(s.pairingCodes)
+	s.pairingCodes = make(map[string]*PairingCode)
 
 	// 生成 Token
-	token, err := s.createTokenLocked(deviceID, deviceName)
+	token, err := s.createTokenLocked(deviceID, deviceName, guest)
 	if err != nil {
 		return nil, err
 	}
 
+	s.scheduleSaveLocked()
+
 	log.Info().
 		Str("deviceID", deviceID).
 		Str("deviceName", deviceName).
+		Bool("guest", guest).
+		Int("codes_expired", expired).
 		Msg("Device paired successfully")
 
 	if s.onPairingComplete != nil {
@@ -221,13 +370,25 @@ func (s *Service) ValidateToken(tokenValue string) (*Token, error) {
 	if time.Now().After(token.ExpiresAt) {
 		delete(s.tokens, tokenValue)
 		delete(s.deviceTokens, token.DeviceID)
+		s.scheduleSaveLocked()
 		return nil, ErrTokenExpired
 	}
 
 	// 更新最后使用时间
 	token.LastUsedAt = time.Now()
 
-	return token, nil
+	return token.clone(), nil
+}
+
+// PeekToken looks up a token without validating expiry or updating last use
+func (s *Service) PeekToken(tokenValue string) (*Token, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.tokens[tokenValue]
+	if !ok {
+		return nil, false
+	}
+	return token.clone(), true
 }
 
 // RevokeToken revokes a token
@@ -242,6 +403,7 @@ func (s *Service) RevokeToken(tokenValue string) bool {
 
 	delete(s.tokens, tokenValue)
 	delete(s.deviceTokens, token.DeviceID)
+	s.scheduleSaveLocked()
 
 	log.Info().
 		Str("deviceID", token.DeviceID).
@@ -262,6 +424,7 @@ func (s *Service) RevokeDevice(deviceID string) bool {
 
 	delete(s.tokens, tokenValue)
 	delete(s.deviceTokens, deviceID)
+	s.scheduleSaveLocked()
 
 	log.Info().
 		Str("deviceID", deviceID).
@@ -270,7 +433,7 @@ func (s *Service) RevokeDevice(deviceID string) bool {
 	return true
 }
 
-// ListActiveDevices returns all active paired devices
+// ListActiveDevices returns copies of all active paired devices
 func (s *Service) ListActiveDevices() []*Token {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -280,13 +443,42 @@ func (s *Service) ListActiveDevices() []*Token {
 
 	for _, token := range s.tokens {
 		if now.Before(token.ExpiresAt) {
-			devices = append(devices, token)
+			devices = append(devices, token.clone())
 		}
 	}
 
 	return devices
 }
 
+// SetAdmin grants or withdraws a device's admin permission. Guest
+// devices cannot be made admins.
+func (s *Service) SetAdmin(deviceID string, admin bool) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokenValue, exists := s.deviceTokens[deviceID]
+	if !exists {
+		return nil, ErrInvalidToken
+	}
+	token := s.tokens[tokenValue]
+	if token.IsGuest() {
+		return nil, ErrGuestAdmin
+	}
+	if admin {
+		token.Permissions = append([]string(nil), AdminPermissions...)
+	} else {
+		token.Permissions = append([]string(nil), FullPermissions...)
+	}
+	s.scheduleSaveLocked()
+
+	log.Info().
+		Str("deviceID", deviceID).
+		Bool("admin", admin).
+		Msg("Device admin permission changed")
+
+	return token.clone(), nil
+}
+
 // RefreshToken extends token expiry
 func (s *Service) RefreshToken(tokenValue string) (*Token, error) {
 	s.mu.Lock()
@@ -300,14 +492,83 @@ func (s *Service) RefreshToken(tokenValue string) (*Token, error) {
 	if time.Now().After(token.ExpiresAt) {
 		delete(s.tokens, tokenValue)
 		delete(s.deviceTokens, token.DeviceID)
+		s.scheduleSaveLocked()
 		return nil, ErrTokenExpired
 	}
 
 	// 延长过期时间
-	token.ExpiresAt = time.Now().Add(s.tokenExpiry)
+	expiry := s.tokenExpiry
+	if token.IsGuest() {
+		expiry = s.guestExpiry
+	}
+	token.ExpiresAt = time.Now().Add(expiry)
 	token.LastUsedAt = time.Now()
+	s.scheduleSaveLocked()
 
-	return token, nil
+	return token.clone(), nil
+}
+
+// SetPushToken registers the push notification token for a device
+func (s *Service) SetPushToken(deviceID, platform, pushToken string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[s.deviceTokens[deviceID]]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	token.PushPlatform = platform
+	token.PushToken = pushToken
+	s.scheduleSaveLocked()
+
+	log.Info().
+		Str("deviceID", deviceID).
+		Str("platform", platform).
+		Msg("Push token registered")
+
+	return token.clone(), nil
+}
+
+// SetE2EPublicKey records the device's end-to-end encryption public key
+func (s *Service) SetE2EPublicKey(deviceID, publicKey string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[s.deviceTokens[deviceID]]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	token.E2EPublicKey = publicKey
+	s.scheduleSaveLocked()
+
+	log.Info().
+		Str("deviceID", deviceID).
+		Msg("E2E key registered")
+
+	return token.clone(), nil
+}
+
+// SetNotifyPrefs merges per-category notification preferences for a device
+func (s *Service) SetNotifyPrefs(deviceID string, prefs map[string]bool) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[s.deviceTokens[deviceID]]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	if token.NotifyPrefs == nil {
+		token.NotifyPrefs = make(map[string]bool)
+	}
+	for category, enabled := range prefs {
+		token.NotifyPrefs[category] = enabled
+	}
+	s.scheduleSaveLocked()
+
+	return token.clone(), nil
 }
 
 // OnPairingComplete sets callback for successful pairing
@@ -317,14 +578,14 @@ func (s *Service) OnPairingComplete(callback func(deviceID, deviceName string))
 	s.onPairingComplete = callback
 }
 
-// GetActivePairingCode returns current active pairing code if exists
+// GetActivePairingCode returns current active (non-guest) pairing code if exists
 func (s *Service) GetActivePairingCode() *PairingCode {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	now := time.Now()
 	for _, pc := range s.pairingCodes {
-		if !pc.Used && now.Before(pc.ExpiresAt) {
+		if !pc.Used && !pc.Guest && now.Before(pc.ExpiresAt) {
 			return pc
 		}
 	}
@@ -333,7 +594,7 @@ func (s *Service) GetActivePairingCode() *PairingCode {
 
 // Internal methods
 
-func (s *Service) createTokenLocked(deviceID, deviceName string) (*Token, error) {
+func (s *Service) createTokenLocked(deviceID, deviceName string, guest bool) (*Token, error) {
 	// 如果设备已有 token，先删除
 	if oldToken, exists := s.deviceTokens[deviceID]; exists {
 		delete(s.tokens, oldToken)
@@ -345,14 +606,18 @@ func (s *Service) createTokenLocked(deviceID, deviceName string) (*Token, error)
 	}
 
 	now := time.Now()
+	expiry, permissions := s.tokenExpiry, FullPermissions
+	if guest {
+		expiry, permissions = s.guestExpiry, GuestPermissions
+	}
 	token := &Token{
 		Value:       tokenValue,
 		DeviceID:    deviceID,
 		DeviceName:  deviceName,
 		CreatedAt:   now,
-		ExpiresAt:   now.Add(s.tokenExpiry),
+		ExpiresAt:   now.Add(expiry),
 		LastUsedAt:  now,
-		Permissions: []string{"read", "write", "execute"},
+		Permissions: append([]string(nil), permissions...),
 	}
 
 	s.tokens[tokenValue] = token
@@ -390,31 +655,31 @@ func (s *Service) cleanupExpiredCodesLocked() {
 	}
 }
 
-func (s *Service) cleanupExpired() {
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		s.mu.Lock()
+// CleanupInterval is how often CleanupExpired should run
+const CleanupInterval = 10 * time.Minute
 
-		now := time.Now()
+// CleanupExpired drops expired pairing codes, pairing requests and tokens
+func (s *Service) CleanupExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		// 清理过期配对码
-		for code, pc := range s.pairingCodes {
-			if now.After(pc.ExpiresAt) || pc.Used {
-				delete(s.pairingCodes, code)
-			}
-		}
+	now := time.Now()
 
-		// 清理过期 Token
-		for tokenValue, token := range s.tokens {
-			if now.After(token.ExpiresAt) {
-				delete(s.tokens, tokenValue)
-				delete(s.deviceTokens, token.DeviceID)
-			}
+	// 清理过期配对码
+	s.cleanupExpiredCodesLocked()
+	s.cleanupExpiredRequestsLocked()
+
+	// 清理过期 Token
+	expired := 0
+	for tokenValue, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, tokenValue)
+			delete(s.deviceTokens, token.DeviceID)
+			expired++
 		}
-
-		s.mu.Unlock()
+	}
+	if expired > 0 {
+		s.scheduleSaveLocked()
 	}
 }
 
@@ -459,6 +724,9 @@ func HashToken(token string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// saveDelay is how long state must be unchanged before it is saved
+const saveDelay = 2 * time.Second
+
 // persistedState represents the data saved to disk
 type persistedState struct {
 	Tokens       map[string]*Token `json:"tokens"`
@@ -466,7 +734,9 @@ type persistedState struct {
 	SavedAt      time.Time         `json:"saved_at"`
 }
 
-// SaveState saves tokens to disk for persistence
+// SaveState saves tokens to disk for persistence. The file is replaced
+// atomically and the previous copy kept as a backup. Changes to tokens
+// schedule it on their own; see scheduleSaveLocked.
 func (s *Service) SaveState() error {
 	if s.storagePath == "" {
 		return nil // 未配置持久化
@@ -482,7 +752,8 @@ func (s *Service) SaveState() error {
 	now := time.Now()
 	for k, v := range s.tokens {
 		if now.Before(v.ExpiresAt) {
-			state.Tokens[k] = v
+			// 复制一份，序列化时不再持有锁
+			state.Tokens[k] = v.clone()
 		}
 	}
 	for k, v := range s.deviceTokens {
@@ -492,18 +763,7 @@ func (s *Service) SaveState() error {
 	}
 	s.mu.RUnlock()
 
-	// 确保目录存在
-	dir := filepath.Dir(s.storagePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create storage dir: %w", err)
-	}
-
-	data, err := json.MarshalIndent(state, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
-	}
-
-	if err := os.WriteFile(s.storagePath, data, 0600); err != nil {
+	if err := statefile.Write(s.storagePath, state, 0600, s.vault); err != nil {
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
@@ -511,25 +771,53 @@ func (s *Service) SaveState() error {
 	return nil
 }
 
-// LoadState loads tokens from disk
+// scheduleSaveLocked saves the state once changes have settled for
+// saveDelay, so a burst of updates is written once. Must hold s.mu.
+func (s *Service) scheduleSaveLocked() {
+	if s.storagePath == "" {
+		return
+	}
+	if s.saveTimer != nil {
+		s.saveTimer.Reset(saveDelay)
+		return
+	}
+	s.saveTimer = time.AfterFunc(saveDelay, func() {
+		s.mu.Lock()
+		s.saveTimer = nil
+		s.mu.Unlock()
+		if err := s.SaveState(); err != nil {
+			log.Warn().Err(err).Msg("Failed to save auth state")
+		}
+	})
+}
+
+// Flush writes a pending save at once, for shutdown
+func (s *Service) Flush() error {
+	s.mu.Lock()
+	pending := s.saveTimer != nil && s.saveTimer.Stop()
+	s.saveTimer = nil
+	s.mu.Unlock()
+	if !pending {
+		return nil
+	}
+	return s.SaveState()
+}
+
+// LoadState loads tokens from disk, falling back to the backup copy when
+// the file is damaged
 func (s *Service) LoadState() error {
 	if s.storagePath == "" {
 		return nil
 	}
 
-	data, err := os.ReadFile(s.storagePath)
-	if err != nil {
-		if os.IsNotExist(err) {
+	var state persistedState
+	if err := statefile.Read(s.storagePath, &state, s.vault); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
 			return nil // 文件不存在，跳过
 		}
 		return fmt.Errorf("failed to read state file: %w", err)
 	}
 
-	var state persistedState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return fmt.Errorf("failed to unmarshal state: %w", err)
-	}
-
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -559,6 +847,9 @@ var (
 	ErrCodeAlreadyUsed = &AuthError{Code: "CODE_USED", Message: "Pairing code already used"}
 	ErrInvalidToken    = &AuthError{Code: "INVALID_TOKEN", Message: "Invalid token"}
 	ErrTokenExpired    = &AuthError{Code: "TOKEN_EXPIRED", Message: "Token has expired"}
+	ErrRequestNotFound = &AuthError{Code: "PAIRING_REQUEST_NOT_FOUND", Message: "Pairing request not found or expired"}
+	ErrGuestAdmin      = &AuthError{Code: "GUEST_ADMIN", Message: "Guest devices cannot be admins"}
+	ErrPairingDenied   = &AuthError{Code: "PAIRING_DENIED", Message: "Pairing was denied on the desktop"}
 )
 
 // AuthError represents an authentication error
@@ -0,0 +1,428 @@
+// Package backup provides archiving and restoring of bridge state for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FormatVersion is written to the manifest; archives from newer versions
+// are refused
+const FormatVersion = 1
+
+// Archive layout: the manifest first, then the data directory under
+// data/ and the .env file as config/.env
+const (
+	manifestName = "manifest.json"
+	dataPrefix   = "data/"
+	envName      = "config/.env"
+)
+
+// BackupsDir is the subdirectory of the data directory that holds
+// automatic backups; it is never archived itself
+const BackupsDir = "backups"
+
+// skippedDirs are data subdirectories left out of archives: the backups
+// themselves and caches that are rebuilt on demand
+var skippedDirs = map[string]bool{
+	BackupsDir: true,
+	"semantic": true,
+}
+
+// secretFiles are left out of archives made with ExcludeSecrets
+var secretFiles = map[string]bool{
+	"identity_key": true,
+}
+
+// ErrInvalidArchive is returned when a restore input is not a backup
+var ErrInvalidArchive = errors.New("not an EchoHelix backup archive")
+
+// Manifest describes the contents of an archive
+type Manifest struct {
+	Version         int       `json:"version"`
+	CreatedAt       time.Time `json:"created_at"`
+	Host            string    `json:"host,omitempty"`
+	SecretsExcluded bool      `json:"secrets_excluded"`
+	Files           []string  `json:"files"`
+}
+
+// Options controls what goes into an archive and where it is restored
+type Options struct {
+	// DataDir is the bridge data directory, normally ~/.echohelix
+	DataDir string
+	// EnvFile is the .env config file; empty leaves config out
+	EnvFile string
+	// ExcludeSecrets drops the identity key and blanks secret values in
+	// the config (API keys, tokens, passwords)
+	ExcludeSecrets bool
+}
+
+// Write archives the data directory and config to w as tar.gz
+func Write(w io.Writer, opts Options) (*Manifest, error) {
+	files, err := collect(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	manifest := &Manifest{
+		Version:         FormatVersion,
+		CreatedAt:       time.Now().UTC(),
+		Host:            host,
+		SecretsExcluded: opts.ExcludeSecrets,
+		Files:           files,
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := writeEntry(tw, manifestName, data, manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	for _, name := range files {
+		if name == envName {
+			data, err := os.ReadFile(opts.EnvFile)
+			if err != nil {
+				return nil, err
+			}
+			if opts.ExcludeSecrets {
+				data = redactEnv(data)
+			}
+			if err := writeEntry(tw, envName, data, time.Now()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := copyFile(tw, name, filepath.Join(opts.DataDir, filepath.FromSlash(strings.TrimPrefix(name, dataPrefix)))); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// collect lists the archive names of the files to back up
+func collect(opts Options) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(opts.DataDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(opts.DataDir, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if skippedDirs[rel] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// 跳过 socket 等非普通文件
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if opts.ExcludeSecrets && secretFiles[rel] {
+			return nil
+		}
+		files = append(files, dataPrefix+rel)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if opts.EnvFile != "" {
+		if _, err := os.Stat(opts.EnvFile); err == nil {
+			files = append(files, envName)
+		}
+	}
+	return files, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func copyFile(tw *tar.Writer, name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		// 遍历后被删除的文件直接跳过
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, info.Size())
+	return err
+}
+
+// Restore extracts an archive written by Write over opts.DataDir and
+// opts.EnvFile. Files not in the archive are left alone. When the archive
+// was made without secrets, the secret config values and identity key
+// already present are kept.
+func Restore(r io.Reader, opts Options) (*Manifest, error) {
+	return extract(r, opts, true)
+}
+
+// extract reads an archive, checking every entry, and writes the files
+// out when apply is set
+func extract(r io.Reader, opts Options, apply bool) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != manifestName {
+		return nil, ErrInvalidArchive
+	}
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, ErrInvalidArchive
+	}
+	if manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("backup format %d is newer than this bridge supports (%d)", manifest.Version, FormatVersion)
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		switch {
+		case name == envName:
+			if opts.EnvFile == "" || !apply {
+				continue
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if manifest.SecretsExcluded {
+				data = mergeEnv(data, opts.EnvFile)
+			}
+			if err := writeFileAtomic(opts.EnvFile, bytes.NewReader(data), 0644); err != nil {
+				return nil, err
+			}
+
+		case strings.HasPrefix(name, dataPrefix):
+			rel := strings.TrimPrefix(name, dataPrefix)
+			// 拒绝越出数据目录的路径
+			if rel == "" || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+				return nil, fmt.Errorf("invalid path in archive: %s", hdr.Name)
+			}
+			if !apply {
+				continue
+			}
+			mode := os.FileMode(hdr.Mode).Perm()
+			if mode == 0 {
+				mode = 0600
+			}
+			if err := writeFileAtomic(filepath.Join(opts.DataDir, filepath.FromSlash(rel)), tr, mode); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &manifest, nil
+}
+
+// PendingName is the archive Stage leaves in the backups directory for
+// ApplyPending
+const PendingName = "restore-pending.tar.gz"
+
+// Stage checks an archive and keeps it in the backups directory, to be
+// restored by ApplyPending at the next start. Restoring under a running
+// bridge would be undone by its services saving the state they hold.
+func Stage(r io.Reader, opts Options) (*Manifest, error) {
+	dir := filepath.Join(opts.DataDir, BackupsDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, ".partial-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	manifest, err := extract(tmp, opts, false)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, PendingName)); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// HasPending reports whether Stage left an archive in dataDir
+func HasPending(dataDir string) bool {
+	_, err := os.Stat(filepath.Join(dataDir, BackupsDir, PendingName))
+	return err == nil
+}
+
+// ApplyPending restores the archive left by Stage and removes it; it
+// returns nil when none is staged. It must run before anything loads
+// the state it replaces.
+func ApplyPending(opts Options) (*Manifest, error) {
+	pending := filepath.Join(opts.DataDir, BackupsDir, PendingName)
+	f, err := os.Open(pending)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := Restore(f, opts)
+	f.Close()
+	// 成败都移除，失败的归档不会在每次启动时被反复套用
+	if rmErr := os.Remove(pending); err == nil {
+		err = rmErr
+	}
+	return manifest, err
+}
+
+// writeFileAtomic writes via a temporary file so an interrupted restore
+// never leaves a truncated file behind
+func writeFileAtomic(dst string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// IsSecretKey reports whether a config key holds a credential
+func IsSecretKey(key string) bool {
+	key = strings.ToUpper(key)
+	for _, s := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "PASSPHRASE"} {
+		if strings.HasSuffix(key, s) || strings.Contains(key, s+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// redactEnv blanks the values of secret keys in .env content
+func redactEnv(data []byte) []byte {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if key, _, ok := strings.Cut(strings.TrimSpace(line), "="); ok && !strings.HasPrefix(key, "#") && IsSecretKey(key) {
+			line = key + "="
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.Bytes()
+}
+
+// mergeEnv fills the blanked secrets of restored .env content with the
+// values currently in existingPath
+func mergeEnv(data []byte, existingPath string) []byte {
+	existing := map[string]string{}
+	if current, err := os.ReadFile(existingPath); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(current))
+		for scanner.Scan() {
+			if key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "="); ok {
+				existing[key] = value
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok && value == "" && IsSecretKey(key) {
+			if v, ok := existing[key]; ok {
+				line = key + "=" + v
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,227 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Info describes a stored backup file
+type Info struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RotationConfig configures automatic backups
+type RotationConfig struct {
+	Options
+	// Interval between automatic backups; 0 disables the schedule but
+	// still allows Save
+	Interval time.Duration
+	// Keep is how many backups to retain (default 7)
+	Keep int
+}
+
+// Rotator writes backups to <DataDir>/backups on a schedule and prunes
+// the oldest beyond Keep
+type Rotator struct {
+	cfg  RotationConfig
+	dir  string
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+const filePrefix = "echohelix-"
+const fileSuffix = ".tar.gz"
+
+// NewRotator creates a rotator and starts its schedule
+func NewRotator(cfg RotationConfig) *Rotator {
+	if cfg.Keep <= 0 {
+		cfg.Keep = 7
+	}
+	r := &Rotator{
+		cfg:  cfg,
+		dir:  filepath.Join(cfg.DataDir, BackupsDir),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if cfg.Interval > 0 {
+		go r.loop()
+	} else {
+		close(r.done)
+	}
+	return r
+}
+
+func (r *Rotator) loop() {
+	defer close(r.done)
+
+	// 启动时若距上次备份已超过间隔则立即补做
+	wait := r.cfg.Interval
+	if list, err := r.List(); err == nil {
+		if len(list) == 0 {
+			wait = time.Minute
+		} else if since := time.Since(list[0].CreatedAt); since >= r.cfg.Interval {
+			wait = time.Minute
+		} else {
+			wait = r.cfg.Interval - since
+		}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if info, err := r.Save(""); err != nil {
+				log.Error().Err(err).Str("component", "backup").Msg("Automatic backup failed")
+			} else {
+				log.Info().Str("component", "backup").Str("file", info.Name).Int64("size", info.Size).Msg("Automatic backup written")
+			}
+			timer.Reset(r.cfg.Interval)
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Save writes a backup now. label, if given, is appended to the file
+// name (e.g. "pre-restore"). Old backups beyond Keep are removed.
+func (r *Rotator) Save(label string) (*Info, error) {
+	return r.SaveWith(label, r.cfg.Options)
+}
+
+// SaveWith is Save with opts in place of the rotator's own options, for a
+// one-off archive such as one without secrets
+func (r *Rotator) SaveWith(label string, opts Options) (*Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	name := filePrefix + now.Format("20060102-150405")
+	if label != "" {
+		name += "-" + label
+	}
+	name += fileSuffix
+
+	tmp, err := os.CreateTemp(r.dir, ".partial-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := Write(tmp, opts); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	dst := filepath.Join(r.dir, name)
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return nil, err
+	}
+
+	r.prune()
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		return nil, err
+	}
+	return &Info{Name: name, Size: info.Size(), CreatedAt: info.ModTime()}, nil
+}
+
+// prune removes the oldest backups beyond Keep. Callers hold r.mu.
+func (r *Rotator) prune() {
+	list, err := r.List()
+	if err != nil {
+		return
+	}
+	for _, info := range list[min(len(list), r.cfg.Keep):] {
+		if err := os.Remove(filepath.Join(r.dir, info.Name)); err != nil {
+			log.Warn().Err(err).Str("component", "backup").Str("file", info.Name).Msg("Failed to remove old backup")
+		}
+	}
+}
+
+// Trim removes the oldest backups until the rest fit in maxBytes,
+// always keeping the newest, and returns the names removed
+func (r *Rotator) Trim(maxBytes int64) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, err := r.List()
+	if err != nil || len(list) == 0 {
+		return nil
+	}
+	var total int64
+	for _, info := range list {
+		total += info.Size
+	}
+	var removed []string
+	for i := len(list) - 1; i > 0 && total > maxBytes; i-- {
+		if err := os.Remove(filepath.Join(r.dir, list[i].Name)); err != nil {
+			log.Warn().Err(err).Str("component", "backup").Str("file", list[i].Name).Msg("Failed to remove old backup")
+			continue
+		}
+		total -= list[i].Size
+		removed = append(removed, list[i].Name)
+	}
+	return removed
+}
+
+// List returns the stored backups, newest first
+func (r *Rotator) List() ([]Info, error) {
+	entries, err := os.ReadDir(r.dir)
+	if os.IsNotExist(err) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Info{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), filePrefix) || !strings.HasSuffix(e.Name(), fileSuffix) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, Info{Name: e.Name(), Size: fi.Size(), CreatedAt: fi.ModTime()})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list, nil
+}
+
+// Open opens a stored backup by name
+func (r *Rotator) Open(name string) (*os.File, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, filePrefix) {
+		return nil, os.ErrNotExist
+	}
+	return os.Open(filepath.Join(r.dir, name))
+}
+
+// Close stops the schedule
+func (r *Rotator) Close() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	<-r.done
+}
@@ -0,0 +1,457 @@
+// Package changes provides a review queue for proposed file edits for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package changes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Action is what a change does to a file
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionModify Action = "modify"
+	ActionDelete Action = "delete"
+)
+
+// Status is the review state of a change
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApplied  Status = "applied"
+	StatusRejected Status = "rejected"
+)
+
+// ChangeError is a review queue failure with a stable code
+type ChangeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty"`
+}
+
+func (e *ChangeError) Error() string {
+	if e.Path != "" {
+		return e.Message + ": " + e.Path
+	}
+	return e.Message
+}
+
+var (
+	ErrNotFound   = &ChangeError{Code: "CHANGE_NOT_FOUND", Message: "Change not found"}
+	ErrNotPending = &ChangeError{Code: "CHANGE_NOT_PENDING", Message: "Change was already applied or rejected"}
+	ErrNoChanges  = &ChangeError{Code: "INVALID_CHANGE", Message: "The proposal does not change any file"}
+)
+
+// invalid returns an INVALID_CHANGE error about path
+func invalid(message, path string) error {
+	return &ChangeError{Code: "INVALID_CHANGE", Message: message, Path: path}
+}
+
+// conflict reports a file that changed on disk since it was proposed
+func conflict(path string) error {
+	return &ChangeError{Code: "CHANGE_CONFLICT", Message: "File was modified after the change was proposed", Path: path}
+}
+
+// Edit is one file operation of a proposal: new Content, Delete, or
+// replacing the single occurrence of Old with New
+type Edit struct {
+	Path    string  `json:"path"`
+	Content *string `json:"content,omitempty"`
+	Delete  bool    `json:"delete,omitempty"`
+	Old     string  `json:"old,omitempty"`
+	New     string  `json:"new,omitempty"`
+}
+
+// FileChange is the proposed state of one file
+type FileChange struct {
+	Path      string `json:"path"`
+	Action    Action `json:"action"`
+	Content   string `json:"content,omitempty"`
+	BaseHash  string `json:"base_hash,omitempty"` // 提议时文件的哈希，新建为空
+	Diff      string `json:"diff"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// Change is a set of file edits awaiting review
+type Change struct {
+	ID          string       `json:"id"`
+	Workspace   string       `json:"workspace"`
+	Description string       `json:"description,omitempty"`
+	Source      string       `json:"source,omitempty"`
+	Status      Status       `json:"status"`
+	Files       []FileChange `json:"files"`
+	Additions   int          `json:"additions"`
+	Deletions   int          `json:"deletions"`
+	Reason      string       `json:"reason,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	ResolvedAt  *time.Time   `json:"resolved_at,omitempty"`
+}
+
+// Diff returns the change's files as one unified diff
+func (c Change) Diff() string {
+	var b strings.Builder
+	for _, f := range c.Files {
+		b.WriteString(f.Diff)
+	}
+	return b.String()
+}
+
+// Queue stores proposed changes until they are applied or rejected
+type Queue struct {
+	mu          sync.Mutex
+	changes     map[string]*Change
+	storagePath string
+	maxResolved int
+
+	saveMu sync.Mutex
+}
+
+// NewQueue creates a queue persisted at storagePath
+func NewQueue(storagePath string) *Queue {
+	q := &Queue{
+		changes:     make(map[string]*Change),
+		storagePath: storagePath,
+		maxResolved: 200,
+	}
+	if err := q.load(); err != nil {
+		log.Warn().Err(err).Msg("Failed to load proposed changes")
+	}
+	return q
+}
+
+// fileState tracks a file while a proposal's edits are applied in memory
+type fileState struct {
+	baseExists bool
+	base       string
+	exists     bool
+	content    string
+}
+
+// Propose records edits to files under workspace for review. Edits are
+// applied in order, so several may target the same file; files end up
+// in the order they were first edited.
+func (q *Queue) Propose(workspace, description, source string, edits []Edit) (Change, error) {
+	root := filepath.Clean(workspace)
+	states := make(map[string]*fileState)
+	var order []string
+
+	for _, e := range edits {
+		rel, err := CleanPath(e.Path)
+		if err != nil {
+			return Change{}, err
+		}
+		st := states[rel]
+		if st == nil {
+			st = &fileState{}
+			data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+			switch {
+			case err == nil:
+				st.baseExists, st.base = true, string(data)
+			case !os.IsNotExist(err):
+				return Change{}, err
+			}
+			st.exists, st.content = st.baseExists, st.base
+			states[rel] = st
+			order = append(order, rel)
+		}
+
+		switch {
+		case e.Delete:
+			if !st.exists {
+				return Change{}, invalid("Cannot delete a file that does not exist", rel)
+			}
+			st.exists, st.content = false, ""
+		case e.Content != nil:
+			st.exists, st.content = true, *e.Content
+		case e.Old != "":
+			if !st.exists {
+				return Change{}, invalid("Cannot edit a file that does not exist", rel)
+			}
+			switch n := strings.Count(st.content, e.Old); n {
+			case 0:
+				return Change{}, invalid("Text to replace was not found", rel)
+			case 1:
+				st.content = strings.Replace(st.content, e.Old, e.New, 1)
+			default:
+				return Change{}, invalid(fmt.Sprintf("Text to replace occurs %d times; include more context", n), rel)
+			}
+		default:
+			return Change{}, invalid("Edit needs content, delete, or old/new", rel)
+		}
+	}
+
+	c := &Change{
+		ID:          generateID(),
+		Workspace:   root,
+		Description: description,
+		Source:      source,
+		Status:      StatusPending,
+		CreatedAt:   time.Now(),
+	}
+	for _, rel := range order {
+		st := states[rel]
+		if st.exists == st.baseExists && st.content == st.base {
+			continue
+		}
+		fc := FileChange{Path: rel}
+		switch {
+		case !st.baseExists:
+			fc.Action = ActionCreate
+		case !st.exists:
+			fc.Action = ActionDelete
+		default:
+			fc.Action = ActionModify
+		}
+		if st.baseExists {
+			fc.BaseHash = hash(st.base)
+		}
+		if st.exists {
+			fc.Content = st.content
+		}
+		fc.Diff, fc.Additions, fc.Deletions = unified(rel, st.base, st.content, fc.Action)
+		c.Additions += fc.Additions
+		c.Deletions += fc.Deletions
+		c.Files = append(c.Files, fc)
+	}
+	if len(c.Files) == 0 {
+		return Change{}, ErrNoChanges
+	}
+
+	q.mu.Lock()
+	q.changes[c.ID] = c
+	snapshot := *c
+	q.mu.Unlock()
+	q.save()
+
+	log.Info().Str("id", c.ID).Str("source", source).Int("files", len(c.Files)).Msg("Change proposed")
+	return snapshot, nil
+}
+
+// Get returns a change by ID
+func (q *Queue) Get(id string) (Change, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	c, ok := q.changes[id]
+	if !ok {
+		return Change{}, false
+	}
+	return *c, true
+}
+
+// List returns the changes with the given status (all when empty),
+// oldest first so a review goes in the order the edits were made
+func (q *Queue) List(status Status) []Change {
+	q.mu.Lock()
+	list := make([]Change, 0, len(q.changes))
+	for _, c := range q.changes {
+		if status == "" || c.Status == status {
+			list = append(list, *c)
+		}
+	}
+	q.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Apply writes a pending change to disk. Unless force is set, it fails
+// with a CHANGE_CONFLICT error if any file was modified since the
+// change was proposed; nothing is written in that case.
+func (q *Queue) Apply(id string, force bool) (Change, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	c, ok := q.changes[id]
+	if !ok {
+		return Change{}, ErrNotFound
+	}
+	if c.Status != StatusPending {
+		return Change{}, ErrNotPending
+	}
+
+	if !force {
+		for _, f := range c.Files {
+			data, err := os.ReadFile(filepath.Join(c.Workspace, filepath.FromSlash(f.Path)))
+			current := ""
+			switch {
+			case err == nil:
+				current = hash(string(data))
+			case !os.IsNotExist(err):
+				return Change{}, err
+			}
+			if current != f.BaseHash {
+				return Change{}, conflict(f.Path)
+			}
+		}
+	}
+
+	for _, f := range c.Files {
+		full := filepath.Join(c.Workspace, filepath.FromSlash(f.Path))
+		var err error
+		if f.Action == ActionDelete {
+			if err = os.Remove(full); os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			err = writeFile(full, f.Content)
+		}
+		if err != nil {
+			return Change{}, fmt.Errorf("%s: %w", f.Path, err)
+		}
+	}
+
+	q.resolveLocked(c, StatusApplied, "")
+	return *c, nil
+}
+
+// Reject discards a pending change
+func (q *Queue) Reject(id, reason string) (Change, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	c, ok := q.changes[id]
+	if !ok {
+		return Change{}, ErrNotFound
+	}
+	if c.Status != StatusPending {
+		return Change{}, ErrNotPending
+	}
+	q.resolveLocked(c, StatusRejected, reason)
+	return *c, nil
+}
+
+func (q *Queue) resolveLocked(c *Change, status Status, reason string) {
+	now := time.Now()
+	c.Status = status
+	c.Reason = reason
+	c.ResolvedAt = &now
+	q.pruneLocked()
+	go q.save()
+
+	log.Info().Str("id", c.ID).Str("status", string(status)).Msg("Change resolved")
+}
+
+// pruneLocked drops the oldest resolved changes beyond maxResolved
+func (q *Queue) pruneLocked() {
+	var resolved []*Change
+	for _, c := range q.changes {
+		if c.Status != StatusPending {
+			resolved = append(resolved, c)
+		}
+	}
+	if len(resolved) <= q.maxResolved {
+		return
+	}
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i].ResolvedAt.Before(*resolved[j].ResolvedAt) })
+	for _, c := range resolved[:len(resolved)-q.maxResolved] {
+		delete(q.changes, c.ID)
+	}
+}
+
+// writeFile replaces path with content through a temporary file, keeping
+// the mode of an existing file
+func writeFile(path, content string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".echohelix-tmp"
+	if err := os.WriteFile(tmp, []byte(content), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// CleanPath normalizes a workspace-relative path, refusing ones that
+// escape the workspace
+func CleanPath(p string) (string, error) {
+	if p == "" {
+		return "", invalid("Path is required", "")
+	}
+	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(p)))
+	if filepath.IsAbs(p) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", invalid("Path must be inside the workspace", p)
+	}
+	return clean, nil
+}
+
+func hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Persistence
+
+func (q *Queue) save() {
+	if q.storagePath == "" {
+		return
+	}
+	// 快照在 saveMu 内获取，后写入的总是较新的状态
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+	q.mu.Lock()
+	list := make([]Change, 0, len(q.changes))
+	for _, c := range q.changes {
+		list = append(list, *c)
+	}
+	q.mu.Unlock()
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(q.storagePath), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(q.storagePath, data, 0600)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to save proposed changes")
+	}
+}
+
+func (q *Queue) load() error {
+	data, err := os.ReadFile(q.storagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var list []*Change
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, c := range list {
+		q.changes[c.ID] = c
+	}
+	return nil
+}
+
+func generateID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -120,3 +120,8 @@ func (s *Service) GetAll() map[string]string {
 	}
 	return res
 }
+
+// Path returns the .env file the settings are read from
+func (s *Service) Path() string {
+	return s.envPath
+}
@@ -0,0 +1,275 @@
+package contextpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Manifest summarizes a dependency manifest
+type Manifest struct {
+	Path         string   `json:"path"`
+	Ecosystem    string   `json:"ecosystem"`
+	Name         string   `json:"name,omitempty"`
+	Version      string   `json:"version,omitempty"`
+	Dependencies []string `json:"dependencies,omitempty"`
+	DevDeps      []string `json:"dev_dependencies,omitempty"`
+	Scripts      []string `json:"scripts,omitempty"`
+}
+
+// manifestParsers maps manifest file names to their parser
+var manifestParsers = map[string]func(src []byte, m *Manifest){
+	"go.mod":           parseGoMod,
+	"package.json":     parsePackageJSON,
+	"requirements.txt": parseRequirements,
+	"pyproject.toml":   parsePyproject,
+	"Cargo.toml":       parseCargo,
+	"Gemfile":          parseGemfile,
+	"composer.json":    parseComposer,
+	"pom.xml":          nil,
+	"build.gradle":     nil,
+	"build.gradle.kts": nil,
+}
+
+var manifestEcosystems = map[string]string{
+	"go.mod":           "go",
+	"package.json":     "npm",
+	"requirements.txt": "pip",
+	"pyproject.toml":   "python",
+	"Cargo.toml":       "cargo",
+	"Gemfile":          "bundler",
+	"composer.json":    "composer",
+	"pom.xml":          "maven",
+	"build.gradle":     "gradle",
+	"build.gradle.kts": "gradle",
+}
+
+// isManifest reports whether a file name is a known dependency manifest
+func isManifest(name string) bool {
+	_, ok := manifestParsers[name]
+	return ok
+}
+
+// parseManifest summarizes the manifest at rel
+func parseManifest(rel string, src []byte) Manifest {
+	name := path.Base(rel)
+	m := Manifest{Path: rel, Ecosystem: manifestEcosystems[name]}
+	if parse := manifestParsers[name]; parse != nil {
+		parse(src, &m)
+	}
+	return m
+}
+
+// Summary renders the manifest as one line
+func (m Manifest) Summary() string {
+	var b strings.Builder
+	b.WriteString(m.Path + " (" + m.Ecosystem + ")")
+	if m.Name != "" {
+		b.WriteString(": " + m.Name)
+		if m.Version != "" {
+			b.WriteString(" " + m.Version)
+		}
+	}
+	if len(m.Dependencies) > 0 {
+		b.WriteString("; dependencies: " + strings.Join(m.Dependencies, ", "))
+	}
+	if len(m.DevDeps) > 0 {
+		b.WriteString("; dev: " + strings.Join(m.DevDeps, ", "))
+	}
+	if len(m.Scripts) > 0 {
+		b.WriteString("; scripts: " + strings.Join(m.Scripts, ", "))
+	}
+	return b.String()
+}
+
+func parseGoMod(src []byte, m *Manifest) {
+	inRequire := false
+	scanLines(src, func(line string) {
+		switch {
+		case strings.HasPrefix(line, "module "):
+			m.Name = strings.TrimSpace(strings.TrimPrefix(line, "module "))
+		case strings.HasPrefix(line, "go "):
+			m.Version = "go" + strings.TrimSpace(strings.TrimPrefix(line, "go "))
+		case line == "require (":
+			inRequire = true
+		case inRequire && line == ")":
+			inRequire = false
+		case inRequire, strings.HasPrefix(line, "require "):
+			line = strings.TrimPrefix(line, "require ")
+			// 间接依赖对理解项目帮助不大
+			if strings.Contains(line, "// indirect") {
+				return
+			}
+			if f := strings.Fields(line); len(f) > 0 {
+				m.Dependencies = append(m.Dependencies, f[0])
+			}
+		}
+	})
+}
+
+func parsePackageJSON(src []byte, m *Manifest) {
+	var pkg struct {
+		Name            string            `json:"name"`
+		Version         string            `json:"version"`
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+		Scripts         map[string]string `json:"scripts"`
+	}
+	if json.Unmarshal(src, &pkg) != nil {
+		return
+	}
+	m.Name, m.Version = pkg.Name, pkg.Version
+	m.Dependencies = sortedKeys(pkg.Dependencies)
+	m.DevDeps = sortedKeys(pkg.DevDependencies)
+	m.Scripts = sortedKeys(pkg.Scripts)
+}
+
+func parseComposer(src []byte, m *Manifest) {
+	var pkg struct {
+		Name       string            `json:"name"`
+		Require    map[string]string `json:"require"`
+		RequireDev map[string]string `json:"require-dev"`
+	}
+	if json.Unmarshal(src, &pkg) != nil {
+		return
+	}
+	m.Name = pkg.Name
+	m.Dependencies = sortedKeys(pkg.Require)
+	m.DevDeps = sortedKeys(pkg.RequireDev)
+}
+
+func parseRequirements(src []byte, m *Manifest) {
+	scanLines(src, func(line string) {
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			return
+		}
+		m.Dependencies = append(m.Dependencies, requirementName(line))
+	})
+}
+
+// parsePyproject reads the project name, version and dependencies of a
+// PEP 621 pyproject.toml, or the Poetry tables
+func parsePyproject(src []byte, m *Manifest) {
+	section := ""
+	inDeps := false
+	scanLines(src, func(line string) {
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			inDeps = false
+			return
+		}
+		if inDeps {
+			if strings.HasPrefix(line, "]") {
+				inDeps = false
+				return
+			}
+			if dep := strings.Trim(line, `"', `); dep != "" {
+				m.Dependencies = append(m.Dependencies, requirementName(dep))
+			}
+			return
+		}
+		key, value, ok := tomlPair(line)
+		if !ok {
+			return
+		}
+		switch section {
+		case "project", "tool.poetry":
+			switch key {
+			case "name":
+				m.Name = value
+			case "version":
+				m.Version = value
+			case "dependencies":
+				// dependencies = ["a", "b"] 可能跨行
+				inline := strings.TrimPrefix(strings.TrimSpace(line[strings.Index(line, "=")+1:]), "[")
+				for _, dep := range strings.Split(strings.TrimSuffix(inline, "]"), ",") {
+					if dep = strings.Trim(dep, `"' `); dep != "" {
+						m.Dependencies = append(m.Dependencies, requirementName(dep))
+					}
+				}
+				inDeps = !strings.HasSuffix(line, "]")
+			}
+		case "tool.poetry.dependencies":
+			if key != "python" {
+				m.Dependencies = append(m.Dependencies, key)
+			}
+		}
+	})
+}
+
+func parseCargo(src []byte, m *Manifest) {
+	section := ""
+	scanLines(src, func(line string) {
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			return
+		}
+		key, value, ok := tomlPair(line)
+		if !ok {
+			return
+		}
+		switch section {
+		case "package":
+			switch key {
+			case "name":
+				m.Name = value
+			case "version":
+				m.Version = value
+			}
+		case "dependencies":
+			m.Dependencies = append(m.Dependencies, key)
+		case "dev-dependencies":
+			m.DevDeps = append(m.DevDeps, key)
+		}
+	})
+}
+
+func parseGemfile(src []byte, m *Manifest) {
+	scanLines(src, func(line string) {
+		if !strings.HasPrefix(line, "gem ") {
+			return
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(line, "gem "), ",")
+		m.Dependencies = append(m.Dependencies, strings.Trim(name, `"' `))
+	})
+}
+
+// requirementName strips the version specifier from a requirement
+func requirementName(req string) string {
+	if i := strings.IndexAny(req, "<>=!~[; @"); i > 0 {
+		return req[:i]
+	}
+	return req
+}
+
+// tomlPair splits a `key = "value"` line
+func tomlPair(line string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.Trim(strings.TrimSpace(key), `"`), strings.Trim(strings.TrimSpace(value), `"'`), true
+}
+
+// scanLines calls fn with each trimmed line of src
+func scanLines(src []byte, fn func(line string)) {
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		fn(strings.TrimSpace(scanner.Text()))
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,294 @@
+// Package contextpack provides model-ready workspace summaries for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package contextpack
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bridgefs "echohelix/bridge/internal/fs"
+)
+
+const (
+	// DefaultBudget is the token budget when none is given
+	DefaultBudget = 8000
+	// MaxBudget bounds the requested budget
+	MaxBudget = 200000
+
+	// maxScannedFiles stops the walk on huge trees
+	maxScannedFiles = 50000
+	// maxExcerptFile skips files too large to be worth excerpting
+	maxExcerptFile = 1 << 20
+	// cacheSize is the number of packs kept
+	cacheSize = 16
+)
+
+// Options configures a pack
+type Options struct {
+	// Budget is the approximate token budget of Text (default 8000)
+	Budget int `json:"budget"`
+	// Include lists files excerpted before the automatically chosen ones
+	Include []string `json:"include,omitempty"`
+	// Refresh rebuilds the pack even if the workspace is unchanged
+	Refresh bool `json:"refresh,omitempty"`
+}
+
+// Excerpt is the beginning of a key file
+type Excerpt struct {
+	Path      string `json:"path"`
+	Lines     int    `json:"lines"`
+	Shown     int    `json:"shown"`
+	Truncated bool   `json:"truncated"`
+}
+
+// Pack is a workspace summary sized for a model's context
+type Pack struct {
+	Root        string     `json:"root"`
+	Budget      int        `json:"budget"`
+	Tokens      int        `json:"tokens"`
+	Files       int        `json:"files"`
+	Fingerprint string     `json:"fingerprint"`
+	Manifests   []Manifest `json:"manifests"`
+	Excerpts    []Excerpt  `json:"excerpts"`
+	Omitted     []string   `json:"omitted,omitempty"`
+	Text        string     `json:"text"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	Cached      bool       `json:"cached"`
+}
+
+// EstimateTokens approximates the token count of text (~4 bytes a token)
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Builder builds packs and caches them until the workspace changes
+type Builder struct {
+	mu    sync.Mutex
+	cache map[string]*Pack
+	order []string
+}
+
+// NewBuilder creates a builder
+func NewBuilder() *Builder {
+	return &Builder{cache: make(map[string]*Pack)}
+}
+
+// Build returns the pack of the workspace at root. A cached pack is
+// returned when no file was added, removed, or modified since it was built.
+func (b *Builder) Build(root string, opts Options) (*Pack, error) {
+	if opts.Budget <= 0 {
+		opts.Budget = DefaultBudget
+	}
+	if opts.Budget > MaxBudget {
+		opts.Budget = MaxBudget
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	files, fingerprint := scan(root)
+	key := root + "\x00" + strconv.Itoa(opts.Budget) + "\x00" + strings.Join(opts.Include, "\x00")
+
+	b.mu.Lock()
+	cached := b.cache[key]
+	b.mu.Unlock()
+	if cached != nil && cached.Fingerprint == fingerprint && !opts.Refresh {
+		p := *cached
+		p.Cached = true
+		return &p, nil
+	}
+
+	p := build(root, files, opts)
+	p.Fingerprint = fingerprint
+
+	b.mu.Lock()
+	if _, ok := b.cache[key]; !ok {
+		b.order = append(b.order, key)
+		if len(b.order) > cacheSize {
+			delete(b.cache, b.order[0])
+			b.order = b.order[1:]
+		}
+	}
+	b.cache[key] = p
+	b.mu.Unlock()
+
+	out := *p
+	return &out, nil
+}
+
+// scannedFile is a workspace file found by scan
+type scannedFile struct {
+	rel     string
+	size    int64
+	modTime time.Time
+}
+
+// scan lists the workspace's files, skipping ignored and hidden
+// directories, and fingerprints their paths, sizes, and mtimes
+func scan(root string) ([]scannedFile, string) {
+	var files []scannedFile
+	filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != root && (bridgefs.IsIgnoredDir(d.Name()) || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		files = append(files, scannedFile{rel: filepath.ToSlash(rel), size: info.Size(), modTime: info.ModTime()})
+		if len(files) >= maxScannedFiles {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+
+	h := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", f.rel, f.size, f.modTime.UnixNano())
+	}
+	return files, hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// build assembles the pack: the manifests always, then the tree within a
+// quarter of the budget, then key file excerpts until the budget is spent
+func build(root string, files []scannedFile, opts Options) *Pack {
+	p := &Pack{
+		Root:        root,
+		Budget:      opts.Budget,
+		Files:       len(files),
+		Manifests:   []Manifest{},
+		Excerpts:    []Excerpt{},
+		GeneratedAt: time.Now(),
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "# Workspace: %s\n\n%d files\n", filepath.Base(root), len(files))
+
+	for _, f := range files {
+		if isManifest(path.Base(f.rel)) && strings.Count(f.rel, "/") <= 2 && f.size <= maxExcerptFile {
+			if src, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(f.rel))); err == nil {
+				p.Manifests = append(p.Manifests, parseManifest(f.rel, src))
+			}
+		}
+	}
+	if len(p.Manifests) > 0 {
+		var section strings.Builder
+		section.WriteString("\n## Dependencies\n\n")
+		for _, m := range p.Manifests {
+			section.WriteString("- " + m.Summary() + "\n")
+		}
+		text.WriteString(clip(section.String(), opts.Budget/5))
+	}
+
+	treeBudget := opts.Budget / 4
+	text.WriteString("\n## Directory tree\n\n```\n")
+	text.WriteString(renderTree(files, treeBudget*4))
+	text.WriteString("```\n")
+
+	used := EstimateTokens(text.String())
+	for _, rel := range keyFiles(files, opts.Include) {
+		remaining := opts.Budget - used
+		if remaining < 64 {
+			p.Omitted = append(p.Omitted, rel)
+			continue
+		}
+		src, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || bytes.IndexByte(src, 0) >= 0 {
+			continue
+		}
+		// 单个文件最多占剩余预算的一半，给后面的文件留空间
+		limit := remaining / 2
+		if limit < 256 {
+			limit = remaining
+		}
+		body, ex := excerpt(rel, string(src), limit*4)
+		section := "\n## " + rel + "\n\n```" + fenceLang(rel) + "\n" + body + "```\n"
+		if ex.Truncated {
+			section += fmt.Sprintf("(%d of %d lines)\n", ex.Shown, ex.Lines)
+		}
+		text.WriteString(section)
+		used += EstimateTokens(section)
+		p.Excerpts = append(p.Excerpts, ex)
+	}
+
+	p.Text = text.String()
+	p.Tokens = EstimateTokens(p.Text)
+	return p
+}
+
+// excerpt returns whole lines from the start of src within maxBytes
+func excerpt(rel, src string, maxBytes int) (string, Excerpt) {
+	lines := strings.SplitAfter(src, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	ex := Excerpt{Path: rel, Lines: len(lines)}
+
+	var b strings.Builder
+	for _, line := range lines {
+		if b.Len()+len(line) > maxBytes {
+			ex.Truncated = true
+			break
+		}
+		b.WriteString(line)
+		ex.Shown++
+	}
+	body := b.String()
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	return body, ex
+}
+
+// clip cuts text to about maxTokens at a line boundary
+func clip(text string, maxTokens int) string {
+	if EstimateTokens(text) <= maxTokens {
+		return text
+	}
+	cut := strings.LastIndexByte(text[:maxTokens*4], '\n')
+	if cut < 0 {
+		return ""
+	}
+	return text[:cut+1] + "- …\n"
+}
+
+func fenceLang(rel string) string {
+	switch ext := strings.TrimPrefix(path.Ext(rel), "."); ext {
+	case "md", "go", "py", "rs", "rb", "java", "kt", "swift", "toml", "json", "yaml", "sh":
+		return ext
+	case "yml":
+		return "yaml"
+	case "js", "mjs", "cjs", "jsx":
+		return "javascript"
+	case "ts", "tsx":
+		return "typescript"
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package contextpack
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxDirEntries is the number of entries listed per directory
+	maxDirEntries = 40
+	// maxKeyFiles is the number of automatically chosen excerpts
+	maxKeyFiles = 12
+)
+
+type treeNode struct {
+	children map[string]*treeNode // nil for files
+	files    int                  // files below this directory
+}
+
+// renderTree draws the file list as an indented tree within maxBytes.
+// Deep trees are collapsed level by level, showing only the file count
+// of directories below the cut, until the drawing fits.
+func renderTree(files []scannedFile, maxBytes int) string {
+	root := &treeNode{children: make(map[string]*treeNode)}
+	for _, f := range files {
+		n := root
+		parts := strings.Split(f.rel, "/")
+		for i, part := range parts {
+			n.files++
+			if i == len(parts)-1 {
+				n.children[part] = &treeNode{}
+				break
+			}
+			child := n.children[part]
+			if child == nil {
+				child = &treeNode{children: make(map[string]*treeNode)}
+				n.children[part] = child
+			}
+			n = child
+		}
+	}
+
+	var out string
+	for depth := 6; depth >= 1; depth-- {
+		var b strings.Builder
+		drawTree(&b, root, "", 1, depth)
+		out = b.String()
+		if len(out) <= maxBytes {
+			return out
+		}
+	}
+	// 只剩顶层也放不下，按行截断
+	if cut := strings.LastIndexByte(out[:maxBytes], '\n'); cut >= 0 {
+		return out[:cut+1] + "…\n"
+	}
+	return ""
+}
+
+func drawTree(b *strings.Builder, n *treeNode, indent string, depth, maxDepth int) {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	// 目录在前，再按名称
+	sort.Slice(names, func(i, j int) bool {
+		di, dj := n.children[names[i]].children != nil, n.children[names[j]].children != nil
+		if di != dj {
+			return di
+		}
+		return names[i] < names[j]
+	})
+
+	for i, name := range names {
+		if i == maxDirEntries {
+			fmt.Fprintf(b, "%s… %d more\n", indent, len(names)-i)
+			return
+		}
+		child := n.children[name]
+		switch {
+		case child.children == nil:
+			b.WriteString(indent + name + "\n")
+		case depth >= maxDepth:
+			fmt.Fprintf(b, "%s%s/ (%d files)\n", indent, name, child.files)
+		default:
+			b.WriteString(indent + name + "/\n")
+			drawTree(b, child, indent+"  ", depth+1, maxDepth)
+		}
+	}
+}
+
+// keyFileScores ranks the files most useful for understanding a project
+var keyFileScores = map[string]int{
+	"README.md": 100, "README": 100, "README.rst": 100, "README.txt": 100,
+	"AGENTS.md": 90, "GEMINI.md": 90, "ARCHITECTURE.md": 85, "CONTRIBUTING.md": 60,
+	"main.go": 70, "main.py": 70, "app.py": 70, "manage.py": 65, "__main__.py": 65,
+	"main.rs": 70, "lib.rs": 65, "index.js": 60, "index.ts": 60, "main.ts": 65, "main.js": 60,
+	"server.js": 60, "server.ts": 60, "App.tsx": 55, "App.jsx": 55, "App.vue": 55,
+	"Makefile": 50, "Dockerfile": 45, "docker-compose.yml": 40, "compose.yaml": 40,
+}
+
+// keyFiles returns the files to excerpt: the included ones first, then
+// the best-ranked key files, shallow ones before deep ones
+func keyFiles(files []scannedFile, include []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, rel := range include {
+		rel = path.Clean(strings.TrimPrefix(rel, "/"))
+		if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+		out = append(out, rel)
+	}
+
+	type ranked struct {
+		rel   string
+		score int
+	}
+	var candidates []ranked
+	for _, f := range files {
+		score := keyFileScores[path.Base(f.rel)]
+		if score == 0 || seen[f.rel] || f.size > maxExcerptFile {
+			continue
+		}
+		// 每深一层降分，cmd/x/main.go 这类入口仍然靠前
+		depth := strings.Count(f.rel, "/")
+		if depth > 3 {
+			continue
+		}
+		candidates = append(candidates, ranked{f.rel, score - depth*15})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	for i, c := range candidates {
+		if i == maxKeyFiles {
+			break
+		}
+		out = append(out, c.rel)
+	}
+	return out
+}
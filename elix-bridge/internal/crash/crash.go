@@ -0,0 +1,307 @@
+// Package crash provides crash bundles for EchoHelix Bridge.
+//
+// A bundle is one JSON file in ~/.echohelix/crashes holding what a bug
+// report needs: the crash message and stacks of every goroutine, the most
+// recent log lines, the config with secrets blanked and version info.
+// Panics the bridge recovers from and kernel failures are bundled as they
+// happen; fatal runtime errors, which cannot be recovered, are captured
+// by the Go runtime into a file that the next start turns into a bundle.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package crash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Bundle kinds
+const (
+	KindPanic  = "panic"
+	KindKernel = "kernel"
+	KindFatal  = "fatal"
+)
+
+const (
+	filePrefix = "crash-"
+	fileSuffix = ".json"
+	// fatalFile receives the runtime's output on an unrecovered crash
+	fatalFile = "fatal.log"
+	// defaultKeep bounds the bundles kept
+	defaultKeep = 20
+)
+
+// ErrNotFound is returned for an unknown bundle name
+var ErrNotFound = errors.New("crash bundle not found")
+
+// Bundle is the content of a crash file
+type Bundle struct {
+	Kind       string                 `json:"kind"`
+	Message    string                 `json:"message"`
+	CreatedAt  time.Time              `json:"created_at"`
+	Version    VersionInfo            `json:"version"`
+	Stack      string                 `json:"stack,omitempty"`
+	Goroutines string                 `json:"goroutines,omitempty"`
+	Logs       interface{}            `json:"logs,omitempty"`
+	Config     map[string]string      `json:"config,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// VersionInfo identifies the build and platform
+type VersionInfo struct {
+	Bridge    string  `json:"bridge"`
+	Go        string  `json:"go"`
+	OS        string  `json:"os"`
+	Arch      string  `json:"arch"`
+	Uptime    float64 `json:"uptime_seconds"`
+	StartedAt string  `json:"started_at"`
+}
+
+// Info describes a stored bundle
+type Info struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Config configures a Reporter
+type Config struct {
+	// Dir holds the bundles, normally ~/.echohelix/crashes
+	Dir           string
+	BridgeVersion string
+	// Keep is how many bundles to retain (default 20)
+	Keep int
+	// Logs returns the recent log lines to include
+	Logs func() interface{}
+	// Settings returns the config with secret values already blanked
+	Settings func() map[string]string
+}
+
+// Reporter writes crash bundles
+type Reporter struct {
+	cfg       Config
+	startedAt time.Time
+	mu        sync.Mutex
+	fatal     *os.File
+}
+
+// NewReporter creates a reporter. It routes the runtime's fatal crash
+// output to a file in Dir and bundles any such file left by a previous
+// run.
+func NewReporter(cfg Config) *Reporter {
+	if cfg.Keep <= 0 {
+		cfg.Keep = defaultKeep
+	}
+	r := &Reporter{cfg: cfg, startedAt: time.Now()}
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		log.Warn().Err(err).Msg("Failed to create crash directory")
+		return r
+	}
+	r.collectFatal()
+
+	f, err := os.OpenFile(filepath.Join(cfg.Dir, fatalFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to open crash output file")
+		return r
+	}
+	if err := debug.SetCrashOutput(f, debug.CrashOptions{}); err != nil {
+		f.Close()
+		log.Warn().Err(err).Msg("Failed to capture crash output")
+		return r
+	}
+	r.fatal = f
+	return r
+}
+
+// Report writes a bundle and returns its name. stack is the stack of the
+// failing goroutine, if known; all goroutines are captured as well.
+func (r *Reporter) Report(kind, message string, stack []byte, details map[string]interface{}) (string, error) {
+	if r == nil {
+		return "", errors.New("crash reporting not set up")
+	}
+	b := r.newBundle(kind, message)
+	b.Stack = string(stack)
+	b.Goroutines = allStacks()
+	b.Details = details
+	return r.write(b)
+}
+
+// Close stops capturing crash output; a clean exit leaves no fatal file
+func (r *Reporter) Close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fatal == nil {
+		return
+	}
+	debug.SetCrashOutput(nil, debug.CrashOptions{})
+	r.fatal.Close()
+	os.Remove(r.fatal.Name())
+	r.fatal = nil
+}
+
+// List returns the stored bundles, newest first
+func (r *Reporter) List() ([]Info, error) {
+	entries, err := os.ReadDir(r.cfg.Dir)
+	if os.IsNotExist(err) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := []Info{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), filePrefix) || !strings.HasSuffix(e.Name(), fileSuffix) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		info := Info{Name: e.Name(), Size: fi.Size(), CreatedAt: fi.ModTime()}
+		// 只解析摘要字段
+		var head struct {
+			Kind      string    `json:"kind"`
+			Message   string    `json:"message"`
+			CreatedAt time.Time `json:"created_at"`
+		}
+		if data, err := os.ReadFile(filepath.Join(r.cfg.Dir, e.Name())); err == nil && json.Unmarshal(data, &head) == nil {
+			info.Kind, info.Message = head.Kind, head.Message
+			if !head.CreatedAt.IsZero() {
+				info.CreatedAt = head.CreatedAt
+			}
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list, nil
+}
+
+// Open opens a stored bundle by name
+func (r *Reporter) Open(name string) (*os.File, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(filepath.Join(r.cfg.Dir, name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete removes a stored bundle
+func (r *Reporter) Delete(name string) error {
+	f, err := r.Open(name)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func (r *Reporter) newBundle(kind, message string) *Bundle {
+	b := &Bundle{
+		Kind:      kind,
+		Message:   message,
+		CreatedAt: time.Now(),
+		Version: VersionInfo{
+			Bridge:    r.cfg.BridgeVersion,
+			Go:        runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			Uptime:    time.Since(r.startedAt).Seconds(),
+			StartedAt: r.startedAt.Format(time.RFC3339),
+		},
+	}
+	if r.cfg.Logs != nil {
+		b.Logs = r.cfg.Logs()
+	}
+	if r.cfg.Settings != nil {
+		b.Config = r.cfg.Settings()
+	}
+	return b
+}
+
+func (r *Reporter) write(b *Bundle) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s%s-%s%s", filePrefix, b.CreatedAt.Format("20060102-150405.000"), b.Kind, fileSuffix)
+	if err := os.WriteFile(filepath.Join(r.cfg.Dir, name), data, 0600); err != nil {
+		return "", err
+	}
+	r.prune()
+	log.Warn().Str("file", name).Str("kind", b.Kind).Msg("Crash bundle written")
+	return name, nil
+}
+
+// prune removes the oldest bundles beyond Keep. Callers hold r.mu.
+func (r *Reporter) prune() {
+	list, err := r.List()
+	if err != nil {
+		return
+	}
+	for _, info := range list[min(len(list), r.cfg.Keep):] {
+		os.Remove(filepath.Join(r.cfg.Dir, info.Name))
+	}
+}
+
+// collectFatal bundles the runtime output of a crash in the previous run
+func (r *Reporter) collectFatal() {
+	path := filepath.Join(r.cfg.Dir, fatalFile)
+	data, err := os.ReadFile(path)
+	if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+		return
+	}
+	info, _ := os.Stat(path)
+
+	message, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	b := r.newBundle(KindFatal, message)
+	// 日志和运行时长属于本次启动，与上次崩溃无关
+	b.Logs = nil
+	b.Version.Uptime = 0
+	b.Version.StartedAt = ""
+	b.Goroutines = string(data)
+	if info != nil {
+		b.CreatedAt = info.ModTime()
+	}
+	if _, err := r.write(b); err != nil {
+		log.Warn().Err(err).Msg("Failed to bundle previous crash")
+		return
+	}
+	os.Remove(path)
+}
+
+// allStacks returns the stacks of all goroutines
+func allStacks() string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 16<<20 {
+			return string(buf[:n])
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
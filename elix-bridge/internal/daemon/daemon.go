@@ -0,0 +1,103 @@
+// Package daemon provides OS service installation for EchoHelix Bridge.
+//
+// The bridge is registered as a per-user systemd unit on Linux, a launchd
+// agent on macOS, and a Windows service, started automatically at boot
+// or login.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Service identifiers on each platform
+const (
+	ServiceName  = "echohelix-bridge"     // systemd unit
+	LaunchdLabel = "com.echohelix.bridge" // launchd agent
+	WindowsName  = "EchoHelixBridge"      // Windows service
+	DisplayName  = "EchoHelix Bridge"
+	Description  = "Connects the EchoHelix mobile app to AI kernels on this machine"
+)
+
+var (
+	// ErrUnsupported is returned on platforms without a supported service manager
+	ErrUnsupported = errors.New("service installation is not supported on this platform")
+	// ErrNotInstalled is returned when the service has not been installed
+	ErrNotInstalled = errors.New("service is not installed; run install-service first")
+)
+
+// Config describes how the service launches the bridge
+type Config struct {
+	Executable string   // bridge 可执行文件的绝对路径
+	Args       []string // 可执行文件之后的参数
+	WorkDir    string   // 工作目录：.env 与 cores/ 所在目录
+	HomeDir    string   // 数据目录 ~/.echohelix 所在的用户目录
+	LogFile    string
+}
+
+// Status is the installed service's state
+type Status struct {
+	Manager   string `json:"manager"` // systemd, launchd, windows
+	Installed bool   `json:"installed"`
+	Running   bool   `json:"running"`
+	Path      string `json:"path,omitempty"` // unit / plist 文件路径
+}
+
+// DefaultConfig runs the current executable from the current directory,
+// logging to ~/.echohelix/logs/bridge.log
+func DefaultConfig() (Config, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return Config{}, err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return Config{}, err
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return Config{}, err
+	}
+
+	logFile := filepath.Join(homeDir, ".echohelix", "logs", "bridge.log")
+	return Config{
+		Executable: exe,
+		Args:       []string{"serve", "--log-file", logFile},
+		WorkDir:    workDir,
+		HomeDir:    homeDir,
+		LogFile:    logFile,
+	}, nil
+}
+
+// prepare creates the log directory
+func (c Config) prepare() error {
+	if c.LogFile == "" {
+		return nil
+	}
+	return os.MkdirAll(filepath.Dir(c.LogFile), 0700)
+}
+
+// run executes a service manager command, including its output in errors
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			return fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+		}
+		return fmt.Errorf("%s %s: %s", name, strings.Join(args, " "), msg)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// plistPath returns the launchd agent location
+func plistPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, "Library", "LaunchAgents", LaunchdLabel+".plist"), nil
+}
+
+// Install writes a launchd agent that starts at login and restarts the
+// bridge if it crashes, then loads it
+func Install(config Config) error {
+	if err := config.prepare(); err != nil {
+		return err
+	}
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	plistKey(&b, "Label", LaunchdLabel)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{config.Executable}, config.Args...) {
+		b.WriteString("\t\t<string>" + xmlEscape(arg) + "</string>\n")
+	}
+	b.WriteString("\t</array>\n")
+	plistKey(&b, "WorkingDirectory", config.WorkDir)
+	b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	b.WriteString("\t\t<key>HOME</key>\n\t\t<string>" + xmlEscape(config.HomeDir) + "</string>\n")
+	b.WriteString("\t</dict>\n")
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	// 正常退出（stop）不重启，崩溃时重启
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	if config.LogFile != "" {
+		plistKey(&b, "StandardOutPath", config.LogFile)
+		plistKey(&b, "StandardErrorPath", config.LogFile)
+	}
+	b.WriteString("</dict>\n</plist>\n")
+
+	// 重新安装时先卸载旧的 agent
+	if _, err := os.Stat(path); err == nil {
+		run("launchctl", "unload", path)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	return run("launchctl", "load", "-w", path)
+}
+
+// Uninstall unloads and removes the agent
+func Uninstall() error {
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+
+	run("launchctl", "unload", "-w", path)
+	return os.Remove(path)
+}
+
+// Start starts the loaded agent
+func Start() error {
+	if err := checkInstalled(); err != nil {
+		return err
+	}
+	return run("launchctl", "start", LaunchdLabel)
+}
+
+// Stop stops the agent; it starts again at next login
+func Stop() error {
+	if err := checkInstalled(); err != nil {
+		return err
+	}
+	return run("launchctl", "stop", LaunchdLabel)
+}
+
+// Query reports whether the agent is installed and has a running process
+func Query() (Status, error) {
+	path, err := plistPath()
+	if err != nil {
+		return Status{}, err
+	}
+	st := Status{Manager: "launchd", Path: path}
+	if _, err := os.Stat(path); err != nil {
+		return st, nil
+	}
+	st.Installed = true
+
+	out, err := exec.Command("launchctl", "list", LaunchdLabel).Output()
+	if err == nil {
+		st.Running = strings.Contains(string(out), `"PID" = `)
+	}
+	return st, nil
+}
+
+func checkInstalled() error {
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	return nil
+}
+
+func plistKey(b *bytes.Buffer, key, value string) {
+	b.WriteString("\t<key>" + key + "</key>\n\t<string>" + xmlEscape(value) + "</string>\n")
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// unitPath returns the systemd user unit location
+func unitPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "systemd", "user", ServiceName+".service"), nil
+}
+
+// Install writes a systemd user unit, enables it, and starts it.
+// Lingering is enabled when permitted so the unit starts at boot rather
+// than at first login.
+func Install(config Config) error {
+	if err := config.prepare(); err != nil {
+		return err
+	}
+	path, err := unitPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	var unit bytes.Buffer
+	fmt.Fprintf(&unit, "[Unit]\nDescription=%s\nAfter=network-online.target\n\n", DisplayName)
+	fmt.Fprintf(&unit, "[Service]\nType=simple\n")
+	fmt.Fprintf(&unit, "WorkingDirectory=%s\n", config.WorkDir)
+	fmt.Fprintf(&unit, "ExecStart=%s\n", systemdCommand(config.Executable, config.Args))
+	fmt.Fprintf(&unit, "Environment=%s\n", systemdQuote("HOME="+config.HomeDir))
+	fmt.Fprintf(&unit, "Restart=on-failure\nRestartSec=5\n\n")
+	fmt.Fprintf(&unit, "[Install]\nWantedBy=default.target\n")
+
+	if err := os.WriteFile(path, unit.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := run("systemctl", "--user", "daemon-reload"); err != nil {
+		return err
+	}
+	if err := run("systemctl", "--user", "enable", "--now", ServiceName); err != nil {
+		return err
+	}
+
+	// 未开启 linger 时用户服务只在登录后启动；无权限时忽略
+	if u, err := user.Current(); err == nil {
+		run("loginctl", "enable-linger", u.Username)
+	}
+	return nil
+}
+
+// Uninstall stops and removes the unit
+func Uninstall() error {
+	path, err := unitPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+
+	run("systemctl", "--user", "disable", "--now", ServiceName)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return run("systemctl", "--user", "daemon-reload")
+}
+
+// Start starts the installed unit
+func Start() error {
+	if err := checkInstalled(); err != nil {
+		return err
+	}
+	return run("systemctl", "--user", "start", ServiceName)
+}
+
+// Stop stops the installed unit
+func Stop() error {
+	if err := checkInstalled(); err != nil {
+		return err
+	}
+	return run("systemctl", "--user", "stop", ServiceName)
+}
+
+// Query reports whether the unit is installed and active
+func Query() (Status, error) {
+	path, err := unitPath()
+	if err != nil {
+		return Status{}, err
+	}
+	st := Status{Manager: "systemd", Path: path}
+	if _, err := os.Stat(path); err != nil {
+		return st, nil
+	}
+	st.Installed = true
+
+	// is-active 在非运行状态下返回非零退出码，只看输出
+	out, _ := exec.Command("systemctl", "--user", "is-active", ServiceName).Output()
+	st.Running = strings.TrimSpace(string(out)) == "active"
+	return st, nil
+}
+
+func checkInstalled() error {
+	path, err := unitPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	return nil
+}
+
+// systemdCommand renders an ExecStart line
+func systemdCommand(exe string, args []string) string {
+	parts := []string{systemdQuote(exe)}
+	for _, a := range args {
+		parts = append(parts, systemdQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// systemdQuote quotes a word containing spaces, quotes, or specifiers
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !windows
+
+package daemon
+
+// Install is not supported on this platform
+func Install(config Config) error { return ErrUnsupported }
+
+// Uninstall is not supported on this platform
+func Uninstall() error { return ErrUnsupported }
+
+// Start is not supported on this platform
+func Start() error { return ErrUnsupported }
+
+// Stop is not supported on this platform
+func Stop() error { return ErrUnsupported }
+
+// Query is not supported on this platform
+func Query() (Status, error) { return Status{}, ErrUnsupported }
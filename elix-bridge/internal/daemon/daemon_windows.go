@@ -0,0 +1,173 @@
+package daemon
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Install registers an automatic-start Windows service and starts it.
+// Requires an elevated prompt.
+func Install(config Config) error {
+	if err := config.prepare(); err != nil {
+		return err
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(WindowsName); err == nil {
+		s.Close()
+		return errors.New("service is already installed; run uninstall-service first")
+	}
+
+	// 服务从 System32 启动，需要显式指定工作目录
+	args := append(append([]string{}, config.Args...), "--workdir", config.WorkDir)
+	s, err := m.CreateService(WindowsName, config.Executable, mgr.Config{
+		DisplayName: DisplayName,
+		Description: Description,
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+	}, uint32((24 * time.Hour).Seconds()))
+
+	// 服务以 LocalSystem 运行；指向安装用户的目录，使数据仍保存在其 ~/.echohelix 下
+	if err := setServiceEnv([]string{"USERPROFILE=" + config.HomeDir}); err != nil {
+		return err
+	}
+
+	return s.Start()
+}
+
+// setServiceEnv sets the environment the service control manager passes
+// to the service process
+func setServiceEnv(env []string) error {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+WindowsName, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	return k.SetStringsValue("Environment", env)
+}
+
+// Uninstall stops and deletes the service
+func Uninstall() error {
+	m, s, err := openService()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	s.Control(svc.Stop)
+	return s.Delete()
+}
+
+// Start starts the service
+func Start() error {
+	m, s, err := openService()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	return s.Start()
+}
+
+// Stop stops the service
+func Stop() error {
+	m, s, err := openService()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	_, err = s.Control(svc.Stop)
+	return err
+}
+
+// Query reports whether the service is installed and running
+func Query() (Status, error) {
+	st := Status{Manager: "windows"}
+	m, s, err := openService()
+	if errors.Is(err, ErrNotInstalled) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	st.Installed = true
+	q, err := s.Query()
+	if err != nil {
+		return st, err
+	}
+	st.Running = q.State == svc.Running
+	return st, nil
+}
+
+func openService() (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := m.OpenService(WindowsName)
+	if err != nil {
+		m.Disconnect()
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return nil, nil, ErrNotInstalled
+		}
+		return nil, nil, err
+	}
+	return m, s, nil
+}
+
+// RunningAsService reports whether the process was started by the
+// service control manager
+func RunningAsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// RunService reports to the service control manager until a stop request
+// arrives, then calls onStop, which should return once the bridge has
+// shut down
+func RunService(onStop func()) error {
+	return svc.Run(WindowsName, serviceHandler{onStop: onStop})
+}
+
+type serviceHandler struct {
+	onStop func()
+}
+
+func (h serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			h.onStop()
+			return false, 0
+		}
+	}
+	return false, 0
+}
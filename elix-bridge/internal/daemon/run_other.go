@@ -0,0 +1,11 @@
+//go:build !windows
+
+package daemon
+
+// RunningAsService reports whether the process was started by the
+// Windows service control manager; always false elsewhere, where
+// systemd and launchd stop the bridge with SIGTERM
+func RunningAsService() bool { return false }
+
+// RunService is only used on Windows
+func RunService(onStop func()) error { return ErrUnsupported }
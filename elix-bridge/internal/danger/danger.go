@@ -0,0 +1,207 @@
+// Package danger provides classification and confirmation of destructive
+// operations for EchoHelix Bridge.
+//
+// Some operations cannot be undone: recursive deletes, force pushes,
+// change sets that delete many files and writes outside the workspace.
+// Before performing one, the bridge requires a confirmation token minted
+// by an earlier, separate request (POST /api/v2/confirm) for exactly that
+// operation, so a single malformed tool call cannot do irreversible
+// damage on its own.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package danger
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Danger classes
+const (
+	ClassRecursiveDelete  = "recursive_delete"
+	ClassForcePush        = "force_push"
+	ClassBulkDelete       = "bulk_delete"
+	ClassOutsideWorkspace = "outside_workspace"
+)
+
+// Actions that can be confirmed
+const (
+	ActionExec        = "exec"
+	ActionFSWrite     = "fs.write"
+	ActionChangeApply = "change.apply"
+)
+
+// ValidAction reports whether a is an action that can be confirmed
+func ValidAction(a string) bool {
+	switch a {
+	case ActionExec, ActionFSWrite, ActionChangeApply:
+		return true
+	}
+	return false
+}
+
+// Operation identifies one dangerous operation: what is done (Action) to
+// what (Target: the command line, file path or change ID)
+type Operation struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+	// Class is why it is dangerous; informational, not part of the
+	// fingerprint
+	Class string `json:"class,omitempty"`
+}
+
+// Fingerprint identifies the operation a token is bound to
+func (op Operation) Fingerprint() string {
+	sum := sha256.Sum256([]byte(op.Action + "\x00" + op.Target))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ClassifyCommand returns the danger class of a shell command line, or ""
+// when it is not dangerous. Every command of a list or pipeline is
+// checked, so "make && rm -rf build" is a recursive delete.
+func ClassifyCommand(command string) string {
+	for _, segment := range splitCommands(command) {
+		fields := strings.Fields(segment)
+		for i := range fields {
+			fields[i] = strings.Trim(fields[i], `"'`)
+		}
+		for i, f := range fields {
+			switch filepath.Base(f) {
+			case "rm":
+				if recursiveRM(fields[i+1:]) {
+					return ClassRecursiveDelete
+				}
+			case "git":
+				if forcePush(fields[i+1:]) {
+					return ClassForcePush
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitCommands splits a command line at ; & | and newlines
+func splitCommands(command string) []string {
+	return strings.FieldsFunc(command, func(r rune) bool {
+		return r == ';' || r == '&' || r == '|' || r == '\n' || r == '(' || r == ')' || r == '`'
+	})
+}
+
+func recursiveRM(args []string) bool {
+	for _, a := range args {
+		switch {
+		case a == "--":
+			return false
+		case a == "--recursive":
+			return true
+		case strings.HasPrefix(a, "--"):
+		case strings.HasPrefix(a, "-") && strings.ContainsAny(a, "rR"):
+			return true
+		}
+	}
+	return false
+}
+
+func forcePush(args []string) bool {
+	// git 的全局选项（-C dir、-c k=v）之后才是子命令
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		if args[i] == "-C" || args[i] == "-c" {
+			i++
+		}
+		i++
+	}
+	if i >= len(args) || args[i] != "push" {
+		return false
+	}
+	for _, a := range args[i+1:] {
+		switch {
+		case a == "--force", a == "--mirror", strings.HasPrefix(a, "--force-with-lease"):
+			return true
+		case strings.HasPrefix(a, "--"):
+		case strings.HasPrefix(a, "-") && strings.Contains(a, "f"):
+			return true
+		case strings.HasPrefix(a, "+"):
+			// +refspec 强制更新该分支
+			return true
+		}
+	}
+	return false
+}
+
+// ErrTokenInvalid is returned for a confirmation token that is unknown,
+// expired, already used, or minted for another operation or device
+var ErrTokenInvalid = errors.New("confirmation token is invalid, expired, or for a different operation")
+
+// Token is a minted confirmation
+type Token struct {
+	Token       string    `json:"token"`
+	Operation   Operation `json:"operation"`
+	Fingerprint string    `json:"fingerprint"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type pending struct {
+	fingerprint string
+	device      string
+	expires     time.Time
+}
+
+// Confirmer mints and redeems single-use confirmation tokens
+type Confirmer struct {
+	ttl    time.Duration
+	mu     sync.Mutex
+	tokens map[string]pending
+}
+
+// NewConfirmer creates a confirmer whose tokens are valid for ttl
+func NewConfirmer(ttl time.Duration) *Confirmer {
+	return &Confirmer{ttl: ttl, tokens: make(map[string]pending)}
+}
+
+// Mint creates a token for op, redeemable once by the same device
+func (c *Confirmer) Mint(op Operation, device string) Token {
+	b := make([]byte, 16)
+	rand.Read(b)
+	t := Token{
+		Token:       "ct_" + hex.EncodeToString(b),
+		Operation:   op,
+		Fingerprint: op.Fingerprint(),
+		ExpiresAt:   time.Now().Add(c.ttl),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, p := range c.tokens {
+		if now.After(p.expires) {
+			delete(c.tokens, k)
+		}
+	}
+	c.tokens[t.Token] = pending{fingerprint: t.Fingerprint, device: device, expires: t.ExpiresAt}
+	return t
+}
+
+// Redeem consumes token if it was minted for op by device. A token that
+// does not match is left untouched.
+func (c *Confirmer) Redeem(token string, op Operation, device string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.tokens[token]
+	if !ok || p.fingerprint != op.Fingerprint() || p.device != device {
+		return ErrTokenInvalid
+	}
+	delete(c.tokens, token)
+	if time.Now().After(p.expires) {
+		return ErrTokenInvalid
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package dashboard
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"echohelix/bridge/internal/i18n"
+)
+
+// staticFiles holds the dashboard pages (*.html, rendered as templates)
+// and their CSS/JS, compiled into the binary
+//
+//go:embed static
+var staticFiles embed.FS
+
+// assets serves the dashboard bundle from the embedded files, or from a
+// directory on disk in dev mode so edits show up on reload
+type assets struct {
+	mu       sync.RWMutex
+	files    fs.FS
+	dev      bool
+	versions map[string]string // 文件名 -> 内容哈希，用于缓存失效
+	tmpl     *template.Template
+}
+
+func newEmbeddedAssets() *assets {
+	files, _ := fs.Sub(staticFiles, "static")
+	a := &assets{files: files}
+	if err := a.load(); err != nil {
+		// 内嵌资源在编译时已确定，解析失败说明模板本身有误
+		panic(err)
+	}
+	return a
+}
+
+// load hashes every asset and parses the page templates
+func (a *assets) load() error {
+	versions := make(map[string]string)
+	err := fs.WalkDir(a.files, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(a.files, p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		versions[p] = hex.EncodeToString(sum[:6])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("").Funcs(template.FuncMap{
+		"asset": func(name string) string {
+			return "/dashboard/static/" + name + "?v=" + versions[name]
+		},
+	}).Funcs(localeFuncs(i18n.Fallback)).ParseFS(a.files, "*.html")
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.versions = versions
+	a.tmpl = tmpl
+	a.mu.Unlock()
+	return nil
+}
+
+// render executes a page template in lang; dev mode reloads from disk
+// first. The parsed templates are never executed themselves, only
+// clones bound to the request's language.
+func (a *assets) render(w http.ResponseWriter, page, lang string, data interface{}) error {
+	if a.dev {
+		if err := a.load(); err != nil {
+			return err
+		}
+	}
+
+	a.mu.RLock()
+	tmpl, err := a.tmpl.Clone()
+	a.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return tmpl.Funcs(localeFuncs(lang)).ExecuteTemplate(w, page, data)
+}
+
+// localeFuncs are the template functions for text in lang: t for one
+// message, messages for every dashboard message, which the pages hand
+// to their scripts
+func localeFuncs(lang string) template.FuncMap {
+	return template.FuncMap{
+		"lang": func() string { return lang },
+		"t": func(key string, args ...interface{}) string {
+			return i18n.Default.T(lang, key, args...)
+		},
+		"messages": func() map[string]string {
+			return i18n.Default.Messages(lang, "dashboard.")
+		},
+	}
+}
+
+// pageLanguage is the language negotiated for a dashboard page by the
+// server's locale middleware, or from Accept-Language without it
+func pageLanguage(w http.ResponseWriter, r *http.Request) string {
+	if lang := w.Header().Get("Content-Language"); lang != "" {
+		return lang
+	}
+	return i18n.Default.Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// SetDevDir serves the dashboard from dir instead of the embedded bundle,
+// re-reading files on every request. dir should contain the same layout
+// as internal/dashboard/static.
+func (h *Handler) SetDevDir(dir string) error {
+	a := &assets{files: os.DirFS(dir), dev: true}
+	if err := a.load(); err != nil {
+		return err
+	}
+	h.assets = a
+	return nil
+}
+
+// HandleStatic serves CSS, JS, and other assets. Requests carrying the
+// current ?v= hash are cached indefinitely.
+// GET /dashboard/static/{file}
+func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/dashboard/static/")
+	if name == "" || strings.HasSuffix(name, ".html") || !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	a := h.assets
+	a.mu.RLock()
+	version, ok := a.versions[name]
+	a.mu.RUnlock()
+	if !ok && !a.dev {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !a.dev && r.URL.Query().Get("v") == version {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeFileFS(w, r, a.files, name)
+}
@@ -0,0 +1,119 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"echohelix/bridge/internal/process"
+	"echohelix/bridge/internal/session"
+)
+
+// KernelControl lets the dashboard start and stop kernels through the
+// server, so the same events are published as for API requests
+type KernelControl struct {
+	Status func() process.Status
+	Start  func(kernel string, port int) error
+	Stop   func() error
+}
+
+// SetControls enables the session and kernel panels
+func (h *Handler) SetControls(sessions *session.Manager, kernel KernelControl) {
+	h.sessions = sessions
+	h.kernel = kernel
+}
+
+// HandleListSessions returns sessions, most recently active first
+func (h *Handler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.sessions == nil {
+		writeError(w, "NOT_CONFIGURED", http.StatusServiceUnavailable, "Session manager not available")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": h.sessions.List(),
+	})
+}
+
+// HandleSessionTranscript returns a session with its messages
+func (h *Handler) HandleSessionTranscript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.sessions == nil {
+		writeError(w, "NOT_CONFIGURED", http.StatusServiceUnavailable, "Session manager not available")
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	sess, ok := h.sessions.Get(id)
+	if !ok {
+		writeError(w, "SESSION_NOT_FOUND", http.StatusNotFound, "session not found")
+		return
+	}
+
+	limit := 500
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	messages, err := h.sessions.GetMessages(id, limit, 0)
+	if err != nil {
+		writeError(w, "SESSION_NOT_FOUND", http.StatusNotFound, err.Error())
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session":  sess,
+		"messages": messages,
+	})
+}
+
+// HandleKernelStatus returns the kernel process state
+func (h *Handler) HandleKernelStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.kernel.Status == nil {
+		writeError(w, "NOT_CONFIGURED", http.StatusServiceUnavailable, "Kernel control not available")
+		return
+	}
+	json.NewEncoder(w).Encode(h.kernel.Status())
+}
+
+// HandleKernelStart starts a kernel
+// POST /dashboard/kernel/start {"kernel": "gemini", "port": 41242}
+func (h *Handler) HandleKernelStart(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.kernel.Start == nil {
+		writeError(w, "NOT_CONFIGURED", http.StatusServiceUnavailable, "Kernel control not available")
+		return
+	}
+
+	var req struct {
+		Kernel string `json:"kernel"`
+		Port   int    `json:"port"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "INVALID_BODY", http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.kernel.Start(req.Kernel, req.Port); err != nil {
+		h.logger.Log("ERROR", "Failed to start kernel from dashboard: "+err.Error())
+		writeError(w, "INTERNAL_ERROR", http.StatusInternalServerError, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(h.kernel.Status())
+}
+
+// HandleKernelStop stops the running kernel
+func (h *Handler) HandleKernelStop(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.kernel.Stop == nil {
+		writeError(w, "NOT_CONFIGURED", http.StatusServiceUnavailable, "Kernel control not available")
+		return
+	}
+
+	if err := h.kernel.Stop(); err != nil {
+		writeError(w, "INTERNAL_ERROR", http.StatusInternalServerError, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(h.kernel.Status())
+}
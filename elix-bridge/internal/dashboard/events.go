@@ -0,0 +1,58 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"echohelix/bridge/internal/events"
+)
+
+// pageTopics are the bus events the dashboard page reacts to
+var pageTopics = []string{"auth.paired", "auth.pairing_requested"}
+
+// SetEvents lets the page follow pairing as it happens
+func (h *Handler) SetEvents(bus *events.Bus) {
+	h.events = bus
+}
+
+// HandleEventStream streams pairing events to the page as server-sent
+// events, so a code being used shows up without a reload
+// GET /dashboard/events
+func (h *Handler) HandleEventStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || h.events == nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, "NOT_CONFIGURED", http.StatusServiceUnavailable, "Event stream not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	sub := h.events.Subscribe(pageTopics...)
+	defer sub.Close()
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Topic, data)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// logFilter selects log entries for the logs API and stream
+type logFilter struct {
+	level     zerolog.Level
+	query     string // 小写，匹配消息和字段值
+	component string
+	since     time.Time
+}
+
+// parseLogFilter reads level=, q=, component=, and since= from r
+func parseLogFilter(r *http.Request) (logFilter, error) {
+	q := r.URL.Query()
+	f := logFilter{
+		level:     zerolog.TraceLevel,
+		query:     strings.ToLower(q.Get("q")),
+		component: q.Get("component"),
+	}
+
+	if v := q.Get("level"); v != "" {
+		lvl, err := zerolog.ParseLevel(strings.ToLower(v))
+		if err != nil {
+			return f, fmt.Errorf("invalid level %q", v)
+		}
+		f.level = lvl
+	}
+
+	if v := q.Get("since"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			f.since = t
+		} else if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			f.since = time.Now().Add(-d)
+		} else {
+			return f, errors.New("since must be an RFC 3339 time or a duration such as 15m")
+		}
+	}
+	return f, nil
+}
+
+func (f logFilter) match(e LogEntry) bool {
+	if f.level > zerolog.TraceLevel && entryLevel(e) < f.level {
+		return false
+	}
+	if f.component != "" && e.Component != f.component {
+		return false
+	}
+	if !f.since.IsZero() && e.Timestamp.Before(f.since) {
+		return false
+	}
+	if f.query != "" && !entryContains(e, f.query) {
+		return false
+	}
+	return true
+}
+
+func (f logFilter) apply(entries []LogEntry) []LogEntry {
+	result := make([]LogEntry, 0, len(entries))
+	for _, e := range entries {
+		if f.match(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func entryLevel(e LogEntry) zerolog.Level {
+	lvl, err := zerolog.ParseLevel(strings.ToLower(e.Level))
+	if err != nil {
+		return zerolog.InfoLevel
+	}
+	return lvl
+}
+
+// entryContains matches the lowercased query against the message and
+// field values
+func entryContains(e LogEntry, query string) bool {
+	if strings.Contains(strings.ToLower(e.Message), query) {
+		return true
+	}
+	for k, v := range e.Fields {
+		if strings.Contains(strings.ToLower(k+"="+fmt.Sprint(v)), query) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,19 +2,38 @@ package dashboard
 
 import (
 	"encoding/json"
-	"html/template"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/events"
+	"echohelix/bridge/internal/i18n"
+	"echohelix/bridge/internal/metrics"
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/stats"
+	"echohelix/bridge/internal/usage"
+
+	"github.com/rs/zerolog/log"
 )
 
 // Handler handles Dashboard requests
 type Handler struct {
 	logger      *Logger
 	authService *auth.Service
-	tmpl        *template.Template
+	assets      *assets
+
+	// 会话与内核面板，由 SetControls 注入
+	sessions *session.Manager
+	kernel   KernelControl
+	metrics  *metrics.Collector
+	usage    *usage.Ledger
+	budgets  func() usage.Budgets
+	stats    *stats.Aggregator
+
+	// 配对事件，由 SetEvents 注入
+	events *events.Bus
 }
 
 // NewHandler creates a new Dashboard handler
@@ -23,7 +42,7 @@ func NewHandler(logger *Logger, authService *auth.Service) *Handler {
 		logger:      logger,
 		authService: authService,
 	}
-	h.tmpl = template.Must(template.New("dashboard").Parse(dashboardHTML))
+	h.assets = newEmbeddedAssets()
 	return h
 }
 
@@ -44,38 +63,119 @@ func (h *Handler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 		"ExpiresIn":   expiresIn,
 	}
 
-	h.tmpl.Execute(w, data)
+	if err := h.assets.render(w, "index.html", pageLanguage(w, r), data); err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to render dashboard")
+	}
 }
 
-// HandleGetLogs returns log data
+// HandleGetLogs returns log data, most recent last.
+// Filters: level= (minimum), q= (text in message or fields), component=,
+// since= (RFC 3339 time or duration such as 15m), count= (default 100).
+// format=ndjson downloads the matching entries one JSON object per line.
+// GET /dashboard/logs
 func (h *Handler) HandleGetLogs(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	filter, err := parseLogFilter(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, "INVALID_PARAMETER", http.StatusBadRequest, err.Error())
+		return
+	}
 
-	countStr := r.URL.Query().Get("count")
 	count := 100
-	if countStr != "" {
-		if n, err := strconv.Atoi(countStr); err == nil && n > 0 {
-			count = n
+	if n, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && n > 0 {
+		count = n
+	}
+	logs := filter.apply(h.logger.GetLogs(0))
+
+	if r.URL.Query().Get("format") == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="echohelix-logs-%s.ndjson"`, time.Now().Format("20060102-150405")))
+		enc := json.NewEncoder(w)
+		for _, e := range logs {
+			enc.Encode(e)
 		}
+		return
 	}
 
-	logs := h.logger.GetLogs(count)
+	matched := len(logs)
+	if len(logs) > count {
+		logs = logs[len(logs)-count:]
+	}
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"logs":  logs,
-		"total": h.logger.Count(),
+		"logs":    logs,
+		"matched": matched,
+		"total":   h.logger.Count(),
 	})
 }
 
-// HandleRefreshPairingCode refreshes the pairing code
+// HandleLogStream streams logs as server-sent events: the buffered
+// entries first, then new ones as they are logged. Accepts the same
+// filters as HandleGetLogs.
+func (h *Handler) HandleLogStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, "INTERNAL_ERROR", http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	filter, err := parseLogFilter(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, "INVALID_PARAMETER", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	replay, ch, cancel := h.logger.Subscribe()
+	defer cancel()
+
+	writeEntry := func(e LogEntry) {
+		data, _ := json.Marshal(e)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+
+	for _, e := range filter.apply(replay) {
+		writeEntry(e)
+	}
+	flusher.Flush()
+
+	// 定期发送注释行，防止代理断开空闲连接
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case e := <-ch:
+			if filter.match(e) {
+				writeEntry(e)
+				flusher.Flush()
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// HandleRefreshPairingCode refreshes the pairing code; ?guest=true issues
+// a read-only guest code instead
 func (h *Handler) HandleRefreshPairingCode(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	pc, err := h.authService.GeneratePairingCode()
+	generate := h.authService.GeneratePairingCode
+	if r.URL.Query().Get("guest") == "true" {
+		generate = h.authService.GenerateGuestPairingCode
+	}
+	pc, err := generate()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeError(w, "INTERNAL_ERROR", http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -83,89 +183,83 @@ func (h *Handler) HandleRefreshPairingCode(w http.ResponseWriter, r *http.Reques
 		"code":       pc.Code,
 		"expires_at": pc.ExpiresAt,
 		"expires_in": int64(time.Until(pc.ExpiresAt).Seconds()),
+		"guest":      pc.Guest,
+	})
+}
+
+// HandlePairingRequests returns the pairings waiting for approval
+func (h *Handler) HandlePairingRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"requests": h.authService.PairingRequests(),
+	})
+}
+
+// HandleApprovePairing approves a waiting pairing
+func (h *Handler) HandleApprovePairing(w http.ResponseWriter, r *http.Request) {
+	h.decidePairing(w, r, h.authService.ApprovePairing)
+}
+
+// HandleDenyPairing denies a waiting pairing
+func (h *Handler) HandleDenyPairing(w http.ResponseWriter, r *http.Request) {
+	h.decidePairing(w, r, h.authService.DenyPairing)
+}
+
+func (h *Handler) decidePairing(w http.ResponseWriter, r *http.Request, decide func(id string) (auth.PairingRequest, error)) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, "MISSING_PARAMETER", http.StatusBadRequest, "Request ID is required")
+		return
+	}
+	req, err := decide(id)
+	if err != nil {
+		writeError(w, "PAIRING_REQUEST_NOT_FOUND", http.StatusNotFound, err.Error())
+		return
+	}
+	log.Info().Str("request", id).Str("device", req.DeviceID).Str("status", req.Status).Msg("Pairing decided from dashboard")
+	json.NewEncoder(w).Encode(req)
+}
+
+// HandleListDevices returns the paired devices
+func (h *Handler) HandleListDevices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	devices := []auth.DeviceInfo{}
+	for _, t := range h.authService.ListActiveDevices() {
+		devices = append(devices, t.Info())
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"devices": devices,
 	})
 }
 
-// Minimal HTML template
-const dashboardHTML = `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>EchoHelix Dashboard</title>
-    <style>
-        body { font-family: monospace; margin: 20px; background: #000; color: #0f0; }
-        h1 { border-bottom: 2px solid #0f0; padding-bottom: 10px; }
-        .section { margin: 30px 0; padding: 20px; border: 1px solid #0f0; }
-        .code { font-size: 32px; letter-spacing: 8px; text-align: center; margin: 20px 0; }
-        .timer { text-align: center; margin: 10px 0; }
-        button { background: #0f0; color: #000; border: none; padding: 10px 20px; font-size: 14px; cursor: pointer; font-family: monospace; }
-        button:hover { background: #0a0; }
-        #logs { font-size: 12px; height: 400px; overflow-y: scroll; border: 1px solid #0f0; padding: 10px; }
-        .log-entry { margin: 2px 0; }
-        .info { color: #0ff; }
-        .warn { color: #ff0; }
-        .error { color: #f00; }
-    </style>
-</head>
-<body>
-    <h1>🌊 EchoHelix Bridge Dashboard</h1>
-    
-    <div class="section">
-        <h2>📱 配对码</h2>
-        <div class="code" id="code">{{.PairingCode}}</div>
-        <div class="timer">剩余: <span id="timer">--:--</span></div>
-        <center><button onclick="refresh()">🔄 刷新</button></center>
-    </div>
-
-    <div class="section">
-        <h2>📋 服务器日志 <button onclick="loadLogs()" style="float:right">刷新</button></h2>
-        <div id="logs">加载中...</div>
-    </div>
-
-    <script>
-        let countdown = {{.ExpiresIn}};
-
-        function updateTimer() {
-            if (countdown <= 0) {
-                document.getElementById('timer').textContent = '已过期';
-                return;
-            }
-            const m = Math.floor(countdown / 60);
-            const s = countdown % 60;
-            document.getElementById('timer').textContent = m + ':' + s.toString().padStart(2, '0');
-            countdown--;
-        }
-
-        async function refresh() {
-            const res = await fetch('/dashboard/pairing/refresh', { method: 'POST' });
-            const data = await res.json();
-            if (data.code) {
-                document.getElementById('code').textContent = data.code;
-                countdown = data.expires_in;
-                updateTimer();
-            }
-        }
-
-        async function loadLogs() {
-            const res = await fetch('/dashboard/logs?count=100');
-            const data = await res.json();
-            const container = document.getElementById('logs');
-            if (!data.logs || data.logs.length === 0) {
-                container.innerHTML = '暂无日志';
-                return;
-            }
-            container.innerHTML = data.logs.map(log => {
-                const time = new Date(log.timestamp).toLocaleTimeString();
-                const cls = log.level.toLowerCase();
-                return '<div class="log-entry ' + cls + '">[' + time + '] ' + log.level + ': ' + log.message + '</div>';
-            }).join('');
-        }
-
-        setInterval(updateTimer, 1000);
-        setInterval(loadLogs, 3000);
-        loadLogs();
-        updateTimer();
-    </script>
-</body>
-</html>`
+// HandleRevokeDevice revokes a paired device
+func (h *Handler) HandleRevokeDevice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, "MISSING_PARAMETER", http.StatusBadRequest, "id parameter is required")
+		return
+	}
+	if !h.authService.RevokeDevice(id) {
+		writeError(w, "NOT_FOUND", http.StatusNotFound, "device not found")
+		return
+	}
+	h.logger.Log("INFO", "Device revoked from dashboard: "+id)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "revoked", "device_id": id})
+}
+
+// writeError writes an error body in the same shape as the API,
+// translated like it into the response's Content-Language
+func writeError(w http.ResponseWriter, code string, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":      i18n.Default.Localize(w.Header().Get("Content-Language"), "error."+code, message),
+		"code":       code,
+		"request_id": w.Header().Get("X-Request-ID"),
+	})
+}
@@ -1,22 +1,29 @@
 package dashboard
 
 import (
+	"encoding/json"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/rs/zerolog"
 )
 
 // LogEntry represents a single log entry
 type LogEntry struct {
-	Timestamp time.Time `json:"timestamp"`
-	Level     string    `json:"level"`
-	Message   string    `json:"message"`
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Component string                 `json:"component,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
 }
 
 // Logger collects logs in memory
 type Logger struct {
-	mu      sync.RWMutex
-	entries []LogEntry
-	maxSize int
+	mu          sync.RWMutex
+	entries     []LogEntry
+	maxSize     int
+	subscribers map[chan LogEntry]struct{}
 }
 
 // NewLogger creates a new logger
@@ -25,21 +32,24 @@ func NewLogger(maxSize int) *Logger {
 		maxSize = 500
 	}
 	return &Logger{
-		entries: make([]LogEntry, 0, maxSize),
-		maxSize: maxSize,
+		entries:     make([]LogEntry, 0, maxSize),
+		maxSize:     maxSize,
+		subscribers: make(map[chan LogEntry]struct{}),
 	}
 }
 
 // Log adds a log entry
 func (l *Logger) Log(level, message string) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	entry := LogEntry{
+	l.add(LogEntry{
 		Timestamp: time.Now(),
 		Level:     level,
 		Message:   message,
-	}
+	})
+}
+
+func (l *Logger) add(entry LogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
 	l.entries = append(l.entries, entry)
 
@@ -47,6 +57,66 @@ func (l *Logger) Log(level, message string) {
 	if len(l.entries) > l.maxSize {
 		l.entries = l.entries[len(l.entries)-l.maxSize:]
 	}
+
+	for ch := range l.subscribers {
+		select {
+		case ch <- entry:
+		default:
+			// 订阅者处理不过来时丢弃，避免阻塞日志写入
+		}
+	}
+}
+
+// Write implements io.Writer for zerolog JSON output, keeping each
+// event's fields. Add it to the global logger with zerolog.MultiLevelWriter.
+func (l *Logger) Write(p []byte) (int, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return len(p), nil
+	}
+
+	entry := LogEntry{Timestamp: time.Now()}
+	if v, ok := fields[zerolog.LevelFieldName].(string); ok {
+		entry.Level = strings.ToUpper(v)
+	}
+	if v, ok := fields[zerolog.MessageFieldName].(string); ok {
+		entry.Message = v
+	}
+	if v, ok := fields["component"].(string); ok {
+		entry.Component = v
+	}
+	delete(fields, zerolog.LevelFieldName)
+	delete(fields, zerolog.MessageFieldName)
+	delete(fields, zerolog.TimestampFieldName)
+	delete(fields, "component")
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+
+	if entry.Message != "" && entry.Level != "" {
+		l.add(entry)
+	}
+	return len(p), nil
+}
+
+// Subscribe returns the buffered logs and a channel of new entries.
+// cancel must be called when the subscriber goes away.
+func (l *Logger) Subscribe() ([]LogEntry, <-chan LogEntry, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	replay := make([]LogEntry, len(l.entries))
+	copy(replay, l.entries)
+
+	ch := make(chan LogEntry, 256)
+	l.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		l.mu.Lock()
+		delete(l.subscribers, ch)
+		l.mu.Unlock()
+	}
+	return replay, ch, cancel
 }
 
 // GetLogs returns the most recent n logs
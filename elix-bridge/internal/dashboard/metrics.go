@@ -0,0 +1,38 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"echohelix/bridge/internal/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SetMetrics enables the metrics page
+func (h *Handler) SetMetrics(collector *metrics.Collector) {
+	h.metrics = collector
+}
+
+// HandleMetricsPage renders the metrics charts
+func (h *Handler) HandleMetricsPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.assets.render(w, "metrics.html", pageLanguage(w, r), nil); err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to render metrics page")
+	}
+}
+
+// HandleMetrics returns every series collected so far
+// GET /dashboard/metrics/data
+func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.metrics == nil {
+		writeError(w, "NOT_CONFIGURED", http.StatusServiceUnavailable, "Metrics not available")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"interval_seconds": h.metrics.Interval().Seconds(),
+		"series":           h.metrics.Snapshot(),
+	})
+}
@@ -0,0 +1,106 @@
+// 轻量折线图：不依赖第三方库，直接在 canvas 上绘制
+function drawChart(canvas, points, format) {
+    const ratio = window.devicePixelRatio || 1;
+    const width = canvas.clientWidth;
+    const height = canvas.clientHeight;
+    canvas.width = width * ratio;
+    canvas.height = height * ratio;
+
+    const ctx = canvas.getContext('2d');
+    ctx.scale(ratio, ratio);
+    ctx.clearRect(0, 0, width, height);
+    ctx.font = '11px monospace';
+    ctx.fillStyle = '#080';
+
+    if (points.length < 2) {
+        ctx.fillText(t('dashboard.metrics.collecting'), 10, height / 2);
+        return;
+    }
+
+    const pad = { left: 60, right: 10, top: 10, bottom: 20 };
+    const w = width - pad.left - pad.right;
+    const h = height - pad.top - pad.bottom;
+    const t0 = new Date(points[0].t).getTime();
+    const t1 = new Date(points[points.length - 1].t).getTime();
+    const max = Math.max(...points.map(p => p.v)) || 1;
+
+    const x = (p) => pad.left + (new Date(p.t).getTime() - t0) / ((t1 - t0) || 1) * w;
+    const y = (v) => pad.top + h - v / max * h;
+
+    // 坐标轴与刻度
+    ctx.strokeStyle = '#060';
+    ctx.beginPath();
+    ctx.moveTo(pad.left, pad.top);
+    ctx.lineTo(pad.left, pad.top + h);
+    ctx.lineTo(pad.left + w, pad.top + h);
+    ctx.stroke();
+    ctx.textAlign = 'right';
+    ctx.fillText(format(max), pad.left - 5, pad.top + 8);
+    ctx.fillText(format(0), pad.left - 5, pad.top + h);
+    ctx.textAlign = 'left';
+    ctx.fillText(new Date(t0).toLocaleTimeString(), pad.left, height - 4);
+    ctx.textAlign = 'right';
+    ctx.fillText(new Date(t1).toLocaleTimeString(), pad.left + w, height - 4);
+
+    ctx.strokeStyle = '#0f0';
+    ctx.lineWidth = 1.5;
+    ctx.beginPath();
+    points.forEach((p, i) => {
+        if (i === 0) ctx.moveTo(x(p), y(p.v));
+        else ctx.lineTo(x(p), y(p.v));
+    });
+    ctx.stroke();
+}
+
+function formatBytes(v) {
+    const units = ['B', 'KB', 'MB', 'GB'];
+    let i = 0;
+    while (v >= 1024 && i < units.length - 1) {
+        v /= 1024;
+        i++;
+    }
+    return v.toFixed(i === 0 ? 0 : 1) + ' ' + units[i];
+}
+
+function formatNumber(v) {
+    return Number.isInteger(v) ? String(v) : v.toFixed(1);
+}
+
+let interval = 10;
+
+// 每个图表：序列名、数值换算（计数按采样间隔折算为每分钟）、显示格式
+const charts = [
+    { name: 'requests', value: (v) => v * 60 / interval, format: (v) => formatNumber(v) + '/min' },
+    { name: 'tokens', value: (v) => v, format: formatNumber },
+    { name: 'active_sessions', value: (v) => v, format: formatNumber },
+    { name: 'kernel_memory_bytes', value: (v) => v, format: formatBytes },
+];
+
+let lastData = null;
+
+function render() {
+    if (!lastData) return;
+    for (const chart of charts) {
+        const points = (lastData.series[chart.name] || []).map(p => ({ t: p.t, v: chart.value(p.v) }));
+        drawChart(document.getElementById(chart.name), points, chart.format);
+        const now = points.length ? chart.format(points[points.length - 1].v) : '--';
+        document.getElementById(chart.name + '-now').textContent = now;
+    }
+}
+
+async function loadMetrics() {
+    try {
+        const res = await fetch('/dashboard/metrics/data');
+        if (!res.ok) throw new Error(res.status);
+        lastData = await res.json();
+        interval = lastData.interval_seconds || interval;
+        document.getElementById('metrics-status').textContent = '';
+        render();
+    } catch (e) {
+        document.getElementById('metrics-status').textContent = t('dashboard.metrics.load_failed', e.message);
+    }
+}
+
+window.addEventListener('resize', render);
+loadMetrics();
+setInterval(loadMetrics, 10000);
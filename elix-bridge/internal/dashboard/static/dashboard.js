@@ -0,0 +1,364 @@
+let countdown = parseInt(document.body.dataset.expiresIn, 10) || 0;
+let paired = false;
+const pairingStatus = document.getElementById('pairing-status').innerHTML;
+
+function updateTimer() {
+    if (paired) return;
+    if (countdown <= 0) {
+        document.getElementById('timer').textContent = t('dashboard.pairing.expired');
+        return;
+    }
+    const m = Math.floor(countdown / 60);
+    const s = countdown % 60;
+    document.getElementById('timer').textContent = m + ':' + s.toString().padStart(2, '0');
+    countdown--;
+}
+
+async function refresh(guest) {
+    const res = await fetch('/dashboard/pairing/refresh' + (guest ? '?guest=true' : ''), { method: 'POST' });
+    const data = await res.json();
+    if (data.code) {
+        if (paired) {
+            paired = false;
+            document.getElementById('pairing-status').innerHTML = pairingStatus;
+        }
+        document.getElementById('code').textContent = data.code;
+        document.getElementById('code-kind').textContent = data.guest ? t('dashboard.pairing.guest_kind') : '';
+        countdown = data.expires_in;
+        updateTimer();
+    }
+}
+
+// 配对成功由服务端推送：配对码换成设备名，其余配对码已在服务端作废
+function connectEvents() {
+    const source = new EventSource('/dashboard/events');
+    source.addEventListener('auth.paired', (e) => {
+        const device = JSON.parse(e.data).data || {};
+        paired = true;
+        document.getElementById('code').textContent = device.device_name || device.device_id;
+        document.getElementById('pairing-status').textContent = t('dashboard.pairing.paired');
+        loadDevices();
+        loadPairingRequests();
+    });
+    source.addEventListener('auth.pairing_requested', loadPairingRequests);
+}
+
+// PAIRING_APPROVAL 开启时，输入配对码的设备需在此核对指纹后批准
+async function loadPairingRequests() {
+    const res = await fetch('/dashboard/pairing/requests');
+    const data = await res.json();
+    const container = document.getElementById('pairing-requests');
+    if (!data.requests || data.requests.length === 0) {
+        container.innerHTML = '';
+        return;
+    }
+    container.innerHTML = '<h3>' + esc(t('dashboard.pairing.requests')) + '</h3>' +
+        '<p class="muted">' + esc(t('dashboard.pairing.requests_hint')) + '</p>' +
+        '<table><tr>' + headers('name', 'device_id', 'fingerprint', 'address') + '<th></th></tr>' +
+        data.requests.map(r => '<tr>' +
+            '<td>' + esc(r.device_name || '-') + (r.guest ? ' 👀' : '') + '</td>' +
+            '<td class="muted">' + esc(r.device_id) + '</td>' +
+            '<td><code>' + esc(r.fingerprint) + '</code></td>' +
+            '<td class="muted">' + esc(r.remote_addr || '-') + '</td>' +
+            '<td><button data-id="' + esc(r.id) + '" onclick="decidePairing(this, \'approve\')">' + esc(t('dashboard.pairing.approve')) + '</button> ' +
+            '<button data-id="' + esc(r.id) + '" onclick="decidePairing(this, \'deny\')">' + esc(t('dashboard.pairing.deny')) + '</button></td>' +
+        '</tr>').join('') + '</table>';
+}
+
+async function decidePairing(btn, decision) {
+    const res = await fetch('/dashboard/pairing/' + decision + '?id=' + encodeURIComponent(btn.dataset.id), { method: 'POST' });
+    if (!res.ok) {
+        const data = await res.json();
+        alert(data.error);
+    }
+    loadPairingRequests();
+}
+
+// 日志通过 SSE 实时推送；暂停时新日志先缓存，恢复后一次性追加
+const maxLogLines = 1000;
+let logSource = null;
+let paused = false;
+let pending = [];
+
+function connectLogs() {
+    if (logSource) logSource.close();
+    const container = document.getElementById('logs');
+    container.innerHTML = '';
+    pending = [];
+    logSource = new EventSource('/dashboard/logs/stream?' + logParams());
+    logSource.onopen = () => { document.getElementById('log-status').textContent = ''; };
+    logSource.onerror = () => { document.getElementById('log-status').textContent = t('dashboard.logs.reconnecting'); };
+    logSource.onmessage = (e) => {
+        const entry = JSON.parse(e.data);
+        if (paused) {
+            pending.push(entry);
+            return;
+        }
+        appendLogs([entry]);
+    };
+}
+
+function logParams() {
+    const params = new URLSearchParams({ level: document.getElementById('level').value });
+    const q = document.getElementById('log-query').value.trim();
+    if (q) params.set('q', q);
+    return params.toString();
+}
+
+function exportLogs() {
+    window.location = '/dashboard/logs?format=ndjson&' + logParams();
+}
+
+function formatFields(fields) {
+    if (!fields) return '';
+    return Object.keys(fields).map(k => k + '=' + (typeof fields[k] === 'object' ? JSON.stringify(fields[k]) : fields[k])).join(' ');
+}
+
+function appendLogs(entries) {
+    const container = document.getElementById('logs');
+    // 仅在已滚动到底部时自动跟随，便于回看历史
+    const atBottom = container.scrollTop + container.clientHeight >= container.scrollHeight - 20;
+    for (const log of entries) {
+        const div = document.createElement('div');
+        div.className = 'log-entry ' + log.level.toLowerCase();
+        div.textContent = '[' + new Date(log.timestamp).toLocaleTimeString() + '] ' + log.level + ': ' +
+            (log.component ? '(' + log.component + ') ' : '') + log.message;
+        if (log.fields) {
+            const span = document.createElement('span');
+            span.className = 'muted';
+            span.textContent = ' ' + formatFields(log.fields);
+            div.appendChild(span);
+        }
+        container.appendChild(div);
+    }
+    while (container.childElementCount > maxLogLines) {
+        container.removeChild(container.firstChild);
+    }
+    if (atBottom) container.scrollTop = container.scrollHeight;
+}
+
+function togglePause() {
+    paused = !paused;
+    const btn = document.getElementById('pause');
+    if (paused) {
+        btn.textContent = t('dashboard.logs.resume');
+    } else {
+        btn.textContent = t('dashboard.logs.pause');
+        appendLogs(pending);
+        pending = [];
+    }
+    document.getElementById('log-status').textContent = paused ? t('dashboard.logs.paused') : '';
+}
+
+function clearLogs() {
+    document.getElementById('logs').innerHTML = '';
+    pending = [];
+}
+
+function esc(s) {
+    const d = document.createElement('div');
+    d.textContent = s == null ? '' : String(s);
+    return d.innerHTML;
+}
+
+// headers renders table header cells from dashboard.col.* messages
+function headers(...cols) {
+    return cols.map(c => '<th>' + esc(t('dashboard.col.' + c)) + '</th>').join('');
+}
+
+function fmtTime(ts) {
+    if (!ts || ts.startsWith('0001')) return '<span class="muted">' + esc(t('dashboard.never')) + '</span>';
+    return esc(new Date(ts).toLocaleString());
+}
+
+async function loadDevices() {
+    const res = await fetch('/dashboard/devices');
+    const data = await res.json();
+    const container = document.getElementById('devices');
+    if (!data.devices || data.devices.length === 0) {
+        container.textContent = t('dashboard.devices.none');
+        return;
+    }
+    container.innerHTML = '<table><tr>' + headers('name', 'device_id', 'last_active', 'client', 'permissions', 'encryption') + '<th></th></tr>' +
+        data.devices.map(d => '<tr>' +
+            '<td>' + esc(d.device_name || '-') + '</td>' +
+            '<td class="muted">' + esc(d.device_id) + '</td>' +
+            '<td>' + fmtTime(d.last_used_at) + '</td>' +
+            '<td>' + fmtClient(d.last_seen, d.bound_network) + '</td>' +
+            '<td>' + esc((d.permissions || []).join(', ')) + '</td>' +
+            '<td>' + (d.e2e ? '🔒' : '-') + '</td>' +
+            '<td><button data-id="' + esc(d.device_id) + '" data-name="' + esc(d.device_name || d.device_id) + '" onclick="revokeDevice(this)">' + esc(t('dashboard.devices.revoke')) + '</button></td>' +
+        '</tr>').join('') + '</table>';
+}
+
+// fmtClient 显示设备最近一次连接的平台、版本与地址；绑定网段时附上 🔗
+function fmtClient(c, bound) {
+    if (!c || !c.ip) return '<span class="muted">-</span>';
+    const app = [c.platform, c.app_version].filter(Boolean).join(' ');
+    return (app ? esc(app) + '<br>' : '') +
+        '<span class="muted" title="' + esc(c.user_agent || '') + '">' + esc(c.ip) + '</span>' +
+        (bound ? ' <span title="' + esc(t('dashboard.devices.bound', bound)) + '">🔗</span>' : '');
+}
+
+async function revokeDevice(btn) {
+    if (!confirm(t('dashboard.devices.revoke_confirm', btn.dataset.name))) return;
+    const res = await fetch('/dashboard/devices?id=' + encodeURIComponent(btn.dataset.id), { method: 'DELETE' });
+    if (!res.ok) {
+        const data = await res.json();
+        alert(t('dashboard.devices.revoke_failed', data.error));
+    }
+    loadDevices();
+}
+
+async function loadKernel() {
+    const res = await fetch('/dashboard/kernel');
+    const st = await res.json();
+    const el = document.getElementById('kernel');
+    if (!res.ok) {
+        el.textContent = st.error;
+        return;
+    }
+    if (!st.running) {
+        el.innerHTML = esc(t('dashboard.kernel.status')) + ': <span class="muted">' + esc(t('dashboard.kernel.stopped')) + '</span>' +
+            (st.kernel ? ' (' + esc(t('dashboard.kernel.last', st.kernel)) + ')' : '');
+        return;
+    }
+    el.innerHTML = esc(t('dashboard.kernel.status')) + ': <span class="info">' + esc(t('dashboard.kernel.running')) + '</span> — ' +
+        esc(st.kernel) + ' · ' + esc(t('dashboard.kernel.port')) + ' ' + st.port +
+        ' · PID ' + st.pid + ' · ' + esc(t('dashboard.kernel.started')) + ' ' + fmtTime(st.started_at);
+}
+
+async function startKernel() {
+    const kernel = document.getElementById('kernel-name').value;
+    const res = await fetch('/dashboard/kernel/start', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ kernel: kernel, port: { aider: 41243, echo: 41244 }[kernel] || 41242 })
+    });
+    if (!res.ok) alert(t('dashboard.kernel.start_failed', (await res.json()).error));
+    loadKernel();
+}
+
+async function stopKernel() {
+    const res = await fetch('/dashboard/kernel/stop', { method: 'POST' });
+    if (!res.ok) alert(t('dashboard.kernel.stop_failed', (await res.json()).error));
+    loadKernel();
+}
+
+async function loadSessions() {
+    const res = await fetch('/dashboard/sessions');
+    const data = await res.json();
+    const container = document.getElementById('sessions');
+    const sessions = (data.sessions || []).sort((a, b) => b.updated_at.localeCompare(a.updated_at));
+    if (sessions.length === 0) {
+        container.textContent = t('dashboard.sessions.none');
+        return;
+    }
+    container.innerHTML = '<table><tr>' + headers('name', 'status', 'model', 'messages', 'last_active') + '<th></th></tr>' +
+        sessions.map(s => '<tr>' +
+            '<td>' + esc(s.name) + '</td>' +
+            '<td>' + esc(s.status) + '</td>' +
+            '<td class="muted">' + esc(s.provider) + ' / ' + esc(s.model) + '</td>' +
+            '<td>' + s.message_count + '</td>' +
+            '<td>' + fmtTime(s.updated_at) + '</td>' +
+            '<td><button data-id="' + esc(s.id) + '" onclick="openTranscript(this.dataset.id)">' + esc(t('dashboard.sessions.view')) + '</button></td>' +
+        '</tr>').join('') + '</table>';
+}
+
+async function openTranscript(id) {
+    const res = await fetch('/dashboard/sessions/transcript?id=' + encodeURIComponent(id));
+    const data = await res.json();
+    if (!res.ok) {
+        alert(data.error);
+        return;
+    }
+    document.getElementById('transcript-title').textContent = data.session.name;
+    const body = document.getElementById('transcript-body');
+    const messages = data.messages || [];
+    body.innerHTML = messages.length === 0 ? esc(t('dashboard.sessions.no_messages')) : messages.map(m =>
+        '<div class="msg ' + esc(m.role) + '"><span class="role">' + esc(m.role) + '</span> ' +
+        '<span class="muted">' + fmtTime(m.timestamp) + '</span><br>' + esc(m.content) + '</div>'
+    ).join('');
+    document.getElementById('transcript').style.display = 'block';
+}
+
+function closeTranscript() {
+    document.getElementById('transcript').style.display = 'none';
+}
+
+function fmtUsage(u, limit) {
+    u = u || { tokens: 0, cost: 0, turns: 0 };
+    let s = u.tokens + ' tokens' + (limit && limit.tokens ? ' / ' + limit.tokens : '') +
+        ' · $' + u.cost.toFixed(2) + (limit && limit.cost ? ' / $' + limit.cost.toFixed(2) : '');
+    if (limit && ((limit.tokens && u.tokens >= limit.tokens) || (limit.cost && u.cost >= limit.cost))) {
+        s = '<span class="error">' + s + ' · ' + esc(t('dashboard.usage.exceeded')) + '</span>';
+    }
+    return s;
+}
+
+async function loadUsage() {
+    const res = await fetch('/dashboard/usage');
+    const data = await res.json();
+    const container = document.getElementById('usage');
+    if (!res.ok) {
+        container.textContent = data.error;
+        return;
+    }
+    const b = data.budgets;
+    const today = data.today;
+    let html = '<p>' + esc(t('dashboard.usage.today')) + ': ' + fmtUsage(today.total, b.daily) + ' · ' +
+        esc(t('dashboard.usage.turns', today.total.turns)) + '</p>';
+    const devices = Object.entries(today.devices || {});
+    if (devices.length > 0) {
+        html += '<table><tr>' + headers('device', 'today') + '</tr>' +
+            devices.map(([id, u]) => '<tr><td class="muted">' + esc(id) + '</td><td>' + fmtUsage(u, b.device) + '</td></tr>').join('') +
+            '</table>';
+    }
+    const kernels = Object.entries(today.kernels || {});
+    if (kernels.length > 0) {
+        html += '<table><tr>' + headers('kernel', 'today') + '</tr>' +
+            kernels.map(([k, u]) => '<tr><td>' + esc(k) + '</td><td>' + fmtUsage(u) + '</td></tr>').join('') +
+            '</table>';
+    }
+    html += '<table><tr>' + headers('date', 'tokens', 'cost', 'turns') + '</tr>' +
+        data.days.map(d => '<tr><td>' + esc(d.date) + '</td><td>' + d.total.tokens + '</td>' +
+            '<td>$' + d.total.cost.toFixed(2) + '</td><td>' + d.total.turns + '</td></tr>').join('') +
+        '</table>';
+    container.innerHTML = html;
+}
+
+function statsTable(name, rows) {
+    if (rows.length === 0) return '';
+    return '<table><tr>' + headers(name, 'sessions', 'messages', 'tokens') + '</tr>' +
+        rows.map(r => '<tr><td>' + esc(r.name) + '</td><td>' + r.sessions + '</td><td>' + r.messages + '</td><td>' + r.tokens + '</td></tr>').join('') +
+        '</table>';
+}
+
+async function loadStats() {
+    const res = await fetch('/dashboard/stats');
+    const data = await res.json();
+    const container = document.getElementById('stats');
+    if (!res.ok) {
+        container.textContent = data.error;
+        return;
+    }
+    const sum = key => data.days.reduce((n, d) => n + d[key], 0);
+    let html = '<p>' + esc(t('dashboard.stats.summary', data.window_days, sum('sessions'), sum('messages'), sum('tokens'))) + '</p>' +
+        '<p class="muted">' + esc(t('dashboard.stats.average', data.avg_messages_per_session.toFixed(1), (data.avg_session_seconds / 60).toFixed(1))) + '</p>';
+    html += statsTable('provider', data.providers);
+    html += statsTable('model', data.models);
+    html += statsTable('workspace', data.workspaces);
+    container.innerHTML = html;
+}
+
+setInterval(loadKernel, 5000);
+setInterval(updateTimer, 1000);
+connectLogs();
+connectEvents();
+loadPairingRequests();
+loadDevices();
+loadKernel();
+loadSessions();
+loadUsage();
+loadStats();
+updateTimer();
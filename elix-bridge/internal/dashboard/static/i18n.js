@@ -0,0 +1,6 @@
+// 页面模板把当前语言的文案放在 window.I18N 中
+function t(key, ...args) {
+    let text = (window.I18N && window.I18N[key]) || key;
+    args.forEach((arg, i) => { text = text.split('{' + i + '}').join(arg); });
+    return text;
+}
@@ -0,0 +1,24 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"echohelix/bridge/internal/stats"
+)
+
+// SetStats enables the insights panel
+func (h *Handler) SetStats(aggregator *stats.Aggregator) {
+	h.stats = aggregator
+}
+
+// HandleStats returns the session statistics of the last 30 days
+// GET /dashboard/stats
+func (h *Handler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.stats == nil {
+		writeError(w, "NOT_CONFIGURED", http.StatusServiceUnavailable, "Session statistics not available")
+		return
+	}
+	json.NewEncoder(w).Encode(h.stats.Overview(30))
+}
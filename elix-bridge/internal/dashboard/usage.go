@@ -0,0 +1,31 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"echohelix/bridge/internal/usage"
+)
+
+// SetUsage enables the usage panel
+func (h *Handler) SetUsage(ledger *usage.Ledger, budgets func() usage.Budgets) {
+	h.usage = ledger
+	h.budgets = budgets
+}
+
+// HandleUsage returns today's usage against the budgets and the last
+// seven days
+// GET /dashboard/usage
+func (h *Handler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.usage == nil {
+		writeError(w, "NOT_CONFIGURED", http.StatusServiceUnavailable, "Usage ledger not available")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"budgets": h.budgets(),
+		"today":   h.usage.Today(),
+		"days":    h.usage.Days(7),
+	})
+}
@@ -0,0 +1,226 @@
+// Package e2e provides end-to-end payload encryption for EchoHelix Bridge.
+//
+// During pairing the app sends an X25519 public key. The bridge combines
+// it with its long-lived identity key to derive a per-device key (HKDF-
+// SHA256), and payloads are sealed with XChaCha20-Poly1305. Intermediaries
+// such as the relay only ever see envelopes.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package e2e
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Version is sent in the X-EchoHelix-E2E header and envelopes
+const Version = "v1"
+
+// hkdfInfo separates this key from any other use of the shared secret
+const hkdfInfo = "echohelix e2e v1"
+
+var (
+	// ErrInvalidKey is returned for malformed public keys
+	ErrInvalidKey = errors.New("invalid X25519 public key")
+	// ErrDecrypt is returned when an envelope fails authentication
+	ErrDecrypt = errors.New("failed to decrypt payload")
+)
+
+// Identity is the bridge's long-lived X25519 key pair
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// LoadOrCreateIdentity reads the identity key at path, generating it on
+// first use
+func LoadOrCreateIdentity(path string) (*Identity, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity key file: %w", err)
+		}
+		key, err := ecdh.X25519().NewPrivateKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity key: %w", err)
+		}
+		return &Identity{key: key}, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(key.Bytes())
+	if err := os.WriteFile(path, []byte(encoded+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return &Identity{key: key}, nil
+}
+
+// PublicKey returns the base64 public key sent to the app when pairing
+func (id *Identity) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(id.key.PublicKey().Bytes())
+}
+
+// Fingerprint is a short hash of the public key, shown next to the
+// pairing code so the user can check the app received the right key
+func (id *Identity) Fingerprint() string {
+	sum := sha256.Sum256(id.key.PublicKey().Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+// DeviceKey derives the symmetric key shared with a device
+func (id *Identity) DeviceKey(devicePublicKey, deviceID string) ([]byte, error) {
+	pub, err := ParsePublicKey(devicePublicKey)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := id.key.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Key(sha256.New, secret, []byte(deviceID), hkdfInfo, chacha20poly1305.KeySize)
+}
+
+// ParsePublicKey decodes a base64 X25519 public key
+func ParsePublicKey(encoded string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	pub, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return pub, nil
+}
+
+// Envelope is an encrypted payload
+type Envelope struct {
+	Version    string `json:"v"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// Seal encrypts plaintext. aad binds the envelope to its context (for
+// example method and path) so it cannot be replayed elsewhere.
+func Seal(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Envelope{
+		Version:    Version,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, aad)),
+	})
+}
+
+// Open decrypts an envelope produced by Seal
+func Open(key, envelope, aad []byte) ([]byte, error) {
+	var env Envelope
+	if err := json.Unmarshal(envelope, &env); err != nil || env.Version != Version {
+		return nil, ErrDecrypt
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// ReplayWindow is how far a request ID's timestamp may be from the
+// bridge's clock
+const ReplayWindow = 5 * time.Minute
+
+// ErrReplay is returned for request IDs that are malformed, outside
+// ReplayWindow or already used
+var ErrReplay = errors.New("request ID is invalid, stale or already used")
+
+// ReplayGuard accepts each request ID once. IDs have the form
+// "<unix seconds>.<random>"; an ID is remembered until its timestamp
+// leaves ReplayWindow, after which the timestamp alone refuses it.
+type ReplayGuard struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time // scope + ID -> forget after
+	lastPrune time.Time
+}
+
+// NewReplayGuard creates an empty guard
+func NewReplayGuard() *ReplayGuard {
+	return &ReplayGuard{seen: make(map[string]time.Time)}
+}
+
+// Check accepts id the first time it is seen for scope, such as a device
+func (g *ReplayGuard) Check(scope, id string) error {
+	ts, random, ok := strings.Cut(id, ".")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if !ok || err != nil || random == "" || len(id) > 128 {
+		return ErrReplay
+	}
+	now := time.Now()
+	issued := time.Unix(sec, 0)
+	if issued.Before(now.Add(-ReplayWindow)) || issued.After(now.Add(ReplayWindow)) {
+		return ErrReplay
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.Sub(g.lastPrune) > time.Minute {
+		for key, forget := range g.seen {
+			if now.After(forget) {
+				delete(g.seen, key)
+			}
+		}
+		g.lastPrune = now
+	}
+	key := scope + " " + id
+	if _, used := g.seen[key]; used {
+		return ErrReplay
+	}
+	g.seen[key] = issued.Add(ReplayWindow)
+	return nil
+}
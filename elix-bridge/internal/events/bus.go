@@ -0,0 +1,135 @@
+// Package events provides an in-process pub/sub event bus for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a single published event. Topics are dot-separated,
+// e.g. "session.created", "process.exited", "fs.written", "job.finished".
+type Event struct {
+	Topic string      `json:"topic"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// Subscription receives events matching its topic patterns
+type Subscription struct {
+	C <-chan Event
+
+	bus      *Bus
+	ch       chan Event
+	mu       sync.RWMutex
+	patterns []string
+}
+
+// Bus fans published events out to subscribers
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBus creates an event bus
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends an event to all matching subscribers. Slow subscribers
+// drop events rather than blocking the publisher.
+func (b *Bus) Publish(topic string, data interface{}) {
+	ev := Event{Topic: topic, Time: time.Now(), Data: data}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if !sub.Matches(topic) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a subscription for the given topic patterns.
+// Subscribing without patterns matches everything, as "*" does; once
+// set, an empty pattern list matches nothing.
+func (b *Bus) Subscribe(patterns ...string) *Subscription {
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+	ch := make(chan Event, 256)
+	sub := &Subscription{
+		C:        ch,
+		bus:      b,
+		ch:       ch,
+		patterns: patterns,
+	}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Close unsubscribes and closes the channel
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+// SetPatterns replaces the subscription's topic patterns; an empty list
+// pauses the subscription rather than opening it to every topic
+func (s *Subscription) SetPatterns(patterns []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patterns = patterns
+}
+
+// Patterns returns the subscription's topic patterns
+func (s *Subscription) Patterns() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.patterns...)
+}
+
+// Matches reports whether topic matches any of the subscription's patterns.
+// "*" matches everything, "session" and "session.*" match "session.created".
+func (s *Subscription) Matches(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.patterns {
+		if MatchTopic(p, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchTopic reports whether topic matches pattern
+func MatchTopic(pattern, topic string) bool {
+	pattern = strings.TrimSuffix(pattern, ".*")
+	if pattern == "" {
+		return false
+	}
+	if pattern == "*" || pattern == topic {
+		return true
+	}
+	return strings.HasPrefix(topic, pattern+".")
+}
@@ -0,0 +1,77 @@
+package forward
+
+import (
+	"sort"
+	"strings"
+)
+
+// Listener is a TCP port being listened on by a process
+type Listener struct {
+	Port    int    `json:"port"`
+	Address string `json:"address"`
+	PID     int    `json:"pid"`
+}
+
+// Detect returns the TCP ports listened on by the given processes and
+// their descendants, one entry per port
+func Detect(roots []int) ([]Listener, error) {
+	if len(roots) == 0 {
+		return nil, nil
+	}
+	parents, err := processParents()
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := listeners(descendants(roots, parents))
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一端口可能同时监听 IPv4 与 IPv6，只保留一条
+	seen := make(map[int]bool)
+	out := make([]Listener, 0, len(found))
+	for _, l := range found {
+		if !seen[l.Port] {
+			seen[l.Port] = true
+			out = append(out, l)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+	return out, nil
+}
+
+// descendants returns roots and every process below them
+func descendants(roots []int, parents map[int]int) map[int]bool {
+	children := make(map[int][]int)
+	for pid, ppid := range parents {
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	set := make(map[int]bool)
+	queue := append([]int(nil), roots...)
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if pid <= 0 || set[pid] {
+			continue
+		}
+		set[pid] = true
+		queue = append(queue, children[pid]...)
+	}
+	return set
+}
+
+// targetHost returns the address to dial for a listener bound to addr
+func targetHost(addr string) string {
+	switch addr {
+	case "", "*", "0.0.0.0", "::", "127.0.0.1":
+		return "127.0.0.1"
+	case "::1":
+		return "[::1]"
+	}
+	if strings.Contains(addr, ":") && !strings.HasPrefix(addr, "[") {
+		return "[" + addr + "]"
+	}
+	return addr
+}
@@ -0,0 +1,327 @@
+// Package forward provides port forwarding to local dev servers for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package forward
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PathPrefix is where forwards are served: /preview/<id>/...
+const PathPrefix = "/preview/"
+
+// Source says how a forward was created
+type Source string
+
+const (
+	SourceManual   Source = "manual"
+	SourceDetected Source = "detected"
+)
+
+// ErrInvalidPort is returned for ports outside 1-65535
+var ErrInvalidPort = errors.New("port must be between 1 and 65535")
+
+// Forward proxies a local port through the bridge
+type Forward struct {
+	ID        string    `json:"id"`
+	Port      int       `json:"port"`
+	Host      string    `json:"host"`
+	Label     string    `json:"label,omitempty"`
+	Source    Source    `json:"source"`
+	PID       int       `json:"pid,omitempty"`
+	Rewrite   bool      `json:"rewrite"`
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+
+	proxy *httputil.ReverseProxy
+}
+
+// Config configures a Manager
+type Config struct {
+	// Roots returns the processes whose listening ports (and those of
+	// their children) are forwarded automatically; nil disables detection
+	Roots func() []int
+	// Ignore excludes ports from detection, e.g. the kernel's own
+	Ignore func(port int) bool
+	// Interval between detection scans (default 5s)
+	Interval time.Duration
+	// OnChange is called with "added" or "removed" as forwards come and go
+	OnChange func(event string, f Forward)
+}
+
+// Manager owns the active forwards
+type Manager struct {
+	cfg Config
+
+	mu        sync.RWMutex
+	forwards  map[string]*Forward
+	listeners []Listener
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewManager creates a manager and starts port detection when cfg.Roots
+// is set
+func NewManager(cfg Config) *Manager {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	m := &Manager{
+		cfg:      cfg,
+		forwards: make(map[string]*Forward),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if cfg.Roots != nil {
+		go m.detectLoop()
+	} else {
+		close(m.done)
+	}
+	return m
+}
+
+// Options configures a forward created with Add
+type Options struct {
+	// Host to dial (default 127.0.0.1)
+	Host  string
+	Label string
+	// Raw disables URL rewriting, for servers that already handle being
+	// served under a path prefix
+	Raw bool
+}
+
+// Add forwards port. Adding a port that is already forwarded returns the
+// existing forward, which becomes manual so detection no longer removes it.
+func (m *Manager) Add(port int, opts Options) (Forward, error) {
+	if port < 1 || port > 65535 {
+		return Forward{}, ErrInvalidPort
+	}
+	host := opts.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range m.forwards {
+		if f.Port == port {
+			f.Source = SourceManual
+			if opts.Label != "" {
+				f.Label = opts.Label
+			}
+			if f.Rewrite == opts.Raw {
+				f.Rewrite = !opts.Raw
+				f.proxy = newProxy(f)
+			}
+			return *f, nil
+		}
+	}
+
+	f := m.addLocked(port, host, opts.Label, SourceManual, 0, !opts.Raw)
+	m.notify("added", *f)
+	return *f, nil
+}
+
+func (m *Manager) addLocked(port int, host, label string, source Source, pid int, rewrite bool) *Forward {
+	f := &Forward{
+		ID:        generateID(),
+		Port:      port,
+		Host:      host,
+		Label:     label,
+		Source:    source,
+		PID:       pid,
+		Rewrite:   rewrite,
+		CreatedAt: time.Now(),
+	}
+	f.Path = PathPrefix + f.ID + "/"
+	f.proxy = newProxy(f)
+	m.forwards[f.ID] = f
+
+	log.Info().Str("component", "forward").Str("id", f.ID).Int("port", port).Str("source", string(source)).Msg("Port forwarded")
+	return f
+}
+
+// Remove stops forwarding; it reports whether id existed
+func (m *Manager) Remove(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.forwards[id]
+	if !ok {
+		return false
+	}
+	delete(m.forwards, id)
+	m.notify("removed", *f)
+	return true
+}
+
+// Get returns a forward by ID
+func (m *Manager) Get(id string) (Forward, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f, ok := m.forwards[id]
+	if !ok {
+		return Forward{}, false
+	}
+	return *f, true
+}
+
+// List returns the forwards ordered by port
+func (m *Manager) List() []Forward {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]Forward, 0, len(m.forwards))
+	for _, f := range m.forwards {
+		list = append(list, *f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Port < list[j].Port })
+	return list
+}
+
+// Listeners returns the ports found by the latest detection scan
+func (m *Manager) Listeners() []Listener {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Listener{}, m.listeners...)
+}
+
+// ServeHTTP proxies /preview/<id>/<path> to the forward's port. HTTP
+// and WebSocket upgrades are both passed through; responses are rewritten
+// to stay inside the preview unless the forward is raw.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, PathPrefix), "/")
+
+	m.mu.RLock()
+	f, ok := m.forwards[id]
+	m.mu.RUnlock()
+	if !ok {
+		http.Error(w, "Forward not found", http.StatusNotFound)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.URL.Path = "/" + rest
+	out.URL.RawPath = ""
+	f.proxy.ServeHTTP(w, out)
+}
+
+func newProxy(f *Forward) *httputil.ReverseProxy {
+	target := &url.URL{Scheme: "http", Host: f.Host + ":" + strconv.Itoa(f.Port)}
+	rw := &rewriter{prefix: strings.TrimSuffix(f.Path, "/"), port: f.Port}
+	rewrite := f.Rewrite
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			pr.Out.Header.Set("X-Forwarded-Prefix", rw.prefix)
+			// 开发服务器（如 vite）会拒绝未知的 Host
+			pr.Out.Host = "localhost:" + strconv.Itoa(f.Port)
+			if rewrite {
+				rw.rewriteRequest(pr.Out)
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Debug().Str("component", "forward").Err(err).Int("port", f.Port).Msg("Forward upstream failed")
+			http.Error(w, fmt.Sprintf("Nothing is responding on port %d", f.Port), http.StatusBadGateway)
+		},
+	}
+	if rewrite {
+		proxy.ModifyResponse = rw.modifyResponse
+	}
+	return proxy
+}
+
+func (m *Manager) detectLoop() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		m.detect()
+		select {
+		case <-ticker.C:
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// detect forwards newly listening ports of the managed processes and
+// drops detected forwards whose port has closed
+func (m *Manager) detect() {
+	found, err := Detect(m.cfg.Roots())
+	if err != nil {
+		log.Debug().Str("component", "forward").Err(err).Msg("Port detection failed")
+		return
+	}
+
+	listening := make(map[int]Listener, len(found))
+	kept := found[:0]
+	for _, l := range found {
+		if m.cfg.Ignore != nil && m.cfg.Ignore(l.Port) {
+			continue
+		}
+		listening[l.Port] = l
+		kept = append(kept, l)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = kept
+
+	forwarded := make(map[int]bool, len(m.forwards))
+	for id, f := range m.forwards {
+		if _, ok := listening[f.Port]; !ok && f.Source == SourceDetected {
+			delete(m.forwards, id)
+			log.Info().Str("component", "forward").Str("id", id).Int("port", f.Port).Msg("Detected port closed")
+			m.notify("removed", *f)
+			continue
+		}
+		forwarded[f.Port] = true
+	}
+	for _, l := range kept {
+		if !forwarded[l.Port] {
+			f := m.addLocked(l.Port, targetHost(l.Address), "", SourceDetected, l.PID, true)
+			m.notify("added", *f)
+		}
+	}
+}
+
+func (m *Manager) notify(event string, f Forward) {
+	if m.cfg.OnChange != nil {
+		go m.cfg.OnChange(event, f)
+	}
+}
+
+// Close stops detection
+func (m *Manager) Close() {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+	<-m.done
+}
+
+func generateID() string {
+	bytes := make([]byte, 4)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
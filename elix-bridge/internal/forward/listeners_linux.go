@@ -0,0 +1,114 @@
+package forward
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processParents maps every visible process to its parent, from /proc
+func processParents() (map[int]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[int]int, len(entries))
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", e.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// comm 字段可能包含空格与括号，从最后一个 ')' 之后解析
+		s := string(data)
+		fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		if ppid, err := strconv.Atoi(fields[1]); err == nil {
+			parents[pid] = ppid
+		}
+	}
+	return parents, nil
+}
+
+// listeners finds the listening sockets owned by pids by matching the
+// socket inodes in /proc/<pid>/fd against /proc/net/tcp{,6}
+func listeners(pids map[int]bool) ([]Listener, error) {
+	sockets := make(map[string]Listener)
+	for _, name := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		if err := readListening(name, sockets); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	var out []Listener
+	for pid := range pids {
+		fds, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			if l, ok := sockets[inode]; ok {
+				l.PID = pid
+				out = append(out, l)
+			}
+		}
+	}
+	return out, nil
+}
+
+// readListening adds the LISTEN sockets of a /proc/net table, keyed by inode
+func readListening(name string, sockets map[string]Listener) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // 表头
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sl local_address rem_address st ... inode
+		if len(fields) < 10 || fields[3] != "0A" {
+			continue
+		}
+		host, port, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		p, err := strconv.ParseUint(port, 16, 16)
+		if err != nil {
+			continue
+		}
+		sockets[fields[9]] = Listener{Port: int(p), Address: decodeProcIP(host)}
+	}
+	return scanner.Err()
+}
+
+// decodeProcIP decodes an address from /proc/net/tcp, stored as 32-bit
+// words in host (little-endian) byte order
+func decodeProcIP(s string) string {
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw)%4 != 0 {
+		return ""
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return ip.String()
+}
@@ -0,0 +1,74 @@
+//go:build !linux && !windows
+
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// processParents maps every process to its parent, as reported by ps
+func processParents() (map[int]int, error) {
+	out, err := exec.Command("ps", "-A", "-o", "pid=,ppid=").Output()
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[int]int)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			parents[pid] = ppid
+		}
+	}
+	return parents, nil
+}
+
+// listeners asks lsof for the listening TCP sockets of pids
+func listeners(pids map[int]bool) ([]Listener, error) {
+	if len(pids) == 0 {
+		return nil, nil
+	}
+	list := make([]string, 0, len(pids))
+	for pid := range pids {
+		list = append(list, strconv.Itoa(pid))
+	}
+
+	out, err := exec.Command("lsof", "-nP", "-a", "-iTCP", "-sTCP:LISTEN", "-p", strings.Join(list, ","), "-F", "pn").Output()
+	// 没有匹配时 lsof 以状态 1 退出且无输出
+	if err != nil && len(out) == 0 {
+		return nil, nil
+	}
+
+	var found []Listener
+	pid := 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'p':
+			pid, _ = strconv.Atoi(line[1:])
+		case 'n':
+			host, port, err := net.SplitHostPort(line[1:])
+			if err != nil {
+				continue
+			}
+			if p, err := strconv.Atoi(port); err == nil {
+				found = append(found, Listener{Port: p, Address: host, PID: pid})
+			}
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,64 @@
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// processParents maps every process to its parent from a toolhelp snapshot
+func processParents() (map[int]int, error) {
+	snap, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(snap)
+
+	parents := make(map[int]int)
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = windows.Process32First(snap, &entry); err == nil; err = windows.Process32Next(snap, &entry) {
+		parents[int(entry.ProcessID)] = int(entry.ParentProcessID)
+	}
+	return parents, nil
+}
+
+// listeners parses `netstat -ano` for the listening TCP sockets of pids
+func listeners(pids map[int]bool) ([]Listener, error) {
+	out, err := exec.Command("netstat", "-ano", "-p", "TCP").Output()
+	if err != nil {
+		return nil, err
+	}
+	// -p TCP 只列 IPv4，再查一次 IPv6
+	if out6, err := exec.Command("netstat", "-ano", "-p", "TCPv6").Output(); err == nil {
+		out = append(out, out6...)
+	}
+
+	var found []Listener
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// Proto  Local Address  Foreign Address  State  PID
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 5 || fields[3] != "LISTENING" {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil || !pids[pid] {
+			continue
+		}
+		host, port, err := net.SplitHostPort(fields[1])
+		if err != nil {
+			continue
+		}
+		if p, err := strconv.Atoi(port); err == nil {
+			found = append(found, Listener{Port: p, Address: host, PID: pid})
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,242 @@
+package forward
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxRewriteSize bounds the responses that are buffered for rewriting;
+// larger ones pass through unchanged
+const maxRewriteSize = 16 << 20
+
+// Dev servers and SPAs assume they are served from "/". Under
+// /preview/<id>/ their root-relative URLs would escape the preview, so
+// responses are rewritten: URLs in HTML attributes, CSS, and JS imports
+// get the prefix, redirects and cookie paths are mapped, and HTML pages
+// get a <base> tag plus a small script that prefixes URLs passed to
+// fetch, XHR, WebSocket, EventSource, and the history API at runtime.
+var (
+	htmlAttrDouble = regexp.MustCompile(`(?i)(\s(?:src|href|action|poster|formaction)\s*=\s*")(/[^/"][^"]*|/)"`)
+	htmlAttrSingle = regexp.MustCompile(`(?i)(\s(?:src|href|action|poster|formaction)\s*=\s*')(/[^/'][^']*|/)'`)
+	cssURL         = regexp.MustCompile(`(url\(\s*["']?)(/[^/)"'][^)"']*)`)
+	cssImport      = regexp.MustCompile(`(@import\s+["'])(/[^/"'][^"']*)`)
+	jsImport       = regexp.MustCompile(`((?:\bfrom|\bimport)\s*\(?\s*["'])(/[^/"'][^"']*)`)
+	headTag        = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+	baseTag        = regexp.MustCompile(`(?i)<base\s`)
+)
+
+// rewriter maps upstream URLs into a preview
+type rewriter struct {
+	prefix string // "/preview/<id>"，不带结尾斜杠
+	port   int
+}
+
+// origins are the absolute forms of the upstream's own URLs
+func (rw *rewriter) origins() []string {
+	p := strconv.Itoa(rw.port)
+	return []string{"http://localhost:" + p, "http://127.0.0.1:" + p}
+}
+
+// path prefixes a root-relative path, leaving ones already in the
+// preview alone
+func (rw *rewriter) path(p string) string {
+	if strings.HasPrefix(p, rw.prefix+"/") || p == rw.prefix {
+		return p
+	}
+	return rw.prefix + p
+}
+
+// location maps a redirect target
+func (rw *rewriter) location(loc string) string {
+	for _, origin := range rw.origins() {
+		if strings.HasPrefix(loc, origin+"/") || loc == origin {
+			loc = strings.TrimPrefix(loc, origin)
+			if loc == "" {
+				loc = "/"
+			}
+			break
+		}
+	}
+	if strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+		return rw.path(loc)
+	}
+	return loc
+}
+
+// cookie scopes a Set-Cookie header to the preview
+func (rw *rewriter) cookie(value string) string {
+	parts := strings.Split(value, ";")
+	hasPath := false
+	for i, part := range parts {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(k) {
+		case "path":
+			hasPath = true
+			if strings.HasPrefix(v, "/") {
+				parts[i] = " Path=" + rw.path(v)
+			}
+		case "domain":
+			// 上游的 localhost 域名对桥接地址无效
+			parts[i] = ""
+		}
+	}
+	if !hasPath {
+		parts = append(parts, " Path="+rw.prefix+"/")
+	}
+	out := parts[:0]
+	for _, p := range parts {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, ";")
+}
+
+func (rw *rewriter) absolute(body []byte) []byte {
+	for _, origin := range rw.origins() {
+		body = bytes.ReplaceAll(body, []byte(origin+"/"), []byte(rw.prefix+"/"))
+	}
+	return body
+}
+
+// prefixPaths prefixes the path captured by the second group of each
+// match of re
+func (rw *rewriter) prefixPaths(re *regexp.Regexp, body []byte) []byte {
+	return re.ReplaceAllFunc(body, func(m []byte) []byte {
+		sub := re.FindSubmatchIndex(m)
+		out := append([]byte{}, m[:sub[4]]...)
+		out = append(out, rw.path(string(m[sub[4]:sub[5]]))...)
+		return append(out, m[sub[5]:]...)
+	})
+}
+
+func (rw *rewriter) html(body []byte) []byte {
+	body = rw.absolute(body)
+	body = rw.prefixPaths(htmlAttrDouble, body)
+	body = rw.prefixPaths(htmlAttrSingle, body)
+	body = rw.css(body) // 内联样式
+	body = rw.js(body)  // 内联模块脚本
+
+	inject := rw.runtimeScript()
+	if !baseTag.Match(body) {
+		inject = `<base href="` + rw.prefix + `/">` + inject
+	}
+	if loc := headTag.FindIndex(body); loc != nil {
+		return append(body[:loc[1]:loc[1]], append([]byte(inject), body[loc[1]:]...)...)
+	}
+	return append([]byte(inject), body...)
+}
+
+func (rw *rewriter) css(body []byte) []byte {
+	body = rw.prefixPaths(cssURL, body)
+	return rw.prefixPaths(cssImport, body)
+}
+
+func (rw *rewriter) js(body []byte) []byte {
+	return rw.prefixPaths(jsImport, body)
+}
+
+// runtimeScript patches browser APIs so URLs built at runtime stay inside
+// the preview
+func (rw *rewriter) runtimeScript() string {
+	return `<script>(function(){var p=` + strconv.Quote(rw.prefix) + `,port=` + strconv.Itoa(rw.port) + `;` +
+		`function fix(u){if(typeof u!=="string")return u;` +
+		`var m=u.match(/^(https?|wss?):\/\/(localhost|127\.0\.0\.1):(\d+)(\/.*)?$/);if(m&&+m[3]===port)u=m[4]||"/";` +
+		`if(u.charAt(0)==="/"&&u.charAt(1)!=="/"&&u.indexOf(p+"/")!==0&&u!==p)return p+u;return u}` +
+		`function ws(u){u=fix(String(u));if(u.charAt(0)==="/")u=(location.protocol==="https:"?"wss://":"ws://")+location.host+u;return u}` +
+		`var f=window.fetch;if(f)window.fetch=function(i,o){return f.call(this,typeof i==="string"?fix(i):i,o)};` +
+		`var x=XMLHttpRequest.prototype.open;XMLHttpRequest.prototype.open=function(m,u){arguments[1]=fix(u);return x.apply(this,arguments)};` +
+		`var W=window.WebSocket;if(W){var P=function(u,q){return q===undefined?new W(ws(u)):new W(ws(u),q)};P.prototype=W.prototype;["CONNECTING","OPEN","CLOSING","CLOSED"].forEach(function(k){P[k]=W[k]});window.WebSocket=P}` +
+		`var E=window.EventSource;if(E){var Q=function(u,o){return new E(fix(String(u)),o)};Q.prototype=E.prototype;window.EventSource=Q}` +
+		`["pushState","replaceState"].forEach(function(k){var o=history[k];history[k]=function(s,t,u){return o.call(this,s,t,u==null?u:fix(String(u)))}})` +
+		`})();</script>`
+}
+
+// modifyResponse rewrites redirects, cookies, and text bodies of
+// upstream responses
+func (rw *rewriter) modifyResponse(resp *http.Response) error {
+	if loc := resp.Header.Get("Location"); loc != "" {
+		resp.Header.Set("Location", rw.location(loc))
+	}
+	if cookies := resp.Header.Values("Set-Cookie"); len(cookies) > 0 {
+		resp.Header.Del("Set-Cookie")
+		for _, c := range cookies {
+			resp.Header.Add("Set-Cookie", rw.cookie(c))
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusSwitchingProtocols,
+		resp.StatusCode == http.StatusNoContent,
+		resp.StatusCode == http.StatusNotModified,
+		resp.Request != nil && resp.Request.Method == http.MethodHead,
+		resp.ContentLength > maxRewriteSize:
+		return nil
+	}
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return nil
+	}
+
+	var transform func([]byte) []byte
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		transform = rw.html
+	case "text/css":
+		transform = func(b []byte) []byte { return rw.css(rw.absolute(b)) }
+	case "application/javascript", "text/javascript", "application/x-javascript":
+		transform = func(b []byte) []byte { return rw.js(rw.absolute(b)) }
+	default:
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRewriteSize+1))
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+	if len(body) > maxRewriteSize {
+		// 过大的响应原样转发
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+
+	body = transform(body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	// 内容已改变，旧的校验值不再对应
+	resp.Header.Del("Content-MD5")
+	return nil
+}
+
+// rewriteRequest maps the Referer and Origin the browser sends back to
+// the upstream's own URLs
+func (rw *rewriter) rewriteRequest(out *http.Request) {
+	origin := rw.origins()[0]
+	if ref := out.Header.Get("Referer"); ref != "" {
+		if u, err := url.Parse(ref); err == nil {
+			p := strings.TrimPrefix(u.Path, rw.prefix)
+			if p == "" {
+				p = "/"
+			}
+			u.Scheme, u.Host, u.Path, u.RawPath = "http", strings.TrimPrefix(origin, "http://"), p, ""
+			out.Header.Set("Referer", u.String())
+		}
+	}
+	if out.Header.Get("Origin") != "" {
+		out.Header.Set("Origin", origin)
+	}
+	// 需要明文响应体才能改写
+	out.Header.Del("Accept-Encoding")
+}
@@ -0,0 +1,144 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// BlockSignature is the checksum pair of one block of the client's copy
+// of a file, as in rsync. Weak is the rolling checksum below; Strong is
+// the first 16 bytes of the block's SHA-256 in hex.
+type BlockSignature struct {
+	Weak   uint32 `json:"weak"`
+	Strong string `json:"strong"`
+}
+
+// DeltaOp rebuilds part of the file: either a run of blocks the client
+// already has, or literal bytes it doesn't
+type DeltaOp struct {
+	Copy *BlockRange `json:"copy,omitempty"`
+	Data []byte      `json:"data,omitempty"`
+}
+
+// BlockRange is count consecutive client blocks starting at start
+type BlockRange struct {
+	Start int `json:"start"`
+	Count int `json:"count"`
+}
+
+// WeakChecksum is the rsync rolling checksum of block: with a the sum of
+// its bytes and b the sum of (len-i)*block[i], both mod 2^16, it is
+// a | b<<16
+func WeakChecksum(block []byte) uint32 {
+	var a, b uint32
+	n := uint32(len(block))
+	for i, c := range block {
+		a += uint32(c)
+		b += (n - uint32(i)) * uint32(c)
+	}
+	return (a & 0xffff) | (b&0xffff)<<16
+}
+
+// StrongChecksum is the strong checksum of block
+func StrongChecksum(block []byte) string {
+	sum := sha256.Sum256(block)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Delta compares data with the signatures of the client's copy, cut into
+// blocks of blockSize bytes (the last one lastSize bytes, 0 meaning a
+// full block), and returns the operations that turn the client's copy
+// into data
+func Delta(data []byte, blockSize int, sigs []BlockSignature, lastSize int) []DeltaOp {
+	d := &delta{}
+	if blockSize <= 0 || len(sigs) == 0 {
+		d.literal(data)
+		return d.ops
+	}
+
+	// 末尾的短块只能在文件结尾匹配，不参与滚动查找
+	full := len(sigs)
+	if lastSize > 0 && lastSize < blockSize {
+		full--
+	}
+	index := make(map[uint32][]int, full)
+	for i := 0; i < full; i++ {
+		index[sigs[i].Weak] = append(index[sigs[i].Weak], i)
+	}
+
+	bs := uint32(blockSize)
+	var a, b uint32
+	roll := func(at int) {
+		a, b = 0, 0
+		for j, c := range data[at : at+blockSize] {
+			a += uint32(c)
+			b += (bs - uint32(j)) * uint32(c)
+		}
+	}
+
+	pending, i := 0, 0
+	if len(data) >= blockSize {
+		roll(0)
+	}
+	for i+blockSize <= len(data) {
+		if candidates, ok := index[(a&0xffff)|(b&0xffff)<<16]; ok {
+			strong := StrongChecksum(data[i : i+blockSize])
+			matched := -1
+			for _, c := range candidates {
+				if sigs[c].Strong == strong {
+					matched = c
+					break
+				}
+			}
+			if matched >= 0 {
+				d.literal(data[pending:i])
+				d.copy(matched)
+				i += blockSize
+				pending = i
+				if i+blockSize <= len(data) {
+					roll(i)
+				}
+				continue
+			}
+		}
+		if i+blockSize < len(data) {
+			out, in := uint32(data[i]), uint32(data[i+blockSize])
+			a = a - out + in
+			b = b - bs*out + a
+		}
+		i++
+	}
+
+	if full < len(sigs) && len(data)-pending >= lastSize {
+		tail := data[len(data)-lastSize:]
+		last := sigs[len(sigs)-1]
+		if WeakChecksum(tail) == last.Weak && StrongChecksum(tail) == last.Strong {
+			d.literal(data[pending : len(data)-lastSize])
+			d.copy(len(sigs) - 1)
+			return d.ops
+		}
+	}
+	d.literal(data[pending:])
+	return d.ops
+}
+
+// delta accumulates operations, merging runs of consecutive blocks
+type delta struct {
+	ops []DeltaOp
+}
+
+func (d *delta) literal(p []byte) {
+	if len(p) > 0 {
+		d.ops = append(d.ops, DeltaOp{Data: p})
+	}
+}
+
+func (d *delta) copy(block int) {
+	if n := len(d.ops); n > 0 {
+		if last := d.ops[n-1].Copy; last != nil && last.Start+last.Count == block {
+			last.Count++
+			return
+		}
+	}
+	d.ops = append(d.ops, DeltaOp{Copy: &BlockRange{Start: block, Count: 1}})
+}
@@ -0,0 +1,122 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ListCache keeps recent listings and serves them again while none of
+// the directories they were read from has changed. A directory's mtime
+// moves whenever an entry is added, removed or renamed in it, which is
+// all a listing shows, so checking the mtimes is enough to revalidate a
+// recursive listing without walking the tree again.
+type ListCache struct {
+	mu         sync.Mutex
+	lists      map[listKey]*cachedList
+	ttl        time.Duration
+	maxEntries int
+}
+
+type listKey struct {
+	base      string
+	path      string
+	recursive bool
+}
+
+type cachedList struct {
+	entries []FileEntry
+	dirs    map[string]time.Time
+	etag    string
+	at      time.Time
+}
+
+// NewListCache creates a cache whose listings are walked again at the
+// latest after ttl, holding at most maxEntries listings
+func NewListCache(ttl time.Duration, maxEntries int) *ListCache {
+	return &ListCache{
+		lists:      make(map[listKey]*cachedList),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+// List returns the listing of relPath under baseDir together with an
+// ETag that changes whenever the listing can have changed. The returned
+// slice is shared; callers must not modify it.
+func (c *ListCache) List(baseDir, relPath string, recursive bool) ([]FileEntry, string, error) {
+	key := listKey{base: baseDir, path: relPath, recursive: recursive}
+
+	c.mu.Lock()
+	cached := c.lists[key]
+	c.mu.Unlock()
+	if cached != nil && time.Since(cached.at) < c.ttl && cached.fresh() {
+		return cached.entries, cached.etag, nil
+	}
+
+	dirs := make(map[string]time.Time)
+	entries, err := NewWalker(baseDir).listFiles(relPath, recursive, dirs)
+	if err != nil {
+		return nil, "", err
+	}
+	list := &cachedList{
+		entries: entries,
+		dirs:    dirs,
+		etag:    listETag(key, dirs),
+		at:      time.Now(),
+	}
+
+	c.mu.Lock()
+	c.lists[key] = list
+	c.pruneLocked()
+	c.mu.Unlock()
+	return list.entries, list.etag, nil
+}
+
+// fresh reports whether every directory still has the mtime it had
+// when the listing was read
+func (l *cachedList) fresh() bool {
+	for dir, modTime := range l.dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneLocked drops the oldest listings beyond maxEntries
+func (c *ListCache) pruneLocked() {
+	if len(c.lists) <= c.maxEntries {
+		return
+	}
+	keys := make([]listKey, 0, len(c.lists))
+	for key := range c.lists {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return c.lists[keys[i]].at.Before(c.lists[keys[j]].at) })
+	for _, key := range keys[:len(keys)-c.maxEntries] {
+		delete(c.lists, key)
+	}
+}
+
+// listETag hashes the listing's location and directory mtimes
+func listETag(key listKey, dirs map[string]time.Time) string {
+	paths := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		paths = append(paths, dir)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%t\n", key.base, filepath.Clean(key.path), key.recursive)
+	for _, dir := range paths {
+		fmt.Fprintf(h, "%s\x00%d\n", dir, dirs[dir].UnixNano())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+}
@@ -0,0 +1,203 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of a text file
+type Encoding string
+
+const (
+	EncodingUTF8    Encoding = "utf-8"
+	EncodingUTF8BOM Encoding = "utf-8-bom"
+	EncodingUTF16LE Encoding = "utf-16le" // 带 BOM
+	EncodingUTF16BE Encoding = "utf-16be" // 带 BOM
+	EncodingLatin1  Encoding = "latin-1"
+	// EncodingBinary files are served as they are
+	EncodingBinary Encoding = "binary"
+)
+
+// EOL is the line ending style of a text file
+type EOL string
+
+const (
+	EOLLF    EOL = "lf"
+	EOLCRLF  EOL = "crlf"
+	EOLCR    EOL = "cr"
+	EOLMixed EOL = "mixed"
+	EOLNone  EOL = "none" // no line breaks
+)
+
+// DetectSample is how much of a file detection needs to look at
+const DetectSample = 64 << 10
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// ErrUnencodable is returned when text has characters the target
+// encoding cannot represent
+var ErrUnencodable = errors.New("text cannot be represented in the encoding")
+
+// ParseEncoding checks an encoding name from a request
+func ParseEncoding(name string) (Encoding, error) {
+	switch e := Encoding(strings.ToLower(name)); e {
+	case EncodingUTF8, EncodingUTF8BOM, EncodingUTF16LE, EncodingUTF16BE, EncodingLatin1:
+		return e, nil
+	case "utf8":
+		return EncodingUTF8, nil
+	case "latin1", "iso-8859-1":
+		return EncodingLatin1, nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q", name)
+}
+
+// DetectEncoding guesses the encoding of a file from its first bytes.
+// UTF-16 is recognized by its byte order mark, UTF-8 by being valid, and
+// anything else without NUL bytes is taken as Latin-1. more reports that
+// the file continues past the sample, which may then end mid-character.
+func DetectEncoding(sample []byte, more bool) Encoding {
+	switch {
+	case bytes.HasPrefix(sample, bomUTF8):
+		return EncodingUTF8BOM
+	case bytes.HasPrefix(sample, bomUTF16LE):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(sample, bomUTF16BE):
+		return EncodingUTF16BE
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return EncodingBinary
+	}
+	valid := sample
+	if more {
+		// 样本末尾可能截断了一个多字节字符
+		for i := len(sample) - 1; i >= 0 && i > len(sample)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(sample[i]) {
+				if !utf8.FullRune(sample[i:]) {
+					valid = sample[:i]
+				}
+				break
+			}
+		}
+	}
+	if utf8.Valid(valid) {
+		return EncodingUTF8
+	}
+	return EncodingLatin1
+}
+
+// DetectEOL reports the line endings text uses
+func DetectEOL(text string) EOL {
+	crlf := strings.Count(text, "\r\n")
+	lf := strings.Count(text, "\n") - crlf
+	cr := strings.Count(text, "\r") - crlf
+	kinds := 0
+	for _, n := range []int{crlf, lf, cr} {
+		if n > 0 {
+			kinds++
+		}
+	}
+	switch {
+	case kinds == 0:
+		return EOLNone
+	case kinds > 1:
+		return EOLMixed
+	case crlf > 0:
+		return EOLCRLF
+	case cr > 0:
+		return EOLCR
+	}
+	return EOLLF
+}
+
+// Decode converts data in enc to UTF-8, dropping a byte order mark at its
+// start. Binary data is returned unchanged.
+func Decode(data []byte, enc Encoding) string {
+	switch enc {
+	case EncodingUTF8BOM:
+		return string(bytes.TrimPrefix(data, bomUTF8))
+	case EncodingUTF16LE, EncodingUTF16BE:
+		if bytes.HasPrefix(data, bomUTF16LE) || bytes.HasPrefix(data, bomUTF16BE) {
+			data = data[2:]
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if enc == EncodingUTF16LE {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units))
+	case EncodingLatin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(data)
+}
+
+// Encode converts UTF-8 text to enc, with the byte order mark the
+// encoding carries
+func Encode(text string, enc Encoding) ([]byte, error) {
+	switch enc {
+	case EncodingUTF8BOM:
+		return append(append([]byte{}, bomUTF8...), text...), nil
+	case EncodingUTF16LE, EncodingUTF16BE:
+		units := utf16.Encode([]rune(text))
+		out := make([]byte, 2, 2+2*len(units))
+		if enc == EncodingUTF16LE {
+			copy(out, bomUTF16LE)
+			for _, u := range units {
+				out = append(out, byte(u), byte(u>>8))
+			}
+		} else {
+			copy(out, bomUTF16BE)
+			for _, u := range units {
+				out = append(out, byte(u>>8), byte(u))
+			}
+		}
+		return out, nil
+	case EncodingLatin1:
+		out := make([]byte, 0, len(text))
+		for _, r := range text {
+			if r > 0xFF {
+				return nil, fmt.Errorf("%w: %q in latin-1", ErrUnencodable, r)
+			}
+			out = append(out, byte(r))
+		}
+		return out, nil
+	}
+	return []byte(text), nil
+}
+
+// ConvertEOL rewrites every line break in text as eol. Mixed and none
+// leave text unchanged.
+func ConvertEOL(text string, eol EOL) string {
+	var sep string
+	switch eol {
+	case EOLLF:
+		sep = "\n"
+	case EOLCRLF:
+		sep = "\r\n"
+	case EOLCR:
+		sep = "\r"
+	default:
+		return text
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	if sep == "\n" {
+		return text
+	}
+	return strings.ReplaceAll(text, "\n", sep)
+}
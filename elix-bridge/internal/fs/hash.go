@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// HashCache remembers the SHA-256 of files so conditional reads don't
+// hash an unchanged file again. An entry is used while the file keeps
+// the size and modification time it had when it was hashed.
+type HashCache struct {
+	mu         sync.Mutex
+	hashes     map[string]fileHash
+	maxEntries int
+}
+
+type fileHash struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// NewHashCache creates a cache holding at most maxEntries hashes
+func NewHashCache(maxEntries int) *HashCache {
+	return &HashCache{hashes: make(map[string]fileHash), maxEntries: maxEntries}
+}
+
+// Hash returns the hex SHA-256 of f's content. info is f's Stat; the
+// file's read offset is moved, so callers seek before reading it.
+func (c *HashCache) Hash(f *os.File, info os.FileInfo) (string, error) {
+	path := f.Name()
+	c.mu.Lock()
+	h, ok := c.hashes[path]
+	c.mu.Unlock()
+	if ok && h.size == info.Size() && h.modTime.Equal(info.ModTime()) {
+		return h.sum, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sha := sha256.New()
+	if _, err := io.Copy(sha, f); err != nil {
+		return "", err
+	}
+	h = fileHash{size: info.Size(), modTime: info.ModTime(), sum: hex.EncodeToString(sha.Sum(nil))}
+
+	c.mu.Lock()
+	if len(c.hashes) >= c.maxEntries {
+		// 满了就整体清空，重新计算一次哈希的代价不高
+		c.hashes = make(map[string]fileHash)
+	}
+	c.hashes[path] = h
+	c.mu.Unlock()
+	return h.sum, nil
+}
@@ -0,0 +1,232 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+// Entry is a file or directory found by WalkParallel
+type Entry struct {
+	Path  string      // absolute path
+	Rel   string      // path relative to the walk root, with forward slashes
+	IsDir bool        // symlinks to directories are not followed
+	Info  os.FileInfo // set when the options ask for it
+}
+
+// WalkOptions tunes WalkParallel
+type WalkOptions struct {
+	// Workers is how many directories are read at once; 0 picks a
+	// default based on the number of CPUs
+	Workers int
+	// SkipDir reports whether a directory is neither returned nor
+	// descended into; nil skips IsIgnoredDir names. It is called from
+	// several goroutines.
+	SkipDir func(path string, d os.DirEntry) bool
+	// StatDirs and StatFiles fill Entry.Info in the workers, so callers
+	// needing sizes or mtimes don't stat one file at a time
+	StatDirs  bool
+	StatFiles bool
+}
+
+// WalkParallel calls fn for every entry under root, reading directories
+// on a pool of workers. Entries arrive in no particular order, though a
+// directory always comes before its contents. fn is called from the
+// calling goroutine only; an error from fn stops the walk and is
+// returned, as is ctx's error when it is cancelled. Unreadable
+// directories below root are skipped.
+func WalkParallel(ctx context.Context, root string, opts WalkOptions, fn func(Entry) error) error {
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers()
+	}
+	if opts.SkipDir == nil {
+		opts.SkipDir = func(_ string, d os.DirEntry) bool { return IsIgnoredDir(d.Name()) }
+	}
+	if info, err := os.Stat(root); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return &os.PathError{Op: "readdir", Path: root, Err: syscall.ENOTDIR}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pw := &parallelWalk{ctx: ctx, root: root, opts: opts, results: make(chan []Entry, opts.Workers)}
+	pw.cond = sync.NewCond(&pw.mu)
+	stop := context.AfterFunc(ctx, func() {
+		pw.mu.Lock()
+		pw.cond.Broadcast()
+		pw.mu.Unlock()
+	})
+	defer stop()
+
+	pw.push(root)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pw.work()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(pw.results)
+	}()
+
+	var fnErr error
+	for batch := range pw.results {
+		if fnErr != nil {
+			continue // 出错后只需排空，让工作协程退出
+		}
+		for _, e := range batch {
+			if fnErr = fn(e); fnErr != nil {
+				cancel()
+				break
+			}
+		}
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	return ctx.Err()
+}
+
+func defaultWorkers() int {
+	n := runtime.GOMAXPROCS(0) * 2
+	if n < 4 {
+		n = 4
+	}
+	if n > 32 {
+		n = 32
+	}
+	return n
+}
+
+// parallelWalk is a queue of directories still to read. pending counts
+// directories queued or being read; the walk is over when it drops to 0.
+type parallelWalk struct {
+	ctx     context.Context
+	root    string
+	opts    WalkOptions
+	results chan []Entry
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []string
+	pending int
+}
+
+func (pw *parallelWalk) push(dir string) {
+	pw.mu.Lock()
+	pw.queue = append(pw.queue, dir)
+	pw.pending++
+	pw.mu.Unlock()
+	pw.cond.Signal()
+}
+
+// pop waits for a directory to read; ok is false when the walk is over
+func (pw *parallelWalk) pop() (dir string, ok bool) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	for len(pw.queue) == 0 && pw.pending > 0 && pw.ctx.Err() == nil {
+		pw.cond.Wait()
+	}
+	if len(pw.queue) == 0 || pw.ctx.Err() != nil {
+		return "", false
+	}
+	dir = pw.queue[len(pw.queue)-1]
+	pw.queue = pw.queue[:len(pw.queue)-1]
+	return dir, true
+}
+
+func (pw *parallelWalk) done() {
+	pw.mu.Lock()
+	pw.pending--
+	if pw.pending == 0 {
+		pw.cond.Broadcast()
+	}
+	pw.mu.Unlock()
+}
+
+func (pw *parallelWalk) work() {
+	for {
+		dir, ok := pw.pop()
+		if !ok {
+			return
+		}
+		batch, subdirs := pw.read(dir)
+		if len(batch) > 0 {
+			select {
+			case pw.results <- batch:
+				// 目录条目送出后才排队其内容，保证目录先于内容
+				for _, sub := range subdirs {
+					pw.push(sub)
+				}
+			case <-pw.ctx.Done():
+			}
+		}
+		pw.done()
+	}
+}
+
+// read lists one directory and the subdirectories to descend into. A
+// directory's entry is stat'ed here, so its Info predates the read of
+// its contents.
+func (pw *parallelWalk) read(dir string) (batch []Entry, subdirs []string) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil
+	}
+	batch = make([]Entry, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		path := filepath.Join(dir, d.Name())
+		isDir := d.IsDir()
+		if isDir && pw.opts.SkipDir(path, d) {
+			continue
+		}
+		rel, _ := filepath.Rel(pw.root, path)
+		e := Entry{Path: path, Rel: filepath.ToSlash(rel), IsDir: isDir}
+		if (isDir && pw.opts.StatDirs) || (!isDir && pw.opts.StatFiles) {
+			info, err := d.Info()
+			if err != nil {
+				continue // 读取期间被删除
+			}
+			e.Info = info
+		}
+		batch = append(batch, e)
+		if isDir {
+			subdirs = append(subdirs, path)
+		}
+	}
+	return batch, subdirs
+}
+
+// sortWalkOrder sorts slash-separated paths the way filepath.WalkDir
+// visits them: by name within a directory, each directory followed by
+// its contents
+func sortWalkOrder(paths []FileEntry) {
+	sort.Slice(paths, func(i, j int) bool {
+		return walkOrderLess(paths[i].Path, paths[j].Path)
+	})
+}
+
+func walkOrderLess(a, b string) bool {
+	// 把 "/" 视为最小字符，目录内容紧跟在目录之后
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		if a[i] == '/' {
+			return true
+		}
+		if b[i] == '/' {
+			return false
+		}
+		return a[i] < b[i]
+	}
+	return len(a) < len(b)
+}
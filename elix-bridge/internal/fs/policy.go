@@ -0,0 +1,174 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Operations checked by a Policy
+const (
+	OpRead  = "read"
+	OpWrite = "write"
+)
+
+// PolicyFile is where a workspace keeps its policy, relative to its root
+const PolicyFile = ".echohelix/policy.json"
+
+// Policy restricts file access inside a workspace. Patterns are globs on
+// slash-separated paths relative to the workspace root: * and ? stay
+// within one path segment, ** spans segments, "dir/**" also covers dir
+// itself and a pattern without a slash matches a name at any depth.
+//
+//	{"read_only": false, "deny_read": ["secrets/**", ".env"], "deny_write": [".git/**"]}
+type Policy struct {
+	// ReadOnly refuses every write
+	ReadOnly bool `json:"read_only,omitempty"`
+	// DenyRead hides paths entirely; they cannot be written either
+	DenyRead []string `json:"deny_read,omitempty"`
+	// DenyWrite refuses writes to paths that stay readable
+	DenyWrite []string `json:"deny_write,omitempty"`
+	// AllowWrite, when set, refuses writes outside these paths
+	AllowWrite []string `json:"allow_write,omitempty"`
+}
+
+// PolicyError reports an access the workspace policy refuses
+type PolicyError struct {
+	Code string `json:"code"`
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	// Rule is what refused it: "read_only", or the list and pattern,
+	// such as "deny_write:.git/**"
+	Rule string `json:"rule"`
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s of %s is not allowed by the workspace policy (%s)", e.Op, e.Path, e.Rule)
+}
+
+// LoadPolicy reads a workspace's policy; a workspace without one gets an
+// empty policy that allows everything
+func LoadPolicy(workspace string) (*Policy, error) {
+	data, err := os.ReadFile(filepath.Join(workspace, filepath.FromSlash(PolicyFile)))
+	if os.IsNotExist(err) {
+		return &Policy{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", PolicyFile, err)
+	}
+	for _, list := range [][]string{p.DenyRead, p.DenyWrite, p.AllowWrite} {
+		for _, pattern := range list {
+			if _, err := globRegexp(pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q in %s: %w", pattern, PolicyFile, err)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// Check returns a *PolicyError when op on rel (relative to the workspace
+// root) is refused
+func (p *Policy) Check(op, rel string) error {
+	if p == nil {
+		return nil
+	}
+	rel = path.Clean(filepath.ToSlash(rel))
+	if rel == "." {
+		rel = ""
+	}
+	deny := func(rule string) error {
+		return &PolicyError{Code: "POLICY_VIOLATION", Op: op, Path: rel, Rule: rule}
+	}
+
+	// 有策略时不允许通过桥接修改策略本身
+	if op == OpWrite && rel == PolicyFile && !p.Empty() {
+		return deny("policy_file")
+	}
+	if pattern, ok := matchAny(p.DenyRead, rel); ok {
+		return deny("deny_read:" + pattern)
+	}
+	if op != OpWrite {
+		return nil
+	}
+	if p.ReadOnly {
+		return deny("read_only")
+	}
+	if pattern, ok := matchAny(p.DenyWrite, rel); ok {
+		return deny("deny_write:" + pattern)
+	}
+	if len(p.AllowWrite) > 0 {
+		if _, ok := matchAny(p.AllowWrite, rel); !ok {
+			return deny("allow_write")
+		}
+	}
+	return nil
+}
+
+// Readable reports whether rel may be read, for filtering listings
+func (p *Policy) Readable(rel string) bool {
+	return p.Check(OpRead, rel) == nil
+}
+
+// Empty reports whether the policy restricts nothing
+func (p *Policy) Empty() bool {
+	return p == nil || (!p.ReadOnly && len(p.DenyRead) == 0 && len(p.DenyWrite) == 0 && len(p.AllowWrite) == 0)
+}
+
+func matchAny(patterns []string, rel string) (string, bool) {
+	for _, pattern := range patterns {
+		if re, err := globRegexp(pattern); err == nil && re.MatchString(rel) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// globRegexp compiles a policy glob
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimPrefix(path.Clean(pattern), "/")
+	var b strings.Builder
+	// 不含斜杠的模式匹配任意深度的同名条目
+	if !strings.Contains(pattern, "/") {
+		b.WriteString("^(?:.*/)?")
+	} else {
+		b.WriteString("^")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if strings.HasSuffix(b.String(), "/") && i == len(pattern)-1 {
+					// "dir/**" 同时匹配 dir 本身
+					s := strings.TrimSuffix(b.String(), "/")
+					b.Reset()
+					b.WriteString(s + "(?:/.*)?")
+					continue
+				}
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+					continue
+				}
+				b.WriteString(".*")
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// 目录模式也覆盖其中的内容
+	b.WriteString("(?:/.*)?$")
+	return regexp.Compile(b.String())
+}
@@ -1,8 +1,10 @@
 package fs
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Walker provides optimized file system traversal
@@ -37,6 +39,11 @@ var ignoredDirs = map[string]bool{
 	"__pycache__":  true,
 }
 
+// IsIgnoredDir reports whether a directory name is skipped during traversal
+func IsIgnoredDir(name string) bool {
+	return ignoredDirs[name]
+}
+
 // FileEntry represents a file or directory in the list
 type FileEntry struct {
 	Path  string `json:"path"`
@@ -46,70 +53,90 @@ type FileEntry struct {
 
 // ListFiles traverses the directory and returns a list of files
 func (w *Walker) ListFiles(relPath string, recursive bool) ([]FileEntry, error) {
-	rootPath := filepath.Join(w.BaseDir, relPath)
+	return w.listFiles(relPath, recursive, nil)
+}
+
+// Walk calls fn for each entry ListFiles would return as directories are
+// read. Recursive walks read several directories at once, so entries
+// arrive in no fixed order, though each directory comes before its
+// contents. An error from fn or ctx stops the walk and is returned.
+func (w *Walker) Walk(ctx context.Context, relPath string, recursive bool, fn func(FileEntry) error) error {
+	return w.walk(ctx, relPath, recursive, nil, fn)
+}
+
+// listFiles is ListFiles that also records the modification time of
+// every directory it reads in dirs, when dirs is not nil. Recursive
+// listings are sorted back into the order filepath.WalkDir would give.
+func (w *Walker) listFiles(relPath string, recursive bool, dirs map[string]time.Time) ([]FileEntry, error) {
 	var entries []FileEntry
+	err := w.walk(context.Background(), relPath, recursive, dirs, func(e FileEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if recursive {
+		sortWalkOrder(entries)
+	}
+	return entries, nil
+}
+
+// walk stamps each directory in dirs before its entries are read, so a
+// change made during the walk shows up as a stale stamp rather than
+// being missed
+func (w *Walker) walk(ctx context.Context, relPath string, recursive bool, dirs map[string]time.Time, fn func(FileEntry) error) error {
+	rootPath := filepath.Join(w.BaseDir, relPath)
 
-	// If recursive, we use WalkDir (more memory efficient than Walk)
+	// 递归遍历交给并行遍历器；路径始终相对于项目根目录 (BaseDir)，便于 @ 引用
 	if recursive {
-		err := filepath.WalkDir(rootPath, func(path string, d os.DirEntry, err error) error {
+		if ignoredDirs[filepath.Base(rootPath)] {
+			return nil
+		}
+		if dirs != nil {
+			info, err := os.Stat(rootPath)
 			if err != nil {
-				// Skip unreadable files/dirs but continue walking
-				return nil
+				return err
 			}
-
-			// Get relative path from request root (or BaseDir)
-			// For the API response, we want paths relative to the requested root
-			// relToRequest, _ := filepath.Rel(rootPath, path)
-
-			// Actually, for @ inputs, simpler is better: return path relative to Project Root (BaseDir)
-			relToProject, err := filepath.Rel(w.BaseDir, path)
+			dirs[rootPath] = info.ModTime()
+		}
+		return WalkParallel(ctx, rootPath, WalkOptions{StatDirs: dirs != nil}, func(e Entry) error {
+			if e.IsDir && dirs != nil {
+				dirs[e.Path] = e.Info.ModTime()
+			}
+			relToProject, err := filepath.Rel(w.BaseDir, e.Path)
 			if err != nil {
 				return nil
 			}
-
-			// Normalize to forward slashes for consistency across OS
-			relToProject = filepath.ToSlash(relToProject)
-
-			if d.IsDir() {
-				if ignoredDirs[d.Name()] {
-					return filepath.SkipDir
-				}
-				// Don't include the root itself in the list
-				if path == rootPath {
-					return nil
-				}
-			}
-
-			entries = append(entries, FileEntry{
-				Path:  relToProject,
-				IsDir: d.IsDir(),
-				// Getting size requires Info(), which is an extra stat call.
-				// For WalkDir, DirEntry usually has Info cached on Linux/Windows?
-				// Actually DirEntry.Info() might cause a stat.
-				// For high perf fuzzy search, we might not need size immediately.
-				// Let's optimize speed for now and skip Size unless it's cheap.
-			})
-
-			return nil
+			// Size needs a stat per file; listings skip it for speed
+			return fn(FileEntry{Path: filepath.ToSlash(relToProject), IsDir: e.IsDir})
 		})
-		return entries, err
 	}
 
 	// Non-recursive (readdir)
+	if dirs != nil {
+		info, err := os.Stat(rootPath)
+		if err != nil {
+			return err
+		}
+		dirs[rootPath] = info.ModTime()
+	}
 	dirEntries, err := os.ReadDir(rootPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	for _, d := range dirEntries {
 		relToProject, _ := filepath.Rel(w.BaseDir, filepath.Join(rootPath, d.Name()))
 		relToProject = filepath.ToSlash(relToProject)
 
-		entries = append(entries, FileEntry{
+		if err := fn(FileEntry{
 			Path:  relToProject,
 			IsDir: d.IsDir(),
-		})
+		}); err != nil {
+			return err
+		}
 	}
 
-	return entries, nil
+	return nil
 }
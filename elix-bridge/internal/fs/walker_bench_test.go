@@ -0,0 +1,82 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchTree builds a tree of fanout^depth directories with files in each,
+// shared by the benchmarks below
+func benchTree(b *testing.B, depth, fanout, files int) string {
+	b.Helper()
+	root := b.TempDir()
+	var build func(dir string, level int)
+	build = func(dir string, level int) {
+		for i := 0; i < files; i++ {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%02d.go", i)), nil, 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if level == depth {
+			return
+		}
+		for i := 0; i < fanout; i++ {
+			sub := filepath.Join(dir, fmt.Sprintf("dir%02d", i))
+			if err := os.Mkdir(sub, 0755); err != nil {
+				b.Fatal(err)
+			}
+			build(sub, level+1)
+		}
+	}
+	build(root, 0)
+	return root
+}
+
+// BenchmarkWalkDir is the single-threaded baseline: stat every entry
+// the way the index builders did with filepath.WalkDir
+func BenchmarkWalkDir(b *testing.B) {
+	root := benchTree(b, 3, 8, 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if _, err := d.Info(); err == nil {
+				n++
+			}
+			return nil
+		})
+	}
+}
+
+func BenchmarkWalkParallel(b *testing.B) {
+	root := benchTree(b, 3, 8, 20)
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			opts := WalkOptions{Workers: workers, StatDirs: true, StatFiles: true}
+			for i := 0; i < b.N; i++ {
+				n := 0
+				WalkParallel(context.Background(), root, opts, func(Entry) error {
+					n++
+					return nil
+				})
+			}
+		})
+	}
+}
+
+func BenchmarkListFiles(b *testing.B) {
+	root := benchTree(b, 3, 8, 20)
+	w := NewWalker(root)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := w.ListFiles(".", true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Package fslock provides advisory file locks for EchoHelix Bridge.
+//
+// Locks do not stop anything from writing a file; the mobile editor and
+// the tool pipeline consult them to warn that a file is being edited
+// elsewhere instead of overwriting each other's changes. Every lock
+// expires unless its holder keeps renewing it.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package fslock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Mode is what the holder is doing with the file
+type Mode string
+
+const (
+	// ModeRead holders are viewing the file; they share it with other
+	// readers but warn writers
+	ModeRead Mode = "read"
+	// ModeWrite holders are editing the file; they hold it alone
+	ModeWrite Mode = "write"
+)
+
+// Kind tells the app who holds a lock
+type Kind string
+
+const (
+	KindDevice Kind = "device"
+	KindAI     Kind = "ai"
+)
+
+const (
+	// DefaultTTL is how long a lock lives without a heartbeat
+	DefaultTTL = 60 * time.Second
+	// MaxTTL bounds what holders may ask for
+	MaxTTL = 10 * time.Minute
+)
+
+// Lock is an advisory lock on one file
+type Lock struct {
+	ID         string    `json:"id"`
+	Path       string    `json:"path"`
+	Mode       Mode      `json:"mode"`
+	Owner      string    `json:"owner"` // 设备 ID 或 kernel:<name>
+	Kind       Kind      `json:"kind"`
+	SessionID  string    `json:"session_id,omitempty"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	ttl        time.Duration
+}
+
+// LockError is a lock failure with a stable code
+type LockError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Lock is the lock in the way, for FILE_LOCKED
+	Lock *Lock `json:"lock,omitempty"`
+}
+
+func (e *LockError) Error() string {
+	if e.Lock != nil {
+		return e.Message + ": " + e.Lock.Path
+	}
+	return e.Message
+}
+
+// ErrNotFound is returned for a lock that expired, was released or is
+// held by someone else
+var ErrNotFound = &LockError{Code: "LOCK_NOT_FOUND", Message: "Lock not found or expired"}
+
+// CodeLocked is the code of the error returned for a file locked by
+// another holder
+const CodeLocked = "FILE_LOCKED"
+
+func locked(l *Lock) error {
+	held := *l
+	return &LockError{Code: CodeLocked, Message: "File is locked by " + describe(l), Lock: &held}
+}
+
+func describe(l *Lock) string {
+	if l.Kind == KindAI {
+		return "the AI"
+	}
+	return "another device"
+}
+
+// Request asks for a lock
+type Request struct {
+	Path      string
+	Mode      Mode
+	Owner     string
+	Kind      Kind
+	SessionID string
+	// TTL is clamped to MaxTTL; zero means DefaultTTL
+	TTL time.Duration
+}
+
+// Service holds the locks. They live in memory only: a restarted bridge
+// has no edits in flight.
+type Service struct {
+	mu       sync.Mutex
+	locks    map[string]*Lock
+	onChange func(l Lock, held bool)
+}
+
+// NewService creates an empty lock service
+func NewService() *Service {
+	return &Service{locks: make(map[string]*Lock)}
+}
+
+// OnChange sets callback for locks acquired or changing mode (held true),
+// and released or expired (held false); renewals are not reported. It runs outside the service's mutex.
+func (s *Service) OnChange(callback func(l Lock, held bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = callback
+}
+
+// Acquire takes a lock on req.Path. The holder's own lock on the path is
+// renewed and changed to req.Mode. A write lock conflicts with any lock
+// of another holder, a read lock with another holder's write lock; the
+// error then carries the lock in the way.
+func (s *Service) Acquire(req Request) (Lock, error) {
+	if req.Mode != ModeRead && req.Mode != ModeWrite {
+		return Lock{}, &LockError{Code: "INVALID_PARAMETER", Message: fmt.Sprintf("mode must be %q or %q", ModeRead, ModeWrite)}
+	}
+	ttl := req.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if ttl > MaxTTL {
+		ttl = MaxTTL
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	expired := s.expireLocked(now)
+
+	var own *Lock
+	for _, l := range s.locks {
+		if l.Path != req.Path {
+			continue
+		}
+		if l.Owner == req.Owner {
+			own = l
+			continue
+		}
+		if req.Mode == ModeWrite || l.Mode == ModeWrite {
+			s.mu.Unlock()
+			s.notify(expired, false)
+			return Lock{}, locked(l)
+		}
+	}
+
+	changed := own == nil || own.Mode != req.Mode
+	if own == nil {
+		own = &Lock{ID: newID(), Path: req.Path, Owner: req.Owner, AcquiredAt: now}
+		s.locks[own.ID] = own
+	}
+	own.Mode, own.Kind, own.SessionID, own.ttl = req.Mode, req.Kind, req.SessionID, ttl
+	own.ExpiresAt = now.Add(ttl)
+	l := *own
+	s.mu.Unlock()
+
+	s.notify(expired, false)
+	if changed {
+		s.notify([]Lock{l}, true)
+	}
+	return l, nil
+}
+
+// Heartbeat renews the owner's lock id for its TTL
+func (s *Service) Heartbeat(id, owner string) (Lock, error) {
+	now := time.Now()
+	s.mu.Lock()
+	expired := s.expireLocked(now)
+	var renewed *Lock
+	if l, ok := s.locks[id]; ok && l.Owner == owner {
+		l.ExpiresAt = now.Add(l.ttl)
+		held := *l
+		renewed = &held
+	}
+	s.mu.Unlock()
+
+	s.notify(expired, false)
+	if renewed == nil {
+		return Lock{}, ErrNotFound
+	}
+	return *renewed, nil
+}
+
+// Release drops the owner's lock id
+func (s *Service) Release(id, owner string) error {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok || l.Owner != owner {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	delete(s.locks, id)
+	released := *l
+	s.mu.Unlock()
+
+	s.notify([]Lock{released}, false)
+	return nil
+}
+
+// ReleasePath drops the owner's lock on path, if it holds one
+func (s *Service) ReleasePath(path, owner string) {
+	s.mu.Lock()
+	var released []Lock
+	for id, l := range s.locks {
+		if l.Path == path && l.Owner == owner {
+			delete(s.locks, id)
+			released = append(released, *l)
+		}
+	}
+	s.mu.Unlock()
+	s.notify(released, false)
+}
+
+// List returns the live locks, on path only when it is not empty, oldest
+// first
+func (s *Service) List(path string) []Lock {
+	s.mu.Lock()
+	expired := s.expireLocked(time.Now())
+	out := make([]Lock, 0, len(s.locks))
+	for _, l := range s.locks {
+		if path == "" || l.Path == path {
+			out = append(out, *l)
+		}
+	}
+	s.mu.Unlock()
+
+	s.notify(expired, false)
+	sort.Slice(out, func(i, j int) bool { return out[i].AcquiredAt.Before(out[j].AcquiredAt) })
+	return out
+}
+
+// Cleanup drops expired locks, so holders that went away without
+// releasing are announced even when nobody asks about their files
+func (s *Service) Cleanup() {
+	s.mu.Lock()
+	expired := s.expireLocked(time.Now())
+	s.mu.Unlock()
+	s.notify(expired, false)
+}
+
+func (s *Service) expireLocked(now time.Time) []Lock {
+	var expired []Lock
+	for id, l := range s.locks {
+		if !now.Before(l.ExpiresAt) {
+			delete(s.locks, id)
+			expired = append(expired, *l)
+		}
+	}
+	return expired
+}
+
+func (s *Service) notify(locks []Lock, held bool) {
+	if len(locks) == 0 {
+		return
+	}
+	s.mu.Lock()
+	callback := s.onChange
+	s.mu.Unlock()
+	if callback == nil {
+		return
+	}
+	for _, l := range locks {
+		callback(l, held)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
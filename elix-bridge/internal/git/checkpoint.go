@@ -0,0 +1,213 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// checkpointRefPrefix namespaces checkpoint refs away from branches and tags
+const checkpointRefPrefix = "refs/echohelix/checkpoints/"
+
+// Checkpoint is a snapshot of the work tree stored as a hidden git ref
+type Checkpoint struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label"`
+	Commit    string    `json:"commit"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateCheckpoint snapshots the full work tree (including untracked files)
+// without touching the index, HEAD, or any branch.
+func (r *Repo) CreateCheckpoint(label string) (*Checkpoint, error) {
+	tree, err := r.snapshotTree()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if label == "" {
+		label = "checkpoint"
+	}
+
+	args := []string{"commit-tree", tree, "-m", label}
+	if head, err := r.run("rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		args = append(args, "-p", strings.TrimSpace(head))
+	}
+	env := []string{
+		"GIT_AUTHOR_NAME=EchoHelix", "GIT_AUTHOR_EMAIL=bridge@echohelix.local",
+		"GIT_COMMITTER_NAME=EchoHelix", "GIT_COMMITTER_EMAIL=bridge@echohelix.local",
+	}
+	out, err := r.runEnv(env, args...)
+	if err != nil {
+		return nil, err
+	}
+	commit := strings.TrimSpace(out)
+
+	id := strconv.FormatInt(now.UnixNano(), 36)
+	if _, err := r.run("update-ref", checkpointRefPrefix+id, commit); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("id", id).Str("label", label).Str("dir", r.Dir).Msg("Checkpoint created")
+	return &Checkpoint{ID: id, Label: label, Commit: commit, CreatedAt: now}, nil
+}
+
+// ListCheckpoints returns checkpoints newest first
+func (r *Repo) ListCheckpoints() ([]Checkpoint, error) {
+	out, err := r.run("for-each-ref", "--sort=-committerdate",
+		"--format=%(refname)%1f%(objectname)%1f%(committerdate:unix)%1f%(contents:subject)",
+		checkpointRefPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints := make([]Checkpoint, 0)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		ts, _ := strconv.ParseInt(fields[2], 10, 64)
+		checkpoints = append(checkpoints, Checkpoint{
+			ID:        strings.TrimPrefix(fields[0], checkpointRefPrefix),
+			Commit:    fields[1],
+			CreatedAt: time.Unix(ts, 0),
+			Label:     fields[3],
+		})
+	}
+	return checkpoints, nil
+}
+
+// Rollback restores the work tree to the checkpoint. Files created after the
+// checkpoint are removed. The current state is checkpointed first so the
+// rollback itself can be undone.
+func (r *Repo) Rollback(id string) (*Checkpoint, error) {
+	target, err := r.run("rev-parse", "--verify", "-q", checkpointRefPrefix+id+"^{tree}")
+	if err != nil {
+		return nil, ErrCheckpointNotFound
+	}
+	target = strings.TrimSpace(target)
+
+	backup, err := r.CreateCheckpoint("before rollback to " + id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 删除检查点之后新增的文件
+	added, err := r.run("diff-tree", "-r", "--name-only", "--diff-filter=A", "-z", target, backup.Commit+"^{tree}")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range strings.Split(added, "\x00") {
+		if p == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(r.Dir, filepath.FromSlash(p))); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", p, err)
+		}
+	}
+
+	// 使用临时 index 检出，避免影响用户暂存区
+	env, cleanup, err := r.tempIndexEnv()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if _, err := r.runEnv(env, "read-tree", target); err != nil {
+		return nil, err
+	}
+	if _, err := r.runEnv(env, "checkout-index", "-a", "-f"); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("id", id).Str("backup", backup.ID).Msg("Rolled back to checkpoint")
+	return backup, nil
+}
+
+// DeleteCheckpoint removes a checkpoint ref
+func (r *Repo) DeleteCheckpoint(id string) error {
+	if _, err := r.run("update-ref", "-d", checkpointRefPrefix+id); err != nil {
+		return ErrCheckpointNotFound
+	}
+	return nil
+}
+
+// snapshotTree writes the current work tree to a tree object via a temporary index
+func (r *Repo) snapshotTree() (string, error) {
+	env, cleanup, err := r.tempIndexEnv()
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	if _, err := r.runEnv(env, "add", "--all"); err != nil {
+		return "", err
+	}
+	out, err := r.runEnv(env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (r *Repo) tempIndexEnv() ([]string, func(), error) {
+	f, err := os.CreateTemp("", "echohelix-index-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	name := f.Name()
+	f.Close()
+	// git 需要索引文件不存在或为有效格式
+	os.Remove(name)
+
+	return []string{"GIT_INDEX_FILE=" + name}, func() { os.Remove(name) }, nil
+}
+
+// Checkpointer creates at most one checkpoint per directory within a batch
+// window, so a burst of AI file writes is covered by a single snapshot.
+type Checkpointer struct {
+	mu     sync.Mutex
+	window time.Duration
+	last   map[string]time.Time
+}
+
+// NewCheckpointer creates a checkpointer with the given batch window
+func NewCheckpointer(window time.Duration) *Checkpointer {
+	if window <= 0 {
+		window = 30 * time.Second
+	}
+	return &Checkpointer{
+		window: window,
+		last:   make(map[string]time.Time),
+	}
+}
+
+// BeforeWrite checkpoints dir unless one was taken within the batch window.
+// Directories that are not git repositories are ignored.
+func (c *Checkpointer) BeforeWrite(dir, label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 窗口从上次实际创建的检查点算起，持续写入也会定期生成新检查点
+	if t, ok := c.last[dir]; ok && time.Since(t) < c.window {
+		return
+	}
+
+	repo, err := Open(dir)
+	if err != nil {
+		return
+	}
+	if _, err := repo.CreateCheckpoint(label); err != nil {
+		log.Warn().Err(err).Str("dir", dir).Msg("Failed to create checkpoint")
+		return
+	}
+	c.last[dir] = time.Now()
+}
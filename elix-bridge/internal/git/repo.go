@@ -0,0 +1,388 @@
+// Package git provides a git CLI wrapper for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileStatus represents the status of a single path
+type FileStatus struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"` // 重命名前的路径
+	Staged   string `json:"staged"`              // index status code, e.g. "M", "A", " "
+	Worktree string `json:"worktree"`            // worktree status code
+}
+
+// Status represents the repository status
+type Status struct {
+	Branch   string       `json:"branch"`
+	Upstream string       `json:"upstream,omitempty"`
+	Ahead    int          `json:"ahead"`
+	Behind   int          `json:"behind"`
+	Files    []FileStatus `json:"files"`
+	Clean    bool         `json:"clean"`
+}
+
+// Commit represents a single log entry
+type Commit struct {
+	Hash        string    `json:"hash"`
+	AuthorName  string    `json:"author_name"`
+	AuthorEmail string    `json:"author_email"`
+	Date        time.Time `json:"date"`
+	Subject     string    `json:"subject"`
+}
+
+// Repo wraps git operations for a working directory
+type Repo struct {
+	Dir     string
+	Timeout time.Duration
+}
+
+// Open returns a Repo for dir, failing if dir is not inside a git work tree
+func Open(dir string) (*Repo, error) {
+	r := &Repo{Dir: dir, Timeout: 30 * time.Second}
+	out, err := r.run("rev-parse", "--is-inside-work-tree")
+	if err != nil || strings.TrimSpace(out) != "true" {
+		return nil, ErrNotRepository
+	}
+	return r, nil
+}
+
+// Status returns the branch and file status of the work tree
+func (r *Repo) Status() (*Status, error) {
+	out, err := r.run("status", "--porcelain=v1", "--branch", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Status{Files: make([]FileStatus, 0)}
+	records := strings.Split(out, "\x00")
+	for i := 0; i < len(records); i++ {
+		rec := records[i]
+		if rec == "" {
+			continue
+		}
+		if strings.HasPrefix(rec, "## ") {
+			parseBranchLine(st, rec[3:])
+			continue
+		}
+		if len(rec) < 4 {
+			continue
+		}
+
+		fs := FileStatus{
+			Staged:   rec[0:1],
+			Worktree: rec[1:2],
+			Path:     rec[3:],
+		}
+		// 重命名/复制记录后跟随原路径
+		if fs.Staged == "R" || fs.Staged == "C" {
+			if i+1 < len(records) {
+				fs.OrigPath = records[i+1]
+				i++
+			}
+		}
+		st.Files = append(st.Files, fs)
+	}
+
+	st.Clean = len(st.Files) == 0
+	return st, nil
+}
+
+// Diff returns the unified diff of the work tree (or index when staged)
+func (r *Repo) Diff(path string, staged bool) (string, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	if path != "" {
+		args = append(args, "--", path)
+	}
+	return r.run(args...)
+}
+
+// Log returns the most recent commits
+func (r *Repo) Log(limit int) ([]Commit, error) {
+	return r.LogRange("", "", limit)
+}
+
+// LogRange returns the commits reachable from to but not from, newest
+// first. Empty from and to mean the whole history of HEAD.
+func (r *Repo) LogRange(from, to string, limit int) ([]Commit, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	args := []string{"log", "-n", strconv.Itoa(limit), "--pretty=format:%H%x1f%an%x1f%ae%x1f%at%x1f%s%x1e"}
+	switch {
+	case from != "":
+		if to == "" {
+			to = "HEAD"
+		}
+		args = append(args, from+".."+to)
+	case to != "":
+		args = append(args, to)
+	}
+	out, err := r.run(args...)
+	if err != nil {
+		// 空仓库没有任何提交
+		if strings.Contains(err.Error(), "does not have any commits") {
+			return []Commit{}, nil
+		}
+		return nil, err
+	}
+
+	commits := make([]Commit, 0, limit)
+	for _, rec := range strings.Split(out, "\x1e") {
+		rec = strings.TrimSpace(rec)
+		if rec == "" {
+			continue
+		}
+		fields := strings.Split(rec, "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		ts, _ := strconv.ParseInt(fields[3], 10, 64)
+		commits = append(commits, Commit{
+			Hash:        fields[0],
+			AuthorName:  fields[1],
+			AuthorEmail: fields[2],
+			Date:        time.Unix(ts, 0),
+			Subject:     fields[4],
+		})
+	}
+	return commits, nil
+}
+
+// ResolveCommit returns the full hash of the commit rev names
+func (r *Repo) ResolveCommit(rev string) (string, error) {
+	out, err := r.run("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil || strings.TrimSpace(out) == "" {
+		return "", ErrUnknownRevision
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// CurrentBranch returns the checked out branch, or "" when HEAD is detached
+func (r *Repo) CurrentBranch() string {
+	out, _ := r.run("symbolic-ref", "--short", "-q", "HEAD")
+	return strings.TrimSpace(out)
+}
+
+// Describe names HEAD after the nearest tag, falling back to the
+// abbreviated hash
+func (r *Repo) Describe() string {
+	out, _ := r.run("describe", "--tags", "--always")
+	return strings.TrimSpace(out)
+}
+
+// Stage adds paths to the index (all changes when paths is empty)
+func (r *Repo) Stage(paths ...string) error {
+	if len(paths) == 0 {
+		_, err := r.run("add", "--all")
+		return err
+	}
+	_, err := r.run(append([]string{"add", "--"}, paths...)...)
+	return err
+}
+
+// Unstage removes paths from the index (everything when paths is empty)
+func (r *Repo) Unstage(paths ...string) error {
+	args := []string{"reset", "-q", "HEAD"}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	_, err := r.run(args...)
+	return err
+}
+
+// Commit records the index as a new commit and returns it.
+// Empty authorName/authorEmail fall back to the repository's git config.
+func (r *Repo) Commit(message, authorName, authorEmail string) (*Commit, error) {
+	if strings.TrimSpace(message) == "" {
+		return nil, ErrEmptyMessage
+	}
+
+	var env []string
+	if authorName != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+authorName, "GIT_COMMITTER_NAME="+authorName)
+	}
+	if authorEmail != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+authorEmail, "GIT_COMMITTER_EMAIL="+authorEmail)
+	}
+
+	if _, err := r.runEnv(env, "commit", "-q", "-m", message); err != nil {
+		return nil, err
+	}
+
+	commits, err := r.Log(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("git commit: new commit not found in log")
+	}
+	return &commits[0], nil
+}
+
+// Discard reverts work tree changes under path: tracked files go back to
+// their staged content and untracked files are removed. Staged changes
+// are kept.
+func (r *Repo) Discard(path string) error {
+	if path == "" {
+		return ErrPathRequired
+	}
+
+	out, err := r.run("status", "--porcelain=v1", "-z", "--", path)
+	if err != nil {
+		return err
+	}
+
+	// path 可能是包含多种改动的目录，逐条分类处理
+	var untracked, modified []string
+	records := strings.Split(out, "\x00")
+	for i := 0; i < len(records); i++ {
+		rec := records[i]
+		if len(rec) < 4 {
+			continue
+		}
+		staged, worktree, file := rec[0], rec[1], rec[3:]
+		if staged == 'R' || staged == 'C' {
+			i++ // 跳过原路径
+		}
+		// status 输出的路径相对于仓库根目录
+		spec := ":(top,literal)" + file
+		switch {
+		case staged == '?':
+			// 未跟踪文件无法 checkout，只能清理
+			untracked = append(untracked, spec)
+		case worktree != ' ':
+			modified = append(modified, spec)
+		}
+	}
+
+	if len(modified) > 0 {
+		if _, err := r.run(append([]string{"checkout", "--"}, modified...)...); err != nil {
+			return err
+		}
+	}
+	if len(untracked) > 0 {
+		if _, err := r.run(append([]string{"clean", "-f", "-d", "--"}, untracked...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clone clones url into dest. It honors ctx cancellation.
+func Clone(ctx context.Context, url, dest string) error {
+	cmd := exec.CommandContext(ctx, "git", "clone", "--quiet", "--", url, dest)
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("git clone: %s", msg)
+	}
+	return nil
+}
+
+// run executes git with args in the repo directory
+func (r *Repo) run(args ...string) (string, error) {
+	return r.runEnv(nil, args...)
+}
+
+// runEnv executes git with extra environment variables
+func (r *Repo) runEnv(env []string, args ...string) (string, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	if env != nil {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return stdout.String(), fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// parseBranchLine parses "main...origin/main [ahead 1, behind 2]"
+func parseBranchLine(st *Status, line string) {
+	info := ""
+	if idx := strings.Index(line, " ["); idx != -1 {
+		info = strings.Trim(line[idx+2:], "]")
+		line = line[:idx]
+	}
+
+	if strings.HasPrefix(line, "No commits yet on ") {
+		line = strings.TrimPrefix(line, "No commits yet on ")
+	}
+
+	if idx := strings.Index(line, "..."); idx != -1 {
+		st.Branch = line[:idx]
+		st.Upstream = line[idx+3:]
+	} else {
+		st.Branch = line
+	}
+
+	for _, part := range strings.Split(info, ", ") {
+		if n, ok := strings.CutPrefix(part, "ahead "); ok {
+			st.Ahead, _ = strconv.Atoi(n)
+		}
+		if n, ok := strings.CutPrefix(part, "behind "); ok {
+			st.Behind, _ = strconv.Atoi(n)
+		}
+	}
+}
+
+// Errors
+var (
+	ErrNotRepository = &GitError{Code: "NOT_A_REPOSITORY", Message: "Not a git repository"}
+	ErrEmptyMessage  = &GitError{Code: "EMPTY_MESSAGE", Message: "Commit message is required"}
+	ErrPathRequired  = &GitError{Code: "PATH_REQUIRED", Message: "Path is required"}
+
+	ErrUnknownRevision = &GitError{Code: "UNKNOWN_REVISION", Message: "Revision not found"}
+
+	ErrCheckpointNotFound = &GitError{Code: "CHECKPOINT_NOT_FOUND", Message: "Checkpoint not found"}
+)
+
+// GitError represents a git-related error
+type GitError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *GitError) Error() string {
+	return e.Message
+}
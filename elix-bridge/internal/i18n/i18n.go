@@ -0,0 +1,241 @@
+// Package i18n provides translated user-facing strings for EchoHelix Bridge.
+//
+// Catalogs are flat JSON objects of message keys to text, one file per
+// language named by its tag (en.json, zh.json). English and Chinese are
+// compiled in; more languages, or overrides of single messages, are
+// loaded from a directory at startup. A missing translation falls back
+// to English.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Fallback is the language of untranslated messages
+const Fallback = "en"
+
+//go:embed locales/*.json
+var bundled embed.FS
+
+// Default holds the bundled catalogs and any loaded with LoadDir
+var Default = New()
+
+// Bundle is a set of catalogs by language tag
+type Bundle struct {
+	mu       sync.RWMutex
+	catalogs map[string]map[string]string
+	fallback string // 没有 Accept-Language 时使用的语言
+}
+
+// New returns a bundle of the compiled-in catalogs
+func New() *Bundle {
+	b := &Bundle{catalogs: make(map[string]map[string]string), fallback: Fallback}
+	entries, _ := fs.ReadDir(bundled, "locales")
+	for _, e := range entries {
+		data, _ := fs.ReadFile(bundled, "locales/"+e.Name())
+		if err := b.add(e.Name(), data); err != nil {
+			// 内嵌目录在编译时已确定，解析失败说明目录本身有误
+			panic(err)
+		}
+	}
+	return b
+}
+
+// LoadDir merges the *.json catalogs in dir over the bundled ones. A
+// missing dir is not an error.
+func (b *Bundle) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := b.add(filepath.Base(file), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bundle) add(name string, data []byte) error {
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return &CatalogError{File: name, Err: err}
+	}
+	lang := normalize(strings.TrimSuffix(name, filepath.Ext(name)))
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	catalog := b.catalogs[lang]
+	if catalog == nil {
+		catalog = make(map[string]string)
+		b.catalogs[lang] = catalog
+	}
+	for key, text := range messages {
+		catalog[key] = text
+	}
+	return nil
+}
+
+// SetDefault sets the language used when a request names none that is
+// available; unknown languages are ignored
+func (b *Bundle) SetDefault(lang string) {
+	lang = normalize(lang)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.catalogs[lang]; ok {
+		b.fallback = lang
+	}
+}
+
+// Languages lists the available language tags
+func (b *Bundle) Languages() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	langs := make([]string, 0, len(b.catalogs))
+	for lang := range b.catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Negotiate picks the available language that best matches an
+// Accept-Language header, trying each tag and then its primary
+// language, e.g. zh-CN then zh
+func (b *Bundle) Negotiate(accept string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(accept, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if tag != "" && tag != "*" && q > 0 {
+			tags = append(tags, weighted{normalize(tag), q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, t := range tags {
+		if _, ok := b.catalogs[t.tag]; ok {
+			return t.tag
+		}
+		if primary, _, found := strings.Cut(t.tag, "-"); found {
+			if _, ok := b.catalogs[primary]; ok {
+				return primary
+			}
+		}
+	}
+	return b.fallback
+}
+
+// Lookup returns the text of key in lang, falling back to English
+func (b *Bundle) Lookup(lang, key string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if text, ok := b.catalogs[normalize(lang)][key]; ok {
+		return text, true
+	}
+	text, ok := b.catalogs[Fallback][key]
+	return text, ok
+}
+
+// T returns the text of key in lang, or the key itself when no catalog
+// has it. Arguments replace {0}, {1}, ... in order.
+func (b *Bundle) T(lang, key string, args ...interface{}) string {
+	text, ok := b.Lookup(lang, key)
+	if !ok {
+		text = key
+	}
+	return format(text, args)
+}
+
+// Localize translates message, the English text of key, into lang.
+// Messages that differ from the catalog's English text carry specifics
+// no catalog knows, such as a file name, and are returned unchanged.
+func (b *Bundle) Localize(lang, key, message string) string {
+	b.mu.RLock()
+	english, ok := b.catalogs[Fallback][key]
+	b.mu.RUnlock()
+	if !ok || english != message {
+		return message
+	}
+	text, _ := b.Lookup(lang, key)
+	return text
+}
+
+// Messages returns the texts in lang of every key with prefix, with
+// English for untranslated ones, e.g. for a page's scripts
+func (b *Bundle) Messages(lang, prefix string) map[string]string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make(map[string]string)
+	for _, l := range []string{Fallback, normalize(lang)} {
+		for key, text := range b.catalogs[l] {
+			if strings.HasPrefix(key, prefix) {
+				out[key] = text
+			}
+		}
+	}
+	return out
+}
+
+// CatalogError reports a catalog file that is not a JSON object of
+// strings
+type CatalogError struct {
+	File string
+	Err  error
+}
+
+func (e *CatalogError) Error() string { return "catalog " + e.File + ": " + e.Err.Error() }
+
+func (e *CatalogError) Unwrap() error { return e.Err }
+
+// normalize lowercases a language tag and uses "-" between subtags
+func normalize(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+func format(text string, args []interface{}) string {
+	for i, arg := range args {
+		text = strings.ReplaceAll(text, "{"+strconv.Itoa(i)+"}", toString(arg))
+	}
+	return text
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case error:
+		return v.Error()
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
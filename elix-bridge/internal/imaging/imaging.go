@@ -0,0 +1,317 @@
+// Package imaging provides image attachments for prompts for EchoHelix Bridge.
+//
+// Uploaded images are stored as sent, one directory per session. Before
+// a prompt goes to a kernel each image is fitted to that kernel's limits:
+// downscaled to its largest accepted dimension and re-encoded until it is
+// small enough. Fitted copies are kept next to the original so a later
+// prompt to the same kernel reuses them.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package imaging
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MaxUpload is the largest image accepted for storage
+const MaxUpload = 20 << 20
+
+// MaxPixels bounds width x height. A small compressed file can declare
+// huge dimensions, and decoding allocates for every pixel.
+const MaxPixels = 50_000_000
+
+// Default limits for kernels that do not state their own
+const (
+	DefaultMaxDimension = 2048
+	DefaultMaxBytes     = 5 << 20
+)
+
+// Limits are the largest image a kernel accepts
+type Limits struct {
+	// MaxDimension bounds the longer side in pixels
+	MaxDimension int
+	// MaxBytes bounds the encoded size
+	MaxBytes int
+}
+
+// Image is a stored upload
+type Image struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	Format    string    `json:"format"` // png, jpeg, gif
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+	// Path is relative to the workspace, where kernels read it from
+	Path string `json:"path"`
+}
+
+var (
+	// ErrNotFound is returned for unknown image IDs
+	ErrNotFound = errors.New("image not found")
+	// ErrUnsupported is returned for data that is not a PNG, JPEG or GIF
+	ErrUnsupported = errors.New("unsupported image format; send PNG, JPEG or GIF")
+	// ErrTooLarge is returned when an image cannot be made to fit a
+	// kernel's limits
+	ErrTooLarge = errors.New("image cannot be compressed to the kernel's size limit")
+	// ErrTooManyPixels is returned for images whose dimensions exceed
+	// MaxPixels
+	ErrTooManyPixels = fmt.Errorf("image has more than %d megapixels", MaxPixels/1_000_000)
+)
+
+// extensions maps decoder format names to the extensions originals are
+// stored with
+var extensions = map[string]string{"png": ".png", "jpeg": ".jpg", "gif": ".gif"}
+
+var idPattern = regexp.MustCompile(`^img_[0-9a-f]{16}$`)
+
+// Dir is where a session's images are stored within its workspace
+func Dir(workspace, sessionID string) string {
+	return filepath.Join(workspace, ".echohelix", "images", sessionID)
+}
+
+// Save stores an uploaded image for a session. The data must decode as
+// PNG, JPEG or GIF; it is kept unchanged.
+func Save(workspace, sessionID string, r io.Reader) (Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUpload+1))
+	if err != nil {
+		return Image{}, err
+	}
+	if len(data) > MaxUpload {
+		return Image{}, fmt.Errorf("image is larger than %d MB", MaxUpload>>20)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrUnsupported
+	}
+	ext, ok := extensions[format]
+	if !ok {
+		return Image{}, ErrUnsupported
+	}
+	if !withinPixels(cfg) {
+		return Image{}, ErrTooManyPixels
+	}
+
+	dir := Dir(workspace, sessionID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return Image{}, err
+	}
+	img := Image{
+		ID:        newID(),
+		SessionID: sessionID,
+		Format:    format,
+		Width:     cfg.Width,
+		Height:    cfg.Height,
+		Size:      int64(len(data)),
+		CreatedAt: time.Now(),
+	}
+	path := filepath.Join(dir, img.ID+ext)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return Image{}, err
+	}
+	img.Path, _ = filepath.Rel(workspace, path)
+	return img, nil
+}
+
+// Open returns the path and format of a stored original
+func Open(workspace, sessionID, id string) (string, string, error) {
+	if !idPattern.MatchString(id) {
+		return "", "", ErrNotFound
+	}
+	dir := Dir(workspace, sessionID)
+	for format, ext := range extensions {
+		path := filepath.Join(dir, id+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, format, nil
+		}
+	}
+	return "", "", ErrNotFound
+}
+
+// Prepare returns the workspace-relative path of an image fitted to
+// limits. variant names the limits, usually the kernel, and keys the
+// fitted copy; an original that already fits is used as is.
+func Prepare(workspace, sessionID, id, variant string, limits Limits) (string, error) {
+	if limits.MaxDimension <= 0 {
+		limits.MaxDimension = DefaultMaxDimension
+	}
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = DefaultMaxBytes
+	}
+	src, format, err := Open(workspace, sessionID, id)
+	if err != nil {
+		return "", err
+	}
+	rel := func(path string) string {
+		p, _ := filepath.Rel(workspace, path)
+		return p
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrUnsupported
+	}
+	// 解码前再检查一次，存储目录中的文件可能不是经 Save 写入的
+	if !withinPixels(cfg) {
+		return "", ErrTooManyPixels
+	}
+	// GIF 只取第一帧，统一转成静态图
+	if format != "gif" && len(data) <= limits.MaxBytes &&
+		cfg.Width <= limits.MaxDimension && cfg.Height <= limits.MaxDimension {
+		return rel(src), nil
+	}
+
+	dir := filepath.Dir(src)
+	for _, ext := range []string{".png", ".jpg"} {
+		path := filepath.Join(dir, id+"."+variant+ext)
+		if _, err := os.Stat(path); err == nil {
+			return rel(path), nil
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrUnsupported
+	}
+	out, ext, err := Fit(img, format, limits)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, id+"."+variant+ext)
+	if err := os.WriteFile(path, out, 0600); err != nil {
+		return "", err
+	}
+	return rel(path), nil
+}
+
+// Fit downscales img to limits and encodes it, returning the data and
+// its extension. PNG input stays PNG when that fits, which keeps text in
+// screenshots sharp; otherwise JPEG quality is lowered, then the image
+// shrunk further, until the result fits.
+func Fit(img image.Image, format string, limits Limits) ([]byte, string, error) {
+	b := img.Bounds()
+	w, h := scaledSize(b.Dx(), b.Dy(), limits.MaxDimension)
+	for attempt := 0; attempt < 6 && w > 0 && h > 0; attempt++ {
+		scaled := img
+		if w != b.Dx() || h != b.Dy() {
+			scaled = resize(img, w, h)
+		}
+		if format == "png" || format == "gif" {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, scaled); err != nil {
+				return nil, "", err
+			}
+			if buf.Len() <= limits.MaxBytes {
+				return buf.Bytes(), ".png", nil
+			}
+		}
+		flat := flatten(scaled)
+		for quality := 85; quality >= 40; quality -= 15 {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+				return nil, "", err
+			}
+			if buf.Len() <= limits.MaxBytes {
+				return buf.Bytes(), ".jpg", nil
+			}
+		}
+		w, h = w*3/4, h*3/4
+	}
+	return nil, "", ErrTooLarge
+}
+
+// withinPixels reports whether cfg stays under MaxPixels, checking each
+// side first so the product cannot overflow
+func withinPixels(cfg image.Config) bool {
+	return cfg.Width > 0 && cfg.Height > 0 &&
+		cfg.Width <= MaxPixels && cfg.Height <= MaxPixels &&
+		int64(cfg.Width)*int64(cfg.Height) <= MaxPixels
+}
+
+// scaledSize fits w x h within limit on the longer side, keeping the
+// aspect ratio
+func scaledSize(w, h, limit int) (int, int) {
+	if w <= limit && h <= limit {
+		return w, h
+	}
+	if w >= h {
+		return limit, max(1, h*limit/w)
+	}
+	return max(1, w*limit/h), limit
+}
+
+// resize scales img to w x h by averaging the source pixels each target
+// pixel covers. It is meant for shrinking.
+func resize(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*sh/h
+		y1 := max(y0+1, b.Min.Y+(y+1)*sh/h)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*sw/w
+			x1 := max(x0+1, b.Min.X+(x+1)*sw/w)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// flatten draws img over white, since JPEG has no transparency
+func flatten(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+// ContentType is the MIME type of a decoder format name
+func ContentType(format string) string {
+	if format == "" {
+		return "application/octet-stream"
+	}
+	return "image/" + strings.ToLower(format)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "img_" + hex.EncodeToString(b)
+}
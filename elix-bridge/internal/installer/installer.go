@@ -0,0 +1,263 @@
+// Package installer provides kernel setup (sources and dependencies) for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package installer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DefaultGeminiRepo is cloned into cores/gemini when no other source is
+// configured
+const DefaultGeminiRepo = "https://github.com/google-gemini/gemini-cli.git"
+
+// aiderPackages are installed when cores/aider has no requirements.txt
+var aiderPackages = []string{"aider-chat", "fastapi", "uvicorn[standard]", "websockets"}
+
+// ErrUnknownKernel is returned for kernels the installer cannot set up
+var ErrUnknownKernel = errors.New("unknown kernel; expected gemini or aider")
+
+// Kernels lists the kernels that can be installed
+var Kernels = []string{"gemini", "aider"}
+
+// Options configures an installation
+type Options struct {
+	// CoresDir is the directory holding one subdirectory per kernel
+	CoresDir string
+	// Repo is cloned into CoresDir/<kernel> when it is missing
+	Repo string
+	// CoresRepo is cloned into CoresDir when CoresDir is missing; it
+	// provides every kernel's sources at once
+	CoresRepo string
+	// Ref is the branch or tag to clone
+	Ref string
+	// Update pulls existing git checkouts before installing dependencies
+	Update bool
+}
+
+// Step is one command of an installation
+type Step struct {
+	Name    string   `json:"name"`
+	Dir     string   `json:"dir"`
+	Command []string `json:"command"`
+}
+
+// fetchSteps returns the commands that put the kernel's sources in
+// place; sources already on disk are reused
+func fetchSteps(kernel string, opts Options) ([]Step, error) {
+	dir := filepath.Join(opts.CoresDir, kernel)
+	switch {
+	case exists(dir):
+		if opts.Update && exists(filepath.Join(dir, ".git")) {
+			return []Step{{Name: "update sources", Dir: dir, Command: []string{"git", "pull", "--ff-only"}}}, nil
+		}
+		return nil, nil
+	case opts.Repo != "":
+		return []Step{cloneStep(opts.Repo, opts.Ref, filepath.Dir(dir), dir)}, nil
+	case opts.CoresRepo != "" && !exists(opts.CoresDir):
+		return []Step{cloneStep(opts.CoresRepo, opts.Ref, filepath.Dir(opts.CoresDir), opts.CoresDir)}, nil
+	case kernel == "gemini":
+		return []Step{cloneStep(DefaultGeminiRepo, opts.Ref, filepath.Dir(dir), dir)}, nil
+	}
+	return nil, fmt.Errorf("%s not found; set KERNEL_CORES_REPO or KERNEL_%s_REPO to a repository with the kernel sources",
+		dir, strings.ToUpper(kernel))
+}
+
+// setupSteps returns the commands that install the kernel's
+// dependencies. They always run so a half-finished install is completed.
+func setupSteps(kernel, dir string) ([]Step, error) {
+	switch kernel {
+	case "gemini":
+		npm := "npm"
+		if runtime.GOOS == "windows" {
+			npm = "npm.cmd"
+		}
+		return []Step{
+			{Name: "install dependencies", Dir: dir, Command: []string{npm, "install", "--no-audit", "--no-fund"}},
+			{Name: "build", Dir: dir, Command: []string{npm, "run", "build"}},
+		}, nil
+	case "aider":
+		python, err := systemPython()
+		if err != nil {
+			return nil, err
+		}
+		venvPython := filepath.Join(dir, ".venv", "bin", "python3")
+		if runtime.GOOS == "windows" {
+			venvPython = filepath.Join(dir, ".venv", "Scripts", "python.exe")
+		}
+		pip := []string{venvPython, "-m", "pip", "install"}
+		if exists(filepath.Join(dir, "requirements.txt")) {
+			pip = append(pip, "-r", "requirements.txt")
+		} else {
+			pip = append(pip, aiderPackages...)
+		}
+		return []Step{
+			{Name: "create virtualenv", Dir: dir, Command: []string{python, "-m", "venv", ".venv"}},
+			{Name: "upgrade pip", Dir: dir, Command: []string{venvPython, "-m", "pip", "install", "--upgrade", "pip"}},
+			{Name: "install dependencies", Dir: dir, Command: pip},
+		}, nil
+	}
+	return nil, ErrUnknownKernel
+}
+
+func cloneStep(repo, ref, parent, dest string) Step {
+	cmd := []string{"git", "clone", "--depth", "1"}
+	if ref != "" {
+		cmd = append(cmd, "--branch", ref)
+	}
+	return Step{Name: "clone sources", Dir: parent, Command: append(cmd, repo, dest)}
+}
+
+// systemPython finds the interpreter used to create the virtualenv
+func systemPython() (string, error) {
+	for _, name := range []string{"python3", "python"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("python was not found on PATH")
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// StepError is a failed step with the end of its output
+type StepError struct {
+	Step   Step
+	Err    error
+	Output []string
+}
+
+func (e *StepError) Error() string {
+	msg := fmt.Sprintf("%s failed: %v", e.Step.Name, e.Err)
+	if len(e.Output) > 0 {
+		msg += "\n" + strings.Join(e.Output, "\n")
+	}
+	return msg
+}
+
+// tailLines is the output kept for error messages
+const tailLines = 20
+
+// Plan returns the steps Install would run now. Dependency steps are
+// planned against the sources currently on disk and may differ once
+// they are cloned.
+func Plan(kernel string, opts Options) ([]Step, error) {
+	if kernel != "gemini" && kernel != "aider" {
+		return nil, ErrUnknownKernel
+	}
+	fetch, err := fetchSteps(kernel, opts)
+	if err != nil {
+		return nil, err
+	}
+	setup, err := setupSteps(kernel, filepath.Join(opts.CoresDir, kernel))
+	if err != nil {
+		return nil, err
+	}
+	return append(fetch, setup...), nil
+}
+
+// Install sets up kernel: it fetches the sources when missing, then
+// installs the dependencies. progress is called before each step with
+// its index and the step count, and output with every line printed.
+func Install(ctx context.Context, kernel string, opts Options, progress func(i, total int, step Step), output func(line string)) error {
+	if kernel != "gemini" && kernel != "aider" {
+		return ErrUnknownKernel
+	}
+	dir := filepath.Join(opts.CoresDir, kernel)
+
+	fetch, err := fetchSteps(kernel, opts)
+	if err != nil {
+		return err
+	}
+	// 依赖步骤要等源码就位后再生成（如 requirements.txt 是否存在）
+	planned, err := setupSteps(kernel, dir)
+	if err != nil {
+		return err
+	}
+	total := len(fetch) + len(planned)
+
+	for i, step := range fetch {
+		if err := run(ctx, i, total, step, progress, output); err != nil {
+			return err
+		}
+	}
+	if !exists(dir) {
+		return fmt.Errorf("%s was not created by the cloned repository", dir)
+	}
+	setup, err := setupSteps(kernel, dir)
+	if err != nil {
+		return err
+	}
+	for i, step := range setup {
+		if err := run(ctx, len(fetch)+i, total, step, progress, output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run(ctx context.Context, i, total int, step Step, progress func(i, total int, step Step), output func(line string)) error {
+	if progress != nil {
+		progress(i, total, step)
+	}
+	if err := os.MkdirAll(step.Dir, 0755); err != nil {
+		return &StepError{Step: step, Err: err}
+	}
+	return runStep(ctx, step, output)
+}
+
+func runStep(ctx context.Context, step Step, output func(line string)) error {
+	cmd := exec.CommandContext(ctx, step.Command[0], step.Command[1:]...)
+	cmd.Dir = step.Dir
+	// 避免 git 等工具等待终端输入
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "CI=1")
+
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	var tail []string
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if output != nil {
+				output(line)
+			}
+			tail = append(tail, line)
+			if len(tail) > tailLines {
+				tail = tail[1:]
+			}
+		}
+		io.Copy(io.Discard, pr)
+	}()
+
+	err := cmd.Run()
+	pw.Close()
+	wg.Wait()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return &StepError{Step: step, Err: err, Output: tail}
+	}
+	return nil
+}
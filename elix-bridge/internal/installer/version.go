@@ -0,0 +1,348 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	bridgegit "echohelix/bridge/internal/git"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxChangelog bounds the commits listed between two versions
+const maxChangelog = 200
+
+var (
+	// ErrNotInstalled is returned for kernels without sources on disk
+	ErrNotInstalled = errors.New("kernel is not installed")
+	// ErrNotCheckout is returned when the sources are not a git checkout
+	// and so cannot be upgraded or rolled back
+	ErrNotCheckout = errors.New("kernel sources are not a git checkout; reinstall from a repository to upgrade")
+	// ErrNoPrevious is returned by a rollback without an earlier version
+	ErrNoPrevious = errors.New("no previous version recorded")
+)
+
+// Version identifies installed kernel sources
+type Version struct {
+	Commit string `json:"commit,omitempty"`
+	// Describe is the nearest tag, or the short hash without tags
+	Describe string `json:"describe,omitempty"`
+	// Branch is empty when the checkout is pinned to a tag or commit
+	Branch string `json:"branch,omitempty"`
+	// Package is the version declared by package.json or pyproject.toml
+	Package     string     `json:"package,omitempty"`
+	InstalledAt *time.Time `json:"installed_at,omitempty"`
+}
+
+// Pinned reports whether the checkout is detached from any branch, so
+// an upgrade needs an explicit ref
+func (v Version) Pinned() bool {
+	return v.Commit != "" && v.Branch == ""
+}
+
+// String names the version for messages
+func (v Version) String() string {
+	switch {
+	case v.Describe != "":
+		return v.Describe
+	case v.Package != "":
+		return v.Package
+	case len(v.Commit) > 12:
+		return v.Commit[:12]
+	}
+	return v.Commit
+}
+
+var pyprojectVersion = regexp.MustCompile(`(?m)^version\s*=\s*["']([^"']+)["']`)
+
+// Detect reads the version of the kernel sources in dir
+func Detect(dir string) (Version, error) {
+	if !exists(dir) {
+		return Version{}, ErrNotInstalled
+	}
+	var v Version
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		var pkg struct {
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			v.Package = pkg.Version
+		}
+	} else if data, err := os.ReadFile(filepath.Join(dir, "pyproject.toml")); err == nil {
+		if m := pyprojectVersion.FindSubmatch(data); m != nil {
+			v.Package = string(m[1])
+		}
+	}
+
+	if !exists(filepath.Join(dir, ".git")) {
+		return v, nil
+	}
+	repo, err := bridgegit.Open(dir)
+	if err != nil {
+		return v, nil
+	}
+	if v.Commit, err = repo.ResolveCommit("HEAD"); err != nil {
+		return v, nil
+	}
+	v.Branch = repo.CurrentBranch()
+	v.Describe = repo.Describe()
+	return v, nil
+}
+
+// UpgradeResult describes a finished upgrade or rollback
+type UpgradeResult struct {
+	Kernel    string             `json:"kernel"`
+	From      Version            `json:"from"`
+	To        Version            `json:"to"`
+	UpToDate  bool               `json:"up_to_date,omitempty"`
+	Changelog []bridgegit.Commit `json:"changelog,omitempty"`
+}
+
+// Pending fetches the kernel's remote and returns the commits an upgrade
+// to ref would bring in; ref "" means the upstream of the current branch
+func Pending(ctx context.Context, kernel string, opts Options, ref string) (string, []bridgegit.Commit, error) {
+	dir := filepath.Join(opts.CoresDir, kernel)
+	repo, err := openCheckout(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := runStep(ctx, Step{Name: "fetch updates", Dir: dir, Command: []string{"git", "fetch", "--tags", "origin"}}, nil); err != nil {
+		return "", nil, err
+	}
+	target, err := resolveTarget(repo, ref)
+	if err != nil {
+		return "", nil, err
+	}
+	commits, err := repo.LogRange("HEAD", target, maxChangelog)
+	return target, commits, err
+}
+
+// Upgrade moves the kernel's sources to ref and reinstalls its
+// dependencies. Without ref the current branch is fast-forwarded to its
+// upstream; a pinned checkout needs a ref. If a step fails after the
+// sources moved, they are put back and the dependencies reinstalled so
+// the previous version keeps working.
+func Upgrade(ctx context.Context, kernel string, opts Options, ref string, progress func(i, total int, step Step), output func(line string)) (UpgradeResult, error) {
+	result := UpgradeResult{Kernel: kernel}
+	dir := filepath.Join(opts.CoresDir, kernel)
+	repo, err := openCheckout(dir)
+	if err != nil {
+		return result, err
+	}
+	if result.From, err = Detect(dir); err != nil {
+		return result, err
+	}
+	planned, err := setupSteps(kernel, dir)
+	if err != nil {
+		return result, err
+	}
+	total := 2 + len(planned)
+
+	fetch := Step{Name: "fetch updates", Dir: dir, Command: []string{"git", "fetch", "--tags", "origin"}}
+	if err := run(ctx, 0, total, fetch, progress, output); err != nil {
+		return result, err
+	}
+	target, err := resolveTarget(repo, ref)
+	if err != nil {
+		return result, err
+	}
+	if target == result.From.Commit {
+		// 指定 ref 时即使提交相同也要固定到该版本
+		if ref != "" && !result.From.Pinned() {
+			pin := Step{Name: "pin " + ref, Dir: dir, Command: []string{"git", "checkout", "--detach", target}}
+			if err := run(ctx, 1, 2, pin, progress, output); err != nil {
+				return result, err
+			}
+		}
+		result.To, err = Detect(dir)
+		result.UpToDate = true
+		return result, err
+	}
+	if result.Changelog, err = repo.LogRange(result.From.Commit, target, maxChangelog); err != nil {
+		log.Debug().Str("component", "installer").Err(err).Msg("Changelog unavailable")
+	}
+
+	checkout := Step{Name: "check out " + target[:12], Dir: dir, Command: []string{"git", "checkout", "--detach", target}}
+	if ref == "" {
+		checkout = Step{Name: "fast-forward", Dir: dir, Command: []string{"git", "merge", "--ff-only", "@{upstream}"}}
+	}
+	if err := run(ctx, 1, total, checkout, progress, output); err != nil {
+		return result, err
+	}
+
+	for i, step := range planned {
+		if err = run(ctx, 2+i, total, step, progress, output); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		// 升级失败时回到原版本，保证内核仍然可用
+		if output != nil {
+			output(fmt.Sprintf("upgrade failed, restoring %s", result.From))
+		}
+		if rbErr := restore(context.WithoutCancel(ctx), kernel, dir, result.From, progress, output); rbErr != nil {
+			return result, fmt.Errorf("%w; restoring %s also failed: %v", err, result.From, rbErr)
+		}
+		return result, fmt.Errorf("%w; restored %s", err, result.From)
+	}
+
+	result.To, err = Detect(dir)
+	return result, err
+}
+
+// Rollback checks out the sources of an earlier version and reinstalls
+// its dependencies
+func Rollback(ctx context.Context, kernel string, opts Options, to Version, progress func(i, total int, step Step), output func(line string)) (UpgradeResult, error) {
+	result := UpgradeResult{Kernel: kernel}
+	dir := filepath.Join(opts.CoresDir, kernel)
+	repo, err := openCheckout(dir)
+	if err != nil {
+		return result, err
+	}
+	if to.Commit == "" {
+		return result, ErrNoPrevious
+	}
+	if _, err := repo.ResolveCommit(to.Commit); err != nil {
+		return result, fmt.Errorf("previous version %s is no longer in the checkout", to)
+	}
+	if result.From, err = Detect(dir); err != nil {
+		return result, err
+	}
+	if err := restore(ctx, kernel, dir, to, progress, output); err != nil {
+		return result, err
+	}
+	result.To, err = Detect(dir)
+	return result, err
+}
+
+// restore checks out v, back on its branch when it had one, and
+// reinstalls the dependencies
+func restore(ctx context.Context, kernel, dir string, v Version, progress func(i, total int, step Step), output func(line string)) error {
+	checkout := Step{Name: "check out " + v.String(), Dir: dir, Command: []string{"git", "checkout", "--detach", v.Commit}}
+	if v.Branch != "" {
+		checkout.Command = []string{"git", "checkout", "-B", v.Branch, v.Commit}
+	}
+	setup, err := setupSteps(kernel, dir)
+	if err != nil {
+		return err
+	}
+	steps := append([]Step{checkout}, setup...)
+	for i, step := range steps {
+		if err := run(ctx, i, len(steps), step, progress, output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func openCheckout(dir string) (*bridgegit.Repo, error) {
+	if !exists(dir) {
+		return nil, ErrNotInstalled
+	}
+	if !exists(filepath.Join(dir, ".git")) {
+		return nil, ErrNotCheckout
+	}
+	repo, err := bridgegit.Open(dir)
+	if err != nil {
+		return nil, ErrNotCheckout
+	}
+	return repo, nil
+}
+
+// resolveTarget finds the commit to upgrade to. Branch names resolve to
+// the fetched remote branch rather than a stale local one.
+func resolveTarget(repo *bridgegit.Repo, ref string) (string, error) {
+	if ref == "" {
+		branch := repo.CurrentBranch()
+		if branch == "" {
+			return "", fmt.Errorf("kernel is pinned to %s; pass a ref to upgrade", repo.Describe())
+		}
+		commit, err := repo.ResolveCommit("@{upstream}")
+		if err != nil {
+			return "", fmt.Errorf("branch %s has no upstream; pass a ref to upgrade", branch)
+		}
+		return commit, nil
+	}
+	for _, rev := range []string{"origin/" + ref, ref} {
+		if commit, err := repo.ResolveCommit(rev); err == nil {
+			return commit, nil
+		}
+	}
+	return "", fmt.Errorf("ref %q not found in the kernel repository", ref)
+}
+
+// Record is the version history of one kernel
+type Record struct {
+	Current  *Version `json:"current,omitempty"`
+	Previous *Version `json:"previous,omitempty"`
+}
+
+// VersionStore remembers the installed and previous version of each
+// kernel so an upgrade can be rolled back
+type VersionStore struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]*Record
+}
+
+// NewVersionStore loads the versions saved at path
+func NewVersionStore(path string) *VersionStore {
+	vs := &VersionStore{path: path, records: make(map[string]*Record)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &vs.records); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to load kernel versions")
+		}
+	}
+	return vs
+}
+
+// Get returns the recorded versions of kernel
+func (vs *VersionStore) Get(kernel string) Record {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	if r := vs.records[kernel]; r != nil {
+		return *r
+	}
+	return Record{}
+}
+
+// Installed records v as the current version of kernel; the version it
+// replaces becomes the rollback target
+func (vs *VersionStore) Installed(kernel string, v Version) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	r := vs.records[kernel]
+	if r == nil {
+		r = &Record{}
+		vs.records[kernel] = r
+	}
+	now := time.Now()
+	v.InstalledAt = &now
+	if r.Current != nil {
+		if r.Current.Commit != v.Commit {
+			prev := *r.Current
+			r.Previous = &prev
+		} else if r.Current.InstalledAt != nil {
+			v.InstalledAt = r.Current.InstalledAt
+		}
+	}
+	r.Current = &v
+
+	data, err := json.MarshalIndent(vs.records, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(vs.path), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(vs.path, data, 0600)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to save kernel versions")
+	}
+}
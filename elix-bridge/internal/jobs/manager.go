@@ -0,0 +1,362 @@
+// Package jobs provides a background job queue for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Status represents the state of a job
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Func is the work performed by a job. It should honor ctx cancellation and
+// may report progress through the job.
+type Func func(ctx context.Context, job *Job) (interface{}, error)
+
+// Job is a long-running operation tracked by the queue
+type Job struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	Status     Status      `json:"status"`
+	Progress   float64     `json:"progress"` // 0..1
+	Message    string      `json:"message,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+
+	mgr    *Manager
+	fn     Func
+	cancel context.CancelFunc
+}
+
+// Event is published whenever a job changes
+type Event struct {
+	Type string `json:"type"` // "job.created", "job.updated", "job.finished"
+	Job  Job    `json:"job"`
+}
+
+// Manager runs jobs on a bounded worker pool and persists their status
+type Manager struct {
+	mu          sync.RWMutex
+	jobs        map[string]*Job
+	queue       chan *Job
+	subs        map[chan Event]struct{}
+	storagePath string
+	maxJobs     int
+}
+
+// ManagerConfig configures the job manager
+type ManagerConfig struct {
+	Workers     int    // 并发执行的任务数，默认 2
+	StoragePath string // 状态持久化路径，空则不持久化
+	MaxJobs     int    // 保留的历史任务数，默认 200
+}
+
+// NewManager creates a job manager and starts its workers
+func NewManager(config ManagerConfig) *Manager {
+	if config.Workers <= 0 {
+		config.Workers = 2
+	}
+	if config.MaxJobs <= 0 {
+		config.MaxJobs = 200
+	}
+
+	m := &Manager{
+		jobs:        make(map[string]*Job),
+		queue:       make(chan *Job, 1024),
+		subs:        make(map[chan Event]struct{}),
+		storagePath: config.StoragePath,
+		maxJobs:     config.MaxJobs,
+	}
+
+	if err := m.load(); err != nil {
+		log.Warn().Err(err).Msg("Failed to load job state")
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		go m.worker()
+	}
+
+	return m
+}
+
+// Submit queues fn as a new job of the given kind and returns a snapshot of it
+func (m *Manager) Submit(kind string, fn Func) Job {
+	job := &Job{
+		ID:        generateID(),
+		Kind:      kind,
+		Status:    StatusQueued,
+		CreatedAt: time.Now(),
+		mgr:       m,
+		fn:        fn,
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.pruneLocked()
+	snapshot := job.copyLocked()
+	m.mu.Unlock()
+
+	m.publish("job.created", job)
+	m.queue <- job
+
+	log.Info().Str("id", job.ID).Str("kind", kind).Msg("Job queued")
+	return snapshot
+}
+
+// Get returns a copy of a job by ID
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.copyLocked(), true
+}
+
+// List returns copies of all jobs, newest first
+func (m *Manager) List() []Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		list = append(list, job.copyLocked())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Cancel cancels a queued or running job
+func (m *Manager) Cancel(id string) bool {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok || job.Status == StatusSucceeded || job.Status == StatusFailed || job.Status == StatusCanceled {
+		m.mu.Unlock()
+		return false
+	}
+
+	if job.Status == StatusQueued {
+		now := time.Now()
+		job.Status = StatusCanceled
+		job.FinishedAt = &now
+	} else if job.cancel != nil {
+		job.cancel()
+	}
+	m.mu.Unlock()
+
+	m.publish("job.updated", job)
+	return true
+}
+
+// Subscribe returns a channel of job events; call cancel to unsubscribe
+func (m *Manager) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+
+	m.mu.Lock()
+	m.subs[ch] = struct{}{}
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.subs[ch]; ok {
+			delete(m.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// SetProgress reports progress (0..1) and an optional status message
+func (j *Job) SetProgress(progress float64, message string) {
+	j.mgr.mu.Lock()
+	j.Progress = progress
+	j.Message = message
+	j.mgr.mu.Unlock()
+
+	j.mgr.publish("job.updated", j)
+}
+
+func (m *Manager) worker() {
+	for job := range m.queue {
+		m.mu.Lock()
+		if job.Status != StatusQueued {
+			// 排队期间已被取消
+			m.mu.Unlock()
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		now := time.Now()
+		job.Status = StatusRunning
+		job.StartedAt = &now
+		job.cancel = cancel
+		m.mu.Unlock()
+
+		m.publish("job.updated", job)
+
+		result, err := job.fn(ctx, job)
+
+		m.mu.Lock()
+		finished := time.Now()
+		job.FinishedAt = &finished
+		switch {
+		case ctx.Err() == context.Canceled:
+			job.Status = StatusCanceled
+		case err != nil:
+			job.Status = StatusFailed
+			job.Error = err.Error()
+		default:
+			job.Status = StatusSucceeded
+			job.Progress = 1
+		}
+		// 失败时也保留结果（如任务输出），便于排查
+		job.Result = result
+		status := job.Status
+		m.mu.Unlock()
+		cancel()
+
+		log.Info().Str("id", job.ID).Str("kind", job.Kind).Str("status", string(status)).Msg("Job finished")
+		m.publish("job.finished", job)
+
+		if err := m.save(); err != nil {
+			log.Warn().Err(err).Msg("Failed to save job state")
+		}
+	}
+}
+
+func (m *Manager) publish(eventType string, job *Job) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	event := Event{Type: eventType, Job: job.copyLocked()}
+	for ch := range m.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (j *Job) copyLocked() Job {
+	return Job{
+		ID:         j.ID,
+		Kind:       j.Kind,
+		Status:     j.Status,
+		Progress:   j.Progress,
+		Message:    j.Message,
+		Result:     j.Result,
+		Error:      j.Error,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+}
+
+func (m *Manager) pruneLocked() {
+	if len(m.jobs) <= m.maxJobs {
+		return
+	}
+	list := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if job.FinishedAt != nil {
+			list = append(list, job)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	for i := 0; i < len(list) && len(m.jobs) > m.maxJobs; i++ {
+		delete(m.jobs, list[i].ID)
+	}
+}
+
+// Persistence
+
+func (m *Manager) save() error {
+	if m.storagePath == "" {
+		return nil
+	}
+
+	m.mu.RLock()
+	list := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		list = append(list, job.copyLocked())
+	}
+	m.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(m.storagePath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.storagePath, data, 0644)
+}
+
+func (m *Manager) load() error {
+	if m.storagePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(m.storagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var list []*Job
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, job := range list {
+		// 重启前未完成的任务无法恢复执行
+		if job.Status == StatusQueued || job.Status == StatusRunning {
+			job.Status = StatusFailed
+			job.Error = "interrupted by bridge restart"
+			job.FinishedAt = &now
+		}
+		job.mgr = m
+		m.jobs[job.ID] = job
+	}
+	return nil
+}
+
+func generateID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,286 @@
+// Package aider provides the Aider kernel adapter for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package aider
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"echohelix/bridge/internal/kernel"
+)
+
+// Name is the kernel name of Aider
+const Name = "aider"
+
+// DefaultPort is where cores/aider/server.py listens unless told otherwise
+const DefaultPort = 41243
+
+// Commands lists the Aider slash commands a client may send with the
+// command method
+var Commands = []string{
+	"add", "drop", "read-only", "ls", "diff", "undo", "commit", "run", "test", "lint",
+	"clear", "reset", "tokens", "ask", "code", "architect", "model", "git", "map",
+}
+
+// Adapter talks to the Aider server in cores/aider. server.py wraps the
+// aider Coder and sends JSON frames over /ws:
+//
+//	{"type": "token", "content": "..."}       streamed reply text
+//	{"type": "output"|"warning"|"error", "content": "..."}  aider's io messages
+//	{"type": "diff", "content": "..."}        output of /diff or an applied edit
+//	{"type": "commit", "hash": "...", "message": "..."}
+//	{"type": "done", "tokens_sent": 0, "tokens_received": 0, "cost": 0}
+//
+// Plain text frames are treated as reply text. The reply and output are
+// also parsed for what aider prints itself: SEARCH/REPLACE blocks,
+// "Applied edit to" and "Commit <hash> <message>" lines.
+type Adapter struct{}
+
+// New creates the Aider adapter
+func New() *Adapter {
+	return &Adapter{}
+}
+
+func (*Adapter) Name() string { return Name }
+
+func (*Adapter) URL(port int) string {
+	if port == 0 {
+		port = DefaultPort
+	}
+	return fmt.Sprintf("ws://127.0.0.1:%d/ws", port)
+}
+
+func (*Adapter) NewCodec() kernel.Codec {
+	return &codec{}
+}
+
+// Capabilities reflects aider's edit loop: it streams, edits and
+// commits several files per turn, and takes images with /add when the
+// model has vision, but has no tool calls of its own. Images are kept
+// within what the common vision models accept.
+func (*Adapter) Capabilities() kernel.Capabilities {
+	return kernel.Capabilities{
+		Streaming:         true,
+		ImageInput:        true,
+		MaxImageDimension: 1568,
+		MaxImageBytes:     5 << 20,
+		Interrupt:         true,
+		MultiFileEdits:    true,
+		Commits:           true,
+		Methods:           []string{kernel.MethodChat, kernel.MethodCommand, kernel.MethodInterrupt},
+		Commands:          Commands,
+	}
+}
+
+type codec struct {
+	blocks editBlockParser
+	// usage is read from aider's "Tokens: ..." report until done
+	usage *kernel.Usage
+}
+
+type frame struct {
+	Type           string  `json:"type"`
+	Content        string  `json:"content"`
+	Hash           string  `json:"hash"`
+	Message        string  `json:"message"`
+	TokensSent     int     `json:"tokens_sent"`
+	TokensReceived int     `json:"tokens_received"`
+	Cost           float64 `json:"cost"`
+}
+
+// Encode sends chats and commands as aider input lines; slash commands
+// are how aider itself takes them
+func (c *codec) Encode(req kernel.Request) ([][]byte, error) {
+	var frames [][]byte
+	chat := func(text string) {
+		data, _ := json.Marshal(map[string]string{"type": "chat", "message": text})
+		frames = append(frames, data)
+	}
+
+	switch req.Method {
+	case kernel.MethodChat:
+		if strings.TrimSpace(req.Text) == "" {
+			return nil, fmt.Errorf("text is required")
+		}
+		if len(req.Files) > 0 {
+			chat("/add " + quoteArgs(req.Files))
+		}
+		chat(req.Text)
+	case kernel.MethodCommand:
+		name := strings.TrimPrefix(req.Command, "/")
+		if !isCommand(name) {
+			return nil, fmt.Errorf("unknown aider command %q", req.Command)
+		}
+		line := "/" + name
+		if len(req.Args) > 0 {
+			line += " " + quoteArgs(req.Args)
+		}
+		chat(line)
+	case kernel.MethodInterrupt:
+		frames = append(frames, []byte(`{"type":"interrupt"}`))
+	default:
+		return nil, kernel.ErrUnsupported
+	}
+	return frames, nil
+}
+
+func (c *codec) Decode(data []byte) []kernel.Event {
+	var f frame
+	if err := json.Unmarshal(data, &f); err != nil || f.Type == "" {
+		// 非 JSON 帧按回复文本处理
+		return c.reply(string(data))
+	}
+
+	switch f.Type {
+	case "token", "stream", "chunk":
+		return c.reply(f.Content)
+	case "message", "response":
+		events := c.reply(f.Content)
+		return append(events, c.event(kernel.EventDone))
+	case "output", "tool_output":
+		return c.output(f.Content, "info")
+	case "warning", "tool_warning":
+		return c.output(f.Content, "warning")
+	case "error", "tool_error":
+		ev := c.event(kernel.EventError)
+		ev.Text = f.Content
+		return []kernel.Event{ev}
+	case "diff":
+		return c.diff(f.Content)
+	case "commit":
+		ev := c.event(kernel.EventCommit)
+		ev.Commit = &kernel.Commit{Hash: f.Hash, Message: f.Message}
+		return []kernel.Event{ev}
+	case "done", "end":
+		events := c.flushBlocks()
+		ev := c.event(kernel.EventDone)
+		ev.Usage = c.usage
+		if f.TokensSent > 0 || f.TokensReceived > 0 || f.Cost > 0 {
+			ev.Usage = &kernel.Usage{InputTokens: f.TokensSent, OutputTokens: f.TokensReceived, Cost: f.Cost}
+		}
+		c.usage = nil
+		return append(events, ev)
+	}
+
+	ev := c.event(kernel.EventRaw)
+	ev.Raw = json.RawMessage(data)
+	return []kernel.Event{ev}
+}
+
+func (c *codec) event(t kernel.EventType) kernel.Event {
+	return kernel.Event{Type: t, Kernel: Name}
+}
+
+// reply emits text as a delta, followed by any edit blocks it completed
+func (c *codec) reply(text string) []kernel.Event {
+	if text == "" {
+		return nil
+	}
+	ev := c.event(kernel.EventMessageDelta)
+	ev.Text = text
+	events := []kernel.Event{ev}
+	for _, edit := range c.blocks.feed(text) {
+		e := c.event(kernel.EventFileEdit)
+		e.Edit = edit
+		events = append(events, e)
+	}
+	return events
+}
+
+func (c *codec) flushBlocks() []kernel.Event {
+	var events []kernel.Event
+	for _, edit := range c.blocks.flush() {
+		e := c.event(kernel.EventFileEdit)
+		e.Edit = edit
+		events = append(events, e)
+	}
+	return events
+}
+
+var (
+	appliedLine = regexp.MustCompile(`^Applied edit to (.+)$`)
+	commitLine  = regexp.MustCompile(`^Commit ([0-9a-f]{7,40}) (.+)$`)
+	tokensLine  = regexp.MustCompile(`^Tokens: ([\d.]+)(k?) sent, ([\d.]+)(k?) received\.(?: Cost: \$([\d.]+) message)?`)
+)
+
+// output emits aider's io messages, recognizing the lines that report
+// applied edits, commits and token usage
+func (c *codec) output(text, level string) []kernel.Event {
+	var events []kernel.Event
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case appliedLine.MatchString(line):
+			e := c.event(kernel.EventFileEdit)
+			e.Edit = &kernel.FileEdit{Path: appliedLine.FindStringSubmatch(line)[1], Applied: true}
+			events = append(events, e)
+		case commitLine.MatchString(line):
+			m := commitLine.FindStringSubmatch(line)
+			e := c.event(kernel.EventCommit)
+			e.Commit = &kernel.Commit{Hash: m[1], Message: m[2]}
+			events = append(events, e)
+		case tokensLine.MatchString(line):
+			// 用量随完成事件一起发出
+			m := tokensLine.FindStringSubmatch(line)
+			c.usage = &kernel.Usage{InputTokens: parseTokens(m[1], m[2]), OutputTokens: parseTokens(m[3], m[4])}
+			c.usage.Cost, _ = strconv.ParseFloat(m[5], 64)
+		}
+	}
+	ev := c.event(kernel.EventOutput)
+	ev.Text, ev.Level = text, level
+	return append([]kernel.Event{ev}, events...)
+}
+
+// diff splits a unified diff into one edit per file
+func (c *codec) diff(text string) []kernel.Event {
+	var events []kernel.Event
+	for _, edit := range splitDiff(text) {
+		e := c.event(kernel.EventFileEdit)
+		e.Edit = edit
+		events = append(events, e)
+	}
+	if len(events) == 0 {
+		ev := c.event(kernel.EventOutput)
+		ev.Text, ev.Level = text, "info"
+		events = append(events, ev)
+	}
+	return events
+}
+
+// parseTokens reads "1.2k" style counts from aider's token report
+func parseTokens(num, suffix string) int {
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if suffix == "k" {
+		f *= 1000
+	}
+	return int(f)
+}
+
+func isCommand(name string) bool {
+	for _, c := range Commands {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteArgs joins args for an aider command line, quoting ones with spaces
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if strings.ContainsAny(a, " \t\"") {
+			a = strconv.Quote(a)
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,170 @@
+package aider
+
+import (
+	"regexp"
+	"strings"
+
+	"echohelix/bridge/internal/kernel"
+)
+
+var (
+	searchMarker  = regexp.MustCompile(`^<{5,9} SEARCH\s*$`)
+	dividerMarker = regexp.MustCompile(`^={5,9}\s*$`)
+	replaceMarker = regexp.MustCompile(`^>{5,9} REPLACE\s*$`)
+)
+
+type blockState int
+
+const (
+	outsideBlock blockState = iota
+	inSearch
+	inReplace
+)
+
+// editBlockParser finds aider's SEARCH/REPLACE blocks in streamed reply
+// text. The file name is the last non-fence line before the block:
+//
+//	path/to/file.py
+//	```python
+//	<<<<<<< SEARCH
+//	old lines
+//	=======
+//	new lines
+//	>>>>>>> REPLACE
+//	```
+type editBlockParser struct {
+	partial  string // 尚未收到换行的行
+	state    blockState
+	lastLine string
+	path     string
+	search   []string
+	replace  []string
+}
+
+// feed consumes streamed text and returns the blocks it completed
+func (p *editBlockParser) feed(text string) []*kernel.FileEdit {
+	text = p.partial + text
+	lines := strings.Split(text, "\n")
+	p.partial = lines[len(lines)-1]
+
+	var edits []*kernel.FileEdit
+	for _, line := range lines[:len(lines)-1] {
+		if edit := p.line(strings.TrimRight(line, "\r")); edit != nil {
+			edits = append(edits, edit)
+		}
+	}
+	return edits
+}
+
+// flush ends the reply: a final line without newline is consumed and an
+// unfinished block is dropped
+func (p *editBlockParser) flush() []*kernel.FileEdit {
+	var edits []*kernel.FileEdit
+	if p.partial != "" {
+		if edit := p.line(p.partial); edit != nil {
+			edits = append(edits, edit)
+		}
+	}
+	*p = editBlockParser{}
+	return edits
+}
+
+func (p *editBlockParser) line(line string) *kernel.FileEdit {
+	switch p.state {
+	case outsideBlock:
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case searchMarker.MatchString(trimmed):
+			p.state = inSearch
+			p.path = strings.Trim(p.lastLine, "`*: ")
+			p.search, p.replace = nil, nil
+		case trimmed != "" && !strings.HasPrefix(trimmed, "```"):
+			p.lastLine = trimmed
+		}
+	case inSearch:
+		if dividerMarker.MatchString(line) {
+			p.state = inReplace
+		} else {
+			p.search = append(p.search, line)
+		}
+	case inReplace:
+		if !replaceMarker.MatchString(line) {
+			p.replace = append(p.replace, line)
+			return nil
+		}
+		p.state = outsideBlock
+		if p.path == "" {
+			return nil
+		}
+		var diff strings.Builder
+		for _, l := range p.search {
+			diff.WriteString("-" + l + "\n")
+		}
+		for _, l := range p.replace {
+			diff.WriteString("+" + l + "\n")
+		}
+		return &kernel.FileEdit{Path: p.path, Diff: diff.String()}
+	}
+	return nil
+}
+
+// splitDiff splits a unified diff, as printed by /diff, into one edit
+// per file
+func splitDiff(text string) []*kernel.FileEdit {
+	var edits []*kernel.FileEdit
+	var cur *kernel.FileEdit
+	var body strings.Builder
+	finish := func() {
+		if cur != nil && cur.Path != "" {
+			cur.Diff = body.String()
+			edits = append(edits, cur)
+		}
+		cur = nil
+		body.Reset()
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			finish()
+			cur = &kernel.FileEdit{Applied: true}
+			continue
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			// 删除行也可能以 "--- " 开头，只有紧跟 "+++ " 时才是文件头
+			if cur == nil || body.Len() > 0 {
+				finish()
+				cur = &kernel.FileEdit{Applied: true}
+			}
+			cur.Path = diffPath(lines[i+1][4:])
+			if cur.Path == "" {
+				cur.Path = diffPath(line[4:])
+			}
+			i++
+			continue
+		case strings.HasPrefix(line, "index "), strings.HasPrefix(line, "new file mode"), strings.HasPrefix(line, "deleted file mode"):
+			if body.Len() == 0 {
+				continue
+			}
+		}
+		if cur != nil {
+			body.WriteString(line + "\n")
+		}
+	}
+	finish()
+	return edits
+}
+
+// diffPath strips the a/ or b/ prefix of a diff header path; /dev/null
+// yields ""
+func diffPath(p string) string {
+	p = strings.TrimSpace(strings.SplitN(p, "\t", 2)[0])
+	if p == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/") {
+		return p[2:]
+	}
+	return p
+}
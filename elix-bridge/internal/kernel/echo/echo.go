@@ -0,0 +1,190 @@
+// Package echo provides a built-in mock kernel for EchoHelix Bridge.
+//
+// The echo kernel runs inside the bridge, needs no API key, npm or
+// python, and answers every prompt by streaming it back. It also
+// simulates thoughts, tool calls with approval, file edits and errors,
+// so apps and CI can exercise the whole pairing, session and chat flow
+// offline.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package echo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/session"
+)
+
+// Name is the kernel name of the echo kernel
+const Name = "echo"
+
+// DefaultPort is where the echo kernel listens unless told otherwise
+const DefaultPort = 41244
+
+// Commands simulate what real kernels do besides replying
+const (
+	// CommandTool runs a tool call named by the first argument, after
+	// asking for approval
+	CommandTool = "tool"
+	// CommandEdit proposes an edit to the file named by the first argument
+	CommandEdit = "edit"
+	// CommandError fails the turn with the arguments as message
+	CommandError = "error"
+)
+
+// Commands lists the commands a client may send with the command method
+var Commands = []string{CommandTool, CommandEdit, CommandError}
+
+// Adapter talks to the echo kernel. Its frames are JSON over /ws:
+//
+//	{"type": "thought", "content": "..."}
+//	{"type": "token", "content": "..."}     streamed reply text
+//	{"type": "tool_call", "id": "...", "name": "...", "arguments": {}, "status": "...", "result": "..."}
+//	{"type": "edit", "path": "...", "diff": "..."}
+//	{"type": "error", "content": "..."}
+//	{"type": "done", "input_tokens": 0, "output_tokens": 0}
+//
+// Clients send {"type": "chat"|"command"|"interrupt"|"approve", ...}.
+type Adapter struct{}
+
+// New creates the echo adapter
+func New() *Adapter {
+	return &Adapter{}
+}
+
+func (*Adapter) Name() string { return Name }
+
+func (*Adapter) URL(port int) string {
+	if port == 0 {
+		port = DefaultPort
+	}
+	return fmt.Sprintf("ws://127.0.0.1:%d/ws", port)
+}
+
+func (*Adapter) NewCodec() kernel.Codec {
+	return &codec{}
+}
+
+// Capabilities claims everything the kernel can simulate, so clients
+// show all of their UI against it
+func (*Adapter) Capabilities() kernel.Capabilities {
+	return kernel.Capabilities{
+		Streaming:      true,
+		ToolCalls:      true,
+		Approvals:      true,
+		Interrupt:      true,
+		MultiFileEdits: true,
+		Thoughts:       true,
+		Methods:        []string{kernel.MethodChat, kernel.MethodCommand, kernel.MethodInterrupt, kernel.MethodApprove},
+		Commands:       Commands,
+	}
+}
+
+// frame is a message in either direction
+type frame struct {
+	Type string `json:"type"`
+
+	// 客户端 → 内核
+	Message string   `json:"message,omitempty"`
+	Files   []string `json:"files,omitempty"`
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	CallID  string   `json:"call_id,omitempty"`
+	Outcome string   `json:"outcome,omitempty"`
+
+	// 内核 → 客户端
+	Content      string                 `json:"content,omitempty"`
+	ID           string                 `json:"id,omitempty"`
+	Name         string                 `json:"name,omitempty"`
+	ToolArgs     map[string]interface{} `json:"arguments,omitempty"`
+	Status       string                 `json:"status,omitempty"`
+	Result       string                 `json:"result,omitempty"`
+	Path         string                 `json:"path,omitempty"`
+	Diff         string                 `json:"diff,omitempty"`
+	InputTokens  int                    `json:"input_tokens,omitempty"`
+	OutputTokens int                    `json:"output_tokens,omitempty"`
+}
+
+type codec struct{}
+
+func (c *codec) Encode(req kernel.Request) ([][]byte, error) {
+	f := frame{Type: req.Method}
+	switch req.Method {
+	case kernel.MethodChat:
+		if strings.TrimSpace(req.Text) == "" {
+			return nil, fmt.Errorf("text is required")
+		}
+		f.Message, f.Files = req.Text, req.Files
+	case kernel.MethodCommand:
+		name := strings.TrimPrefix(req.Command, "/")
+		if !isCommand(name) {
+			return nil, fmt.Errorf("unknown echo command %q", req.Command)
+		}
+		f.Command, f.Args = name, req.Args
+	case kernel.MethodInterrupt:
+	case kernel.MethodApprove:
+		if req.CallID == "" {
+			return nil, fmt.Errorf("call_id is required")
+		}
+		f.CallID, f.Outcome = req.CallID, req.Outcome
+	default:
+		return nil, kernel.ErrUnsupported
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{data}, nil
+}
+
+func (c *codec) Decode(data []byte) []kernel.Event {
+	var f frame
+	if err := json.Unmarshal(data, &f); err != nil || f.Type == "" {
+		ev := c.event(kernel.EventRaw)
+		ev.Text = string(data)
+		return []kernel.Event{ev}
+	}
+
+	var ev kernel.Event
+	switch f.Type {
+	case "thought":
+		ev = c.event(kernel.EventThought)
+		ev.Text = f.Content
+	case "token":
+		ev = c.event(kernel.EventMessageDelta)
+		ev.Text = f.Content
+	case "tool_call":
+		ev = c.event(kernel.EventToolCall)
+		ev.ToolCall = &session.ToolCall{ID: f.ID, Name: f.Name, Arguments: f.ToolArgs, Result: f.Result, Status: f.Status}
+	case "edit":
+		ev = c.event(kernel.EventFileEdit)
+		ev.Edit = &kernel.FileEdit{Path: f.Path, Diff: f.Diff}
+	case "error":
+		ev = c.event(kernel.EventError)
+		ev.Text = f.Content
+	case "done":
+		ev = c.event(kernel.EventDone)
+		ev.Usage = &kernel.Usage{InputTokens: f.InputTokens, OutputTokens: f.OutputTokens}
+	default:
+		ev = c.event(kernel.EventRaw)
+		ev.Raw = json.RawMessage(data)
+	}
+	return []kernel.Event{ev}
+}
+
+func (c *codec) event(t kernel.EventType) kernel.Event {
+	return kernel.Event{Type: t, Kernel: Name}
+}
+
+func isCommand(name string) bool {
+	for _, c := range Commands {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package echo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/kernel"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// Server is the echo kernel itself, served by the process manager on the
+// kernel's port in place of a child process
+type Server struct {
+	// Delay is the pause between streamed words and tool call steps
+	Delay time.Duration
+
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	conns map[*conn]struct{}
+}
+
+// NewServer creates the echo kernel with a delay that makes streaming
+// visible in the app
+func NewServer() *Server {
+	return &Server{Delay: 30 * time.Millisecond, conns: make(map[*conn]struct{})}
+}
+
+// ServeHTTP accepts kernel connections on /ws; each runs one turn at a time
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/ws" {
+		http.NotFound(w, r)
+		return
+	}
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &conn{server: s, ws: ws, approvals: make(map[string]chan string)}
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+	c.serve(r.Context())
+}
+
+// Close drops every connection; the process manager calls it on stop
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.ws.Close()
+	}
+	return nil
+}
+
+// conn is one client of the kernel
+type conn struct {
+	server *Server
+	ws     *websocket.Conn
+
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	cancel    context.CancelFunc // 正在进行的回合
+	approvals map[string]chan string
+	calls     int
+}
+
+func (c *conn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer c.ws.Close()
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var f frame
+		if err := json.Unmarshal(data, &f); err != nil {
+			c.send(frame{Type: "error", Content: "invalid frame: " + err.Error()})
+			continue
+		}
+
+		switch f.Type {
+		case kernel.MethodChat:
+			c.start(ctx, func(ctx context.Context) { c.chat(ctx, f.Message, f.Files) })
+		case kernel.MethodCommand:
+			c.start(ctx, func(ctx context.Context) { c.command(ctx, f.Command, f.Args) })
+		case kernel.MethodInterrupt:
+			c.mu.Lock()
+			if c.cancel != nil {
+				c.cancel()
+			}
+			c.mu.Unlock()
+		case kernel.MethodApprove:
+			c.mu.Lock()
+			ch, ok := c.approvals[f.CallID]
+			delete(c.approvals, f.CallID)
+			c.mu.Unlock()
+			if !ok {
+				c.send(frame{Type: "error", Content: "no tool call awaiting approval: " + f.CallID})
+				continue
+			}
+			ch <- f.Outcome
+		default:
+			c.send(frame{Type: "error", Content: "unknown frame type: " + f.Type})
+		}
+	}
+}
+
+// start runs a turn unless one is already running
+func (c *conn) start(ctx context.Context, turn func(ctx context.Context)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.send(frame{Type: "error", Content: "a turn is already running"})
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			c.cancel = nil
+			c.mu.Unlock()
+			cancel()
+		}()
+		turn(ctx)
+	}()
+}
+
+// chat streams the prompt back, reading each attached file first
+func (c *conn) chat(ctx context.Context, text string, files []string) {
+	words := strings.Fields(text)
+	c.send(frame{Type: "thought", Content: fmt.Sprintf("Echoing %d words", len(words))})
+
+	for _, path := range files {
+		id := c.nextCall()
+		args := map[string]interface{}{"path": path}
+		c.send(frame{Type: "tool_call", ID: id, Name: "read_file", ToolArgs: args, Status: kernel.ToolRunning})
+		if !c.wait(ctx) {
+			c.done(len(words), 0)
+			return
+		}
+		c.send(frame{Type: "tool_call", ID: id, Name: "read_file", ToolArgs: args, Status: "completed", Result: "(simulated)"})
+	}
+
+	reply := append([]string{"Echo:"}, words...)
+	sent := 0
+	for i, word := range reply {
+		if i > 0 {
+			word = " " + word
+		}
+		if !c.wait(ctx) {
+			break
+		}
+		c.send(frame{Type: "token", Content: word})
+		sent++
+	}
+	c.done(len(words), sent)
+}
+
+// command simulates a tool call, an edit or an error
+func (c *conn) command(ctx context.Context, name string, args []string) {
+	arg := ""
+	if len(args) > 0 {
+		arg = args[0]
+	}
+
+	switch name {
+	case CommandTool:
+		if arg == "" {
+			arg = "run_shell_command"
+		}
+		id := c.nextCall()
+		callArgs := map[string]interface{}{"args": args[min(1, len(args)):]}
+		outcome := make(chan string, 1)
+		c.mu.Lock()
+		c.approvals[id] = outcome
+		c.mu.Unlock()
+		c.send(frame{Type: "tool_call", ID: id, Name: arg, ToolArgs: callArgs, Status: kernel.ToolAwaitingApproval})
+
+		select {
+		case o := <-outcome:
+			if o == kernel.OutcomeCancel {
+				c.send(frame{Type: "tool_call", ID: id, Name: arg, ToolArgs: callArgs, Status: kernel.ToolCancelled})
+				break
+			}
+			c.send(frame{Type: "tool_call", ID: id, Name: arg, ToolArgs: callArgs, Status: kernel.ToolRunning})
+			if c.wait(ctx) {
+				c.send(frame{Type: "tool_call", ID: id, Name: arg, ToolArgs: callArgs, Status: "completed", Result: "(simulated)"})
+			}
+		case <-ctx.Done():
+			c.mu.Lock()
+			delete(c.approvals, id)
+			c.mu.Unlock()
+			c.send(frame{Type: "tool_call", ID: id, Name: arg, ToolArgs: callArgs, Status: kernel.ToolCancelled})
+		}
+	case CommandEdit:
+		if arg == "" {
+			arg = "README.md"
+		}
+		c.send(frame{Type: "edit", Path: arg, Diff: "+Edited by the echo kernel"})
+	case CommandError:
+		msg := strings.Join(args, " ")
+		if msg == "" {
+			msg = "Simulated error"
+		}
+		c.send(frame{Type: "error", Content: msg})
+	default:
+		c.send(frame{Type: "error", Content: "unknown command: " + name})
+	}
+	c.done(len(args), 0)
+}
+
+// wait pauses for the delay; false means the turn was interrupted
+func (c *conn) wait(ctx context.Context) bool {
+	select {
+	case <-time.After(c.server.Delay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// done ends a turn; tokens are counted as words
+func (c *conn) done(input, output int) {
+	c.send(frame{Type: "done", InputTokens: input, OutputTokens: output})
+}
+
+func (c *conn) nextCall() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	return fmt.Sprintf("echo-%d", c.calls)
+}
+
+func (c *conn) send(f frame) {
+	data, _ := json.Marshal(f)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+		log.Debug().Str("component", "kernel").Err(err).Msg("Echo kernel write failed")
+	}
+}
@@ -0,0 +1,418 @@
+// Package gemini provides the Gemini a2a-server kernel adapter for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package gemini
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"echohelix/bridge/internal/kernel"
+	"echohelix/bridge/internal/session"
+)
+
+// Name is the kernel name of Gemini
+const Name = "gemini"
+
+// DefaultPort is where the a2a-server listens unless told otherwise
+const DefaultPort = 41242
+
+// Adapter talks to the a2a-server of cores/gemini. Frames are JSON-RPC
+// 2.0 messages; results are A2A events ("task", "status-update",
+// "artifact-update", "message") whose metadata.coderAgent.kind says
+// what a status update carries: text-content, thought, tool-call-update,
+// tool-call-confirmation, or state-change.
+type Adapter struct{}
+
+// New creates the Gemini adapter
+func New() *Adapter {
+	return &Adapter{}
+}
+
+func (*Adapter) Name() string { return Name }
+
+func (*Adapter) URL(port int) string {
+	if port == 0 {
+		port = DefaultPort
+	}
+	return fmt.Sprintf("ws://127.0.0.1:%d", port)
+}
+
+func (*Adapter) NewCodec() kernel.Codec {
+	return &codec{}
+}
+
+// Capabilities reflects the Gemini CLI agent: tools that change files or
+// run commands ask for approval first, and @path reads images too,
+// inlined into the request up to Gemini's inline data limit
+func (*Adapter) Capabilities() kernel.Capabilities {
+	return kernel.Capabilities{
+		Streaming:         true,
+		ToolCalls:         true,
+		Approvals:         true,
+		ImageInput:        true,
+		MaxImageDimension: 3072,
+		MaxImageBytes:     7 << 20,
+		Interrupt:         true,
+		MultiFileEdits:    true,
+		Thoughts:          true,
+		Methods:           []string{kernel.MethodChat, kernel.MethodApprove, kernel.MethodInterrupt},
+	}
+}
+
+// codec remembers the task and context of the conversation so follow-up
+// messages and approvals reach the same task
+type codec struct {
+	nextID    int
+	taskID    string
+	contextID string
+}
+
+type part struct {
+	Kind string          `json:"kind"`
+	Text string          `json:"text,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type message struct {
+	Kind      string `json:"kind"`
+	Role      string `json:"role"`
+	MessageID string `json:"messageId"`
+	Parts     []part `json:"parts"`
+	TaskID    string `json:"taskId,omitempty"`
+	ContextID string `json:"contextId,omitempty"`
+}
+
+func (c *codec) request(method string, params interface{}) []byte {
+	c.nextID++
+	data, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      c.nextID,
+		"method":  method,
+		"params":  params,
+	})
+	return data
+}
+
+func (c *codec) send(parts ...part) []byte {
+	msg := message{
+		Kind:      "message",
+		Role:      "user",
+		MessageID: newID(),
+		Parts:     parts,
+		TaskID:    c.taskID,
+		ContextID: c.contextID,
+	}
+	return c.request("message/stream", map[string]interface{}{"message": msg})
+}
+
+func (c *codec) Encode(req kernel.Request) ([][]byte, error) {
+	switch req.Method {
+	case kernel.MethodChat:
+		if strings.TrimSpace(req.Text) == "" {
+			return nil, fmt.Errorf("text is required")
+		}
+		// Gemini 用 @路径 把文件加入上下文
+		text := req.Text
+		for i := len(req.Files) - 1; i >= 0; i-- {
+			text = "@" + req.Files[i] + " " + text
+		}
+		return [][]byte{c.send(part{Kind: "text", Text: text})}, nil
+	case kernel.MethodApprove:
+		if req.CallID == "" {
+			return nil, fmt.Errorf("call_id is required")
+		}
+		switch req.Outcome {
+		case kernel.OutcomeProceedOnce, kernel.OutcomeProceedAlways, kernel.OutcomeCancel:
+		case "":
+			req.Outcome = kernel.OutcomeProceedOnce
+		default:
+			return nil, fmt.Errorf("unknown outcome %q", req.Outcome)
+		}
+		data, _ := json.Marshal(map[string]string{"callId": req.CallID, "outcome": req.Outcome})
+		return [][]byte{c.send(part{Kind: "data", Data: data})}, nil
+	case kernel.MethodInterrupt:
+		if c.taskID == "" {
+			return nil, fmt.Errorf("no task is running")
+		}
+		return [][]byte{c.request("tasks/cancel", map[string]string{"id": c.taskID})}, nil
+	}
+	return nil, kernel.ErrUnsupported
+}
+
+type rpcFrame struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Params json.RawMessage `json:"params"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type statusFrame struct {
+	State   string   `json:"state"`
+	Message *message `json:"message"`
+}
+
+type a2aEvent struct {
+	Kind      string      `json:"kind"`
+	ID        string      `json:"id"`
+	TaskID    string      `json:"taskId"`
+	ContextID string      `json:"contextId"`
+	Status    statusFrame `json:"status"`
+	Final     bool        `json:"final"`
+	Parts     []part      `json:"parts"`
+	Artifact  struct {
+		Parts []part `json:"parts"`
+	} `json:"artifact"`
+	Metadata struct {
+		CoderAgent struct {
+			Kind string `json:"kind"`
+		} `json:"coderAgent"`
+	} `json:"metadata"`
+}
+
+func (c *codec) Decode(data []byte) []kernel.Event {
+	var f rpcFrame
+	if err := json.Unmarshal(data, &f); err != nil {
+		return []kernel.Event{c.raw(data)}
+	}
+	if f.Error != nil {
+		ev := c.event(kernel.EventError)
+		ev.Text = f.Error.Message
+		return []kernel.Event{ev}
+	}
+	body := f.Result
+	if body == nil {
+		body = f.Params
+	}
+	var ev a2aEvent
+	if body == nil || json.Unmarshal(body, &ev) != nil || ev.Kind == "" {
+		return []kernel.Event{c.raw(data)}
+	}
+
+	switch ev.Kind {
+	case "task":
+		c.track(ev.ID, ev.ContextID)
+		return c.state(ev.Status, false)
+	case "message":
+		c.track(ev.TaskID, ev.ContextID)
+		return c.parts(ev.Parts, kernel.EventMessageDelta)
+	case "artifact-update":
+		c.track(ev.TaskID, ev.ContextID)
+		return c.parts(ev.Artifact.Parts, kernel.EventMessageDelta)
+	case "status-update":
+		c.track(ev.TaskID, ev.ContextID)
+		var parts []part
+		if ev.Status.Message != nil {
+			parts = ev.Status.Message.Parts
+		}
+		switch ev.Metadata.CoderAgent.Kind {
+		case "text-content":
+			return c.parts(parts, kernel.EventMessageDelta)
+		case "thought":
+			return c.thoughts(parts)
+		case "tool-call-update", "tool-call-confirmation":
+			return c.toolCalls(parts)
+		}
+		return c.state(ev.Status, ev.Final)
+	}
+	return []kernel.Event{c.raw(data)}
+}
+
+func (c *codec) track(taskID, contextID string) {
+	if taskID != "" {
+		c.taskID = taskID
+	}
+	if contextID != "" {
+		c.contextID = contextID
+	}
+}
+
+func (c *codec) event(t kernel.EventType) kernel.Event {
+	return kernel.Event{Type: t, Kernel: Name}
+}
+
+func (c *codec) raw(data []byte) kernel.Event {
+	ev := c.event(kernel.EventRaw)
+	if json.Valid(data) {
+		ev.Raw = json.RawMessage(data)
+	} else {
+		ev.Text = string(data)
+	}
+	return ev
+}
+
+// parts emits the text parts of a message as events of type t
+func (c *codec) parts(parts []part, t kernel.EventType) []kernel.Event {
+	var events []kernel.Event
+	for _, p := range parts {
+		if p.Kind == "text" && p.Text != "" {
+			ev := c.event(t)
+			ev.Text = p.Text
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func (c *codec) thoughts(parts []part) []kernel.Event {
+	var events []kernel.Event
+	for _, p := range parts {
+		var thought struct {
+			Subject     string `json:"subject"`
+			Description string `json:"description"`
+		}
+		if p.Kind != "data" || json.Unmarshal(p.Data, &thought) != nil {
+			continue
+		}
+		ev := c.event(kernel.EventThought)
+		ev.Text = thought.Subject
+		if thought.Description != "" {
+			if ev.Text != "" {
+				ev.Text += ": "
+			}
+			ev.Text += thought.Description
+		}
+		events = append(events, ev)
+	}
+	return append(events, c.parts(parts, kernel.EventThought)...)
+}
+
+// state turns task states into turn boundaries. input-required ends the
+// turn but keeps the task, which waits for a reply or an approval.
+func (c *codec) state(status statusFrame, final bool) []kernel.Event {
+	var events []kernel.Event
+	switch status.State {
+	case "failed":
+		ev := c.event(kernel.EventError)
+		ev.Text = "Task failed"
+		if status.Message != nil {
+			if texts := c.parts(status.Message.Parts, kernel.EventError); len(texts) > 0 {
+				ev.Text = texts[len(texts)-1].Text
+			}
+		}
+		events = append(events, ev)
+	case "completed", "canceled":
+	case "input-required":
+		if !final {
+			return nil
+		}
+		return []kernel.Event{c.event(kernel.EventDone)}
+	default:
+		return nil
+	}
+	c.taskID = ""
+	return append(events, c.event(kernel.EventDone))
+}
+
+// toolCall is the data part of a tool-call-update
+type toolCall struct {
+	Request struct {
+		CallID string                 `json:"callId"`
+		Name   string                 `json:"name"`
+		Args   map[string]interface{} `json:"args"`
+	} `json:"request"`
+	Status   string `json:"status"`
+	Response *struct {
+		ResultDisplay interface{} `json:"resultDisplay"`
+		Error         *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"response"`
+	ConfirmationDetails *struct {
+		Type     string `json:"type"`
+		Title    string `json:"title"`
+		FileName string `json:"fileName"`
+		FilePath string `json:"filePath"`
+		FileDiff string `json:"fileDiff"`
+		Command  string `json:"command"`
+	} `json:"confirmationDetails"`
+}
+
+// editTools are the Gemini CLI tools that change files
+var editTools = map[string]bool{"replace": true, "write_file": true, "edit": true}
+
+func (c *codec) toolCalls(parts []part) []kernel.Event {
+	var events []kernel.Event
+	for _, p := range parts {
+		var tc toolCall
+		if p.Kind != "data" || json.Unmarshal(p.Data, &tc) != nil || tc.Request.CallID == "" {
+			continue
+		}
+
+		call := &session.ToolCall{
+			ID:        tc.Request.CallID,
+			Name:      tc.Request.Name,
+			Arguments: tc.Request.Args,
+			Status:    toolStatus(tc.Status),
+		}
+		if tc.Response != nil {
+			if tc.Response.Error != nil {
+				call.Result = tc.Response.Error.Message
+			} else if s, ok := tc.Response.ResultDisplay.(string); ok {
+				call.Result = s
+			}
+		}
+		ev := c.event(kernel.EventToolCall)
+		ev.ToolCall = call
+		events = append(events, ev)
+
+		// 需要确认的编辑带有差异，成功后的编辑标记为已应用
+		path := stringArg(tc.Request.Args, "file_path", "absolute_path", "path")
+		switch {
+		case tc.ConfirmationDetails != nil && tc.ConfirmationDetails.Type == "edit":
+			if tc.ConfirmationDetails.FilePath != "" {
+				path = tc.ConfirmationDetails.FilePath
+			} else if tc.ConfirmationDetails.FileName != "" {
+				path = tc.ConfirmationDetails.FileName
+			}
+			e := c.event(kernel.EventFileEdit)
+			e.Edit = &kernel.FileEdit{Path: path, Diff: tc.ConfirmationDetails.FileDiff}
+			events = append(events, e)
+		case editTools[tc.Request.Name] && call.Status == "completed" && path != "":
+			e := c.event(kernel.EventFileEdit)
+			e.Edit = &kernel.FileEdit{Path: path, Applied: true}
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// toolStatus maps Gemini CLI tool call states to the session model
+func toolStatus(status string) string {
+	switch status {
+	case "success":
+		return "completed"
+	case "error":
+		return "failed"
+	case "cancelled":
+		return kernel.ToolCancelled
+	case "awaiting_approval":
+		return kernel.ToolAwaitingApproval
+	case "executing":
+		return kernel.ToolRunning
+	}
+	return "pending"
+}
+
+func stringArg(args map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := args[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
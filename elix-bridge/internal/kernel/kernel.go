@@ -0,0 +1,170 @@
+// Package kernel provides typed adapters for the AI kernels for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package kernel
+
+import (
+	"encoding/json"
+	"errors"
+
+	"echohelix/bridge/internal/session"
+)
+
+// EventType identifies a bridge event
+type EventType string
+
+const (
+	// EventMessageDelta is a streamed piece of the assistant's reply
+	EventMessageDelta EventType = "message.delta"
+	// EventMessage is a complete assistant reply from a kernel that
+	// does not stream
+	EventMessage EventType = "message"
+	// EventThought is the kernel's reasoning, shown apart from the reply
+	EventThought EventType = "thought"
+	// EventToolCall is a new tool call or a change in its status
+	EventToolCall EventType = "tool_call"
+	// EventFileEdit is an edit the kernel made or proposes to a file
+	EventFileEdit EventType = "file_edit"
+	// EventCommit is a git commit the kernel created
+	EventCommit EventType = "commit"
+	// EventOutput is informational kernel output, not part of the reply
+	EventOutput EventType = "output"
+	// EventError is an error reported by the kernel
+	EventError EventType = "error"
+	// EventDone ends a turn
+	EventDone EventType = "done"
+	// EventRaw carries a frame the adapter did not recognize
+	EventRaw EventType = "raw"
+)
+
+// Event is kernel output normalized to the bridge's model
+type Event struct {
+	Type     EventType         `json:"type"`
+	Kernel   string            `json:"kernel"`
+	Text     string            `json:"text,omitempty"`
+	Level    string            `json:"level,omitempty"` // output: info, warning
+	ToolCall *session.ToolCall `json:"tool_call,omitempty"`
+	Edit     *FileEdit         `json:"edit,omitempty"`
+	Commit   *Commit           `json:"commit,omitempty"`
+	Usage    *Usage            `json:"usage,omitempty"`
+	Raw      json.RawMessage   `json:"raw,omitempty"`
+}
+
+// FileEdit is a change to one file. Diff holds removed lines prefixed
+// with "-" and added lines with "+".
+type FileEdit struct {
+	Path    string `json:"path"`
+	Diff    string `json:"diff,omitempty"`
+	Applied bool   `json:"applied"`
+}
+
+// Commit is a git commit made by the kernel
+type Commit struct {
+	Hash    string `json:"hash"`
+	Message string `json:"message"`
+}
+
+// Usage is the token and cost accounting of a turn
+type Usage struct {
+	InputTokens  int     `json:"input_tokens,omitempty"`
+	OutputTokens int     `json:"output_tokens,omitempty"`
+	Cost         float64 `json:"cost,omitempty"`
+}
+
+// Request methods understood by every adapter
+const (
+	MethodChat      = "chat"
+	MethodCommand   = "command"
+	MethodInterrupt = "interrupt"
+	// MethodApprove answers a tool call awaiting approval
+	MethodApprove = "approve"
+)
+
+// Outcomes of MethodApprove
+const (
+	OutcomeProceedOnce   = "proceed_once"
+	OutcomeProceedAlways = "proceed_always"
+	OutcomeCancel        = "cancel"
+)
+
+// Tool call statuses beyond the session model's pending, completed and
+// failed
+const (
+	ToolAwaitingApproval = "awaiting_approval"
+	ToolRunning          = "running"
+	ToolCancelled        = "cancelled"
+)
+
+// Request is a client message in the bridge's format, encoded by the
+// adapter into the kernel's own protocol
+type Request struct {
+	Method  string   `json:"method"`
+	Text    string   `json:"text,omitempty"`
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// Files are added to the kernel's context before the message
+	Files []string `json:"files,omitempty"`
+	// Images are IDs of images uploaded to the session; the bridge fits
+	// them to the kernel's limits and adds them to Files
+	Images []string `json:"images,omitempty"`
+	// CallID and Outcome answer an approval request
+	CallID  string `json:"call_id,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+}
+
+// ErrUnsupported is returned by Encode for methods a kernel lacks
+var ErrUnsupported = errors.New("method not supported by this kernel")
+
+// Adapter describes how to talk to one kernel
+type Adapter interface {
+	// Name is the kernel name used by the process manager
+	Name() string
+	// URL is the WebSocket endpoint of the kernel listening on port
+	URL(port int) string
+	// NewCodec returns the translator for one connection; codecs keep
+	// state across frames, such as a partially streamed edit
+	NewCodec() Codec
+	// Capabilities describes what the kernel supports
+	Capabilities() Capabilities
+}
+
+// Capabilities tells clients which features a kernel supports, so they
+// can enable or hide the matching UI
+type Capabilities struct {
+	// Streaming kernels send the reply as message.delta events
+	Streaming bool `json:"streaming"`
+	// ToolCalls kernels report their tool calls as tool_call events
+	ToolCalls bool `json:"tool_calls"`
+	// Approvals kernels wait for the approve method before running
+	// some tool calls
+	Approvals bool `json:"approvals"`
+	// ImageInput kernels accept image paths in a chat's files
+	ImageInput bool `json:"image_input"`
+	// MaxImageDimension and MaxImageBytes bound attached images; larger
+	// ones are downscaled and compressed before they are sent. Zero
+	// means the bridge's defaults.
+	MaxImageDimension int `json:"max_image_dimension,omitempty"`
+	MaxImageBytes     int `json:"max_image_bytes,omitempty"`
+	// Interrupt kernels can stop a running turn
+	Interrupt bool `json:"interrupt"`
+	// MultiFileEdits kernels can change several files in one turn
+	MultiFileEdits bool `json:"multi_file_edits"`
+	// Thoughts kernels send their reasoning as thought events
+	Thoughts bool `json:"thoughts"`
+	// Commits kernels commit their edits to git
+	Commits bool `json:"commits"`
+	// Methods are the request methods the adapter encodes
+	Methods []string `json:"methods"`
+	// Commands are the names accepted by the command method
+	Commands []string `json:"commands,omitempty"`
+}
+
+// Codec translates between bridge requests and events and the frames of
+// one kernel connection
+type Codec interface {
+	// Encode turns a request into the frames to send to the kernel
+	Encode(req Request) ([][]byte, error)
+	// Decode turns one kernel frame into events
+	Decode(frame []byte) []Event
+}
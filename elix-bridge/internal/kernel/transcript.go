@@ -0,0 +1,108 @@
+package kernel
+
+import (
+	"strings"
+
+	"echohelix/bridge/internal/session"
+)
+
+// Transcript assembles the events of a turn into a session message
+type Transcript struct {
+	text  strings.Builder
+	calls []session.ToolCall
+	index map[string]int // 工具调用 ID -> calls 下标，状态更新时原地替换
+	usage Usage
+	busy  bool
+}
+
+// NewTranscript creates an empty transcript
+func NewTranscript() *Transcript {
+	return &Transcript{index: make(map[string]int)}
+}
+
+// Observe adds ev to the current turn. When ev ends the turn it returns
+// the assistant message and true, and starts a new turn.
+func (t *Transcript) Observe(ev Event) (session.Message, bool) {
+	switch ev.Type {
+	case EventMessageDelta:
+		t.text.WriteString(ev.Text)
+	case EventMessage:
+		// 不流式的内核一条消息就是一轮
+		t.text.WriteString(ev.Text)
+		return t.flush(), true
+	case EventToolCall:
+		if ev.ToolCall != nil {
+			t.addCall(*ev.ToolCall)
+		}
+	case EventFileEdit:
+		if ev.Edit != nil {
+			status := "pending"
+			if ev.Edit.Applied {
+				status = "completed"
+			}
+			args := map[string]interface{}{"path": ev.Edit.Path}
+			if ev.Edit.Diff != "" {
+				args["diff"] = ev.Edit.Diff
+			}
+			t.addCall(session.ToolCall{ID: "edit:" + ev.Edit.Path, Name: "edit_file", Arguments: args, Status: status})
+		}
+	case EventCommit:
+		if ev.Commit != nil {
+			t.addCall(session.ToolCall{
+				ID:        "commit:" + ev.Commit.Hash,
+				Name:      "git_commit",
+				Arguments: map[string]interface{}{"hash": ev.Commit.Hash, "message": ev.Commit.Message},
+				Status:    "completed",
+			})
+		}
+	case EventDone:
+		if ev.Usage != nil {
+			t.usage = *ev.Usage
+		}
+		busy := t.busy
+		return t.flush(), busy
+	default:
+		return session.Message{}, false
+	}
+	t.busy = true
+	return session.Message{}, false
+}
+
+func (t *Transcript) addCall(call session.ToolCall) {
+	if call.ID != "" {
+		if i, ok := t.index[call.ID]; ok {
+			// 更新只带状态或部分参数时，保留先前的参数
+			for k, v := range t.calls[i].Arguments {
+				if call.Arguments == nil {
+					call.Arguments = make(map[string]interface{})
+				}
+				if _, ok := call.Arguments[k]; !ok {
+					call.Arguments[k] = v
+				}
+			}
+			if call.Name == "" {
+				call.Name = t.calls[i].Name
+			}
+			t.calls[i] = call
+			return
+		}
+		t.index[call.ID] = len(t.calls)
+	}
+	t.calls = append(t.calls, call)
+}
+
+// flush returns the turn as a message and resets the transcript
+func (t *Transcript) flush() session.Message {
+	msg := session.Message{
+		Role:       "assistant",
+		Content:    t.text.String(),
+		TokenCount: t.usage.InputTokens + t.usage.OutputTokens,
+		ToolCalls:  t.calls,
+	}
+	t.text.Reset()
+	t.calls = nil
+	t.index = make(map[string]int)
+	t.usage = Usage{}
+	t.busy = false
+	return msg
+}
@@ -0,0 +1,139 @@
+// Package lifecycle provides background task management for EchoHelix Bridge.
+//
+// Cleanup loops, reapers and schedulers run under one Manager owned by
+// whoever starts the bridge. They share a context that Stop cancels, and
+// Stop waits for them to return, so the bridge can be started and shut
+// down repeatedly in one process (for tests or when embedded) without
+// leaking goroutines. As with an errgroup, a task that fails stops the
+// others.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Manager runs background tasks until stopped
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	err     error
+	running map[string]int
+}
+
+// New creates a manager whose tasks stop when parent is done or Stop is
+// called
+func New(parent context.Context) *Manager {
+	ctx, cancel := context.WithCancel(parent)
+	return &Manager{ctx: ctx, cancel: cancel, running: make(map[string]int)}
+}
+
+// Context is cancelled when the manager stops
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go runs fn until it returns. fn must return once ctx is done. An error
+// other than the context's own stops every task; a panic is recovered
+// and treated as an error.
+func (m *Manager) Go(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	m.running[name]++
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() {
+			m.mu.Lock()
+			if m.running[name]--; m.running[name] == 0 {
+				delete(m.running, name)
+			}
+			m.mu.Unlock()
+		}()
+		defer func() {
+			if p := recover(); p != nil {
+				m.fail(name, fmt.Errorf("panic: %v", p))
+			}
+		}()
+
+		if err := fn(m.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			m.fail(name, err)
+		}
+	}()
+}
+
+// Every runs fn every interval until the manager stops
+func (m *Manager) Every(name string, interval time.Duration, fn func(ctx context.Context)) {
+	m.Go(name, func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn(ctx)
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+}
+
+// fail records the first task error and stops the rest
+func (m *Manager) fail(name string, err error) {
+	log.Error().Err(err).Str("task", name).Msg("Background task failed")
+	m.mu.Lock()
+	if m.err == nil {
+		m.err = fmt.Errorf("%s: %w", name, err)
+	}
+	m.mu.Unlock()
+	m.cancel()
+}
+
+// Err returns the error of the first failed task
+func (m *Manager) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Running returns the names of the tasks still running
+func (m *Manager) Running() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.running))
+	for name := range m.running {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Stop cancels every task and waits for them to return, at most until
+// ctx is done. It returns the first task error, or ctx's error when tasks
+// were still running.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.cancel()
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return m.Err()
+	case <-ctx.Done():
+		log.Warn().Strs("tasks", m.Running()).Msg("Background tasks did not stop in time")
+		return ctx.Err()
+	}
+}
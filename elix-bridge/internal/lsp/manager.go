@@ -0,0 +1,306 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Language is a language the bridge can run a server for
+type Language struct {
+	Name       string   `json:"name"`
+	Extensions []string `json:"extensions"`
+	Command    []string `json:"command"`
+}
+
+// DefaultLanguages are the built-in language servers
+var DefaultLanguages = []Language{
+	{Name: "go", Extensions: []string{".go"}, Command: []string{"gopls"}},
+	{Name: "typescript", Extensions: []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"}, Command: []string{"typescript-language-server", "--stdio"}},
+	{Name: "python", Extensions: []string{".py", ".pyi"}, Command: []string{"pyright-langserver", "--stdio"}},
+}
+
+// ErrUnsupported is returned for files no configured server handles
+var ErrUnsupported = errors.New("no language server for this file type")
+
+// ServerInfo describes a running language server
+type ServerInfo struct {
+	Language  string    `json:"language"`
+	Root      string    `json:"root"`
+	Command   []string  `json:"command"`
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+	OpenFiles int       `json:"open_files"`
+}
+
+// DiagnosticsEvent is published when a server reports diagnostics
+type DiagnosticsEvent struct {
+	Path        string       `json:"path"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Manager starts language servers on demand, one per language and
+// workspace root, and translates file paths to LSP requests
+type Manager struct {
+	languages []Language
+
+	mu      sync.Mutex
+	servers map[string]*server
+	subs    map[chan DiagnosticsEvent]string // 订阅者 -> 工作区根目录
+}
+
+// NewManager creates a manager for languages
+func NewManager(languages []Language) *Manager {
+	return &Manager{
+		languages: languages,
+		servers:   make(map[string]*server),
+		subs:      make(map[chan DiagnosticsEvent]string),
+	}
+}
+
+// languageFor returns the language handling path
+func (m *Manager) languageFor(path string) (Language, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, lang := range m.languages {
+		for _, e := range lang.Extensions {
+			if e == ext {
+				return lang, true
+			}
+		}
+	}
+	return Language{}, false
+}
+
+// languageID returns the LSP language identifier for a file
+func languageID(language, path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tsx":
+		return "typescriptreact"
+	case ".js", ".mjs", ".cjs":
+		return "javascript"
+	case ".jsx":
+		return "javascriptreact"
+	}
+	return language
+}
+
+// server returns the running server for path under root, starting it
+// when needed
+func (m *Manager) server(ctx context.Context, root, path string) (*server, error) {
+	lang, ok := m.languageFor(path)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, filepath.Ext(path))
+	}
+	return m.serverFor(ctx, root, lang)
+}
+
+func (m *Manager) serverFor(ctx context.Context, root string, lang Language) (*server, error) {
+	key := lang.Name + "\x00" + root
+
+	m.mu.Lock()
+	s := m.servers[key]
+	m.mu.Unlock()
+	if s != nil && s.alive() {
+		return s, nil
+	}
+
+	// 启动较慢（gopls 需要加载模块），在锁外进行
+	s, err := startServer(ctx, lang.Name, root, lang.Command, func(uri string, diags []Diagnostic) {
+		m.publish(root, DiagnosticsEvent{Path: relPath(root, uriToPath(uri)), Diagnostics: diags})
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("component", "lsp").Str("language", lang.Name).Str("root", root).Msg("Language server started")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing := m.servers[key]; existing != nil && existing.alive() {
+		// 并发请求已经启动了同一个服务器
+		go s.shutdown()
+		return existing, nil
+	}
+	m.servers[key] = s
+	return s, nil
+}
+
+// Hover returns hover information at a position in path (relative to root)
+func (m *Manager) Hover(ctx context.Context, root, path string, pos Position) (*Hover, error) {
+	s, uri, err := m.open(ctx, root, path)
+	if err != nil {
+		return nil, err
+	}
+	var result *struct {
+		Contents json.RawMessage `json:"contents"`
+		Range    *Range          `json:"range"`
+	}
+	if err := s.conn.call(ctx, "textDocument/hover", positionParams(uri, pos), &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
+	}
+	return &Hover{Contents: hoverText(result.Contents), Range: result.Range}, nil
+}
+
+// Definition returns the locations defining the symbol at a position
+func (m *Manager) Definition(ctx context.Context, root, path string, pos Position) ([]Location, error) {
+	s, uri, err := m.open(ctx, root, path)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := s.conn.call(ctx, "textDocument/definition", positionParams(uri, pos), &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(root, raw), nil
+}
+
+// Diagnostics returns the diagnostics for path. Servers report them
+// asynchronously, so it waits up to wait for the first report.
+func (m *Manager) Diagnostics(ctx context.Context, root, path string, wait time.Duration) ([]Diagnostic, error) {
+	s, uri, err := m.open(ctx, root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		if diags, ok := s.fileDiagnostics(uri); ok {
+			return diags, nil
+		}
+		if time.Now().After(deadline) {
+			return []Diagnostic{}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// open syncs path with its language server
+func (m *Manager) open(ctx context.Context, root, path string) (*server, string, error) {
+	full := filepath.Join(root, path)
+	s, err := m.server(ctx, root, full)
+	if err != nil {
+		return nil, "", err
+	}
+	uri, err := s.sync(full)
+	if err != nil {
+		return nil, "", err
+	}
+	return s, uri, nil
+}
+
+// Subscribe returns a channel of diagnostics reported for files under
+// root, and a function to stop the subscription
+func (m *Manager) Subscribe(root string) (<-chan DiagnosticsEvent, func()) {
+	ch := make(chan DiagnosticsEvent, 32)
+	m.mu.Lock()
+	m.subs[ch] = root
+	m.mu.Unlock()
+	return ch, func() {
+		m.mu.Lock()
+		delete(m.subs, ch)
+		m.mu.Unlock()
+	}
+}
+
+func (m *Manager) publish(root string, event DiagnosticsEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch, subRoot := range m.subs {
+		if subRoot != root {
+			continue
+		}
+		select {
+		case ch <- event:
+		default: // 订阅者过慢时丢弃
+		}
+	}
+}
+
+// Servers describes the running servers
+func (m *Manager) Servers() []ServerInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]ServerInfo, 0, len(m.servers))
+	for key, s := range m.servers {
+		if !s.alive() {
+			delete(m.servers, key)
+			continue
+		}
+		s.mu.Lock()
+		open := len(s.docs)
+		s.mu.Unlock()
+		infos = append(infos, ServerInfo{
+			Language:  s.language,
+			Root:      s.root,
+			Command:   s.command,
+			PID:       s.cmd.Process.Pid,
+			StartedAt: s.started,
+			OpenFiles: open,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Language < infos[j].Language })
+	return infos
+}
+
+// Close shuts down every server
+func (m *Manager) Close() {
+	m.mu.Lock()
+	servers := m.servers
+	m.servers = make(map[string]*server)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s *server) {
+			defer wg.Done()
+			s.shutdown()
+		}(s)
+	}
+	wg.Wait()
+}
+
+func positionParams(uri string, pos Position) textDocumentPosition {
+	var p textDocumentPosition
+	p.TextDocument.URI = uri
+	p.Position = pos
+	return p
+}
+
+// relPath returns path relative to root, or path itself when outside
+func relPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// DocumentSymbols returns the symbols of path as reported by its server
+func (m *Manager) DocumentSymbols(ctx context.Context, root, path string) ([]DocumentSymbol, error) {
+	s, uri, err := m.open(ctx, root, path)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]interface{}{"textDocument": map[string]string{"uri": uri}}
+	var raw json.RawMessage
+	if err := s.conn.call(ctx, "textDocument/documentSymbol", params, &raw); err != nil {
+		return nil, err
+	}
+	return parseDocumentSymbols(raw), nil
+}
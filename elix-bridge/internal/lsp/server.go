@@ -0,0 +1,224 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// document tracks a file opened on the language server
+type document struct {
+	version int
+	modTime time.Time
+	size    int64
+}
+
+// server is one running language server for a language and root
+type server struct {
+	language string
+	root     string
+	command  []string
+	cmd      *exec.Cmd
+	conn     *conn
+	started  time.Time
+
+	mu          sync.Mutex
+	docs        map[string]*document
+	diagnostics map[string][]Diagnostic
+	onDiag      func(uri string, diags []Diagnostic)
+}
+
+// startServer launches the language server and initializes it
+func startServer(ctx context.Context, language, root string, command []string, onDiag func(string, []Diagnostic)) (*server, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command[0], err)
+	}
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Debug().Str("component", "lsp").Str("language", language).Msg(scanner.Text())
+		}
+	}()
+
+	s := &server{
+		language:    language,
+		root:        root,
+		command:     command,
+		cmd:         cmd,
+		started:     time.Now(),
+		docs:        make(map[string]*document),
+		diagnostics: make(map[string][]Diagnostic),
+		onDiag:      onDiag,
+	}
+	s.conn = newConn(stdout, stdin)
+	s.conn.onNotify = s.handleNotify
+	s.conn.onRequest = s.handleRequest
+	go func() {
+		<-s.conn.done
+		cmd.Wait()
+	}()
+
+	rootURI := pathToURI(root)
+	params := map[string]interface{}{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": root},
+		},
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"hover":              map[string]interface{}{"contentFormat": []string{"markdown", "plaintext"}},
+				"definition":         map[string]interface{}{"linkSupport": false},
+				"publishDiagnostics": map[string]interface{}{},
+				"documentSymbol":     map[string]interface{}{"hierarchicalDocumentSymbolSupport": true},
+			},
+			"workspace": map[string]interface{}{
+				"workspaceFolders": true,
+				"configuration":    true,
+				"symbol":           map[string]interface{}{},
+			},
+		},
+	}
+	if err := s.conn.call(ctx, "initialize", params, nil); err != nil {
+		s.kill()
+		return nil, fmt.Errorf("initialize %s: %w", command[0], err)
+	}
+	if err := s.conn.notify("initialized", struct{}{}); err != nil {
+		s.kill()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *server) handleNotify(method string, params json.RawMessage) {
+	if method != "textDocument/publishDiagnostics" {
+		return
+	}
+	var p struct {
+		URI         string       `json:"uri"`
+		Diagnostics []Diagnostic `json:"diagnostics"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+	s.mu.Lock()
+	s.diagnostics[p.URI] = p.Diagnostics
+	onDiag := s.onDiag
+	s.mu.Unlock()
+	if onDiag != nil {
+		onDiag(p.URI, p.Diagnostics)
+	}
+}
+
+// handleRequest answers the requests servers commonly send during
+// startup with neutral defaults
+func (s *server) handleRequest(method string, params json.RawMessage) interface{} {
+	if method == "workspace/configuration" {
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(params, &p)
+		return make([]interface{}, len(p.Items))
+	}
+	return nil
+}
+
+// sync opens the file on the server, or sends its new content when it
+// changed on disk since it was last sent
+func (s *server) sync(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	uri := pathToURI(path)
+
+	s.mu.Lock()
+	doc := s.docs[uri]
+	unchanged := doc != nil && doc.modTime.Equal(info.ModTime()) && doc.size == info.Size()
+	s.mu.Unlock()
+	if unchanged {
+		return uri, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc == nil {
+		s.docs[uri] = &document{version: 1, modTime: info.ModTime(), size: info.Size()}
+		return uri, s.conn.notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"uri":        uri,
+				"languageId": languageID(s.language, path),
+				"version":    1,
+				"text":       string(content),
+			},
+		})
+	}
+	doc.version++
+	doc.modTime, doc.size = info.ModTime(), info.Size()
+	return uri, s.conn.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": doc.version},
+		"contentChanges": []map[string]string{{"text": string(content)}},
+	})
+}
+
+func (s *server) fileDiagnostics(uri string) ([]Diagnostic, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	diags, ok := s.diagnostics[uri]
+	return diags, ok
+}
+
+func (s *server) alive() bool {
+	select {
+	case <-s.conn.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// shutdown asks the server to exit, killing it if it does not
+func (s *server) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.conn.call(ctx, "shutdown", nil, nil); err == nil {
+		s.conn.notify("exit", nil)
+	}
+	select {
+	case <-s.conn.done:
+	case <-ctx.Done():
+		s.kill()
+	}
+}
+
+func (s *server) kill() {
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,184 @@
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Position is a zero-based line and UTF-16 character offset
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span between two positions
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a file. Path is relative to the workspace root
+// when the file is inside it.
+type Location struct {
+	URI   string `json:"uri,omitempty"`
+	Path  string `json:"path"`
+	Range Range  `json:"range"`
+}
+
+// Diagnostic is an error, warning, or hint reported for a file
+type Diagnostic struct {
+	Range    Range       `json:"range"`
+	Severity int         `json:"severity,omitempty"` // 1 错误 2 警告 3 信息 4 提示
+	Code     interface{} `json:"code,omitempty"`
+	Source   string      `json:"source,omitempty"`
+	Message  string      `json:"message"`
+}
+
+// Hover is hover information rendered as markdown or plain text
+type Hover struct {
+	Contents string `json:"contents"`
+	Range    *Range `json:"range,omitempty"`
+}
+
+// textDocumentPosition is the params of position-based requests
+type textDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position Position `json:"position"`
+}
+
+// pathToURI converts an absolute path to a file URI
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows 盘符路径
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// uriToPath converts a file URI to an absolute path
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:] // /C:/... -> C:/...
+	}
+	return filepath.FromSlash(path)
+}
+
+// hoverText flattens the several shapes of Hover.contents to a string
+func hoverText(raw json.RawMessage) string {
+	var markup struct {
+		Kind     string `json:"kind"`
+		Value    string `json:"value"`
+		Language string `json:"language"`
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	if json.Unmarshal(raw, &markup) == nil && (markup.Kind != "" || markup.Language != "") {
+		if markup.Language != "" {
+			return "```" + markup.Language + "\n" + markup.Value + "\n```"
+		}
+		return markup.Value
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) == nil {
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			if text := hoverText(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// parseLocations accepts Location, []Location, and []LocationLink
+func parseLocations(root string, raw json.RawMessage) []Location {
+	type wire struct {
+		URI                  string `json:"uri"`
+		Range                *Range `json:"range"`
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange *Range `json:"targetSelectionRange"`
+	}
+	var list []wire
+	if err := json.Unmarshal(raw, &list); err != nil {
+		var single wire
+		if json.Unmarshal(raw, &single) != nil || (single.URI == "" && single.TargetURI == "") {
+			return []Location{}
+		}
+		list = []wire{single}
+	}
+
+	locations := make([]Location, 0, len(list))
+	for _, w := range list {
+		loc := Location{URI: w.URI}
+		if w.Range != nil {
+			loc.Range = *w.Range
+		}
+		if w.TargetURI != "" {
+			loc.URI = w.TargetURI
+			if w.TargetSelectionRange != nil {
+				loc.Range = *w.TargetSelectionRange
+			}
+		}
+		loc.Path = relPath(root, uriToPath(loc.URI))
+		locations = append(locations, loc)
+	}
+	return locations
+}
+
+// DocumentSymbol is a symbol in a file, nested when the server supports
+// hierarchical symbols
+type DocumentSymbol struct {
+	Name          string           `json:"name"`
+	Detail        string           `json:"detail,omitempty"`
+	Kind          int              `json:"kind"`
+	ContainerName string           `json:"containerName,omitempty"`
+	Range         Range            `json:"range"`
+	Children      []DocumentSymbol `json:"children,omitempty"`
+}
+
+// SymbolKindName returns the lowercase name of an LSP SymbolKind
+func SymbolKindName(kind int) string {
+	names := []string{"", "file", "module", "namespace", "package", "class", "method", "property",
+		"field", "constructor", "enum", "interface", "function", "variable", "constant", "string",
+		"number", "boolean", "array", "object", "key", "null", "enummember", "struct", "event",
+		"operator", "typeparameter"}
+	if kind > 0 && kind < len(names) {
+		return names[kind]
+	}
+	return "symbol"
+}
+
+// parseDocumentSymbols accepts both DocumentSymbol[] and the older flat
+// SymbolInformation[]
+func parseDocumentSymbols(raw json.RawMessage) []DocumentSymbol {
+	var items []struct {
+		DocumentSymbol
+		Location *struct {
+			Range Range `json:"range"`
+		} `json:"location"`
+	}
+	if json.Unmarshal(raw, &items) != nil {
+		return []DocumentSymbol{}
+	}
+	symbols := make([]DocumentSymbol, 0, len(items))
+	for _, item := range items {
+		s := item.DocumentSymbol
+		if item.Location != nil {
+			s.Range = item.Location.Range
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols
+}
@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ServerConfig describes an external MCP server: either a command run as
+// a subprocess speaking stdio, or a URL speaking streamable HTTP
+type ServerConfig struct {
+	Command  string            `json:"command,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Dir      string            `json:"cwd,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Disabled bool              `json:"disabled,omitempty"`
+}
+
+// transport sends JSON-RPC messages to a server
+type transport interface {
+	call(ctx context.Context, req *Request) (*Response, error)
+	notify(ctx context.Context, req *Request) error
+	close() error
+}
+
+// Client is a connection to an external MCP server
+type Client struct {
+	transport  transport
+	nextID     int64
+	ServerInfo Implementation
+	Version    string
+}
+
+// Connect starts or dials the server and performs the initialize handshake
+func Connect(ctx context.Context, config ServerConfig) (*Client, error) {
+	var t transport
+	var err error
+	switch {
+	case config.URL != "":
+		t = newHTTPTransport(config.URL, config.Headers)
+	case config.Command != "":
+		t, err = newStdioTransport(config)
+	default:
+		return nil, errors.New("server needs a command or url")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{transport: t}
+	var result InitializeResult
+	err = c.request(ctx, "initialize", InitializeParams{
+		ProtocolVersion: ProtocolVersions[0],
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      Implementation{Name: "echohelix-bridge", Version: "1"},
+	}, &result)
+	if err != nil {
+		t.close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	c.ServerInfo = result.ServerInfo
+	c.Version = result.ProtocolVersion
+	if ht, ok := t.(*httpTransport); ok {
+		ht.version = result.ProtocolVersion
+	}
+
+	if err := t.notify(ctx, &Request{JSONRPC: "2.0", Method: "notifications/initialized"}); err != nil {
+		t.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// ListTools returns every tool the server offers, following pagination
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var result struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.request(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool invokes a tool
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (*CallResult, error) {
+	var result CallResult
+	if err := c.request(ctx, "tools/call", CallParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close shuts the connection down
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+func (c *Client) request(ctx context.Context, method string, params, out interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	id := atomic.AddInt64(&c.nextID, 1)
+	resp, err := c.transport.call(ctx, &Request{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(fmt.Sprint(id)),
+		Method:  method,
+		Params:  raw,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	data, err := json.Marshal(resp.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,231 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// toolSeparator joins a server name and tool name in the names the pool
+// exposes, keeping tools from different servers apart
+const toolSeparator = "__"
+
+// configFile is the external server config; "mcpServers" is accepted so
+// files written for other MCP clients can be reused
+type configFile struct {
+	Servers    map[string]ServerConfig `json:"servers"`
+	MCPServers map[string]ServerConfig `json:"mcpServers"`
+}
+
+// ServerStatus describes a configured external server
+type ServerStatus struct {
+	Name      string          `json:"name"`
+	Transport string          `json:"transport"` // "stdio" 或 "http"
+	Connected bool            `json:"connected"`
+	Disabled  bool            `json:"disabled,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Server    *Implementation `json:"server_info,omitempty"`
+	Tools     []Tool          `json:"tools"`
+}
+
+type poolEntry struct {
+	config ServerConfig
+	client *Client
+	tools  []Tool
+	err    error
+}
+
+// Pool connects to the external MCP servers listed in a config file and
+// exposes their tools under "<server>__<tool>" names
+type Pool struct {
+	path string
+
+	mu      sync.RWMutex
+	servers map[string]*poolEntry
+}
+
+// NewPool creates a pool for the servers in path. Call Load to connect.
+func NewPool(path string) *Pool {
+	return &Pool{path: path, servers: make(map[string]*poolEntry)}
+}
+
+// Load reads the config file and (re)connects every server. Servers that
+// fail to connect are kept with their error so they show up in Status.
+func (p *Pool) Load(ctx context.Context) error {
+	configs, err := p.readConfig()
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]*poolEntry, len(configs))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for name, config := range configs {
+		entry := &poolEntry{config: config}
+		entries[name] = entry
+		if config.Disabled {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, entry *poolEntry) {
+			defer wg.Done()
+			client, tools, err := connect(ctx, entry.config)
+			mu.Lock()
+			entry.client, entry.tools, entry.err = client, tools, err
+			mu.Unlock()
+			if err != nil {
+				log.Warn().Str("component", "mcp").Err(err).Str("server", name).Msg("Failed to connect MCP server")
+				return
+			}
+			log.Info().Str("component", "mcp").Str("server", name).Int("tools", len(tools)).Msg("Connected MCP server")
+		}(name, entry)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	old := p.servers
+	p.servers = entries
+	p.mu.Unlock()
+
+	for _, entry := range old {
+		if entry.client != nil {
+			entry.client.Close()
+		}
+	}
+	return nil
+}
+
+func connect(ctx context.Context, config ServerConfig) (*Client, []Tool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	client, err := Connect(ctx, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("tools/list: %w", err)
+	}
+	return client, tools, nil
+}
+
+func (p *Pool) readConfig() (map[string]ServerConfig, error) {
+	data, err := os.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file configFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid MCP server config %s: %w", p.path, err)
+	}
+	configs := file.Servers
+	if configs == nil {
+		configs = file.MCPServers
+	}
+	for name := range configs {
+		if name == "" || strings.Contains(name, toolSeparator) {
+			return nil, fmt.Errorf("invalid MCP server name %q", name)
+		}
+	}
+	return configs, nil
+}
+
+// Tools returns the tools of every connected server under pool names
+func (p *Pool) Tools() []Tool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var tools []Tool
+	for name, entry := range p.servers {
+		for _, t := range entry.tools {
+			t.Name = name + toolSeparator + t.Name
+			t.Description = "[" + name + "] " + t.Description
+			tools = append(tools, t)
+		}
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// Has reports whether name is a pool tool name of a connected server
+func (p *Pool) Has(name string) bool {
+	server, _, ok := strings.Cut(name, toolSeparator)
+	if !ok {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	entry := p.servers[server]
+	return entry != nil && entry.client != nil
+}
+
+// Call invokes a tool by its pool name
+func (p *Pool) Call(ctx context.Context, name string, args json.RawMessage) (*CallResult, error) {
+	server, tool, ok := strings.Cut(name, toolSeparator)
+	if !ok {
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	}
+	p.mu.RLock()
+	entry := p.servers[server]
+	p.mu.RUnlock()
+	if entry == nil || entry.client == nil {
+		return nil, fmt.Errorf("MCP server %s is not connected", server)
+	}
+	return entry.client.CallTool(ctx, tool, args)
+}
+
+// Status describes every configured server
+func (p *Pool) Status() []ServerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	statuses := make([]ServerStatus, 0, len(p.servers))
+	for name, entry := range p.servers {
+		st := ServerStatus{
+			Name:      name,
+			Transport: "stdio",
+			Connected: entry.client != nil,
+			Disabled:  entry.config.Disabled,
+			Tools:     entry.tools,
+		}
+		if entry.config.URL != "" {
+			st.Transport = "http"
+		}
+		if entry.err != nil {
+			st.Error = entry.err.Error()
+		}
+		if entry.client != nil {
+			st.Server = &entry.client.ServerInfo
+		}
+		if st.Tools == nil {
+			st.Tools = []Tool{}
+		}
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Close disconnects every server
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, entry := range p.servers {
+		if entry.client != nil {
+			entry.client.Close()
+		}
+	}
+	p.servers = make(map[string]*poolEntry)
+}
@@ -0,0 +1,211 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ToolHandler runs a tool with its raw JSON arguments
+type ToolHandler func(ctx context.Context, args json.RawMessage) (*CallResult, error)
+
+// registeredTool is a tool with the permission a caller needs to see and
+// call it
+type registeredTool struct {
+	Tool
+	permission string
+	handler    ToolHandler
+}
+
+// Server answers MCP requests with a fixed set of tools. Callers pass the
+// permissions they hold with every request; tools requiring other
+// permissions are hidden and refused.
+type Server struct {
+	info         Implementation
+	instructions string
+
+	mu             sync.RWMutex
+	tools          map[string]registeredTool
+	pool           *Pool
+	poolPermission string
+}
+
+// NewServer creates an MCP server
+func NewServer(info Implementation, instructions string) *Server {
+	return &Server{
+		info:         info,
+		instructions: instructions,
+		tools:        make(map[string]registeredTool),
+	}
+}
+
+// AddTool registers a tool requiring permission ("" for none)
+func (s *Server) AddTool(tool Tool, permission string, handler ToolHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tools[tool.Name] = registeredTool{Tool: tool, permission: permission, handler: handler}
+}
+
+// Attach adds the tools of external servers in pool, requiring
+// permission to see and call them
+func (s *Server) Attach(pool *Pool, permission string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pool = pool
+	s.poolPermission = permission
+}
+
+// Tools returns the tools visible with permissions, sorted by name
+func (s *Server) Tools(permissions []string) []Tool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(s.tools))
+	for _, t := range s.tools {
+		if allowed(t.permission, permissions) {
+			tools = append(tools, t.Tool)
+		}
+	}
+	if s.pool != nil && allowed(s.poolPermission, permissions) {
+		tools = append(tools, s.pool.Tools()...)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// HandleMessage processes a JSON-RPC message or batch. It returns nil
+// when the message held only notifications.
+func (s *Server) HandleMessage(ctx context.Context, body []byte, permissions []string) []byte {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []Request
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return mustMarshal(errorResponse(nil, CodeParseError, "parse error"))
+		}
+		var responses []Response
+		for i := range batch {
+			if resp := s.handle(ctx, &batch[i], permissions); resp != nil {
+				responses = append(responses, *resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return mustMarshal(responses)
+	}
+
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return mustMarshal(errorResponse(nil, CodeParseError, "parse error"))
+	}
+	resp := s.handle(ctx, &req, permissions)
+	if resp == nil {
+		return nil
+	}
+	return mustMarshal(resp)
+}
+
+func (s *Server) handle(ctx context.Context, req *Request, permissions []string) *Response {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, CodeInvalidRequest, "invalid request")
+	}
+
+	result, err := s.dispatch(ctx, req, permissions)
+	if req.IsNotification() {
+		return nil
+	}
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return &Response{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+	return &Response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+func (s *Server) dispatch(ctx context.Context, req *Request, permissions []string) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "invalid initialize params"}
+		}
+		return InitializeResult{
+			ProtocolVersion: negotiateVersion(params.ProtocolVersion),
+			Capabilities: map[string]interface{}{
+				"tools": map[string]interface{}{"listChanged": false},
+			},
+			ServerInfo:   s.info,
+			Instructions: s.instructions,
+		}, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "tools/list":
+		return map[string]interface{}{"tools": s.Tools(permissions)}, nil
+
+	case "tools/call":
+		var params CallParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "invalid tools/call params"}
+		}
+		s.mu.RLock()
+		tool, ok := s.tools[params.Name]
+		pool, poolPermission := s.pool, s.poolPermission
+		s.mu.RUnlock()
+		if !ok && pool != nil && pool.Has(params.Name) {
+			ok = true
+			tool = registeredTool{permission: poolPermission, handler: func(ctx context.Context, args json.RawMessage) (*CallResult, error) {
+				return pool.Call(ctx, params.Name, args)
+			}}
+		}
+		if !ok || !allowed(tool.permission, permissions) {
+			// 无权限的工具与不存在的工具同样处理，不暴露其存在
+			return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", params.Name)}
+		}
+		if len(params.Arguments) == 0 {
+			params.Arguments = json.RawMessage("{}")
+		}
+		result, err := tool.handler(ctx, params.Arguments)
+		if err != nil {
+			return ErrorResult(err.Error()), nil
+		}
+		return result, nil
+	}
+
+	if strings.HasPrefix(req.Method, "notifications/") {
+		return nil, nil
+	}
+	return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// allowed reports whether permissions include the required one
+func allowed(required string, permissions []string) bool {
+	if required == "" {
+		return true
+	}
+	for _, p := range permissions {
+		if p == required {
+			return true
+		}
+	}
+	return false
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Response{JSONRPC: "2.0", ID: id, Error: &Error{Code: code, Message: message}}
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
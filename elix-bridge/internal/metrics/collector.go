@@ -0,0 +1,213 @@
+// Package metrics provides an in-memory time-series collector for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Point is one sample of a series
+type Point struct {
+	Time  time.Time `json:"t"`
+	Value float64   `json:"v"`
+}
+
+// Config configures a collector
+type Config struct {
+	Interval  time.Duration // 采样间隔，默认 10 秒
+	Retention time.Duration // 保留时长，默认 1 小时
+}
+
+// Collector samples counters and gauges at a fixed interval and keeps
+// the most recent samples of each series in ring buffers
+type Collector struct {
+	interval time.Duration
+	size     int
+
+	mu       sync.Mutex
+	counters map[string]*int64
+	gauges   map[string]func() float64
+	totals   map[string]func() float64
+	last     map[string]float64
+	series   map[string]*ring
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// ring is a fixed-size buffer of points, oldest first once full
+type ring struct {
+	points []Point
+	next   int
+	full   bool
+}
+
+func (r *ring) add(p Point) {
+	r.points[r.next] = p
+	r.next = (r.next + 1) % len(r.points)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *ring) list() []Point {
+	if !r.full {
+		return append([]Point(nil), r.points[:r.next]...)
+	}
+	out := make([]Point, 0, len(r.points))
+	out = append(out, r.points[r.next:]...)
+	return append(out, r.points[:r.next]...)
+}
+
+// New creates a collector and starts sampling
+func New(config Config) *Collector {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.Retention <= 0 {
+		config.Retention = time.Hour
+	}
+	size := int(config.Retention / config.Interval)
+	if size < 1 {
+		size = 1
+	}
+
+	c := &Collector{
+		interval: config.Interval,
+		size:     size,
+		counters: make(map[string]*int64),
+		gauges:   make(map[string]func() float64),
+		totals:   make(map[string]func() float64),
+		last:     make(map[string]float64),
+		series:   make(map[string]*ring),
+		stop:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Counter registers a series that records how many times Inc was
+// called during each interval
+func (c *Collector) Counter(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.counters[name]; !ok {
+		c.counters[name] = new(int64)
+		c.series[name] = &ring{points: make([]Point, c.size)}
+	}
+}
+
+// Inc adds one to a counter registered with Counter; unknown names are
+// ignored
+func (c *Collector) Inc(name string) {
+	c.mu.Lock()
+	counter := c.counters[name]
+	c.mu.Unlock()
+	if counter != nil {
+		atomic.AddInt64(counter, 1)
+	}
+}
+
+// Gauge registers a series sampled from fn at each interval
+func (c *Collector) Gauge(name string, fn func() float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gauges[name] = fn
+	c.series[name] = &ring{points: make([]Point, c.size)}
+}
+
+// Total registers a series recording the per-interval increase of a
+// running total read from fn. Decreases (e.g. a deleted session) are
+// recorded as zero.
+func (c *Collector) Total(name string, fn func() float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.totals[name] = fn
+	c.last[name] = fn()
+	c.series[name] = &ring{points: make([]Point, c.size)}
+}
+
+// Interval returns the sampling interval
+func (c *Collector) Interval() time.Duration {
+	return c.interval
+}
+
+// Snapshot returns every series, oldest sample first
+func (c *Collector) Snapshot() map[string][]Point {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[string][]Point, len(c.series))
+	for name, r := range c.series {
+		out[name] = r.list()
+	}
+	return out
+}
+
+// Close stops sampling
+func (c *Collector) Close() {
+	c.once.Do(func() { close(c.stop) })
+}
+
+func (c *Collector) run() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.sample(now)
+		}
+	}
+}
+
+func (c *Collector) sample(now time.Time) {
+	c.mu.Lock()
+	counters := make(map[string]*int64, len(c.counters))
+	for name, counter := range c.counters {
+		counters[name] = counter
+	}
+	gauges := make(map[string]func() float64, len(c.gauges))
+	for name, fn := range c.gauges {
+		gauges[name] = fn
+	}
+	totals := make(map[string]func() float64, len(c.totals))
+	for name, fn := range c.totals {
+		totals[name] = fn
+	}
+	c.mu.Unlock()
+
+	// 回调可能较慢（如读取进程内存），在锁外执行
+	values := make(map[string]float64, len(counters)+len(gauges)+len(totals))
+	for name, counter := range counters {
+		values[name] = float64(atomic.SwapInt64(counter, 0))
+	}
+	for name, fn := range gauges {
+		values[name] = fn()
+	}
+	current := make(map[string]float64, len(totals))
+	for name, fn := range totals {
+		current[name] = fn()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, v := range current {
+		delta := v - c.last[name]
+		if delta < 0 {
+			delta = 0
+		}
+		c.last[name] = v
+		values[name] = delta
+	}
+	for name, v := range values {
+		if r := c.series[name]; r != nil {
+			r.add(Point{Time: now, Value: v})
+		}
+	}
+}
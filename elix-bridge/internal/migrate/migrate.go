@@ -0,0 +1,158 @@
+// Package migrate provides data directory schema versioning and upgrades for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"echohelix/bridge/internal/statefile"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MarkerFile records the schema version of the data directory
+const MarkerFile = "version.json"
+
+// legacyVersion is assumed for data directories written before the
+// marker existed
+const legacyVersion = 1
+
+// ErrNewerSchema is returned when the data directory was upgraded by a
+// newer bridge; running an older one against it could lose data
+var ErrNewerSchema = errors.New("data directory was written by a newer bridge")
+
+// Migration upgrades the data directory from Version-1 to Version. It
+// must be safe to run again after a crash part way through.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(dataDir string) error
+}
+
+// Marker is the contents of MarkerFile
+type Marker struct {
+	Schema        int       `json:"schema"`
+	BridgeVersion string    `json:"bridge_version,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Options configures Run
+type Options struct {
+	// DataDir is the bridge data directory, normally ~/.echohelix
+	DataDir string
+	// BridgeVersion is recorded in the marker
+	BridgeVersion string
+	// Backup is called once before the first migration runs, with a label
+	// for the backup's name; an error aborts the upgrade
+	Backup func(label string) error
+}
+
+// Result describes what Run did
+type Result struct {
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Applied []string `json:"applied,omitempty"`
+}
+
+// CurrentVersion is the schema version this bridge writes
+func CurrentVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Run brings the data directory up to CurrentVersion. A new, empty
+// directory is stamped with the current version without migrating. The
+// marker is rewritten after each step, so an interrupted upgrade resumes
+// from the last completed one.
+func Run(opts Options) (*Result, error) {
+	if err := os.MkdirAll(opts.DataDir, 0755); err != nil {
+		return nil, err
+	}
+	current := CurrentVersion()
+
+	version, err := readVersion(opts.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{From: version, To: current}
+
+	if version > current {
+		return result, fmt.Errorf("%w (schema %d, this bridge supports %d)", ErrNewerSchema, version, current)
+	}
+	if version == current {
+		return result, nil
+	}
+	if version == 0 {
+		// 全新目录，无需迁移
+		result.From = current
+		return result, writeVersion(opts, current)
+	}
+
+	if opts.Backup != nil {
+		if err := opts.Backup(fmt.Sprintf("pre-migrate-v%d", version)); err != nil {
+			return result, fmt.Errorf("pre-migration backup failed: %w", err)
+		}
+	}
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		log.Info().Int("to", m.Version).Str("migration", m.Name).Msg("Migrating data directory")
+		if err := m.Up(opts.DataDir); err != nil {
+			return result, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if err := writeVersion(opts, m.Version); err != nil {
+			return result, err
+		}
+		result.Applied = append(result.Applied, m.Name)
+	}
+	return result, nil
+}
+
+// readVersion returns the schema version of dataDir: the marker's, the
+// legacy version for existing data without one, or 0 for an empty
+// directory
+func readVersion(dataDir string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, MarkerFile))
+	if err == nil {
+		var marker Marker
+		if err := json.Unmarshal(data, &marker); err != nil || marker.Schema <= 0 {
+			return 0, fmt.Errorf("invalid %s, fix or remove it to continue", MarkerFile)
+		}
+		return marker.Schema, nil
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		switch e.Name() {
+		case "logs", "bridge.sock":
+			continue // 不含需迁移的数据
+		}
+		return legacyVersion, nil
+	}
+	return 0, nil
+}
+
+func writeVersion(opts Options, version int) error {
+	data, err := json.MarshalIndent(Marker{
+		Schema:        version,
+		BridgeVersion: opts.BridgeVersion,
+		UpdatedAt:     time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statefile.WriteFileAtomic(filepath.Join(opts.DataDir, MarkerFile), data, 0644)
+}
@@ -0,0 +1,39 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"echohelix/bridge/internal/statefile"
+)
+
+// migrations are applied in order; append new ones with the next
+// version. Version 1 is the layout before versioning was introduced.
+var migrations = []Migration{
+	{Version: 1, Name: "initial"},
+	{Version: 2, Name: "checksummed-state-files", Up: checksumStateFiles},
+}
+
+// checksumStateFiles rewrites auth.json and workspaces.json in the
+// checksummed statefile format
+func checksumStateFiles(dataDir string) error {
+	for name, perm := range map[string]os.FileMode{
+		"auth.json":       0600,
+		"workspaces.json": 0644,
+	} {
+		path := filepath.Join(dataDir, name)
+		var raw json.RawMessage
+		if err := statefile.Read(path, &raw, nil); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if err := statefile.Write(path, raw, perm, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
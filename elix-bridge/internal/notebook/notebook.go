@@ -0,0 +1,402 @@
+// Package notebook provides Jupyter notebook reading and cell editing for
+// EchoHelix Bridge.
+//
+// A notebook is read into cells with their outputs summarized, so a phone
+// or a kernel sees the code and its results without the embedded images
+// and widget state that make .ipynb files megabytes of JSON. Edits change
+// single cells; every field they don't touch is written back as it was
+// read.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package notebook
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Ext is the extension of notebook files
+const Ext = ".ipynb"
+
+// MaxOutputText caps the text kept of each output
+const MaxOutputText = 2000
+
+// Cell types
+const (
+	TypeCode     = "code"
+	TypeMarkdown = "markdown"
+	TypeRaw      = "raw"
+)
+
+// Edit operations
+const (
+	OpReplace      = "replace"
+	OpInsert       = "insert"
+	OpDelete       = "delete"
+	OpClearOutputs = "clear_outputs"
+)
+
+// Error codes
+const (
+	CodeInvalid      = "NOTEBOOK_INVALID"
+	CodeEditInvalid  = "NOTEBOOK_EDIT_INVALID"
+	CodeCellNotFound = "CELL_NOT_FOUND"
+)
+
+// Error is a notebook failure with a stable code
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &Error{Code: CodeInvalid, Message: fmt.Sprintf(format, args...)}
+}
+
+func badEdit(format string, args ...interface{}) error {
+	return &Error{Code: CodeEditInvalid, Message: fmt.Sprintf(format, args...)}
+}
+
+// Notebook is a parsed nbformat 4 notebook. Fields are kept as raw JSON
+// so those the bridge doesn't understand survive a round trip.
+type Notebook struct {
+	fields map[string]json.RawMessage
+	cells  []map[string]json.RawMessage
+	minor  int
+	indent string
+}
+
+// Parse reads a notebook; only nbformat 4 is supported
+func Parse(data []byte) (*Notebook, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, invalid("not a notebook: %v", err)
+	}
+	var major int
+	if err := json.Unmarshal(fields["nbformat"], &major); err != nil || major != 4 {
+		return nil, invalid("unsupported nbformat %s; only version 4 is supported", fields["nbformat"])
+	}
+	nb := &Notebook{fields: fields, indent: detectIndent(data)}
+	json.Unmarshal(fields["nbformat_minor"], &nb.minor)
+	if err := json.Unmarshal(fields["cells"], &nb.cells); err != nil {
+		return nil, invalid("invalid cells: %v", err)
+	}
+	return nb, nil
+}
+
+// detectIndent finds the indentation of the first field, so a notebook
+// is written back as its editor wrote it; Jupyter uses one space
+func detectIndent(data []byte) string {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return " "
+	}
+	line := data[i+1:]
+	n := 0
+	for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+		n++
+	}
+	if n == 0 {
+		return " "
+	}
+	return string(line[:n])
+}
+
+// Marshal writes the notebook as Jupyter does: sorted keys, non-ASCII
+// text unescaped and a final newline
+func (nb *Notebook) Marshal() ([]byte, error) {
+	cells, err := encode(nb.cells, "")
+	if err != nil {
+		return nil, err
+	}
+	nb.fields["cells"] = cells
+	return encode(nb.fields, nb.indent)
+}
+
+// encode marshals v without escaping <, > and &, which are common in
+// code and which Jupyter writes as they are
+func encode(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent != "" {
+		enc.SetIndent("", indent)
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Len is the number of cells
+func (nb *Notebook) Len() int {
+	return len(nb.cells)
+}
+
+// Language is the notebook's programming language, from its language
+// info or kernel spec
+func (nb *Notebook) Language() string {
+	var meta struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+	}
+	json.Unmarshal(nb.fields["metadata"], &meta)
+	if meta.LanguageInfo.Name != "" {
+		return meta.LanguageInfo.Name
+	}
+	return meta.KernelSpec.Language
+}
+
+// Version is the notebook's nbformat, such as "4.5"
+func (nb *Notebook) Version() string {
+	return fmt.Sprintf("4.%d", nb.minor)
+}
+
+// Cell is a cell as clients see it
+type Cell struct {
+	Index          int      `json:"index"`
+	ID             string   `json:"id,omitempty"`
+	Type           string   `json:"cell_type"`
+	Source         string   `json:"source"`
+	ExecutionCount *int     `json:"execution_count,omitempty"`
+	Outputs        []Output `json:"outputs,omitempty"`
+}
+
+// Output summarizes a code cell output. Text is the stream text, the
+// plain text of a result or the error; rich data is listed by MIME type
+// only.
+type Output struct {
+	Type      string   `json:"output_type"`
+	Name      string   `json:"name,omitempty"` // stdout、stderr
+	Text      string   `json:"text,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"`
+	MimeTypes []string `json:"mime_types,omitempty"`
+}
+
+// Cells lists the cells with their outputs summarized
+func (nb *Notebook) Cells() []Cell {
+	cells := make([]Cell, len(nb.cells))
+	for i, raw := range nb.cells {
+		c := Cell{Index: i}
+		json.Unmarshal(raw["id"], &c.ID)
+		json.Unmarshal(raw["cell_type"], &c.Type)
+		c.Source = multiline(raw["source"])
+		json.Unmarshal(raw["execution_count"], &c.ExecutionCount)
+
+		var outputs []map[string]json.RawMessage
+		json.Unmarshal(raw["outputs"], &outputs)
+		for _, o := range outputs {
+			c.Outputs = append(c.Outputs, summarize(o))
+		}
+		cells[i] = c
+	}
+	return cells
+}
+
+func summarize(raw map[string]json.RawMessage) Output {
+	var o Output
+	json.Unmarshal(raw["output_type"], &o.Type)
+	switch o.Type {
+	case "stream":
+		json.Unmarshal(raw["name"], &o.Name)
+		o.Text = multiline(raw["text"])
+	case "error":
+		var ename, evalue string
+		json.Unmarshal(raw["ename"], &ename)
+		json.Unmarshal(raw["evalue"], &evalue)
+		o.Text = ename + ": " + evalue
+	default: // execute_result、display_data
+		var data map[string]json.RawMessage
+		json.Unmarshal(raw["data"], &data)
+		for mime := range data {
+			o.MimeTypes = append(o.MimeTypes, mime)
+		}
+		sort.Strings(o.MimeTypes)
+		o.Text = multiline(data["text/plain"])
+	}
+	if len(o.Text) > MaxOutputText {
+		cut := MaxOutputText
+		for cut > 0 && !utf8.RuneStart(o.Text[cut]) {
+			cut--
+		}
+		o.Text = o.Text[:cut]
+		o.Truncated = true
+	}
+	return o
+}
+
+// multiline reads an nbformat multiline string, stored either as one
+// string or as a list of lines
+func multiline(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var lines []string
+	json.Unmarshal(raw, &lines)
+	return strings.Join(lines, "")
+}
+
+// splitLines stores text as Jupyter does, one string per line keeping
+// its line break
+func splitLines(text string) []string {
+	lines := []string{}
+	for text != "" {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			lines = append(lines, text)
+			break
+		}
+		lines = append(lines, text[:i+1])
+		text = text[i+1:]
+	}
+	return lines
+}
+
+// Edit changes one cell. The cell is found by ID when it is set and by
+// Index otherwise; insert places the new cell at Index, shifting the
+// cell there and those after it.
+type Edit struct {
+	Op    string `json:"op"`
+	Index int    `json:"index"`
+	ID    string `json:"id,omitempty"`
+	// Type is the cell type of an inserted cell, code by default, or the
+	// type a replaced cell changes to
+	Type   string  `json:"cell_type,omitempty"`
+	Source *string `json:"source,omitempty"`
+}
+
+// Apply applies edits in order, each to the notebook as the previous
+// ones left it. On error the notebook may be partly edited and should be
+// discarded.
+func (nb *Notebook) Apply(edits []Edit) error {
+	for i, e := range edits {
+		if err := nb.apply(e); err != nil {
+			return fmt.Errorf("edit %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (nb *Notebook) apply(e Edit) error {
+	if e.Type != "" && e.Type != TypeCode && e.Type != TypeMarkdown && e.Type != TypeRaw {
+		return badEdit("unknown cell type %q", e.Type)
+	}
+
+	if e.Op == OpInsert {
+		if e.Index < 0 || e.Index > len(nb.cells) {
+			return badEdit("insert index %d out of range 0..%d", e.Index, len(nb.cells))
+		}
+		cell := map[string]json.RawMessage{"metadata": json.RawMessage("{}")}
+		if nb.minor >= 5 {
+			cell["id"] = rawString(newID())
+		}
+		typ := e.Type
+		if typ == "" {
+			typ = TypeCode
+		}
+		setType(cell, typ)
+		source := ""
+		if e.Source != nil {
+			source = *e.Source
+		}
+		setSource(cell, source)
+		nb.cells = append(nb.cells, nil)
+		copy(nb.cells[e.Index+1:], nb.cells[e.Index:])
+		nb.cells[e.Index] = cell
+		return nil
+	}
+
+	i, err := nb.find(e)
+	if err != nil {
+		return err
+	}
+	cell := nb.cells[i]
+	switch e.Op {
+	case OpReplace:
+		if e.Source == nil && e.Type == "" {
+			return badEdit("replace needs source or cell_type")
+		}
+		if e.Type != "" {
+			setType(cell, e.Type)
+		}
+		if e.Source != nil {
+			setSource(cell, *e.Source)
+		}
+	case OpDelete:
+		nb.cells = append(nb.cells[:i], nb.cells[i+1:]...)
+	case OpClearOutputs:
+		if _, ok := cell["outputs"]; ok {
+			cell["outputs"] = json.RawMessage("[]")
+			cell["execution_count"] = json.RawMessage("null")
+		}
+	default:
+		return badEdit("unknown op %q", e.Op)
+	}
+	return nil
+}
+
+// find returns the index of the cell an edit targets
+func (nb *Notebook) find(e Edit) (int, error) {
+	if e.ID == "" {
+		if e.Index < 0 || e.Index >= len(nb.cells) {
+			return 0, &Error{Code: CodeCellNotFound, Message: fmt.Sprintf("cell %d not found; the notebook has %d cells", e.Index, len(nb.cells))}
+		}
+		return e.Index, nil
+	}
+	for i, cell := range nb.cells {
+		var id string
+		json.Unmarshal(cell["id"], &id)
+		if id == e.ID {
+			return i, nil
+		}
+	}
+	return 0, &Error{Code: CodeCellNotFound, Message: fmt.Sprintf("cell %q not found", e.ID)}
+}
+
+// setType changes a cell's type, adding the fields code cells have or
+// dropping them
+func setType(cell map[string]json.RawMessage, typ string) {
+	cell["cell_type"] = rawString(typ)
+	if typ == TypeCode {
+		if _, ok := cell["outputs"]; !ok {
+			cell["outputs"] = json.RawMessage("[]")
+			cell["execution_count"] = json.RawMessage("null")
+		}
+		delete(cell, "attachments")
+		return
+	}
+	delete(cell, "outputs")
+	delete(cell, "execution_count")
+}
+
+func setSource(cell map[string]json.RawMessage, source string) {
+	cell["source"], _ = encode(splitLines(source), "")
+}
+
+func rawString(s string) json.RawMessage {
+	data, _ := encode(s, "")
+	return data
+}
+
+// newID generates a cell ID as nbformat 4.5 requires
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,151 @@
+// Package notify provides push notification delivery for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"echohelix/bridge/internal/auth"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Notification categories; devices can opt out per category
+const (
+	CategoryTaskFinished   = "task_finished"
+	CategoryApprovalNeeded = "approval_needed"
+	CategoryKernelCrashed  = "kernel_crashed"
+)
+
+// Categories lists all known categories
+var Categories = []string{CategoryTaskFinished, CategoryApprovalNeeded, CategoryKernelCrashed}
+
+// Notification is a message delivered to paired devices
+type Notification struct {
+	Category string            `json:"category"`
+	Title    string            `json:"title"`
+	Body     string            `json:"body"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+// Target is a device push endpoint
+type Target struct {
+	DeviceID string `json:"device_id"`
+	Platform string `json:"platform"` // "fcm" or "apns"
+	Token    string `json:"token"`
+}
+
+// Sender delivers a notification to a single target
+type Sender interface {
+	Send(ctx context.Context, target Target, n Notification) error
+}
+
+// RelaySender posts notifications to an HTTP relay that holds the FCM/APNs
+// credentials, so the bridge never needs provider secrets itself
+type RelaySender struct {
+	URL    string
+	APIKey string
+	client *http.Client
+}
+
+// NewRelaySender creates a relay sender
+func NewRelaySender(url, apiKey string) *RelaySender {
+	return &RelaySender{
+		URL:    url,
+		APIKey: apiKey,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send implements Sender
+func (r *RelaySender) Send(ctx context.Context, target Target, n Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"target":       target,
+		"notification": n,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.APIKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("relay returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Service fans notifications out to paired devices
+type Service struct {
+	authService *auth.Service
+	sender      func() Sender // 每次发送时解析，以便配置变更即时生效
+}
+
+// NewService creates a notification service. sender may return nil when
+// push delivery is not configured.
+func NewService(authService *auth.Service, sender func() Sender) *Service {
+	return &Service{
+		authService: authService,
+		sender:      sender,
+	}
+}
+
+// Notify delivers n to every active device that has a push token and has not
+// disabled the category. Delivery is asynchronous.
+func (s *Service) Notify(n Notification) {
+	sender := s.sender()
+	if sender == nil {
+		log.Debug().Str("category", n.Category).Msg("Push relay not configured, notification dropped")
+		return
+	}
+
+	for _, target := range s.Targets(n.Category) {
+		go func(target Target) {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			if err := sender.Send(ctx, target, n); err != nil {
+				log.Warn().Err(err).Str("deviceID", target.DeviceID).Msg("Failed to deliver notification")
+			}
+		}(target)
+	}
+}
+
+// Targets returns the push targets subscribed to a category
+func (s *Service) Targets(category string) []Target {
+	var targets []Target
+	for _, token := range s.authService.ListActiveDevices() {
+		if token.PushToken == "" {
+			continue
+		}
+		// 未设置偏好时默认开启
+		if enabled, ok := token.NotifyPrefs[category]; ok && !enabled {
+			continue
+		}
+		targets = append(targets, Target{
+			DeviceID: token.DeviceID,
+			Platform: token.PushPlatform,
+			Token:    token.PushToken,
+		})
+	}
+	return targets
+}
@@ -0,0 +1,263 @@
+// Package plugin provides external hook executables for EchoHelix Bridge.
+//
+// A plugin is a long-running executable listed in plugins.json that
+// subscribes to lifecycle hooks. The bridge writes one JSON object per
+// line to its stdin for every hook event:
+//
+//	{"id": 7, "hook": "on_fs_write", "data": {...}}
+//
+// and reads one JSON line per event back from its stdout:
+//
+//	{"id": 7, "deny": true, "reason": "content contains an AWS key"}
+//
+// A reply without "deny" lets the action through. Anything the plugin
+// writes to stderr goes to the bridge log. Events for one plugin are sent
+// one at a time; plugins run in name order and the first denial wins.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Hooks
+const (
+	// HookRequest runs before every protected API request
+	HookRequest = "on_request"
+	// HookSessionMessage runs before a message is added to a session
+	HookSessionMessage = "on_session_message"
+	// HookFSWrite runs before a file is written or a change is proposed
+	HookFSWrite = "on_fs_write"
+	// HookPairing runs before a pairing code is accepted
+	HookPairing = "on_pairing"
+)
+
+// Hooks lists every hook a plugin can subscribe to
+var Hooks = []string{HookRequest, HookSessionMessage, HookFSWrite, HookPairing}
+
+// defaultTimeout bounds how long a plugin may take to answer
+const defaultTimeout = 5 * time.Second
+
+// Config describes one plugin in plugins.json
+type Config struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Dir     string            `json:"dir,omitempty"`
+	Hooks   []string          `json:"hooks"`
+	// TimeoutMS bounds each answer (default 5000)
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+	// FailClosed denies the action when the plugin fails or times out;
+	// by default such failures are logged and the action proceeds
+	FailClosed bool `json:"fail_closed,omitempty"`
+	Disabled   bool `json:"disabled,omitempty"`
+}
+
+func (c Config) timeout() time.Duration {
+	if c.TimeoutMS > 0 {
+		return time.Duration(c.TimeoutMS) * time.Millisecond
+	}
+	return defaultTimeout
+}
+
+type configFile struct {
+	Plugins map[string]Config `json:"plugins"`
+}
+
+// DeniedError reports an action a plugin refused
+type DeniedError struct {
+	Code   string `json:"code"`
+	Plugin string `json:"plugin"`
+	Hook   string `json:"hook"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (e *DeniedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("blocked by plugin %s", e.Plugin)
+	}
+	return fmt.Sprintf("blocked by plugin %s: %s", e.Plugin, e.Reason)
+}
+
+// Status describes a configured plugin
+type Status struct {
+	Name       string   `json:"name"`
+	Command    string   `json:"command"`
+	Hooks      []string `json:"hooks"`
+	Running    bool     `json:"running"`
+	Disabled   bool     `json:"disabled,omitempty"`
+	FailClosed bool     `json:"fail_closed,omitempty"`
+	Calls      int64    `json:"calls"`
+	Denials    int64    `json:"denials"`
+	Failures   int64    `json:"failures"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Manager runs the plugins listed in a config file
+type Manager struct {
+	path string
+
+	mu      sync.RWMutex
+	plugins map[string]*process
+}
+
+// NewManager creates a manager for the plugins in path. Call Load to
+// start them.
+func NewManager(path string) *Manager {
+	return &Manager{path: path, plugins: make(map[string]*process)}
+}
+
+// Load reads the config file and restarts every plugin. Plugins that fail
+// to start are kept with their error and retried on their next event.
+func (m *Manager) Load() error {
+	configs, err := m.readConfig()
+	if err != nil {
+		return err
+	}
+
+	plugins := make(map[string]*process, len(configs))
+	for name, config := range configs {
+		p := &process{name: name, config: config}
+		plugins[name] = p
+		if config.Disabled {
+			continue
+		}
+		p.mu.Lock()
+		if err := p.startLocked(); err != nil {
+			log.Warn().Str("component", "plugin").Err(err).Str("plugin", name).Msg("Failed to start plugin")
+		} else {
+			log.Info().Str("component", "plugin").Str("plugin", name).Strs("hooks", config.Hooks).Msg("Started plugin")
+		}
+		p.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	old := m.plugins
+	m.plugins = plugins
+	m.mu.Unlock()
+
+	for _, p := range old {
+		p.stop()
+	}
+	return nil
+}
+
+func (m *Manager) readConfig() (map[string]Config, error) {
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file configFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid plugin config %s: %w", m.path, err)
+	}
+	for name, config := range file.Plugins {
+		if name == "" || config.Command == "" {
+			return nil, fmt.Errorf("plugin %q needs a command", name)
+		}
+		for _, hook := range config.Hooks {
+			if !slices.Contains(Hooks, hook) {
+				return nil, fmt.Errorf("plugin %q: unknown hook %q", name, hook)
+			}
+		}
+	}
+	return file.Plugins, nil
+}
+
+// Has reports whether any enabled plugin subscribes to hook, so callers
+// can skip building event data
+func (m *Manager) Has(hook string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, p := range m.plugins {
+		if !p.config.Disabled && slices.Contains(p.config.Hooks, hook) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run sends a hook event to every subscribed plugin in name order and
+// returns a *DeniedError for the first that refuses. A plugin that fails
+// denies only when it is configured fail_closed.
+func (m *Manager) Run(ctx context.Context, hook string, data interface{}) error {
+	if !m.Has(hook) {
+		return nil
+	}
+
+	m.mu.RLock()
+	var subscribed []*process
+	for _, p := range m.plugins {
+		if !p.config.Disabled && slices.Contains(p.config.Hooks, hook) {
+			subscribed = append(subscribed, p)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(subscribed, func(i, j int) bool {
+		return subscribed[i].name < subscribed[j].name
+	})
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	for _, p := range subscribed {
+		reply, err := p.call(ctx, hook, raw)
+		if err != nil {
+			log.Warn().Str("component", "plugin").Err(err).Str("plugin", p.name).Str("hook", hook).Msg("Plugin failed")
+			if p.config.FailClosed {
+				return &DeniedError{Code: "PLUGIN_DENIED", Plugin: p.name, Hook: hook, Reason: "plugin failed: " + err.Error()}
+			}
+			continue
+		}
+		if reply.Deny {
+			log.Info().Str("component", "plugin").Str("plugin", p.name).Str("hook", hook).Str("reason", reply.Reason).Msg("Plugin denied action")
+			return &DeniedError{Code: "PLUGIN_DENIED", Plugin: p.name, Hook: hook, Reason: reply.Reason}
+		}
+	}
+	return nil
+}
+
+// Status lists the configured plugins by name
+func (m *Manager) Status() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]Status, 0, len(m.plugins))
+	for _, p := range m.plugins {
+		list = append(list, p.status())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Close stops every plugin
+func (m *Manager) Close() {
+	m.mu.Lock()
+	plugins := m.plugins
+	m.plugins = make(map[string]*process)
+	m.mu.Unlock()
+
+	for _, p := range plugins {
+		p.stop()
+	}
+}
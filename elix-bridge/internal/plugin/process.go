@@ -0,0 +1,243 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxLine bounds one line a plugin writes
+const maxLine = 1 << 20
+
+// event is one hook event sent to a plugin
+type event struct {
+	ID   uint64          `json:"id"`
+	Hook string          `json:"hook"`
+	Data json.RawMessage `json:"data"`
+}
+
+// reply is a plugin's answer to an event
+type reply struct {
+	ID     uint64 `json:"id"`
+	Deny   bool   `json:"deny"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// process is a running plugin. Calls are serialized by mu; a plugin that
+// exits is restarted on its next event.
+type process struct {
+	name   string
+	config Config
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	replies chan reply
+	exited  chan struct{}
+	nextID  uint64
+	err     error
+
+	calls, denials, failures int64
+}
+
+// startLocked launches the executable. Callers hold p.mu.
+func (p *process) startLocked() error {
+	cmd := exec.Command(p.config.Command, p.config.Args...)
+	cmd.Dir = p.config.Dir
+	cmd.Env = append(os.Environ(), "ECHOHELIX_PLUGIN="+p.name)
+	for k, v := range p.config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		p.err = err
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		p.err = err
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		p.err = err
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		p.err = err
+		return err
+	}
+
+	replies := make(chan reply, 16)
+	exited := make(chan struct{})
+	go p.logStderr(stderr)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), maxLine)
+		for scanner.Scan() {
+			var r reply
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.ID == 0 {
+				log.Debug().Str("component", "plugin").Str("plugin", p.name).Str("line", scanner.Text()).Msg("Ignoring plugin output")
+				continue
+			}
+			// 只有最新一次调用在等待，缓冲满时丢弃
+			select {
+			case replies <- r:
+			default:
+			}
+		}
+		cmd.Wait()
+		close(exited)
+	}()
+
+	p.cmd, p.stdin, p.replies, p.exited, p.err = cmd, stdin, replies, exited, nil
+	return nil
+}
+
+func (p *process) logStderr(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+	for scanner.Scan() {
+		log.Info().Str("component", "plugin").Str("plugin", p.name).Msg(scanner.Text())
+	}
+}
+
+func (p *process) runningLocked() bool {
+	if p.cmd == nil {
+		return false
+	}
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// call sends one event and waits for its reply
+func (p *process) call(ctx context.Context, hook string, data json.RawMessage) (reply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls++
+	r, err := p.callLocked(ctx, hook, data)
+	if err != nil {
+		p.failures++
+		p.err = err
+	} else if r.Deny {
+		p.denials++
+	}
+	return r, err
+}
+
+func (p *process) callLocked(ctx context.Context, hook string, data json.RawMessage) (reply, error) {
+	if !p.runningLocked() {
+		if err := p.startLocked(); err != nil {
+			return reply{}, fmt.Errorf("start: %w", err)
+		}
+	}
+
+	p.nextID++
+	line, err := json.Marshal(event{ID: p.nextID, Hook: hook, Data: data})
+	if err != nil {
+		return reply{}, err
+	}
+	timer := time.NewTimer(p.config.timeout())
+	defer timer.Stop()
+
+	// 不读 stdin 的插件会让管道写满，写入放到协程里以便超时
+	written := make(chan error, 1)
+	go func(stdin io.Writer) {
+		_, err := stdin.Write(append(line, '\n'))
+		written <- err
+	}(p.stdin)
+	select {
+	case err := <-written:
+		if err != nil {
+			return reply{}, fmt.Errorf("write: %w", err)
+		}
+	case <-p.exited:
+		return reply{}, errors.New("plugin exited")
+	case <-timer.C:
+		// 写了一半的事件无法撤回，结束插件，下次调用时重启
+		p.killLocked()
+		return reply{}, fmt.Errorf("event not read within %s", p.config.timeout())
+	case <-ctx.Done():
+		p.killLocked()
+		return reply{}, ctx.Err()
+	}
+
+	for {
+		select {
+		case r := <-p.replies:
+			// 超时调用的迟到回复直接丢弃
+			if r.ID != p.nextID {
+				continue
+			}
+			return r, nil
+		case <-p.exited:
+			return reply{}, errors.New("plugin exited")
+		case <-timer.C:
+			return reply{}, fmt.Errorf("no reply within %s", p.config.timeout())
+		case <-ctx.Done():
+			return reply{}, ctx.Err()
+		}
+	}
+}
+
+func (p *process) status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := Status{
+		Name:       p.name,
+		Command:    p.config.Command,
+		Hooks:      p.config.Hooks,
+		Running:    p.runningLocked(),
+		Disabled:   p.config.Disabled,
+		FailClosed: p.config.FailClosed,
+		Calls:      p.calls,
+		Denials:    p.denials,
+		Failures:   p.failures,
+	}
+	if p.err != nil {
+		st.Error = p.err.Error()
+	}
+	if st.Hooks == nil {
+		st.Hooks = []string{}
+	}
+	return st
+}
+
+// killLocked kills the plugin and waits for it to exit. Callers hold p.mu.
+func (p *process) killLocked() {
+	p.cmd.Process.Kill()
+	<-p.exited
+}
+
+// stop closes stdin so the plugin can exit cleanly, killing it if it
+// does not within two seconds
+func (p *process) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.runningLocked() {
+		return
+	}
+	p.stdin.Close()
+	select {
+	case <-p.exited:
+	case <-time.After(2 * time.Second):
+		p.cmd.Process.Kill()
+		<-p.exited
+	}
+	p.cmd = nil
+}
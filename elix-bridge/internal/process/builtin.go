@@ -0,0 +1,89 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RegisterBuiltin adds a kernel that runs inside the bridge. Start serves
+// the handler newHandler returns on the kernel's port instead of
+// launching a process; Stop closes it, and the handler too when it is an
+// io.Closer.
+func (m *Manager) RegisterBuiltin(kernel string, newHandler func() http.Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.builtins == nil {
+		m.builtins = make(map[string]func() http.Handler)
+	}
+	m.builtins[kernel] = newHandler
+}
+
+// IsBuiltin reports whether kernel runs inside the bridge
+func (m *Manager) IsBuiltin(kernel string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.builtins[kernel]
+	return ok
+}
+
+// closeBuiltin stops serving a built-in kernel. Connections upgraded to
+// WebSocket are not closed by the server, so a handler holding them
+// implements io.Closer.
+func closeBuiltin(srv *http.Server) error {
+	err := srv.Close()
+	if c, ok := srv.Handler.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// startBuiltin serves a built-in kernel on 127.0.0.1:port
+func (m *Manager) startBuiltin(kernel string, port int, newHandler func() http.Handler) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to start %s kernel: %w", kernel, err)
+	}
+	srv := &http.Server{Handler: newHandler(), ReadHeaderTimeout: 10 * time.Second}
+
+	m.mu.Lock()
+	m.cmd = nil
+	m.builtin = srv
+	m.stopping = false
+	m.kernel = kernel
+	m.port = port
+	m.running = true
+	m.startedAt = time.Now()
+	m.mu.Unlock()
+
+	go func() {
+		err := srv.Serve(ln)
+
+		m.mu.Lock()
+		expected := m.stopping || m.builtin != srv
+		if m.builtin == srv {
+			m.running = false
+			m.builtin = nil
+		}
+		callback := m.onExit
+		m.mu.Unlock()
+
+		if expected || errors.Is(err, http.ErrServerClosed) {
+			return
+		}
+		log.Warn().Str("component", "kernel").Err(err).Str("kernel", kernel).Msg("Core exited unexpectedly")
+		if callback != nil {
+			callback(kernel, err)
+		}
+	}()
+
+	log.Info().Str("component", "kernel").Str("kernel", kernel).Int("port", port).Msg("Built-in Core Started")
+	return nil
+}
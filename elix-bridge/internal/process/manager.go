@@ -4,10 +4,13 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
@@ -16,6 +19,77 @@ import (
 type Manager struct {
 	cmd     *exec.Cmd
 	WorkDir string
+
+	// 内置内核在桥接进程内运行，没有子进程
+	builtins map[string]func() http.Handler
+	builtin  *http.Server
+
+	mu        sync.Mutex
+	stopping  bool
+	onExit    func(kernel string, err error)
+	kernel    string
+	port      int
+	running   bool
+	startedAt time.Time
+}
+
+// Status describes the current kernel process
+type Status struct {
+	Kernel    string     `json:"kernel,omitempty"`
+	Port      int        `json:"port,omitempty"`
+	PID       int        `json:"pid,omitempty"`
+	Running   bool       `json:"running"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// Status returns the state of the most recently started kernel
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := Status{Kernel: m.kernel, Port: m.port, Running: m.running}
+	if m.running {
+		startedAt := m.startedAt
+		st.StartedAt = &startedAt
+		// 内置内核没有独立进程
+		if m.cmd != nil && m.cmd.Process != nil {
+			st.PID = m.cmd.Process.Pid
+		}
+	}
+	return st
+}
+
+// Stats adds resource usage to Status
+type Stats struct {
+	Status
+	MemoryRSS     uint64  `json:"memory_rss_bytes"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// Stats returns the kernel status with its memory usage and uptime.
+// Memory is zero when no kernel is running or it cannot be read.
+func (m *Manager) Stats() Stats {
+	st := Stats{Status: m.Status()}
+	if !st.Running {
+		return st
+	}
+	st.UptimeSeconds = time.Since(*st.StartedAt).Seconds()
+	if st.PID == 0 {
+		return st
+	}
+	if rss, err := residentMemory(st.PID); err == nil {
+		st.MemoryRSS = rss
+	} else {
+		log.Debug().Str("component", "kernel").Err(err).Int("pid", st.PID).Msg("Failed to read kernel memory")
+	}
+	return st
+}
+
+// OnExit sets a callback for when the kernel exits without Stop being called
+func (m *Manager) OnExit(callback func(kernel string, err error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onExit = callback
 }
 
 func NewManager(workDir string) *Manager {
@@ -29,9 +103,16 @@ func (m *Manager) Start(kernel string, port int) error {
 	var cmd *exec.Cmd
 	var serverPath string
 
+	m.mu.Lock()
+	newHandler, builtin := m.builtins[kernel]
+	m.mu.Unlock()
+	if builtin {
+		return m.startBuiltin(kernel, port, newHandler)
+	}
+
 	if kernel == "aider" {
 		serverPath = filepath.Join(m.WorkDir, "cores", "aider")
-		log.Info().Str("kernel", "aider").Str("path", serverPath).Int("port", port).Msg("Starting Aider Core...")
+		log.Info().Str("component", "kernel").Str("kernel", "aider").Str("path", serverPath).Int("port", port).Msg("Starting Aider Core...")
 
 		// Check if server.py exists
 		if _, err := os.Stat(filepath.Join(serverPath, "server.py")); os.IsNotExist(err) {
@@ -46,7 +127,7 @@ func (m *Manager) Start(kernel string, port int) error {
 			if _, err := os.Stat(pythonPath); err == nil {
 				cmd = exec.Command(pythonPath, "server.py")
 			} else {
-				log.Warn().Msg("Aider venv not found, falling back to system python")
+				log.Warn().Str("component", "kernel").Msg("Aider venv not found, falling back to system python")
 				cmd = exec.Command("python", "server.py")
 			}
 		} else {
@@ -54,7 +135,7 @@ func (m *Manager) Start(kernel string, port int) error {
 			if _, err := os.Stat(pythonPath); err == nil {
 				cmd = exec.Command(pythonPath, "server.py")
 			} else {
-				log.Warn().Msg("Aider venv not found, falling back to system python3")
+				log.Warn().Str("component", "kernel").Msg("Aider venv not found, falling back to system python3")
 				cmd = exec.Command("python3", "server.py")
 			}
 		}
@@ -75,7 +156,7 @@ func (m *Manager) Start(kernel string, port int) error {
 			return fmt.Errorf("gemini core path not found: %s", serverPath)
 		}
 
-		log.Info().Str("kernel", "gemini").Str("path", serverPath).Int("port", port).Msg("Starting Gemini Core...")
+		log.Info().Str("component", "kernel").Str("kernel", "gemini").Str("path", serverPath).Int("port", port).Msg("Starting Gemini Core...")
 
 		if runtime.GOOS == "windows" {
 			cmd = exec.Command("npm.cmd", "run", "start")
@@ -99,20 +180,71 @@ func (m *Manager) Start(kernel string, port int) error {
 		return fmt.Errorf("failed to start %s process: %w", kernel, err)
 	}
 
+	m.mu.Lock()
 	m.cmd = cmd
+	m.builtin = nil
+	m.stopping = false
+	m.kernel = kernel
+	m.port = port
+	m.running = true
+	m.startedAt = time.Now()
+	m.mu.Unlock()
 
 	// Async Log Forwarding
-	go scanLog(stdout, fmt.Sprintf("%s_OUT", kernel))
-	go scanLog(stderr, fmt.Sprintf("%s_ERR", kernel))
+	var logs sync.WaitGroup
+	logs.Add(2)
+	go func() {
+		defer logs.Done()
+		scanLog(stdout, fmt.Sprintf("%s_OUT", kernel))
+	}()
+	go func() {
+		defer logs.Done()
+		scanLog(stderr, fmt.Sprintf("%s_ERR", kernel))
+	}()
 
-	log.Info().Str("kernel", kernel).Int("pid", cmd.Process.Pid).Msg("Core Started")
+	// Detect unexpected exits (pipes must be drained before Wait)
+	go func() {
+		logs.Wait()
+		err := cmd.Wait()
+
+		m.mu.Lock()
+		expected := m.stopping || m.cmd != cmd
+		if m.cmd == cmd {
+			m.running = false
+		}
+		callback := m.onExit
+		m.mu.Unlock()
+
+		if expected {
+			return
+		}
+		log.Warn().Str("component", "kernel").Err(err).Str("kernel", kernel).Msg("Core exited unexpectedly")
+		if callback != nil {
+			callback(kernel, err)
+		}
+	}()
+
+	log.Info().Str("component", "kernel").Str("kernel", kernel).Int("pid", cmd.Process.Pid).Msg("Core Started")
 	return nil
 }
 
 // Stop terminates the process
 func (m *Manager) Stop() error {
+	m.mu.Lock()
+	m.stopping = true
+	builtin := m.builtin
+	if builtin != nil {
+		m.builtin = nil
+		m.running = false
+	}
+	m.mu.Unlock()
+
+	if builtin != nil {
+		log.Info().Str("component", "kernel").Msg("Stopping built-in Core...")
+		return closeBuiltin(builtin)
+	}
 	if m.cmd != nil && m.cmd.Process != nil {
-		log.Info().Msg("Stopping Gemini Core...")
+		log.Info().Str("component", "kernel").Msg("Stopping Gemini Core...")
 		if runtime.GOOS == "windows" {
 			// /F = Force, /T = Tree (kill child processes)
 			err := exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprint(m.cmd.Process.Pid)).Run()
@@ -131,6 +263,6 @@ func (m *Manager) Stop() error {
 func scanLog(r io.Reader, prefix string) {
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		log.Info().Str("stream", prefix).Msg(scanner.Text())
+		log.Info().Str("component", "kernel").Str("stream", prefix).Msg(scanner.Text())
 	}
 }
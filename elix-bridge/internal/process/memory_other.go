@@ -0,0 +1,24 @@
+//go:build !linux && !windows
+
+package process
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// residentMemory returns the resident set size of pid in bytes, as
+// reported by ps
+func residentMemory(pid int) (uint64, error) {
+	out, err := exec.Command("ps", "-o", "rss=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, err
+	}
+	kb, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected ps output: %w", err)
+	}
+	return kb * 1024, nil
+}
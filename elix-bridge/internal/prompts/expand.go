@@ -0,0 +1,56 @@
+package prompts
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholder matches {{name}} and {{name|default}}
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*(?:\|([^}]*))?\}\}`)
+
+// Variables returns the distinct variable names used in content, in order
+// of first appearance
+func Variables(content string) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, m := range placeholder.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// Expand replaces each {{name}} in content with vars[name]. A placeholder
+// written as {{name|text}} falls back to text when name is not given;
+// any other missing variable is reported in a MISSING_VARIABLES error.
+func Expand(content string, vars map[string]string) (string, error) {
+	missing := make(map[string]bool)
+	out := placeholder.ReplaceAllStringFunc(content, func(match string) string {
+		m := placeholder.FindStringSubmatch(match)
+		if v, ok := vars[m[1]]; ok {
+			return v
+		}
+		if strings.Contains(match, "|") {
+			return m[2]
+		}
+		missing[m[1]] = true
+		return match
+	})
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", &PromptError{
+			Code:    "MISSING_VARIABLES",
+			Message: "Missing template variables: " + strings.Join(names, ", "),
+			Missing: names,
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,293 @@
+// Package prompts provides reusable prompt templates for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package prompts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope says where a template is stored
+type Scope string
+
+const (
+	// ScopeUser templates live in ~/.echohelix/prompts.json
+	ScopeUser Scope = "user"
+	// ScopeWorkspace templates live in <workspace>/.echohelix/prompts.json
+	// so they can be committed alongside the project
+	ScopeWorkspace Scope = "workspace"
+)
+
+// Template is a reusable prompt with {{variable}} placeholders
+type Template struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Content     string    `json:"content"`
+	Scope       Scope     `json:"scope"`
+	Workspace   string    `json:"workspace,omitempty"`
+	Variables   []string  `json:"variables"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Patch holds the fields to change in Update; nil fields are kept
+type Patch struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Content     *string `json:"content"`
+}
+
+// Errors
+var (
+	ErrNotFound     = &PromptError{Code: "PROMPT_NOT_FOUND", Message: "Prompt template not found"}
+	ErrNameRequired = &PromptError{Code: "INVALID_PROMPT", Message: "Template name and content are required"}
+	ErrNoWorkspace  = &PromptError{Code: "INVALID_PROMPT", Message: "Workspace templates need a workspace"}
+	ErrInvalidScope = &PromptError{Code: "INVALID_PROMPT", Message: "Scope must be user or workspace"}
+)
+
+// PromptError represents a prompt-related error
+type PromptError struct {
+	Code    string
+	Message string
+	// Missing lists the variables that had no value, for MISSING_VARIABLES
+	Missing []string
+}
+
+func (e *PromptError) Error() string {
+	return e.Message
+}
+
+// Store reads and writes templates. Files are read on every call so that
+// hand edits and templates pulled with the workspace show up immediately.
+type Store struct {
+	mu       sync.Mutex
+	userPath string
+}
+
+// NewStore creates a store whose user templates are kept in configDir
+func NewStore(configDir string) *Store {
+	return &Store{userPath: filepath.Join(configDir, "prompts.json")}
+}
+
+// workspaceFile returns the template file of a workspace
+func workspaceFile(workspace string) string {
+	return filepath.Join(workspace, ".echohelix", "prompts.json")
+}
+
+// List returns the user templates followed by those of workspace, if
+// given, each sorted by name
+func (s *Store) List(workspace string) ([]Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.load(ScopeUser, "")
+	if err != nil {
+		return nil, err
+	}
+	if workspace != "" {
+		ws, err := s.load(ScopeWorkspace, workspace)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, ws...)
+	}
+	return list, nil
+}
+
+// Get finds a template by ID among the user templates and those of
+// workspace
+func (s *Store) Get(id, workspace string) (Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, list, i, err := s.find(id, workspace)
+	if err != nil {
+		return Template{}, err
+	}
+	return list[i], nil
+}
+
+// Create stores a new template. An empty scope means ScopeUser.
+func (s *Store) Create(t Template) (Template, error) {
+	if strings.TrimSpace(t.Name) == "" || t.Content == "" {
+		return Template{}, ErrNameRequired
+	}
+	switch t.Scope {
+	case "":
+		t.Scope = ScopeUser
+	case ScopeUser, ScopeWorkspace:
+	default:
+		return Template{}, ErrInvalidScope
+	}
+	if t.Scope == ScopeUser {
+		t.Workspace = ""
+	} else if t.Workspace == "" {
+		return Template{}, ErrNoWorkspace
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.load(t.Scope, t.Workspace)
+	if err != nil {
+		return Template{}, err
+	}
+
+	now := time.Now()
+	t.ID = generateID()
+	t.Variables = Variables(t.Content)
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	list = append(list, t)
+
+	if err := s.save(t.Scope, t.Workspace, list); err != nil {
+		return Template{}, err
+	}
+	return t, nil
+}
+
+// Update applies p to the template with the given ID
+func (s *Store) Update(id, workspace string, p Patch) (Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scope, list, i, err := s.find(id, workspace)
+	if err != nil {
+		return Template{}, err
+	}
+
+	t := list[i]
+	if p.Name != nil {
+		t.Name = *p.Name
+	}
+	if p.Description != nil {
+		t.Description = *p.Description
+	}
+	if p.Content != nil {
+		t.Content = *p.Content
+	}
+	if strings.TrimSpace(t.Name) == "" || t.Content == "" {
+		return Template{}, ErrNameRequired
+	}
+	t.Variables = Variables(t.Content)
+	t.UpdatedAt = time.Now()
+	list[i] = t
+
+	if err := s.save(scope, t.Workspace, list); err != nil {
+		return Template{}, err
+	}
+	return t, nil
+}
+
+// Delete removes the template with the given ID
+func (s *Store) Delete(id, workspace string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scope, list, i, err := s.find(id, workspace)
+	if err != nil {
+		return err
+	}
+	dir := list[i].Workspace
+	list = append(list[:i], list[i+1:]...)
+	return s.save(scope, dir, list)
+}
+
+// find locates id, checking the user templates first. Callers hold s.mu.
+func (s *Store) find(id, workspace string) (Scope, []Template, int, error) {
+	list, err := s.load(ScopeUser, "")
+	if err != nil {
+		return "", nil, 0, err
+	}
+	for i, t := range list {
+		if t.ID == id {
+			return ScopeUser, list, i, nil
+		}
+	}
+
+	if workspace != "" {
+		list, err := s.load(ScopeWorkspace, workspace)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		for i, t := range list {
+			if t.ID == id {
+				return ScopeWorkspace, list, i, nil
+			}
+		}
+	}
+	return "", nil, 0, ErrNotFound
+}
+
+func (s *Store) path(scope Scope, workspace string) string {
+	if scope == ScopeWorkspace {
+		return workspaceFile(workspace)
+	}
+	return s.userPath
+}
+
+// load reads one template file; a missing file is an empty list
+func (s *Store) load(scope Scope, workspace string) ([]Template, error) {
+	data, err := os.ReadFile(s.path(scope, workspace))
+	if os.IsNotExist(err) {
+		return []Template{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Template
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	// 文件可能被手工编辑，补全派生字段
+	for i := range list {
+		list[i].Scope = scope
+		list[i].Workspace = workspace
+		list[i].Variables = Variables(list[i].Content)
+		// 手写的模板没有 ID 时用名称派生，保证多次读取一致
+		if list[i].ID == "" {
+			list[i].ID = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(list[i].Name)), " ", "-")
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	return list, nil
+}
+
+func (s *Store) save(scope Scope, workspace string, list []Template) error {
+	path := s.path(scope, workspace)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// 工作区文件可能提交到仓库，不写入本机路径
+	stored := make([]Template, len(list))
+	for i, t := range list {
+		t.Workspace = ""
+		stored[i] = t
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func generateID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,172 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Provider API endpoints; variables so they can point at a proxy
+var (
+	geminiBaseURL    = "https://generativelanguage.googleapis.com/v1beta"
+	openaiBaseURL    = "https://api.openai.com/v1"
+	anthropicBaseURL = "https://api.anthropic.com/v1"
+	ollamaDefaultURL = "http://127.0.0.1:11434"
+)
+
+// liveClient queries provider APIs for the models a key can use
+type liveClient struct {
+	http *http.Client
+}
+
+func newLiveClient() *liveClient {
+	return &liveClient{http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// listModels fetches the provider's model list. Providers without a
+// known API return no models.
+func (c *liveClient) listModels(p Provider, lookup func(string) string) ([]Model, error) {
+	models, _, err := c.fetchModels(p, lookup)
+	return models, err
+}
+
+// fetchModels is listModels that also returns the response headers,
+// which carry organization and rate limit details
+func (c *liveClient) fetchModels(p Provider, lookup func(string) string) ([]Model, http.Header, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var header http.Header
+	key := ""
+	if p.KeyEnv != "" {
+		key = lookup(p.KeyEnv)
+	}
+
+	switch p.ID {
+	case "gemini":
+		var resp struct {
+			Models []struct {
+				Name            string `json:"name"`
+				DisplayName     string `json:"displayName"`
+				InputTokenLimit int    `json:"inputTokenLimit"`
+			} `json:"models"`
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, geminiBaseURL+"/models?pageSize=1000", nil)
+		if err != nil {
+			return nil, header, err
+		}
+		req.Header.Set("x-goog-api-key", key)
+		if header, err = c.getJSON(req, &resp); err != nil {
+			return nil, header, err
+		}
+		models := make([]Model, 0, len(resp.Models))
+		for _, m := range resp.Models {
+			models = append(models, Model{
+				ID:            strings.TrimPrefix(m.Name, "models/"),
+				Name:          m.DisplayName,
+				Provider:      p.ID,
+				ContextWindow: m.InputTokenLimit,
+				Live:          true,
+			})
+		}
+		return models, header, nil
+
+	case "openai":
+		var resp struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, openaiBaseURL+"/models", nil)
+		if err != nil {
+			return nil, header, err
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+		if header, err = c.getJSON(req, &resp); err != nil {
+			return nil, header, err
+		}
+		models := make([]Model, 0, len(resp.Data))
+		for _, m := range resp.Data {
+			models = append(models, Model{ID: m.ID, Provider: p.ID, Live: true})
+		}
+		return models, header, nil
+
+	case "anthropic":
+		var resp struct {
+			Data []struct {
+				ID          string `json:"id"`
+				DisplayName string `json:"display_name"`
+			} `json:"data"`
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, anthropicBaseURL+"/models?limit=1000", nil)
+		if err != nil {
+			return nil, header, err
+		}
+		req.Header.Set("x-api-key", key)
+		req.Header.Set("anthropic-version", "2023-06-01")
+		if header, err = c.getJSON(req, &resp); err != nil {
+			return nil, header, err
+		}
+		models := make([]Model, 0, len(resp.Data))
+		for _, m := range resp.Data {
+			models = append(models, Model{ID: m.ID, Name: m.DisplayName, Provider: p.ID, Live: true})
+		}
+		return models, header, nil
+
+	case "local":
+		base := ollamaDefaultURL
+		if p.URLEnv != "" && lookup(p.URLEnv) != "" {
+			base = lookup(p.URLEnv)
+		}
+		if !strings.Contains(base, "://") {
+			base = "http://" + base
+		}
+		var resp struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+"/api/tags", nil)
+		if err != nil {
+			return nil, header, err
+		}
+		if header, err = c.getJSON(req, &resp); err != nil {
+			return nil, header, err
+		}
+		models := make([]Model, 0, len(resp.Models))
+		for _, m := range resp.Models {
+			models = append(models, Model{ID: m.Name, Provider: p.ID, Live: true})
+		}
+		return models, header, nil
+	}
+	return nil, nil, nil
+}
+
+// StatusError is a non-2xx response from a provider API
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// getJSON performs req and decodes a 2xx JSON response into out
+func (c *liveClient) getJSON(req *http.Request, out interface{}) (http.Header, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.Header, &StatusError{Status: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,256 @@
+// Package providers provides the model and provider registry for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package providers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultRegistry lists the providers and models known at build time
+//
+//go:embed registry.json
+var defaultRegistry []byte
+
+// Model describes a model a provider offers. Prices are USD per million
+// tokens; zero means unknown or free.
+type Model struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name,omitempty"`
+	Provider      string  `json:"provider"`
+	ContextWindow int     `json:"context_window,omitempty"`
+	InputPrice    float64 `json:"input_price,omitempty"`
+	OutputPrice   float64 `json:"output_price,omitempty"`
+	Live          bool    `json:"live"` // 提供方实时返回了该模型
+}
+
+// Provider describes a model provider and the kernel that drives it
+type Provider struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Kernel     string  `json:"kernel"`
+	KeyEnv     string  `json:"key_env,omitempty"` // 保存 API Key 的配置项
+	URLEnv     string  `json:"url_env,omitempty"` // 保存服务地址的配置项（本地模型）
+	Models     []Model `json:"models,omitempty"`
+	Configured bool    `json:"configured"`
+	LiveError  string  `json:"live_error,omitempty"`
+}
+
+// ErrUnknownProvider is returned for provider IDs not in the registry
+var ErrUnknownProvider = errors.New("unknown provider")
+
+type registryFile struct {
+	Providers []Provider `json:"providers"`
+}
+
+type liveResult struct {
+	models  []Model
+	err     error
+	fetched time.Time
+}
+
+// Registry merges the built-in registry, an optional user registry file,
+// and models reported live by configured providers
+type Registry struct {
+	path   string
+	lookup func(key string) string
+	client *liveClient
+
+	mu    sync.Mutex
+	cache map[string]liveResult
+	ttl   time.Duration
+}
+
+// NewRegistry creates a registry. path is a JSON file in the same format
+// as the built-in registry whose providers and models override the
+// defaults; lookup reads API keys and URLs from the bridge config.
+func NewRegistry(path string, lookup func(key string) string) *Registry {
+	return &Registry{
+		path:   path,
+		lookup: lookup,
+		client: newLiveClient(),
+		cache:  make(map[string]liveResult),
+		ttl:    10 * time.Minute,
+	}
+}
+
+// Providers returns every provider with its registry models. Live models
+// are not fetched; use Models for that.
+func (r *Registry) Providers() []Provider {
+	providers := r.load()
+	for i := range providers {
+		providers[i].Configured = r.configured(providers[i])
+	}
+	return providers
+}
+
+// Models returns a provider's models, adding those reported live when the
+// provider is configured. refresh bypasses the live cache.
+func (r *Registry) Models(providerID string, refresh bool) (Provider, error) {
+	var provider Provider
+	found := false
+	for _, p := range r.load() {
+		if p.ID == providerID {
+			provider, found = p, true
+			break
+		}
+	}
+	if !found {
+		return Provider{}, fmt.Errorf("%w: %s", ErrUnknownProvider, providerID)
+	}
+
+	provider.Configured = r.configured(provider)
+	if !provider.Configured {
+		return provider, nil
+	}
+
+	live, err := r.live(provider, refresh)
+	if err != nil {
+		// 实时查询失败时仍返回注册表中的模型
+		provider.LiveError = err.Error()
+		return provider, nil
+	}
+	provider.Models = mergeModels(provider.Models, live, true)
+	return provider, nil
+}
+
+// Find returns the provider with the given ID from the registry
+func (r *Registry) Find(providerID string) (Provider, bool) {
+	for _, p := range r.load() {
+		if p.ID == providerID {
+			p.Configured = r.configured(p)
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+func (r *Registry) configured(p Provider) bool {
+	if p.KeyEnv != "" {
+		return r.lookup(p.KeyEnv) != ""
+	}
+	// 本地模型无需密钥，默认地址即视为已配置
+	return true
+}
+
+// live returns the provider's models from its API, cached for ttl
+func (r *Registry) live(p Provider, refresh bool) ([]Model, error) {
+	r.mu.Lock()
+	cached, ok := r.cache[p.ID]
+	r.mu.Unlock()
+	if ok && !refresh && time.Since(cached.fetched) < r.ttl {
+		return cached.models, cached.err
+	}
+
+	models, err := r.client.listModels(p, r.lookup)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", p.ID).Msg("Failed to list provider models")
+	}
+
+	r.mu.Lock()
+	r.cache[p.ID] = liveResult{models: models, err: err, fetched: time.Now()}
+	r.mu.Unlock()
+	return models, err
+}
+
+// load reads the built-in registry and applies the user file on top
+func (r *Registry) load() []Provider {
+	var base registryFile
+	if err := json.Unmarshal(defaultRegistry, &base); err != nil {
+		// 内嵌文件在编译时已确定
+		panic(err)
+	}
+
+	if r.path != "" {
+		data, err := os.ReadFile(r.path)
+		if err == nil {
+			var user registryFile
+			if err := json.Unmarshal(data, &user); err != nil {
+				log.Warn().Err(err).Str("path", r.path).Msg("Invalid model registry file, ignoring")
+			} else {
+				base.Providers = mergeProviders(base.Providers, user.Providers)
+			}
+		} else if !os.IsNotExist(err) {
+			log.Warn().Err(err).Str("path", r.path).Msg("Failed to read model registry file")
+		}
+	}
+
+	for i := range base.Providers {
+		for j := range base.Providers[i].Models {
+			base.Providers[i].Models[j].Provider = base.Providers[i].ID
+		}
+	}
+	return base.Providers
+}
+
+// mergeProviders overlays user providers on the defaults by ID; models
+// are merged rather than replaced
+func mergeProviders(base, user []Provider) []Provider {
+	index := make(map[string]int, len(base))
+	for i, p := range base {
+		index[p.ID] = i
+	}
+	for _, p := range user {
+		i, ok := index[p.ID]
+		if !ok {
+			index[p.ID] = len(base)
+			base = append(base, p)
+			continue
+		}
+		merged := base[i]
+		if p.Name != "" {
+			merged.Name = p.Name
+		}
+		if p.Kernel != "" {
+			merged.Kernel = p.Kernel
+		}
+		if p.KeyEnv != "" {
+			merged.KeyEnv = p.KeyEnv
+		}
+		if p.URLEnv != "" {
+			merged.URLEnv = p.URLEnv
+		}
+		merged.Models = mergeModels(merged.Models, p.Models, false)
+		base[i] = merged
+	}
+	return base
+}
+
+// mergeModels adds or updates models by ID. For live models only missing
+// details are filled in, since the registry is the source of pricing.
+func mergeModels(base, extra []Model, live bool) []Model {
+	out := append([]Model(nil), base...)
+	index := make(map[string]int, len(out))
+	for i, m := range out {
+		index[m.ID] = i
+	}
+	for _, m := range extra {
+		i, ok := index[m.ID]
+		if !ok {
+			index[m.ID] = len(out)
+			out = append(out, m)
+			continue
+		}
+		if live {
+			out[i].Live = true
+			if out[i].Name == "" {
+				out[i].Name = m.Name
+			}
+			if out[i].ContextWindow == 0 {
+				out[i].ContextWindow = m.ContextWindow
+			}
+			continue
+		}
+		out[i] = m
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Validation reports whether a provider key works
+type Validation struct {
+	Provider     string            `json:"provider"`
+	Valid        bool              `json:"valid"`
+	Status       int               `json:"status,omitempty"` // 提供方返回的 HTTP 状态码
+	Error        string            `json:"error,omitempty"`
+	Organization string            `json:"organization,omitempty"`
+	Quota        map[string]string `json:"quota,omitempty"`
+	Models       []string          `json:"models,omitempty"`
+}
+
+// ErrNoKey is returned when neither a key nor a configured key is
+// available to validate
+var ErrNoKey = errors.New("no API key configured")
+
+// organizationHeaders and quotaPrefixes pick the account details out of
+// provider response headers
+var (
+	organizationHeaders = []string{"Openai-Organization", "Anthropic-Organization-Id"}
+	quotaPrefixes       = []string{"X-Ratelimit-", "Anthropic-Ratelimit-"}
+)
+
+// Validate lists models with the provider's key to check that it is
+// accepted. key overrides the configured key, so a key can be tested
+// before it is saved. An invalid key is reported in the result, not as
+// an error; errors mean the check could not be made.
+func (r *Registry) Validate(providerID, key string) (Validation, error) {
+	p, ok := r.Find(providerID)
+	if !ok {
+		return Validation{}, fmt.Errorf("%w: %s", ErrUnknownProvider, providerID)
+	}
+
+	lookup := r.lookup
+	if key != "" {
+		lookup = func(name string) string {
+			if name == p.KeyEnv {
+				return key
+			}
+			return r.lookup(name)
+		}
+	} else if p.KeyEnv != "" && r.lookup(p.KeyEnv) == "" {
+		return Validation{}, fmt.Errorf("%w: set %s", ErrNoKey, p.KeyEnv)
+	}
+
+	result := Validation{Provider: p.ID}
+	models, header, err := r.client.fetchModels(p, lookup)
+	if err != nil {
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			// 网络错误等无法判断密钥是否有效
+			return Validation{}, err
+		}
+		result.Status = statusErr.Status
+		result.Error = statusErr.Message
+	} else {
+		result.Valid = true
+		result.Status = http.StatusOK
+		for _, m := range models {
+			result.Models = append(result.Models, m.ID)
+		}
+	}
+
+	for _, name := range organizationHeaders {
+		if v := header.Get(name); v != "" {
+			result.Organization = v
+		}
+	}
+	for name, values := range header {
+		for _, prefix := range quotaPrefixes {
+			if strings.HasPrefix(name, prefix) && len(values) > 0 {
+				if result.Quota == nil {
+					result.Quota = make(map[string]string)
+				}
+				result.Quota[strings.ToLower(strings.TrimPrefix(name, prefix))] = values[0]
+			}
+		}
+	}
+
+	// 使用已保存的密钥验证成功时刷新模型缓存
+	if key == "" && result.Valid {
+		r.mu.Lock()
+		delete(r.cache, p.ID)
+		r.mu.Unlock()
+	}
+	return result, nil
+}
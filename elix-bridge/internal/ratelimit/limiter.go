@@ -0,0 +1,93 @@
+// Package ratelimit provides token-bucket rate limiting for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter keeps one token bucket per key (device, IP, ...)
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*bucket
+	idleTTL time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Config configures a limiter
+type Config struct {
+	PerMinute int // 每分钟补充的请求数，<=0 表示不限制
+	Burst     int // 桶容量，默认等于 PerMinute
+}
+
+// New creates a limiter. It returns nil when PerMinute <= 0; a nil
+// Limiter allows everything.
+func New(config Config) *Limiter {
+	if config.PerMinute <= 0 {
+		return nil
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.PerMinute
+	}
+
+	return &Limiter{
+		rate:    float64(config.PerMinute) / 60,
+		burst:   float64(config.Burst),
+		buckets: make(map[string]*bucket),
+		idleTTL: 10 * time.Minute,
+	}
+}
+
+// Allow takes a token for key. When the bucket is empty it returns false
+// and how long until the next token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// Cleanup drops the buckets of keys idle for a while; run it every
+// minute or so
+func (l *Limiter) Cleanup() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, b := range l.buckets {
+		if time.Since(b.last) > l.idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package recentfiles provides recent file tracking for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package recentfiles
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/statefile"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Op is what was done to a file
+type Op string
+
+const (
+	OpRead  Op = "read"
+	OpWrite Op = "write"
+)
+
+// Source is who touched a file. Kernel and MCP touches are the AI's.
+type Source string
+
+const (
+	SourceDevice Source = "device"
+	SourceKernel Source = "kernel"
+	SourceMCP    Source = "mcp"
+)
+
+const (
+	// maxWorkspaceFiles and maxSessionFiles bound the lists; the files
+	// touched longest ago are dropped first
+	maxWorkspaceFiles = 200
+	maxSessionFiles   = 500
+	// sessionRetention drops the files of sessions idle this long
+	sessionRetention = 30 * 24 * time.Hour
+)
+
+// Touch is one read or write of a file
+type Touch struct {
+	// Workspace is the root Path is relative to
+	Workspace string
+	Path      string
+	// SessionID is the session the touch belongs to, if any
+	SessionID string
+	Source    Source
+	Op        Op
+}
+
+// File is what is known of a file's recent use
+type File struct {
+	Path       string    `json:"path"`
+	Reads      int       `json:"reads"`
+	Writes     int       `json:"writes"`
+	AIWrites   int       `json:"ai_writes"` // writes by a kernel or over MCP
+	LastRead   time.Time `json:"last_read,omitempty"`
+	LastWrite  time.Time `json:"last_write,omitempty"`
+	LastAccess time.Time `json:"last_access"`
+	LastSource Source    `json:"last_source"`
+	SessionID  string    `json:"session_id,omitempty"` // 最近一次访问所属的会话
+}
+
+// AI reports whether the AI changed the file
+func (f File) AI() bool {
+	return f.AIWrites > 0
+}
+
+type sessionFiles struct {
+	Workspace string           `json:"workspace"`
+	Updated   time.Time        `json:"updated"`
+	Files     map[string]*File `json:"files"`
+}
+
+// Tracker records the files read and written through the bridge, per
+// workspace and per session. Save persists it to storagePath.
+type Tracker struct {
+	mu          sync.Mutex
+	workspaces  map[string]map[string]*File
+	sessions    map[string]*sessionFiles
+	storagePath string
+	dirty       bool
+
+	saveMu sync.Mutex
+}
+
+type trackerFile struct {
+	Workspaces map[string]map[string]*File `json:"workspaces"`
+	Sessions   map[string]*sessionFiles    `json:"sessions"`
+}
+
+// NewTracker creates a tracker persisted at storagePath
+func NewTracker(storagePath string) *Tracker {
+	t := &Tracker{
+		workspaces:  make(map[string]map[string]*File),
+		sessions:    make(map[string]*sessionFiles),
+		storagePath: storagePath,
+	}
+	var f trackerFile
+	if err := statefile.Read(storagePath, &f, nil); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Msg("Failed to load recent files")
+		}
+	} else {
+		if f.Workspaces != nil {
+			t.workspaces = f.Workspaces
+		}
+		if f.Sessions != nil {
+			t.sessions = f.Sessions
+		}
+	}
+	return t
+}
+
+// Record adds a touch to its workspace's recent files and to its
+// session's files
+func (t *Tracker) Record(touch Touch) {
+	if touch.Path == "" {
+		return
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	files := t.workspaces[touch.Workspace]
+	if files == nil {
+		files = make(map[string]*File)
+		t.workspaces[touch.Workspace] = files
+	}
+	apply(files, touch, now, maxWorkspaceFiles)
+
+	if touch.SessionID != "" {
+		sf := t.sessions[touch.SessionID]
+		if sf == nil {
+			sf = &sessionFiles{Workspace: touch.Workspace, Files: make(map[string]*File)}
+			t.sessions[touch.SessionID] = sf
+		}
+		sf.Updated = now
+		apply(sf.Files, touch, now, maxSessionFiles)
+	}
+	t.dirty = true
+}
+
+// apply counts touch in files, dropping the least recent file when there
+// are more than max
+func apply(files map[string]*File, touch Touch, now time.Time, max int) {
+	f := files[touch.Path]
+	if f == nil {
+		f = &File{Path: touch.Path}
+		files[touch.Path] = f
+	}
+	switch touch.Op {
+	case OpRead:
+		f.Reads++
+		f.LastRead = now
+	case OpWrite:
+		f.Writes++
+		f.LastWrite = now
+		if touch.Source != SourceDevice {
+			f.AIWrites++
+		}
+	}
+	f.LastAccess = now
+	f.LastSource = touch.Source
+	if touch.SessionID != "" {
+		f.SessionID = touch.SessionID
+	}
+
+	if len(files) > max {
+		var oldest *File
+		for _, c := range files {
+			if oldest == nil || c.LastAccess.Before(oldest.LastAccess) {
+				oldest = c
+			}
+		}
+		delete(files, oldest.Path)
+	}
+}
+
+// Recent returns up to limit of workspace's files, most recently touched
+// first; with aiOnly, only the files the AI changed
+func (t *Tracker) Recent(workspace string, limit int, aiOnly bool) []File {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return list(t.workspaces[workspace], limit, aiOnly)
+}
+
+// Session returns the files a session touched, most recent first, and
+// the workspace their paths are relative to
+func (t *Tracker) Session(id string) (workspace string, files []File) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sf := t.sessions[id]
+	if sf == nil {
+		return "", []File{}
+	}
+	return sf.Workspace, list(sf.Files, 0, false)
+}
+
+// Forget drops a session's files, for a deleted session
+func (t *Tracker) Forget(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.sessions[sessionID]; ok {
+		delete(t.sessions, sessionID)
+		t.dirty = true
+	}
+}
+
+func list(files map[string]*File, limit int, aiOnly bool) []File {
+	out := make([]File, 0, len(files))
+	for _, f := range files {
+		if !aiOnly || f.AI() {
+			out = append(out, *f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LastAccess.Equal(out[j].LastAccess) {
+			return out[i].LastAccess.After(out[j].LastAccess)
+		}
+		return out[i].Path < out[j].Path
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// Save writes the tracker when it changed since the last save, dropping
+// sessions idle for sessionRetention
+func (t *Tracker) Save() {
+	if t.storagePath == "" {
+		return
+	}
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return
+	}
+	cutoff := time.Now().Add(-sessionRetention)
+	for id, sf := range t.sessions {
+		if sf.Updated.Before(cutoff) {
+			delete(t.sessions, id)
+		}
+	}
+	err := statefile.Write(t.storagePath, trackerFile{Workspaces: t.workspaces, Sessions: t.sessions}, 0600, nil)
+	t.dirty = false
+	t.mu.Unlock()
+
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to save recent files")
+	}
+}
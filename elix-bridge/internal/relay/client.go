@@ -0,0 +1,229 @@
+// Package relay provides an outbound tunnel for EchoHelix Bridge.
+//
+// The bridge keeps a WebSocket open to a relay and multiplexes incoming
+// phone connections over it, so the phone can reach the bridge from
+// outside the LAN without port forwarding. The relay only routes bytes;
+// device tokens are still checked by the bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package relay
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/rs/zerolog/log"
+)
+
+// ClientConfig configures the bridge side of the tunnel
+type ClientConfig struct {
+	URL      string // 中继地址，如 wss://relay.example.com
+	BridgeID string // 本桥接在中继上的标识
+	Secret   string // 中继注册密钥
+}
+
+// Client is a net.Listener whose connections arrive through the relay.
+// It reconnects with backoff until closed.
+type Client struct {
+	config ClientConfig
+	conns  chan net.Conn
+	done   chan struct{}
+
+	mu        sync.Mutex
+	session   *yamux.Session
+	connected bool
+	lastError string
+	closeOnce sync.Once
+}
+
+// Status describes the tunnel for health reporting
+type Status struct {
+	Connected bool   `json:"connected"`
+	URL       string `json:"url"`
+	PublicURL string `json:"public_url"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// NewClient validates config and starts connecting in the background
+func NewClient(config ClientConfig) (*Client, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") {
+		return nil, errors.New("RELAY_URL must be a ws:// or wss:// URL")
+	}
+	if config.BridgeID == "" || strings.ContainsAny(config.BridgeID, "/?#") {
+		return nil, errors.New("invalid relay bridge ID")
+	}
+
+	c := &Client{
+		config: config,
+		conns:  make(chan net.Conn),
+		done:   make(chan struct{}),
+	}
+	go c.run()
+	return c, nil
+}
+
+// PublicURL is the base URL the phone uses to reach this bridge
+func (c *Client) PublicURL() string {
+	base := strings.TrimSuffix(c.config.URL, "/")
+	base = strings.Replace(base, "ws", "http", 1) // ws -> http, wss -> https
+	return base + "/b/" + c.config.BridgeID
+}
+
+// Status reports whether the tunnel is up
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Status{
+		Connected: c.connected,
+		URL:       c.config.URL,
+		PublicURL: c.PublicURL(),
+		LastError: c.lastError,
+	}
+}
+
+// Accept waits for the next connection through the relay
+func (c *Client) Accept() (net.Conn, error) {
+	select {
+	case conn := <-c.conns:
+		return conn, nil
+	case <-c.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close disconnects from the relay
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		if c.session != nil {
+			c.session.Close()
+		}
+		c.mu.Unlock()
+	})
+	return nil
+}
+
+// Addr returns a placeholder address for the tunnel
+func (c *Client) Addr() net.Addr {
+	return Addr(c.config.URL)
+}
+
+// run keeps the tunnel connected
+func (c *Client) run() {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := c.serve()
+
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		c.mu.Lock()
+		c.connected = false
+		if err != nil {
+			c.lastError = err.Error()
+		}
+		c.mu.Unlock()
+
+		// 连接稳定一段时间后断开则重置退避
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Warn().Str("component", "relay").Err(err).Dur("retry_in", backoff).Msg("Relay disconnected")
+
+		select {
+		case <-time.After(backoff):
+		case <-c.done:
+			return
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// serve connects once and hands out streams until the session ends
+func (c *Client) serve() error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.config.Secret)
+
+	endpoint := strings.TrimSuffix(c.config.URL, "/") + "/bridge/" + url.PathEscape(c.config.BridgeID)
+	dialer := websocket.Dialer{HandshakeTimeout: 15 * time.Second}
+	ws, resp, err := dialer.Dial(endpoint, header)
+	if err != nil {
+		if resp != nil {
+			return errors.New("relay rejected connection: " + resp.Status)
+		}
+		return err
+	}
+
+	session, err := yamux.Server(newWSConn(ws), yamuxConfig())
+	if err != nil {
+		ws.Close()
+		return err
+	}
+
+	c.mu.Lock()
+	c.session = session
+	c.connected = true
+	c.lastError = ""
+	c.mu.Unlock()
+	log.Info().Str("component", "relay").Str("public_url", c.PublicURL()).Msg("Relay connected")
+
+	defer session.Close()
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			return err
+		}
+		select {
+		case c.conns <- Conn{stream}:
+		case <-c.done:
+			stream.Close()
+			return nil
+		}
+	}
+}
+
+// Conn is a connection that arrived through the relay. Its remote address
+// is the relay, never loopback, so localhost-only endpoints stay closed.
+type Conn struct {
+	net.Conn
+}
+
+// RemoteAddr identifies the connection as relayed
+func (c Conn) RemoteAddr() net.Addr {
+	return Addr(RelayedAddr)
+}
+
+// RelayedAddr is the remote address of every relayed request; the
+// phone's address is in X-Forwarded-For, set by the relay
+const RelayedAddr = "relay"
+
+// Addr is the address reported for relayed connections
+type Addr string
+
+func (a Addr) Network() string { return "relay" }
+func (a Addr) String() string  { return string(a) }
+
+func yamuxConfig() *yamux.Config {
+	config := yamux.DefaultConfig()
+	config.LogOutput = io.Discard
+	config.EnableKeepAlive = true
+	config.KeepAliveInterval = 30 * time.Second
+	return config
+}
@@ -0,0 +1,164 @@
+package relay
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/rs/zerolog/log"
+)
+
+// ServerConfig configures a relay server
+type ServerConfig struct {
+	Secret string // 桥接注册所需的共享密钥
+}
+
+// Server accepts bridge tunnels on /bridge/{id} and forwards phone
+// requests on /b/{id}/... to them. HTTP, SSE, and WebSocket upgrades
+// all pass through unchanged.
+type Server struct {
+	config   ServerConfig
+	upgrader websocket.Upgrader
+
+	mu      sync.RWMutex
+	tunnels map[string]*tunnel
+}
+
+// tunnel is a connected bridge
+type tunnel struct {
+	session *yamux.Session
+	proxy   *httputil.ReverseProxy
+}
+
+// NewServer creates a relay server
+func NewServer(config ServerConfig) (*Server, error) {
+	if config.Secret == "" {
+		return nil, errors.New("relay secret is required")
+	}
+	return &Server{
+		config:   config,
+		upgrader: websocket.Upgrader{ReadBufferSize: 32 << 10, WriteBufferSize: 32 << 10},
+		tunnels:  make(map[string]*tunnel),
+	}, nil
+}
+
+// ServeHTTP routes bridge registrations and phone requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/bridge/"):
+		s.handleBridge(w, r, strings.TrimPrefix(r.URL.Path, "/bridge/"))
+	case strings.HasPrefix(r.URL.Path, "/b/"):
+		id, _ := splitPhonePath(r.URL.Path)
+		s.handlePhone(w, r, id)
+	case r.URL.Path == "/health":
+		w.Write([]byte("ok"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleBridge registers a bridge tunnel, replacing any previous one
+// with the same ID
+func (s *Server) handleBridge(w http.ResponseWriter, r *http.Request, id string) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Secret)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "invalid bridge id", http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warn().Str("component", "relay").Err(err).Msg("Failed to upgrade bridge connection")
+		return
+	}
+
+	// 桥接端是 yamux server，中继作为 client 主动打开流
+	session, err := yamux.Client(newWSConn(ws), yamuxConfig())
+	if err != nil {
+		ws.Close()
+		return
+	}
+
+	t := &tunnel{session: session, proxy: newProxy(id, session)}
+	s.mu.Lock()
+	if old := s.tunnels[id]; old != nil {
+		old.session.Close()
+	}
+	s.tunnels[id] = t
+	s.mu.Unlock()
+	log.Info().Str("component", "relay").Str("bridge_id", id).Str("remote", r.RemoteAddr).Msg("Bridge connected")
+
+	<-session.CloseChan()
+
+	s.mu.Lock()
+	if s.tunnels[id] == t {
+		delete(s.tunnels, id)
+	}
+	s.mu.Unlock()
+	log.Info().Str("component", "relay").Str("bridge_id", id).Msg("Bridge disconnected")
+}
+
+// handlePhone proxies a request to the bridge's tunnel
+func (s *Server) handlePhone(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.RLock()
+	t := s.tunnels[id]
+	s.mu.RUnlock()
+	if t == nil {
+		writeUpstreamError(w, "bridge is not connected")
+		return
+	}
+	t.proxy.ServeHTTP(w, r)
+}
+
+// newProxy forwards requests over a bridge session, stripping the
+// /b/{id} prefix
+func newProxy(id string, session *yamux.Session) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			_, path := splitPhonePath(pr.In.URL.Path)
+			pr.SetURL(&url.URL{Scheme: "http", Host: "bridge"})
+			pr.Out.URL.Path = path
+			pr.Out.URL.RawPath = ""
+			pr.SetXForwarded()
+		},
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return session.Open()
+			},
+			MaxIdleConnsPerHost: 16,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		FlushInterval: -1, // SSE 需要立即刷新
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Warn().Str("component", "relay").Err(err).Str("bridge_id", id).Msg("Relay request failed")
+			writeUpstreamError(w, "bridge request failed")
+		},
+	}
+}
+
+// splitPhonePath splits /b/{id}/rest into id and /rest
+func splitPhonePath(p string) (string, string) {
+	id, rest, _ := strings.Cut(strings.TrimPrefix(p, "/b/"), "/")
+	return id, "/" + rest
+}
+
+// writeUpstreamError uses the bridge's error body shape
+func writeUpstreamError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadGateway)
+	json.NewEncoder(w).Encode(map[string]string{"error": message, "code": "UPSTREAM_ERROR"})
+}
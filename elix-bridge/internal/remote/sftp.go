@@ -0,0 +1,355 @@
+// Package remote provides SSH/SFTP-backed workspaces for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package remote
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/fs"
+
+	"github.com/pkg/sftp"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Scheme is the URL prefix that marks a remote workspace path
+const Scheme = "ssh://"
+
+// Location identifies a path on a remote host
+type Location struct {
+	User string
+	Host string // host:port
+	Path string // absolute remote path
+}
+
+// IsRemote reports whether p refers to an SSH workspace
+func IsRemote(p string) bool {
+	return strings.HasPrefix(p, Scheme)
+}
+
+// ParseURL parses ssh://user@host[:port]/path
+func ParseURL(raw string) (*Location, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh url: %w", err)
+	}
+	if u.Scheme != "ssh" || u.Host == "" {
+		return nil, fmt.Errorf("invalid ssh url: %s", raw)
+	}
+
+	user := u.User.Username()
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+
+	return &Location{User: user, Host: host, Path: path.Clean(p)}, nil
+}
+
+// Join returns a copy of the location with rel appended to its path.
+// rel cannot climb above the location: ".." stops at its path.
+func (l *Location) Join(rel string) *Location {
+	if rel == "" || rel == "." {
+		return &Location{User: l.User, Host: l.Host, Path: l.Path}
+	}
+	return &Location{User: l.User, Host: l.Host, Path: path.Join(l.Path, path.Clean("/"+filepath.ToSlash(rel)))}
+}
+
+func (l *Location) key() string {
+	return l.User + "@" + l.Host
+}
+
+// Pool caches SFTP connections per user@host
+type Pool struct {
+	mu      sync.Mutex
+	clients map[string]*conn
+}
+
+type conn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+// NewPool creates a new connection pool
+func NewPool() *Pool {
+	return &Pool{
+		clients: make(map[string]*conn),
+	}
+}
+
+// Client returns a connected SFTP client for the location
+func (p *Pool) Client(loc *Location) (*sftp.Client, error) {
+	key := loc.key()
+	p.mu.Lock()
+	c, ok := p.clients[key]
+	p.mu.Unlock()
+
+	if ok {
+		// 连接仍然可用则复用
+		if _, err := c.sftp.Getwd(); err == nil {
+			return c.sftp, nil
+		}
+		p.mu.Lock()
+		if p.clients[key] == c {
+			delete(p.clients, key)
+		}
+		p.mu.Unlock()
+		c.sftp.Close()
+		c.ssh.Close()
+	}
+
+	// 拨号和握手在锁外进行，慢速或不可达的主机不会阻塞其他连接
+	config, closeAgent, err := clientConfig(loc.User)
+	if err != nil {
+		return nil, err
+	}
+	sshClient, err := ssh.Dial("tcp", loc.Host, config)
+	// 认证只在握手时用到 agent
+	closeAgent()
+	if err != nil {
+		return nil, fmt.Errorf("ssh dial %s: %w", loc.Host, err)
+	}
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("sftp session %s: %w", loc.Host, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if existing, ok := p.clients[key]; ok {
+		// 并发请求已先建立连接，保留先到的那个
+		sftpClient.Close()
+		sshClient.Close()
+		return existing.sftp, nil
+	}
+	p.clients[key] = &conn{ssh: sshClient, sftp: sftpClient}
+	log.Info().Str("host", loc.Host).Str("user", loc.User).Msg("SFTP connection established")
+	return sftpClient, nil
+}
+
+// Close closes all pooled connections
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, c := range p.clients {
+		c.sftp.Close()
+		c.ssh.Close()
+		delete(p.clients, key)
+	}
+}
+
+// ListFiles lists a remote directory, mirroring fs.Walker.ListFiles.
+// Returned paths are relative to root.
+func (p *Pool) ListFiles(root *Location, relPath string, recursive bool) ([]fs.FileEntry, error) {
+	client, err := p.Client(root)
+	if err != nil {
+		return nil, err
+	}
+
+	target := root.Join(relPath).Path
+	var entries []fs.FileEntry
+
+	if recursive {
+		walker := client.Walk(target)
+		for walker.Step() {
+			if walker.Err() != nil {
+				continue
+			}
+			info := walker.Stat()
+			if info.IsDir() {
+				if fs.IsIgnoredDir(info.Name()) {
+					walker.SkipDir()
+					continue
+				}
+				if walker.Path() == target {
+					continue
+				}
+			}
+			entries = append(entries, fs.FileEntry{
+				Path:  relativeTo(root.Path, walker.Path()),
+				IsDir: info.IsDir(),
+				Size:  fileSize(info),
+			})
+		}
+		return entries, nil
+	}
+
+	infos, err := client.ReadDir(target)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		entries = append(entries, fs.FileEntry{
+			Path:  relativeTo(root.Path, path.Join(target, info.Name())),
+			IsDir: info.IsDir(),
+			Size:  fileSize(info),
+		})
+	}
+	return entries, nil
+}
+
+// ReadFile reads up to limit bytes from offset (limit <= 0 reads to end)
+func (p *Pool) ReadFile(loc *Location, offset, limit int64) ([]byte, int64, error) {
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("negative offset %d", offset)
+	}
+	client, err := p.Client(loc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	f, err := client.Open(loc.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.IsDir() {
+		return nil, 0, fmt.Errorf("path is a directory")
+	}
+
+	size := info.Size()
+	if offset > size {
+		offset = size
+	}
+	if limit <= 0 || offset+limit > size {
+		limit = size - offset
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, limit)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, 0, err
+	}
+	return buf[:n], size, nil
+}
+
+// WriteFile writes content to a remote file, creating parent directories
+func (p *Pool) WriteFile(loc *Location, content []byte) error {
+	client, err := p.Client(loc)
+	if err != nil {
+		return err
+	}
+
+	if err := client.MkdirAll(path.Dir(loc.Path)); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := client.OpenFile(loc.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(content)
+	return err
+}
+
+// Stat returns file info for a remote path
+func (p *Pool) Stat(loc *Location) (os.FileInfo, error) {
+	client, err := p.Client(loc)
+	if err != nil {
+		return nil, err
+	}
+	return client.Stat(loc.Path)
+}
+
+// Helper functions
+
+// clientConfig authenticates with ssh-agent and unencrypted ~/.ssh keys.
+// The returned func closes the agent connection once the handshake is done.
+func clientConfig(user string) (*ssh.ClientConfig, func(), error) {
+	var methods []ssh.AuthMethod
+	closeAgent := func() {}
+
+	// 优先使用 ssh-agent
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if agentConn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+			closeAgent = func() { agentConn.Close() }
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		data, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			// 跳过带密码的私钥
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if len(methods) == 0 {
+		return nil, nil, fmt.Errorf("no ssh credentials available (ssh-agent or ~/.ssh keys)")
+	}
+
+	hostKeyCallback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		closeAgent()
+		return nil, nil, fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            methods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         10 * time.Second,
+	}, closeAgent, nil
+}
+
+func relativeTo(root, p string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+	if rel == "" {
+		return "."
+	}
+	return rel
+}
+
+func fileSize(info os.FileInfo) int64 {
+	if info.IsDir() {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,70 @@
+package semantic
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// chunkLines is the size of a chunk; consecutive chunks overlap by
+	// chunkOverlap lines so code spanning a boundary is still found
+	chunkLines   = 40
+	chunkOverlap = 8
+	// maxChunkBytes truncates chunks of very long lines
+	maxChunkBytes = 4000
+	// maxIndexedFile skips generated or minified files
+	maxIndexedFile = 256 << 10
+)
+
+// indexedExts are the file types worth embedding
+var indexedExts = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".mjs": true, ".cjs": true, ".vue": true, ".svelte": true, ".rs": true, ".java": true,
+	".kt": true, ".swift": true, ".rb": true, ".php": true, ".c": true, ".h": true,
+	".cc": true, ".cpp": true, ".hpp": true, ".cs": true, ".scala": true, ".dart": true,
+	".lua": true, ".sh": true, ".sql": true, ".md": true, ".proto": true, ".graphql": true,
+	".html": true, ".css": true, ".scss": true, ".yaml": true, ".yml": true, ".toml": true,
+}
+
+// Indexable reports whether a file type is embedded
+func Indexable(path string) bool {
+	return indexedExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// textChunk is a range of lines to embed; lines are 1-based and inclusive
+type textChunk struct {
+	start, end int
+	text       string
+}
+
+// chunkFile splits a file into overlapping line windows. Each chunk's
+// text starts with the file path, which often says what the code is for.
+func chunkFile(rel string, src []byte) []textChunk {
+	if bytes.IndexByte(src, 0) >= 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var chunks []textChunk
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := start + chunkLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+		body := strings.Join(lines[start:end], "")
+		if strings.TrimSpace(body) != "" {
+			if len(body) > maxChunkBytes {
+				body = body[:maxChunkBytes]
+			}
+			chunks = append(chunks, textChunk{start: start + 1, end: end, text: rel + "\n" + body})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
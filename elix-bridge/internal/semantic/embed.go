@@ -0,0 +1,302 @@
+// Package semantic provides embedding-based code search for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package semantic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Embedder turns texts into vectors
+type Embedder interface {
+	// Name identifies the provider and model; vectors from different
+	// names are not comparable
+	Name() string
+	// Embed returns one vector per text
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// BatchSize is the number of texts sent per Embed call
+	BatchSize() int
+}
+
+// Providers that can be passed to NewEmbedder
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+	ProviderHash   = "hash"
+)
+
+// Provider API endpoints; variables so they can point at a proxy
+var (
+	geminiBaseURL    = "https://generativelanguage.googleapis.com/v1beta"
+	openaiBaseURL    = "https://api.openai.com/v1"
+	ollamaDefaultURL = "http://127.0.0.1:11434"
+)
+
+// NewEmbedder creates the embedder for provider. model may be empty for
+// the provider's default; lookup reads API keys and URLs from the config.
+func NewEmbedder(provider, model string, lookup func(string) string) (Embedder, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+	switch provider {
+	case ProviderGemini:
+		key := lookup("GEMINI_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("GEMINI_API_KEY is not set")
+		}
+		if model == "" {
+			model = "gemini-embedding-001"
+		}
+		return &geminiEmbedder{client: client, key: key, model: model}, nil
+	case ProviderOpenAI:
+		key := lookup("OPENAI_API_KEY")
+		base := lookup("OPENAI_BASE_URL")
+		if base == "" {
+			base = openaiBaseURL
+			if key == "" {
+				return nil, fmt.Errorf("OPENAI_API_KEY is not set")
+			}
+		}
+		if model == "" {
+			model = "text-embedding-3-small"
+		}
+		return &openaiEmbedder{client: client, base: strings.TrimRight(base, "/"), key: key, model: model}, nil
+	case ProviderOllama:
+		base := lookup("OLLAMA_HOST")
+		if base == "" {
+			base = ollamaDefaultURL
+		}
+		if !strings.Contains(base, "://") {
+			base = "http://" + base
+		}
+		if model == "" {
+			model = "nomic-embed-text"
+		}
+		return &ollamaEmbedder{client: client, base: strings.TrimRight(base, "/"), model: model}, nil
+	case ProviderHash:
+		return hashEmbedder{}, nil
+	}
+	return nil, fmt.Errorf("unknown embedding provider %q", provider)
+}
+
+// postJSON sends body to url and decodes a 2xx JSON response into out
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("embedding request failed: %d %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type geminiEmbedder struct {
+	client *http.Client
+	key    string
+	model  string
+}
+
+func (e *geminiEmbedder) Name() string   { return ProviderGemini + "/" + e.model }
+func (e *geminiEmbedder) BatchSize() int { return 100 }
+
+func (e *geminiEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	type part struct {
+		Text string `json:"text"`
+	}
+	type request struct {
+		Model   string `json:"model"`
+		Content struct {
+			Parts []part `json:"parts"`
+		} `json:"content"`
+	}
+	reqs := make([]request, len(texts))
+	for i, t := range texts {
+		reqs[i].Model = "models/" + e.model
+		reqs[i].Content.Parts = []part{{Text: t}}
+	}
+
+	var resp struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+	header := http.Header{"X-Goog-Api-Key": {e.key}}
+	url := geminiBaseURL + "/models/" + e.model + ":batchEmbedContents"
+	if err := postJSON(ctx, e.client, url, header, map[string]interface{}{"requests": reqs}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+	out := make([][]float32, len(texts))
+	for i, emb := range resp.Embeddings {
+		out[i] = emb.Values
+	}
+	return out, nil
+}
+
+// openaiEmbedder also serves OpenAI-compatible servers via OPENAI_BASE_URL
+type openaiEmbedder struct {
+	client *http.Client
+	base   string
+	key    string
+	model  string
+}
+
+func (e *openaiEmbedder) Name() string   { return ProviderOpenAI + "/" + e.model }
+func (e *openaiEmbedder) BatchSize() int { return 64 }
+
+func (e *openaiEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	header := http.Header{}
+	if e.key != "" {
+		header.Set("Authorization", "Bearer "+e.key)
+	}
+	body := map[string]interface{}{"model": e.model, "input": texts}
+	if err := postJSON(ctx, e.client, e.base+"/embeddings", header, body, &resp); err != nil {
+		return nil, err
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index >= 0 && d.Index < len(out) {
+			out[d.Index] = d.Embedding
+		}
+	}
+	for i := range out {
+		if out[i] == nil {
+			return nil, fmt.Errorf("missing embedding %d", i)
+		}
+	}
+	return out, nil
+}
+
+type ollamaEmbedder struct {
+	client *http.Client
+	base   string
+	model  string
+}
+
+func (e *ollamaEmbedder) Name() string   { return ProviderOllama + "/" + e.model }
+func (e *ollamaEmbedder) BatchSize() int { return 32 }
+
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	body := map[string]interface{}{"model": e.model, "input": texts}
+	if err := postJSON(ctx, e.client, e.base+"/api/embed", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+	return resp.Embeddings, nil
+}
+
+// hashDims is the vector size of the hash embedder
+const hashDims = 512
+
+// hashEmbedder works offline by hashing identifier parts into a fixed
+// vector. It only matches shared words, not meaning, but needs no model.
+type hashEmbedder struct{}
+
+func (hashEmbedder) Name() string   { return ProviderHash }
+func (hashEmbedder) BatchSize() int { return 256 }
+
+func (hashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v := make([]float32, hashDims)
+		for _, word := range words(t) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			sum := h.Sum32()
+			// 用最高位决定符号，减少碰撞带来的偏差
+			if sum>>31 == 0 {
+				v[sum%hashDims]++
+			} else {
+				v[sum%hashDims]--
+			}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// words splits text into lowercase words, breaking camelCase and
+// snake_case identifiers apart and stemming a trailing "s"
+func words(text string) []string {
+	var out []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 1 {
+			w := strings.ToLower(string(cur))
+			if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+				w = w[:len(w)-1]
+			}
+			out = append(out, w)
+		}
+		cur = cur[:0]
+	}
+	var prev rune
+	for _, r := range text {
+		switch {
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			cur = append(cur, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			cur = append(cur, r)
+		default:
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return out
+}
+
+// normalize scales v to unit length so dot products are cosine similarity
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	n := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= n
+	}
+}
@@ -0,0 +1,390 @@
+package semantic
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bridgefs "echohelix/bridge/internal/fs"
+
+	"github.com/rs/zerolog/log"
+)
+
+// storeVersion is bumped when the on-disk format changes
+const storeVersion = 1
+
+// maxSnippetLines bounds the code returned with a result
+const maxSnippetLines = 30
+
+// ErrRefreshing is returned when a refresh is already running
+var ErrRefreshing = errors.New("index refresh already running")
+
+// Result is a chunk matching a query. Lines are 1-based.
+type Result struct {
+	Path      string  `json:"path"`
+	StartLine int     `json:"start_line"`
+	EndLine   int     `json:"end_line"`
+	Score     float32 `json:"score"`
+	Snippet   string  `json:"snippet"`
+}
+
+// Status describes an index
+type Status struct {
+	Root       string    `json:"root"`
+	Embedder   string    `json:"embedder"`
+	Files      int       `json:"files"`
+	Chunks     int       `json:"chunks"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+	Refreshing bool      `json:"refreshing"`
+}
+
+// RefreshStats reports the work done by a refresh
+type RefreshStats struct {
+	Embedded int `json:"embedded_files"`
+	Removed  int `json:"removed_files"`
+	Chunks   int `json:"embedded_chunks"`
+	Files    int `json:"files"`
+}
+
+type storedChunk struct {
+	Start, End int
+	Vector     []float32
+}
+
+type storedFile struct {
+	ModTime time.Time
+	Size    int64
+	Chunks  []storedChunk
+}
+
+// stored is the gob-encoded index file
+type stored struct {
+	Version  int
+	Root     string
+	Embedder string
+	Updated  time.Time
+	Files    map[string]*storedFile
+}
+
+// Index holds the chunk vectors of one workspace, saved under the data
+// directory and updated incrementally: only files whose size or mtime
+// changed are embedded again.
+type Index struct {
+	root     string
+	path     string
+	embedder Embedder
+
+	mu   sync.RWMutex
+	data stored
+
+	refreshMu  sync.Mutex
+	refreshing bool
+}
+
+// Open loads the index of root from dir, starting empty when none was
+// saved or it was built with a different embedder
+func Open(root, dir string, embedder Embedder) *Index {
+	sum := sha256.Sum256([]byte(root))
+	x := &Index{
+		root:     root,
+		path:     filepath.Join(dir, hex.EncodeToString(sum[:8])+".gob"),
+		embedder: embedder,
+	}
+	x.data = stored{Version: storeVersion, Root: root, Embedder: embedder.Name(), Files: make(map[string]*storedFile)}
+
+	f, err := os.Open(x.path)
+	if err != nil {
+		return x
+	}
+	defer f.Close()
+	var data stored
+	if err := gob.NewDecoder(f).Decode(&data); err != nil {
+		log.Warn().Str("component", "semantic").Err(err).Str("path", x.path).Msg("Ignoring unreadable index")
+		return x
+	}
+	if data.Version == storeVersion && data.Root == root && data.Embedder == embedder.Name() && data.Files != nil {
+		x.data = data
+	}
+	return x
+}
+
+// Root returns the workspace root
+func (x *Index) Root() string {
+	return x.root
+}
+
+// Embedder returns the name of the index's embedder
+func (x *Index) Embedder() string {
+	return x.embedder.Name()
+}
+
+// Status returns the index size and last update
+func (x *Index) Status() Status {
+	x.mu.RLock()
+	st := Status{Root: x.root, Embedder: x.data.Embedder, Files: len(x.data.Files), UpdatedAt: x.data.Updated}
+	for _, f := range x.data.Files {
+		st.Chunks += len(f.Chunks)
+	}
+	x.mu.RUnlock()
+
+	x.refreshMu.Lock()
+	st.Refreshing = x.refreshing
+	x.refreshMu.Unlock()
+	return st
+}
+
+type scanned struct {
+	rel     string
+	modTime time.Time
+	size    int64
+}
+
+// scan lists the indexable files of the workspace
+func (x *Index) scan(ctx context.Context) []scanned {
+	var files []scanned
+	bridgefs.WalkParallel(ctx, x.root, walkOptions, func(e bridgefs.Entry) error {
+		if e.IsDir || !e.Info.Mode().IsRegular() || !Indexable(e.Path) || e.Info.Size() > maxIndexedFile {
+			return nil
+		}
+		files = append(files, scanned{rel: e.Rel, modTime: e.Info.ModTime(), size: e.Info.Size()})
+		return nil
+	})
+	return files
+}
+
+// walkOptions skips ignored and hidden directories and stats files on
+// the walker's workers
+var walkOptions = bridgefs.WalkOptions{
+	SkipDir: func(_ string, d os.DirEntry) bool {
+		return bridgefs.IsIgnoredDir(d.Name()) || strings.HasPrefix(d.Name(), ".")
+	},
+	StatFiles: true,
+}
+
+// Refresh embeds new and changed files and drops deleted ones. progress,
+// if set, is called with the number of changed files embedded so far.
+// Files finished before an error or cancellation are kept.
+func (x *Index) Refresh(ctx context.Context, progress func(done, total int)) (RefreshStats, error) {
+	x.refreshMu.Lock()
+	if x.refreshing {
+		x.refreshMu.Unlock()
+		return RefreshStats{}, ErrRefreshing
+	}
+	x.refreshing = true
+	x.refreshMu.Unlock()
+	defer func() {
+		x.refreshMu.Lock()
+		x.refreshing = false
+		x.refreshMu.Unlock()
+	}()
+
+	files := x.scan(ctx)
+	var stats RefreshStats
+	stats.Files = len(files)
+
+	present := make(map[string]bool, len(files))
+	var changed []scanned
+	x.mu.RLock()
+	for _, f := range files {
+		present[f.rel] = true
+		if old := x.data.Files[f.rel]; old == nil || old.Size != f.size || !old.ModTime.Equal(f.modTime) {
+			changed = append(changed, f)
+		}
+	}
+	for rel := range x.data.Files {
+		if !present[rel] {
+			stats.Removed++
+		}
+	}
+	x.mu.RUnlock()
+
+	var err error
+	done := 0
+	batch := x.embedder.BatchSize()
+	for done < len(changed) && err == nil {
+		// 攒够一批分块再请求，减少往返
+		var group []scanned
+		var chunks [][]textChunk
+		total := 0
+		for done+len(group) < len(changed) && total < batch {
+			f := changed[done+len(group)]
+			src, readErr := os.ReadFile(filepath.Join(x.root, filepath.FromSlash(f.rel)))
+			var c []textChunk
+			if readErr == nil {
+				c = chunkFile(f.rel, src)
+			}
+			group = append(group, f)
+			chunks = append(chunks, c)
+			total += len(c)
+		}
+
+		var vectors [][]float32
+		if vectors, err = x.embedAll(ctx, chunks); err != nil {
+			break
+		}
+
+		x.mu.Lock()
+		for i, f := range group {
+			entry := &storedFile{ModTime: f.modTime, Size: f.size}
+			for _, c := range chunks[i] {
+				v := vectors[0]
+				vectors = vectors[1:]
+				normalize(v)
+				entry.Chunks = append(entry.Chunks, storedChunk{Start: c.start, End: c.end, Vector: v})
+			}
+			x.data.Files[f.rel] = entry
+			stats.Chunks += len(entry.Chunks)
+		}
+		x.mu.Unlock()
+
+		done += len(group)
+		stats.Embedded += len(group)
+		if progress != nil {
+			progress(done, len(changed))
+		}
+	}
+
+	x.mu.Lock()
+	for rel := range x.data.Files {
+		if !present[rel] {
+			delete(x.data.Files, rel)
+		}
+	}
+	x.data.Updated = time.Now()
+	saveErr := x.saveLocked()
+	x.mu.Unlock()
+
+	if err == nil {
+		err = saveErr
+	}
+	return stats, err
+}
+
+// embedAll embeds the chunks of a group of files in batches
+func (x *Index) embedAll(ctx context.Context, groups [][]textChunk) ([][]float32, error) {
+	var texts []string
+	for _, g := range groups {
+		for _, c := range g {
+			texts = append(texts, c.text)
+		}
+	}
+	out := make([][]float32, 0, len(texts))
+	size := x.embedder.BatchSize()
+	for start := 0; start < len(texts); start += size {
+		end := start + size
+		if end > len(texts) {
+			end = len(texts)
+		}
+		vectors, err := x.embedder.Embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vectors...)
+	}
+	return out, nil
+}
+
+func (x *Index) saveLocked() error {
+	if err := os.MkdirAll(filepath.Dir(x.path), 0700); err != nil {
+		return err
+	}
+	tmp := x.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := gob.NewEncoder(w).Encode(&x.data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, x.path)
+}
+
+// Search returns the chunks most similar to query, best first. Every
+// chunk is compared; workspaces are small enough that an exact scan is
+// fast and avoids maintaining an approximate index.
+func (x *Index) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	vectors, err := x.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	q := vectors[0]
+	normalize(q)
+
+	x.mu.RLock()
+	var results []Result
+	for rel, f := range x.data.Files {
+		for _, c := range f.Chunks {
+			if len(c.Vector) != len(q) {
+				continue
+			}
+			var score float32
+			for i, v := range c.Vector {
+				score += v * q[i]
+			}
+			results = append(results, Result{Path: rel, StartLine: c.Start, EndLine: c.End, Score: score})
+		}
+	}
+	x.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].StartLine < results[j].StartLine
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	for i := range results {
+		results[i].Snippet = x.snippet(results[i])
+	}
+	return results, nil
+}
+
+// snippet reads a result's lines from the file
+func (x *Index) snippet(r Result) string {
+	f, err := os.Open(filepath.Join(x.root, filepath.FromSlash(r.Path)))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	end := r.EndLine
+	if end-r.StartLine >= maxSnippetLines {
+		end = r.StartLine + maxSnippetLines - 1
+	}
+	var b strings.Builder
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxIndexedFile)
+	for line := 1; scanner.Scan() && line <= end; line++ {
+		if line >= r.StartLine {
+			b.WriteString(scanner.Text())
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
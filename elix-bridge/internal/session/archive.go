@@ -0,0 +1,135 @@
+package session
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ArchiveDir is the subdirectory of the storage directory holding
+// archived sessions, one gzipped snapshot (<id>.json.gz) each. Archived
+// sessions are no longer loaded at startup.
+const ArchiveDir = "archive"
+
+const archiveExt = snapshotExt + ".gz"
+
+// ArchivedSession describes an archived session file
+type ArchivedSession struct {
+	ID         string    `json:"id"`
+	Size       int64     `json:"size"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// Archive moves a session out of memory into the archive, compressed.
+// With encryption at rest the compressed snapshot is sealed as well.
+func (m *Manager) Archive(id string) error {
+	if m.storageDir == "" {
+		return ErrStorageNotConfigured
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	data, err := json.Marshal(sessionPersisted{Session: session, Messages: m.messages[id]})
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	sealed, err := m.vault.Seal(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(m.storageDir, ArchiveDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, id+archiveExt), sealed); err != nil {
+		return err
+	}
+
+	delete(m.sessions, id)
+	delete(m.messages, id)
+	delete(m.walRecords, id)
+	m.deleteSessionFile(id)
+
+	log.Info().Str("id", id).Msg("Session archived")
+	return nil
+}
+
+// ListArchived returns archived sessions, oldest first
+func (m *Manager) ListArchived() ([]ArchivedSession, error) {
+	if m.storageDir == "" {
+		return nil, ErrStorageNotConfigured
+	}
+	entries, err := os.ReadDir(filepath.Join(m.storageDir, ArchiveDir))
+	if os.IsNotExist(err) {
+		return []ArchivedSession{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]ArchivedSession, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), archiveExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, ArchivedSession{
+			ID:         strings.TrimSuffix(e.Name(), archiveExt),
+			Size:       info.Size(),
+			ArchivedAt: info.ModTime(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ArchivedAt.Before(list[j].ArchivedAt)
+	})
+	return list, nil
+}
+
+// DeleteArchived removes an archived session
+func (m *Manager) DeleteArchived(id string) error {
+	if m.storageDir == "" {
+		return ErrStorageNotConfigured
+	}
+	if id != filepath.Base(id) {
+		return ErrSessionNotFound
+	}
+	err := os.Remove(filepath.Join(m.storageDir, ArchiveDir, id+archiveExt))
+	if os.IsNotExist(err) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+// DiskUsage returns the bytes used on disk by a live session's snapshot,
+// log and timeline
+func (m *Manager) DiskUsage(id string) int64 {
+	var total int64
+	for _, path := range []string{m.snapshotPath(id), m.walPath(id), m.eventsPath(id)} {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
@@ -0,0 +1,125 @@
+package session
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/statefile"
+	"echohelix/bridge/internal/vault"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxDraftSize caps a draft's content
+const maxDraftSize = 256 << 10
+
+// Draft is a prompt being written on one device, kept so it survives
+// the app being closed and can be picked up on another device
+type Draft struct {
+	SessionID string    `json:"session_id"`
+	Device    string    `json:"device"`
+	Content   string    `json:"content"`
+	Files     []string  `json:"files,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErrDraftTooLarge is returned for drafts over maxDraftSize
+var ErrDraftTooLarge = &SessionError{Code: "DRAFT_TOO_LARGE", Message: "Draft is too large"}
+
+// DraftStore keeps drafts by session and device in a state file
+type DraftStore struct {
+	mu     sync.Mutex
+	path   string
+	vault  *vault.Vault
+	drafts map[string]*Draft // sessionID + "/" + device -> draft
+}
+
+// NewDraftStore loads the drafts at path
+func NewDraftStore(path string, key *vault.Vault) *DraftStore {
+	st := &DraftStore{path: path, vault: key, drafts: make(map[string]*Draft)}
+	if err := statefile.Read(path, &st.drafts, key); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("path", path).Msg("Failed to load drafts")
+	}
+	return st
+}
+
+// Save stores a device's draft for a session; empty content without
+// files clears it
+func (st *DraftStore) Save(d Draft) (Draft, error) {
+	if len(d.Content) > maxDraftSize {
+		return Draft{}, ErrDraftTooLarge
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	key := d.SessionID + "/" + d.Device
+	if d.Content == "" && len(d.Files) == 0 {
+		delete(st.drafts, key)
+	} else {
+		d.UpdatedAt = time.Now()
+		st.drafts[key] = &d
+	}
+	return d, st.saveLocked()
+}
+
+// Get returns a device's draft for a session
+func (st *DraftStore) Get(sessionID, device string) (Draft, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	d, ok := st.drafts[sessionID+"/"+device]
+	if !ok {
+		return Draft{}, false
+	}
+	return *d, true
+}
+
+// List returns every device's draft for a session, newest first
+func (st *DraftStore) List(sessionID string) []Draft {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	list := make([]Draft, 0)
+	for _, d := range st.drafts {
+		if d.SessionID == sessionID {
+			list = append(list, *d)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UpdatedAt.After(list[j].UpdatedAt) })
+	return list
+}
+
+// Clear removes a device's draft for a session, e.g. once it was sent
+func (st *DraftStore) Clear(sessionID, device string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	key := sessionID + "/" + device
+	if _, ok := st.drafts[key]; !ok {
+		return
+	}
+	delete(st.drafts, key)
+	if err := st.saveLocked(); err != nil {
+		log.Warn().Err(err).Msg("Failed to save drafts")
+	}
+}
+
+// ClearSession removes every draft of a deleted session
+func (st *DraftStore) ClearSession(sessionID string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	removed := false
+	for key, d := range st.drafts {
+		if d.SessionID == sessionID {
+			delete(st.drafts, key)
+			removed = true
+		}
+	}
+	if removed {
+		if err := st.saveLocked(); err != nil {
+			log.Warn().Err(err).Msg("Failed to save drafts")
+		}
+	}
+}
+
+func (st *DraftStore) saveLocked() error {
+	return statefile.Write(st.path, st.drafts, 0600, st.vault)
+}
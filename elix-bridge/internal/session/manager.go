@@ -11,9 +11,12 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"echohelix/bridge/internal/vault"
+
 	"github.com/rs/zerolog/log"
 )
 
@@ -54,6 +57,7 @@ type Message struct {
 
 // ToolCall represents a tool invocation in a message
 type ToolCall struct {
+	ID        string                 `json:"id,omitempty"`
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments"`
 	Result    string                 `json:"result,omitempty"`
@@ -67,20 +71,35 @@ type Manager struct {
 	mu         sync.RWMutex
 	storageDir string
 	autoSave   bool
+	walRecords map[string]int // sessionID -> 未压缩的日志记录数
+	vault      *vault.Vault
+	stop       chan struct{}
+	closeOnce  sync.Once
+
+	// 统计等订阅方，在释放锁后按顺序调用
+	onCreate  func(sess Session)
+	onMessage func(sess Session, msg Message)
 }
 
 // ManagerConfig configures the session manager
 type ManagerConfig struct {
 	StorageDir string
 	AutoSave   bool
+	// CompactInterval is how often session logs are folded into their
+	// snapshots; 0 means every 5 minutes
+	CompactInterval time.Duration
+	// Vault, when set, encrypts snapshots and log records on disk
+	Vault *vault.Vault
 }
 
 // NewManager creates a new session manager
 func NewManager() *Manager {
 	return &Manager{
-		sessions: make(map[string]*Session),
-		messages: make(map[string][]*Message),
-		autoSave: false,
+		sessions:   make(map[string]*Session),
+		messages:   make(map[string][]*Message),
+		walRecords: make(map[string]int),
+		stop:       make(chan struct{}),
+		autoSave:   false,
 	}
 }
 
@@ -91,6 +110,9 @@ func NewManagerWithConfig(config ManagerConfig) *Manager {
 		messages:   make(map[string][]*Message),
 		storageDir: config.StorageDir,
 		autoSave:   config.AutoSave,
+		walRecords: make(map[string]int),
+		vault:      config.Vault,
+		stop:       make(chan struct{}),
 	}
 
 	// 如果配置了存储目录，尝试加载现有会话
@@ -98,6 +120,13 @@ func NewManagerWithConfig(config ManagerConfig) *Manager {
 		if err := m.LoadAll(); err != nil {
 			log.Warn().Err(err).Msg("Failed to load existing sessions")
 		}
+		if m.autoSave {
+			interval := config.CompactInterval
+			if interval <= 0 {
+				interval = defaultCompactInterval
+			}
+			go m.compactLoop(interval)
+		}
 	}
 
 	return m
@@ -106,7 +135,6 @@ func NewManagerWithConfig(config ManagerConfig) *Manager {
 // Create creates a new session
 func (m *Manager) Create(name, workDir, provider, model string) *Session {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
 	id := generateID()
 	now := time.Now()
@@ -133,10 +161,13 @@ func (m *Manager) Create(name, workDir, provider, model string) *Session {
 		Str("workDir", workDir).
 		Msg("Session created")
 
-	if m.autoSave {
-		go m.saveSession(session)
-	}
+	m.logChangeLocked(session, nil)
+	created, callback := *session, m.onCreate
+	m.mu.Unlock()
 
+	if callback != nil {
+		callback(created)
+	}
 	return session
 }
 
@@ -201,9 +232,7 @@ func (m *Manager) Update(id string, updates map[string]string) (*Session, bool)
 
 	session.UpdatedAt = time.Now()
 
-	if m.autoSave {
-		go m.saveSession(session)
-	}
+	m.logChangeLocked(session, nil)
 
 	return session, true
 }
@@ -219,6 +248,7 @@ func (m *Manager) Delete(id string) bool {
 
 	delete(m.sessions, id)
 	delete(m.messages, id)
+	delete(m.walRecords, id)
 
 	if m.storageDir != "" {
 		go m.deleteSessionFile(id)
@@ -230,39 +260,59 @@ func (m *Manager) Delete(id string) bool {
 
 // AddMessage adds a message to a session
 func (m *Manager) AddMessage(sessionID, role, content string, tokenCount int) (*Message, error) {
+	return m.AppendMessage(sessionID, Message{Role: role, Content: content, TokenCount: tokenCount})
+}
+
+// AppendMessage adds a message with tool calls to a session; its ID,
+// session and timestamp are filled in
+func (m *Manager) AppendMessage(sessionID string, message Message) (*Message, error) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
 	session, ok := m.sessions[sessionID]
 	if !ok {
+		m.mu.Unlock()
 		return nil, ErrSessionNotFound
 	}
 
-	msg := &Message{
-		ID:         generateID(),
-		SessionID:  sessionID,
-		Role:       role,
-		Content:    content,
-		Timestamp:  time.Now(),
-		TokenCount: tokenCount,
-	}
+	msg := &message
+	msg.ID = generateID()
+	msg.SessionID = sessionID
+	msg.Timestamp = time.Now()
 
 	m.messages[sessionID] = append(m.messages[sessionID], msg)
 
 	// 更新会话统计
 	session.MessageCount++
-	session.TokensUsed += int64(tokenCount)
-	session.LastMessage = truncateString(content, 100)
+	session.TokensUsed += int64(msg.TokenCount)
+	session.LastMessage = truncateString(msg.Content, 100)
 	session.UpdatedAt = time.Now()
 	session.Status = StatusActive
 
-	if m.autoSave {
-		go m.saveSession(session)
-	}
+	m.logChangeLocked(session, msg)
+	sess, added, callback := *session, *msg, m.onMessage
+	m.mu.Unlock()
 
+	if callback != nil {
+		callback(sess, added)
+	}
 	return msg, nil
 }
 
+// OnCreate sets callback for new sessions
+func (m *Manager) OnCreate(callback func(sess Session)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onCreate = callback
+}
+
+// OnMessage sets callback for messages added to a session. It runs after
+// the message is stored, in the order messages arrive for a session.
+func (m *Manager) OnMessage(callback func(sess Session, msg Message)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onMessage = callback
+}
+
 // GetMessages returns messages for a session
 func (m *Manager) GetMessages(sessionID string, limit, offset int) ([]*Message, error) {
 	m.mu.RLock()
@@ -287,6 +337,21 @@ func (m *Manager) GetMessages(sessionID string, limit, offset int) ([]*Message,
 	return msgs[offset:end], nil
 }
 
+// GetMessage finds a message of any session by its ID
+func (m *Manager) GetMessage(id string) (*Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, msgs := range m.messages {
+		for _, msg := range msgs {
+			if msg.ID == id {
+				return msg, nil
+			}
+		}
+	}
+	return nil, ErrMessageNotFound
+}
+
 // SetStatus updates session status
 func (m *Manager) SetStatus(id string, status SessionStatus) error {
 	m.mu.Lock()
@@ -300,9 +365,7 @@ func (m *Manager) SetStatus(id string, status SessionStatus) error {
 	session.Status = status
 	session.UpdatedAt = time.Now()
 
-	if m.autoSave {
-		go m.saveSession(session)
-	}
+	m.logChangeLocked(session, nil)
 
 	return nil
 }
@@ -318,17 +381,20 @@ func (m *Manager) GetActive() *Session {
 
 // Persistence functions
 
-// SaveAll saves all sessions to disk
+// SaveAll writes a snapshot of every session and clears their logs
 func (m *Manager) SaveAll() error {
 	if m.storageDir == "" {
 		return ErrStorageNotConfigured
 	}
+	if err := os.MkdirAll(m.storageDir, 0755); err != nil {
+		return err
+	}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	for _, session := range m.sessions {
-		if err := m.saveSession(session); err != nil {
+	for id := range m.sessions {
+		if err := m.compactLocked(id); err != nil {
 			return err
 		}
 	}
@@ -336,6 +402,17 @@ func (m *Manager) SaveAll() error {
 	return nil
 }
 
+// Close stops background compaction and folds pending logs into
+// snapshots
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		if m.storageDir != "" {
+			m.compactAll()
+		}
+	})
+}
+
 // LoadAll loads all sessions from disk
 func (m *Manager) LoadAll() error {
 	if m.storageDir == "" {
@@ -355,20 +432,54 @@ func (m *Manager) LoadAll() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// 会话可能只有日志（上次压缩后才创建）
+	ids := make(map[string]bool)
 	for _, entry := range entries {
-		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
-			continue
+		name := entry.Name()
+		switch {
+		case entry.IsDir():
+		case filepath.Ext(name) == snapshotExt, filepath.Ext(name) == walExt:
+			ids[strings.TrimSuffix(name, filepath.Ext(name))] = true
+		case strings.Contains(name, snapshotExt+".tmp-"):
+			os.Remove(filepath.Join(m.storageDir, name)) // 压缩中途崩溃留下的临时文件
 		}
+	}
 
-		filePath := filepath.Join(m.storageDir, entry.Name())
-		session, messages, err := m.loadSessionFile(filePath)
+	recovered := 0
+	for id := range ids {
+		var session *Session
+		messages := make([]*Message, 0)
+		if _, err := os.Stat(m.snapshotPath(id)); err == nil {
+			session, messages, err = m.loadSessionFile(m.snapshotPath(id))
+			if err != nil {
+				log.Warn().Err(err).Str("file", id+snapshotExt).Msg("Failed to load session snapshot")
+				messages = make([]*Message, 0)
+			}
+		}
+
+		session, messages, applied, err := replayLog(m.walPath(id), session, messages, m.vault)
 		if err != nil {
-			log.Warn().Err(err).Str("file", entry.Name()).Msg("Failed to load session")
+			log.Warn().Err(err).Str("file", id+walExt).Msg("Failed to read session log")
+		}
+		if session == nil {
 			continue
 		}
 
 		m.sessions[session.ID] = session
 		m.messages[session.ID] = messages
+		if applied > 0 {
+			m.walRecords[session.ID] = applied
+			recovered++
+		}
+	}
+
+	if recovered > 0 {
+		log.Info().Int("sessions", recovered).Msg("Recovered session changes from logs")
+		for id := range m.walRecords {
+			if err := m.compactLocked(id); err != nil {
+				log.Warn().Err(err).Str("session", id).Msg("Failed to compact session log")
+			}
+		}
 	}
 
 	log.Info().Int("count", len(m.sessions)).Msg("Sessions loaded")
@@ -381,35 +492,14 @@ type sessionPersisted struct {
 	Messages []*Message `json:"messages"`
 }
 
-func (m *Manager) saveSession(session *Session) error {
-	if m.storageDir == "" {
-		return nil
-	}
-
-	if err := os.MkdirAll(m.storageDir, 0755); err != nil {
-		return err
-	}
-
-	// 同时保存会话和消息
-	persisted := sessionPersisted{
-		Session:  session,
-		Messages: m.messages[session.ID],
-	}
-
-	filePath := filepath.Join(m.storageDir, session.ID+".json")
-	data, err := json.MarshalIndent(persisted, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(filePath, data, 0644)
-}
-
 func (m *Manager) loadSessionFile(filePath string) (*Session, []*Message, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, nil, err
 	}
+	if data, err = m.vault.Open(data); err != nil {
+		return nil, nil, err
+	}
 
 	// 先尝试新格式（包含消息）
 	var persisted sessionPersisted
@@ -435,8 +525,9 @@ func (m *Manager) deleteSessionFile(id string) {
 		return
 	}
 
-	filePath := filepath.Join(m.storageDir, id+".json")
-	os.Remove(filePath)
+	os.Remove(m.snapshotPath(id))
+	os.Remove(m.walPath(id))
+	os.Remove(m.eventsPath(id))
 }
 
 // Helper functions
@@ -457,6 +548,7 @@ func truncateString(s string, maxLen int) string {
 // Errors
 var (
 	ErrSessionNotFound      = &SessionError{Code: "SESSION_NOT_FOUND", Message: "Session not found"}
+	ErrMessageNotFound      = &SessionError{Code: "MESSAGE_NOT_FOUND", Message: "Message not found"}
 	ErrStorageNotConfigured = &SessionError{Code: "STORAGE_NOT_CONFIGURED", Message: "Storage directory not configured"}
 )
 
@@ -0,0 +1,265 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PromptStatus is the state of a queued prompt
+type PromptStatus string
+
+const (
+	PromptQueued   PromptStatus = "queued"
+	PromptRunning  PromptStatus = "running"
+	PromptDone     PromptStatus = "done"
+	PromptFailed   PromptStatus = "failed"
+	PromptCanceled PromptStatus = "canceled"
+)
+
+// QueuedPrompt is a prompt waiting to be sent to a kernel on behalf of
+// a session
+type QueuedPrompt struct {
+	ID         string       `json:"id"`
+	SessionID  string       `json:"session_id"`
+	Kernel     string       `json:"kernel"`
+	DeviceID   string       `json:"device_id,omitempty"`
+	Text       string       `json:"text"`
+	Files      []string     `json:"files,omitempty"`
+	Status     PromptStatus `json:"status"`
+	JobID      string       `json:"job_id,omitempty"`
+	Reply      string       `json:"reply,omitempty"` // 回复的前 200 个字符，完整内容在会话消息中
+	MessageID  string       `json:"message_id,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+}
+
+var (
+	ErrPromptNotFound  = &SessionError{Code: "PROMPT_NOT_FOUND", Message: "Queued prompt not found"}
+	ErrPromptNotQueued = &SessionError{Code: "PROMPT_NOT_QUEUED", Message: "Prompt is already running or finished"}
+)
+
+// PromptQueue holds prompts until they are run, oldest first. Finished
+// prompts are kept so clients can see their outcome.
+type PromptQueue struct {
+	mu          sync.Mutex
+	prompts     map[string]*QueuedPrompt
+	storagePath string
+	maxFinished int
+
+	saveMu sync.Mutex
+}
+
+// NewPromptQueue creates a queue persisted at storagePath. Prompts that
+// were running when the bridge stopped are queued again.
+func NewPromptQueue(storagePath string) *PromptQueue {
+	q := &PromptQueue{
+		prompts:     make(map[string]*QueuedPrompt),
+		storagePath: storagePath,
+		maxFinished: 200,
+	}
+	if err := q.load(); err != nil {
+		log.Warn().Err(err).Msg("Failed to load prompt queue")
+	}
+	for _, p := range q.prompts {
+		if p.Status == PromptRunning {
+			p.Status = PromptQueued
+			p.JobID = ""
+		}
+	}
+	return q
+}
+
+// Add queues a prompt for sessionID
+func (q *PromptQueue) Add(sessionID, kernel, deviceID, text string, files []string) QueuedPrompt {
+	p := &QueuedPrompt{
+		ID:        generateID(),
+		SessionID: sessionID,
+		DeviceID:  deviceID,
+		Kernel:    kernel,
+		Text:      text,
+		Files:     files,
+		Status:    PromptQueued,
+		CreatedAt: time.Now(),
+	}
+	q.mu.Lock()
+	q.prompts[p.ID] = p
+	q.mu.Unlock()
+	q.save()
+	return *p
+}
+
+// Get returns a prompt by ID
+func (q *PromptQueue) Get(id string) (QueuedPrompt, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p, ok := q.prompts[id]
+	if !ok {
+		return QueuedPrompt{}, false
+	}
+	return *p, true
+}
+
+// List returns the prompts of sessionID, or of all sessions when it is
+// empty, oldest first
+func (q *PromptQueue) List(sessionID string) []QueuedPrompt {
+	q.mu.Lock()
+	list := make([]QueuedPrompt, 0, len(q.prompts))
+	for _, p := range q.prompts {
+		if sessionID == "" || p.SessionID == sessionID {
+			list = append(list, *p)
+		}
+	}
+	q.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Next returns the oldest queued prompt
+func (q *PromptQueue) Next() (QueuedPrompt, bool) {
+	for _, p := range q.List("") {
+		if p.Status == PromptQueued {
+			return p, true
+		}
+	}
+	return QueuedPrompt{}, false
+}
+
+// Start marks a queued prompt as running in jobID
+func (q *PromptQueue) Start(id, jobID string) error {
+	q.mu.Lock()
+	p, ok := q.prompts[id]
+	if !ok {
+		q.mu.Unlock()
+		return ErrPromptNotFound
+	}
+	if p.Status != PromptQueued {
+		q.mu.Unlock()
+		return ErrPromptNotQueued
+	}
+	p.Status = PromptRunning
+	p.JobID = jobID
+	q.mu.Unlock()
+	q.save()
+	return nil
+}
+
+// Finish records the outcome of a running prompt: the reply message on
+// success, the error otherwise. A canceled context marks it canceled.
+func (q *PromptQueue) Finish(id string, reply *Message, err error) (QueuedPrompt, bool) {
+	q.mu.Lock()
+	p, ok := q.prompts[id]
+	if !ok {
+		q.mu.Unlock()
+		return QueuedPrompt{}, false
+	}
+	now := time.Now()
+	p.FinishedAt = &now
+	p.Status = PromptDone
+	if reply != nil {
+		p.MessageID = reply.ID
+		p.Reply = truncateString(reply.Content, 200)
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		p.Status = PromptCanceled
+	case err != nil:
+		p.Status = PromptFailed
+		p.Error = err.Error()
+	}
+	q.pruneLocked()
+	snapshot := *p
+	q.mu.Unlock()
+	q.save()
+	return snapshot, true
+}
+
+// Cancel removes a prompt from the queue before it runs
+func (q *PromptQueue) Cancel(id string) (QueuedPrompt, error) {
+	q.mu.Lock()
+	p, ok := q.prompts[id]
+	if !ok {
+		q.mu.Unlock()
+		return QueuedPrompt{}, ErrPromptNotFound
+	}
+	if p.Status != PromptQueued {
+		q.mu.Unlock()
+		return QueuedPrompt{}, ErrPromptNotQueued
+	}
+	now := time.Now()
+	p.Status = PromptCanceled
+	p.FinishedAt = &now
+	q.pruneLocked()
+	snapshot := *p
+	q.mu.Unlock()
+	q.save()
+	return snapshot, nil
+}
+
+// pruneLocked drops the oldest finished prompts beyond maxFinished
+func (q *PromptQueue) pruneLocked() {
+	var finished []*QueuedPrompt
+	for _, p := range q.prompts {
+		if p.FinishedAt != nil {
+			finished = append(finished, p)
+		}
+	}
+	if len(finished) <= q.maxFinished {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].FinishedAt.Before(*finished[j].FinishedAt) })
+	for _, p := range finished[:len(finished)-q.maxFinished] {
+		delete(q.prompts, p.ID)
+	}
+}
+
+func (q *PromptQueue) save() {
+	if q.storagePath == "" {
+		return
+	}
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+	q.mu.Lock()
+	list := make([]QueuedPrompt, 0, len(q.prompts))
+	for _, p := range q.prompts {
+		list = append(list, *p)
+	}
+	q.mu.Unlock()
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(q.storagePath), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(q.storagePath, data, 0600)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to save prompt queue")
+	}
+}
+
+func (q *PromptQueue) load() error {
+	data, err := os.ReadFile(q.storagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var list []*QueuedPrompt
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, p := range list {
+		q.prompts[p.ID] = p
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/statefile"
+	"echohelix/bridge/internal/vault"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Share is a read-only link to one session's transcript. Only a hash of
+// its token is stored; the token itself is returned once, when minted.
+type Share struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	TokenHash string    `json:"token_hash,omitempty"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Views     int       `json:"views"`
+}
+
+// ShareStore keeps share links in a state file
+type ShareStore struct {
+	mu     sync.Mutex
+	path   string
+	vault  *vault.Vault
+	shares []*Share
+}
+
+// NewShareStore loads the share links at path
+func NewShareStore(path string, key *vault.Vault) *ShareStore {
+	st := &ShareStore{path: path, vault: key, shares: make([]*Share, 0)}
+	if err := statefile.Read(path, &st.shares, key); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("path", path).Msg("Failed to load session shares")
+	}
+	return st
+}
+
+// Create mints a share of sessionID valid for ttl and returns it with
+// its token
+func (st *ShareStore) Create(sessionID, createdBy string, ttl time.Duration) (Share, string, error) {
+	b := make([]byte, 24)
+	rand.Read(b)
+	token := "sh_" + hex.EncodeToString(b)
+	now := time.Now()
+	share := &Share{
+		ID:        generateID(),
+		SessionID: sessionID,
+		TokenHash: hashShareToken(token),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.pruneLocked()
+	st.shares = append(st.shares, share)
+	if err := st.saveLocked(); err != nil {
+		st.shares = st.shares[:len(st.shares)-1]
+		return Share{}, "", err
+	}
+	return *share, token, nil
+}
+
+// Resolve returns the unexpired share for token and counts the view
+func (st *ShareStore) Resolve(token string) (Share, bool) {
+	hash := hashShareToken(token)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, share := range st.shares {
+		if share.TokenHash == hash && time.Now().Before(share.ExpiresAt) {
+			share.Views++
+			// 浏览计数只是参考，保存失败不影响访问
+			st.saveLocked()
+			return *share, true
+		}
+	}
+	return Share{}, false
+}
+
+// List returns the unexpired shares, of one session when sessionID is
+// set, newest first
+func (st *ShareStore) List(sessionID string) []Share {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	list := make([]Share, 0)
+	for _, share := range st.shares {
+		if (sessionID == "" || share.SessionID == sessionID) && time.Now().Before(share.ExpiresAt) {
+			list = append(list, *share)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// Revoke removes a share by ID
+func (st *ShareStore) Revoke(id string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for i, share := range st.shares {
+		if share.ID == id {
+			st.shares = append(st.shares[:i], st.shares[i+1:]...)
+			if err := st.saveLocked(); err != nil {
+				log.Warn().Err(err).Msg("Failed to save session shares")
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (st *ShareStore) pruneLocked() {
+	kept := st.shares[:0]
+	for _, share := range st.shares {
+		if time.Now().Before(share.ExpiresAt) {
+			kept = append(kept, share)
+		}
+	}
+	st.shares = kept
+}
+
+func (st *ShareStore) saveLocked() error {
+	return statefile.Write(st.path, st.shares, 0600, st.vault)
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,200 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"echohelix/bridge/internal/vault"
+)
+
+// Besides its messages, a session keeps a timeline, <id>.events, of the
+// tool calls and file changes made during it. The timeline is only ever
+// appended to and is read back to replay the session.
+const eventsExt = ".events"
+
+// Timeline event types
+const (
+	EventToolCall = "tool_call"
+	EventFileEdit = "file_edit"
+	EventCommit   = "commit"
+)
+
+// Event is a tool call or file change made during a session. A tool call
+// is recorded each time its status changes.
+type Event struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Kernel    string    `json:"kernel,omitempty"`
+	ToolCall  *ToolCall `json:"tool_call,omitempty"`
+	// Path and Diff describe a file edit
+	Path    string `json:"path,omitempty"`
+	Diff    string `json:"diff,omitempty"`
+	Applied bool   `json:"applied,omitempty"`
+	// Commit and Message describe a commit
+	Commit  string `json:"commit,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// RecordEvent appends an event to a session's timeline; its timestamp is
+// filled in. Without storage nothing is kept.
+func (m *Manager) RecordEvent(sessionID string, ev Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[sessionID]; !ok {
+		return ErrSessionNotFound
+	}
+	if m.storageDir == "" {
+		return nil
+	}
+	ev.Timestamp = time.Now()
+	line, err := json.Marshal(ev)
+	if err == nil && m.vault.Enabled() {
+		line, err = sealRecord(m.vault, line)
+	}
+	if err != nil {
+		return err
+	}
+	return appendSync(m.eventsPath(sessionID), append(line, '\n'))
+}
+
+// Events returns a session's timeline, oldest first
+func (m *Manager) Events(sessionID string) ([]Event, error) {
+	m.mu.RLock()
+	_, ok := m.sessions[sessionID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if m.storageDir == "" {
+		return []Event{}, nil
+	}
+	return readEvents(m.eventsPath(sessionID), m.vault)
+}
+
+// readEvents reads a timeline; like replayLog it stops at a torn final line
+func readEvents(path string, key *vault.Vault) ([]Event, error) {
+	events := make([]Event, 0)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return events, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '{' {
+			opened, err := openRecord(key, line)
+			if errors.Is(err, vault.ErrLocked) {
+				return nil, err
+			}
+			line = opened
+		}
+		var ev Event
+		if err := json.Unmarshal(line, &ev); err != nil {
+			break
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return events, err
+	}
+	return events, nil
+}
+
+// Replay is a session as it was at a point in time
+type Replay struct {
+	Session  Session    `json:"session"`
+	Until    time.Time  `json:"until"`
+	Messages []*Message `json:"messages"`
+	Events   []Event    `json:"events"`
+	// Files is the state of the workspace diff: every file edited so
+	// far with its edits in order
+	Files []FileHistory `json:"files"`
+}
+
+// FileHistory is what a session did to one file up to a replay point
+type FileHistory struct {
+	Path        string    `json:"path"`
+	Edits       int       `json:"edits"`
+	Diffs       []string  `json:"diffs"`
+	LastChanged time.Time `json:"last_changed"`
+}
+
+// Replay reconstructs a session at until: the messages and timeline
+// events up to then, and the files edited so far. A zero until replays
+// the whole session.
+func (m *Manager) Replay(sessionID string, until time.Time) (*Replay, error) {
+	events, err := m.Events(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+
+	m.mu.RLock()
+	session, ok := m.sessions[sessionID]
+	if !ok {
+		m.mu.RUnlock()
+		return nil, ErrSessionNotFound
+	}
+	r := &Replay{Session: *session, Until: until, Messages: make([]*Message, 0), Events: make([]Event, 0)}
+	for _, msg := range m.messages[sessionID] {
+		if !msg.Timestamp.After(until) {
+			r.Messages = append(r.Messages, msg)
+		}
+	}
+	m.mu.RUnlock()
+	// 统计也回到当时
+	r.Session.MessageCount, r.Session.TokensUsed, r.Session.LastMessage = len(r.Messages), 0, ""
+	for _, msg := range r.Messages {
+		r.Session.TokensUsed += int64(msg.TokenCount)
+		r.Session.LastMessage = truncateString(msg.Content, 100)
+	}
+
+	files := make(map[string]*FileHistory)
+	for _, ev := range events {
+		if ev.Timestamp.After(until) {
+			break
+		}
+		r.Events = append(r.Events, ev)
+		if ev.Type != EventFileEdit || ev.Path == "" {
+			continue
+		}
+		f, ok := files[ev.Path]
+		if !ok {
+			f = &FileHistory{Path: ev.Path, Diffs: make([]string, 0)}
+			files[ev.Path] = f
+		}
+		f.Edits++
+		if ev.Diff != "" {
+			f.Diffs = append(f.Diffs, ev.Diff)
+		}
+		f.LastChanged = ev.Timestamp
+	}
+	r.Files = make([]FileHistory, 0, len(files))
+	for _, f := range files {
+		r.Files = append(r.Files, *f)
+	}
+	sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].Path < r.Files[j].Path })
+	return r, nil
+}
+
+func (m *Manager) eventsPath(id string) string {
+	return filepath.Join(m.storageDir, id+eventsExt)
+}
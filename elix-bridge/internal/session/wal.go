@@ -0,0 +1,241 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"echohelix/bridge/internal/vault"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Each session is stored as a snapshot, <id>.json, and a write-ahead
+// log, <id>.wal, of changes since the snapshot. Changes are appended and
+// fsynced to the log as they happen; compaction folds the log into a new
+// snapshot, written to a temporary file and renamed over the old one, so
+// a crash at any point leaves a complete snapshot plus a log to replay.
+const (
+	walExt      = ".wal"
+	snapshotExt = ".json"
+
+	// compactEvery compacts a session once its log has this many records
+	compactEvery = 200
+	// defaultCompactInterval compacts logs in the background
+	defaultCompactInterval = 5 * time.Minute
+)
+
+// walRecord is one line of a session log: the session's metadata after
+// the change, and the message appended, if any
+type walRecord struct {
+	Session *Session `json:"session"`
+	Message *Message `json:"message,omitempty"`
+}
+
+// logChangeLocked appends a change to the session's log and compacts it
+// when the log is long. Must hold m.mu.
+func (m *Manager) logChangeLocked(session *Session, msg *Message) {
+	if m.storageDir == "" || !m.autoSave {
+		return
+	}
+	snapshot := *session
+	line, err := json.Marshal(walRecord{Session: &snapshot, Message: msg})
+	if err == nil && m.vault.Enabled() {
+		line, err = sealRecord(m.vault, line)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("session", session.ID).Msg("Failed to encode session log record")
+		return
+	}
+	if err := appendSync(m.walPath(session.ID), append(line, '\n')); err != nil {
+		log.Error().Err(err).Str("session", session.ID).Msg("Failed to write session log")
+		return
+	}
+
+	m.walRecords[session.ID]++
+	if m.walRecords[session.ID] >= compactEvery {
+		if err := m.compactLocked(session.ID); err != nil {
+			log.Warn().Err(err).Str("session", session.ID).Msg("Failed to compact session log")
+		}
+	}
+}
+
+// compactLocked writes a fresh snapshot of the session and drops its
+// log. Must hold m.mu.
+func (m *Manager) compactLocked(id string) error {
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil
+	}
+	data, err := json.MarshalIndent(sessionPersisted{Session: session, Messages: m.messages[id]}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = m.vault.Seal(data); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(m.snapshotPath(id), data); err != nil {
+		return err
+	}
+	// 快照落盘后再删除日志；若在此之间崩溃，重放时按消息 ID 去重
+	if err := os.Remove(m.walPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(m.walRecords, id)
+	return nil
+}
+
+// compactAll compacts every session with a pending log
+func (m *Manager) compactAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range m.walRecords {
+		if err := m.compactLocked(id); err != nil {
+			log.Warn().Err(err).Str("session", id).Msg("Failed to compact session log")
+		}
+	}
+}
+
+// compactLoop compacts logs every interval until Close
+func (m *Manager) compactLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.compactAll()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// replayLog applies a session's log on top of its snapshot (session may
+// be nil when the session was created after the last snapshot). A torn
+// final line from a crash mid-append is ignored. It returns the number
+// of records applied.
+func replayLog(path string, session *Session, messages []*Message, key *vault.Vault) (*Session, []*Message, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return session, messages, 0, nil
+		}
+		return session, messages, 0, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		seen[msg.ID] = true
+	}
+
+	applied := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var rec walRecord
+		if line[0] != '{' {
+			// 加密的记录：base64 编码的密文
+			opened, err := openRecord(key, line)
+			if errors.Is(err, vault.ErrLocked) {
+				return session, messages, applied, err
+			}
+			line = opened
+		}
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Session == nil {
+			log.Warn().Str("file", filepath.Base(path)).Int("applied", applied).Msg("Session log ends with an incomplete record, ignoring the rest")
+			break
+		}
+		session = rec.Session
+		if rec.Message != nil && !seen[rec.Message.ID] {
+			seen[rec.Message.ID] = true
+			messages = append(messages, rec.Message)
+		}
+		applied++
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return session, messages, applied, err
+	}
+	return session, messages, applied, nil
+}
+
+// sealRecord encrypts a log record, keeping it on one line
+func sealRecord(key *vault.Vault, line []byte) ([]byte, error) {
+	sealed, err := key.Seal(line)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func openRecord(key *vault.Vault, line []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil {
+		return nil, err
+	}
+	return key.Open(sealed)
+}
+
+func (m *Manager) walPath(id string) string {
+	return filepath.Join(m.storageDir, id+walExt)
+}
+
+func (m *Manager) snapshotPath(id string) string {
+	return filepath.Join(m.storageDir, id+snapshotExt)
+}
+
+// appendSync appends data to the file at path and fsyncs it
+func appendSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeFileAtomic replaces path with data via a synced temporary file
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后为空操作
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
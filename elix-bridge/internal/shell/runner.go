@@ -0,0 +1,471 @@
+// Package shell provides shell command execution for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package shell
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RunStatus represents the state of a run
+type RunStatus string
+
+const (
+	StatusRunning  RunStatus = "running"
+	StatusSuccess  RunStatus = "success"
+	StatusFailed   RunStatus = "failed"
+	StatusTimedOut RunStatus = "timed_out"
+	StatusCanceled RunStatus = "canceled"
+)
+
+// Chunk is a piece of streamed output
+type Chunk struct {
+	Stream string `json:"stream"` // "stdout", "stderr", "exit"
+	Data   string `json:"data"`
+}
+
+// Request describes a command to run
+type Request struct {
+	Command string            `json:"command"`
+	Dir     string            `json:"cwd"`
+	Env     map[string]string `json:"env,omitempty"`
+	Timeout time.Duration     `json:"-"`
+	Source  string            `json:"source,omitempty"` // 触发来源，如 "exec"、"task"
+}
+
+// RunInfo is the recorded state of a command execution
+type RunInfo struct {
+	ID         string     `json:"id"`
+	Command    string     `json:"command"`
+	Dir        string     `json:"cwd"`
+	Source     string     `json:"source,omitempty"`
+	PID        int        `json:"pid,omitempty"`
+	Status     RunStatus  `json:"status"`
+	ExitCode   int        `json:"exit_code"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Output     string     `json:"output,omitempty"` // 输出尾部，仅完成后保存
+}
+
+// Run is a single command execution with its live output stream
+type Run struct {
+	RunInfo
+
+	mu     sync.Mutex
+	chunks []Chunk
+	size   int
+	subs   map[chan Chunk]struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Runner executes commands and keeps a bounded history of runs
+type Runner struct {
+	mu          sync.RWMutex
+	runs        map[string]*Run
+	storagePath string
+	maxRuns     int
+}
+
+const (
+	defaultTimeout = 10 * time.Minute
+	maxBuffered    = 1 << 20  // 单次运行在内存中保留的输出上限
+	maxPersisted   = 64 << 10 // 持久化的输出尾部长度
+)
+
+// NewRunner creates a runner. History is persisted to storagePath when set.
+func NewRunner(storagePath string, maxRuns int) *Runner {
+	if maxRuns <= 0 {
+		maxRuns = 100
+	}
+	r := &Runner{
+		runs:        make(map[string]*Run),
+		storagePath: storagePath,
+		maxRuns:     maxRuns,
+	}
+	if err := r.load(); err != nil {
+		log.Warn().Err(err).Msg("Failed to load run history")
+	}
+	return r
+}
+
+// Start launches the command asynchronously and returns its run record
+func (r *Runner) Start(req Request) (*Run, error) {
+	if strings.TrimSpace(req.Command) == "" {
+		return nil, ErrEmptyCommand
+	}
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	cmd := Command(ctx, req.Command)
+	cmd.Dir = req.Dir
+	cmd.Env = os.Environ()
+	for k, v := range req.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	run := &Run{
+		RunInfo: RunInfo{
+			ID:        generateID(),
+			Command:   req.Command,
+			Dir:       req.Dir,
+			Source:    req.Source,
+			Status:    StatusRunning,
+			StartedAt: time.Now(),
+		},
+		subs:   make(map[chan Chunk]struct{}),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	cmd.Stdout = &streamWriter{run: run, stream: "stdout"}
+	cmd.Stderr = &streamWriter{run: run, stream: "stderr"}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	run.PID = cmd.Process.Pid
+
+	r.mu.Lock()
+	r.runs[run.ID] = run
+	r.pruneLocked()
+	r.mu.Unlock()
+
+	log.Info().Str("id", run.ID).Str("cmd", req.Command).Str("cwd", req.Dir).Msg("Command started")
+
+	go func() {
+		defer cancel()
+		err := cmd.Wait()
+
+		status := StatusSuccess
+		exitCode := 0
+		if err != nil {
+			status = StatusFailed
+			exitCode = -1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				exitCode = exitErr.ExitCode()
+			}
+			switch ctx.Err() {
+			case context.DeadlineExceeded:
+				status = StatusTimedOut
+			case context.Canceled:
+				status = StatusCanceled
+			}
+		}
+		run.finish(status, exitCode)
+
+		log.Info().Str("id", run.ID).Str("status", string(status)).Int("exit", exitCode).Msg("Command finished")
+		if err := r.save(); err != nil {
+			log.Warn().Err(err).Msg("Failed to save run history")
+		}
+	}()
+
+	return run, nil
+}
+
+// Get returns a run by ID
+func (r *Runner) Get(id string) (*Run, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	run, ok := r.runs[id]
+	return run, ok
+}
+
+// List returns runs newest first
+func (r *Runner) List() []*Run {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	runs := make([]*Run, 0, len(r.runs))
+	for _, run := range r.runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	return runs
+}
+
+// RunningPIDs returns the process IDs of the commands still running
+func (r *Runner) RunningPIDs() []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var pids []int
+	for _, run := range r.runs {
+		info := run.Snapshot()
+		if info.Status == StatusRunning && info.PID != 0 {
+			pids = append(pids, info.PID)
+		}
+	}
+	return pids
+}
+
+// Cancel stops a running command
+func (r *Runner) Cancel(id string) bool {
+	run, ok := r.Get(id)
+	if !ok || run.cancel == nil {
+		return false
+	}
+	run.cancel()
+	return true
+}
+
+// Snapshot returns a copy of the run's recorded state, safe to encode
+func (run *Run) Snapshot() RunInfo {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return run.RunInfo
+}
+
+// Subscribe returns buffered output so far plus a channel for new chunks.
+// The channel is closed when the run finishes; call cancel to unsubscribe early.
+func (run *Run) Subscribe() (replay []Chunk, ch <-chan Chunk, cancel func()) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	replay = make([]Chunk, len(run.chunks))
+	copy(replay, run.chunks)
+
+	c := make(chan Chunk, 256)
+	if run.done == nil || isClosed(run.done) {
+		// 已完成的运行（包括从磁盘加载的）只回放
+		if len(replay) == 0 && run.Output != "" {
+			replay = append(replay, Chunk{Stream: "stdout", Data: run.Output})
+		}
+		close(c)
+		return replay, c, func() {}
+	}
+
+	run.subs[c] = struct{}{}
+	return replay, c, func() {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+		if _, ok := run.subs[c]; ok {
+			delete(run.subs, c)
+			close(c)
+		}
+	}
+}
+
+// Wait blocks until the run finishes
+func (run *Run) Wait() {
+	if run.done != nil {
+		<-run.done
+	}
+}
+
+func (run *Run) publish(c Chunk) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	if run.size < maxBuffered {
+		run.chunks = append(run.chunks, c)
+		run.size += len(c.Data)
+	}
+	for sub := range run.subs {
+		select {
+		case sub <- c:
+		default:
+			// 慢订阅者丢弃数据，避免阻塞进程输出
+		}
+	}
+}
+
+func (run *Run) finish(status RunStatus, exitCode int) {
+	now := time.Now()
+	exitChunk := Chunk{Stream: "exit", Data: string(status)}
+	run.publish(exitChunk)
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	run.Status = status
+	run.ExitCode = exitCode
+	run.FinishedAt = &now
+
+	var sb strings.Builder
+	for _, c := range run.chunks {
+		if c.Stream != "exit" {
+			sb.WriteString(c.Data)
+		}
+	}
+	out := sb.String()
+	if len(out) > maxPersisted {
+		out = out[len(out)-maxPersisted:]
+	}
+	run.Output = out
+
+	for sub := range run.subs {
+		close(sub)
+		delete(run.subs, sub)
+	}
+	close(run.done)
+}
+
+func (r *Runner) pruneLocked() {
+	if len(r.runs) <= r.maxRuns {
+		return
+	}
+	runs := make([]*Run, 0, len(r.runs))
+	for _, run := range r.runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.Before(runs[j].StartedAt)
+	})
+	for _, run := range runs[:len(runs)-r.maxRuns] {
+		if run.Snapshot().Status != StatusRunning {
+			delete(r.runs, run.ID)
+		}
+	}
+}
+
+// Persistence
+
+func (r *Runner) save() error {
+	if r.storagePath == "" {
+		return nil
+	}
+
+	r.mu.RLock()
+	records := make([]RunInfo, 0, len(r.runs))
+	for _, run := range r.runs {
+		snap := run.Snapshot()
+		if snap.Status != StatusRunning {
+			records = append(records, snap)
+		}
+	}
+	r.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(r.storagePath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.storagePath, data, 0644)
+}
+
+func (r *Runner) load() error {
+	if r.storagePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.storagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var records []RunInfo
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, info := range records {
+		r.runs[info.ID] = &Run{
+			RunInfo: info,
+			subs:    make(map[chan Chunk]struct{}),
+		}
+	}
+	return nil
+}
+
+// Helper functions
+
+// Command builds a platform shell invocation for a command line
+func Command(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// IsAllowed reports whether command matches an allowlist entry.
+// Entries match whole leading words ("go test" allows "go test ./..."),
+// and commands that chain or substitute other commands never match.
+func IsAllowed(command string, allowlist []string) bool {
+	command = strings.TrimSpace(command)
+	if strings.ContainsAny(command, ";&|`<>\n") || strings.Contains(command, "$(") {
+		return false
+	}
+	for _, entry := range allowlist {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if command == entry || strings.HasPrefix(command, entry+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+type streamWriter struct {
+	run    *Run
+	stream string
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.run.publish(Chunk{Stream: w.stream, Data: string(p)})
+	return len(p), nil
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func generateID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// Errors
+var (
+	ErrEmptyCommand = &ShellError{Code: "EMPTY_COMMAND", Message: "Command is required"}
+	ErrNotAllowed   = &ShellError{Code: "NOT_ALLOWED", Message: "Command is not in the allowlist; repeat with confirm=true"}
+	ErrRunNotFound  = &ShellError{Code: "RUN_NOT_FOUND", Message: "Run not found"}
+)
+
+// ShellError represents a shell-related error
+type ShellError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ShellError) Error() string {
+	return e.Message
+}
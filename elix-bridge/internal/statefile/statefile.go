@@ -0,0 +1,169 @@
+// Package statefile provides crash-safe JSON state files for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package statefile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"echohelix/bridge/internal/vault"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BackupSuffix is appended to a state file's path for its previous good copy
+const BackupSuffix = ".bak"
+
+// ErrChecksum is returned when a state file's contents do not match its checksum
+var ErrChecksum = errors.New("state file checksum mismatch")
+
+// envelope wraps the state with a SHA-256 of its compact JSON, so a torn
+// or bit-rotted file is detected instead of half loaded
+type envelope struct {
+	Checksum string          `json:"checksum"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Write stores v at path, sealed with key when it is not nil. The file is
+// written to a synced temporary file and renamed into place; the copy it
+// replaces, if it was valid, is kept at path+BackupSuffix for Read to
+// fall back to.
+func Write(path string, v interface{}, perm os.FileMode, key *vault.Vault) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	out, err := json.MarshalIndent(envelope{Checksum: hex.EncodeToString(sum[:]), Data: data}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if out, err = key.Seal(out); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// 只轮换校验通过的旧文件，避免用损坏的文件覆盖备份
+	if old, err := os.ReadFile(path); err == nil {
+		if _, err := decode(old, key); err == nil {
+			if err := os.Rename(path, path+BackupSuffix); err != nil {
+				return fmt.Errorf("failed to keep backup: %w", err)
+			}
+		}
+	}
+	return WriteFileAtomic(path, out, perm)
+}
+
+// Read loads the state at path into v, opening it with key if it was
+// sealed. When the file is missing, torn or fails its checksum, the
+// backup copy is used instead. Files written before checksums were added
+// are accepted as plain JSON. The error wraps os.ErrNotExist when neither
+// copy exists.
+func Read(path string, v interface{}, key *vault.Vault) error {
+	err := readInto(path, v, key)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, vault.ErrLocked) || errors.Is(err, vault.ErrWrongKey) {
+		return err // 备份同样无法解密
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Str("file", path).Msg("State file is damaged, falling back to backup")
+	}
+
+	backupErr := readInto(path+BackupSuffix, v, key)
+	if backupErr == nil {
+		log.Warn().Str("file", path+BackupSuffix).Msg("Loaded state from backup")
+		return nil
+	}
+	if errors.Is(backupErr, os.ErrNotExist) {
+		return err
+	}
+	return fmt.Errorf("%w (backup: %v)", err, backupErr)
+}
+
+func readInto(path string, v interface{}, key *vault.Vault) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err := decode(raw, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// decode opens and verifies a state file and returns the JSON of the state
+func decode(raw []byte, key *vault.Vault) ([]byte, error) {
+	raw, err := key.Open(raw)
+	if err != nil {
+		return nil, err
+	}
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return raw, nil // 旧格式，顶层不是对象
+		}
+		return nil, err
+	}
+	if env.Checksum == "" || env.Data == nil {
+		return raw, nil // 旧格式，无校验和
+	}
+	// 校验和按紧凑格式计算，与文件中的缩进无关
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, env.Data); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	if hex.EncodeToString(sum[:]) != env.Checksum {
+		return nil, ErrChecksum
+	}
+	return env.Data, nil
+}
+
+// WriteFileAtomic replaces path with data via a synced temporary file,
+// then syncs the directory so the rename itself survives a crash
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后为空操作
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+// Package stats provides session statistics for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package stats
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/session"
+
+	"github.com/rs/zerolog/log"
+)
+
+// dateLayout keys days in the bridge's local time zone, as the usage
+// ledger does
+const dateLayout = "2006-01-02"
+
+// keepDays is how long daily statistics and idle sessions are kept
+const keepDays = 90
+
+// topWorkspaces is how many of the busiest workspaces Overview lists
+const topWorkspaces = 5
+
+// Counts is the activity of a day, provider, model or workspace
+type Counts struct {
+	Sessions int   `json:"sessions"`
+	Messages int   `json:"messages"`
+	Tokens   int64 `json:"tokens"`
+}
+
+type day struct {
+	Counts
+	Providers map[string]*Counts `json:"providers"`
+	Models    map[string]*Counts `json:"models"` // provider/model
+}
+
+// sessionStat is what the aggregator needs to know of a session to
+// update the statistics when its next message arrives
+type sessionStat struct {
+	Workspace string    `json:"workspace"`
+	Counted   bool      `json:"counted"` // 已计入会话数
+	First     time.Time `json:"first"`
+	Last      time.Time `json:"last"` // 最后活动：创建或最后一条消息
+	Messages  int       `json:"messages"`
+}
+
+// totals are all-time counts, including sessions deleted or archived
+// since
+type totals struct {
+	Counts
+	// DurationSum adds up each session's time from its first message to
+	// its last; Timed counts the sessions with two or more messages
+	DurationSum float64 `json:"duration_sum"`
+	Timed       int     `json:"timed"`
+}
+
+// Aggregator keeps session statistics up to date as sessions are
+// created and messages arrive, so reading them never walks the
+// transcripts. The statistics are saved to storagePath by Save.
+type Aggregator struct {
+	mu          sync.Mutex
+	days        map[string]*day
+	sessions    map[string]*sessionStat
+	workspaces  map[string]*Counts
+	total       totals
+	storagePath string
+	loaded      bool
+	dirty       bool
+
+	saveMu sync.Mutex
+}
+
+type statsFile struct {
+	Days       map[string]*day         `json:"days"`
+	Sessions   map[string]*sessionStat `json:"sessions"`
+	Workspaces map[string]*Counts      `json:"workspaces"`
+	Total      totals                  `json:"total"`
+}
+
+// NewAggregator creates an aggregator persisted at storagePath
+func NewAggregator(storagePath string) *Aggregator {
+	a := &Aggregator{
+		days:        make(map[string]*day),
+		sessions:    make(map[string]*sessionStat),
+		workspaces:  make(map[string]*Counts),
+		storagePath: storagePath,
+	}
+	if err := a.load(); err != nil {
+		log.Warn().Err(err).Msg("Failed to load session statistics")
+	}
+	return a
+}
+
+// Loaded reports whether statistics were read from disk. When they were
+// not, the caller backfills them from the existing sessions.
+func (a *Aggregator) Loaded() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.loaded
+}
+
+// SessionCreated counts a new session
+func (a *Aggregator) SessionCreated(sess session.Session) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st := a.sessionLocked(sess)
+	if st.Counted {
+		return
+	}
+	st.Counted = true
+	if st.Last.IsZero() {
+		st.Last = sess.CreatedAt
+	}
+	a.total.Sessions++
+	d := a.dayLocked(sess.CreatedAt)
+	d.Sessions++
+	counter(d.Providers, sess.Provider).Sessions++
+	counter(d.Models, sess.Provider+"/"+sess.Model).Sessions++
+	counter(a.workspaces, st.Workspace).Sessions++
+	a.dirty = true
+}
+
+// MessageAdded counts a message of sess toward its day, provider, model
+// and workspace
+func (a *Aggregator) MessageAdded(sess session.Session, msg session.Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st := a.sessionLocked(sess)
+	at := msg.Timestamp
+	if st.Messages == 0 {
+		st.First = at
+	} else if d := at.Sub(st.Last).Seconds(); d > 0 {
+		a.total.DurationSum += d
+	}
+	if st.Messages == 1 {
+		a.total.Timed++
+	}
+	st.Messages++
+	st.Last = at
+
+	tokens := int64(msg.TokenCount)
+	a.total.Messages++
+	a.total.Tokens += tokens
+	d := a.dayLocked(at)
+	d.Messages++
+	d.Tokens += tokens
+	for _, c := range []*Counts{counter(d.Providers, sess.Provider), counter(d.Models, sess.Provider+"/"+sess.Model), counter(a.workspaces, st.Workspace)} {
+		c.Messages++
+		c.Tokens += tokens
+	}
+	a.dirty = true
+}
+
+// Backfill counts the existing sessions and their messages, for a bridge
+// that had no statistics yet
+func (a *Aggregator) Backfill(sessions []*session.Session, messages func(id string) []*session.Message) {
+	for _, sess := range sessions {
+		a.SessionCreated(*sess)
+		for _, msg := range messages(sess.ID) {
+			a.MessageAdded(*sess, *msg)
+		}
+	}
+	log.Info().Int("sessions", len(sessions)).Msg("Session statistics backfilled")
+}
+
+func (a *Aggregator) sessionLocked(sess session.Session) *sessionStat {
+	st := a.sessions[sess.ID]
+	if st == nil {
+		st = &sessionStat{Workspace: sess.WorkingDirectory}
+		if st.Workspace == "" {
+			st.Workspace = "."
+		}
+		a.sessions[sess.ID] = st
+	}
+	return st
+}
+
+func (a *Aggregator) dayLocked(t time.Time) *day {
+	date := t.Format(dateLayout)
+	d := a.days[date]
+	if d == nil {
+		d = &day{Providers: make(map[string]*Counts), Models: make(map[string]*Counts)}
+		a.days[date] = d
+	}
+	return d
+}
+
+func counter(m map[string]*Counts, key string) *Counts {
+	c := m[key]
+	if c == nil {
+		c = &Counts{}
+		m[key] = c
+	}
+	return c
+}
+
+// Overview is the activity of recent days with all-time totals
+type Overview struct {
+	WindowDays int   `json:"window_days"`
+	Sessions   int   `json:"sessions"`
+	Messages   int   `json:"messages"`
+	Tokens     int64 `json:"tokens"`
+	// AvgMessages is per session; AvgSessionSeconds is the time from
+	// first to last message of sessions with two or more messages
+	AvgMessages       float64 `json:"avg_messages_per_session"`
+	AvgSessionSeconds float64 `json:"avg_session_seconds"`
+	// Days are the window's days, oldest first, including idle ones
+	Days []DayCounts `json:"days"`
+	// Providers and Models break the window down, busiest first
+	Providers []Named `json:"providers"`
+	Models    []Named `json:"models"`
+	// Workspaces are the busiest workspaces of all time
+	Workspaces []Named `json:"workspaces"`
+}
+
+// DayCounts is the activity of one day
+type DayCounts struct {
+	Date string `json:"date"`
+	Counts
+}
+
+// Named is the activity of a provider, model or workspace
+type Named struct {
+	Name string `json:"name"`
+	Counts
+}
+
+// Overview sums up the last days days, up to today
+func (a *Aggregator) Overview(days int) Overview {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	o := Overview{
+		WindowDays: days,
+		Sessions:   a.total.Sessions,
+		Messages:   a.total.Messages,
+		Tokens:     a.total.Tokens,
+		Days:       make([]DayCounts, 0, days),
+	}
+	if a.total.Sessions > 0 {
+		o.AvgMessages = float64(a.total.Messages) / float64(a.total.Sessions)
+	}
+	if a.total.Timed > 0 {
+		o.AvgSessionSeconds = a.total.DurationSum / float64(a.total.Timed)
+	}
+
+	providers := make(map[string]*Counts)
+	models := make(map[string]*Counts)
+	now := time.Now()
+	for i := days - 1; i >= 0; i-- {
+		date := now.AddDate(0, 0, -i).Format(dateLayout)
+		dc := DayCounts{Date: date}
+		if d := a.days[date]; d != nil {
+			dc.Counts = d.Counts
+			merge(providers, d.Providers)
+			merge(models, d.Models)
+		}
+		o.Days = append(o.Days, dc)
+	}
+	o.Providers = ranked(providers, 0)
+	o.Models = ranked(models, 0)
+	o.Workspaces = ranked(a.workspaces, topWorkspaces)
+	return o
+}
+
+func merge(dst, src map[string]*Counts) {
+	for k, c := range src {
+		d := dst[k]
+		if d == nil {
+			d = &Counts{}
+			dst[k] = d
+		}
+		d.Sessions += c.Sessions
+		d.Messages += c.Messages
+		d.Tokens += c.Tokens
+	}
+}
+
+// ranked lists m by messages, busiest first, keeping the first n (all
+// when n is 0)
+func ranked(m map[string]*Counts, n int) []Named {
+	list := make([]Named, 0, len(m))
+	for name, c := range m {
+		list = append(list, Named{Name: name, Counts: *c})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Messages != list[j].Messages {
+			return list[i].Messages > list[j].Messages
+		}
+		return list[i].Name < list[j].Name
+	})
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// Save writes the statistics when they changed since the last save,
+// dropping days and idle sessions older than keepDays
+func (a *Aggregator) Save() {
+	if a.storagePath == "" {
+		return
+	}
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+
+	a.mu.Lock()
+	if !a.dirty {
+		a.mu.Unlock()
+		return
+	}
+	a.pruneLocked()
+	data, err := json.MarshalIndent(statsFile{
+		Days:       a.days,
+		Sessions:   a.sessions,
+		Workspaces: a.workspaces,
+		Total:      a.total,
+	}, "", "  ")
+	a.dirty = false
+	a.mu.Unlock()
+
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(a.storagePath), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(a.storagePath, data, 0600)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to save session statistics")
+	}
+}
+
+func (a *Aggregator) pruneLocked() {
+	cutoff := time.Now().AddDate(0, 0, -keepDays)
+	for date := range a.days {
+		if date < cutoff.Format(dateLayout) {
+			delete(a.days, date)
+		}
+	}
+	// 长期未活动的会话不再需要增量信息；其数据已计入总数
+	for id, st := range a.sessions {
+		if st.Last.Before(cutoff) {
+			delete(a.sessions, id)
+		}
+	}
+}
+
+func (a *Aggregator) load() error {
+	data, err := os.ReadFile(a.storagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var f statsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	if f.Days != nil {
+		a.days = f.Days
+	}
+	if f.Sessions != nil {
+		a.sessions = f.Sessions
+	}
+	if f.Workspaces != nil {
+		a.workspaces = f.Workspaces
+	}
+	a.total = f.Total
+	a.loaded = true
+	return nil
+}
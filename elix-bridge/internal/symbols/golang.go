@@ -0,0 +1,125 @@
+package symbols
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// outlineGo uses the standard Go parser; methods are nested under their
+// receiver type when it is declared in the same file
+func outlineGo(src []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if file == nil {
+		return nil, err
+	}
+	// 语法错误时仍返回已解析部分
+
+	line := func(p token.Pos) int { return fset.Position(p).Line - 1 }
+	var symbols []Symbol
+	types := make(map[string]int) // 类型名 -> symbols 下标
+	var methods []Symbol
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			s := Symbol{
+				Name:    d.Name.Name,
+				Kind:    KindFunction,
+				Detail:  funcSignature(src, fset, d),
+				Line:    line(d.Pos()),
+				EndLine: line(d.End()),
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				s.Kind = KindMethod
+				s.Container = receiverType(d.Recv.List[0].Type)
+				methods = append(methods, s)
+				continue
+			}
+			symbols = append(symbols, s)
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					s := Symbol{Name: sp.Name.Name, Kind: KindType, Line: line(sp.Pos()), EndLine: line(sp.End())}
+					switch t := sp.Type.(type) {
+					case *ast.StructType:
+						s.Kind = KindStruct
+						s.Children = goFields(t.Fields, line)
+					case *ast.InterfaceType:
+						s.Kind = KindInterface
+						s.Children = goFields(t.Methods, line)
+					}
+					types[s.Name] = len(symbols)
+					symbols = append(symbols, s)
+				case *ast.ValueSpec:
+					kind := KindVar
+					if d.Tok == token.CONST {
+						kind = KindConst
+					}
+					for _, name := range sp.Names {
+						if name.Name == "_" {
+							continue
+						}
+						symbols = append(symbols, Symbol{Name: name.Name, Kind: kind, Line: line(name.Pos()), EndLine: line(sp.End())})
+					}
+				}
+			}
+		}
+	}
+
+	for _, m := range methods {
+		if i, ok := types[m.Container]; ok {
+			m.Container = ""
+			symbols[i].Children = append(symbols[i].Children, m)
+			continue
+		}
+		symbols = append(symbols, m)
+	}
+	return symbols, nil
+}
+
+func goFields(list *ast.FieldList, line func(token.Pos) int) []Symbol {
+	if list == nil {
+		return nil
+	}
+	var out []Symbol
+	for _, f := range list.List {
+		for _, name := range f.Names {
+			kind := KindField
+			if _, ok := f.Type.(*ast.FuncType); ok {
+				kind = KindMethod
+			}
+			out = append(out, Symbol{Name: name.Name, Kind: kind, Line: line(name.Pos()), EndLine: line(f.End())})
+		}
+	}
+	return out
+}
+
+// receiverType returns the type name of a method receiver
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// funcSignature returns the declaration line without the body
+func funcSignature(src []byte, fset *token.FileSet, d *ast.FuncDecl) string {
+	start := fset.Position(d.Pos()).Offset
+	end := fset.Position(d.Type.End()).Offset
+	if start < 0 || end > len(src) || start >= end {
+		return ""
+	}
+	return strings.Join(strings.Fields(string(src[start:end])), " ")
+}
@@ -0,0 +1,182 @@
+package symbols
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bridgefs "echohelix/bridge/internal/fs"
+)
+
+// ErrUnsupported is returned for files the bridge cannot outline itself
+var ErrUnsupported = errors.New("outline not supported for this file type")
+
+// maxIndexedFile skips generated or minified files
+const maxIndexedFile = 1 << 20
+
+type indexedFile struct {
+	modTime time.Time
+	size    int64
+	symbols []Symbol // 已展开，带 Path
+}
+
+// Index keeps the symbols of every supported file in a workspace,
+// re-parsing only files that changed since the last refresh
+type Index struct {
+	root string
+
+	mu        sync.Mutex
+	files     map[string]*indexedFile
+	refreshed time.Time
+	minAge    time.Duration
+}
+
+// NewIndex creates an index for the workspace at root
+func NewIndex(root string) *Index {
+	return &Index{
+		root:   root,
+		files:  make(map[string]*indexedFile),
+		minAge: 5 * time.Second,
+	}
+}
+
+// Root returns the workspace root
+func (x *Index) Root() string {
+	return x.root
+}
+
+// Refresh rescans the workspace unless it was scanned within the last
+// few seconds
+func (x *Index) Refresh() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if time.Since(x.refreshed) < x.minAge {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(x.files))
+	opts := bridgefs.WalkOptions{
+		SkipDir: func(_ string, d os.DirEntry) bool {
+			return bridgefs.IsIgnoredDir(d.Name()) || strings.HasPrefix(d.Name(), ".")
+		},
+		StatFiles: true,
+	}
+	err := bridgefs.WalkParallel(context.Background(), x.root, opts, func(e bridgefs.Entry) error {
+		if e.IsDir || !Supported(e.Path) {
+			return nil
+		}
+		path, rel, info := e.Path, e.Rel, e.Info
+		if info.Size() > maxIndexedFile {
+			return nil
+		}
+		seen[rel] = true
+		if f := x.files[rel]; f != nil && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+			return nil
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		outline, _ := Outline(path, src)
+		flat := Flatten(outline)
+		for i := range flat {
+			flat[i].Path = rel
+		}
+		x.files[rel] = &indexedFile{modTime: info.ModTime(), size: info.Size(), symbols: flat}
+		return nil
+	})
+
+	for rel := range x.files {
+		if !seen[rel] {
+			delete(x.files, rel)
+		}
+	}
+	x.refreshed = time.Now()
+	return err
+}
+
+// Search returns symbols whose name matches query, best matches first:
+// exact, then prefix, then substring, then subsequence (fuzzy), all case
+// insensitive
+func (x *Index) Search(query string, limit int) []Symbol {
+	q := strings.ToLower(query)
+
+	type scored struct {
+		sym   Symbol
+		score int
+	}
+	var matches []scored
+	x.mu.Lock()
+	for _, f := range x.files {
+		for _, s := range f.symbols {
+			if score := matchScore(strings.ToLower(s.Name), q); score > 0 {
+				matches = append(matches, scored{s, score})
+			}
+		}
+	}
+	x.mu.Unlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if len(a.sym.Name) != len(b.sym.Name) {
+			return len(a.sym.Name) < len(b.sym.Name)
+		}
+		if a.sym.Path != b.sym.Path {
+			return a.sym.Path < b.sym.Path
+		}
+		return a.sym.Line < b.sym.Line
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	out := make([]Symbol, len(matches))
+	for i, m := range matches {
+		out[i] = m.sym
+	}
+	return out
+}
+
+// Count returns the number of indexed files and symbols
+func (x *Index) Count() (files, symbols int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, f := range x.files {
+		symbols += len(f.symbols)
+	}
+	return len(x.files), symbols
+}
+
+func matchScore(name, query string) int {
+	switch {
+	case query == "":
+		return 1
+	case name == query:
+		return 4
+	case strings.HasPrefix(name, query):
+		return 3
+	case strings.Contains(name, query):
+		return 2
+	case isSubsequence(query, name):
+		return 1
+	}
+	return 0
+}
+
+func isSubsequence(needle, haystack string) bool {
+	i := 0
+	for j := 0; j < len(haystack) && i < len(needle); j++ {
+		if haystack[j] == needle[i] {
+			i++
+		}
+	}
+	return i == len(needle)
+}
@@ -0,0 +1,115 @@
+package symbols
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Python and JavaScript/TypeScript are outlined with line patterns: good
+// enough for navigation without bundling a parser for each language
+
+var pythonDef = regexp.MustCompile(`^(\s*)(?:async\s+)?(def|class)\s+([A-Za-z_]\w*)`)
+
+// outlinePython nests methods under classes by indentation
+func outlinePython(src []byte) ([]Symbol, error) {
+	lines := strings.Split(string(src), "\n")
+
+	type open struct {
+		indent int
+		sym    *Symbol
+	}
+	var roots []*Symbol
+	var stack []open
+
+	for i, text := range lines {
+		m := pythonDef.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		indent := len(strings.ReplaceAll(m[1], "\t", "    "))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		s := &Symbol{Name: m[3], Kind: KindFunction, Line: i, EndLine: i}
+		if m[2] == "class" {
+			s.Kind = KindClass
+		} else if len(stack) > 0 && stack[len(stack)-1].sym.Kind == KindClass {
+			s.Kind = KindMethod
+		}
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1].sym
+			parent.Children = append(parent.Children, *s)
+			s = &parent.Children[len(parent.Children)-1]
+		} else {
+			roots = append(roots, s)
+		}
+		stack = append(stack, open{indent: indent, sym: s})
+	}
+
+	symbols := make([]Symbol, 0, len(roots))
+	for _, s := range roots {
+		symbols = append(symbols, *s)
+	}
+	return symbols, nil
+}
+
+var scriptPatterns = []struct {
+	re   *regexp.Regexp
+	kind string
+}{
+	{regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`), KindFunction},
+	{regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`), KindClass},
+	{regexp.MustCompile(`^\s*(?:export\s+)?interface\s+([A-Za-z_$][\w$]*)`), KindInterface},
+	{regexp.MustCompile(`^\s*(?:export\s+)?type\s+([A-Za-z_$][\w$]*)\s*(?:<[^=]*>)?\s*=`), KindType},
+	{regexp.MustCompile(`^\s*(?:export\s+)?(?:const\s+)?enum\s+([A-Za-z_$][\w$]*)`), KindEnum},
+	{regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s*)?(?:\([^)]*\)|[A-Za-z_$][\w$]*)\s*(?::[^=]+)?=>`), KindFunction},
+}
+
+// scriptMethod matches class members like "async save(", "static get x(";
+// only applied inside a class body
+var scriptMethod = regexp.MustCompile(`^\s+(?:(?:public|private|protected|static|async|readonly|get|set|override)\s+)*([A-Za-z_$][\w$]*)\s*(?:<[^>]*>)?\([^)]*\)?\s*[:{]?`)
+
+var scriptKeywords = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true, "function": true}
+
+// outlineScript finds top-level declarations and class methods, using
+// brace depth to know when a class body ends
+func outlineScript(src []byte) ([]Symbol, error) {
+	lines := strings.Split(string(src), "\n")
+	var symbols []Symbol
+	depth := 0
+	classIndex, classDepth := -1, 0
+
+	for i, text := range lines {
+		trimmed := strings.TrimSpace(text)
+		matched := false
+		if depth == 0 || (classIndex >= 0 && depth == classDepth) {
+			for _, p := range scriptPatterns {
+				if m := p.re.FindStringSubmatch(text); m != nil && depth == 0 {
+					symbols = append(symbols, Symbol{Name: m[1], Kind: p.kind, Line: i, EndLine: i})
+					if p.kind == KindClass {
+						classIndex, classDepth = len(symbols)-1, 1
+					}
+					matched = true
+					break
+				}
+			}
+			if !matched && classIndex >= 0 && depth == classDepth && !strings.HasPrefix(trimmed, "//") {
+				if m := scriptMethod.FindStringSubmatch(text); m != nil && !scriptKeywords[m[1]] {
+					cls := &symbols[classIndex]
+					cls.Children = append(cls.Children, Symbol{Name: m[1], Kind: KindMethod, Line: i, EndLine: i})
+				}
+			}
+		}
+
+		depth += strings.Count(text, "{") - strings.Count(text, "}")
+		if depth < 0 {
+			depth = 0
+		}
+		if classIndex >= 0 && depth < classDepth && i > symbols[classIndex].Line {
+			symbols[classIndex].EndLine = i
+			classIndex = -1
+		}
+	}
+	return symbols, nil
+}
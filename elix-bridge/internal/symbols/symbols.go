@@ -0,0 +1,87 @@
+// Package symbols provides file outlines and a workspace symbol index for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package symbols
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Symbol kinds
+const (
+	KindFunction  = "function"
+	KindMethod    = "method"
+	KindClass     = "class"
+	KindStruct    = "struct"
+	KindInterface = "interface"
+	KindType      = "type"
+	KindEnum      = "enum"
+	KindConst     = "const"
+	KindVar       = "var"
+	KindField     = "field"
+)
+
+// Symbol is a declaration in a file. Lines are zero-based, matching LSP.
+type Symbol struct {
+	Name      string   `json:"name"`
+	Kind      string   `json:"kind"`
+	Detail    string   `json:"detail,omitempty"`
+	Path      string   `json:"path,omitempty"`
+	Line      int      `json:"line"`
+	EndLine   int      `json:"end_line"`
+	Container string   `json:"container,omitempty"`
+	Children  []Symbol `json:"children,omitempty"`
+}
+
+// outliner parses a file's source into top-level symbols
+type outliner func(src []byte) ([]Symbol, error)
+
+var outliners = map[string]outliner{
+	".go":  outlineGo,
+	".py":  outlinePython,
+	".pyi": outlinePython,
+	".ts":  outlineScript,
+	".tsx": outlineScript,
+	".js":  outlineScript,
+	".jsx": outlineScript,
+	".mjs": outlineScript,
+	".cjs": outlineScript,
+}
+
+// Supported reports whether the bridge can outline path without a
+// language server
+func Supported(path string) bool {
+	_, ok := outliners[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Outline returns the nested symbols of a file
+func Outline(path string, src []byte) ([]Symbol, error) {
+	fn, ok := outliners[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return fn(src)
+}
+
+// Flatten returns symbols and their children as one list, recording each
+// child's container
+func Flatten(symbols []Symbol) []Symbol {
+	var out []Symbol
+	var walk func(list []Symbol, container string)
+	walk = func(list []Symbol, container string) {
+		for _, s := range list {
+			children := s.Children
+			s.Children = nil
+			if s.Container == "" {
+				s.Container = container
+			}
+			out = append(out, s)
+			walk(children, s.Name)
+		}
+	}
+	walk(symbols, "")
+	return out
+}
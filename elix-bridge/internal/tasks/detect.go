@@ -0,0 +1,172 @@
+// Package tasks detects runnable project tasks for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package tasks
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Task is a runnable project script
+type Task struct {
+	ID      string `json:"id"` // "<source>:<name>", stable across calls
+	Name    string `json:"name"`
+	Source  string `json:"source"` // "npm", "make", "go", "cargo"
+	Command string `json:"command"`
+}
+
+// makeTarget matches "target:" rules, excluding variable assignments (":=")
+var makeTarget = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_.\-/]*)\s*:([^=]|$)`)
+
+// Detect returns the tasks available in dir
+func Detect(dir string) []Task {
+	var tasks []Task
+	tasks = append(tasks, detectNPM(dir)...)
+	tasks = append(tasks, detectMake(dir)...)
+	tasks = append(tasks, detectGo(dir)...)
+	tasks = append(tasks, detectCargo(dir)...)
+	return tasks
+}
+
+// Find returns the task with the given ID in dir
+func Find(dir, id string) (Task, bool) {
+	for _, t := range Detect(dir) {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return Task{}, false
+}
+
+func detectNPM(dir string) []Task {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil
+	}
+
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil
+	}
+
+	// 根据锁文件选择包管理器
+	runner := "npm run"
+	switch {
+	case fileExists(filepath.Join(dir, "pnpm-lock.yaml")):
+		runner = "pnpm run"
+	case fileExists(filepath.Join(dir, "yarn.lock")):
+		runner = "yarn run"
+	case fileExists(filepath.Join(dir, "bun.lockb")):
+		runner = "bun run"
+	}
+
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tasks := make([]Task, 0, len(names))
+	for _, name := range names {
+		arg, ok := shellArg(name)
+		if !ok {
+			continue
+		}
+		tasks = append(tasks, Task{
+			ID:      "npm:" + name,
+			Name:    name,
+			Source:  "npm",
+			Command: runner + " " + arg,
+		})
+	}
+	return tasks
+}
+
+func detectMake(dir string) []Task {
+	f, err := os.Open(filepath.Join(dir, "Makefile"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var tasks []Task
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := makeTarget.FindStringSubmatch(scanner.Text())
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		arg, ok := shellArg(m[1])
+		if !ok {
+			continue
+		}
+		tasks = append(tasks, Task{
+			ID:      "make:" + m[1],
+			Name:    m[1],
+			Source:  "make",
+			Command: "make " + arg,
+		})
+	}
+	return tasks
+}
+
+func detectGo(dir string) []Task {
+	if !fileExists(filepath.Join(dir, "go.mod")) {
+		return nil
+	}
+	return []Task{
+		{ID: "go:test", Name: "test", Source: "go", Command: "go test ./..."},
+		{ID: "go:build", Name: "build", Source: "go", Command: "go build ./..."},
+		{ID: "go:vet", Name: "vet", Source: "go", Command: "go vet ./..."},
+	}
+}
+
+func detectCargo(dir string) []Task {
+	if !fileExists(filepath.Join(dir, "Cargo.toml")) {
+		return nil
+	}
+	return []Task{
+		{ID: "cargo:test", Name: "test", Source: "cargo", Command: "cargo test"},
+		{ID: "cargo:build", Name: "build", Source: "cargo", Command: "cargo build"},
+		{ID: "cargo:check", Name: "check", Source: "cargo", Command: "cargo check"},
+	}
+}
+
+// plainArg matches names that need no quoting in sh or cmd.exe
+var plainArg = regexp.MustCompile(`^[A-Za-z0-9_.:/@+=,-]+$`)
+
+// shellArg quotes a script or target name taken from the project for the
+// shell command line the task runs as, since a cloned repository can
+// name a script "x;curl ...|sh". Names starting with "-" would be read
+// as options of npm or make and are refused, as are names needing quotes
+// on Windows, where cmd.exe has no quoting that neutralizes every
+// metacharacter.
+func shellArg(name string) (string, bool) {
+	if strings.HasPrefix(name, "-") {
+		return "", false
+	}
+	if plainArg.MatchString(name) {
+		return name, true
+	}
+	if runtime.GOOS == "windows" || name == "" {
+		return "", false
+	}
+	return "'" + strings.ReplaceAll(name, "'", `'\''`) + "'", true
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
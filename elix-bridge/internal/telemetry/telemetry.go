@@ -0,0 +1,342 @@
+// Package telemetry provides opt-in anonymous usage statistics for EchoHelix Bridge.
+//
+// Only counts are kept: API routes used (as templates, never paths or
+// parameters), kernel types started and error classes. They are
+// aggregated locally in telemetry.json and, when the user opts in, sent
+// as one report a day. Preview returns exactly the report that would be
+// sent next.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/statefile"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Modes
+const (
+	// ModeOff records nothing (the default)
+	ModeOff = "off"
+	// ModeLocal aggregates locally for preview but never sends
+	ModeLocal = "local"
+	// ModeOn aggregates and sends reports to the endpoint
+	ModeOn = "on"
+)
+
+// SchemaVersion identifies the report format
+const SchemaVersion = 1
+
+// sendInterval is how often a report is sent
+const sendInterval = 24 * time.Hour
+
+// Report is what is sent: counts since PeriodStart plus coarse platform
+// facts. InstallID is random, generated locally and not derived from the
+// machine or user.
+type Report struct {
+	Schema        int              `json:"schema"`
+	InstallID     string           `json:"install_id"`
+	BridgeVersion string           `json:"bridge_version"`
+	OS            string           `json:"os"`
+	Arch          string           `json:"arch"`
+	PeriodStart   time.Time        `json:"period_start"`
+	PeriodEnd     time.Time        `json:"period_end"`
+	Features      map[string]int64 `json:"features"`
+	Kernels       map[string]int64 `json:"kernels"`
+	Errors        map[string]int64 `json:"errors"`
+}
+
+// Config configures a Recorder
+type Config struct {
+	// Mode is one of ModeOff, ModeLocal, ModeOn
+	Mode string
+	// Endpoint receives reports as JSON POSTs in ModeOn; empty sends nothing
+	Endpoint string
+	// StoragePath keeps the aggregates across restarts
+	StoragePath   string
+	BridgeVersion string
+}
+
+// state is the locally buffered aggregate
+type state struct {
+	InstallID   string           `json:"install_id"`
+	PeriodStart time.Time        `json:"period_start"`
+	LastSentAt  *time.Time       `json:"last_sent_at,omitempty"`
+	Features    map[string]int64 `json:"features"`
+	Kernels     map[string]int64 `json:"kernels"`
+	Errors      map[string]int64 `json:"errors"`
+}
+
+// Recorder aggregates usage counts
+type Recorder struct {
+	mu     sync.Mutex
+	config Config
+	state  state
+	dirty  bool
+	client *http.Client
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// New creates a recorder, loading any buffered counts, and starts the
+// flush and send loop
+func New(config Config) *Recorder {
+	r := &Recorder{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+		stop:   make(chan struct{}),
+	}
+	if err := statefile.Read(config.StoragePath, &r.state, nil); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msg("Failed to load telemetry buffer, starting fresh")
+	}
+	if r.state.InstallID == "" {
+		r.state.InstallID = newInstallID()
+		r.dirty = true
+	}
+	r.resetLocked(r.state.PeriodStart)
+	go r.loop()
+	return r
+}
+
+// Mode returns the current mode
+func (r *Recorder) Mode() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.config.Mode
+}
+
+// SetMode switches the mode; turning telemetry off discards the buffer
+func (r *Recorder) SetMode(mode string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config.Mode = mode
+	if mode == ModeOff {
+		r.resetLocked(time.Time{})
+	}
+}
+
+// Feature counts one use of a feature, such as an API route template
+func (r *Recorder) Feature(name string) {
+	r.add(func(s *state) { s.Features[name]++ })
+}
+
+// Kernel counts one start of a kernel type
+func (r *Recorder) Kernel(kind string) {
+	r.add(func(s *state) { s.Kernels[kind]++ })
+}
+
+// Error counts one error of a class, such as http_500 or panic
+func (r *Recorder) Error(class string) {
+	r.add(func(s *state) { s.Errors[class]++ })
+}
+
+func (r *Recorder) add(fn func(*state)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.config.Mode == ModeOff {
+		return
+	}
+	fn(&r.state)
+	r.dirty = true
+}
+
+// Preview returns the report that would be sent now
+func (r *Recorder) Preview() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reportLocked()
+}
+
+// Info describes the recorder's settings and schedule
+type Info struct {
+	Mode       string     `json:"mode"`
+	Endpoint   string     `json:"endpoint,omitempty"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	NextSendAt *time.Time `json:"next_send_at,omitempty"`
+}
+
+// Info returns the recorder's settings and schedule
+func (r *Recorder) Info() Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info := Info{Mode: r.config.Mode, Endpoint: r.config.Endpoint, LastSentAt: r.state.LastSentAt}
+	if r.config.Mode == ModeOn && r.config.Endpoint != "" {
+		next := r.state.PeriodStart.Add(sendInterval)
+		info.NextSendAt = &next
+	}
+	return info
+}
+
+// Discard drops the buffered counts
+func (r *Recorder) Discard() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resetLocked(time.Time{})
+}
+
+// Close flushes the buffer and stops the loop
+func (r *Recorder) Close() {
+	r.once.Do(func() {
+		close(r.stop)
+		r.flush()
+	})
+}
+
+func (r *Recorder) reportLocked() Report {
+	return Report{
+		Schema:        SchemaVersion,
+		InstallID:     r.state.InstallID,
+		BridgeVersion: r.config.BridgeVersion,
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		PeriodStart:   r.state.PeriodStart,
+		PeriodEnd:     time.Now().UTC().Truncate(time.Second),
+		Features:      maps.Clone(r.state.Features),
+		Kernels:       maps.Clone(r.state.Kernels),
+		Errors:        maps.Clone(r.state.Errors),
+	}
+}
+
+// resetLocked starts a new period, keeping counts loaded from disk when
+// start is set
+func (r *Recorder) resetLocked(start time.Time) {
+	if start.IsZero() {
+		r.state.Features, r.state.Kernels, r.state.Errors = nil, nil, nil
+		start = time.Now().UTC().Truncate(time.Second)
+		r.dirty = true
+	}
+	r.state.PeriodStart = start
+	if r.state.Features == nil {
+		r.state.Features = map[string]int64{}
+	}
+	if r.state.Kernels == nil {
+		r.state.Kernels = map[string]int64{}
+	}
+	if r.state.Errors == nil {
+		r.state.Errors = map[string]int64{}
+	}
+}
+
+func (r *Recorder) loop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.flush()
+			r.maybeSend()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// flush writes the buffer to disk when it changed. With telemetry off
+// nothing is kept on disk.
+func (r *Recorder) flush() {
+	r.mu.Lock()
+	if !r.dirty || r.config.StoragePath == "" {
+		r.mu.Unlock()
+		return
+	}
+	if r.config.Mode == ModeOff {
+		r.dirty = false
+		r.mu.Unlock()
+		os.Remove(r.config.StoragePath)
+		os.Remove(r.config.StoragePath + statefile.BackupSuffix)
+		return
+	}
+	data, err := json.Marshal(r.state)
+	r.dirty = false
+	r.mu.Unlock()
+	if err == nil {
+		err = statefile.Write(r.config.StoragePath, json.RawMessage(data), 0600, nil)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to save telemetry buffer")
+	}
+}
+
+// maybeSend sends the report once the period is over
+func (r *Recorder) maybeSend() {
+	r.mu.Lock()
+	if r.config.Mode != ModeOn || r.config.Endpoint == "" || time.Since(r.state.PeriodStart) < sendInterval {
+		r.mu.Unlock()
+		return
+	}
+	report := r.reportLocked()
+	endpoint := r.config.Endpoint
+	r.mu.Unlock()
+
+	if err := r.send(endpoint, report); err != nil {
+		log.Debug().Err(err).Msg("Failed to send telemetry report, will retry")
+		return
+	}
+
+	r.mu.Lock()
+	now := time.Now().UTC()
+	r.state.LastSentAt = &now
+	// 发送期间新增的计数并入下一周期
+	subtract(r.state.Features, report.Features)
+	subtract(r.state.Kernels, report.Kernels)
+	subtract(r.state.Errors, report.Errors)
+	r.state.PeriodStart = report.PeriodEnd
+	r.dirty = true
+	r.mu.Unlock()
+}
+
+func (r *Recorder) send(endpoint string, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// subtract removes sent counts, dropping keys that reach zero
+func subtract(counts, sent map[string]int64) {
+	for k, n := range sent {
+		if counts[k] -= n; counts[k] <= 0 {
+			delete(counts, k)
+		}
+	}
+}
+
+func newInstallID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,340 @@
+// Package terminal provides PTY-backed interactive shells for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package terminal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/rs/zerolog/log"
+)
+
+// Session is a running PTY shell
+type Session struct {
+	ID        string
+	Shell     string
+	Dir       string
+	Cols      uint16
+	Rows      uint16
+	CreatedAt time.Time
+	Attached  int
+
+	mu         sync.Mutex
+	cmd        *exec.Cmd
+	pty        *os.File
+	scrollback []byte
+	subs       map[chan []byte]struct{}
+	detachedAt time.Time
+	done       chan struct{}
+}
+
+// Info is a lock-free copy of a session's public fields
+type Info struct {
+	ID        string    `json:"id"`
+	Shell     string    `json:"shell"`
+	Dir       string    `json:"cwd"`
+	Cols      uint16    `json:"cols"`
+	Rows      uint16    `json:"rows"`
+	CreatedAt time.Time `json:"created_at"`
+	Attached  int       `json:"attached"`
+}
+
+// Manager owns all terminal sessions
+type Manager struct {
+	mu            sync.RWMutex
+	sessions      map[string]*Session
+	scrollbackMax int
+	idleTimeout   time.Duration
+}
+
+// ManagerConfig configures the terminal manager
+type ManagerConfig struct {
+	ScrollbackBytes int           // 回滚缓冲区大小，默认 256KB
+	IdleTimeout     time.Duration // 无客户端连接后保留时间，默认 10 分钟
+}
+
+// NewManager creates a terminal manager
+func NewManager(config ManagerConfig) *Manager {
+	if config.ScrollbackBytes <= 0 {
+		config.ScrollbackBytes = 256 << 10
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 10 * time.Minute
+	}
+
+	return &Manager{
+		sessions:      make(map[string]*Session),
+		scrollbackMax: config.ScrollbackBytes,
+		idleTimeout:   config.IdleTimeout,
+	}
+}
+
+// Create spawns a new shell in dir with the given size
+func (m *Manager) Create(dir string, cols, rows uint16) (*Session, error) {
+	if cols == 0 {
+		cols = 80
+	}
+	if rows == 0 {
+		rows = 24
+	}
+
+	shell := defaultShell()
+	cmd := exec.Command(shell)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+
+	f, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: cols, Rows: rows})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		ID:         generateID(),
+		Shell:      shell,
+		Dir:        dir,
+		Cols:       cols,
+		Rows:       rows,
+		CreatedAt:  time.Now(),
+		cmd:        cmd,
+		pty:        f,
+		subs:       make(map[chan []byte]struct{}),
+		detachedAt: time.Now(),
+		done:       make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.sessions[s.ID] = s
+	m.mu.Unlock()
+
+	go m.pump(s)
+
+	log.Info().Str("id", s.ID).Str("shell", shell).Str("cwd", dir).Msg("Terminal started")
+	return s, nil
+}
+
+// Get returns a session by ID
+func (m *Manager) Get(id string) (*Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+// List returns all sessions, oldest first
+func (m *Manager) List() []Info {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]Info, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		list = append(list, s.Info())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// PIDs returns the process IDs of the running shells
+func (m *Manager) PIDs() []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pids := make([]int, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		if s.cmd.Process != nil {
+			pids = append(pids, s.cmd.Process.Pid)
+		}
+	}
+	return pids
+}
+
+// Close kills a session's shell
+func (m *Manager) Close(id string) bool {
+	s, ok := m.Get(id)
+	if !ok {
+		return false
+	}
+	s.kill()
+	return true
+}
+
+// CloseAll kills every session
+func (m *Manager) CloseAll() {
+	m.mu.RLock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.RUnlock()
+
+	for _, s := range sessions {
+		s.kill()
+	}
+}
+
+// Info returns a copy of the session's public fields
+func (s *Session) Info() Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Info{
+		ID:        s.ID,
+		Shell:     s.Shell,
+		Dir:       s.Dir,
+		Cols:      s.Cols,
+		Rows:      s.Rows,
+		CreatedAt: s.CreatedAt,
+		Attached:  s.Attached,
+	}
+}
+
+// Attach returns the scrollback and a channel of new output.
+// The channel is closed when the shell exits or detach is called.
+func (s *Session) Attach() (scrollback []byte, out <-chan []byte, detach func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scrollback = make([]byte, len(s.scrollback))
+	copy(scrollback, s.scrollback)
+
+	ch := make(chan []byte, 256)
+	select {
+	case <-s.done:
+		close(ch)
+		return scrollback, ch, func() {}
+	default:
+	}
+
+	s.subs[ch] = struct{}{}
+	s.Attached++
+	return scrollback, ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[ch]; ok {
+			delete(s.subs, ch)
+			close(ch)
+			s.Attached--
+			if s.Attached == 0 {
+				s.detachedAt = time.Now()
+			}
+		}
+	}
+}
+
+// Write sends input to the shell
+func (s *Session) Write(p []byte) (int, error) {
+	return s.pty.Write(p)
+}
+
+// Resize changes the terminal window size
+func (s *Session) Resize(cols, rows uint16) error {
+	s.mu.Lock()
+	s.Cols, s.Rows = cols, rows
+	s.mu.Unlock()
+	return pty.Setsize(s.pty, &pty.Winsize{Cols: cols, Rows: rows})
+}
+
+// Done is closed when the shell exits
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Session) kill() {
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+}
+
+// pump copies PTY output to the scrollback and all attached clients
+func (m *Manager) pump(s *Session) {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := s.pty.Read(buf)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buf[:n])
+
+			s.mu.Lock()
+			s.scrollback = append(s.scrollback, data...)
+			if over := len(s.scrollback) - m.scrollbackMax; over > 0 {
+				s.scrollback = s.scrollback[over:]
+			}
+			for ch := range s.subs {
+				select {
+				case ch <- data:
+				default:
+					// 客户端过慢，丢弃本段输出
+				}
+			}
+			s.mu.Unlock()
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	s.cmd.Wait()
+	s.pty.Close()
+
+	s.mu.Lock()
+	for ch := range s.subs {
+		close(ch)
+		delete(s.subs, ch)
+	}
+	s.Attached = 0
+	close(s.done)
+	s.mu.Unlock()
+
+	m.mu.Lock()
+	delete(m.sessions, s.ID)
+	m.mu.Unlock()
+
+	log.Info().Str("id", s.ID).Msg("Terminal exited")
+}
+
+// ReapIdle closes terminals nobody has been attached to for the idle
+// timeout; run it every minute or so
+func (m *Manager) ReapIdle() {
+	m.mu.RLock()
+	var idle []*Session
+	for _, s := range m.sessions {
+		s.mu.Lock()
+		if s.Attached == 0 && time.Since(s.detachedAt) > m.idleTimeout {
+			idle = append(idle, s)
+		}
+		s.mu.Unlock()
+	}
+	m.mu.RUnlock()
+
+	for _, s := range idle {
+		log.Info().Str("id", s.ID).Msg("Closing idle terminal")
+		s.kill()
+	}
+}
+
+// Helper functions
+
+func defaultShell() string {
+	if runtime.GOOS == "windows" {
+		return "powershell.exe"
+	}
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return sh
+	}
+	return "/bin/sh"
+}
+
+func generateID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,201 @@
+// Package transcribe provides speech-to-text for EchoHelix Bridge.
+//
+// Voice notes are transcribed either by an OpenAI-compatible
+// transcription API (Whisper) or by a local whisper.cpp binary. Audio
+// in a format whisper.cpp cannot read is converted with ffmpeg first,
+// when it is installed.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Providers that can be passed to New
+const (
+	ProviderOpenAI     = "openai"
+	ProviderWhisperCpp = "whisper.cpp"
+)
+
+// openaiBaseURL is the default API endpoint; OPENAI_BASE_URL overrides it
+var openaiBaseURL = "https://api.openai.com/v1"
+
+// ErrDisabled is returned when no provider is configured
+var ErrDisabled = errors.New("transcription is not configured; set TRANSCRIBE_PROVIDER to openai or whisper.cpp")
+
+// Transcriber turns an audio file into text
+type Transcriber interface {
+	// Name identifies the provider and model
+	Name() string
+	// Transcribe returns the text spoken in the audio file at path.
+	// language is an ISO-639-1 code, or empty to detect it.
+	Transcribe(ctx context.Context, path, language string) (string, error)
+}
+
+// New creates the transcriber for provider. model may be empty for the
+// provider's default; lookup reads API keys, URLs and commands from the
+// config.
+func New(provider, model string, lookup func(string) string) (Transcriber, error) {
+	switch provider {
+	case "":
+		return nil, ErrDisabled
+	case ProviderOpenAI:
+		key := lookup("OPENAI_API_KEY")
+		base := lookup("OPENAI_BASE_URL")
+		if base == "" {
+			base = openaiBaseURL
+			if key == "" {
+				return nil, fmt.Errorf("OPENAI_API_KEY is not set")
+			}
+		}
+		if model == "" {
+			model = "whisper-1"
+		}
+		return &openaiTranscriber{
+			client: &http.Client{Timeout: 5 * time.Minute},
+			base:   strings.TrimRight(base, "/"),
+			key:    key,
+			model:  model,
+		}, nil
+	case ProviderWhisperCpp:
+		if model == "" {
+			return nil, fmt.Errorf("TRANSCRIBE_MODEL must be the path of a whisper.cpp model file")
+		}
+		command := strings.Fields(lookup("TRANSCRIBE_COMMAND"))
+		if len(command) == 0 {
+			command = []string{"whisper-cli"}
+		}
+		return &whisperCpp{command: command, model: model}, nil
+	}
+	return nil, fmt.Errorf("unknown transcription provider %q", provider)
+}
+
+type openaiTranscriber struct {
+	client *http.Client
+	base   string
+	key    string
+	model  string
+}
+
+func (t *openaiTranscriber) Name() string { return ProviderOpenAI + "/" + t.model }
+
+func (t *openaiTranscriber) Transcribe(ctx context.Context, path, language string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("model", t.model)
+	mw.WriteField("response_format", "json")
+	if language != "" {
+		mw.WriteField("language", language)
+	}
+	part, err := mw.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.base+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if t.key != "" {
+		req.Header.Set("Authorization", "Bearer "+t.key)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("transcription request failed: %d %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.Text), nil
+}
+
+// whisperCpp runs whisper.cpp's CLI, which prints the transcript to
+// stdout with -nt (no timestamps)
+type whisperCpp struct {
+	command []string
+	model   string
+}
+
+func (t *whisperCpp) Name() string { return ProviderWhisperCpp + "/" + filepath.Base(t.model) }
+
+// whisperFormats are the inputs whisper.cpp reads without conversion
+var whisperFormats = map[string]bool{".wav": true, ".mp3": true, ".flac": true, ".ogg": true}
+
+func (t *whisperCpp) Transcribe(ctx context.Context, path, language string) (string, error) {
+	if !whisperFormats[strings.ToLower(filepath.Ext(path))] {
+		wav, err := toWAV(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(wav)
+		path = wav
+	}
+
+	args := append(append([]string{}, t.command[1:]...), "-m", t.model, "-f", path, "-nt", "-np")
+	if language != "" {
+		args = append(args, "-l", language)
+	}
+	cmd := exec.CommandContext(ctx, t.command[0], args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 512 {
+			msg = msg[len(msg)-512:]
+		}
+		return "", fmt.Errorf("%s: %w: %s", t.command[0], err, msg)
+	}
+	// 每段一行，合并成一段文字
+	return strings.Join(strings.Fields(string(out)), " "), nil
+}
+
+// toWAV converts audio to 16 kHz mono WAV with ffmpeg
+func toWAV(ctx context.Context, path string) (string, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return "", fmt.Errorf("%s audio needs ffmpeg to convert it for whisper.cpp: %w", filepath.Ext(path), err)
+	}
+	wav := strings.TrimSuffix(path, filepath.Ext(path)) + ".wav"
+	cmd := exec.CommandContext(ctx, ffmpeg, "-nostdin", "-loglevel", "error", "-y", "-i", path, "-ar", "16000", "-ac", "1", wav)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(wav)
+		return "", fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return wav, nil
+}
@@ -0,0 +1,256 @@
+// Package tts provides text-to-speech for EchoHelix Bridge.
+//
+// Replies are spoken either by an OpenAI-compatible speech API or by a
+// local command: espeak-ng on Linux, say on macOS, or any program set in
+// TTS_COMMAND. Long replies go to the API in chunks, each streamed as
+// soon as it is synthesized, so playback starts before the whole reply
+// is spoken.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Engines that can be passed to New
+const (
+	EngineOpenAI = "openai"
+	EngineSystem = "system"
+)
+
+// openaiBaseURL is the default API endpoint; OPENAI_BASE_URL overrides it
+var openaiBaseURL = "https://api.openai.com/v1"
+
+// openaiMaxInput is the most characters the speech API takes at once
+const openaiMaxInput = 4096
+
+// ErrDisabled is returned when no engine is configured
+var ErrDisabled = errors.New("text-to-speech is not configured; set TTS_ENGINE to system or openai")
+
+// Synthesizer turns text into audio
+type Synthesizer interface {
+	// Name identifies the engine and model
+	Name() string
+	// ContentType is the MIME type of the audio written by Synthesize
+	ContentType() string
+	// Synthesize writes the spoken text to w as it is produced. voice
+	// may be empty for the engine's default.
+	Synthesize(ctx context.Context, text, voice string, w io.Writer) error
+}
+
+// New creates the synthesizer for engine. model may be empty for the
+// engine's default; lookup reads API keys, URLs and commands from the
+// config.
+func New(engine, model string, lookup func(string) string) (Synthesizer, error) {
+	switch engine {
+	case "":
+		return nil, ErrDisabled
+	case EngineOpenAI:
+		key := lookup("OPENAI_API_KEY")
+		base := lookup("OPENAI_BASE_URL")
+		if base == "" {
+			base = openaiBaseURL
+			if key == "" {
+				return nil, fmt.Errorf("OPENAI_API_KEY is not set")
+			}
+		}
+		if model == "" {
+			model = "tts-1"
+		}
+		return &openaiSynthesizer{
+			client: &http.Client{Timeout: 5 * time.Minute},
+			base:   strings.TrimRight(base, "/"),
+			key:    key,
+			model:  model,
+		}, nil
+	case EngineSystem:
+		command := strings.Fields(lookup("TTS_COMMAND"))
+		if len(command) == 0 {
+			command = systemCommand()
+		}
+		if len(command) == 0 {
+			return nil, fmt.Errorf("no system text-to-speech on %s; set TTS_COMMAND", runtime.GOOS)
+		}
+		return &systemSynthesizer{command: command}, nil
+	}
+	return nil, fmt.Errorf("unknown text-to-speech engine %q", engine)
+}
+
+// systemCommand is the platform's speech program, writing WAV to {out}
+// or to stdout
+func systemCommand() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"say", "-o", "{out}", "--file-format=WAVE", "--data-format=LEI16@22050"}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return []string{"espeak-ng", "--stdout"}
+	}
+	return nil
+}
+
+type openaiSynthesizer struct {
+	client *http.Client
+	base   string
+	key    string
+	model  string
+}
+
+func (t *openaiSynthesizer) Name() string { return EngineOpenAI + "/" + t.model }
+
+func (t *openaiSynthesizer) ContentType() string { return "audio/mpeg" }
+
+// Synthesize requests each chunk in turn; MP3 streams can simply be
+// concatenated
+func (t *openaiSynthesizer) Synthesize(ctx context.Context, text, voice string, w io.Writer) error {
+	if voice == "" {
+		voice = "alloy"
+	}
+	for _, chunk := range Chunks(text, openaiMaxInput) {
+		body, _ := json.Marshal(map[string]string{
+			"model":           t.model,
+			"input":           chunk,
+			"voice":           voice,
+			"response_format": "mp3",
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.base+"/audio/speech", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if t.key != "" {
+			req.Header.Set("Authorization", "Bearer "+t.key)
+		}
+		resp, err := t.client.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			resp.Body.Close()
+			return fmt.Errorf("speech request failed: %d %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+		}
+		_, err = io.Copy(w, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// systemSynthesizer runs a speech program with the text on stdin. The
+// audio is read from stdout, or from the file given for a {out}
+// argument for programs that cannot write to stdout.
+type systemSynthesizer struct {
+	command []string
+}
+
+func (t *systemSynthesizer) Name() string { return EngineSystem + "/" + t.command[0] }
+
+func (t *systemSynthesizer) ContentType() string { return "audio/wav" }
+
+func (t *systemSynthesizer) Synthesize(ctx context.Context, text, voice string, w io.Writer) error {
+	args := append([]string{}, t.command[1:]...)
+	out := ""
+	for i, arg := range args {
+		if arg == "{out}" {
+			f, err := os.CreateTemp("", "echohelix-tts-*.wav")
+			if err != nil {
+				return err
+			}
+			f.Close()
+			out = f.Name()
+			defer os.Remove(out)
+			args[i] = out
+		}
+	}
+	if voice != "" {
+		args = append(args, "-v", voice)
+	}
+
+	cmd := exec.CommandContext(ctx, t.command[0], args...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if out == "" {
+		cmd.Stdout = w
+	}
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 512 {
+			msg = msg[len(msg)-512:]
+		}
+		return fmt.Errorf("%s: %w: %s", t.command[0], err, msg)
+	}
+	if out != "" {
+		f, err := os.Open(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}
+	return nil
+}
+
+var (
+	codeBlock  = regexp.MustCompile("(?s)```.*?(```|$)")
+	inlineCode = regexp.MustCompile("`([^`]*)`")
+	mdLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdMarkup   = regexp.MustCompile(`(?m)^\s{0,3}(#{1,6}\s+|[-*+]\s+|>\s?)|\*\*|__|~~`)
+)
+
+// Speakable turns a markdown reply into text worth listening to: code
+// blocks are left out, and links and markup are reduced to their text
+func Speakable(markdown string) string {
+	text := codeBlock.ReplaceAllString(markdown, "\n(code omitted)\n")
+	text = inlineCode.ReplaceAllString(text, "$1")
+	text = mdLink.ReplaceAllString(text, "$1")
+	text = mdMarkup.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+// Chunks splits text into pieces of at most limit bytes, breaking after
+// paragraphs or sentences where possible
+func Chunks(text string, limit int) []string {
+	var chunks []string
+	for len(text) > limit {
+		cut := -1
+		for _, sep := range []string{"\n\n", ". ", "。", "\n", " "} {
+			if i := strings.LastIndex(text[:limit], sep); i > 0 {
+				cut = i + len(sep)
+				break
+			}
+		}
+		if cut < 0 {
+			// 没有可断开的位置，退回到 UTF-8 字符边界
+			cut = limit
+			for cut > 0 && text[cut]&0xC0 == 0x80 {
+				cut--
+			}
+		}
+		if chunk := strings.TrimSpace(text[:cut]); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		text = text[cut:]
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
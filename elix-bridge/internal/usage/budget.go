@@ -0,0 +1,88 @@
+package usage
+
+import (
+	"fmt"
+)
+
+// Scope is what a budget applies to
+type Scope string
+
+const (
+	ScopeSession Scope = "session"
+	ScopeDaily   Scope = "daily"
+	ScopeDevice  Scope = "device" // 每台设备每天
+)
+
+// Limit caps tokens and cost; zero fields are unlimited
+type Limit struct {
+	Tokens int     `json:"tokens,omitempty"`
+	Cost   float64 `json:"cost,omitempty"`
+}
+
+// exceeded reports whether used has reached the limit
+func (l Limit) exceeded(used Totals) bool {
+	return (l.Tokens > 0 && used.Tokens >= l.Tokens) || (l.Cost > 0 && used.Cost >= l.Cost)
+}
+
+// Budgets are the configured limits
+type Budgets struct {
+	Session Limit `json:"session"`
+	Daily   Limit `json:"daily"`
+	Device  Limit `json:"device"`
+}
+
+// BudgetError reports a prompt blocked by a budget
+type BudgetError struct {
+	Code  string `json:"code"`
+	Scope Scope  `json:"scope"`
+	Limit Limit  `json:"limit"`
+	Used  Totals `json:"used"`
+}
+
+func (e *BudgetError) Error() string {
+	what := map[Scope]string{
+		ScopeSession: "This session",
+		ScopeDaily:   "Today's usage",
+		ScopeDevice:  "This device's usage today",
+	}[e.Scope]
+	if e.Limit.Cost > 0 && e.Used.Cost >= e.Limit.Cost {
+		return fmt.Sprintf("%s reached the %s budget of $%.2f (spent $%.2f); raise BUDGET_%s_COST to continue",
+			what, e.Scope, e.Limit.Cost, e.Used.Cost, envScope(e.Scope))
+	}
+	return fmt.Sprintf("%s reached the %s budget of %d tokens (used %d); raise BUDGET_%s_TOKENS to continue",
+		what, e.Scope, e.Limit.Tokens, e.Used.Tokens, envScope(e.Scope))
+}
+
+func envScope(s Scope) string {
+	switch s {
+	case ScopeSession:
+		return "SESSION"
+	case ScopeDevice:
+		return "DEVICE"
+	}
+	return "DAILY"
+}
+
+// Check returns a *BudgetError when the session, today, or the device
+// today has used up its budget. Empty IDs skip their scope.
+func (l *Ledger) Check(b Budgets, sessionID, deviceID string) error {
+	if sessionID != "" {
+		if used := l.Session(sessionID); b.Session.exceeded(used) {
+			return &BudgetError{Code: "BUDGET_EXCEEDED", Scope: ScopeSession, Limit: b.Session, Used: used}
+		}
+	}
+	today := l.Today()
+	if b.Daily.exceeded(today.Total) {
+		return &BudgetError{Code: "BUDGET_EXCEEDED", Scope: ScopeDaily, Limit: b.Daily, Used: today.Total}
+	}
+	if deviceID != "" {
+		var used Totals
+		if t := today.Devices[deviceID]; t != nil {
+			used = *t
+		}
+		if b.Device.exceeded(used) {
+			return &BudgetError{Code: "BUDGET_EXCEEDED", Scope: ScopeDevice, Limit: b.Device, Used: used}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Package usage provides token and cost accounting for EchoHelix Bridge.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package usage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// dateLayout keys days in the bridge's local time zone
+const dateLayout = "2006-01-02"
+
+// Entry is the usage of one kernel turn
+type Entry struct {
+	SessionID    string
+	DeviceID     string
+	Kernel       string
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+}
+
+// Totals sums the usage of several turns
+type Totals struct {
+	Tokens       int     `json:"tokens"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+	Turns        int     `json:"turns"`
+}
+
+func (t *Totals) add(e Entry) {
+	t.InputTokens += e.InputTokens
+	t.OutputTokens += e.OutputTokens
+	t.Tokens += e.InputTokens + e.OutputTokens
+	t.Cost += e.Cost
+	t.Turns++
+}
+
+// Day is the usage of one calendar day, overall and per device and kernel
+type Day struct {
+	Date    string             `json:"date"`
+	Total   Totals             `json:"total"`
+	Devices map[string]*Totals `json:"devices"`
+	Kernels map[string]*Totals `json:"kernels"`
+}
+
+func (d *Day) copy() Day {
+	c := Day{Date: d.Date, Total: d.Total, Devices: make(map[string]*Totals), Kernels: make(map[string]*Totals)}
+	for k, t := range d.Devices {
+		v := *t
+		c.Devices[k] = &v
+	}
+	for k, t := range d.Kernels {
+		v := *t
+		c.Kernels[k] = &v
+	}
+	return c
+}
+
+// Ledger accumulates usage per day and per session. Days older than
+// keepDays are dropped; session totals live as long as the ledger.
+type Ledger struct {
+	mu          sync.Mutex
+	days        map[string]*Day
+	sessions    map[string]*Totals
+	storagePath string
+	keepDays    int
+
+	saveMu sync.Mutex
+}
+
+type ledgerFile struct {
+	Days     map[string]*Day    `json:"days"`
+	Sessions map[string]*Totals `json:"sessions"`
+}
+
+// NewLedger creates a ledger persisted at storagePath
+func NewLedger(storagePath string) *Ledger {
+	l := &Ledger{
+		days:        make(map[string]*Day),
+		sessions:    make(map[string]*Totals),
+		storagePath: storagePath,
+		keepDays:    90,
+	}
+	if err := l.load(); err != nil {
+		log.Warn().Err(err).Msg("Failed to load usage ledger")
+	}
+	return l
+}
+
+// Record adds the usage of a turn to today, its device and kernel, and
+// its session. Turns without a device, such as local requests, count
+// toward the day but no device.
+func (l *Ledger) Record(e Entry) {
+	date := time.Now().Format(dateLayout)
+
+	l.mu.Lock()
+	day := l.days[date]
+	if day == nil {
+		day = &Day{Date: date, Devices: make(map[string]*Totals), Kernels: make(map[string]*Totals)}
+		l.days[date] = day
+		l.pruneLocked()
+	}
+	day.Total.add(e)
+	if e.DeviceID != "" {
+		addTo(day.Devices, e.DeviceID, e)
+	}
+	if e.Kernel != "" {
+		addTo(day.Kernels, e.Kernel, e)
+	}
+	if e.SessionID != "" {
+		addTo(l.sessions, e.SessionID, e)
+	}
+	l.mu.Unlock()
+	l.save()
+}
+
+func addTo(m map[string]*Totals, key string, e Entry) {
+	t := m[key]
+	if t == nil {
+		t = &Totals{}
+		m[key] = t
+	}
+	t.add(e)
+}
+
+// Session returns the usage of a session
+func (l *Ledger) Session(id string) Totals {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t := l.sessions[id]; t != nil {
+		return *t
+	}
+	return Totals{}
+}
+
+// Today returns the usage of the current day
+func (l *Ledger) Today() Day {
+	return l.Day(time.Now().Format(dateLayout))
+}
+
+// Day returns the usage of a date formatted as 2006-01-02
+func (l *Ledger) Day(date string) Day {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if d := l.days[date]; d != nil {
+		return d.copy()
+	}
+	return Day{Date: date, Devices: map[string]*Totals{}, Kernels: map[string]*Totals{}}
+}
+
+// Days returns the last n days up to today, newest first, including
+// days without usage
+func (l *Ledger) Days(n int) []Day {
+	now := time.Now()
+	days := make([]Day, 0, n)
+	for i := 0; i < n; i++ {
+		days = append(days, l.Day(now.AddDate(0, 0, -i).Format(dateLayout)))
+	}
+	return days
+}
+
+// pruneLocked drops days beyond keepDays
+func (l *Ledger) pruneLocked() {
+	if len(l.days) <= l.keepDays {
+		return
+	}
+	dates := make([]string, 0, len(l.days))
+	for d := range l.days {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+	for _, d := range dates[:len(dates)-l.keepDays] {
+		delete(l.days, d)
+	}
+}
+
+func (l *Ledger) save() {
+	if l.storagePath == "" {
+		return
+	}
+	l.saveMu.Lock()
+	defer l.saveMu.Unlock()
+	l.mu.Lock()
+	data, err := json.MarshalIndent(ledgerFile{Days: l.days, Sessions: l.sessions}, "", "  ")
+	l.mu.Unlock()
+
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(l.storagePath), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(l.storagePath, data, 0600)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to save usage ledger")
+	}
+}
+
+func (l *Ledger) load() error {
+	data, err := os.ReadFile(l.storagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var f ledgerFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	if f.Days != nil {
+		l.days = f.Days
+	}
+	if f.Sessions != nil {
+		l.sessions = f.Sessions
+	}
+	return nil
+}
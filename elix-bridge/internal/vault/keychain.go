@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Keychain entry holding the data key, base64 encoded
+const (
+	keychainService = "echohelix-bridge"
+	keychainAccount = "data-key"
+)
+
+// errNoKeychain is returned on platforms without a supported keychain
+var errNoKeychain = errors.New("OS keychain is not supported on " + runtime.GOOS + "; use a passphrase")
+
+// keychainGet reads the data key from the macOS keychain or the Secret
+// Service (via secret-tool) on Linux
+func keychainGet() ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+	default:
+		return nil, errNoKeychain
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("data key not found in keychain: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+	if err != nil || len(key) != keySize {
+		return nil, errors.New("keychain entry is not a valid data key")
+	}
+	return key, nil
+}
+
+// keychainSet stores the data key in the keychain
+func keychainSet(key []byte) error {
+	secret := base64.StdEncoding.EncodeToString(key)
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// security 只能从参数读取密码
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", keychainAccount, "-w", secret)
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label=EchoHelix Bridge data key", "service", keychainService, "account", keychainAccount)
+		cmd.Stdin = strings.NewReader(secret)
+	default:
+		return errNoKeychain
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to store data key in keychain: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
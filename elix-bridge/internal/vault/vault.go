@@ -0,0 +1,237 @@
+// Package vault provides encryption at rest for EchoHelix Bridge.
+//
+// Session transcripts and auth state are sealed with AES-256-GCM under a
+// data key that is either derived from a passphrase (scrypt) or kept in
+// the OS keychain. The data directory's encryption.json records which,
+// along with the KDF salt and a sealed check value that detects a wrong
+// passphrase at startup. Sealed data starts with a magic prefix, so
+// plaintext written before encryption was enabled still reads.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// MetaFile in the data directory describes how the data key is obtained
+const MetaFile = "encryption.json"
+
+// Key sources
+const (
+	SourcePassphrase = "passphrase"
+	SourceKeychain   = "keychain"
+)
+
+// magic prefixes sealed data: version byte included
+var magic = []byte("EHXENC\x00\x01")
+
+// checkValue is sealed into MetaFile to verify the key
+var checkValue = []byte("echohelix vault check")
+
+// scrypt parameters for new vaults (about 100ms on a laptop)
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+	keySize = 32
+)
+
+var (
+	// ErrLocked is returned when reading sealed data without a key
+	ErrLocked = errors.New("data is encrypted; configure DATA_ENCRYPTION to unlock it")
+	// ErrWrongKey is returned when the passphrase or keychain key does not
+	// match the one the data was sealed with
+	ErrWrongKey = errors.New("wrong passphrase or data key")
+	// ErrCorrupt is returned when sealed data fails authentication
+	ErrCorrupt = errors.New("encrypted data is corrupt")
+)
+
+// Vault seals and opens data with the data key. A nil *Vault is valid
+// and leaves data in plaintext.
+type Vault struct {
+	aead cipher.AEAD
+}
+
+type meta struct {
+	Version int    `json:"version"`
+	Source  string `json:"source"`
+	Salt    []byte `json:"salt,omitempty"`
+	N       int    `json:"n,omitempty"`
+	R       int    `json:"r,omitempty"`
+	P       int    `json:"p,omitempty"`
+	Check   []byte `json:"check"`
+}
+
+// Enabled reports whether data is being encrypted
+func (v *Vault) Enabled() bool {
+	return v != nil
+}
+
+// Seal encrypts data; with a nil vault it is returned unchanged
+func (v *Vault) Seal(data []byte) ([]byte, error) {
+	if v == nil {
+		return data, nil
+	}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte{}, magic...), nonce...)
+	return v.aead.Seal(out, nonce, data, magic), nil
+}
+
+// Open decrypts sealed data. Plaintext is returned unchanged, so files
+// written before encryption was enabled keep loading.
+func (v *Vault) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	if v == nil {
+		return nil, ErrLocked
+	}
+	data = data[len(magic):]
+	ns := v.aead.NonceSize()
+	if len(data) < ns+v.aead.Overhead() {
+		return nil, ErrCorrupt
+	}
+	plain, err := v.aead.Open(nil, data[:ns], data[ns:], magic)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plain, nil
+}
+
+// IsSealed reports whether data was produced by Seal
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// IsConfigured reports whether the data directory has been encrypted
+func IsConfigured(dataDir string) bool {
+	_, err := os.Stat(filepath.Join(dataDir, MetaFile))
+	return err == nil
+}
+
+// Unlock opens the vault of dataDir with a key from source, setting it
+// up on first use. created is true when the vault was just set up, so
+// existing plaintext can be rewritten sealed.
+func Unlock(dataDir, source, passphrase string) (v *Vault, created bool, err error) {
+	path := filepath.Join(dataDir, MetaFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, err
+	}
+	if os.IsNotExist(err) {
+		v, err := create(path, source, passphrase)
+		return v, err == nil, err
+	}
+
+	var m meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, false, fmt.Errorf("invalid %s: %w", MetaFile, err)
+	}
+	if m.Source != source {
+		return nil, false, fmt.Errorf("data directory is encrypted with a %s key, not %s", m.Source, source)
+	}
+
+	var key []byte
+	switch source {
+	case SourcePassphrase:
+		if passphrase == "" {
+			return nil, false, errors.New("passphrase is required to unlock the data directory")
+		}
+		key, err = scrypt.Key([]byte(passphrase), m.Salt, m.N, m.R, m.P, keySize)
+	case SourceKeychain:
+		key, err = keychainGet()
+	default:
+		return nil, false, fmt.Errorf("unknown key source %q", source)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	v, err = newVault(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if check, err := v.Open(m.Check); err != nil || !bytes.Equal(check, checkValue) {
+		return nil, false, ErrWrongKey
+	}
+	return v, false, nil
+}
+
+// create generates the data key and writes MetaFile
+func create(path, source, passphrase string) (*Vault, error) {
+	m := meta{Version: 1, Source: source}
+	var key []byte
+	var err error
+	switch source {
+	case SourcePassphrase:
+		if passphrase == "" {
+			return nil, errors.New("passphrase is required to encrypt the data directory")
+		}
+		m.Salt = make([]byte, 16)
+		if _, err := rand.Read(m.Salt); err != nil {
+			return nil, err
+		}
+		m.N, m.R, m.P = scryptN, scryptR, scryptP
+		key, err = scrypt.Key([]byte(passphrase), m.Salt, m.N, m.R, m.P, keySize)
+	case SourceKeychain:
+		// 复用钥匙串中已有的密钥（例如重新创建数据目录后）
+		if key, err = keychainGet(); err != nil {
+			key = make([]byte, keySize)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			err = keychainSet(key)
+		}
+	default:
+		return nil, fmt.Errorf("unknown key source %q", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := newVault(key)
+	if err != nil {
+		return nil, err
+	}
+	if m.Check, err = v.Seal(checkValue); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func newVault(key []byte) (*Vault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{aead: aead}, nil
+}
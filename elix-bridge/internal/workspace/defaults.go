@@ -0,0 +1,33 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Defaults are the settings new sessions and prompts start with when a
+// request leaves them out. Empty fields inherit from the global config.
+type Defaults struct {
+	Provider    string `json:"provider,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Kernel      string `json:"kernel,omitempty"`
+	SessionName string `json:"session_name,omitempty"`
+}
+
+// LoadDefaults reads <dir>/.echohelix/defaults.json; ok is false when
+// there is none
+func LoadDefaults(dir string) (d Defaults, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(dir, ".echohelix", "defaults.json"))
+	if os.IsNotExist(err) {
+		return Defaults{}, false, nil
+	}
+	if err != nil {
+		return Defaults{}, false, err
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return Defaults{}, false, fmt.Errorf("invalid defaults.json: %w", err)
+	}
+	return d, true, nil
+}
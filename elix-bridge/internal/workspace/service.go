@@ -5,13 +5,17 @@
 package workspace
 
 import (
-	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"echohelix/bridge/internal/statefile"
+
 	"github.com/rs/zerolog/log"
 )
 
@@ -20,9 +24,46 @@ type Workspace struct {
 	ID         string    `json:"id"`
 	Name       string    `json:"name"`
 	Path       string    `json:"path"`
+	Type       Type      `json:"type"`
 	LastAccess time.Time `json:"last_access"`
+	Stats      Stats     `json:"stats"`
+}
+
+// Type distinguishes local workspaces from remote ones
+type Type string
+
+const (
+	TypeLocal Type = "local"
+	TypeSSH   Type = "ssh"
+)
+
+// TypeForPath infers the workspace type from its path
+func TypeForPath(path string) Type {
+	if strings.HasPrefix(path, "ssh://") {
+		return TypeSSH
+	}
+	return TypeLocal
+}
+
+// Stats holds usage counters for a workspace
+type Stats struct {
+	Sessions     int            `json:"sessions"`
+	Messages     int            `json:"messages"`
+	TokensUsed   int64          `json:"tokens_used"`
+	LastActivity time.Time      `json:"last_activity,omitempty"`
+	Daily        map[string]int `json:"daily,omitempty"` // "2006-01-02" -> message count
+}
+
+// DayActivity is the message count for a single day
+type DayActivity struct {
+	Date     string `json:"date"`
+	Messages int    `json:"messages"`
 }
 
+// DailyRetention bounds how many days of activity are kept per workspace,
+// and so how far back activity can be asked for
+const DailyRetention = 90
+
 // Service manages the list of saved workspaces
 type Service struct {
 	mu         sync.RWMutex
@@ -51,14 +92,32 @@ func NewService(configDir string) *Service {
 		s.workspaces = []Workspace{}
 	}
 
+	// 兼容旧数据：补全缺失的类型
+	for i := range s.workspaces {
+		if s.workspaces[i].Type == "" {
+			s.workspaces[i].Type = TypeForPath(s.workspaces[i].Path)
+		}
+	}
+
 	return s
 }
 
-// List returns the list of all workspaces
+// List returns copies of all workspaces
 func (s *Service) List() []Workspace {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.workspaces
+	list := make([]Workspace, len(s.workspaces))
+	for i, w := range s.workspaces {
+		list[i] = w.clone()
+	}
+	return list
+}
+
+// clone copies a workspace with its own daily counts, so callers can
+// encode it after the lock is released while RecordMessage goes on
+func (w Workspace) clone() Workspace {
+	w.Stats.Daily = maps.Clone(w.Stats.Daily)
+	return w
 }
 
 // Add adds a new workspace
@@ -69,7 +128,7 @@ func (s *Service) Add(name, path string) (Workspace, error) {
 	// Check if path already exists
 	for _, w := range s.workspaces {
 		if w.Path == path {
-			return w, nil
+			return w.clone(), nil
 		}
 	}
 
@@ -77,6 +136,7 @@ func (s *Service) Add(name, path string) (Workspace, error) {
 		ID:         fmt.Sprintf("ws_%d", time.Now().UnixNano()),
 		Name:       name,
 		Path:       path,
+		Type:       TypeForPath(path),
 		LastAccess: time.Now(),
 	}
 
@@ -114,18 +174,153 @@ func (s *Service) UpdateAccess(path string) {
 	}
 }
 
-func (s *Service) load() error {
-	data, err := os.ReadFile(s.filePath)
-	if err != nil {
-		return err
+// RecordSession counts a new session against the workspace owning dir
+func (s *Service) RecordSession(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexForPathLocked(dir)
+	if i < 0 {
+		return
 	}
-	return json.Unmarshal(data, &s.workspaces)
+	s.workspaces[i].Stats.Sessions++
+	s.workspaces[i].Stats.LastActivity = time.Now()
+	s.save()
 }
 
-func (s *Service) save() error {
-	data, err := json.MarshalIndent(s.workspaces, "", "  ")
-	if err != nil {
-		return err
+// RecordMessage counts a message and its tokens against the workspace owning dir
+func (s *Service) RecordMessage(dir string, tokens int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexForPathLocked(dir)
+	if i < 0 {
+		return
+	}
+
+	now := time.Now()
+	st := &s.workspaces[i].Stats
+	st.Messages++
+	st.TokensUsed += int64(tokens)
+	st.LastActivity = now
+	if st.Daily == nil {
+		st.Daily = make(map[string]int)
+	}
+	st.Daily[now.Format("2006-01-02")]++
+
+	// 清理超出保留期的每日记录
+	cutoff := now.AddDate(0, 0, -DailyRetention).Format("2006-01-02")
+	for day := range st.Daily {
+		if day < cutoff {
+			delete(st.Daily, day)
+		}
+	}
+	s.save()
+}
+
+// GetStats returns a workspace and its activity over the last n days
+func (s *Service) GetStats(id string, days int) (Workspace, []DayActivity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, w := range s.workspaces {
+		if w.ID == id {
+			return w.clone(), recentActivity(w.Stats, days), nil
+		}
+	}
+	return Workspace{}, nil, fmt.Errorf("workspace not found: %s", id)
+}
+
+// MostActive returns up to limit workspaces ordered by messages in the last n days
+func (s *Service) MostActive(limit, days int) []Workspace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type ranked struct {
+		ws    Workspace
+		count int
+	}
+	list := make([]ranked, 0, len(s.workspaces))
+	for _, w := range s.workspaces {
+		count := 0
+		for _, d := range recentActivity(w.Stats, days) {
+			count += d.Messages
+		}
+		list = append(list, ranked{ws: w, count: count})
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].count != list[j].count {
+			return list[i].count > list[j].count
+		}
+		return list[i].ws.Stats.LastActivity.After(list[j].ws.Stats.LastActivity)
+	})
+
+	if limit <= 0 || limit > len(list) {
+		limit = len(list)
+	}
+	result := make([]Workspace, limit)
+	for i := 0; i < limit; i++ {
+		result[i] = list[i].ws.clone()
+	}
+	return result
+}
+
+// Owner returns the workspace containing dir, the one with the longest
+// path when workspaces are nested
+func (s *Service) Owner(dir string) (Workspace, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexForPathLocked(dir)
+	if i < 0 {
+		return Workspace{}, false
+	}
+	return s.workspaces[i].clone(), true
+}
+
+// indexForPathLocked finds the workspace containing dir (longest match wins)
+func (s *Service) indexForPathLocked(dir string) int {
+	if dir == "" {
+		return -1
+	}
+	dir = filepath.Clean(dir)
+
+	best, bestLen := -1, 0
+	for i, w := range s.workspaces {
+		root := filepath.Clean(w.Path)
+		if dir != root && !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			continue
+		}
+		if len(root) > bestLen {
+			best, bestLen = i, len(root)
+		}
+	}
+	return best
+}
+
+// recentActivity returns the last days days of activity, at most
+// DailyRetention of them
+func recentActivity(st Stats, days int) []DayActivity {
+	if days <= 0 {
+		days = 7
+	}
+	if days > DailyRetention {
+		days = DailyRetention
 	}
-	return os.WriteFile(s.filePath, data, 0644)
+	now := time.Now()
+	result := make([]DayActivity, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		day := now.AddDate(0, 0, -i).Format("2006-01-02")
+		result = append(result, DayActivity{Date: day, Messages: st.Daily[day]})
+	}
+	return result
+}
+
+func (s *Service) load() error {
+	return statefile.Read(s.filePath, &s.workspaces, nil)
+}
+
+func (s *Service) save() error {
+	return statefile.Write(s.filePath, s.workspaces, 0644, nil)
 }
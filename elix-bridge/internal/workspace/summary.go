@@ -0,0 +1,155 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"echohelix/bridge/internal/fs"
+)
+
+// maxReadmeExcerpt bounds the README text a summary carries
+const maxReadmeExcerpt = 1500
+
+// maxScannedFiles bounds the walk that measures languages, so a huge
+// workspace answers quickly with a sample
+const maxScannedFiles = 20000
+
+// readmeNames are tried in order, case-insensitively
+var readmeNames = []string{"readme.md", "readme.markdown", "readme.rst", "readme.txt", "readme"}
+
+// languageExts maps file extensions to the language they count toward.
+// Markup, data and config files are not counted.
+var languageExts = map[string]string{
+	".go": "Go", ".py": "Python", ".pyi": "Python",
+	".js": "JavaScript", ".jsx": "JavaScript", ".mjs": "JavaScript", ".cjs": "JavaScript",
+	".ts": "TypeScript", ".tsx": "TypeScript", ".vue": "Vue", ".svelte": "Svelte",
+	".rs": "Rust", ".java": "Java", ".kt": "Kotlin", ".kts": "Kotlin", ".swift": "Swift",
+	".rb": "Ruby", ".php": "PHP", ".c": "C", ".h": "C", ".cc": "C++", ".cpp": "C++",
+	".cxx": "C++", ".hpp": "C++", ".cs": "C#", ".scala": "Scala", ".dart": "Dart",
+	".lua": "Lua", ".sh": "Shell", ".bash": "Shell", ".sql": "SQL", ".html": "HTML",
+	".css": "CSS", ".scss": "CSS", ".m": "Objective-C", ".ex": "Elixir", ".exs": "Elixir",
+	".hs": "Haskell", ".clj": "Clojure", ".r": "R", ".jl": "Julia", ".zig": "Zig",
+}
+
+// Readme is the start of a workspace's README
+type Readme struct {
+	Path      string `json:"path"`
+	Excerpt   string `json:"excerpt"`
+	Truncated bool   `json:"truncated"`
+}
+
+// ReadReadme returns the excerpt of the README at the root of dir, or nil
+// when there is none
+func ReadReadme(dir string) (*Readme, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names[strings.ToLower(e.Name())] = e.Name()
+		}
+	}
+	for _, candidate := range readmeNames {
+		name, ok := names[candidate]
+		if !ok {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		buf := make([]byte, maxReadmeExcerpt+1)
+		n, _ := f.Read(buf)
+		return excerpt(name, buf[:n]), nil
+	}
+	return nil, nil
+}
+
+// excerpt cuts text to maxReadmeExcerpt bytes at a line break when one is
+// near, without splitting a UTF-8 character
+func excerpt(name string, text []byte) *Readme {
+	r := &Readme{Path: name}
+	if len(text) <= maxReadmeExcerpt {
+		r.Excerpt = string(text)
+		return r
+	}
+	r.Truncated = true
+	text = text[:maxReadmeExcerpt]
+	if i := strings.LastIndexByte(string(text), '\n'); i > maxReadmeExcerpt/2 {
+		text = text[:i]
+	}
+	for len(text) > 0 && !utf8.Valid(text) {
+		text = text[:len(text)-1]
+	}
+	r.Excerpt = string(text)
+	return r
+}
+
+// Language is one language's share of a workspace's source
+type Language struct {
+	Name    string  `json:"name"`
+	Files   int     `json:"files"`
+	Bytes   int64   `json:"bytes"`
+	Percent float64 `json:"percent"` // of source bytes
+}
+
+// Languages measures the source files under dir by language, largest
+// first. Directories fs skips, such as node_modules, are not counted.
+// partial reports that the walk stopped at maxScannedFiles or ctx.
+func Languages(ctx context.Context, dir string) (langs []Language, partial bool, err error) {
+	counts := make(map[string]*Language)
+	var total int64
+	scanned := 0
+	errStop := errors.New("stop")
+
+	err = fs.WalkParallel(ctx, dir, fs.WalkOptions{StatFiles: true}, func(e fs.Entry) error {
+		if e.IsDir {
+			return nil
+		}
+		if scanned++; scanned > maxScannedFiles {
+			return errStop
+		}
+		name, ok := languageExts[strings.ToLower(filepath.Ext(e.Path))]
+		if !ok || e.Info == nil {
+			return nil
+		}
+		l := counts[name]
+		if l == nil {
+			l = &Language{Name: name}
+			counts[name] = l
+		}
+		l.Files++
+		l.Bytes += e.Info.Size()
+		total += e.Info.Size()
+		return nil
+	})
+	if errors.Is(err, errStop) || errors.Is(err, context.DeadlineExceeded) {
+		partial, err = true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	langs = make([]Language, 0, len(counts))
+	for _, l := range counts {
+		if total > 0 {
+			l.Percent = float64(l.Bytes) * 100 / float64(total)
+		}
+		langs = append(langs, *l)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if langs[i].Bytes != langs[j].Bytes {
+			return langs[i].Bytes > langs[j].Bytes
+		}
+		return langs[i].Name < langs[j].Name
+	})
+	return langs, partial, nil
+}
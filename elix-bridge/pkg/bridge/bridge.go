@@ -0,0 +1,208 @@
+// Package bridge provides the embedding API for EchoHelix Bridge.
+//
+// Desktop apps run the bridge in their own process instead of starting
+// the echohelix binary:
+//
+//	b, err := bridge.New(bridge.Options{Addr: ":8765", WorkDir: dir})
+//	if err != nil {
+//		return err
+//	}
+//	go b.Run(ctx) // returns once ctx is done and the bridge has shut down
+//
+//	code, _ := b.Auth().GeneratePairingCode()
+//
+// The accessors reach the same managers the HTTP API uses, so changes
+// made through them are seen by paired devices.
+//
+// Copyright 2026 EchoHelix Contributors
+// SPDX-License-Identifier: Apache-2.0
+package bridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"echohelix/bridge/internal/api"
+	"echohelix/bridge/internal/auth"
+	"echohelix/bridge/internal/config"
+	"echohelix/bridge/internal/events"
+	"echohelix/bridge/internal/lifecycle"
+	"echohelix/bridge/internal/process"
+	"echohelix/bridge/internal/session"
+	"echohelix/bridge/internal/terminal"
+	"echohelix/bridge/internal/workspace"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultAddr is the address the bridge listens on unless told otherwise
+const DefaultAddr = ":8765"
+
+// ErrAlreadyRun is returned by Run when the bridge has already run
+var ErrAlreadyRun = errors.New("bridge: already run")
+
+// Options configure an embedded bridge. Empty fields use the same
+// defaults as the echohelix binary.
+type Options struct {
+	// Addr is the TCP address to listen on (default :8765)
+	Addr string
+	// WorkDir contains .env and cores/ (default: current directory)
+	WorkDir string
+	// DataDir holds pairings, sessions and other state (default ~/.echohelix)
+	DataDir string
+	// InsecureCORS lets any origin call the API (development only)
+	InsecureCORS bool
+	// LogOutput receives the bridge's logs, which also go to the
+	// dashboard's buffer. When nil the process-wide zerolog logger is
+	// left as the embedder set it up.
+	LogOutput io.Writer
+	// ShutdownTimeout bounds the shutdown once Run's context is done
+	// (default 10s)
+	ShutdownTimeout time.Duration
+}
+
+// Bridge is a bridge running in the embedding process
+type Bridge struct {
+	opts   Options
+	server *api.Server
+
+	mu  sync.Mutex
+	ran bool
+}
+
+// New creates a bridge; Run starts it. It fails when the data directory
+// cannot be opened. With opts.LogOutput the process-wide zerolog logger
+// is redirected to it and the dashboard.
+func New(opts Options) (*Bridge, error) {
+	if opts.Addr == "" {
+		opts.Addr = DefaultAddr
+	}
+	if opts.WorkDir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		opts.WorkDir = cwd
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = 10 * time.Second
+	}
+
+	server, err := api.NewServerWithConfig(process.NewManager(opts.WorkDir), api.ServerConfig{
+		DataDir: opts.DataDir,
+		EnvFile: filepath.Join(opts.WorkDir, ".env"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	server.SetInsecureCORS(opts.InsecureCORS)
+
+	// 日志同时写入 dashboard 的内存缓冲区，保留结构化字段
+	if opts.LogOutput != nil {
+		log.Logger = log.Output(zerolog.MultiLevelWriter(opts.LogOutput, server.LogWriter()))
+	}
+
+	return &Bridge{opts: opts, server: server}, nil
+}
+
+// Run serves the API and runs the background tasks until ctx is done or
+// the listener fails, then shuts the bridge down. A bridge runs once.
+func (b *Bridge) Run(ctx context.Context) error {
+	b.mu.Lock()
+	if b.ran {
+		b.mu.Unlock()
+		return ErrAlreadyRun
+	}
+	b.ran = true
+	b.mu.Unlock()
+
+	life := lifecycle.New(ctx)
+	b.server.Run(life)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- b.server.Start(b.opts.Addr)
+	}()
+
+	var err error
+	select {
+	case err = <-served:
+	case <-life.Context().Done():
+		// ctx 结束或后台任务失败
+		err = life.Err()
+	}
+
+	log.Info().Msg("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), b.opts.ShutdownTimeout)
+	defer cancel()
+	if stopErr := life.Stop(shutdownCtx); stopErr != nil {
+		log.Warn().Err(stopErr).Msg("Background tasks did not stop cleanly")
+	}
+	if shutdownErr := b.server.Shutdown(shutdownCtx); shutdownErr != nil {
+		log.Warn().Err(shutdownErr).Msg("Shutdown did not complete cleanly")
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+// Addr returns the address the bridge listens on
+func (b *Bridge) Addr() string {
+	return b.opts.Addr
+}
+
+// DataDir returns the directory the bridge stores its state in
+func (b *Bridge) DataDir() string {
+	return b.server.DataDir()
+}
+
+// Handler returns the HTTP API with its middleware. Run already serves
+// it on Addr; this also serves it elsewhere, such as in-app.
+func (b *Bridge) Handler() http.Handler {
+	return b.server.Handler()
+}
+
+// Auth returns the pairing and token service
+func (b *Bridge) Auth() *auth.Service {
+	return b.server.AuthService()
+}
+
+// Sessions returns the chat session manager
+func (b *Bridge) Sessions() *session.Manager {
+	return b.server.Sessions()
+}
+
+// Processes returns the kernel process manager
+func (b *Bridge) Processes() *process.Manager {
+	return b.server.Processes()
+}
+
+// Terminals returns the terminal manager
+func (b *Bridge) Terminals() *terminal.Manager {
+	return b.server.Terminals()
+}
+
+// Workspaces returns the workspace service
+func (b *Bridge) Workspaces() *workspace.Service {
+	return b.server.Workspaces()
+}
+
+// Config returns the bridge's .env configuration
+func (b *Bridge) Config() *config.Service {
+	return b.server.Config()
+}
+
+// Events returns the bus the bridge publishes pairing, session and
+// process events on
+func (b *Bridge) Events() *events.Bus {
+	return b.server.Events()
+}